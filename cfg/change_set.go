@@ -0,0 +1,178 @@
+package cfg
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hatlonely/gox/cfg/storage"
+	"github.com/hatlonely/gox/log/logger"
+)
+
+// ChangeSet 一批配置变更，HandlerExecutionOptions.Debounce 时间窗口内的多次变更合并为一个变更集
+type ChangeSet struct {
+	// Paths 发生变更的路径，相对于注册监听的配置，按字典序排序，如 "database.port"、"servers[0].host"
+	// 空字符串表示注册监听的配置本身（如子配置由数组变为字符串）
+	Paths []string
+
+	// Storage 变更之后的配置
+	Storage storage.Storage
+}
+
+// Changed 判断 key 本身或者其下的配置是否发生变更，key 为空字符串时有任何变更即返回 true
+func (cs *ChangeSet) Changed(key string) bool {
+	for _, path := range cs.Paths {
+		if key == "" || path == "" || isPathUnder(path, key) || isPathUnder(key, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// isPathUnder 判断 path 是否等于 prefix 或者在 prefix 之下
+func isPathUnder(path string, prefix string) bool {
+	if !strings.HasPrefix(path, prefix) {
+		return false
+	}
+	rest := path[len(prefix):]
+	return rest == "" || rest[0] == '.' || rest[0] == '['
+}
+
+// batchChangeHandler OnBatchChange 注册的回调
+type batchChangeHandler struct {
+	key string // 相对于根配置的路径
+	fn  func(ChangeSet) error
+}
+
+// changedPaths 对比新旧的根配置，返回所有发生变更的路径
+// 根配置总是 map，转换为 map[string]any 以兼容合并多个配置源的 MultiStorage
+func changedPaths(oldStorage, newStorage storage.Storage) []string {
+	var oldData, newData map[string]any
+	if oldStorage != nil {
+		if err := oldStorage.ConvertTo(&oldData); err != nil {
+			return nil
+		}
+	}
+	if newStorage != nil {
+		if err := newStorage.ConvertTo(&newData); err != nil {
+			return nil
+		}
+	}
+	changes := logger.Diff(oldData, newData)
+	paths := make([]string, len(changes))
+	for i, change := range changes {
+		paths[i] = change.Path
+	}
+	return paths
+}
+
+// relativePaths 返回 key 之下的变更路径，路径相对于 key
+func relativePaths(paths []string, key string) []string {
+	var result []string
+	for _, path := range paths {
+		switch {
+		case key == "":
+			result = append(result, path)
+		case isPathUnder(path, key):
+			result = append(result, strings.TrimPrefix(path[len(key):], "."))
+		case isPathUnder(key, path):
+			// key 的祖先节点整体变更（如新增或删除），key 本身发生了变更
+			result = append(result, "")
+		}
+	}
+	return result
+}
+
+// batchHandlerFuncs 为每个有变更的批量回调生成变更集，返回按 key 分组的回调
+func batchHandlerFuncs(handlers []batchChangeHandler, oldStorage, newStorage storage.Storage) map[string][]func(storage.Storage) error {
+	if len(handlers) == 0 {
+		return nil
+	}
+	paths := changedPaths(oldStorage, newStorage)
+	if len(paths) == 0 {
+		return nil
+	}
+	result := map[string][]func(storage.Storage) error{}
+	for _, handler := range handlers {
+		relative := relativePaths(paths, handler.key)
+		if len(relative) == 0 {
+			continue
+		}
+		changeSet := ChangeSet{Paths: relative, Storage: newStorage.Sub(handler.key)}
+		fn := handler.fn
+		result[handler.key] = append(result[handler.key], func(storage.Storage) error {
+			return fn(changeSet)
+		})
+	}
+	return result
+}
+
+// changeDebouncer 合并时间窗口内的多次变更，窗口内没有新的变更时才触发一次通知
+// 通知时对比窗口内第一次变更之前的配置和最后一次变更之后的配置，变更之后又恢复原值时不会触发回调
+type changeDebouncer struct {
+	delay  time.Duration
+	notify func(oldStorage, newStorage storage.Storage)
+
+	mu         sync.Mutex
+	timer      *time.Timer
+	generation uint64
+	base       storage.Storage // 窗口内第一次变更之前的配置
+	latest     storage.Storage // 窗口内最后一次变更之后的配置
+	stopped    bool
+}
+
+func newChangeDebouncer(delay time.Duration, notify func(oldStorage, newStorage storage.Storage)) *changeDebouncer {
+	return &changeDebouncer{delay: delay, notify: notify}
+}
+
+// changed 记录一次从 oldStorage 到 newStorage 的变更，delay 为 0 时直接通知
+func (d *changeDebouncer) changed(oldStorage, newStorage storage.Storage) {
+	if d.delay <= 0 {
+		d.notify(oldStorage, newStorage)
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.stopped {
+		return
+	}
+	if d.timer == nil {
+		d.base = oldStorage
+	} else {
+		d.timer.Stop()
+	}
+	d.latest = newStorage
+	d.generation++
+	generation := d.generation
+	d.timer = time.AfterFunc(d.delay, func() {
+		d.fire(generation)
+	})
+}
+
+// fire 窗口结束时触发通知，窗口内又有新的变更时由新的定时器触发
+func (d *changeDebouncer) fire(generation uint64) {
+	d.mu.Lock()
+	if d.stopped || generation != d.generation {
+		d.mu.Unlock()
+		return
+	}
+	base, latest := d.base, d.latest
+	d.base, d.latest = nil, nil
+	d.timer = nil
+	d.mu.Unlock()
+
+	d.notify(base, latest)
+}
+
+// stop 停止防抖，尚未通知的变更不再触发回调
+func (d *changeDebouncer) stop() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.stopped = true
+	if d.timer != nil {
+		d.timer.Stop()
+		d.timer = nil
+	}
+	d.base, d.latest = nil, nil
+}
@@ -0,0 +1,218 @@
+package cfg
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hatlonely/gox/cfg/storage"
+)
+
+func TestChangeSet_Changed(t *testing.T) {
+	changeSet := &ChangeSet{Paths: []string{"database.port", "servers[0].host"}}
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{"", true},
+		{"database", true},
+		{"database.port", true},
+		{"database.host", false},
+		{"databases", false},
+		{"servers", true},
+		{"servers[0]", true},
+		{"servers[1]", false},
+	}
+	for _, tt := range tests {
+		if got := changeSet.Changed(tt.key); got != tt.want {
+			t.Errorf("Changed(%q) = %v, want %v", tt.key, got, tt.want)
+		}
+	}
+
+	if (&ChangeSet{}).Changed("") {
+		t.Error("expected no change for empty change set")
+	}
+}
+
+func TestSingleConfig_OnBatchChange(t *testing.T) {
+	config := newGetterTestConfig(t, "config.json", `{"database": {"host": "db", "port": 3306}, "mode": "a"}`, jsonDecoderOptions)
+	config.handlerExecution.Async = false
+
+	var rootChanges, databaseChanges, cacheChanges []ChangeSet
+	config.OnBatchChange(func(cs ChangeSet) error {
+		rootChanges = append(rootChanges, cs)
+		return nil
+	})
+	config.Sub("database").OnBatchChange(func(cs ChangeSet) error {
+		databaseChanges = append(databaseChanges, cs)
+		return nil
+	})
+	config.Sub("cache").OnBatchChange(func(cs ChangeSet) error {
+		cacheChanges = append(cacheChanges, cs)
+		return nil
+	})
+
+	if err := config.handleProviderChange([]byte(`{"database": {"host": "db2", "port": 3307}, "mode": "a"}`)); err != nil {
+		t.Fatalf("handleProviderChange() error = %v", err)
+	}
+	if len(rootChanges) != 1 || !reflect.DeepEqual(rootChanges[0].Paths, []string{"database.host", "database.port"}) {
+		t.Fatalf("unexpected root changes: %+v", rootChanges)
+	}
+	if len(databaseChanges) != 1 || !reflect.DeepEqual(databaseChanges[0].Paths, []string{"host", "port"}) {
+		t.Fatalf("unexpected database changes: %+v", databaseChanges)
+	}
+	var port int
+	if err := databaseChanges[0].Storage.Sub("port").ConvertTo(&port); err != nil || port != 3307 {
+		t.Errorf("expected new port 3307, got %d, err %v", port, err)
+	}
+	if len(cacheChanges) != 0 {
+		t.Errorf("expected no cache changes, got %+v", cacheChanges)
+	}
+
+	// 新增的子树整体作为变更路径
+	if err := config.handleProviderChange([]byte(`{"database": {"host": "db2", "port": 3307}, "mode": "a", "cache": {"size": 10}}`)); err != nil {
+		t.Fatalf("handleProviderChange() error = %v", err)
+	}
+	if len(cacheChanges) != 1 || !reflect.DeepEqual(cacheChanges[0].Paths, []string{""}) {
+		t.Errorf("unexpected cache changes: %+v", cacheChanges)
+	}
+	if len(databaseChanges) != 1 {
+		t.Errorf("expected no new database changes, got %+v", databaseChanges)
+	}
+}
+
+func TestSingleConfig_Debounce(t *testing.T) {
+	config := newGetterTestConfig(t, "config.json", `{"database": {"host": "db", "port": 3306}, "mode": "a"}`, jsonDecoderOptions)
+	config.handlerExecution.Async = false
+	config.debouncer = newChangeDebouncer(100*time.Millisecond, config.notifyChange)
+
+	var mu sync.Mutex
+	var changes int
+	var batches []ChangeSet
+	config.OnKeyChange("database", func(storage.Storage) error {
+		mu.Lock()
+		defer mu.Unlock()
+		changes++
+		return nil
+	})
+	config.OnBatchChange(func(cs ChangeSet) error {
+		mu.Lock()
+		defer mu.Unlock()
+		batches = append(batches, cs)
+		return nil
+	})
+
+	for _, data := range []string{
+		`{"database": {"host": "db2", "port": 3306}, "mode": "a"}`,
+		`{"database": {"host": "db2", "port": 3307}, "mode": "a"}`,
+		`{"database": {"host": "db2", "port": 3307}, "mode": "b"}`,
+	} {
+		if err := config.handleProviderChange([]byte(data)); err != nil {
+			t.Fatalf("handleProviderChange() error = %v", err)
+		}
+	}
+
+	// 配置立即生效，回调在窗口结束后只触发一次
+	if got := GetString(config, "mode", ""); got != "b" {
+		t.Errorf("expected config to be updated immediately, got mode %q", got)
+	}
+	mu.Lock()
+	if changes != 0 || len(batches) != 0 {
+		t.Errorf("expected no handler before debounce window ends, got %d, %d", changes, len(batches))
+	}
+	mu.Unlock()
+
+	time.Sleep(300 * time.Millisecond)
+	mu.Lock()
+	if changes != 1 || len(batches) != 1 {
+		t.Fatalf("expected one notification, got %d, %d", changes, len(batches))
+	}
+	if want := []string{"database.host", "database.port", "mode"}; !reflect.DeepEqual(batches[0].Paths, want) {
+		t.Errorf("Paths = %v, want %v", batches[0].Paths, want)
+	}
+	mu.Unlock()
+
+	// 窗口内变更之后又恢复原值，不触发回调
+	if err := config.handleProviderChange([]byte(`{"database": {"host": "db3", "port": 3307}, "mode": "b"}`)); err != nil {
+		t.Fatalf("handleProviderChange() error = %v", err)
+	}
+	if err := config.handleProviderChange([]byte(`{"database": {"host": "db2", "port": 3307}, "mode": "b"}`)); err != nil {
+		t.Fatalf("handleProviderChange() error = %v", err)
+	}
+	time.Sleep(300 * time.Millisecond)
+	mu.Lock()
+	if changes != 1 || len(batches) != 1 {
+		t.Errorf("expected no notification for reverted change, got %d, %d", changes, len(batches))
+	}
+	mu.Unlock()
+
+	// 关闭之后尚未通知的变更被丢弃
+	if err := config.handleProviderChange([]byte(`{"database": {"host": "db4", "port": 3307}, "mode": "b"}`)); err != nil {
+		t.Fatalf("handleProviderChange() error = %v", err)
+	}
+	config.Close()
+	time.Sleep(300 * time.Millisecond)
+	mu.Lock()
+	if changes != 1 {
+		t.Errorf("expected no notification after close, got %d", changes)
+	}
+	mu.Unlock()
+}
+
+func TestMultiConfig_Debounce(t *testing.T) {
+	tempDir := t.TempDir()
+	baseFile := filepath.Join(tempDir, "base.json")
+	overrideFile := filepath.Join(tempDir, "override.json")
+	if err := os.WriteFile(baseFile, []byte(`{"database": {"host": "db", "port": 3306}}`), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	if err := os.WriteFile(overrideFile, []byte(`{}`), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	baseSource, err := createFileSourceOptions(baseFile)
+	if err != nil {
+		t.Fatalf("Failed to create file source options: %v", err)
+	}
+	overrideSource, err := createFileSourceOptions(overrideFile)
+	if err != nil {
+		t.Fatalf("Failed to create file source options: %v", err)
+	}
+
+	config, err := NewMultiConfigWithOptions(&MultiConfigOptions{
+		Sources:          []*ConfigSourceOptions{baseSource, overrideSource},
+		HandlerExecution: &HandlerExecutionOptions{Debounce: 100 * time.Millisecond},
+	})
+	if err != nil {
+		t.Fatalf("NewMultiConfigWithOptions() error = %v", err)
+	}
+	defer config.Close()
+
+	var mu sync.Mutex
+	var batches []ChangeSet
+	config.Sub("database").OnBatchChange(func(cs ChangeSet) error {
+		mu.Lock()
+		defer mu.Unlock()
+		batches = append(batches, cs)
+		return nil
+	})
+
+	if err := config.handleSourceChange(0, []byte(`{"database": {"host": "db2", "port": 3306}}`)); err != nil {
+		t.Fatalf("handleSourceChange() error = %v", err)
+	}
+	if err := config.handleSourceChange(1, []byte(`{"database": {"port": 3307}}`)); err != nil {
+		t.Fatalf("handleSourceChange() error = %v", err)
+	}
+
+	time.Sleep(300 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	if len(batches) != 1 {
+		t.Fatalf("expected one notification, got %+v", batches)
+	}
+	if want := []string{"host", "port"}; !reflect.DeepEqual(batches[0].Paths, want) {
+		t.Errorf("Paths = %v, want %v", batches[0].Paths, want)
+	}
+}
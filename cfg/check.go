@@ -0,0 +1,217 @@
+package cfg
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	govalidator "github.com/go-playground/validator/v10"
+	"github.com/hatlonely/gox/cfg/storage"
+	"github.com/hatlonely/gox/cfg/validator"
+)
+
+// IssueCode 配置问题的类型
+type IssueCode string
+
+const (
+	// IssueUnknownKey 配置中存在结构体没有定义的配置项，通常是拼写错误或者已经废弃的配置
+	IssueUnknownKey IssueCode = "unknownKey"
+	// IssueInvalidValue 配置项的值无法转换成字段的类型
+	IssueInvalidValue IssueCode = "invalidValue"
+	// IssueValidationFailed 配置项的值不满足 validate 标签的规则
+	IssueValidationFailed IssueCode = "validationFailed"
+)
+
+// Issue 配置检查发现的问题
+type Issue struct {
+	// Path 配置项的路径，格式与 Sub 的 key 相同，如 "servers[0].port"，空字符串表示根配置
+	Path string `json:"path"`
+	// Code 问题类型
+	Code IssueCode `json:"code"`
+	// Rule 未通过的 validate 规则，如 "min=1"，只有 IssueValidationFailed 有值
+	Rule string `json:"rule,omitempty"`
+	// Message 可读的问题说明
+	Message string `json:"message"`
+}
+
+func (i Issue) String() string {
+	path := i.Path
+	if path == "" {
+		path = "<root>"
+	}
+	return fmt.Sprintf("%s: %s", path, i.Message)
+}
+
+// Validate 检查配置文件，用于实现 "myapp config check" 之类的子命令，在发布配置之前发现问题
+// 按文件后缀选择解码器（同 NewSingleConfig），转换到 target 并设置默认值，然后执行 validate 标签的校验，
+// 同时检查配置中结构体没有定义的配置项。target 为结构体指针，检查通过时 target 为最终生效的配置
+// 文件无法读取或解析时返回 error，配置内容的问题通过 []Issue 返回，按路径排序，没有问题时为空
+func Validate(path string, target any) ([]Issue, error) {
+	rv := reflect.ValueOf(target)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return nil, fmt.Errorf("target must be a non-nil pointer, got %T", target)
+	}
+
+	config, err := NewSingleConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	defer config.Close()
+
+	var data any
+	if err := config.ConvertTo(&data); err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	var issues []Issue
+	checkUnknownKeys(reflect.ValueOf(data), rv.Type(), "", &issues)
+
+	if err := storage.NewMapStorage(data).ConvertTo(target); err != nil {
+		var convertErr *storage.ConvertError
+		if !errors.As(err, &convertErr) {
+			return nil, fmt.Errorf("failed to convert config: %w", err)
+		}
+		issues = append(issues, Issue{Path: convertErr.Path, Code: IssueInvalidValue, Message: convertErr.Err.Error()})
+	} else if err := validator.ValidateStruct(target); err != nil {
+		var fieldErrs govalidator.ValidationErrors
+		if !errors.As(err, &fieldErrs) {
+			return nil, fmt.Errorf("failed to validate config: %w", err)
+		}
+		for _, fe := range fieldErrs {
+			rule := fe.Tag()
+			if fe.Param() != "" {
+				rule += "=" + fe.Param()
+			}
+			issues = append(issues, Issue{
+				Path:    configPath(rv.Type(), fe.StructNamespace()),
+				Code:    IssueValidationFailed,
+				Rule:    rule,
+				Message: validator.Describe(fe),
+			})
+		}
+	}
+
+	sort.SliceStable(issues, func(i, j int) bool {
+		return issues[i].Path < issues[j].Path
+	})
+	return issues, nil
+}
+
+// checkUnknownKeys 对照目标类型检查配置数据，记录结构体没有定义的配置项
+// 字段名的匹配规则与 ConvertTo 相同，interface 类型的字段（如 ref.TypeOptions 的 options）无法确定结构，不做检查
+func checkUnknownKeys(data reflect.Value, typ reflect.Type, path string, issues *[]Issue) {
+	for data.Kind() == reflect.Interface && !data.IsNil() {
+		data = data.Elem()
+	}
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if !data.IsValid() {
+		return
+	}
+
+	switch typ.Kind() {
+	case reflect.Struct:
+		if data.Kind() != reflect.Map {
+			return
+		}
+		fields := map[string]reflect.Type{}
+		if typ.Name() == "TypeOptions" && strings.HasSuffix(typ.PkgPath(), "ref") {
+			fields = map[string]reflect.Type{"namespace": nil, "type": nil, "options": nil, "retry": nil, "lazy": nil, "expand": nil}
+		} else {
+			for i := 0; i < typ.NumField(); i++ {
+				field := typ.Field(i)
+				if field.IsExported() {
+					fields[structFieldConfigName(field)] = field.Type
+				}
+			}
+		}
+		for _, key := range data.MapKeys() {
+			name := fmt.Sprint(key.Interface())
+			fieldType, ok := fields[name]
+			if !ok {
+				*issues = append(*issues, Issue{Path: joinKey(path, name), Code: IssueUnknownKey, Message: "unknown config key"})
+				continue
+			}
+			if fieldType != nil {
+				checkUnknownKeys(data.MapIndex(key), fieldType, joinKey(path, name), issues)
+			}
+		}
+	case reflect.Map:
+		if data.Kind() != reflect.Map {
+			return
+		}
+		for _, key := range data.MapKeys() {
+			checkUnknownKeys(data.MapIndex(key), typ.Elem(), joinKey(path, fmt.Sprint(key.Interface())), issues)
+		}
+	case reflect.Slice, reflect.Array:
+		if data.Kind() != reflect.Slice && data.Kind() != reflect.Array {
+			return
+		}
+		for i := 0; i < data.Len(); i++ {
+			checkUnknownKeys(data.Index(i), typ.Elem(), path+"["+strconv.Itoa(i)+"]", issues)
+		}
+	}
+}
+
+// structFieldConfigName 字段对应的配置项名称，与 ConvertTo 的规则相同：cfg > json > yaml > toml > ini > 字段名
+func structFieldConfigName(field reflect.StructField) string {
+	for _, tag := range []string{"cfg", "json", "yaml", "toml", "ini"} {
+		value := field.Tag.Get(tag)
+		if value == "" {
+			continue
+		}
+		if name := strings.Split(value, ",")[0]; name != "-" && name != "" {
+			return name
+		}
+		return field.Name
+	}
+	return field.Name
+}
+
+// configPath 将 validator 的结构体路径（如 "AppConfig.Servers[0].Port"）转换为配置路径（如 "servers[0].port"）
+func configPath(typ reflect.Type, namespace string) string {
+	segments := strings.Split(namespace, ".")
+	if len(segments) <= 1 {
+		return ""
+	}
+
+	path := ""
+	for _, segment := range segments[1:] {
+		name, indexes, _ := strings.Cut(segment, "[")
+		for typ.Kind() == reflect.Ptr {
+			typ = typ.Elem()
+		}
+		if typ.Kind() != reflect.Struct {
+			return joinKey(path, segment)
+		}
+		field, ok := typ.FieldByName(name)
+		if !ok {
+			return joinKey(path, segment)
+		}
+		path = joinKey(path, structFieldConfigName(field))
+		typ = field.Type
+
+		// 数组下标保留为 [i]，map 的键转换为 .key
+		for indexes != "" {
+			var index string
+			index, indexes, _ = strings.Cut(indexes, "]")
+			indexes = strings.TrimPrefix(indexes, "[")
+			for typ.Kind() == reflect.Ptr {
+				typ = typ.Elem()
+			}
+			if typ.Kind() == reflect.Map {
+				path = joinKey(path, index)
+			} else {
+				path += "[" + index + "]"
+			}
+			if typ.Kind() == reflect.Map || typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array {
+				typ = typ.Elem()
+			}
+		}
+	}
+	return path
+}
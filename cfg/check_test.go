@@ -0,0 +1,115 @@
+package cfg
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hatlonely/gox/ref"
+)
+
+type checkTestServer struct {
+	Host string `cfg:"host" validate:"required"`
+	Port int    `cfg:"port" validate:"min=1,max=65535"`
+}
+
+type checkTestConfig struct {
+	Name    string                      `cfg:"name" validate:"required"`
+	Timeout time.Duration               `cfg:"timeout" def:"3s" validate:"durmax=10s"`
+	Servers []checkTestServer           `cfg:"servers" validate:"dive"`
+	Groups  map[string]*checkTestServer `cfg:"groups" validate:"dive"`
+	Logger  *ref.TypeOptions            `cfg:"logger"`
+}
+
+func writeCheckTestFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestValidate(t *testing.T) {
+	path := writeCheckTestFile(t, "config.yaml", `
+name: app
+servers:
+  - host: a
+    port: 80
+  - host: b
+    port: 0
+    weight: 1
+groups:
+  primary:
+    port: 3306
+logger:
+  namespace: github.com/hatlonely/gox/log
+  type: SLog
+  options:
+    level: info
+verbose: true
+`)
+
+	var config checkTestConfig
+	issues, err := Validate(path, &config)
+	if err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	want := []Issue{
+		{Path: "groups.primary.host", Code: IssueValidationFailed, Rule: "required", Message: "is required"},
+		{Path: "servers[1].port", Code: IssueValidationFailed, Rule: "min=1", Message: "must satisfy min=1, got 0"},
+		{Path: "servers[1].weight", Code: IssueUnknownKey, Message: "unknown config key"},
+		{Path: "verbose", Code: IssueUnknownKey, Message: "unknown config key"},
+	}
+	if !reflect.DeepEqual(issues, want) {
+		t.Errorf("Validate() issues = %+v, want %+v", issues, want)
+	}
+	if config.Timeout != 3*time.Second {
+		t.Errorf("expected default timeout to be applied, got %v", config.Timeout)
+	}
+	if got := issues[1].String(); got != "servers[1].port: must satisfy min=1, got 0" {
+		t.Errorf("Issue.String() = %q", got)
+	}
+}
+
+func TestValidate_InvalidValue(t *testing.T) {
+	path := writeCheckTestFile(t, "config.json", `{"name": "app", "servers": [{"host": "a", "port": "http"}], "timeout": "1m"}`)
+
+	issues, err := Validate(path, &checkTestConfig{})
+	if err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if len(issues) != 1 || issues[0].Path != "servers[0].port" || issues[0].Code != IssueInvalidValue {
+		t.Errorf("unexpected issues: %+v", issues)
+	}
+
+	// 转换成功时执行范围校验
+	path = writeCheckTestFile(t, "config.json", `{"name": "app", "timeout": "1m"}`)
+	issues, err = Validate(path, &checkTestConfig{})
+	if err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if len(issues) != 1 || issues[0].Path != "timeout" || !strings.Contains(issues[0].Message, "at most 10s") {
+		t.Errorf("unexpected issues: %+v", issues)
+	}
+}
+
+func TestValidate_Errors(t *testing.T) {
+	path := writeCheckTestFile(t, "config.json", `{"name": "app"}`)
+	if issues, err := Validate(path, &checkTestConfig{}); err != nil || len(issues) != 0 {
+		t.Errorf("expected no issues, got %+v, %v", issues, err)
+	}
+
+	if _, err := Validate(path, checkTestConfig{}); err == nil {
+		t.Error("expected error for non-pointer target")
+	}
+	if _, err := Validate(filepath.Join(t.TempDir(), "missing.json"), &checkTestConfig{}); err == nil {
+		t.Error("expected error for missing file")
+	}
+	if _, err := Validate(writeCheckTestFile(t, "bad.json", `{"name":`), &checkTestConfig{}); err == nil {
+		t.Error("expected error for malformed file")
+	}
+}
@@ -0,0 +1,73 @@
+package commonopt
+
+import (
+	"context"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// RetryOptions 通用的重试策略，按指数退避重试，默认不重试
+type RetryOptions struct {
+	// MaxAttempts 最大尝试次数（含首次），小于等于 1 时不重试
+	MaxAttempts int `cfg:"maxAttempts" def:"1" validate:"gte=0"`
+	// Backoff 首次重试前的等待时间，之后每次翻倍
+	Backoff time.Duration `cfg:"backoff" def:"1s" validate:"gte=0"`
+	// MaxBackoff 重试等待时间上限，为 0 时不限制
+	MaxBackoff time.Duration `cfg:"maxBackoff" def:"30s" validate:"gte=0"`
+	// Jitter 等待时间的随机抖动比例，取值 [0, 1]，如 0.2 表示在 ±20% 范围内随机，避免多个实例同时重试
+	Jitter float64 `cfg:"jitter" validate:"gte=0,lte=1"`
+}
+
+// NewRetryOptions 创建重试策略，maxAttempts 小于等于 1 时不重试
+func NewRetryOptions(maxAttempts int, backoff time.Duration, maxBackoff time.Duration) *RetryOptions {
+	return &RetryOptions{MaxAttempts: maxAttempts, Backoff: backoff, MaxBackoff: maxBackoff}
+}
+
+// BackoffFor 第 attempt 次失败后的等待时间，attempt 从 1 开始，已包含抖动
+func (o *RetryOptions) BackoffFor(attempt int) time.Duration {
+	backoff := o.Backoff
+	for i := 1; i < attempt && backoff > 0 && backoff <= math.MaxInt64/2; i++ {
+		if o.MaxBackoff > 0 && backoff >= o.MaxBackoff {
+			break
+		}
+		backoff *= 2
+	}
+	if o.MaxBackoff > 0 && backoff > o.MaxBackoff {
+		backoff = o.MaxBackoff
+	}
+	return Jitter(backoff, o.Jitter)
+}
+
+// Do 按重试策略执行 fn，直到成功、达到最大尝试次数或 ctx 结束，返回最后一次的错误
+// options 为 nil 时只执行一次
+func (o *RetryOptions) Do(ctx context.Context, fn func() error) error {
+	return o.DoIf(ctx, fn, nil)
+}
+
+// DoIf 与 Do 相同，只重试 retryable 返回 true 的错误，retryable 为 nil 时重试所有错误
+func (o *RetryOptions) DoIf(ctx context.Context, fn func() error, retryable func(err error) bool) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || o == nil || attempt >= o.MaxAttempts || (retryable != nil && !retryable(err)) {
+			return err
+		}
+
+		timer := time.NewTimer(o.BackoffFor(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// Jitter 在 d 的 ±jitter 比例范围内随机取值，jitter 大于 1 时按 1 处理
+func Jitter(d time.Duration, jitter float64) time.Duration {
+	if jitter <= 0 || d <= 0 {
+		return d
+	}
+	jitter = min(jitter, 1)
+	return time.Duration(float64(d) * (1 + jitter*(2*rand.Float64()-1)))
+}
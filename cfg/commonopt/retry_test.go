@@ -0,0 +1,106 @@
+package commonopt
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hatlonely/gox/cfg/def"
+	"github.com/hatlonely/gox/cfg/validator"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRetryOptions(t *testing.T) {
+	Convey("测试重试策略", t, func() {
+		Convey("默认值不重试", func() {
+			var options RetryOptions
+			So(def.SetDefaults(&options), ShouldBeNil)
+			So(options, ShouldResemble, RetryOptions{MaxAttempts: 1, Backoff: time.Second, MaxBackoff: 30 * time.Second})
+			So(validator.ValidateStruct(&options), ShouldBeNil)
+
+			options.Jitter = 1.5
+			So(validator.ValidateStruct(&options), ShouldNotBeNil)
+		})
+
+		Convey("退避时间翻倍且不超过上限", func() {
+			options := NewRetryOptions(10, 100*time.Millisecond, time.Second)
+			So(options.BackoffFor(1), ShouldEqual, 100*time.Millisecond)
+			So(options.BackoffFor(2), ShouldEqual, 200*time.Millisecond)
+			So(options.BackoffFor(4), ShouldEqual, 800*time.Millisecond)
+			So(options.BackoffFor(5), ShouldEqual, time.Second)
+			So(options.BackoffFor(100), ShouldEqual, time.Second)
+
+			options.MaxBackoff = 0
+			So(options.BackoffFor(100), ShouldBeGreaterThan, 0)
+		})
+
+		Convey("重试直到成功", func() {
+			attempts := 0
+			err := NewRetryOptions(3, time.Millisecond, 0).Do(context.Background(), func() error {
+				attempts++
+				if attempts < 3 {
+					return errors.New("unavailable")
+				}
+				return nil
+			})
+			So(err, ShouldBeNil)
+			So(attempts, ShouldEqual, 3)
+		})
+
+		Convey("达到最大尝试次数返回最后一次的错误", func() {
+			attempts := 0
+			err := NewRetryOptions(2, time.Millisecond, 0).Do(context.Background(), func() error {
+				attempts++
+				return errors.New("unavailable")
+			})
+			So(err, ShouldNotBeNil)
+			So(attempts, ShouldEqual, 2)
+		})
+
+		Convey("nil 只执行一次", func() {
+			var options *RetryOptions
+			attempts := 0
+			So(options.Do(context.Background(), func() error {
+				attempts++
+				return errors.New("unavailable")
+			}), ShouldNotBeNil)
+			So(attempts, ShouldEqual, 1)
+		})
+
+		Convey("只重试可重试的错误", func() {
+			permanent := errors.New("permanent")
+			attempts := 0
+			err := NewRetryOptions(3, time.Millisecond, 0).DoIf(context.Background(), func() error {
+				attempts++
+				return permanent
+			}, func(err error) bool { return !errors.Is(err, permanent) })
+			So(err, ShouldEqual, permanent)
+			So(attempts, ShouldEqual, 1)
+		})
+
+		Convey("ctx 结束时停止等待", func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+			defer cancel()
+			start := time.Now()
+			err := NewRetryOptions(3, time.Minute, 0).Do(ctx, func() error {
+				return errors.New("unavailable")
+			})
+			So(err, ShouldNotBeNil)
+			So(time.Since(start), ShouldBeLessThan, time.Second)
+		})
+	})
+}
+
+func TestJitter(t *testing.T) {
+	Convey("测试退避抖动", t, func() {
+		So(Jitter(100*time.Millisecond, 0), ShouldEqual, 100*time.Millisecond)
+		for i := 0; i < 100; i++ {
+			d := Jitter(100*time.Millisecond, 0.2)
+			So(d, ShouldBeBetweenOrEqual, 80*time.Millisecond, 120*time.Millisecond)
+		}
+		for i := 0; i < 100; i++ {
+			So(Jitter(100*time.Millisecond, 5), ShouldBeBetweenOrEqual, 0, 200*time.Millisecond)
+		}
+	})
+}
@@ -0,0 +1,71 @@
+package commonopt
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// TLSOptions 通用的 TLS 客户端配置
+type TLSOptions struct {
+	// CAFile 校验服务端证书的 CA 证书文件（PEM），为空时使用系统根证书
+	CAFile string `cfg:"caFile"`
+	// CertFile、KeyFile 客户端证书和私钥文件（PEM），服务端要求双向认证时配置，需要同时配置
+	CertFile string `cfg:"certFile" validate:"required_with=KeyFile"`
+	KeyFile  string `cfg:"keyFile" validate:"required_with=CertFile"`
+	// ServerName 校验证书时使用的服务端名称，为空时使用连接地址中的主机名
+	ServerName string `cfg:"serverName"`
+	// InsecureSkipVerify 跳过服务端证书校验，仅用于测试环境
+	InsecureSkipVerify bool `cfg:"insecureSkipVerify"`
+	// MinVersion 最低 TLS 版本：1.0、1.1、1.2、1.3
+	MinVersion string `cfg:"minVersion" def:"1.2" validate:"omitempty,oneof=1.0 1.1 1.2 1.3"`
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// NewTLSConfigWithOptions 根据配置创建 tls.Config，options 为 nil 时返回 nil，表示不使用 TLS
+func NewTLSConfigWithOptions(options *TLSOptions) (*tls.Config, error) {
+	if options == nil {
+		return nil, nil
+	}
+
+	config := &tls.Config{
+		ServerName:         options.ServerName,
+		InsecureSkipVerify: options.InsecureSkipVerify,
+	}
+	if options.MinVersion != "" {
+		version, ok := tlsVersions[options.MinVersion]
+		if !ok {
+			return nil, fmt.Errorf("unsupported tls version: %s", options.MinVersion)
+		}
+		config.MinVersion = version
+	}
+
+	if options.CAFile != "" {
+		pem, err := os.ReadFile(options.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ca file: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in ca file %s", options.CAFile)
+		}
+		config.RootCAs = pool
+	}
+
+	if options.CertFile != "" || options.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(options.CertFile, options.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %v", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
@@ -0,0 +1,107 @@
+package commonopt
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hatlonely/gox/cfg/def"
+	"github.com/hatlonely/gox/cfg/validator"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// writeTestCertificate 在 dir 下生成自签名证书和私钥，返回文件路径
+func writeTestCertificate(t *testing.T, dir string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "localhost"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestNewTLSConfigWithOptions(t *testing.T) {
+	Convey("测试 TLS 配置", t, func() {
+		certFile, keyFile := writeTestCertificate(t, t.TempDir())
+
+		Convey("nil 表示不使用 TLS", func() {
+			config, err := NewTLSConfigWithOptions(nil)
+			So(err, ShouldBeNil)
+			So(config, ShouldBeNil)
+		})
+
+		Convey("默认最低版本为 1.2", func() {
+			var options TLSOptions
+			So(def.SetDefaults(&options), ShouldBeNil)
+			So(validator.ValidateStruct(&options), ShouldBeNil)
+			config, err := NewTLSConfigWithOptions(&options)
+			So(err, ShouldBeNil)
+			So(config.MinVersion, ShouldEqual, tls.VersionTLS12)
+			So(config.RootCAs, ShouldBeNil)
+			So(config.Certificates, ShouldBeEmpty)
+		})
+
+		Convey("加载 CA 和客户端证书", func() {
+			config, err := NewTLSConfigWithOptions(&TLSOptions{
+				CAFile:     certFile,
+				CertFile:   certFile,
+				KeyFile:    keyFile,
+				ServerName: "db.example.com",
+				MinVersion: "1.3",
+			})
+			So(err, ShouldBeNil)
+			So(config.RootCAs, ShouldNotBeNil)
+			So(config.Certificates, ShouldHaveLength, 1)
+			So(config.ServerName, ShouldEqual, "db.example.com")
+			So(config.MinVersion, ShouldEqual, tls.VersionTLS13)
+		})
+
+		Convey("证书和私钥需要同时配置", func() {
+			So(validator.ValidateStruct(&TLSOptions{CertFile: certFile}), ShouldNotBeNil)
+			_, err := NewTLSConfigWithOptions(&TLSOptions{CertFile: certFile})
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("错误的配置", func() {
+			So(validator.ValidateStruct(&TLSOptions{MinVersion: "2.0"}), ShouldNotBeNil)
+			_, err := NewTLSConfigWithOptions(&TLSOptions{MinVersion: "2.0"})
+			So(err, ShouldNotBeNil)
+			_, err = NewTLSConfigWithOptions(&TLSOptions{CAFile: filepath.Join(t.TempDir(), "missing.pem")})
+			So(err, ShouldNotBeNil)
+			_, err = NewTLSConfigWithOptions(&TLSOptions{CAFile: keyFile})
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
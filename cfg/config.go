@@ -24,12 +24,30 @@ type Config interface {
 	// OnKeyChange 监听指定键的配置变更
 	OnKeyChange(key string, fn func(storage.Storage) error)
 
+	// OnBatchChange 监听配置变更，回调收到变更之后的配置以及相对于当前配置的变更路径
+	// 与 HandlerExecutionOptions.Debounce 配合，短时间内的多次变更（如文件被多次写入、etcd 批量更新）只触发一次回调
+	OnBatchChange(fn func(ChangeSet) error)
+
+	// OnValidate 注册配置变更的校验，key 为相对于当前配置的路径，空字符串表示当前配置
+	// 配置变更时先用新配置执行所有校验，任一校验失败时拒绝整个变更，继续使用原来的配置，
+	// 不触发变更监听，错误记录到 Status 并输出日志；只作用于之后的变更，不校验当前配置
+	OnValidate(key string, fn func(storage.Storage) error)
+
 	// Watch 启动配置变更监听
 	// 只有调用此方法后，OnChange 和 OnKeyChange 注册的回调函数才会被触发
 	// 对于不支持监听的 Provider，此方法静默处理不返回错误
 	// 为了防止在 NewConfig 和 Watch 之间丢失配置变更，会主动检查一次配置
 	Watch() error
 
+	// Status 获取配置源的运行状态
+	// 包括每个配置源最后一次成功加载的时间、最后一次错误以及监听状态
+	// 子配置返回根配置的状态
+	Status() Status
+
+	// Snapshot 获取配置快照，包括合并之后生效的配置（已脱敏）、配置源信息以及每个配置项的来源
+	// 子配置返回子配置部分的快照
+	Snapshot() (*Snapshot, error)
+
 	// Close 关闭配置对象，释放相关资源
 	// 只有根配置对象才能执行关闭操作，子配置对象会将关闭请求转发到根配置
 	// 多次调用只会执行一次，后续调用直接返回第一次调用的结果
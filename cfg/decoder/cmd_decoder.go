@@ -137,7 +137,7 @@ func (c *CmdDecoder) Encode(s storage.Storage) ([]byte, error) {
 
 	// 尝试获取FlatStorage的数据
 	if flatStorage, ok := s.(*storage.FlatStorage); ok {
-		data = flatStorage.Data()
+		data = flatStorage.UnsafeData()
 	} else {
 		// 如果不是FlatStorage，尝试转换
 		if err := s.ConvertTo(&data); err != nil {
@@ -32,7 +32,7 @@ type Decoder interface {
 }
 
 func NewDecoderWithOptions(options *ref.TypeOptions) (Decoder, error) {
-	decoder, err := ref.New(options.Namespace, options.Type, options.Options)
+	decoder, err := ref.NewWithOptions(options)
 	if err != nil {
 		return nil, errors.WithMessage(err, "refx.NewT failed")
 	}
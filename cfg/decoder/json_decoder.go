@@ -1,8 +1,10 @@
 package decoder
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"regexp"
 	"strings"
 
@@ -13,6 +15,9 @@ import (
 type JsonDecoderOptions struct {
 	// UseJSON5 是否使用JSON5解析器（支持注释、尾随逗号等）
 	UseJSON5 bool `cfg:"useJSON5"`
+	// UseNumber 是否将数值解码为 json.Number 而不是 float64
+	// 开启后可以保留超过 2^53 的大整数（如 int64 ID）和高精度小数的原始精度
+	UseNumber bool `cfg:"useNumber"`
 }
 
 // JsonDecoder JSON格式编解码器
@@ -20,6 +25,8 @@ type JsonDecoderOptions struct {
 type JsonDecoder struct {
 	// useJSON5 是否使用JSON5解析器（支持注释、尾随逗号等）
 	useJSON5 bool
+	// useNumber 是否将数值解码为 json.Number，保留原始精度
+	useNumber bool
 }
 
 // NewJsonDecoder 创建新的JSON解码器，使用默认配置
@@ -36,7 +43,8 @@ func NewJsonDecoderWithOptions(options *JsonDecoderOptions) *JsonDecoder {
 		return NewJsonDecoder()
 	}
 	return &JsonDecoder{
-		useJSON5: options.UseJSON5,
+		useJSON5:  options.UseJSON5,
+		useNumber: options.UseNumber,
 	}
 }
 
@@ -48,10 +56,10 @@ func (j *JsonDecoder) Decode(data []byte) (storage.Storage, error) {
 	if j.useJSON5 {
 		// 使用自定义JSON5预处理，支持注释和宽松格式
 		processedData := j.preprocessJSON5(data)
-		err = json.Unmarshal(processedData, &result)
+		err = j.unmarshal(processedData, &result)
 	} else {
 		// 使用标准JSON解析器
-		err = json.Unmarshal(data, &result)
+		err = j.unmarshal(data, &result)
 	}
 
 	if err != nil {
@@ -62,6 +70,24 @@ func (j *JsonDecoder) Decode(data []byte) (storage.Storage, error) {
 	return storage.NewMapStorage(result), nil
 }
 
+// unmarshal 解析JSON数据，开启 useNumber 时数值解码为 json.Number
+func (j *JsonDecoder) unmarshal(data []byte, v interface{}) error {
+	if !j.useNumber {
+		return json.Unmarshal(data, v)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	// 与 json.Unmarshal 保持一致，不允许有多余的数据，More 对多余的 } 或 ] 返回 false，需要用 Token 判断
+	if _, err := dec.Token(); err != io.EOF {
+		return fmt.Errorf("invalid character after top-level value")
+	}
+	return nil
+}
+
 // preprocessJSON5 预处理JSON5格式，移除注释和处理宽松语法
 func (j *JsonDecoder) preprocessJSON5(data []byte) []byte {
 	content := string(data)
@@ -193,7 +219,7 @@ func (j *JsonDecoder) Encode(s storage.Storage) ([]byte, error) {
 
 	// 尝试直接获取MapStorage的内部数据
 	if mapStorage, ok := s.(*storage.MapStorage); ok {
-		data = mapStorage.Data()
+		data = mapStorage.UnsafeData()
 	} else {
 		// 如果不是MapStorage，尝试转换为通用interface{}
 		if err := s.ConvertTo(&data); err != nil {
@@ -1,6 +1,8 @@
 package decoder
 
 import (
+	"encoding/json"
+	"strings"
 	"testing"
 	"time"
 )
@@ -306,4 +308,98 @@ func TestJsonDecoder_Encode(t *testing.T) {
 	if host != "localhost" {
 		t.Errorf("Expected host 'localhost', got %v", host)
 	}
-}
\ No newline at end of file
+}
+func TestJsonDecoder_UseNumber(t *testing.T) {
+	jsonData := `{
+		// 超过 2^53 的 ID
+		"id": 9007199254740993,
+		"ratio": 0.1,
+	}`
+
+	t.Run("UseNumber preserves precision", func(t *testing.T) {
+		decoder := NewJsonDecoderWithOptions(&JsonDecoderOptions{UseJSON5: true, UseNumber: true})
+		storage, err := decoder.Decode([]byte(jsonData))
+		if err != nil {
+			t.Fatalf("Failed to decode JSON: %v", err)
+		}
+
+		var id int64
+		if err := storage.Sub("id").ConvertTo(&id); err != nil {
+			t.Fatalf("Failed to get id: %v", err)
+		}
+		if id != 9007199254740993 {
+			t.Errorf("Expected id 9007199254740993, got %v", id)
+		}
+
+		var data map[string]interface{}
+		if err := storage.ConvertTo(&data); err != nil {
+			t.Fatalf("Failed to convert: %v", err)
+		}
+		if data["id"] != json.Number("9007199254740993") {
+			t.Errorf("Expected json.Number, got %T %v", data["id"], data["id"])
+		}
+
+		// 编码后仍然保持原始数值
+		encoded, err := decoder.Encode(storage)
+		if err != nil {
+			t.Fatalf("Failed to encode: %v", err)
+		}
+		if !strings.Contains(string(encoded), "9007199254740993") {
+			t.Errorf("Expected encoded data to keep original number, got %s", encoded)
+		}
+	})
+
+	t.Run("default float64 mode", func(t *testing.T) {
+		decoder := NewJsonDecoderWithOptions(&JsonDecoderOptions{UseJSON5: true})
+		storage, err := decoder.Decode([]byte(jsonData))
+		if err != nil {
+			t.Fatalf("Failed to decode JSON: %v", err)
+		}
+
+		var data map[string]interface{}
+		if err := storage.ConvertTo(&data); err != nil {
+			t.Fatalf("Failed to convert: %v", err)
+		}
+		if _, ok := data["id"].(float64); !ok {
+			t.Errorf("Expected float64, got %T", data["id"])
+		}
+	})
+
+	t.Run("trailing data is rejected", func(t *testing.T) {
+		decoder := NewJsonDecoderWithOptions(&JsonDecoderOptions{UseNumber: true})
+		for _, data := range []string{`{"a": 1} {"b": 2}`, `{"a": 1}}`, `{"a": 1}]`, `{"a": 1} 2`} {
+			if _, err := decoder.Decode([]byte(data)); err == nil {
+				t.Errorf("Expected error for trailing data in %s", data)
+			}
+		}
+		if _, err := decoder.Decode([]byte("{\"a\": 1}\n")); err != nil {
+			t.Errorf("Expected trailing whitespace to be accepted, got %v", err)
+		}
+	})
+
+	t.Run("durations decode the same in both modes", func(t *testing.T) {
+		type config struct {
+			Timeout  time.Duration `cfg:"timeout"`
+			Interval time.Duration `cfg:"interval"`
+		}
+		var results []config
+		for _, useNumber := range []bool{false, true} {
+			decoder := NewJsonDecoderWithOptions(&JsonDecoderOptions{UseNumber: useNumber})
+			storage, err := decoder.Decode([]byte(`{"timeout": 30, "interval": 1.5}`))
+			if err != nil {
+				t.Fatalf("Failed to decode JSON: %v", err)
+			}
+			var c config
+			if err := storage.ConvertTo(&c); err != nil {
+				t.Fatalf("Failed to convert: %v", err)
+			}
+			results = append(results, c)
+		}
+		if results[0] != results[1] {
+			t.Errorf("Expected same durations in both modes, got %+v and %+v", results[0], results[1])
+		}
+		if results[1].Timeout != 30*time.Second || results[1].Interval != 1500*time.Millisecond {
+			t.Errorf("Expected 30s and 1.5s, got %+v", results[1])
+		}
+	})
+}
@@ -61,7 +61,7 @@ func (t *TomlDecoder) Encode(s storage.Storage) ([]byte, error) {
 
 	// 尝试直接获取MapStorage的内部数据
 	if mapStorage, ok := s.(*storage.MapStorage); ok {
-		data = mapStorage.Data()
+		data = mapStorage.UnsafeData()
 	} else {
 		// 如果不是MapStorage，尝试转换为通用interface{}
 		if err := s.ConvertTo(&data); err != nil {
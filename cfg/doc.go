@@ -0,0 +1,53 @@
+package cfg
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DocFor 根据配置结构体生成 markdown 格式的配置参考文档
+// 每个叶子字段一行，包含配置路径、类型、默认值（def 标签）、约束（validate 标签）和说明（desc 标签）
+// 数组元素和 map 值的字段分别以 [N] 和 {KEY} 作为路径占位符
+func DocFor(config interface{}) string {
+	fields := extractFieldInfo(config, "", "", "", &orderCounter{})
+
+	// 按原始定义顺序排序
+	sort.Slice(fields, func(i, j int) bool {
+		return fields[i].Order < fields[j].Order
+	})
+
+	var sb strings.Builder
+	sb.WriteString("| 配置项 | 类型 | 默认值 | 约束 | 说明 |\n")
+	sb.WriteString("| --- | --- | --- | --- | --- |\n")
+	for _, field := range fields {
+		defaultValue := ""
+		if field.DefaultValue != "" {
+			defaultValue = fmt.Sprintf("`%s`", field.DefaultValue)
+		}
+
+		var constraints []string
+		if field.Required {
+			constraints = append(constraints, "必填")
+		}
+		if rules := formatValidationRules(field.Validation); rules != "" {
+			constraints = append(constraints, rules)
+		}
+
+		sb.WriteString(fmt.Sprintf("| `%s` | `%s` | %s | %s | %s |\n",
+			field.Path,
+			field.Type,
+			escapeMarkdownCell(defaultValue),
+			escapeMarkdownCell(strings.Join(constraints, "; ")),
+			escapeMarkdownCell(field.Description),
+		))
+	}
+
+	return sb.String()
+}
+
+// escapeMarkdownCell 转义表格单元格中的竖线和换行
+func escapeMarkdownCell(s string) string {
+	s = strings.ReplaceAll(s, "|", "\\|")
+	return strings.ReplaceAll(s, "\n", "<br>")
+}
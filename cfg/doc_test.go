@@ -0,0 +1,55 @@
+package cfg
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+type DocServerConfig struct {
+	Host    string        `cfg:"host" def:"localhost" validate:"required" desc:"服务器绑定地址"`
+	Port    int           `cfg:"port" def:"80" validate:"required,min=1,max=65535" desc:"服务器监听端口"`
+	Timeout time.Duration `cfg:"timeout" def:"30s" help:"请求超时时间"`
+}
+
+type DocConfig struct {
+	Name   string                     `cfg:"name" validate:"oneof=dev prod" desc:"运行环境 | 环境名"`
+	Server DocServerConfig            `cfg:"server"`
+	Tags   []string                   `cfg:"tags" desc:"标签列表"`
+	Pools  []DocServerConfig          `cfg:"pools"`
+	Extras map[string]DocServerConfig `cfg:"extras"`
+	Secret string                     `cfg:"-"`
+}
+
+func TestDocFor(t *testing.T) {
+	doc := DocFor(&DocConfig{})
+	lines := strings.Split(strings.TrimSpace(doc), "\n")
+
+	if lines[0] != "| 配置项 | 类型 | 默认值 | 约束 | 说明 |" {
+		t.Errorf("unexpected header: %s", lines[0])
+	}
+
+	expected := []string{
+		"| `name` | `string` |  | 允许值: dev, prod | 运行环境 \\| 环境名 |",
+		"| `server.host` | `string` | `localhost` | 必填 | 服务器绑定地址 |",
+		"| `server.port` | `int` | `80` | 必填; 最小值: 1; 最大值: 65535 | 服务器监听端口 |",
+		"| `server.timeout` | `time.Duration` | `30s` |  | 请求超时时间 |",
+		"| `tags` | `[]string` |  |  | 标签列表 |",
+		"| `pools[N].host` | `string` | `localhost` | 必填 | 服务器绑定地址 |",
+	}
+	for i, want := range expected {
+		if lines[i+2] != want {
+			t.Errorf("line %d:\n got: %s\nwant: %s", i+2, lines[i+2], want)
+		}
+	}
+
+	if !strings.Contains(doc, "| `extras.{KEY}.port` |") {
+		t.Errorf("expected map value fields in doc:\n%s", doc)
+	}
+	if strings.Contains(doc, "Secret") || strings.Contains(doc, "secret") {
+		t.Errorf("ignored field should not appear in doc:\n%s", doc)
+	}
+	if len(lines) != 2+3+1+1+3+3 {
+		t.Errorf("expected %d lines, got %d:\n%s", 2+3+1+1+3+3, len(lines), doc)
+	}
+}
@@ -0,0 +1,102 @@
+package cfg
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// Get 获取 key 对应的配置项并转换成 T 类型，配置项不存在或者转换失败时返回 defaultValue
+// key 的格式与 Sub 相同，如 "database.host"、"servers[0].port"
+func Get[T any](config Config, key string, defaultValue T) T {
+	value, err := lookup[T](config, key)
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+// MustGet 获取 key 对应的配置项并转换成 T 类型，配置项不存在或者转换失败时 panic，panic 信息中包含配置路径
+func MustGet[T any](config Config, key string) T {
+	value, err := lookup[T](config, key)
+	if err != nil {
+		panic(err)
+	}
+	return value
+}
+
+// GetString 获取字符串配置项，不存在或者转换失败时返回 defaultValue
+func GetString(config Config, key string, defaultValue string) string {
+	return Get(config, key, defaultValue)
+}
+
+// GetInt 获取整数配置项，不存在或者转换失败时返回 defaultValue
+func GetInt(config Config, key string, defaultValue int) int {
+	return Get(config, key, defaultValue)
+}
+
+// GetInt64 获取 int64 配置项，不存在或者转换失败时返回 defaultValue
+func GetInt64(config Config, key string, defaultValue int64) int64 {
+	return Get(config, key, defaultValue)
+}
+
+// GetFloat64 获取浮点数配置项，不存在或者转换失败时返回 defaultValue
+func GetFloat64(config Config, key string, defaultValue float64) float64 {
+	return Get(config, key, defaultValue)
+}
+
+// GetBool 获取布尔配置项，不存在或者转换失败时返回 defaultValue
+func GetBool(config Config, key string, defaultValue bool) bool {
+	return Get(config, key, defaultValue)
+}
+
+// GetDuration 获取时长配置项，支持 "5s" 这样的字符串，不存在或者转换失败时返回 defaultValue
+func GetDuration(config Config, key string, defaultValue time.Duration) time.Duration {
+	return Get(config, key, defaultValue)
+}
+
+// GetStringSlice 获取字符串数组配置项，不存在或者转换失败时返回 defaultValue
+func GetStringSlice(config Config, key string, defaultValue []string) []string {
+	return Get(config, key, defaultValue)
+}
+
+// MustGetString 获取字符串配置项，不存在或者转换失败时 panic
+func MustGetString(config Config, key string) string {
+	return MustGet[string](config, key)
+}
+
+// MustGetInt 获取整数配置项，不存在或者转换失败时 panic
+func MustGetInt(config Config, key string) int {
+	return MustGet[int](config, key)
+}
+
+// MustGetDuration 获取时长配置项，不存在或者转换失败时 panic
+func MustGetDuration(config Config, key string) time.Duration {
+	return MustGet[time.Duration](config, key)
+}
+
+// lookup 获取 key 对应的配置项并转换成 T 类型
+// 先转换成 any 判断配置项是否存在，空 map 视为不存在（FlatStorage 中不存在的前缀转换成空 map）
+func lookup[T any](config Config, key string) (T, error) {
+	var value T
+	sub := config.Sub(key)
+
+	var raw any
+	if err := sub.ConvertTo(&raw); err != nil {
+		return value, fmt.Errorf("config %q: %w", key, err)
+	}
+	if raw == nil || isEmptyMap(raw) {
+		return value, fmt.Errorf("config %q: not found", key)
+	}
+
+	if err := sub.ConvertTo(&value); err != nil {
+		return value, fmt.Errorf("config %q: cannot convert to %T: %w", key, value, err)
+	}
+	return value, nil
+}
+
+// isEmptyMap 判断值是否为空 map
+func isEmptyMap(v any) bool {
+	rv := reflect.ValueOf(v)
+	return rv.Kind() == reflect.Map && rv.Len() == 0
+}
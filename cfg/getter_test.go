@@ -0,0 +1,145 @@
+package cfg
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hatlonely/gox/cfg/decoder"
+	"github.com/hatlonely/gox/cfg/provider"
+	"github.com/hatlonely/gox/ref"
+)
+
+func newGetterTestConfig(t *testing.T, filename string, content string, decoderOptions ref.TypeOptions) *SingleConfig {
+	configFile := filepath.Join(t.TempDir(), filename)
+	if err := os.WriteFile(configFile, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	config, err := NewSingleConfigWithOptions(&SingleConfigOptions{
+		Provider: ref.TypeOptions{
+			Namespace: "github.com/hatlonely/gox/cfg/provider",
+			Type:      "FileProvider",
+			Options:   &provider.FileProviderOptions{FilePath: configFile},
+		},
+		Decoder: decoderOptions,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create config: %v", err)
+	}
+	t.Cleanup(func() { config.Close() })
+	return config
+}
+
+var jsonDecoderOptions = ref.TypeOptions{
+	Namespace: "github.com/hatlonely/gox/cfg/decoder",
+	Type:      "JsonDecoder",
+	Options:   &decoder.JsonDecoderOptions{},
+}
+
+func TestGetters(t *testing.T) {
+	config := newGetterTestConfig(t, "config.json", `{
+		"database": {"host": "db", "port": 3306, "timeout": "5s", "ratio": 0.5, "debug": true},
+		"servers": [{"name": "a"}, {"name": "b"}],
+		"tags": ["x", "y"]
+	}`, jsonDecoderOptions)
+
+	if got := GetString(config, "database.host", "localhost"); got != "db" {
+		t.Errorf("expected db, got %v", got)
+	}
+	if got := GetString(config, "database.user", "root"); got != "root" {
+		t.Errorf("expected default root, got %v", got)
+	}
+	if got := GetInt(config, "database.port", 0); got != 3306 {
+		t.Errorf("expected 3306, got %v", got)
+	}
+	if got := GetInt64(config, "database.port", 0); got != 3306 {
+		t.Errorf("expected 3306, got %v", got)
+	}
+	if got := GetFloat64(config, "database.ratio", 0); got != 0.5 {
+		t.Errorf("expected 0.5, got %v", got)
+	}
+	if got := GetBool(config, "database.debug", false); !got {
+		t.Errorf("expected true, got %v", got)
+	}
+	if got := GetDuration(config, "database.timeout", time.Second); got != 5*time.Second {
+		t.Errorf("expected 5s, got %v", got)
+	}
+	if got := GetDuration(config, "database.idle", time.Minute); got != time.Minute {
+		t.Errorf("expected default 1m, got %v", got)
+	}
+	if got := GetString(config, "servers[1].name", ""); got != "b" {
+		t.Errorf("expected b, got %v", got)
+	}
+	if got := GetStringSlice(config, "tags", nil); !reflect.DeepEqual(got, []string{"x", "y"}) {
+		t.Errorf("expected [x y], got %v", got)
+	}
+
+	// 转换失败时返回默认值
+	if got := GetInt(config, "database.host", 1); got != 1 {
+		t.Errorf("expected default 1 on conversion error, got %v", got)
+	}
+
+	// 子配置使用相对路径
+	if got := GetInt(config.Sub("database"), "port", 0); got != 3306 {
+		t.Errorf("expected 3306 from sub config, got %v", got)
+	}
+
+	// 泛型版本
+	type server struct {
+		Name string `cfg:"name"`
+	}
+	if got := Get(config, "servers[0]", server{}); got.Name != "a" {
+		t.Errorf("expected server a, got %v", got)
+	}
+}
+
+func TestMustGetters(t *testing.T) {
+	config := newGetterTestConfig(t, "config.json", `{"database": {"host": "db", "port": 3306, "timeout": "5s"}}`, jsonDecoderOptions)
+
+	if got := MustGetString(config, "database.host"); got != "db" {
+		t.Errorf("expected db, got %v", got)
+	}
+	if got := MustGetInt(config, "database.port"); got != 3306 {
+		t.Errorf("expected 3306, got %v", got)
+	}
+	if got := MustGetDuration(config, "database.timeout"); got != 5*time.Second {
+		t.Errorf("expected 5s, got %v", got)
+	}
+
+	expectPanic := func(key string, fn func()) {
+		t.Helper()
+		defer func() {
+			r := recover()
+			if r == nil {
+				t.Fatalf("expected panic for %s", key)
+			}
+			if !strings.Contains(r.(error).Error(), key) {
+				t.Errorf("expected panic message to contain %q, got %v", key, r)
+			}
+		}()
+		fn()
+	}
+	expectPanic("database.user", func() { MustGetString(config, "database.user") })
+	expectPanic("database.host", func() { MustGetInt(config, "database.host") })
+}
+
+func TestGetters_FlatStorage(t *testing.T) {
+	config := newGetterTestConfig(t, "config.env", "DATABASE_HOST=db\nDATABASE_PORT=3306\n", ref.TypeOptions{
+		Namespace: "github.com/hatlonely/gox/cfg/decoder",
+		Type:      "EnvDecoder",
+	})
+
+	if got := GetString(config, "database.host", "localhost"); got != "db" {
+		t.Errorf("expected db, got %v", got)
+	}
+	if got := GetInt(config, "database.port", 0); got != 3306 {
+		t.Errorf("expected 3306, got %v", got)
+	}
+	if got := GetString(config, "database.user", "root"); got != "root" {
+		t.Errorf("expected default root, got %v", got)
+	}
+}
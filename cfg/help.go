@@ -13,6 +13,7 @@ type FieldInfo struct {
 	Path         string   // 字段路径，如 "database.host"
 	Type         string   // 字段类型描述
 	Help         string   // 帮助信息
+	Description  string   // 字段说明，从 desc 标签获取，缺省时使用 help 标签
 	EnvName      string   // 环境变量名
 	CmdName      string   // 命令行参数名
 	DefaultValue string   // 默认值
@@ -223,10 +224,17 @@ func createFieldInfo(path string, field reflect.StructField, help, envPrefix, cm
 	required := strings.Contains(validation, "required") ||
 		strings.Contains(field.Tag.Get("binding"), "required")
 
+	// 获取字段说明
+	description := field.Tag.Get("desc")
+	if description == "" {
+		description = field.Tag.Get("help")
+	}
+
 	return FieldInfo{
 		Path:         path,
 		Type:         fieldType,
 		Help:         help,
+		Description:  description,
 		EnvName:      envName,
 		CmdName:      cmdName,
 		Required:     required,
@@ -458,6 +466,12 @@ func formatSingleRule(rule string) string {
 			return fmt.Sprintf("不等于: %s", value)
 		case "oneof":
 			return fmt.Sprintf("允许值: %s", strings.ReplaceAll(value, " ", ", "))
+		case "durmin":
+			return fmt.Sprintf("最短时长: %s", value)
+		case "durmax":
+			return fmt.Sprintf("最长时长: %s", value)
+		case "between":
+			return fmt.Sprintf("范围: [%s]", strings.ReplaceAll(value, "~", ", "))
 		case "contains":
 			return fmt.Sprintf("包含: %s", value)
 		case "containsany":
@@ -0,0 +1,245 @@
+package cfg
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hatlonely/gox/cfg/storage"
+	"github.com/hatlonely/gox/log/logger"
+)
+
+// MaskedValue 敏感配置项脱敏后的值
+const MaskedValue = storage.RedactedValue
+
+// SensitiveKeys 敏感配置项名称关键字
+// 配置项名称（忽略大小写）包含其中任意一个关键字时，其值在对外暴露时会被替换为 MaskedValue
+var SensitiveKeys = []string{
+	"password", "passwd", "secret", "token", "credential",
+	"apikey", "api_key", "accesskey", "access_key", "privatekey", "private_key",
+}
+
+// Effective 获取配置当前生效的数据，敏感配置项和 Redact 选项指定的路径已脱敏
+// 返回的是多个配置源合并、解码之后的最终结果，与 ConvertTo 看到的数据一致
+func Effective(config Config) (any, error) {
+	var data any
+	var err error
+	if r, ok := config.(redactor); ok {
+		data, err = r.redactedData()
+	} else {
+		err = config.ConvertTo(&data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert config: %w", err)
+	}
+	return maskSensitive(data), nil
+}
+
+// redactor 支持按 Redact 选项指定的路径脱敏的配置
+type redactor interface {
+	// redactedData 获取配置当前生效的数据，Redact 选项指定的路径已脱敏
+	redactedData() (any, error)
+}
+
+// redactedData 获取配置数据并脱敏 paths 指定的路径
+// paths 是相对于根配置的路径，子配置先取出根配置的完整数据脱敏，再取 prefix 对应的部分
+func redactedData(config Config, root storage.Storage, paths []string, prefix string) (any, error) {
+	var data any
+	if len(paths) == 0 {
+		err := config.ConvertTo(&data)
+		return data, err
+	}
+
+	if err := root.ConvertTo(&data); err != nil {
+		return nil, err
+	}
+	sub, _ := storage.NewMapStorage(data).Redact(paths...).Sub(prefix).(*storage.MapStorage)
+	return sub.Data(), nil
+}
+
+// redactStorage 为解码得到的存储设置脱敏路径，使其 Data 输出脱敏后的数据
+func redactStorage(s storage.Storage, paths []string) storage.Storage {
+	if len(paths) == 0 {
+		return s
+	}
+	switch v := s.(type) {
+	case *storage.MapStorage:
+		v.Redact(paths...)
+	case *storage.FlatStorage:
+		v.Redact(paths...)
+	}
+	return s
+}
+
+// maskSensitive 复制配置数据并替换敏感配置项的值，不修改原始数据
+func maskSensitive(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		masked := make(map[string]any, len(v))
+		for key, val := range v {
+			if isSensitiveKey(key) {
+				masked[key] = MaskedValue
+			} else {
+				masked[key] = maskSensitive(val)
+			}
+		}
+		return masked
+	case []any:
+		masked := make([]any, len(v))
+		for i, val := range v {
+			masked[i] = maskSensitive(val)
+		}
+		return masked
+	default:
+		return value
+	}
+}
+
+// logConfigChanges 以一条日志输出新旧配置之间的所有变更，敏感配置项和 redactPaths 指定路径的值以掩码输出
+func logConfigChanges(l logger.Logger, oldStorage, newStorage storage.Storage, redactPaths []string) {
+	if l == nil {
+		return
+	}
+
+	var oldData, newData any
+	if err := oldStorage.ConvertTo(&oldData); err != nil {
+		return
+	}
+	if err := newStorage.ConvertTo(&newData); err != nil {
+		return
+	}
+
+	changes := logger.Diff(oldData, newData)
+	if len(changes) == 0 {
+		return
+	}
+	oldRedacted := storage.NewMapStorage(oldData).Redact(redactPaths...)
+	newRedacted := storage.NewMapStorage(newData).Redact(redactPaths...)
+	for i := range changes {
+		if len(redactPaths) > 0 {
+			changes[i].Before = redactedChangeValue(oldRedacted, changes[i].Path)
+			changes[i].After = redactedChangeValue(newRedacted, changes[i].Path)
+		}
+		changes[i].Before = maskChangeValue(changes[i].Path, changes[i].Before)
+		changes[i].After = maskChangeValue(changes[i].Path, changes[i].After)
+	}
+	l.Info("config changed", "changes", changes)
+}
+
+// redactedChangeValue 从脱敏后的配置中取出变更路径对应的值
+func redactedChangeValue(data *storage.MapStorage, path string) any {
+	sub, _ := data.Sub(path).(*storage.MapStorage)
+	return sub.Data()
+}
+
+// maskChangeValue 路径中任意一段为敏感配置项时整体脱敏，否则脱敏值内部的敏感配置项
+func maskChangeValue(path string, value any) any {
+	if value == nil {
+		return nil
+	}
+	for _, segment := range strings.Split(path, ".") {
+		if idx := strings.IndexByte(segment, '['); idx >= 0 {
+			segment = segment[:idx]
+		}
+		if segment != "" && isSensitiveKey(segment) {
+			return MaskedValue
+		}
+	}
+	return maskSensitive(value)
+}
+
+// isSensitiveKey 判断配置项名称是否敏感
+// 扁平存储的配置项名称可能是 "db.password" 这样的完整路径，按路径的最后一段判断
+func isSensitiveKey(key string) bool {
+	if idx := strings.LastIndex(key, "."); idx >= 0 {
+		key = key[idx+1:]
+	}
+	key = strings.ToLower(key)
+	for _, sensitive := range SensitiveKeys {
+		if strings.Contains(key, sensitive) {
+			return true
+		}
+	}
+	return false
+}
+
+// inspection 配置调试信息，用于 HTTP 和 expvar 输出
+type inspection struct {
+	Healthy bool               `json:"healthy"`
+	Sources []sourceInspection `json:"sources"`
+	Config  any                `json:"config"`
+	Error   string             `json:"error,omitempty"`
+}
+
+type sourceInspection struct {
+	Index         int        `json:"index"`
+	Watching      bool       `json:"watching"`
+	LastLoadTime  *time.Time `json:"lastLoadTime,omitempty"`
+	LastError     string     `json:"lastError,omitempty"`
+	LastErrorTime *time.Time `json:"lastErrorTime,omitempty"`
+}
+
+// inspect 采集配置的当前数据和运行状态
+func inspect(config Config) *inspection {
+	status := config.Status()
+	result := &inspection{
+		Healthy: status.Healthy(),
+		Sources: make([]sourceInspection, 0, len(status.Sources)),
+	}
+	for _, source := range status.Sources {
+		s := sourceInspection{Index: source.Index, Watching: source.Watching}
+		if !source.LastLoadTime.IsZero() {
+			t := source.LastLoadTime
+			s.LastLoadTime = &t
+		}
+		if source.LastError != nil {
+			s.LastError = source.LastError.Error()
+		}
+		if !source.LastErrorTime.IsZero() {
+			t := source.LastErrorTime
+			s.LastErrorTime = &t
+		}
+		result.Sources = append(result.Sources, s)
+	}
+
+	data, err := Effective(config)
+	if err != nil {
+		result.Error = err.Error()
+	}
+	result.Config = data
+
+	return result
+}
+
+// NewInspectHandler 创建输出配置当前生效数据和配置源状态的 HTTP 处理器
+// 每次请求实时读取配置，配置热更新后无需重新注册；敏感配置项已脱敏。
+// 该处理器会暴露配置内容，应只挂载在内部调试端口上
+//
+// 使用示例：
+//
+//	http.Handle("/debug/config", cfg.NewInspectHandler(config))
+func NewInspectHandler(config Config) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		_ = encoder.Encode(inspect(config))
+	})
+}
+
+// PublishExpvar 将配置当前生效数据和配置源状态以 name 发布到 expvar，可通过 /debug/vars 查看
+// 与 expvar.Publish 一致，name 重复发布时会 panic
+func PublishExpvar(name string, config Config) {
+	expvar.Publish(name, expvar.Func(func() any {
+		return inspect(config)
+	}))
+}
@@ -0,0 +1,300 @@
+package cfg
+
+import (
+	"encoding/json"
+	"expvar"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hatlonely/gox/cfg/decoder"
+	"github.com/hatlonely/gox/cfg/provider"
+	"github.com/hatlonely/gox/ref"
+)
+
+func newInspectTestConfig(t *testing.T, redact ...string) *SingleConfig {
+	configFile := filepath.Join(t.TempDir(), "config.json")
+	content := `{
+		"server": {"port": 8080},
+		"database": {"host": "localhost", "password": "p@ss", "replicas": [{"host": "r1", "accessKey": "ak"}]},
+		"apiToken": "t0ken"
+	}`
+	if err := os.WriteFile(configFile, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	config, err := NewSingleConfigWithOptions(&SingleConfigOptions{
+		Provider: ref.TypeOptions{
+			Namespace: "github.com/hatlonely/gox/cfg/provider",
+			Type:      "FileProvider",
+			Options:   &provider.FileProviderOptions{FilePath: configFile},
+		},
+		Decoder: ref.TypeOptions{
+			Namespace: "github.com/hatlonely/gox/cfg/decoder",
+			Type:      "JsonDecoder",
+			Options:   &decoder.JsonDecoderOptions{},
+		},
+		Redact: redact,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create config: %v", err)
+	}
+	t.Cleanup(func() { config.Close() })
+	return config
+}
+
+func TestEffective(t *testing.T) {
+	config := newInspectTestConfig(t)
+
+	data, err := Effective(config)
+	if err != nil {
+		t.Fatalf("Effective failed: %v", err)
+	}
+	root := data.(map[string]any)
+	database := root["database"].(map[string]any)
+	if database["host"] != "localhost" {
+		t.Errorf("expected host to be kept, got %v", database["host"])
+	}
+	if database["password"] != MaskedValue {
+		t.Errorf("expected password to be masked, got %v", database["password"])
+	}
+	if root["apiToken"] != MaskedValue {
+		t.Errorf("expected apiToken to be masked, got %v", root["apiToken"])
+	}
+	replica := database["replicas"].([]any)[0].(map[string]any)
+	if replica["accessKey"] != MaskedValue || replica["host"] != "r1" {
+		t.Errorf("expected nested list item to be masked, got %v", replica)
+	}
+
+	// 脱敏不影响原始配置
+	var password string
+	if err := config.Sub("database.password").ConvertTo(&password); err != nil || password != "p@ss" {
+		t.Errorf("expected original password to be kept, got %q, err: %v", password, err)
+	}
+
+	// 子配置只暴露对应部分
+	data, err = Effective(config.Sub("database"))
+	if err != nil {
+		t.Fatalf("Effective failed: %v", err)
+	}
+	if data.(map[string]any)["password"] != MaskedValue {
+		t.Errorf("expected sub config password to be masked, got %v", data)
+	}
+}
+
+func TestIsSensitiveKey(t *testing.T) {
+	tests := map[string]bool{
+		"password":        true,
+		"DB_PASSWORD":     true,
+		"clientSecret":    true,
+		"db.password":     true,
+		"password.length": false,
+		"host":            false,
+		"keepAlive":       false,
+	}
+	for key, expected := range tests {
+		if got := isSensitiveKey(key); got != expected {
+			t.Errorf("isSensitiveKey(%q) = %v, expected %v", key, got, expected)
+		}
+	}
+}
+
+func TestNewInspectHandler(t *testing.T) {
+	config := newInspectTestConfig(t)
+	handler := NewInspectHandler(config)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/config", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json; charset=utf-8" {
+		t.Errorf("unexpected content type %q", ct)
+	}
+
+	var body struct {
+		Healthy bool `json:"healthy"`
+		Sources []struct {
+			Index        int     `json:"index"`
+			LastLoadTime *string `json:"lastLoadTime"`
+		} `json:"sources"`
+		Config map[string]any `json:"config"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if !body.Healthy || len(body.Sources) != 1 || body.Sources[0].LastLoadTime == nil {
+		t.Errorf("unexpected status in response: %s", rec.Body.String())
+	}
+	if body.Config["database"].(map[string]any)["password"] != MaskedValue {
+		t.Errorf("expected password to be masked in response: %s", rec.Body.String())
+	}
+
+	// 热更新后实时生效
+	if err := config.handleProviderChange([]byte(`{"server": {"port": 9090}}`)); err != nil {
+		t.Fatalf("handleProviderChange failed: %v", err)
+	}
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/config", nil))
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if body.Config["server"].(map[string]any)["port"] != float64(9090) {
+		t.Errorf("expected reloaded config in response: %s", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/debug/config", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected status 405, got %d", rec.Code)
+	}
+}
+
+func TestPublishExpvar(t *testing.T) {
+	config := newInspectTestConfig(t)
+	PublishExpvar("cfg_inspect_test", config)
+
+	v := expvar.Get("cfg_inspect_test")
+	if v == nil {
+		t.Fatal("expected expvar to be published")
+	}
+
+	var body struct {
+		Config map[string]any `json:"config"`
+	}
+	if err := json.Unmarshal([]byte(v.String()), &body); err != nil {
+		t.Fatalf("failed to decode expvar: %v", err)
+	}
+	if body.Config["apiToken"] != MaskedValue {
+		t.Errorf("expected apiToken to be masked in expvar: %s", v.String())
+	}
+}
+
+func TestLogConfigChanges(t *testing.T) {
+	config := newInspectTestConfig(t)
+	mockWriter := &MockWriter{}
+	config.SetLogger(&mockLogger{writer: mockWriter})
+
+	err := config.handleProviderChange([]byte(`{
+		"server": {"port": 9090},
+		"database": {"host": "localhost", "password": "n3w", "replicas": [{"host": "r1", "accessKey": "ak2"}]},
+		"apiToken": "t0ken",
+		"cache": {"ttl": 60, "secret": "s3cret"}
+	}`))
+	if err != nil {
+		t.Fatalf("handleProviderChange() error = %v", err)
+	}
+
+	var changeLog string
+	for _, log := range mockWriter.logs {
+		if strings.Contains(log, "config changed") {
+			changeLog = log
+		}
+	}
+	if changeLog == "" {
+		t.Fatalf("expected config changed log, got %v", mockWriter.logs)
+	}
+	for _, want := range []string{"server.port", "database.password", "database.replicas[0].accessKey", "cache", MaskedValue} {
+		if !strings.Contains(changeLog, want) {
+			t.Errorf("log = %s, want contains %s", changeLog, want)
+		}
+	}
+	for _, secret := range []string{"p@ss", "n3w", "ak2", "s3cret"} {
+		if strings.Contains(changeLog, secret) {
+			t.Errorf("log = %s, should not contain %s", changeLog, secret)
+		}
+	}
+
+	// 没有变更时不输出
+	mockWriter.logs = nil
+	config.handleProviderChange([]byte(`{
+		"server": {"port": 9090},
+		"database": {"host": "localhost", "password": "n3w", "replicas": [{"host": "r1", "accessKey": "ak2"}]},
+		"apiToken": "t0ken",
+		"cache": {"ttl": 60, "secret": "s3cret"}
+	}`))
+	for _, log := range mockWriter.logs {
+		if strings.Contains(log, "config changed") {
+			t.Errorf("unexpected change log: %s", log)
+		}
+	}
+}
+
+func TestEffectiveRedact(t *testing.T) {
+	config := newInspectTestConfig(t, "server.port", "database.replicas[*].host")
+
+	data, err := Effective(config)
+	if err != nil {
+		t.Fatalf("Effective failed: %v", err)
+	}
+	root := data.(map[string]any)
+	if port := root["server"].(map[string]any)["port"]; port != MaskedValue {
+		t.Errorf("expected server.port to be redacted, got %v", port)
+	}
+	database := root["database"].(map[string]any)
+	if database["host"] != "localhost" {
+		t.Errorf("expected database.host to be kept, got %v", database["host"])
+	}
+	if host := database["replicas"].([]any)[0].(map[string]any)["host"]; host != MaskedValue {
+		t.Errorf("expected replica host to be redacted, got %v", host)
+	}
+
+	// 子配置使用相对于根配置的路径
+	data, err = Effective(config.Sub("server"))
+	if err != nil {
+		t.Fatalf("Effective failed: %v", err)
+	}
+	if port := data.(map[string]any)["port"]; port != MaskedValue {
+		t.Errorf("expected sub config port to be redacted, got %v", port)
+	}
+	data, err = Effective(config.Sub("server.port"))
+	if err != nil {
+		t.Fatalf("Effective failed: %v", err)
+	}
+	if data != MaskedValue {
+		t.Errorf("expected redacted sub config, got %v", data)
+	}
+
+	// ConvertTo 不受影响
+	var server struct {
+		Port int `cfg:"port"`
+	}
+	if err := config.Sub("server").ConvertTo(&server); err != nil || server.Port != 8080 {
+		t.Errorf("ConvertTo() = %v, %v, want 8080", server.Port, err)
+	}
+}
+
+func TestLogConfigChangesRedact(t *testing.T) {
+	config := newInspectTestConfig(t, "server", "database.replicas[*].host")
+	mockWriter := &MockWriter{}
+	config.SetLogger(&mockLogger{writer: mockWriter})
+
+	err := config.handleProviderChange([]byte(`{
+		"server": {"port": 9191},
+		"database": {"host": "db2", "password": "p@ss", "replicas": [{"host": "r2", "accessKey": "ak"}, {"host": "r3"}]},
+		"apiToken": "t0ken"
+	}`))
+	if err != nil {
+		t.Fatalf("handleProviderChange() error = %v", err)
+	}
+
+	var changeLog string
+	for _, log := range mockWriter.logs {
+		if strings.Contains(log, "config changed") {
+			changeLog = log
+		}
+	}
+	for _, want := range []string{"server.port", "database.host", "db2", "database.replicas[0].host", "database.replicas[1]"} {
+		if !strings.Contains(changeLog, want) {
+			t.Errorf("log = %s, want contains %s", changeLog, want)
+		}
+	}
+	for _, secret := range []string{"9191", "r2", "r3"} {
+		if strings.Contains(changeLog, secret) {
+			t.Errorf("log = %s, should not contain %s", changeLog, secret)
+		}
+	}
+}
@@ -19,12 +19,16 @@ type ConfigSource struct {
 	provider provider.Provider // 配置数据提供者
 	decoder  decoder.Decoder   // 配置数据解码器
 	storage  storage.Storage   // 当前配置源的数据
+	profile  string            // 生效的环境，重新加载时同样合并
+	info     SnapshotSource    // 配置源描述，用于生成快照
 }
 
 // ConfigSourceOptions 配置源选项，用于创建配置源
 type ConfigSourceOptions struct {
 	Provider ref.TypeOptions `cfg:"provider"`
 	Decoder  ref.TypeOptions `cfg:"decoder"`
+	// Profile 生效的环境，用法与 SingleConfigOptions.Profile 相同，只作用于当前配置源
+	Profile string `cfg:"profile"`
 }
 
 // MultiConfigOptions 多配置管理器初始化选项
@@ -43,6 +47,13 @@ type MultiConfigOptions struct {
 	// 可选的处理器执行配置，控制 OnChange/OnKeyChange 回调的执行行为
 	// 包括超时时长、异步/同步执行、错误处理策略等
 	HandlerExecution *HandlerExecutionOptions `cfg:"handlerExecution"`
+
+	// 可选的脱敏路径，作用于合并之后的配置，用法与 SingleConfigOptions.Redact 相同
+	Redact []string `cfg:"redact"`
+
+	// 可选的合并策略，设置之后配置源（以及各配置源的 profiles）按数据合并：数组可以追加或者按下标合并，
+	// 键名以 "!" 结尾时整体覆盖低优先级的值，见 storage.MergeOptions 和 storage.NewMultiStorageWithMergeOptions
+	Merge *storage.MergeOptions `cfg:"merge"`
 }
 
 // MultiConfig 多配置管理器
@@ -57,9 +68,20 @@ type MultiConfig struct {
 	// 通用配置
 	logger           logger.Logger
 	handlerExecution *HandlerExecutionOptions
+	redact           []string
+	merge            *storage.MergeOptions
 
 	// 变更监听相关
 	onKeyChangeHandlers map[string][]func(storage.Storage) error
+	// 批量变更处理器（只有根配置使用）
+	batchHandlers []batchChangeHandler
+	// 合并短时间内的多次变更（只有根配置使用）
+	debouncer *changeDebouncer
+	// 配置变更的校验（只有根配置使用）
+	validators []configValidator
+
+	// 配置源加载状态
+	status *statusTracker
 
 	// 子配置支持
 	parent *MultiConfig
@@ -80,6 +102,9 @@ func NewMultiConfigWithOptions(options *MultiConfigOptions) (*MultiConfig, error
 	if len(options.Sources) == 0 {
 		return nil, fmt.Errorf("at least one configuration source is required")
 	}
+	if err := validateMergeOptions(options.Merge); err != nil {
+		return nil, err
+	}
 
 	// 创建配置源
 	sources := make([]ConfigSource, len(options.Sources))
@@ -109,20 +134,25 @@ func NewMultiConfigWithOptions(options *MultiConfigOptions) (*MultiConfig, error
 		if err != nil {
 			return nil, fmt.Errorf("failed to decode data from source %d: %w", i, err)
 		}
+		if stor, err = applyProfile(stor, sourceOptions.Profile, options.Merge); err != nil {
+			return nil, fmt.Errorf("failed to apply profile to source %d: %w", i, err)
+		}
 
 		// 用 ValidateStorage 包装 storage 以提供自动校验功能
-		stor = storage.NewValidateStorage(stor)
+		stor = storage.NewValidateStorage(redactStorage(stor, options.Redact))
 
 		sources[i] = ConfigSource{
 			provider: prov,
 			decoder:  dec,
 			storage:  stor,
+			profile:  sourceOptions.Profile,
+			info:     describeSource(i, sourceOptions.Provider, sourceOptions.Decoder),
 		}
 		storages[i] = stor
 	}
 
 	// 创建 MultiStorage
-	multiStorage := storage.NewMultiStorage(storages)
+	multiStorage := newMultiStorage(storages, options.Merge)
 
 	// 创建 Logger (当 options.Logger 为 nil 时，log.NewLoggerWithOptions 自动返回默认 Logger)
 	logInstance, err := log.NewLoggerWithOptions(options.Logger)
@@ -153,8 +183,15 @@ func NewMultiConfigWithOptions(options *MultiConfigOptions) (*MultiConfig, error
 		multiStorage:        multiStorage,
 		logger:              logInstance,
 		handlerExecution:    handlerExecution,
+		redact:              options.Redact,
+		merge:               options.Merge,
 		onKeyChangeHandlers: make(map[string][]func(storage.Storage) error),
+		status:              newStatusTracker(len(sources)),
 	}
+	for i := range sources {
+		cfg.status.recordSuccess(i)
+	}
+	cfg.debouncer = newChangeDebouncer(handlerExecution.Debounce, cfg.notifyChange)
 
 	// 设置每个 Provider 的变更监听
 	for i, source := range cfg.sources {
@@ -162,11 +199,24 @@ func NewMultiConfigWithOptions(options *MultiConfigOptions) (*MultiConfig, error
 		source.provider.OnChange(func(newData []byte) error {
 			return cfg.handleSourceChange(sourceIndex, newData)
 		})
+		if reporter, ok := source.provider.(provider.ErrorReporter); ok {
+			reporter.OnError(func(err error) {
+				cfg.status.recordPollResult(sourceIndex, err)
+			})
+		}
 	}
 
 	return cfg, nil
 }
 
+// newMultiStorage 按合并选项创建多配置存储，未设置合并选项时依次覆盖各配置源
+func newMultiStorage(storages []storage.Storage, merge *storage.MergeOptions) storage.MultiStorage {
+	if merge == nil {
+		return storage.NewMultiStorage(storages)
+	}
+	return storage.NewMultiStorageWithMergeOptions(storages, merge)
+}
+
 // handleSourceChange 处理某个配置源的数据变更
 func (c *MultiConfig) handleSourceChange(sourceIndex int, newData []byte) error {
 	if sourceIndex < 0 || sourceIndex >= len(c.sources) {
@@ -181,16 +231,33 @@ func (c *MultiConfig) handleSourceChange(sourceIndex int, newData []byte) error
 	for i, s := range c.sources {
 		oldStorages[i] = s.storage
 	}
-	oldMergedStorage := storage.NewMultiStorage(oldStorages)
+	oldMergedStorage := newMultiStorage(oldStorages, c.merge)
 
 	// 重新解码数据
 	newStorage, err := source.decoder.Decode(newData)
 	if err != nil {
+		c.status.recordError(sourceIndex, err)
 		return fmt.Errorf("failed to decode new data from source %d: %w", sourceIndex, err)
 	}
+	if newStorage, err = applyProfile(newStorage, source.profile, c.merge); err != nil {
+		c.status.recordError(sourceIndex, err)
+		return fmt.Errorf("failed to apply profile to source %d: %w", sourceIndex, err)
+	}
 
 	// 用 ValidateStorage 包装新的 storage 以提供自动校验功能
-	newStorage = storage.NewValidateStorage(newStorage)
+	newStorage = storage.NewValidateStorage(redactStorage(newStorage, c.redact))
+
+	// 用合并后的新配置校验，未通过时拒绝整个变更，所有配置源保持不变
+	if len(c.validators) > 0 {
+		candidates := make([]storage.Storage, len(oldStorages))
+		copy(candidates, oldStorages)
+		candidates[sourceIndex] = newStorage
+		if verr := validateChange(c.validators, sourceIndex, newMultiStorage(candidates, c.merge)); verr != nil {
+			rejectChange(c.logger, c.status, verr)
+			return verr
+		}
+	}
+	c.status.recordSuccess(sourceIndex)
 
 	// 更新存储
 	source.storage = newStorage
@@ -198,22 +265,33 @@ func (c *MultiConfig) handleSourceChange(sourceIndex int, newData []byte) error
 
 	if changed {
 		// 新的合并存储就是当前的 multiStorage
-		newMergedStorage := c.multiStorage
+		logConfigChanges(c.logger, oldMergedStorage, c.multiStorage, c.redact)
 
-		// 检查并触发变更监听器（统一处理根配置和特定key）
-		for key, handlers := range c.onKeyChangeHandlers {
-			// 统一使用 isKeyChanged 检查，空字符串key会让Storage.Sub("")返回自己
-			if c.isKeyChanged(oldMergedStorage, newMergedStorage, key) {
-				// 统一使用 Sub 方法获取目标存储，Sub("")会返回自身
-				targetStorage := newMergedStorage.Sub(key)
+		// 触发变更监听器，设置了防抖时合并窗口内的多次变更
+		c.debouncer.changed(oldMergedStorage, c.multiStorage)
+	}
 
-				// 执行 handlers
-				c.executeHandlers(key, handlers, targetStorage)
-			}
+	return nil
+}
+
+// notifyChange 对比 oldMergedStorage 和 newMergedStorage，触发变更监听器
+func (c *MultiConfig) notifyChange(oldMergedStorage, newMergedStorage storage.Storage) {
+	// 检查并触发变更监听器（统一处理根配置和特定key）
+	for key, handlers := range c.onKeyChangeHandlers {
+		// 统一使用 isKeyChanged 检查，空字符串key会让Storage.Sub("")返回自己
+		if c.isKeyChanged(oldMergedStorage, newMergedStorage, key) {
+			// 统一使用 Sub 方法获取目标存储，Sub("")会返回自身
+			targetStorage := newMergedStorage.Sub(key)
+
+			// 执行 handlers
+			c.executeHandlers(key, handlers, targetStorage)
 		}
 	}
 
-	return nil
+	// 批量变更监听器收到相对于自身的变更路径
+	for key, handlers := range batchHandlerFuncs(c.batchHandlers, oldMergedStorage, newMergedStorage) {
+		c.executeHandlers(key, handlers, newMergedStorage.Sub(key))
+	}
 }
 
 // isKeyChanged 检查指定 key 的数据是否发生变更
@@ -337,6 +415,12 @@ func (c *MultiConfig) ConvertTo(object any) error {
 	return subStorage.ConvertTo(object)
 }
 
+// redactedData 获取配置当前生效的数据，Redact 选项指定的路径已脱敏
+func (c *MultiConfig) redactedData() (any, error) {
+	root := c.getRoot()
+	return redactedData(c, root.multiStorage, root.redact, c.prefix)
+}
+
 // SetLogger 设置日志记录器（只有根配置才能设置）
 func (c *MultiConfig) SetLogger(logger logger.Logger) {
 	root := c.getRoot()
@@ -367,6 +451,18 @@ func (c *MultiConfig) OnKeyChange(key string, fn func(storage.Storage) error) {
 	root.onKeyChangeHandlers[key] = append(root.onKeyChangeHandlers[key], fn)
 }
 
+// OnBatchChange 监听配置变更，回调收到合并之后的配置以及发生变更的路径，所有回调都注册到根配置上
+func (c *MultiConfig) OnBatchChange(fn func(ChangeSet) error) {
+	root := c.getRoot()
+	root.batchHandlers = append(root.batchHandlers, batchChangeHandler{key: c.prefix, fn: fn})
+}
+
+// OnValidate 注册配置变更的校验，所有校验都注册到根配置上
+func (c *MultiConfig) OnValidate(key string, fn func(storage.Storage) error) {
+	root := c.getRoot()
+	root.validators = append(root.validators, configValidator{key: joinKey(c.prefix, key), fn: fn})
+}
+
 // Watch 启动配置变更监听
 func (c *MultiConfig) Watch() error {
 	root := c.getRoot()
@@ -374,14 +470,18 @@ func (c *MultiConfig) Watch() error {
 	// 启动所有 Provider 的监听
 	for i, source := range root.sources {
 		if err := source.provider.Watch(); err != nil {
+			root.status.recordError(i, err)
 			return fmt.Errorf("failed to start watching source %d: %w", i, err)
 		}
+		root.status.setWatching(i, true)
 
 		// 主动检查一次配置变更，防止在初始化和 Watch 之间丢失变更
 		newData, loadErr := source.provider.Load()
 		if loadErr == nil {
 			// 触发变更检查和处理
 			root.handleSourceChange(i, newData)
+		} else {
+			root.status.recordError(i, loadErr)
 		}
 		// 即使 Load 失败也不影响 Watch 的成功
 	}
@@ -389,6 +489,21 @@ func (c *MultiConfig) Watch() error {
 	return nil
 }
 
+// Status 获取所有配置源的运行状态
+func (c *MultiConfig) Status() Status {
+	return c.getRoot().status.snapshot()
+}
+
+// Snapshot 获取配置快照，每个配置项的来源为提供该值的优先级最高的配置源
+func (c *MultiConfig) Snapshot() (*Snapshot, error) {
+	root := c.getRoot()
+	sources := make([]snapshotSource, 0, len(root.sources))
+	for _, source := range root.sources {
+		sources = append(sources, snapshotSource{info: source.info, storage: source.storage})
+	}
+	return newSnapshot(c, sources, root.redact, c.prefix)
+}
+
 // getRoot 获取根配置对象
 func (c *MultiConfig) getRoot() *MultiConfig {
 	root := c
@@ -410,6 +525,7 @@ func (c *MultiConfig) Close() error {
 	}
 
 	root.closed = true
+	root.debouncer.stop()
 
 	// 关闭所有 Provider
 	var lastErr error
@@ -420,6 +536,7 @@ func (c *MultiConfig) Close() error {
 			}
 			lastErr = err // 记录最后一个错误
 		}
+		root.status.setWatching(i, false)
 	}
 
 	root.closeResult = lastErr
@@ -3,6 +3,7 @@ package cfg
 import (
 	"encoding/json"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -613,6 +614,61 @@ func TestMultiConfig_ValidateStorageIntegration(t *testing.T) {
 	})
 }
 
+func TestMultiConfig_Merge(t *testing.T) {
+	dir := t.TempDir()
+	source := func(name, content string) *ConfigSourceOptions {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+		return &ConfigSourceOptions{
+			Provider: ref.TypeOptions{
+				Namespace: "github.com/hatlonely/gox/cfg/provider",
+				Type:      "FileProvider",
+				Options:   &provider.FileProviderOptions{FilePath: path},
+			},
+			Decoder: jsonDecoderOptions,
+		}
+	}
+
+	t.Run("按合并选项合并配置源", func(t *testing.T) {
+		config, err := NewMultiConfigWithOptions(&MultiConfigOptions{
+			Sources: []*ConfigSourceOptions{
+				source("base.json", `{"plugins": ["auth"], "servers": ["web1", "web2"], "database": {"host": "localhost", "port": 3306}}`),
+				source("override.json", `{"plugins": ["metrics"], "servers!": ["prod1"], "database": {"host": "db.prod"}}`),
+			},
+			Merge: &storage.MergeOptions{Slice: storage.SliceMergeAppend},
+		})
+		require.NoError(t, err)
+		defer config.Close()
+
+		var result struct {
+			Plugins  []string `cfg:"plugins"`
+			Servers  []string `cfg:"servers"`
+			Database struct {
+				Host string `cfg:"host"`
+				Port int    `cfg:"port"`
+			} `cfg:"database"`
+		}
+		require.NoError(t, config.ConvertTo(&result))
+		assert.Equal(t, []string{"auth", "metrics"}, result.Plugins)
+		assert.Equal(t, []string{"prod1"}, result.Servers)
+		assert.Equal(t, "db.prod", result.Database.Host)
+		assert.Equal(t, 3306, result.Database.Port)
+
+		var plugins []string
+		require.NoError(t, config.Sub("plugins").ConvertTo(&plugins))
+		assert.Equal(t, []string{"auth", "metrics"}, plugins)
+	})
+
+	t.Run("无效的合并策略应该失败", func(t *testing.T) {
+		_, err := NewMultiConfigWithOptions(&MultiConfigOptions{
+			Sources: []*ConfigSourceOptions{source("invalid.json", `{}`)},
+			Merge:   &storage.MergeOptions{Slice: "prepend"},
+		})
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid merge options")
+	})
+}
+
 func TestMain(m *testing.M) {
 	// 运行测试
 	code := m.Run()
@@ -7,6 +7,8 @@ import (
 
 	"github.com/hatlonely/gox/cfg/decoder"
 	"github.com/hatlonely/gox/cfg/provider"
+	"github.com/hatlonely/gox/cfg/storage"
+	"github.com/hatlonely/gox/cfg/validator"
 	"github.com/hatlonely/gox/ref"
 )
 
@@ -50,6 +52,34 @@ func NewConfig(filename string) (Config, error) {
 //	cfg, err := NewConfigWithPrefix("config.yaml", "APP_", "app-")
 //	// 只处理 APP_* 环境变量和 --app-* 命令行参数
 func NewConfigWithPrefix(filename, envPrefix, cmdPrefix string) (Config, error) {
+	return newPresetConfig(filename, envPrefix, cmdPrefix, "")
+}
+
+// NewConfigWithProfile 与 NewConfig 相同，同时将配置文件 profiles 段中指定环境的配置合并到顶层配置上
+//
+// 配置优先级（从低到高）：文件顶层配置 < profiles.default < profiles.<profile> < 环境变量 < 命令行
+//
+// profile 为空时只合并 profiles.default，可以用逗号指定多个环境，如 "prod,canary"，详见 storage.ApplyProfile
+//
+// 使用示例：
+//
+//	// config.yaml:
+//	//   database:
+//	//     host: localhost
+//	//   profiles:
+//	//     prod:
+//	//       database:
+//	//         host: db.prod.internal
+//	cfg, err := NewConfigWithProfile("config.yaml", os.Getenv("APP_PROFILE"))
+func NewConfigWithProfile(filename, profile string) (Config, error) {
+	if profile == "" {
+		profile = storage.DefaultProfile
+	}
+	return newPresetConfig(filename, "", "", profile)
+}
+
+// newPresetConfig 创建文件、环境变量、命令行三个配置源的配置，profile 只作用于文件配置源
+func newPresetConfig(filename, envPrefix, cmdPrefix, profile string) (Config, error) {
 	if filename == "" {
 		return nil, fmt.Errorf("filename cannot be empty")
 	}
@@ -62,6 +92,7 @@ func NewConfigWithPrefix(filename, envPrefix, cmdPrefix string) (Config, error)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create file source options: %w", err)
 	}
+	fileSourceOptions.Profile = profile
 	sources = append(sources, fileSourceOptions)
 
 	// 2. 环境变量配置源（优先级中等）
@@ -162,3 +193,26 @@ func createCmdSourceOptions(prefix string) *ConfigSourceOptions {
 		},
 	}
 }
+
+// validateMergeOptions 校验合并策略，通过 Go 代码构造的选项不经过 ValidateStorage
+func validateMergeOptions(merge *storage.MergeOptions) error {
+	if merge == nil {
+		return nil
+	}
+	if err := validator.ValidateStruct(merge); err != nil {
+		return fmt.Errorf("invalid merge options: %w", err)
+	}
+	return nil
+}
+
+// applyProfile 按 profile 和合并选项合并配置文件中的 profiles 段，profile 为空时原样返回
+func applyProfile(stor storage.Storage, profile string, merge *storage.MergeOptions) (storage.Storage, error) {
+	if profile == "" {
+		return stor, nil
+	}
+	applied, err := storage.ApplyProfileWithMergeOptions(stor, profile, merge)
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply profile %q: %w", profile, err)
+	}
+	return applied, nil
+}
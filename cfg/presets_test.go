@@ -4,6 +4,9 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+
+	"github.com/hatlonely/gox/cfg/provider"
+	"github.com/hatlonely/gox/ref"
 )
 
 func TestNewConfig(t *testing.T) {
@@ -282,3 +285,129 @@ func TestNewConfigUnsupportedFormat(t *testing.T) {
 		cfg.Close()
 	}
 }
+
+func TestNewConfigWithProfile(t *testing.T) {
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "config.yaml")
+	configContent := `
+database:
+  host: localhost
+  port: 3306
+debug: true
+profiles:
+  default:
+    database:
+      user: app
+  prod:
+    database:
+      host: db.prod.internal
+    debug: false
+`
+	if err := os.WriteFile(configFile, []byte(configContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// 环境变量的优先级高于 profile
+	os.Setenv("DATABASE_PORT", "3307")
+	defer os.Unsetenv("DATABASE_PORT")
+	originalArgs := os.Args
+	os.Args = []string{"program"}
+	defer func() {
+		os.Args = originalArgs
+	}()
+
+	type Config struct {
+		Database struct {
+			Host string `cfg:"host"`
+			Port int    `cfg:"port"`
+			User string `cfg:"user"`
+		} `cfg:"database"`
+		Debug    bool           `cfg:"debug"`
+		Profiles map[string]any `cfg:"profiles"`
+	}
+
+	cfg, err := NewConfigWithProfile(configFile, "prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cfg.Close()
+
+	var config Config
+	if err := cfg.ConvertTo(&config); err != nil {
+		t.Fatal(err)
+	}
+	if config.Database.Host != "db.prod.internal" || config.Database.Port != 3307 || config.Database.User != "app" || config.Debug {
+		t.Errorf("unexpected config: %+v", config)
+	}
+	if len(config.Profiles) != 0 {
+		t.Errorf("expected profiles to be removed, got %v", config.Profiles)
+	}
+
+	// profile 为空时只合并 default
+	defaultCfg, err := NewConfigWithProfile(configFile, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer defaultCfg.Close()
+	config = Config{}
+	if err := defaultCfg.ConvertTo(&config); err != nil {
+		t.Fatal(err)
+	}
+	if config.Database.Host != "localhost" || config.Database.User != "app" || !config.Debug {
+		t.Errorf("unexpected default config: %+v", config)
+	}
+
+	if _, err := NewConfigWithProfile(configFile, "staging"); err == nil {
+		t.Error("expected error for unknown profile")
+	}
+}
+
+func TestSingleConfig_ProfileReload(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(configFile, []byte(`{"host": "localhost", "profiles": {"prod": {"host": "db1"}}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config, err := NewSingleConfigWithOptions(&SingleConfigOptions{
+		Provider: ref.TypeOptions{
+			Namespace: "github.com/hatlonely/gox/cfg/provider",
+			Type:      "FileProvider",
+			Options:   &provider.FileProviderOptions{FilePath: configFile},
+		},
+		Decoder: jsonDecoderOptions,
+		Profile: "prod",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer config.Close()
+
+	host := func() string {
+		var host string
+		if err := config.Sub("host").ConvertTo(&host); err != nil {
+			t.Fatal(err)
+		}
+		return host
+	}
+	if got := host(); got != "db1" {
+		t.Errorf("host = %q, want db1", got)
+	}
+
+	// 重新加载时同样合并 profile
+	if err := config.handleProviderChange([]byte(`{"host": "localhost", "profiles": {"prod": {"host": "db2"}}}`)); err != nil {
+		t.Fatal(err)
+	}
+	if got := host(); got != "db2" {
+		t.Errorf("host = %q, want db2", got)
+	}
+
+	// 新配置中缺少 profile 时拒绝变更
+	if err := config.handleProviderChange([]byte(`{"host": "localhost"}`)); err == nil {
+		t.Error("expected error when profile is missing")
+	}
+	if got := host(); got != "db2" {
+		t.Errorf("host = %q, want db2", got)
+	}
+	if config.Status().Sources[0].LastError == nil {
+		t.Error("expected the error to be recorded in status")
+	}
+}
@@ -32,7 +32,9 @@ type GormProvider struct {
 	tableName   string
 	mu          sync.RWMutex
 	onChange    []func(data []byte) error
+	onError     []func(err error)
 	lastVersion int64
+	pollFailed  bool
 
 	// 变更监听
 	stopChan     chan struct{}
@@ -134,10 +136,10 @@ func (p *GormProvider) autoMigrate() error {
 	return nil
 }
 
-// Load 读取配置数据
+// Load 读取配置数据，同时记录当前版本号，需要写锁
 func (p *GormProvider) Load() ([]byte, error) {
-	p.mu.RLock()
-	defer p.mu.RUnlock()
+	p.mu.Lock()
+	defer p.mu.Unlock()
 
 	var config ConfigData
 	result := p.db.Table(p.tableName).Where("id = ?", p.configID).First(&config)
@@ -198,6 +200,14 @@ func (p *GormProvider) OnChange(fn func(data []byte) error) {
 	p.onChange = append(p.onChange, fn)
 }
 
+// OnError 注册轮询错误回调函数
+func (p *GormProvider) OnError(fn func(err error)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.onError = append(p.onError, fn)
+}
+
 // Watch 启动配置变更监听
 func (p *GormProvider) Watch() error {
 	p.once.Do(func() {
@@ -243,8 +253,11 @@ func (p *GormProvider) checkForChanges() {
 	result := p.db.Table(p.tableName).Where("id = ?", p.configID).First(&config)
 
 	if result.Error != nil {
-		return // 忽略错误，继续轮询
+		// 上报错误，继续轮询
+		p.reportPollResult(errors.Wrap(result.Error, "failed to poll config"))
+		return
 	}
+	p.reportPollResult(nil)
 
 	if config.Version > lastVersion {
 		data := []byte(config.Content)
@@ -264,6 +277,25 @@ func (p *GormProvider) checkForChanges() {
 	}
 }
 
+// reportPollResult 上报轮询结果，失败时每次都上报，成功时只在从失败中恢复时上报一次
+func (p *GormProvider) reportPollResult(err error) {
+	p.mu.Lock()
+	recovered := err == nil && p.pollFailed
+	p.pollFailed = err != nil
+	handlers := make([]func(err error), len(p.onError))
+	copy(handlers, p.onError)
+	p.mu.Unlock()
+
+	if err == nil && !recovered {
+		return
+	}
+	for _, handler := range handlers {
+		if handler != nil {
+			handler(err)
+		}
+	}
+}
+
 // Close 关闭提供者，释放资源
 func (p *GormProvider) Close() error {
 	close(p.stopChan)
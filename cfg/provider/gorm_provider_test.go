@@ -452,3 +452,64 @@ func TestGormProvider_WatchMultipleTimes(t *testing.T) {
 		t.Error("Callback should be triggered after multiple Watch() calls")
 	}
 }
+
+func TestGormProvider_OnError(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbFile := filepath.Join(tmpDir, "test.db")
+
+	provider, err := NewGormProviderWithOptions(&GormProviderOptions{
+		ConfigID:     "test_config",
+		Driver:       "sqlite",
+		DSN:          dbFile,
+		PollInterval: 50 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create GormProvider: %v", err)
+	}
+	defer provider.Close()
+
+	if err := provider.Save([]byte(`{"key": "value1"}`)); err != nil {
+		t.Fatalf("Failed to save initial config: %v", err)
+	}
+	if _, err := provider.Load(); err != nil {
+		t.Fatalf("Failed to load initial config: %v", err)
+	}
+
+	errChan := make(chan error, 16)
+	provider.OnChange(func(data []byte) error { return nil })
+	provider.OnError(func(err error) {
+		errChan <- err
+	})
+	if err := provider.Watch(); err != nil {
+		t.Fatalf("Failed to start watching: %v", err)
+	}
+
+	// 表不可用时轮询失败，错误通过 OnError 上报
+	if err := provider.db.Exec("ALTER TABLE config_data RENAME TO config_data_bak").Error; err != nil {
+		t.Fatalf("Failed to rename table: %v", err)
+	}
+	select {
+	case err := <-errChan:
+		if err == nil {
+			t.Fatal("Expected poll error, got nil")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timeout waiting for poll error")
+	}
+
+	// 恢复后上报一次 nil
+	if err := provider.db.Exec("ALTER TABLE config_data_bak RENAME TO config_data").Error; err != nil {
+		t.Fatalf("Failed to restore table: %v", err)
+	}
+	deadline := time.After(2 * time.Second)
+	for {
+		select {
+		case err := <-errChan:
+			if err == nil {
+				return
+			}
+		case <-deadline:
+			t.Fatal("Timeout waiting for poll recovery")
+		}
+	}
+}
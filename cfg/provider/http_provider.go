@@ -0,0 +1,377 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hatlonely/gox/cfg/commonopt"
+	"github.com/pkg/errors"
+)
+
+// HttpProviderOptions HTTP Provider 配置选项
+type HttpProviderOptions struct {
+	// URL 配置的地址，支持 http 和 https
+	URL string `cfg:"url"`
+	// Headers 额外的请求头，如 {"Authorization": "Bearer xxx"}
+	Headers map[string]string `cfg:"headers"`
+	// BearerToken 设置 Authorization: Bearer <token> 请求头
+	BearerToken string `cfg:"bearerToken"`
+	// Username、Password 设置 HTTP Basic 认证
+	Username string `cfg:"username"`
+	Password string `cfg:"password"`
+	// TLS https 的客户端配置，为空时使用系统根证书
+	TLS *commonopt.TLSOptions `cfg:"tls"`
+	// Timeout 每次请求的超时时间，默认 10 秒
+	Timeout time.Duration `cfg:"timeout" def:"10s"`
+	// PollInterval 轮询间隔，默认 30 秒，通过 ETag/Last-Modified 条件请求，配置未变化时服务端返回 304
+	PollInterval time.Duration `cfg:"pollInterval" def:"30s"`
+
+	// Checksum 配置内容固定的 SHA-256 摘要，如 "sha256:<hex>"，用于锁定发布的配置版本
+	Checksum string `cfg:"checksum"`
+	// ChecksumHeader 响应头中配置内容的十六进制 SHA-256 摘要，如 "X-Checksum-Sha256"，设置之后响应必须携带
+	ChecksumHeader string `cfg:"checksumHeader"`
+	// PublicKey 校验签名的 Ed25519 公钥，PEM 或者 base64 编码的 32 字节公钥，设置之后响应必须携带签名
+	PublicKey string `cfg:"publicKey"`
+	// SignatureHeader 响应头中配置内容的 base64 编码 Ed25519 签名，默认 "X-Signature"
+	SignatureHeader string `cfg:"signatureHeader" def:"X-Signature"`
+}
+
+// HttpProvider 从 HTTP(S) 地址读取配置的提供者
+//
+// 轮询时携带 If-None-Match/If-Modified-Since，配置未变化时服务端返回 304，不传输配置内容；
+// 返回的内容先按 Checksum、ChecksumHeader、PublicKey 校验，校验失败时通过 OnError 上报，不触发变更
+type HttpProvider struct {
+	url             string
+	headers         http.Header
+	client          *http.Client
+	checksum        []byte
+	checksumHeader  string
+	publicKey       ed25519.PublicKey
+	signatureHeader string
+
+	mu           sync.RWMutex
+	onChange     []func(data []byte) error
+	onError      []func(err error)
+	etag         string
+	lastModified string
+	lastSum      [sha256.Size]byte
+	pollFailed   bool
+
+	// 变更监听
+	stopChan     chan struct{}
+	pollInterval time.Duration
+	once         sync.Once
+	closeOnce    sync.Once
+}
+
+// httpResponse 一次请求的结果，notModified 表示服务端返回 304
+type httpResponse struct {
+	data         []byte
+	etag         string
+	lastModified string
+	notModified  bool
+}
+
+// NewHttpProviderWithOptions 创建 HTTP Provider
+func NewHttpProviderWithOptions(options *HttpProviderOptions) (*HttpProvider, error) {
+	if options == nil || options.URL == "" {
+		return nil, errors.New("url is required")
+	}
+	if !strings.HasPrefix(options.URL, "http://") && !strings.HasPrefix(options.URL, "https://") {
+		return nil, errors.Errorf("unsupported url scheme: %s", options.URL)
+	}
+
+	timeout := options.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	pollInterval := options.PollInterval
+	if pollInterval <= 0 {
+		pollInterval = 30 * time.Second
+	}
+	signatureHeader := options.SignatureHeader
+	if signatureHeader == "" {
+		signatureHeader = "X-Signature"
+	}
+
+	tlsConfig, err := commonopt.NewTLSConfigWithOptions(options.TLS)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create tls config")
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	headers := http.Header{}
+	for key, value := range options.Headers {
+		headers.Set(key, value)
+	}
+	if options.BearerToken != "" {
+		headers.Set("Authorization", "Bearer "+options.BearerToken)
+	}
+
+	provider := &HttpProvider{
+		url:             options.URL,
+		headers:         headers,
+		client:          &http.Client{Timeout: timeout, Transport: transport},
+		checksumHeader:  options.ChecksumHeader,
+		signatureHeader: signatureHeader,
+		pollInterval:    pollInterval,
+		stopChan:        make(chan struct{}),
+	}
+	if options.Username != "" || options.Password != "" {
+		provider.headers.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(options.Username+":"+options.Password)))
+	}
+	if options.Checksum != "" {
+		if provider.checksum, err = hex.DecodeString(strings.TrimPrefix(options.Checksum, "sha256:")); err != nil || len(provider.checksum) != sha256.Size {
+			return nil, errors.Errorf("invalid sha256 checksum: %s", options.Checksum)
+		}
+	}
+	if options.PublicKey != "" {
+		if provider.publicKey, err = parseEd25519PublicKey(options.PublicKey); err != nil {
+			return nil, err
+		}
+	}
+
+	return provider, nil
+}
+
+// Load 读取配置数据，总是请求完整的内容
+func (p *HttpProvider) Load() ([]byte, error) {
+	resp, err := p.fetch("", "")
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.etag, p.lastModified = resp.etag, resp.lastModified
+	p.lastSum = sha256.Sum256(resp.data)
+	return resp.data, nil
+}
+
+// Save 不支持保存
+func (p *HttpProvider) Save(data []byte) error {
+	return errors.New("http provider does not support save operation")
+}
+
+// OnChange 注册配置变更回调函数
+func (p *HttpProvider) OnChange(fn func(data []byte) error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.onChange = append(p.onChange, fn)
+}
+
+// OnError 注册轮询错误回调函数，请求失败、服务端返回错误状态码和校验失败时上报
+func (p *HttpProvider) OnError(fn func(err error)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.onError = append(p.onError, fn)
+}
+
+// Watch 启动配置变更监听
+func (p *HttpProvider) Watch() error {
+	p.once.Do(func() {
+		go p.startPolling()
+	})
+
+	return nil
+}
+
+// startPolling 启动轮询监听配置变更
+func (p *HttpProvider) startPolling() {
+	ticker := time.NewTicker(p.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			p.checkForChanges()
+		case <-p.stopChan:
+			return
+		}
+	}
+}
+
+// checkForChanges 用上一次的 ETag/Last-Modified 发送条件请求，内容变化时调用回调
+func (p *HttpProvider) checkForChanges() {
+	p.mu.RLock()
+	handlers := make([]func(data []byte) error, len(p.onChange))
+	copy(handlers, p.onChange)
+	etag, lastModified, lastSum := p.etag, p.lastModified, p.lastSum
+	p.mu.RUnlock()
+
+	if len(handlers) == 0 {
+		return
+	}
+
+	resp, err := p.fetch(etag, lastModified)
+	if err != nil {
+		// 上报错误，继续轮询
+		p.reportPollResult(errors.Wrap(err, "failed to poll config"))
+		return
+	}
+	p.reportPollResult(nil)
+	if resp.notModified {
+		return
+	}
+
+	// 服务端不支持条件请求时按内容判断是否变化
+	sum := sha256.Sum256(resp.data)
+	if sum != lastSum {
+		for _, handler := range handlers {
+			if handler != nil {
+				if err := handler(resp.data); err != nil {
+					// 如果某个回调失败，记录但不影响其他回调
+					continue
+				}
+			}
+		}
+	}
+
+	p.mu.Lock()
+	p.etag, p.lastModified, p.lastSum = resp.etag, resp.lastModified, sum
+	p.mu.Unlock()
+}
+
+// fetch 请求配置，etag、lastModified 不为空时发送条件请求，返回的内容通过校验之后才返回
+func (p *HttpProvider) fetch(etag, lastModified string) (*httpResponse, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-p.stopChan:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.url, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create request")
+	}
+	req.Header = p.headers.Clone()
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	if lastModified != "" {
+		req.Header.Set("If-Modified-Since", lastModified)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to request config")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && (etag != "" || lastModified != "") {
+		return &httpResponse{notModified: true}, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, errors.Errorf("unexpected status %s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read response body")
+	}
+	if err := p.verify(data, resp.Header); err != nil {
+		return nil, err
+	}
+
+	return &httpResponse{
+		data:         data,
+		etag:         resp.Header.Get("ETag"),
+		lastModified: resp.Header.Get("Last-Modified"),
+	}, nil
+}
+
+// verify 按配置的摘要和签名校验配置内容
+func (p *HttpProvider) verify(data []byte, header http.Header) error {
+	sum := sha256.Sum256(data)
+	if p.checksum != nil && subtle.ConstantTimeCompare(sum[:], p.checksum) != 1 {
+		return errors.Errorf("checksum mismatch: expected sha256:%x, got sha256:%x", p.checksum, sum)
+	}
+	if p.checksumHeader != "" {
+		expected, err := hex.DecodeString(strings.TrimPrefix(header.Get(p.checksumHeader), "sha256:"))
+		if err != nil || len(expected) == 0 {
+			return errors.Errorf("missing or invalid checksum header %s", p.checksumHeader)
+		}
+		if subtle.ConstantTimeCompare(sum[:], expected) != 1 {
+			return errors.Errorf("checksum mismatch: header %s is %x, got %x", p.checksumHeader, expected, sum)
+		}
+	}
+	if p.publicKey != nil {
+		signature, err := base64.StdEncoding.DecodeString(header.Get(p.signatureHeader))
+		if err != nil || len(signature) == 0 {
+			return errors.Errorf("missing or invalid signature header %s", p.signatureHeader)
+		}
+		if !ed25519.Verify(p.publicKey, data, signature) {
+			return errors.New("signature verification failed")
+		}
+	}
+	return nil
+}
+
+// reportPollResult 上报轮询结果，失败时每次都上报，成功时只在从失败中恢复时上报一次
+func (p *HttpProvider) reportPollResult(err error) {
+	p.mu.Lock()
+	recovered := err == nil && p.pollFailed
+	p.pollFailed = err != nil
+	handlers := make([]func(err error), len(p.onError))
+	copy(handlers, p.onError)
+	p.mu.Unlock()
+
+	if err == nil && !recovered {
+		return
+	}
+	for _, handler := range handlers {
+		if handler != nil {
+			handler(err)
+		}
+	}
+}
+
+// Close 关闭提供者，停止轮询并取消进行中的请求
+func (p *HttpProvider) Close() error {
+	p.closeOnce.Do(func() {
+		close(p.stopChan)
+		p.client.CloseIdleConnections()
+	})
+	return nil
+}
+
+// parseEd25519PublicKey 解析 PEM（PKIX）或者 base64 编码的 Ed25519 公钥
+func parseEd25519PublicKey(key string) (ed25519.PublicKey, error) {
+	if block, _ := pem.Decode([]byte(key)); block != nil {
+		pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid public key")
+		}
+		edKey, ok := pub.(ed25519.PublicKey)
+		if !ok {
+			return nil, errors.Errorf("public key is %T, expected ed25519", pub)
+		}
+		return edKey, nil
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(key))
+	if err != nil || len(raw) != ed25519.PublicKeySize {
+		return nil, errors.New("invalid public key: expected PEM or base64 encoded ed25519 key")
+	}
+	return ed25519.PublicKey(raw), nil
+}
@@ -0,0 +1,238 @@
+package provider
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hatlonely/gox/ref"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// httpConfigServer 测试用的配置服务，按内容摘要生成 ETag，支持条件请求
+type httpConfigServer struct {
+	mu          sync.Mutex
+	data        []byte
+	headers     map[string]string
+	notModified atomic.Int32
+	authHeader  atomic.Value
+}
+
+func (s *httpConfigServer) set(data string, headers map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data, s.headers = []byte(data), headers
+}
+
+func (s *httpConfigServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.authHeader.Store(r.Header.Get("Authorization"))
+
+	sum := sha256.Sum256(s.data)
+	etag := `"` + hex.EncodeToString(sum[:8]) + `"`
+	if r.Header.Get("If-None-Match") == etag {
+		s.notModified.Add(1)
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("ETag", etag)
+	for key, value := range s.headers {
+		w.Header().Set(key, value)
+	}
+	w.Write(s.data)
+}
+
+func TestHttpProvider(t *testing.T) {
+	Convey("测试 HttpProvider", t, func() {
+		server := &httpConfigServer{}
+		server.set(`{"version": 1}`, nil)
+		ts := httptest.NewServer(server)
+		defer ts.Close()
+
+		Convey("读取配置并携带认证请求头", func() {
+			provider, err := NewHttpProviderWithOptions(&HttpProviderOptions{
+				URL:         ts.URL,
+				BearerToken: "secret",
+				Headers:     map[string]string{"X-Env": "prod"},
+			})
+			So(err, ShouldBeNil)
+			defer provider.Close()
+
+			data, err := provider.Load()
+			So(err, ShouldBeNil)
+			So(string(data), ShouldEqual, `{"version": 1}`)
+			So(server.authHeader.Load(), ShouldEqual, "Bearer secret")
+
+			So(provider.Save(data), ShouldNotBeNil)
+
+			provider, err = NewHttpProviderWithOptions(&HttpProviderOptions{URL: ts.URL, Username: "admin", Password: "pass"})
+			So(err, ShouldBeNil)
+			defer provider.Close()
+			_, err = provider.Load()
+			So(err, ShouldBeNil)
+			So(server.authHeader.Load(), ShouldEqual, "Basic "+base64.StdEncoding.EncodeToString([]byte("admin:pass")))
+		})
+
+		Convey("通过 ETag 轮询变更", func() {
+			provider, err := NewHttpProviderWithOptions(&HttpProviderOptions{URL: ts.URL, PollInterval: 20 * time.Millisecond})
+			So(err, ShouldBeNil)
+			defer provider.Close()
+
+			_, err = provider.Load()
+			So(err, ShouldBeNil)
+
+			changes := make(chan string, 10)
+			provider.OnChange(func(data []byte) error {
+				changes <- string(data)
+				return nil
+			})
+			So(provider.Watch(), ShouldBeNil)
+
+			// 配置未变化时服务端返回 304，不触发回调
+			time.Sleep(100 * time.Millisecond)
+			So(server.notModified.Load(), ShouldBeGreaterThan, 0)
+			So(len(changes), ShouldEqual, 0)
+
+			server.set(`{"version": 2}`, nil)
+			select {
+			case data := <-changes:
+				So(data, ShouldEqual, `{"version": 2}`)
+			case <-time.After(2 * time.Second):
+				So("timeout waiting for change", ShouldBeEmpty)
+			}
+			time.Sleep(100 * time.Millisecond)
+			So(len(changes), ShouldEqual, 0)
+		})
+
+		Convey("校验摘要", func() {
+			content := `{"version": 1}`
+			sum := sha256.Sum256([]byte(content))
+
+			provider, err := NewHttpProviderWithOptions(&HttpProviderOptions{URL: ts.URL, Checksum: "sha256:" + hex.EncodeToString(sum[:])})
+			So(err, ShouldBeNil)
+			defer provider.Close()
+			_, err = provider.Load()
+			So(err, ShouldBeNil)
+
+			server.set(`{"version": 2}`, nil)
+			_, err = provider.Load()
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "checksum mismatch")
+
+			provider, err = NewHttpProviderWithOptions(&HttpProviderOptions{URL: ts.URL, ChecksumHeader: "X-Checksum-Sha256"})
+			So(err, ShouldBeNil)
+			defer provider.Close()
+			_, err = provider.Load()
+			So(err, ShouldNotBeNil)
+
+			server.set(content, map[string]string{"X-Checksum-Sha256": hex.EncodeToString(sum[:])})
+			_, err = provider.Load()
+			So(err, ShouldBeNil)
+		})
+
+		Convey("校验签名，失败时上报错误且不触发变更", func() {
+			publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+			So(err, ShouldBeNil)
+			der, err := x509.MarshalPKIXPublicKey(publicKey)
+			So(err, ShouldBeNil)
+			pemKey := string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+			sign := func(data string) map[string]string {
+				return map[string]string{"X-Signature": base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, []byte(data)))}
+			}
+
+			for _, key := range []string{pemKey, base64.StdEncoding.EncodeToString(publicKey)} {
+				server.set(`{"version": 1}`, sign(`{"version": 1}`))
+				provider, err := NewHttpProviderWithOptions(&HttpProviderOptions{URL: ts.URL, PublicKey: key, PollInterval: 20 * time.Millisecond})
+				So(err, ShouldBeNil)
+				defer provider.Close()
+				_, err = provider.Load()
+				So(err, ShouldBeNil)
+
+				changes := make(chan string, 10)
+				errs := make(chan error, 10)
+				provider.OnChange(func(data []byte) error {
+					changes <- string(data)
+					return nil
+				})
+				provider.OnError(func(err error) { errs <- err })
+				So(provider.Watch(), ShouldBeNil)
+
+				// 签名与内容不符
+				server.set(`{"version": 2}`, sign(`{"version": 3}`))
+				select {
+				case err := <-errs:
+					So(err, ShouldNotBeNil)
+					So(err.Error(), ShouldContainSubstring, "signature verification failed")
+				case <-time.After(2 * time.Second):
+					So("timeout waiting for error", ShouldBeEmpty)
+				}
+				So(len(changes), ShouldEqual, 0)
+
+				// 恢复之后以 nil 上报一次
+				server.set(`{"version": 2}`, sign(`{"version": 2}`))
+				for err := range errs {
+					if err == nil {
+						break
+					}
+				}
+				select {
+				case data := <-changes:
+					So(data, ShouldEqual, `{"version": 2}`)
+				case <-time.After(2 * time.Second):
+					So("timeout waiting for change", ShouldBeEmpty)
+				}
+				provider.Close()
+			}
+		})
+
+		Convey("服务端错误", func() {
+			failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "config not found", http.StatusNotFound)
+			}))
+			defer failing.Close()
+
+			provider, err := NewHttpProviderWithOptions(&HttpProviderOptions{URL: failing.URL})
+			So(err, ShouldBeNil)
+			defer provider.Close()
+			_, err = provider.Load()
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "404")
+		})
+
+		Convey("参数错误", func() {
+			for _, options := range []*HttpProviderOptions{
+				nil,
+				{},
+				{URL: "ftp://example.com/config.json"},
+				{URL: ts.URL, Checksum: "sha256:1234"},
+				{URL: ts.URL, PublicKey: "not a key"},
+			} {
+				_, err := NewHttpProviderWithOptions(options)
+				So(err, ShouldNotBeNil)
+			}
+		})
+
+		Convey("通过 ref 创建", func() {
+			provider, err := NewProviderWithOptions(&ref.TypeOptions{
+				Namespace: "github.com/hatlonely/gox/cfg/provider",
+				Type:      "HttpProvider",
+				Options:   &HttpProviderOptions{URL: ts.URL},
+			})
+			So(err, ShouldBeNil)
+			defer provider.Close()
+			_, ok := provider.(ErrorReporter)
+			So(ok, ShouldBeTrue)
+		})
+	})
+}
@@ -11,12 +11,14 @@ func init() {
 	ref.MustRegisterT[RdbProvider](NewRdbProviderWithOptions)
 	ref.MustRegisterT[EnvProvider](NewEnvProviderWithOptions)
 	ref.MustRegisterT[CmdProvider](NewCmdProviderWithOptions)
+	ref.MustRegisterT[HttpProvider](NewHttpProviderWithOptions)
 
 	ref.MustRegisterT[*FileProvider](NewFileProviderWithOptions)
 	ref.MustRegisterT[*GormProvider](NewGormProviderWithOptions)
 	ref.MustRegisterT[*RdbProvider](NewRdbProviderWithOptions)
 	ref.MustRegisterT[*EnvProvider](NewEnvProviderWithOptions)
 	ref.MustRegisterT[*CmdProvider](NewCmdProviderWithOptions)
+	ref.MustRegisterT[*HttpProvider](NewHttpProviderWithOptions)
 }
 
 // Provider 配置数据提供者接口
@@ -36,8 +38,16 @@ type Provider interface {
 	Close() error
 }
 
+// ErrorReporter 可选接口，由轮询类 Provider 实现，用于上报后台检查配置变更时的错误
+// 轮询失败不会中断监听，但调用方需要通过它感知配置源已经不可用
+type ErrorReporter interface {
+	// OnError 注册轮询错误回调函数
+	// 检查失败时以错误调用，失败后第一次检查成功时以 nil 调用表示已恢复
+	OnError(fn func(err error))
+}
+
 func NewProviderWithOptions(options *ref.TypeOptions) (Provider, error) {
-	provider, err := ref.New(options.Namespace, options.Type, options.Options)
+	provider, err := ref.NewWithOptions(options)
 	if err != nil {
 		return nil, errors.WithMessage(err, "refx.NewT failed")
 	}
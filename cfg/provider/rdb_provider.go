@@ -32,7 +32,9 @@ type RdbProvider struct {
 	repo        repository.Repository[RdbConfigData]
 	mu          sync.RWMutex
 	onChange    []func(data []byte) error
+	onError     []func(err error)
 	lastVersion int64
+	pollFailed  bool
 
 	// 变更监听
 	stopChan     chan struct{}
@@ -167,6 +169,14 @@ func (p *RdbProvider) OnChange(fn func(data []byte) error) {
 	p.onChange = append(p.onChange, fn)
 }
 
+// OnError 注册轮询错误回调函数
+func (p *RdbProvider) OnError(fn func(err error)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.onError = append(p.onError, fn)
+}
+
 // Watch 启动配置变更监听
 func (p *RdbProvider) Watch() error {
 	p.once.Do(func() {
@@ -210,8 +220,11 @@ func (p *RdbProvider) checkForChanges() {
 	ctx := context.Background()
 	config, err := p.repo.FindOne(ctx, &query.TermQuery{Field: "id", Value: p.configID})
 	if err != nil {
-		return // 忽略错误，继续轮询
+		// 上报错误，继续轮询
+		p.reportPollResult(errors.Wrap(err, "failed to poll config"))
+		return
 	}
+	p.reportPollResult(nil)
 
 	if config.Version > lastVersion {
 		data := []byte(config.Content)
@@ -231,6 +244,25 @@ func (p *RdbProvider) checkForChanges() {
 	}
 }
 
+// reportPollResult 上报轮询结果，失败时每次都上报，成功时只在从失败中恢复时上报一次
+func (p *RdbProvider) reportPollResult(err error) {
+	p.mu.Lock()
+	recovered := err == nil && p.pollFailed
+	p.pollFailed = err != nil
+	handlers := make([]func(err error), len(p.onError))
+	copy(handlers, p.onError)
+	p.mu.Unlock()
+
+	if err == nil && !recovered {
+		return
+	}
+	for _, handler := range handlers {
+		if handler != nil {
+			handler(err)
+		}
+	}
+}
+
 // Close 关闭提供者，释放资源
 func (p *RdbProvider) Close() error {
 	close(p.stopChan)
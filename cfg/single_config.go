@@ -24,6 +24,8 @@ type HandlerExecutionOptions struct {
 	Async bool `cfg:"async"`
 	// 错误处理策略："continue" 继续执行其他 handler，"stop" 停止执行
 	ErrorPolicy string `cfg:"errorPolicy"`
+	// 防抖时间窗口，窗口内的多次变更合并为一次通知，默认 0 表示每次变更立即通知
+	Debounce time.Duration `cfg:"debounce"`
 }
 
 // SingleConfigOptions 配置类初始化选项
@@ -32,6 +34,14 @@ type SingleConfigOptions struct {
 	Decoder          ref.TypeOptions          `cfg:"decoder"`
 	Logger           *ref.TypeOptions         `cfg:"logger"`
 	HandlerExecution *HandlerExecutionOptions `cfg:"handlerExecution"`
+	// Redact 对外输出时需要脱敏的配置路径，如 "database.password"、"servers[*].token"
+	// 作用于 Effective、NewInspectHandler、PublishExpvar、变更日志以及存储的 Data 方法，ConvertTo 不受影响
+	Redact []string `cfg:"redact"`
+	// Profile 生效的环境，如 "prod"，将配置中 profiles.default 和 profiles.<profile> 合并到顶层配置上，见 storage.ApplyProfile
+	// 为空时不处理 profiles 段，按普通配置项读取
+	Profile string `cfg:"profile"`
+	// Merge 合并 profiles 时数组和 map 的合并策略，为空时 map 按键递归合并，数组整体覆盖，见 storage.MergeOptions
+	Merge *storage.MergeOptions `cfg:"merge"`
 }
 
 // SingleConfig 配置管理器
@@ -42,6 +52,10 @@ type SingleConfig struct {
 	decoder          decoder.Decoder
 	logger           logger.Logger            // 可选的日志记录器
 	handlerExecution *HandlerExecutionOptions // handler 执行配置
+	redact           []string                 // 对外输出时需要脱敏的路径
+	profile          string                   // 生效的环境，重新加载时同样合并
+	merge            *storage.MergeOptions    // 合并 profiles 的策略
+	source           SnapshotSource           // 配置源描述，用于生成快照
 
 	parent *SingleConfig
 	prefix string
@@ -49,6 +63,18 @@ type SingleConfig struct {
 	// 只有根配置才使用这些字段
 	// 统一的变更处理器映射，使用空字符串作为根配置变更的特殊key
 	onKeyChangeHandlers map[string][]func(storage.Storage) error
+	// 批量变更处理器（只有根配置使用）
+	batchHandlers []batchChangeHandler
+	// 合并短时间内的多次变更（只有根配置使用）
+	debouncer *changeDebouncer
+	// 配置变更的校验（只有根配置使用）
+	validators []configValidator
+
+	// 配置源加载状态（只有根配置使用）
+	status *statusTracker
+
+	// 保护 storage 的替换和读取（只有根配置使用）
+	storageMu sync.RWMutex
 
 	// Close 状态管理（只有根配置使用）
 	closeMu     sync.Mutex
@@ -61,6 +87,9 @@ func NewSingleConfigWithOptions(options *SingleConfigOptions) (*SingleConfig, er
 	if options == nil {
 		return nil, fmt.Errorf("options cannot be nil")
 	}
+	if err := validateMergeOptions(options.Merge); err != nil {
+		return nil, err
+	}
 
 	// 创建 Provider 实例
 	prov, err := provider.NewProviderWithOptions(&options.Provider)
@@ -85,9 +114,12 @@ func NewSingleConfigWithOptions(options *SingleConfigOptions) (*SingleConfig, er
 	if err != nil {
 		return nil, fmt.Errorf("failed to decode data: %w", err)
 	}
+	if stor, err = applyProfile(stor, options.Profile, options.Merge); err != nil {
+		return nil, err
+	}
 
 	// 用 ValidateStorage 包装 storage 以提供自动校验功能
-	stor = storage.NewValidateStorage(stor)
+	stor = storage.NewValidateStorage(redactStorage(stor, options.Redact))
 
 	// 创建 Logger (当 options.Logger 为 nil 时，log.NewLoggerWithOptions 自动返回默认 Logger)
 	logInstance, err := log.NewLoggerWithOptions(options.Logger)
@@ -120,13 +152,26 @@ func NewSingleConfigWithOptions(options *SingleConfigOptions) (*SingleConfig, er
 		decoder:             dec,
 		logger:              logInstance,
 		handlerExecution:    handlerExecution,
+		redact:              options.Redact,
+		profile:             options.Profile,
+		merge:               options.Merge,
+		source:              describeSource(0, options.Provider, options.Decoder),
 		onKeyChangeHandlers: make(map[string][]func(storage.Storage) error),
+		status:              newStatusTracker(1),
 	}
+	cfg.status.recordSuccess(0)
+	cfg.debouncer = newChangeDebouncer(handlerExecution.Debounce, cfg.notifyChange)
 
 	// 设置 Provider 的变更监听
 	prov.OnChange(func(newData []byte) error {
 		return cfg.handleProviderChange(newData)
 	})
+	// 轮询类 Provider 的后台错误记录到状态中
+	if reporter, ok := prov.(provider.ErrorReporter); ok {
+		reporter.OnError(func(err error) {
+			cfg.status.recordPollResult(0, err)
+		})
+	}
 
 	return cfg, nil
 }
@@ -195,29 +240,65 @@ func NewSingleConfig(filename string) (*SingleConfig, error) {
 // handleProviderChange 处理 Provider 数据变更
 func (c *SingleConfig) handleProviderChange(newData []byte) error {
 	// 保存旧的 storage
-	oldStorage := c.storage
+	oldStorage := c.getStorage()
 
 	// 重新解码数据
 	newStorage, err := c.decoder.Decode(newData)
 	if err != nil {
+		c.status.recordError(0, err)
 		return fmt.Errorf("failed to decode new data: %w", err)
 	}
+	if newStorage, err = applyProfile(newStorage, c.profile, c.merge); err != nil {
+		c.status.recordError(0, err)
+		return err
+	}
+
 	// 用 ValidateStorage 包装新的 storage 以提供自动校验功能
-	c.storage = storage.NewValidateStorage(newStorage)
+	wrappedStorage := storage.NewValidateStorage(redactStorage(newStorage, c.redact))
+
+	// 校验未通过时拒绝整个变更，继续使用旧的 storage
+	if verr := validateChange(c.validators, 0, wrappedStorage); verr != nil {
+		rejectChange(c.logger, c.status, verr)
+		return verr
+	}
+	c.status.recordSuccess(0)
+
+	c.storageMu.Lock()
+	c.storage = wrappedStorage
+	c.storageMu.Unlock()
+	logConfigChanges(c.logger, oldStorage, wrappedStorage, c.redact)
+
+	// 触发变更监听器，设置了防抖时合并窗口内的多次变更
+	c.debouncer.changed(oldStorage, wrappedStorage)
+
+	return nil
+}
+
+// getStorage 获取根配置当前的 storage
+func (c *SingleConfig) getStorage() storage.Storage {
+	c.storageMu.RLock()
+	defer c.storageMu.RUnlock()
+	return c.storage
+}
 
+// notifyChange 对比 oldStorage 和 newStorage，触发变更监听器
+func (c *SingleConfig) notifyChange(oldStorage, newStorage storage.Storage) {
 	// 检查并触发变更监听器（统一处理根配置和特定key）
 	for key, handlers := range c.onKeyChangeHandlers {
 		// 统一使用 isKeyChanged 检查，空字符串key会让Storage.Sub("")返回自己
 		if c.isKeyChanged(oldStorage, newStorage, key) {
 			// 统一使用 Sub 方法获取目标存储，Sub("")会返回自身
-			targetStorage := c.storage.Sub(key)
+			targetStorage := newStorage.Sub(key)
 
 			// 执行 handlers，直接使用原始的 key
 			c.executeHandlers(key, handlers, targetStorage)
 		}
 	}
 
-	return nil
+	// 批量变更监听器收到相对于自身的变更路径
+	for key, handlers := range batchHandlerFuncs(c.batchHandlers, oldStorage, newStorage) {
+		c.executeHandlers(key, handlers, newStorage.Sub(key))
+	}
 }
 
 // executeHandlers 执行 handler 列表，支持异步、超时和错误处理
@@ -344,14 +425,20 @@ func (c *SingleConfig) Sub(key string) Config {
 func (c *SingleConfig) ConvertTo(object any) error {
 	if c.parent == nil {
 		// 根配置直接使用自己的存储
-		return c.storage.ConvertTo(object)
+		return c.getStorage().ConvertTo(object)
 	}
 
 	// 子配置从父配置获取对应的子存储
-	subStorage := c.parent.storage.Sub(c.prefix)
+	subStorage := c.parent.getStorage().Sub(c.prefix)
 	return subStorage.ConvertTo(object)
 }
 
+// redactedData 获取配置当前生效的数据，Redact 选项指定的路径已脱敏
+func (c *SingleConfig) redactedData() (any, error) {
+	root := c.getRoot()
+	return redactedData(c, root.getStorage(), root.redact, c.prefix)
+}
+
 // SetLogger 设置日志记录器（只有根配置才能设置）
 func (c *SingleConfig) SetLogger(logger logger.Logger) {
 	root := c.getRoot()
@@ -382,6 +469,18 @@ func (c *SingleConfig) OnKeyChange(key string, fn func(storage.Storage) error) {
 	root.onKeyChangeHandlers[key] = append(root.onKeyChangeHandlers[key], fn)
 }
 
+// OnBatchChange 监听配置变更，回调收到变更之后的配置以及发生变更的路径，所有回调都注册到根配置上
+func (c *SingleConfig) OnBatchChange(fn func(ChangeSet) error) {
+	root := c.getRoot()
+	root.batchHandlers = append(root.batchHandlers, batchChangeHandler{key: c.prefix, fn: fn})
+}
+
+// OnValidate 注册配置变更的校验，所有校验都注册到根配置上
+func (c *SingleConfig) OnValidate(key string, fn func(storage.Storage) error) {
+	root := c.getRoot()
+	root.validators = append(root.validators, configValidator{key: joinKey(c.prefix, key), fn: fn})
+}
+
 // Watch 启动配置变更监听
 // 只有调用此方法后，OnChange 和 OnKeyChange 注册的回调函数才会被触发
 // 对于不支持监听的 Provider，此方法静默处理不返回错误
@@ -392,8 +491,10 @@ func (c *SingleConfig) Watch() error {
 		// 先启动 Provider 的监听
 		err := root.provider.Watch()
 		if err != nil {
+			root.status.recordError(0, err)
 			return err
 		}
+		root.status.setWatching(0, true)
 
 		// 主动检查一次配置变更，防止在初始化和 Watch 之间丢失变更
 		newData, loadErr := root.provider.Load()
@@ -401,12 +502,25 @@ func (c *SingleConfig) Watch() error {
 			// 触发变更检查和处理，由于 handleProviderChange 内部有变更检测逻辑，
 			// 如果没有实际变更就不会触发 handler
 			root.handleProviderChange(newData)
+		} else {
+			root.status.recordError(0, loadErr)
 		}
 		// 即使 Load 失败也不影响 Watch 的成功，因为可能是网络问题等临时错误
 	}
 	return nil
 }
 
+// Status 获取配置源的运行状态
+func (c *SingleConfig) Status() Status {
+	return c.getRoot().status.snapshot()
+}
+
+// Snapshot 获取配置快照，单配置源的所有配置项都来自配置源 0
+func (c *SingleConfig) Snapshot() (*Snapshot, error) {
+	root := c.getRoot()
+	return newSnapshot(c, []snapshotSource{{info: root.source, storage: root.getStorage()}}, root.redact, c.prefix)
+}
+
 // getRoot 获取根配置对象
 func (c *SingleConfig) getRoot() *SingleConfig {
 	root := c
@@ -431,8 +545,9 @@ func (c *SingleConfig) Close() error {
 		return root.closeResult
 	}
 
-	// 标记为已关闭
+	// 标记为已关闭，尚未通知的变更不再触发回调
 	root.closed = true
+	root.debouncer.stop()
 
 	// 执行关闭操作
 	if root.provider != nil {
@@ -440,6 +555,7 @@ func (c *SingleConfig) Close() error {
 	} else {
 		root.closeResult = nil
 	}
+	root.status.setWatching(0, false)
 
 	return root.closeResult
 }
@@ -0,0 +1,179 @@
+package cfg
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/hatlonely/gox/cfg/storage"
+	"github.com/hatlonely/gox/ref"
+)
+
+// Snapshot 配置快照，记录某一时刻生效的配置以及每个配置项来自哪个配置源
+// 可以直接序列化为 JSON，附加到崩溃报告、部署记录中用于复现问题；敏感配置项已脱敏
+type Snapshot struct {
+	// 生成快照的时间
+	Time time.Time `json:"time"`
+	// 合并之后生效的配置，敏感配置项和 Redact 选项指定的路径已脱敏
+	Config any `json:"config"`
+	// 配置源信息，按优先级从低到高排列
+	Sources []SnapshotSource `json:"sources"`
+	// 叶子配置项路径到提供该值的配置源索引，路径形如 "db.hosts[0].port"，子配置的路径相对于子配置
+	Provenance map[string]int `json:"provenance"`
+}
+
+// SnapshotSource 快照中的配置源信息
+type SnapshotSource struct {
+	Index    int    `json:"index"`
+	Provider string `json:"provider"`
+	Decoder  string `json:"decoder"`
+	// 创建 Provider 的选项，敏感配置项已脱敏
+	Options      any        `json:"options,omitempty"`
+	LastLoadTime *time.Time `json:"lastLoadTime,omitempty"`
+	LastError    string     `json:"lastError,omitempty"`
+}
+
+// JSON 将快照序列化为缩进格式的 JSON
+func (s *Snapshot) JSON() ([]byte, error) {
+	return json.MarshalIndent(s, "", "  ")
+}
+
+// describeSource 根据创建配置源的选项生成配置源描述，加载状态在生成快照时填充
+func describeSource(index int, providerOptions, decoderOptions ref.TypeOptions) SnapshotSource {
+	source := SnapshotSource{
+		Index:    index,
+		Provider: providerOptions.Type,
+		Decoder:  decoderOptions.Type,
+	}
+	if providerOptions.Options != nil {
+		// 选项可能是结构体，先转换为 map 再脱敏
+		if data, err := json.Marshal(providerOptions.Options); err == nil {
+			var options any
+			if json.Unmarshal(data, &options) == nil {
+				source.Options = maskSensitive(options)
+			}
+		}
+	}
+	return source
+}
+
+// snapshotSource 生成快照所需的配置源数据
+type snapshotSource struct {
+	info    SnapshotSource
+	storage storage.Storage
+}
+
+// newSnapshot 生成快照，prefix 为子配置相对于根配置的路径，redact 为根配置的脱敏路径
+// 快照中的配置由各配置源的数据逐层合并得到：map 按键递归合并，其他值由优先级高的配置源覆盖，与 ConvertTo 到结构体的结果一致
+func newSnapshot(config Config, sources []snapshotSource, redact []string, prefix string) (*Snapshot, error) {
+	snapshot := &Snapshot{
+		Time:       time.Now(),
+		Sources:    make([]SnapshotSource, 0, len(sources)),
+		Provenance: map[string]int{},
+	}
+
+	status := config.Status()
+	var merged any
+	leaves := make([]map[string]any, len(sources))
+	for i, source := range sources {
+		info := source.info
+		if i < len(status.Sources) {
+			if t := status.Sources[i].LastLoadTime; !t.IsZero() {
+				info.LastLoadTime = &t
+			}
+			if status.Sources[i].LastError != nil {
+				info.LastError = status.Sources[i].LastError.Error()
+			}
+		}
+		snapshot.Sources = append(snapshot.Sources, info)
+
+		var data any
+		if err := source.storage.ConvertTo(&data); err != nil {
+			return nil, fmt.Errorf("failed to convert source %d: %w", i, err)
+		}
+		merged = mergeSnapshotData(merged, data)
+		leaves[i] = map[string]any{}
+		collectLeaves("", data, leaves[i])
+	}
+
+	mergedLeaves := map[string]any{}
+	collectLeaves("", merged, mergedLeaves)
+	for fullPath, value := range mergedLeaves {
+		path := fullPath
+		if prefix != "" {
+			if !strings.HasPrefix(fullPath, prefix+".") && !strings.HasPrefix(fullPath, prefix+"[") {
+				continue
+			}
+			path = strings.TrimPrefix(strings.TrimPrefix(fullPath, prefix), ".")
+		}
+		snapshot.Provenance[path] = provenance(leaves, fullPath, value)
+	}
+
+	sub, _ := storage.NewMapStorage(merged).Redact(redact...).Sub(prefix).(*storage.MapStorage)
+	snapshot.Config = maskSensitive(sub.Data())
+
+	return snapshot, nil
+}
+
+// mergeSnapshotData 将 src 合并到 dst，map 按键递归合并，其他值直接覆盖，不修改 dst
+func mergeSnapshotData(dst, src any) any {
+	dstMap, ok1 := dst.(map[string]any)
+	srcMap, ok2 := src.(map[string]any)
+	if !ok1 || !ok2 {
+		return src
+	}
+	merged := make(map[string]any, len(dstMap)+len(srcMap))
+	for key, value := range dstMap {
+		merged[key] = value
+	}
+	for key, value := range srcMap {
+		merged[key] = mergeSnapshotData(merged[key], value)
+	}
+	return merged
+}
+
+// provenance 返回提供该值的配置源索引
+// 优先取值相同且优先级最高的配置源；合并时发生类型转换等情况找不到相同值时，取包含该路径且优先级最高的配置源
+func provenance(leaves []map[string]any, path string, value any) int {
+	found := -1
+	for i := len(leaves) - 1; i >= 0; i-- {
+		sourceValue, ok := leaves[i][path]
+		if !ok {
+			continue
+		}
+		if reflect.DeepEqual(sourceValue, value) {
+			return i
+		}
+		if found < 0 {
+			found = i
+		}
+	}
+	return found
+}
+
+// collectLeaves 展开配置数据，记录所有叶子配置项的路径和值
+func collectLeaves(path string, value any, leaves map[string]any) {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			collectLeaves(joinSnapshotPath(path, key), item, leaves)
+		}
+	case []any:
+		for i, item := range v {
+			collectLeaves(fmt.Sprintf("%s[%d]", path, i), item, leaves)
+		}
+	default:
+		if path != "" {
+			leaves[path] = value
+		}
+	}
+}
+
+func joinSnapshotPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,130 @@
+package cfg
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hatlonely/gox/cfg/decoder"
+	"github.com/hatlonely/gox/cfg/provider"
+	"github.com/hatlonely/gox/ref"
+)
+
+func newSnapshotTestSource(t *testing.T, name, content string) *ConfigSourceOptions {
+	configFile := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(configFile, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	return &ConfigSourceOptions{
+		Provider: ref.TypeOptions{
+			Namespace: "github.com/hatlonely/gox/cfg/provider",
+			Type:      "FileProvider",
+			Options:   &provider.FileProviderOptions{FilePath: configFile},
+		},
+		Decoder: ref.TypeOptions{
+			Namespace: "github.com/hatlonely/gox/cfg/decoder",
+			Type:      "JsonDecoder",
+			Options:   &decoder.JsonDecoderOptions{},
+		},
+	}
+}
+
+func TestSingleConfigSnapshot(t *testing.T) {
+	config := newInspectTestConfig(t)
+
+	snapshot, err := config.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	if snapshot.Time.IsZero() {
+		t.Error("expected snapshot time to be set")
+	}
+
+	database := snapshot.Config.(map[string]any)["database"].(map[string]any)
+	if database["password"] != MaskedValue {
+		t.Errorf("expected password to be masked, got %v", database["password"])
+	}
+
+	if len(snapshot.Sources) != 1 || snapshot.Sources[0].Provider != "FileProvider" || snapshot.Sources[0].Decoder != "JsonDecoder" {
+		t.Fatalf("unexpected sources: %+v", snapshot.Sources)
+	}
+	if snapshot.Sources[0].LastLoadTime == nil {
+		t.Error("expected last load time to be set")
+	}
+
+	for _, path := range []string{"server.port", "database.host", "database.replicas[0].host", "apiToken"} {
+		if index, ok := snapshot.Provenance[path]; !ok || index != 0 {
+			t.Errorf("expected provenance of %s to be 0, got %v, %v", path, index, ok)
+		}
+	}
+}
+
+func TestSnapshotRedact(t *testing.T) {
+	config := newInspectTestConfig(t, "server.port")
+
+	snapshot, err := config.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	if port := snapshot.Config.(map[string]any)["server"].(map[string]any)["port"]; port != MaskedValue {
+		t.Errorf("expected redacted port, got %v", port)
+	}
+	if snapshot.Provenance["server.port"] != 0 {
+		t.Errorf("expected provenance of redacted path to be kept, got %v", snapshot.Provenance)
+	}
+}
+
+func TestMultiConfigSnapshot(t *testing.T) {
+	config, err := NewMultiConfigWithOptions(&MultiConfigOptions{
+		Sources: []*ConfigSourceOptions{
+			newSnapshotTestSource(t, "base.json", `{"server": {"host": "0.0.0.0", "port": 8080}, "db": {"password": "base"}}`),
+			newSnapshotTestSource(t, "prod.json", `{"server": {"port": 9090}, "db": {"password": "prod"}}`),
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create config: %v", err)
+	}
+	defer config.Close()
+
+	snapshot, err := config.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+
+	expected := map[string]int{"server.host": 0, "server.port": 1, "db.password": 1}
+	if len(snapshot.Provenance) != len(expected) {
+		t.Errorf("unexpected provenance: %v", snapshot.Provenance)
+	}
+	for path, index := range expected {
+		if snapshot.Provenance[path] != index {
+			t.Errorf("expected provenance of %s to be %d, got %d", path, index, snapshot.Provenance[path])
+		}
+	}
+	if len(snapshot.Sources) != 2 || snapshot.Sources[1].Index != 1 {
+		t.Errorf("unexpected sources: %+v", snapshot.Sources)
+	}
+
+	data, err := snapshot.JSON()
+	if err != nil {
+		t.Fatalf("JSON failed: %v", err)
+	}
+	var decoded map[string]any
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("snapshot is not valid json: %v", err)
+	}
+	if decoded["config"].(map[string]any)["db"].(map[string]any)["password"] != MaskedValue {
+		t.Errorf("expected password to be masked in json, got %s", data)
+	}
+
+	sub, err := config.Sub("server").Snapshot()
+	if err != nil {
+		t.Fatalf("Sub Snapshot failed: %v", err)
+	}
+	if len(sub.Provenance) != 2 || sub.Provenance["host"] != 0 || sub.Provenance["port"] != 1 {
+		t.Errorf("unexpected sub provenance: %v", sub.Provenance)
+	}
+	if sub.Config.(map[string]any)["port"] != float64(9090) {
+		t.Errorf("unexpected sub config: %v", sub.Config)
+	}
+}
@@ -0,0 +1,141 @@
+package cfg
+
+import (
+	"sync"
+	"time"
+)
+
+// SourceStatus 单个配置源的运行状态
+type SourceStatus struct {
+	// 配置源索引，SingleConfig 固定为 0
+	Index int
+	// 最后一次成功加载（并解码）配置的时间
+	LastLoadTime time.Time
+	// 最后一次加载或解码失败的错误，成功加载后会被清空
+	LastError error
+	// 最后一次失败的时间
+	LastErrorTime time.Time
+	// 是否已经启动监听
+	Watching bool
+}
+
+// Healthy 配置源是否健康（最近一次加载成功）
+func (s SourceStatus) Healthy() bool {
+	return s.LastError == nil
+}
+
+// Status 配置对象的运行状态
+// 用于监控配置源是否长时间加载失败，服务是否在使用过期的配置运行
+type Status struct {
+	Sources []SourceStatus
+}
+
+// Healthy 所有配置源是否都健康
+func (s Status) Healthy() bool {
+	for _, source := range s.Sources {
+		if !source.Healthy() {
+			return false
+		}
+	}
+	return true
+}
+
+// statusTracker 记录配置源的加载状态，并发安全
+// nil tracker 上的所有操作都是空操作
+type statusTracker struct {
+	mu      sync.RWMutex
+	sources []SourceStatus
+	// 当前错误是否来自 Provider 的轮询，只有这类错误会在轮询恢复时清空
+	pollFailed []bool
+}
+
+func newStatusTracker(n int) *statusTracker {
+	sources := make([]SourceStatus, n)
+	for i := range sources {
+		sources[i].Index = i
+	}
+	return &statusTracker{sources: sources, pollFailed: make([]bool, n)}
+}
+
+// recordSuccess 记录一次成功加载
+func (t *statusTracker) recordSuccess(index int) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if index < 0 || index >= len(t.sources) {
+		return
+	}
+	t.sources[index].LastLoadTime = time.Now()
+	t.sources[index].LastError = nil
+	t.pollFailed[index] = false
+}
+
+// recordError 记录一次失败的加载
+func (t *statusTracker) recordError(index int, err error) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if index < 0 || index >= len(t.sources) || err == nil {
+		return
+	}
+	t.sources[index].LastError = err
+	t.sources[index].LastErrorTime = time.Now()
+	t.pollFailed[index] = false
+}
+
+// recordPollResult 记录 Provider 后台轮询的结果
+// 轮询失败时记录错误；轮询恢复时只清空由轮询产生的错误，解码失败等错误需要等下一次成功加载
+func (t *statusTracker) recordPollResult(index int, err error) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if index < 0 || index >= len(t.sources) {
+		return
+	}
+	if err != nil {
+		t.sources[index].LastError = err
+		t.sources[index].LastErrorTime = time.Now()
+		t.pollFailed[index] = true
+		return
+	}
+	if t.pollFailed[index] {
+		t.sources[index].LastError = nil
+		t.pollFailed[index] = false
+	}
+}
+
+// setWatching 设置监听状态
+func (t *statusTracker) setWatching(index int, watching bool) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if index < 0 || index >= len(t.sources) {
+		return
+	}
+	t.sources[index].Watching = watching
+}
+
+// snapshot 获取当前状态的副本
+func (t *statusTracker) snapshot() Status {
+	if t == nil {
+		return Status{}
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	sources := make([]SourceStatus, len(t.sources))
+	copy(sources, t.sources)
+	return Status{Sources: sources}
+}
@@ -0,0 +1,251 @@
+package cfg
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hatlonely/gox/cfg/decoder"
+	"github.com/hatlonely/gox/cfg/provider"
+	"github.com/hatlonely/gox/cfg/storage"
+	"github.com/hatlonely/gox/ref"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestStatusTracker(t *testing.T) {
+	tracker := newStatusTracker(2)
+
+	status := tracker.snapshot()
+	if len(status.Sources) != 2 {
+		t.Fatalf("expected 2 sources, got %d", len(status.Sources))
+	}
+	if status.Sources[1].Index != 1 {
+		t.Errorf("expected index 1, got %d", status.Sources[1].Index)
+	}
+
+	tracker.recordSuccess(0)
+	tracker.recordError(1, errors.New("load failed"))
+	tracker.setWatching(0, true)
+
+	status = tracker.snapshot()
+	if status.Sources[0].LastLoadTime.IsZero() {
+		t.Error("expected LastLoadTime to be set")
+	}
+	if !status.Sources[0].Watching {
+		t.Error("expected source 0 to be watching")
+	}
+	if status.Sources[1].Healthy() {
+		t.Error("expected source 1 to be unhealthy")
+	}
+	if status.Healthy() {
+		t.Error("expected status to be unhealthy")
+	}
+
+	// 成功加载后清空错误，但保留错误时间
+	tracker.recordSuccess(1)
+	status = tracker.snapshot()
+	if !status.Healthy() {
+		t.Error("expected status to be healthy after successful load")
+	}
+	if status.Sources[1].LastErrorTime.IsZero() {
+		t.Error("expected LastErrorTime to be kept")
+	}
+
+	// 越界索引和 nil tracker 不应 panic
+	// 轮询恢复只清空轮询产生的错误
+	tracker.recordPollResult(0, errors.New("poll failed"))
+	if tracker.snapshot().Sources[0].Healthy() {
+		t.Error("expected source 0 to be unhealthy after poll failure")
+	}
+	tracker.recordPollResult(0, nil)
+	if !tracker.snapshot().Sources[0].Healthy() {
+		t.Error("expected source 0 to be healthy after poll recovery")
+	}
+	tracker.recordError(0, errors.New("decode failed"))
+	tracker.recordPollResult(0, nil)
+	if tracker.snapshot().Sources[0].Healthy() {
+		t.Error("expected decode error to be kept after successful poll")
+	}
+
+	tracker.recordSuccess(5)
+	var nilTracker *statusTracker
+	nilTracker.recordError(0, errors.New("ignored"))
+	if len(nilTracker.snapshot().Sources) != 0 {
+		t.Error("expected empty status for nil tracker")
+	}
+}
+
+func TestSingleConfig_Status(t *testing.T) {
+	tempDir := t.TempDir()
+	configFile := filepath.Join(tempDir, "config.json")
+	if err := os.WriteFile(configFile, []byte(`{"key": "value"}`), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	config, err := NewSingleConfigWithOptions(&SingleConfigOptions{
+		Provider: ref.TypeOptions{
+			Namespace: "github.com/hatlonely/gox/cfg/provider",
+			Type:      "FileProvider",
+			Options:   &provider.FileProviderOptions{FilePath: configFile},
+		},
+		Decoder: ref.TypeOptions{
+			Namespace: "github.com/hatlonely/gox/cfg/decoder",
+			Type:      "JsonDecoder",
+			Options:   &decoder.JsonDecoderOptions{},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create config: %v", err)
+	}
+	defer config.Close()
+
+	status := config.Status()
+	if len(status.Sources) != 1 {
+		t.Fatalf("expected 1 source, got %d", len(status.Sources))
+	}
+	if !status.Healthy() || status.Sources[0].LastLoadTime.IsZero() {
+		t.Errorf("expected healthy status after construction, got %+v", status)
+	}
+	if status.Sources[0].Watching {
+		t.Error("expected not watching before Watch")
+	}
+
+	if err := config.Watch(); err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+	if !config.Sub("key").Status().Sources[0].Watching {
+		t.Error("expected sub config to report root watching status")
+	}
+
+	// 解码失败时记录错误，继续使用旧配置
+	if err := config.handleProviderChange([]byte(`{invalid json`)); err == nil {
+		t.Fatal("expected decode error")
+	}
+	status = config.Status()
+	if status.Healthy() || status.Sources[0].LastError == nil {
+		t.Errorf("expected unhealthy status after decode failure, got %+v", status)
+	}
+
+	if err := config.handleProviderChange([]byte(`{"key": "new"}`)); err != nil {
+		t.Fatalf("handleProviderChange failed: %v", err)
+	}
+	if !config.Status().Healthy() {
+		t.Error("expected healthy status after successful reload")
+	}
+
+	config.Close()
+	if config.Status().Sources[0].Watching {
+		t.Error("expected not watching after Close")
+	}
+}
+
+func TestMultiConfig_Status(t *testing.T) {
+	tempDir := t.TempDir()
+	configFile := filepath.Join(tempDir, "config.json")
+	if err := os.WriteFile(configFile, []byte(`{"key": "value"}`), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	fileSource, err := createFileSourceOptions(configFile)
+	if err != nil {
+		t.Fatalf("Failed to create file source options: %v", err)
+	}
+	config, err := NewMultiConfigWithOptions(&MultiConfigOptions{
+		Sources: []*ConfigSourceOptions{fileSource, createEnvSourceOptions("GOX_STATUS_TEST_")},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create config: %v", err)
+	}
+	defer config.Close()
+
+	status := config.Status()
+	if len(status.Sources) != 2 || !status.Healthy() {
+		t.Fatalf("expected 2 healthy sources, got %+v", status)
+	}
+
+	if err := config.handleSourceChange(0, []byte(`{invalid json`)); err == nil {
+		t.Fatal("expected decode error")
+	}
+	status = config.Status()
+	if status.Sources[0].Healthy() {
+		t.Error("expected source 0 to be unhealthy")
+	}
+	if !status.Sources[1].Healthy() {
+		t.Error("expected source 1 to be healthy")
+	}
+}
+
+func TestSingleConfig_StatusPollError(t *testing.T) {
+	dbFile := filepath.Join(t.TempDir(), "config.db")
+	gormOptions := &provider.GormProviderOptions{
+		ConfigID:     "test_config",
+		Driver:       "sqlite",
+		DSN:          dbFile,
+		PollInterval: 50 * time.Millisecond,
+	}
+	seed, err := provider.NewGormProviderWithOptions(gormOptions)
+	if err != nil {
+		t.Fatalf("Failed to create GormProvider: %v", err)
+	}
+	if err := seed.Save([]byte(`{"key": "value"}`)); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+	seed.Close()
+
+	config, err := NewSingleConfigWithOptions(&SingleConfigOptions{
+		Provider: ref.TypeOptions{
+			Namespace: "github.com/hatlonely/gox/cfg/provider",
+			Type:      "GormProvider",
+			Options:   gormOptions,
+		},
+		Decoder: ref.TypeOptions{
+			Namespace: "github.com/hatlonely/gox/cfg/decoder",
+			Type:      "JsonDecoder",
+			Options:   &decoder.JsonDecoderOptions{},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create config: %v", err)
+	}
+	defer config.Close()
+	config.OnChange(func(storage.Storage) error { return nil })
+	if err := config.Watch(); err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+
+	db, err := gorm.Open(sqlite.Open(dbFile), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	sqlDB, _ := db.DB()
+	defer sqlDB.Close()
+
+	waitStatus := func(healthy bool) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for config.Status().Healthy() != healthy {
+			if time.Now().After(deadline) {
+				t.Fatalf("expected healthy=%v, got %+v", healthy, config.Status())
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+	}
+
+	// 配置表不可用时轮询失败，状态变为不健康
+	if err := db.Exec("ALTER TABLE config_data RENAME TO config_data_bak").Error; err != nil {
+		t.Fatalf("Failed to rename table: %v", err)
+	}
+	waitStatus(false)
+	if config.Status().Sources[0].LastError == nil {
+		t.Error("expected poll error to be recorded")
+	}
+
+	// 轮询恢复后状态恢复健康
+	if err := db.Exec("ALTER TABLE config_data_bak RENAME TO config_data").Error; err != nil {
+		t.Fatalf("Failed to restore table: %v", err)
+	}
+	waitStatus(true)
+}
@@ -0,0 +1,258 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// AliasKey 别名节点的键
+// 只包含该键且值以 "#/" 开头的 map 节点是别名节点，读取时解析为指向的子树，
+// 如 {alias: "#/database/primary"}，多个组件可以共用同一份配置而不用重复书写
+const AliasKey = "alias"
+
+// aliasPrefix 别名路径前缀，路径从根节点开始，以 "/" 分隔，数组下标直接写数字，如 "#/servers/0"
+// 键中的 "/" 和 "~" 分别转义为 "~1" 和 "~0"
+const aliasPrefix = "#/"
+
+// ErrAliasCycle 别名循环引用
+var ErrAliasCycle = errors.New("alias cycle")
+
+// aliasPath 判断节点是否是别名节点，返回别名路径
+func aliasPath(node interface{}) (string, bool) {
+	rv := reflect.ValueOf(node)
+	if rv.Kind() != reflect.Map || rv.Len() != 1 {
+		return "", false
+	}
+
+	iter := rv.MapRange()
+	iter.Next()
+	key, value := iter.Key(), iter.Value()
+	for key.Kind() == reflect.Interface && !key.IsNil() {
+		key = key.Elem()
+	}
+	for value.Kind() == reflect.Interface && !value.IsNil() {
+		value = value.Elem()
+	}
+	if key.Kind() != reflect.String || key.String() != AliasKey || value.Kind() != reflect.String {
+		return "", false
+	}
+	if path := value.String(); strings.HasPrefix(path, aliasPrefix) {
+		return path, true
+	}
+	return "", false
+}
+
+// parseAliasPath 将别名路径解析为 key 序列，"#/" 表示根节点
+func parseAliasPath(path string) []string {
+	path = strings.TrimPrefix(path, aliasPrefix)
+	if path == "" {
+		return nil
+	}
+	keys := strings.Split(path, "/")
+	for i, key := range keys {
+		keys[i] = strings.ReplaceAll(strings.ReplaceAll(key, "~1", "/"), "~0", "~")
+	}
+	return keys
+}
+
+// checkAliasCycle 检查别名路径是否已在正在展开的别名路径中
+func checkAliasCycle(stack []string, path string) error {
+	for i, p := range stack {
+		if p == path {
+			return fmt.Errorf("%w: %s -> %s", ErrAliasCycle, strings.Join(stack[i:], " -> "), path)
+		}
+	}
+	return nil
+}
+
+// resolveAliasKeys 解析从根节点开始的 key 序列上经过的别名节点，返回实际的 key 序列
+// aliasAt 返回 key 序列指向的节点的别名路径，不同的存储按各自的结构实现；
+// stack 为正在展开的别名路径，没有消耗新的 key 而再次遇到时返回 ErrAliasCycle，
+// 如 c.child 指向 #/c 时 c.child.child.name 可以逐级解析
+func resolveAliasKeys(keys []string, aliasAt func(keys []string) (string, bool), stack []string) ([]string, error) {
+	base, rest := stack, len(keys)
+	for i := 1; i <= len(keys); i++ {
+		path, ok := aliasAt(keys[:i])
+		if !ok {
+			continue
+		}
+		if len(keys)-i < rest {
+			stack, rest = base, len(keys)-i
+		}
+		if err := checkAliasCycle(stack, path); err != nil {
+			return nil, err
+		}
+		stack = append(stack[:len(stack):len(stack)], path)
+
+		// 用别名路径替换已解析的前缀，从头检查，别名路径上同样可能有别名
+		keys = append(parseAliasPath(path), keys[i:]...)
+		i = 0
+	}
+	return keys, nil
+}
+
+// resolveAlias 解析别名链，返回第一个非别名节点和解析过程中经过的别名路径
+// stack 为正在展开的别名路径，再次遇到时返回 ErrAliasCycle
+func (ms *MapStorage) resolveAlias(node interface{}, stack []string) (interface{}, []string, error) {
+	for {
+		path, ok := aliasPath(node)
+		if !ok {
+			return node, stack, nil
+		}
+		if err := checkAliasCycle(stack, path); err != nil {
+			return nil, nil, err
+		}
+		stack = append(stack[:len(stack):len(stack)], path)
+
+		keys, err := resolveAliasKeys(parseAliasPath(path), ms.aliasAt, stack)
+		if err != nil {
+			return nil, nil, err
+		}
+		if node = ms.lookupRoot(keys); node == nil {
+			return nil, nil, fmt.Errorf("alias %q target not found", path)
+		}
+	}
+}
+
+// aliasAt 返回根节点下 key 序列指向的节点的别名路径
+func (ms *MapStorage) aliasAt(keys []string) (string, bool) {
+	return aliasPath(ms.lookupRoot(keys))
+}
+
+// lookupRoot 从根节点按 key 序列查找，不解析别名
+func (ms *MapStorage) lookupRoot(keys []string) interface{} {
+	current := ms.root
+	for _, key := range keys {
+		if current = ms.getValueByKey(current, key); current == nil {
+			return nil
+		}
+	}
+	return current
+}
+
+// resolveAliases 展开数据中的所有别名节点，没有别名时返回原数据，有别名时只复制包含别名的 map 和 slice
+func (ms *MapStorage) resolveAliases(node interface{}, stack []string) (interface{}, bool, error) {
+	node, next, err := ms.resolveAlias(node, stack)
+	if err != nil {
+		return nil, false, err
+	}
+	changed := len(next) > len(stack)
+
+	rv := reflect.ValueOf(node)
+	switch rv.Kind() {
+	case reflect.Map:
+		var resolved reflect.Value
+		for iter := rv.MapRange(); iter.Next(); {
+			value, valueChanged, err := ms.resolveAliases(iter.Value().Interface(), next)
+			if err != nil {
+				return nil, false, err
+			}
+			if !valueChanged {
+				continue
+			}
+			if !resolved.IsValid() {
+				resolved = reflect.MakeMapWithSize(rv.Type(), rv.Len())
+				for copyIter := rv.MapRange(); copyIter.Next(); {
+					resolved.SetMapIndex(copyIter.Key(), copyIter.Value())
+				}
+			}
+			elem, err := aliasValue(value, rv.Type().Elem())
+			if err != nil {
+				return nil, false, err
+			}
+			resolved.SetMapIndex(iter.Key(), elem)
+		}
+		if resolved.IsValid() {
+			return resolved.Interface(), true, nil
+		}
+	case reflect.Slice:
+		var resolved reflect.Value
+		for i := 0; i < rv.Len(); i++ {
+			value, valueChanged, err := ms.resolveAliases(rv.Index(i).Interface(), next)
+			if err != nil {
+				return nil, false, err
+			}
+			if !valueChanged {
+				continue
+			}
+			if !resolved.IsValid() {
+				resolved = reflect.MakeSlice(rv.Type(), rv.Len(), rv.Len())
+				reflect.Copy(resolved, rv)
+			}
+			elem, err := aliasValue(value, rv.Type().Elem())
+			if err != nil {
+				return nil, false, err
+			}
+			resolved.Index(i).Set(elem)
+		}
+		if resolved.IsValid() {
+			return resolved.Interface(), true, nil
+		}
+	}
+
+	return node, changed, nil
+}
+
+// aliasValue 检查解析后的值能否放入原来的容器，如 map[string]map[string]string 中的别名不能指向数组
+func aliasValue(value interface{}, elemType reflect.Type) (reflect.Value, error) {
+	if value == nil {
+		return reflect.Zero(elemType), nil
+	}
+	rv := reflect.ValueOf(value)
+	if !rv.Type().AssignableTo(elemType) {
+		return reflect.Value{}, fmt.Errorf("alias target %v is not assignable to %v", rv.Type(), elemType)
+	}
+	return rv, nil
+}
+
+// flatAliasAt 返回 FlatStorage 中 key 序列指向的节点的别名路径
+// 扁平数据中 "<key>.alias" 的值是以 "#/" 开头的字符串时，<key> 是别名节点
+func (fs *FlatStorage) flatAliasAt(dataSource map[string]interface{}) func(keys []string) (string, bool) {
+	return func(keys []string) (string, bool) {
+		key := fs.applyCase(strings.Join(append(keys[:len(keys):len(keys)], AliasKey), fs.separator))
+		path, ok := dataSource[key].(string)
+		if !ok || !strings.HasPrefix(path, aliasPrefix) {
+			return "", false
+		}
+		return path, true
+	}
+}
+
+// resolveFlatKey 解析完整键路径上经过的别名节点，返回实际的键路径
+func (fs *FlatStorage) resolveFlatKey(dataSource map[string]interface{}, fullKey string) (string, error) {
+	if fullKey == "" {
+		return fullKey, nil
+	}
+	keys, err := resolveAliasKeys(strings.Split(fullKey, fs.separator), fs.flatAliasAt(dataSource), nil)
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(keys, fs.separator), nil
+}
+
+// checkAliases 检查当前配置范围内的别名节点，循环引用时返回 ErrAliasCycle，目标不存在时返回错误
+func (fs *FlatStorage) checkAliases() error {
+	dataSource, prefix := fs.prepareKey("")
+	if prefix != "" {
+		prefix += fs.separator
+	}
+	suffix := fs.separator + fs.applyCase(AliasKey)
+	aliasAt := fs.flatAliasAt(dataSource)
+
+	for key, value := range dataSource {
+		path, ok := value.(string)
+		if !ok || !strings.HasPrefix(path, aliasPrefix) || !strings.HasPrefix(key, prefix) || !strings.HasSuffix(key, suffix) {
+			continue
+		}
+		keys, err := resolveAliasKeys(parseAliasPath(path), aliasAt, []string{path})
+		if err != nil {
+			return err
+		}
+		if !hasFlatKey(dataSource, fs.applyCase(strings.Join(keys, fs.separator)), fs.separator) {
+			return fmt.Errorf("alias %q target not found", path)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMapStorage_Alias(t *testing.T) {
+	type DatabaseConfig struct {
+		Host     string `cfg:"host"`
+		Port     int    `cfg:"port" def:"3306"`
+		Password string `cfg:"password"`
+	}
+
+	newData := func() map[string]interface{} {
+		return map[string]interface{}{
+			"database": map[string]interface{}{
+				"primary": map[string]interface{}{
+					"host":     "mysql-primary",
+					"password": "secret",
+				},
+				"default": map[string]interface{}{"alias": "#/database/primary"},
+			},
+			"services": map[string]interface{}{
+				"user":  map[string]interface{}{"db": map[string]interface{}{"alias": "#/database/primary"}},
+				"order": map[string]interface{}{"db": map[string]interface{}{"alias": "#/database/default"}},
+			},
+			"servers": []interface{}{
+				"server1",
+				map[string]interface{}{"alias": "#/servers/0"},
+			},
+			"upstream": map[string]interface{}{"alias": "#/services/user"},
+		}
+	}
+
+	Convey("别名解析测试", t, func() {
+		storage := NewMapStorage(newData())
+
+		Convey("ConvertTo 解析别名节点", func() {
+			var db DatabaseConfig
+			So(storage.Sub("services.user.db").ConvertTo(&db), ShouldBeNil)
+			So(db, ShouldResemble, DatabaseConfig{Host: "mysql-primary", Port: 3306, Password: "secret"})
+		})
+
+		Convey("解析别名链", func() {
+			var db DatabaseConfig
+			So(storage.Sub("services.order.db").ConvertTo(&db), ShouldBeNil)
+			So(db.Host, ShouldEqual, "mysql-primary")
+		})
+
+		Convey("Sub 路径经过别名节点", func() {
+			var host string
+			So(storage.Sub("upstream.db.host").ConvertTo(&host), ShouldBeNil)
+			So(host, ShouldEqual, "mysql-primary")
+		})
+
+		Convey("嵌套的别名一起展开", func() {
+			var services map[string]map[string]DatabaseConfig
+			So(storage.Sub("services").ConvertTo(&services), ShouldBeNil)
+			So(services["user"]["db"].Host, ShouldEqual, "mysql-primary")
+			So(services["order"]["db"].Host, ShouldEqual, "mysql-primary")
+
+			var servers []string
+			So(storage.Sub("servers").ConvertTo(&servers), ShouldBeNil)
+			So(servers, ShouldResemble, []string{"server1", "server1"})
+		})
+
+		Convey("展开别名不修改原始数据", func() {
+			var all map[string]interface{}
+			So(storage.ConvertTo(&all), ShouldBeNil)
+			So(storage.Data(), ShouldResemble, newData())
+			So(storage.Sub("database.default").(*MapStorage).Data(), ShouldResemble, map[string]interface{}{"alias": "#/database/primary"})
+		})
+
+		Convey("包含其他键的 map 不是别名节点", func() {
+			storage := NewMapStorage(map[string]interface{}{
+				"a": map[string]interface{}{"alias": "#/b", "name": "a"},
+				"b": map[string]interface{}{"alias": "not a path"},
+			})
+			var a, b map[string]string
+			So(storage.Sub("a").ConvertTo(&a), ShouldBeNil)
+			So(a, ShouldResemble, map[string]string{"alias": "#/b", "name": "a"})
+			So(storage.Sub("b").ConvertTo(&b), ShouldBeNil)
+			So(b, ShouldResemble, map[string]string{"alias": "not a path"})
+		})
+
+		Convey("路径转义", func() {
+			storage := NewMapStorage(map[string]interface{}{
+				"a/b": map[string]interface{}{"c~d": 1},
+				"ref": map[string]interface{}{"alias": "#/a~1b/c~0d"},
+			})
+			var v int
+			So(storage.Sub("ref").ConvertTo(&v), ShouldBeNil)
+			So(v, ShouldEqual, 1)
+		})
+
+		Convey("别名目标不存在", func() {
+			storage := NewMapStorage(map[string]interface{}{
+				"a": map[string]interface{}{"alias": "#/missing"},
+			})
+			var v map[string]interface{}
+			err := storage.ConvertTo(&v)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "#/missing")
+			So(storage.Sub("a.b"), ShouldBeNil)
+		})
+
+		Convey("循环引用", func() {
+			storage := NewMapStorage(map[string]interface{}{
+				"a": map[string]interface{}{"alias": "#/b"},
+				"b": map[string]interface{}{"alias": "#/a"},
+				"c": map[string]interface{}{
+					"name":  "c",
+					"child": map[string]interface{}{"alias": "#/c"},
+				},
+			})
+			var v interface{}
+			err := storage.Sub("a").ConvertTo(&v)
+			So(errors.Is(err, ErrAliasCycle), ShouldBeTrue)
+			So(err.Error(), ShouldContainSubstring, "#/b -> #/a -> #/b")
+
+			// 指向祖先节点的别名
+			err = storage.Sub("c").ConvertTo(&v)
+			So(errors.Is(err, ErrAliasCycle), ShouldBeTrue)
+
+			// 路径导航不展开子树，可以访问
+			var name string
+			So(storage.Sub("c.child.child.name").ConvertTo(&name), ShouldBeNil)
+			So(name, ShouldEqual, "c")
+		})
+
+		Convey("Equals 比较展开后的数据", func() {
+			changed := newData()
+			changed["database"].(map[string]interface{})["primary"].(map[string]interface{})["host"] = "mysql-new"
+			newStorage := NewMapStorage(changed)
+
+			So(storage.Sub("services.user").Equals(newStorage.Sub("services.user")), ShouldBeFalse)
+			So(storage.Sub("servers").Equals(newStorage.Sub("servers")), ShouldBeTrue)
+		})
+	})
+}
+
+func TestFlatStorage_Alias(t *testing.T) {
+	type DatabaseConfig struct {
+		Host     string `cfg:"host"`
+		Password string `cfg:"password"`
+	}
+
+	Convey("扁平存储的别名解析测试", t, func() {
+		storage := NewFlatStorage(map[string]interface{}{
+			"database.primary.host":     "mysql-primary",
+			"database.primary.password": "secret",
+			"database.default.alias":    "#/database/primary",
+			"services.user.db.alias":    "#/database/primary",
+			"services.order.db.alias":   "#/database/default",
+			"upstream.alias":            "#/services/user",
+			"c.name":                    "c",
+			"c.child.alias":             "#/c",
+		})
+
+		Convey("ConvertTo 解析别名节点", func() {
+			var db DatabaseConfig
+			So(storage.Sub("services.user.db").ConvertTo(&db), ShouldBeNil)
+			So(db.Host, ShouldEqual, "mysql-primary")
+			So(db.Password, ShouldEqual, "secret")
+
+			So(storage.Sub("services.order.db").ConvertTo(&db), ShouldBeNil)
+			So(db.Host, ShouldEqual, "mysql-primary")
+		})
+
+		Convey("Sub 路径经过别名节点", func() {
+			var host string
+			So(storage.Sub("upstream.db.host").ConvertTo(&host), ShouldBeNil)
+			So(host, ShouldEqual, "mysql-primary")
+
+			var name string
+			So(storage.Sub("c.child.child.name").ConvertTo(&name), ShouldBeNil)
+			So(name, ShouldEqual, "c")
+		})
+
+		Convey("map 中的别名节点", func() {
+			var services map[string]map[string]DatabaseConfig
+			So(storage.Sub("services").ConvertTo(&services), ShouldBeNil)
+			So(services["user"]["db"].Host, ShouldEqual, "mysql-primary")
+			So(services["order"]["db"].Password, ShouldEqual, "secret")
+		})
+
+		Convey("忽略大小写的键", func() {
+			storage := NewFlatStorage(map[string]interface{}{
+				"APP_DATABASE_HOST": "mysql-primary",
+				"APP_DB_ALIAS":      "#/app/database",
+			}).WithSeparator("_").WithUppercase(true)
+
+			var db DatabaseConfig
+			So(storage.Sub("app").Sub("db").ConvertTo(&db), ShouldBeNil)
+			So(db.Host, ShouldEqual, "mysql-primary")
+		})
+
+		Convey("别名目标不存在", func() {
+			storage := NewFlatStorage(map[string]interface{}{"a.alias": "#/missing"})
+			var v map[string]interface{}
+			err := storage.ConvertTo(&v)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "#/missing")
+		})
+
+		Convey("循环引用", func() {
+			storage := NewFlatStorage(map[string]interface{}{
+				"a.alias": "#/b",
+				"b.alias": "#/a",
+			})
+			var v interface{}
+			err := storage.ConvertTo(&v)
+			So(errors.Is(err, ErrAliasCycle), ShouldBeTrue)
+		})
+	})
+}
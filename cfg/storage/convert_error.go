@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ConvertError ConvertTo 转换失败的错误
+// Path 为出错的配置项相对于转换起点的路径，格式与 Sub 的 key 相同，如 "groups.primary[0].port"
+type ConvertError struct {
+	Path string
+	Err  error
+}
+
+func (e *ConvertError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Path, e.Err)
+}
+
+func (e *ConvertError) Unwrap() error {
+	return e.Err
+}
+
+// withKeyPath 为转换错误的路径补充一级 map 键或结构体字段名
+func withKeyPath(err error, key string) error {
+	return prependConvertPath(err, key)
+}
+
+// withIndexPath 为转换错误的路径补充一级数组下标
+func withIndexPath(err error, index int) error {
+	return prependConvertPath(err, "["+strconv.Itoa(index)+"]")
+}
+
+// prependConvertPath 在路径前补充一段，下层不是 ConvertError 时以该段作为路径
+func prependConvertPath(err error, segment string) error {
+	if err == nil {
+		return nil
+	}
+	convertErr, ok := err.(*ConvertError)
+	if !ok {
+		return &ConvertError{Path: segment, Err: err}
+	}
+	if strings.HasPrefix(convertErr.Path, "[") {
+		return &ConvertError{Path: segment + convertErr.Path, Err: convertErr.Err}
+	}
+	return &ConvertError{Path: segment + "." + convertErr.Path, Err: convertErr.Err}
+}
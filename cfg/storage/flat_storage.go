@@ -25,6 +25,7 @@ type FlatStorage struct {
 	enableDefaults bool
 	uppercase      bool
 	lowercase      bool
+	redactPaths    [][]string
 
 	parent *FlatStorage
 	prefix string
@@ -61,7 +62,41 @@ func (fs *FlatStorage) WithLowercase(enable bool) *FlatStorage {
 	return fs
 }
 
+// Redact 设置 Data 输出时需要脱敏的路径，路径格式与 MapStorage.Redact 相同
+// 启用大小写转换时路径按忽略大小写匹配，如 "db.password" 匹配环境变量 DB_PASSWORD
+func (fs *FlatStorage) Redact(paths ...string) *FlatStorage {
+	if fs == nil {
+		return nil
+	}
+	for _, path := range paths {
+		fs.redactPaths = append(fs.redactPaths, fs.parseKey(path))
+	}
+	return fs
+}
+
+// Data 获取存储的数据，Redact 指定路径下的键已脱敏，用于打印、调试输出等对外暴露的场景
 func (fs *FlatStorage) Data() map[string]interface{} {
+	if fs == nil || len(fs.redactPaths) == 0 || fs.data == nil {
+		return fs.UnsafeData()
+	}
+
+	ignoreCase := fs.uppercase || fs.lowercase
+	data := make(map[string]interface{}, len(fs.data))
+	for key, value := range fs.data {
+		if isRedacted(fs.redactPaths, strings.Split(key, fs.separator), ignoreCase) {
+			value = RedactedValue
+		}
+		data[key] = value
+	}
+	return data
+}
+
+// UnsafeData 获取未脱敏的原始数据
+// 仅用于编码回写等确实需要原始值的场景，不要直接输出到日志或调试接口
+func (fs *FlatStorage) UnsafeData() map[string]interface{} {
+	if fs == nil {
+		return nil
+	}
 	return fs.data
 }
 
@@ -75,6 +110,10 @@ func (fs *FlatStorage) Sub(key string) Storage {
 	}
 
 	keys := fs.parseKey(key)
+	// 通配路径返回所有匹配值组成的数组
+	if hasWildcard(keys) {
+		return fs.subWildcard(key)
+	}
 
 	return &FlatStorage{
 		parent:    fs,
@@ -96,6 +135,11 @@ func (fs *FlatStorage) ConvertTo(object interface{}) error {
 		}
 	}
 
+	// 检查别名节点
+	if err := fs.checkAliases(); err != nil {
+		return err
+	}
+
 	// 转换值
 	err := fs.convertValue("", reflect.ValueOf(object))
 	if err != nil {
@@ -105,7 +149,7 @@ func (fs *FlatStorage) ConvertTo(object interface{}) error {
 	return nil
 }
 
-// prepareKey 构建完整的键路径并应用大小写转换，同时返回数据源
+// prepareKey 构建完整的键路径，解析路径上的别名节点并应用大小写转换，同时返回数据源
 func (fs *FlatStorage) prepareKey(key string) (dataSource map[string]interface{}, actualKey string) {
 	// 构建完整的键路径
 	fullKey := key
@@ -117,28 +161,32 @@ func (fs *FlatStorage) prepareKey(key string) (dataSource map[string]interface{}
 		}
 	}
 
-	// 获取数据源和大小写配置
-	var useUppercase, useLowercase bool
+	dataSource = fs.data
 	if fs.parent != nil {
 		dataSource = fs.parent.data
-		useUppercase = fs.parent.uppercase
-		useLowercase = fs.parent.lowercase
-	} else {
-		dataSource = fs.data
-		useUppercase = fs.uppercase
-		useLowercase = fs.lowercase
 	}
 
-	// 应用大小写转换
-	if useUppercase {
-		actualKey = strings.ToUpper(fullKey)
-	} else if useLowercase {
-		actualKey = strings.ToLower(fullKey)
-	} else {
-		actualKey = fullKey
+	// 解析路径上的别名节点，别名无法解析时按原路径查找，错误由 ConvertTo 返回
+	if resolved, err := fs.resolveFlatKey(dataSource, fullKey); err == nil {
+		fullKey = resolved
 	}
 
-	return dataSource, actualKey
+	return dataSource, fs.applyCase(fullKey)
+}
+
+// applyCase 按大小写配置转换键，子配置使用父配置的设置
+func (fs *FlatStorage) applyCase(key string) string {
+	root := fs
+	if fs.parent != nil {
+		root = fs.parent
+	}
+	if root.uppercase {
+		return strings.ToUpper(key)
+	}
+	if root.lowercase {
+		return strings.ToLower(key)
+	}
+	return key
 }
 
 func (fs *FlatStorage) get(key string) interface{} {
@@ -150,7 +198,11 @@ func (fs *FlatStorage) get(key string) interface{} {
 // 对于结构体字段，检查是否有以该键路径为前缀的任何数据
 func (fs *FlatStorage) hasDataForKey(keyPath string) bool {
 	dataSource, actualKey := fs.prepareKey(keyPath)
+	return hasFlatKey(dataSource, actualKey, fs.separator)
+}
 
+// hasFlatKey 检查数据中是否有该键或者以该键为前缀的键
+func hasFlatKey(dataSource map[string]interface{}, actualKey string, separator string) bool {
 	// 直接检查是否有该键
 	if _, exists := dataSource[actualKey]; exists {
 		return true
@@ -159,7 +211,7 @@ func (fs *FlatStorage) hasDataForKey(keyPath string) bool {
 	// 检查是否有以该键路径为前缀的键（用于结构体）
 	keyPrefix := actualKey
 	if keyPrefix != "" {
-		keyPrefix += fs.separator
+		keyPrefix += separator
 	}
 
 	for key := range dataSource {
@@ -330,7 +382,7 @@ func (fs *FlatStorage) convertToStruct(keyPath string, dst reflect.Value) error
 
 		// 递归转换字段值
 		if err := fs.convertValue(fieldPath, fieldValue); err != nil {
-			return err
+			return withKeyPath(err, fieldName)
 		}
 	}
 
@@ -394,7 +446,7 @@ func (fs *FlatStorage) convertToSlice(keyPath string, dst reflect.Value) error {
 		}
 
 		if err := fs.convertValue(itemPath, dstItem); err != nil {
-			return err
+			return withIndexPath(err, i)
 		}
 	}
 
@@ -439,13 +491,9 @@ func (fs *FlatStorage) convertToMap(keyPath string, dst reflect.Value) error {
 	for mapKey, mapValue := range keyValueMap {
 		if isInterfaceValue {
 			// 对于interface{}类型，直接设置值
-			keyValue := reflect.ValueOf(mapKey)
-			if !keyValue.Type().AssignableTo(dst.Type().Key()) {
-				if keyValue.Type().ConvertibleTo(dst.Type().Key()) {
-					keyValue = keyValue.Convert(dst.Type().Key())
-				} else {
-					return fmt.Errorf("cannot convert key %v to %v", keyValue.Type(), dst.Type().Key())
-				}
+			keyValue, err := convertMapKey(reflect.ValueOf(mapKey), dst.Type().Key())
+			if err != nil {
+				return withKeyPath(err, mapKey)
 			}
 
 			dst.SetMapIndex(keyValue, reflect.ValueOf(mapValue))
@@ -470,17 +518,13 @@ func (fs *FlatStorage) convertToMap(keyPath string, dst reflect.Value) error {
 
 			// 递归转换值
 			if err := fs.convertValue(subKeyPath, dstValue); err != nil {
-				return err
+				return withKeyPath(err, mapKey)
 			}
 
 			// 转换键类型
-			keyValue := reflect.ValueOf(mapKey)
-			if !keyValue.Type().AssignableTo(dst.Type().Key()) {
-				if keyValue.Type().ConvertibleTo(dst.Type().Key()) {
-					keyValue = keyValue.Convert(dst.Type().Key())
-				} else {
-					return fmt.Errorf("cannot convert key %v to %v", keyValue.Type(), dst.Type().Key())
-				}
+			keyValue, err := convertMapKey(reflect.ValueOf(mapKey), dst.Type().Key())
+			if err != nil {
+				return withKeyPath(err, mapKey)
 			}
 
 			dst.SetMapIndex(keyValue, dstValue)
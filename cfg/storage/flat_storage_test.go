@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"errors"
 	"testing"
 	"time"
 
@@ -1228,3 +1229,102 @@ func TestFlatStorage_ConvertTo_ComplexNestedStructure(t *testing.T) {
 		})
 	})
 }
+
+func TestFlatStorage_ConvertTo_MapKeys(t *testing.T) {
+	Convey("FlatStorage 非字符串 map 键转换测试", t, func() {
+		storage := NewFlatStorage(map[string]interface{}{
+			"ports.80":        "http",
+			"ports.443":       "https",
+			"services.1.name": "api",
+			"services.2.name": "admin",
+		})
+
+		var config struct {
+			Ports    map[int]string `cfg:"ports"`
+			Services map[uint]struct {
+				Name string `cfg:"name"`
+			} `cfg:"services"`
+		}
+		So(storage.ConvertTo(&config), ShouldBeNil)
+		So(config.Ports, ShouldResemble, map[int]string{80: "http", 443: "https"})
+		So(config.Services[1].Name, ShouldEqual, "api")
+		So(config.Services[2].Name, ShouldEqual, "admin")
+
+		var invalid struct {
+			Ports map[int]string `cfg:"ports"`
+		}
+		So(NewFlatStorage(map[string]interface{}{"ports.http": "80"}).ConvertTo(&invalid), ShouldNotBeNil)
+	})
+}
+
+func TestFlatStorage_ConvertTo_NestedContainers(t *testing.T) {
+	type Endpoint struct {
+		Host string `cfg:"host"`
+		Port int    `cfg:"port"`
+	}
+
+	Convey("FlatStorage 嵌套容器转换测试", t, func() {
+		storage := NewFlatStorage(map[string]interface{}{
+			"groups.primary.0.host": "h1",
+			"groups.primary.0.port": 8080,
+			"groups.primary.1.host": "h2",
+			"groups.backup.0.host":  "h3",
+			"regions.cn.dev.0.host": "d1",
+		})
+
+		var config struct {
+			Groups  map[string][]Endpoint             `cfg:"groups"`
+			Regions map[string]map[string][]*Endpoint `cfg:"regions"`
+			Kinds   map[testServiceKind][]Endpoint    `cfg:"groups"`
+		}
+		So(storage.ConvertTo(&config), ShouldBeNil)
+		So(config.Groups["primary"], ShouldResemble, []Endpoint{{Host: "h1", Port: 8080}, {Host: "h2"}})
+		So(config.Groups["backup"], ShouldResemble, []Endpoint{{Host: "h3"}})
+		So(config.Regions["cn"]["dev"][0], ShouldResemble, &Endpoint{Host: "d1"})
+		So(config.Kinds["primary"][0].Host, ShouldEqual, "h1")
+
+		Convey("错误包含出错配置项的路径", func() {
+			storage := NewFlatStorage(map[string]interface{}{
+				"groups.primary.0.host": "h1",
+				"groups.primary.1.port": []int{1},
+			})
+			var groups struct {
+				Groups map[string][]Endpoint `cfg:"groups"`
+			}
+			err := storage.ConvertTo(&groups)
+			var convertErr *ConvertError
+			So(errors.As(err, &convertErr), ShouldBeTrue)
+			So(convertErr.Path, ShouldEqual, "groups.primary[1].port")
+		})
+	})
+}
+
+func TestFlatStorage_Redact(t *testing.T) {
+	Convey("FlatStorage 脱敏测试", t, func() {
+		data := map[string]interface{}{
+			"DB_HOST":         "localhost",
+			"DB_PASSWORD":     "p@ss",
+			"SERVERS_0_TOKEN": "t0",
+			"SERVERS_1_TOKEN": "t1",
+			"SERVERS_1_HOST":  "s1",
+		}
+		storage := NewFlatStorage(data).WithSeparator("_").WithUppercase(true).Redact("db.password", "servers[*].token")
+
+		So(storage.Data(), ShouldResemble, map[string]interface{}{
+			"DB_HOST":         "localhost",
+			"DB_PASSWORD":     RedactedValue,
+			"SERVERS_0_TOKEN": RedactedValue,
+			"SERVERS_1_TOKEN": RedactedValue,
+			"SERVERS_1_HOST":  "s1",
+		})
+		So(storage.UnsafeData()["DB_PASSWORD"], ShouldEqual, "p@ss")
+
+		var config struct {
+			DB struct {
+				Password string `cfg:"password"`
+			} `cfg:"db"`
+		}
+		So(storage.ConvertTo(&config), ShouldBeNil)
+		So(config.DB.Password, ShouldEqual, "p@ss")
+	})
+}
@@ -1,6 +1,8 @@
 package storage
 
 import (
+	"encoding"
+	"encoding/json"
 	"fmt"
 	"reflect"
 	"strconv"
@@ -13,11 +15,30 @@ import (
 // MapStorage 基于 map 和 slice 的存储实现
 type MapStorage struct {
 	data           interface{}
-	enableDefaults bool // 控制是否启用默认值功能
+	root           interface{} // 根节点数据，子配置中的别名从根节点查找
+	enableDefaults bool        // 控制是否启用默认值功能
+	redactPaths    [][]string  // 对外输出时需要脱敏的路径
 }
 
-// Data 获取存储的原始数据
+// Data 获取存储的数据，Redact 指定的路径已脱敏，用于打印、调试输出等对外暴露的场景
 func (ms *MapStorage) Data() interface{} {
+	if ms == nil {
+		return nil
+	}
+
+	data := ms.data
+	for _, path := range ms.redactPaths {
+		data = redactValue(data, path)
+	}
+	return data
+}
+
+// UnsafeData 获取未脱敏的原始数据
+// 仅用于编码回写等确实需要原始值的场景，不要直接输出到日志或调试接口
+func (ms *MapStorage) UnsafeData() interface{} {
+	if ms == nil {
+		return nil
+	}
 	return ms.data
 }
 
@@ -25,6 +46,7 @@ func (ms *MapStorage) Data() interface{} {
 func NewMapStorage(data interface{}) *MapStorage {
 	return &MapStorage{
 		data:           data,
+		root:           data,
 		enableDefaults: true,
 	}
 }
@@ -37,14 +59,31 @@ func (ms *MapStorage) WithDefaults(enable bool) *MapStorage {
 	return ms
 }
 
+// Redact 设置 Data 输出时需要脱敏的路径，如 "database.password"、"servers[*].token"
+// 路径格式与 Sub 的 key 相同，"*" 匹配任意 map 键或数组下标，路径指向的值（包括 map 和数组）整体替换为 RedactedValue。
+// 只影响 Data 的输出，ConvertTo 仍然得到原始值；子配置继承父配置的脱敏路径
+func (ms *MapStorage) Redact(paths ...string) *MapStorage {
+	if ms == nil {
+		return nil
+	}
+	for _, path := range paths {
+		ms.redactPaths = append(ms.redactPaths, ms.parseKey(path))
+	}
+	return ms
+}
+
 // Sub 获取子配置存储对象
 // key 可以包含点号（.）表示多级嵌套，[]表示数组索引
 // 例如 "database.connections[0].host"
-// 如果 key 不存在，返回 nil MapStorage
+// key 包含通配段 "*" 时返回所有匹配值组成的数组，如 "services[*].name"
+// 如果 key 不存在或没有匹配，返回 nil MapStorage
 func (ms *MapStorage) Sub(key string) Storage {
 	if key == "" {
 		return ms
 	}
+	if keys := ms.parseKey(key); hasWildcard(keys) {
+		return ms.subWildcard(keys)
+	}
 
 	result := ms.getValue(key)
 	if result == nil {
@@ -52,10 +91,12 @@ func (ms *MapStorage) Sub(key string) Storage {
 		return nilStorage
 	}
 
-	// 子配置继承父配置的默认值设置
+	// 子配置继承父配置的根节点、默认值设置和脱敏路径
 	subStorage := NewMapStorage(result)
 	if ms != nil {
+		subStorage.root = ms.root
 		subStorage.enableDefaults = ms.enableDefaults
+		subStorage.redactPaths = subRedactPaths(ms.redactPaths, ms.parseKey(key))
 	}
 	return subStorage
 }
@@ -75,8 +116,14 @@ func (ms *MapStorage) ConvertTo(object interface{}) error {
 		}
 	}
 
+	// 展开别名节点
+	data, _, err := ms.resolveAliases(ms.data, nil)
+	if err != nil {
+		return err
+	}
+
 	// 用配置数据覆盖默认值
-	err := ms.convertValue(ms.data, reflect.ValueOf(object))
+	err = ms.convertValue(data, reflect.ValueOf(object))
 	if err != nil {
 		return err
 	}
@@ -113,8 +160,13 @@ func (ms *MapStorage) Equals(other Storage) bool {
 		return false // non-nil != nil
 	}
 
-	// 直接比较 data 字段
-	return reflect.DeepEqual(ms.data, otherMapStorage.data)
+	// 比较展开别名后的数据，别名指向的配置变化时也视为不同，展开失败时比较原始数据
+	data, _, err := ms.resolveAliases(ms.data, nil)
+	otherData, _, otherErr := otherMapStorage.resolveAliases(otherMapStorage.data, nil)
+	if err != nil || otherErr != nil {
+		return reflect.DeepEqual(ms.data, otherMapStorage.data)
+	}
+	return reflect.DeepEqual(data, otherData)
 }
 
 // getValue 根据 key 获取嵌套的值
@@ -123,7 +175,12 @@ func (ms *MapStorage) getValue(key string) interface{} {
 	current := ms.data
 
 	for _, k := range keys {
-		current = ms.getValueByKey(current, k)
+		// 路径上的别名节点解析为指向的子树，最后一级保留别名节点，由子配置读取时解析
+		resolved, _, err := ms.resolveAlias(current, nil)
+		if err != nil {
+			return nil
+		}
+		current = ms.getValueByKey(resolved, k)
 		if current == nil {
 			return nil
 		}
@@ -276,6 +333,13 @@ func (ms *MapStorage) convertValue(src interface{}, dst reflect.Value) error {
 		srcValue = srcValue.Elem()
 	}
 
+	// 处理 json.Number（JsonDecoder 开启 UseNumber 时的数值表示），按目标类型精确解析
+	if num, ok := srcValue.Interface().(json.Number); ok {
+		if handled, err := ms.convertJSONNumber(num, dst); handled {
+			return err
+		}
+	}
+
 	// 类型完全匹配，但对于 map 类型需要特殊处理以支持增量合并
 	if srcValue.Type().AssignableTo(dst.Type()) {
 		// 如果目标是 map 类型，使用增量合并而不是完全替换
@@ -318,6 +382,70 @@ func (ms *MapStorage) convertValue(src interface{}, dst reflect.Value) error {
 	return fmt.Errorf("cannot convert %v to %v", srcValue.Type(), dst.Type())
 }
 
+// convertJSONNumber 将 json.Number 转换为数值类型，避免经过 float64 损失精度
+// 返回 false 表示目标类型不是数值类型，交由通用逻辑处理（如 string、interface{}、json.Number）
+func (ms *MapStorage) convertJSONNumber(num json.Number, dst reflect.Value) (bool, error) {
+	switch dst.Type() {
+	case reflect.TypeOf(time.Duration(0)):
+		// 与默认模式解码出的 float64 一致视为秒，整数不经过浮点运算
+		if i, err := num.Int64(); err == nil {
+			dst.Set(reflect.ValueOf(time.Duration(i) * time.Second))
+			return true, nil
+		}
+		f, err := num.Float64()
+		if err != nil {
+			return true, fmt.Errorf("failed to parse number %q as duration: %v", num, err)
+		}
+		dst.Set(reflect.ValueOf(time.Duration(f * float64(time.Second))))
+		return true, nil
+	case reflect.TypeOf(time.Time{}):
+		// Unix 时间戳（秒，支持小数）
+		if i, err := num.Int64(); err == nil {
+			dst.Set(reflect.ValueOf(time.Unix(i, 0)))
+			return true, nil
+		}
+		f, err := num.Float64()
+		if err != nil {
+			return true, fmt.Errorf("failed to parse number %q as time: %v", num, err)
+		}
+		seconds := int64(f)
+		dst.Set(reflect.ValueOf(time.Unix(seconds, int64((f-float64(seconds))*1e9))))
+		return true, nil
+	}
+
+	switch dst.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(num.String(), 10, 64)
+		if err != nil {
+			return true, fmt.Errorf("failed to parse number %q as %v: %v", num, dst.Type(), err)
+		}
+		if dst.OverflowInt(i) {
+			return true, fmt.Errorf("number %q overflows %v", num, dst.Type())
+		}
+		dst.SetInt(i)
+		return true, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(num.String(), 10, 64)
+		if err != nil {
+			return true, fmt.Errorf("failed to parse number %q as %v: %v", num, dst.Type(), err)
+		}
+		if dst.OverflowUint(u) {
+			return true, fmt.Errorf("number %q overflows %v", num, dst.Type())
+		}
+		dst.SetUint(u)
+		return true, nil
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(num.String(), dst.Type().Bits())
+		if err != nil {
+			return true, fmt.Errorf("failed to parse number %q as %v: %v", num, dst.Type(), err)
+		}
+		dst.SetFloat(f)
+		return true, nil
+	}
+
+	return false, nil
+}
+
 // convertTimeTypes 处理时间相关类型的转换
 func (ms *MapStorage) convertTimeTypes(src, dst reflect.Value) error {
 	dstType := dst.Type()
@@ -447,16 +575,12 @@ func (ms *MapStorage) convertToMap(src, dst reflect.Value) error {
 		}
 
 		if err := ms.convertValue(srcValue.Interface(), dstValue); err != nil {
-			return err
+			return withKeyPath(err, mapKeyString(key))
 		}
 
-		convertedKey := key
-		if !key.Type().AssignableTo(dst.Type().Key()) {
-			if key.Type().ConvertibleTo(dst.Type().Key()) {
-				convertedKey = key.Convert(dst.Type().Key())
-			} else {
-				return fmt.Errorf("cannot convert key %v to %v", key.Type(), dst.Type().Key())
-			}
+		convertedKey, err := convertMapKey(key, dst.Type().Key())
+		if err != nil {
+			return withKeyPath(err, mapKeyString(key))
 		}
 
 		dst.SetMapIndex(convertedKey, dstValue)
@@ -465,6 +589,92 @@ func (ms *MapStorage) convertToMap(src, dst reflect.Value) error {
 	return nil
 }
 
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// convertMapKey 将 map 的键转换为目标键类型
+// 配置中的键通常是字符串，目标键为整数、浮点数、布尔类型时按字面值解析，
+// 目标键实现了 encoding.TextUnmarshaler 时调用 UnmarshalText，支持 map[int]T、map[netip.Addr]T 等
+func convertMapKey(key reflect.Value, keyType reflect.Type) (reflect.Value, error) {
+	for key.Kind() == reflect.Interface && !key.IsNil() {
+		key = key.Elem()
+	}
+
+	if key.Type().AssignableTo(keyType) {
+		return key, nil
+	}
+
+	if key.Kind() == reflect.String {
+		str := key.String()
+		if reflect.PointerTo(keyType).Implements(textUnmarshalerType) {
+			dst := reflect.New(keyType)
+			if err := dst.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(str)); err != nil {
+				return reflect.Value{}, fmt.Errorf("failed to parse key %q as %v: %v", str, keyType, err)
+			}
+			return dst.Elem(), nil
+		}
+
+		dst := reflect.New(keyType).Elem()
+		var err error
+		switch keyType.Kind() {
+		case reflect.String:
+			dst.SetString(str)
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			var i int64
+			if i, err = strconv.ParseInt(str, 10, keyType.Bits()); err == nil {
+				dst.SetInt(i)
+			}
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			var u uint64
+			if u, err = strconv.ParseUint(str, 10, keyType.Bits()); err == nil {
+				dst.SetUint(u)
+			}
+		case reflect.Float32, reflect.Float64:
+			var f float64
+			if f, err = strconv.ParseFloat(str, keyType.Bits()); err == nil {
+				dst.SetFloat(f)
+			}
+		case reflect.Bool:
+			var b bool
+			if b, err = strconv.ParseBool(str); err == nil {
+				dst.SetBool(b)
+			}
+		default:
+			return reflect.Value{}, fmt.Errorf("cannot convert key %q to %v", str, keyType)
+		}
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("failed to parse key %q as %v: %v", str, keyType, err)
+		}
+		return dst, nil
+	}
+
+	// 数值键转换为字符串键时使用字面值，而不是 Go 类型转换的 Unicode 码点
+	if keyType.Kind() == reflect.String {
+		switch key.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+			reflect.Float32, reflect.Float64, reflect.Bool:
+			return reflect.ValueOf(fmt.Sprint(key.Interface())).Convert(keyType), nil
+		}
+	}
+
+	if key.Type().ConvertibleTo(keyType) {
+		return key.Convert(keyType), nil
+	}
+
+	return reflect.Value{}, fmt.Errorf("cannot convert key %v to %v", key.Type(), keyType)
+}
+
+// mapKeyString 返回 map 键的字面值，用于匹配结构体字段和错误路径
+func mapKeyString(key reflect.Value) string {
+	for key.Kind() == reflect.Interface && !key.IsNil() {
+		key = key.Elem()
+	}
+	if key.Kind() == reflect.String {
+		return key.String()
+	}
+	return fmt.Sprint(key.Interface())
+}
+
 // convertToSlice 转换为 slice 类型
 func (ms *MapStorage) convertToSlice(src, dst reflect.Value) error {
 	if src.Kind() != reflect.Slice && src.Kind() != reflect.Array {
@@ -486,7 +696,7 @@ func (ms *MapStorage) convertToSlice(src, dst reflect.Value) error {
 		}
 
 		if err := ms.convertValue(srcItem.Interface(), dstItem); err != nil {
-			return err
+			return withIndexPath(err, i)
 		}
 	}
 
@@ -544,9 +754,10 @@ func (ms *MapStorage) convertToStruct(src, dst reflect.Value) error {
 		}
 
 		// 查找对应的源值
+		// 源数据可能是 map[interface{}]interface{}，键需要先取出实际值再比较
 		var srcFieldValue reflect.Value
 		for _, key := range src.MapKeys() {
-			if key.String() == fieldName {
+			if mapKeyString(key) == fieldName {
 				srcFieldValue = src.MapIndex(key)
 				break
 			}
@@ -554,7 +765,7 @@ func (ms *MapStorage) convertToStruct(src, dst reflect.Value) error {
 
 		if srcFieldValue.IsValid() {
 			if err := ms.convertValue(srcFieldValue.Interface(), fieldValue); err != nil {
-				return err
+				return withKeyPath(err, fieldName)
 			}
 		}
 	}
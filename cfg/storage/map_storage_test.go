@@ -1,6 +1,9 @@
 package storage
 
 import (
+	"encoding/json"
+	"errors"
+	"net/netip"
 	"reflect"
 	"testing"
 	"time"
@@ -1485,3 +1488,278 @@ func TestMapStorage_EdgeCases(t *testing.T) {
 		})
 	})
 }
+
+// TestMapStorage_JSONNumber 测试 json.Number 的精确转换
+func TestMapStorage_JSONNumber(t *testing.T) {
+	Convey("MapStorage json.Number 转换测试", t, func() {
+		data := map[string]interface{}{
+			"id":       json.Number("9007199254740993"),
+			"uid":      json.Number("18446744073709551615"),
+			"price":    json.Number("3.141592653589793"),
+			"small":    json.Number("300"),
+			"timeout":  json.Number("1.5"),
+			"interval": json.Number("1000"),
+			"created":  json.Number("1703518245"),
+		}
+		storage := NewMapStorage(data)
+
+		Convey("大整数不损失精度", func() {
+			var config struct {
+				ID  int64  `cfg:"id"`
+				UID uint64 `cfg:"uid"`
+			}
+			So(storage.ConvertTo(&config), ShouldBeNil)
+			So(config.ID, ShouldEqual, int64(9007199254740993))
+			So(config.UID, ShouldEqual, uint64(18446744073709551615))
+		})
+
+		Convey("浮点数和字符串", func() {
+			var config struct {
+				Price    float64 `cfg:"price"`
+				PriceStr string  `cfg:"price"`
+			}
+			So(storage.ConvertTo(&config), ShouldBeNil)
+			So(config.Price, ShouldEqual, 3.141592653589793)
+			So(config.PriceStr, ShouldEqual, "3.141592653589793")
+		})
+
+		Convey("保留 json.Number 和 interface{}", func() {
+			var config struct {
+				ID    json.Number `cfg:"id"`
+				Price interface{} `cfg:"price"`
+			}
+			So(storage.ConvertTo(&config), ShouldBeNil)
+			So(config.ID, ShouldEqual, json.Number("9007199254740993"))
+			So(config.Price, ShouldEqual, json.Number("3.141592653589793"))
+		})
+
+		Convey("时间类型", func() {
+			var config struct {
+				Timeout  time.Duration `cfg:"timeout"`
+				Interval time.Duration `cfg:"interval"`
+				Created  time.Time     `cfg:"created"`
+			}
+			So(storage.ConvertTo(&config), ShouldBeNil)
+			So(config.Timeout, ShouldEqual, 1500*time.Millisecond)
+			So(config.Interval, ShouldEqual, 1000*time.Second)
+			So(config.Created.Unix(), ShouldEqual, int64(1703518245))
+		})
+
+		Convey("溢出和非整数报错", func() {
+			var small struct {
+				Small int8 `cfg:"small"`
+			}
+			So(storage.ConvertTo(&small), ShouldNotBeNil)
+
+			var price struct {
+				Price int `cfg:"price"`
+			}
+			So(storage.ConvertTo(&price), ShouldNotBeNil)
+		})
+	})
+}
+
+type testServiceKind string
+
+func TestMapStorage_ConvertTo_NestedContainers(t *testing.T) {
+	type Endpoint struct {
+		Host string `cfg:"host"`
+		Port int    `cfg:"port" def:"80"`
+	}
+
+	Convey("MapStorage 嵌套容器转换测试", t, func() {
+		storage := NewMapStorage(map[string]interface{}{
+			"groups": map[string]interface{}{
+				"primary": []interface{}{
+					map[string]interface{}{"host": "h1", "port": 8080},
+					map[string]interface{}{"host": "h2"},
+				},
+			},
+			"regions": map[string]interface{}{
+				"cn": map[string]interface{}{
+					"dev": []interface{}{map[string]interface{}{"host": "d1"}},
+				},
+			},
+			"legacy": map[interface{}]interface{}{"host": "l1", "port": 9090},
+		})
+
+		Convey("map 的值为结构体切片", func() {
+			var groups map[string][]Endpoint
+			So(storage.Sub("groups").ConvertTo(&groups), ShouldBeNil)
+			So(groups["primary"], ShouldResemble, []Endpoint{{Host: "h1", Port: 8080}, {Host: "h2", Port: 80}})
+
+			var pointers map[testServiceKind][]*Endpoint
+			So(storage.Sub("groups").ConvertTo(&pointers), ShouldBeNil)
+			So(pointers["primary"][1], ShouldResemble, &Endpoint{Host: "h2", Port: 80})
+		})
+
+		Convey("多层嵌套的 map 和切片", func() {
+			var regions map[string]map[string][]Endpoint
+			So(storage.Sub("regions").ConvertTo(&regions), ShouldBeNil)
+			So(regions["cn"]["dev"], ShouldResemble, []Endpoint{{Host: "d1", Port: 80}})
+		})
+
+		Convey("键为 interface{} 的 map 转换为结构体", func() {
+			var endpoint Endpoint
+			So(storage.Sub("legacy").ConvertTo(&endpoint), ShouldBeNil)
+			So(endpoint, ShouldResemble, Endpoint{Host: "l1", Port: 9090})
+		})
+
+		Convey("错误包含出错配置项的路径", func() {
+			storage := NewMapStorage(map[string]interface{}{
+				"groups": map[string]interface{}{
+					"primary": []interface{}{
+						map[string]interface{}{"host": "h1"},
+						map[string]interface{}{"port": []interface{}{1}},
+					},
+				},
+				"ports": map[string]interface{}{"http": "80"},
+			})
+
+			var config struct {
+				Groups map[string][]Endpoint `cfg:"groups"`
+			}
+			err := storage.ConvertTo(&config)
+			var convertErr *ConvertError
+			So(errors.As(err, &convertErr), ShouldBeTrue)
+			So(convertErr.Path, ShouldEqual, "groups.primary[1].port")
+			So(err.Error(), ShouldStartWith, "groups.primary[1].port: ")
+
+			var ports map[int]string
+			err = storage.Sub("ports").ConvertTo(&ports)
+			So(errors.As(err, &convertErr), ShouldBeTrue)
+			So(convertErr.Path, ShouldEqual, "http")
+		})
+	})
+}
+
+func TestMapStorage_ConvertTo_MapKeys(t *testing.T) {
+	Convey("MapStorage 非字符串 map 键转换测试", t, func() {
+		Convey("字符串键转换为整数、浮点数和布尔键", func() {
+			storage := NewMapStorage(map[string]interface{}{
+				"ports":   map[string]interface{}{"80": "http", "443": "https"},
+				"weights": map[string]interface{}{"0.5": 1, "1.5": 2},
+				"flags":   map[string]interface{}{"true": "on", "false": "off"},
+			})
+			var config struct {
+				Ports   map[uint16]string `cfg:"ports"`
+				Weights map[float64]int   `cfg:"weights"`
+				Flags   map[bool]string   `cfg:"flags"`
+			}
+			So(storage.ConvertTo(&config), ShouldBeNil)
+			So(config.Ports, ShouldResemble, map[uint16]string{80: "http", 443: "https"})
+			So(config.Weights, ShouldResemble, map[float64]int{0.5: 1, 1.5: 2})
+			So(config.Flags, ShouldResemble, map[bool]string{true: "on", false: "off"})
+		})
+
+		Convey("自定义字符串类型和 TextUnmarshaler 键", func() {
+			storage := NewMapStorage(map[string]interface{}{
+				"services": map[string]interface{}{
+					"api": map[string]interface{}{"port": 8080},
+				},
+				"hosts": map[string]interface{}{"127.0.0.1": "localhost"},
+			})
+			var config struct {
+				Services map[testServiceKind]struct {
+					Port int `cfg:"port"`
+				} `cfg:"services"`
+				Hosts map[netip.Addr]string `cfg:"hosts"`
+			}
+			So(storage.ConvertTo(&config), ShouldBeNil)
+			So(config.Services["api"].Port, ShouldEqual, 8080)
+			So(config.Hosts[netip.MustParseAddr("127.0.0.1")], ShouldEqual, "localhost")
+		})
+
+		Convey("非字符串键转换", func() {
+			storage := NewMapStorage(map[string]interface{}{
+				"codes": map[interface{}]interface{}{200: "ok", 404: "not found"},
+			})
+			var ints struct {
+				Codes map[int64]string `cfg:"codes"`
+			}
+			So(storage.ConvertTo(&ints), ShouldBeNil)
+			So(ints.Codes, ShouldResemble, map[int64]string{200: "ok", 404: "not found"})
+
+			var strs struct {
+				Codes map[string]string `cfg:"codes"`
+			}
+			So(storage.ConvertTo(&strs), ShouldBeNil)
+			So(strs.Codes, ShouldResemble, map[string]string{"200": "ok", "404": "not found"})
+		})
+
+		Convey("无法解析的键报错", func() {
+			var config struct {
+				Ports map[uint8]string `cfg:"ports"`
+			}
+			So(NewMapStorage(map[string]interface{}{
+				"ports": map[string]interface{}{"http": "80"},
+			}).ConvertTo(&config), ShouldNotBeNil)
+			So(NewMapStorage(map[string]interface{}{
+				"ports": map[string]interface{}{"300": "x"},
+			}).ConvertTo(&config), ShouldNotBeNil)
+		})
+	})
+}
+
+func TestMapStorage_Redact(t *testing.T) {
+	Convey("MapStorage 脱敏测试", t, func() {
+		data := map[string]interface{}{
+			"database": map[string]interface{}{
+				"host":     "localhost",
+				"password": "p@ss",
+			},
+			"servers": []interface{}{
+				map[string]interface{}{"host": "s1", "token": "t1"},
+				map[string]interface{}{"host": "s2", "token": "t2"},
+			},
+			"credentials": map[string]interface{}{"user": "admin"},
+		}
+		storage := NewMapStorage(data).Redact("database.password", "servers[*].token", "credentials")
+
+		Convey("Data 输出脱敏后的数据", func() {
+			So(storage.Data(), ShouldResemble, map[string]interface{}{
+				"database": map[string]interface{}{
+					"host":     "localhost",
+					"password": RedactedValue,
+				},
+				"servers": []interface{}{
+					map[string]interface{}{"host": "s1", "token": RedactedValue},
+					map[string]interface{}{"host": "s2", "token": RedactedValue},
+				},
+				"credentials": RedactedValue,
+			})
+		})
+
+		Convey("不修改原始数据", func() {
+			storage.Data()
+			So(storage.UnsafeData(), ShouldEqual, data)
+			So(data["database"].(map[string]interface{})["password"], ShouldEqual, "p@ss")
+
+			var config struct {
+				Database struct {
+					Password string `cfg:"password"`
+				} `cfg:"database"`
+			}
+			So(storage.ConvertTo(&config), ShouldBeNil)
+			So(config.Database.Password, ShouldEqual, "p@ss")
+		})
+
+		Convey("子配置继承脱敏路径", func() {
+			So(storage.Sub("database").(*MapStorage).Data(), ShouldResemble, map[string]interface{}{
+				"host":     "localhost",
+				"password": RedactedValue,
+			})
+			So(storage.Sub("servers[1]").(*MapStorage).Data(), ShouldResemble, map[string]interface{}{
+				"host":  "s2",
+				"token": RedactedValue,
+			})
+			So(storage.Sub("credentials").(*MapStorage).Data(), ShouldEqual, RedactedValue)
+			So(storage.Sub("credentials.user").(*MapStorage).Data(), ShouldEqual, RedactedValue)
+			So(storage.Sub("database.host").(*MapStorage).Data(), ShouldEqual, "localhost")
+		})
+
+		Convey("不存在的路径不做处理", func() {
+			So(NewMapStorage(data).Redact("cache.secret").Data(), ShouldResemble, data)
+		})
+	})
+}
@@ -0,0 +1,138 @@
+package storage
+
+import (
+	"sort"
+	"strings"
+)
+
+// ForceReplaceSuffix 键名以该后缀结尾时，该键的值整体覆盖低优先级配置中的值，不受 MergeOptions 影响，
+// 合并结果中的键名去掉后缀，如 "servers!: [...]" 覆盖低优先级配置中的 servers
+const ForceReplaceSuffix = "!"
+
+// SliceMergeStrategy 数组的合并策略
+type SliceMergeStrategy string
+
+const (
+	// SliceMergeReplace 高优先级的数组整体覆盖低优先级的数组，默认策略
+	SliceMergeReplace SliceMergeStrategy = "replace"
+	// SliceMergeAppend 高优先级数组的元素追加到低优先级数组之后
+	SliceMergeAppend SliceMergeStrategy = "append"
+	// SliceMergeIndex 按下标合并，相同下标的元素递归合并，多出的元素保留
+	SliceMergeIndex SliceMergeStrategy = "index"
+)
+
+// MapMergeStrategy map 的合并策略
+type MapMergeStrategy string
+
+const (
+	// MapMergeDeep 按键递归合并，默认策略
+	MapMergeDeep MapMergeStrategy = "deep"
+	// MapMergeReplace 高优先级的 map 整体覆盖低优先级的 map，顶层配置总是按键合并
+	MapMergeReplace MapMergeStrategy = "replace"
+)
+
+// MergeOptions 多层配置的合并选项，为空时 map 按键递归合并，数组整体覆盖
+type MergeOptions struct {
+	Slice SliceMergeStrategy `cfg:"slice" validate:"omitempty,oneof=replace append index"`
+	Map   MapMergeStrategy   `cfg:"map" validate:"omitempty,oneof=deep replace"`
+}
+
+// MergeData 按 options 将 override 合并到 base 上，返回合并后的数据，不修改原始数据
+// 只合并 map[string]interface{} 和 []interface{}，其他类型（包括 nil）的值由 override 覆盖，override 整体为 nil 时保留 base；
+// 两边以 ForceReplaceSuffix 结尾的键都去掉后缀，options 为 nil 时使用默认策略
+func MergeData(base, override interface{}, options *MergeOptions) interface{} {
+	if options == nil {
+		options = &MergeOptions{}
+	}
+	if override == nil {
+		return options.normalize(base)
+	}
+	return options.merge(options.normalize(base), override, true)
+}
+
+// merge 将 override 合并到已经去掉后缀的 base 上，root 表示顶层配置
+func (o *MergeOptions) merge(base, override interface{}, root bool) interface{} {
+	switch overrideValue := override.(type) {
+	case map[string]interface{}:
+		baseMap, ok := base.(map[string]interface{})
+		if !ok || (!root && o.Map == MapMergeReplace) {
+			return o.normalize(overrideValue)
+		}
+		result := make(map[string]interface{}, len(baseMap)+len(overrideValue))
+		for key, value := range baseMap {
+			result[key] = value
+		}
+		// 先合并普通键，再处理强制覆盖的键，同时存在 key 和 key! 时以 key! 为准
+		keys, forced := splitForcedKeys(overrideValue)
+		for _, key := range keys {
+			result[key] = o.merge(result[key], overrideValue[key], false)
+		}
+		for _, key := range forced {
+			result[strings.TrimSuffix(key, ForceReplaceSuffix)] = o.normalize(overrideValue[key])
+		}
+		return result
+	case []interface{}:
+		baseSlice, ok := base.([]interface{})
+		if !ok {
+			return o.normalize(overrideValue)
+		}
+		switch o.Slice {
+		case SliceMergeAppend:
+			result := make([]interface{}, 0, len(baseSlice)+len(overrideValue))
+			result = append(result, baseSlice...)
+			for _, value := range overrideValue {
+				result = append(result, o.normalize(value))
+			}
+			return result
+		case SliceMergeIndex:
+			result := make([]interface{}, max(len(baseSlice), len(overrideValue)))
+			copy(result, baseSlice)
+			for i, value := range overrideValue {
+				if i < len(baseSlice) {
+					result[i] = o.merge(baseSlice[i], value, false)
+				} else {
+					result[i] = o.normalize(value)
+				}
+			}
+			return result
+		}
+		return o.normalize(overrideValue)
+	}
+	return override
+}
+
+// normalize 复制 map 和数组，去掉其中所有以 ForceReplaceSuffix 结尾的键的后缀
+func (o *MergeOptions) normalize(data interface{}) interface{} {
+	switch value := data.(type) {
+	case map[string]interface{}:
+		keys, forced := splitForcedKeys(value)
+		result := make(map[string]interface{}, len(value))
+		for _, key := range keys {
+			result[key] = o.normalize(value[key])
+		}
+		for _, key := range forced {
+			result[strings.TrimSuffix(key, ForceReplaceSuffix)] = o.normalize(value[key])
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(value))
+		for i, elem := range value {
+			result[i] = o.normalize(elem)
+		}
+		return result
+	}
+	return data
+}
+
+// splitForcedKeys 将 map 的键分为普通键和以 ForceReplaceSuffix 结尾的键，强制覆盖的键按名称排序保证结果稳定
+func splitForcedKeys(data map[string]interface{}) (keys []string, forced []string) {
+	for key := range data {
+		if strings.HasSuffix(key, ForceReplaceSuffix) {
+			forced = append(forced, key)
+		} else {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(forced)
+	return keys, forced
+}
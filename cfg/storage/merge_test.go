@@ -0,0 +1,138 @@
+package storage
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMergeData(t *testing.T) {
+	Convey("MergeData 测试", t, func() {
+		base := map[string]interface{}{
+			"database": map[string]interface{}{"host": "localhost", "port": 3306},
+			"servers":  []interface{}{map[string]interface{}{"name": "web1", "port": 80}, "web2"},
+			"tags":     []interface{}{"a"},
+		}
+		override := map[string]interface{}{
+			"database": map[string]interface{}{"host": "db.prod", "user": nil},
+			"servers":  []interface{}{map[string]interface{}{"port": 8080}},
+			"tags":     []interface{}{"b"},
+		}
+
+		Convey("默认策略 map 递归合并，数组整体覆盖", func() {
+			So(MergeData(base, override, nil), ShouldResemble, map[string]interface{}{
+				"database": map[string]interface{}{"host": "db.prod", "port": 3306, "user": nil},
+				"servers":  []interface{}{map[string]interface{}{"port": 8080}},
+				"tags":     []interface{}{"b"},
+			})
+			// 原始数据不变
+			So(base["database"], ShouldResemble, map[string]interface{}{"host": "localhost", "port": 3306})
+		})
+
+		Convey("数组追加", func() {
+			merged := MergeData(base, override, &MergeOptions{Slice: SliceMergeAppend}).(map[string]interface{})
+			So(merged["tags"], ShouldResemble, []interface{}{"a", "b"})
+			So(len(merged["servers"].([]interface{})), ShouldEqual, 3)
+		})
+
+		Convey("数组按下标合并", func() {
+			merged := MergeData(base, override, &MergeOptions{Slice: SliceMergeIndex}).(map[string]interface{})
+			So(merged["servers"], ShouldResemble, []interface{}{map[string]interface{}{"name": "web1", "port": 8080}, "web2"})
+			So(merged["tags"], ShouldResemble, []interface{}{"b"})
+		})
+
+		Convey("map 整体覆盖，顶层仍然按键合并", func() {
+			merged := MergeData(base, override, &MergeOptions{Map: MapMergeReplace}).(map[string]interface{})
+			So(merged["database"], ShouldResemble, map[string]interface{}{"host": "db.prod", "user": nil})
+			So(merged["tags"], ShouldResemble, []interface{}{"b"})
+		})
+
+		Convey("键名以 ! 结尾时强制覆盖", func() {
+			merged := MergeData(base, map[string]interface{}{
+				"database!": map[string]interface{}{"host": "db.prod"},
+				"tags!":     []interface{}{"c"},
+				"servers":   []interface{}{map[string]interface{}{"labels!": []interface{}{"x"}}},
+			}, &MergeOptions{Slice: SliceMergeIndex})
+			So(merged, ShouldResemble, map[string]interface{}{
+				"database": map[string]interface{}{"host": "db.prod"},
+				"servers":  []interface{}{map[string]interface{}{"name": "web1", "port": 80, "labels": []interface{}{"x"}}, "web2"},
+				"tags":     []interface{}{"c"},
+			})
+		})
+
+		Convey("同时存在 key 和 key! 时以 key! 为准", func() {
+			merged := MergeData(nil, map[string]interface{}{"port": 1, "port!": 2}, nil)
+			So(merged, ShouldResemble, map[string]interface{}{"port": 2})
+		})
+
+		Convey("override 为 nil 时保留 base 并去掉后缀", func() {
+			So(MergeData(map[string]interface{}{"a!": []interface{}{map[string]interface{}{"b!": 1}}}, nil, nil), ShouldResemble,
+				map[string]interface{}{"a": []interface{}{map[string]interface{}{"b": 1}}})
+			So(MergeData("a", "b", nil), ShouldEqual, "b")
+		})
+	})
+}
+
+func TestMultiStorageWithMergeOptions(t *testing.T) {
+	Convey("NewMultiStorageWithMergeOptions 测试", t, func() {
+		base := NewMapStorage(map[string]interface{}{
+			"database": map[string]interface{}{"host": "localhost", "port": 3306},
+			"servers":  []interface{}{"web1", "web2"},
+			"plugins":  []interface{}{"auth"},
+		})
+		override := NewMapStorage(map[string]interface{}{
+			"database": map[string]interface{}{"host": "db.prod"},
+			"servers!": []interface{}{"prod1"},
+			"plugins":  []interface{}{"metrics"},
+		})
+
+		type Config struct {
+			Database struct {
+				Host string `cfg:"host"`
+				Port int    `cfg:"port"`
+			} `cfg:"database"`
+			Servers []string `cfg:"servers"`
+			Plugins []string `cfg:"plugins"`
+		}
+
+		Convey("数组追加，强制覆盖的键整体替换", func() {
+			ms := NewMultiStorageWithMergeOptions([]Storage{base, nil, NewValidateStorage(override)}, &MergeOptions{Slice: SliceMergeAppend})
+			var config Config
+			So(ms.ConvertTo(&config), ShouldBeNil)
+			So(config.Database.Host, ShouldEqual, "db.prod")
+			So(config.Database.Port, ShouldEqual, 3306)
+			So(config.Servers, ShouldResemble, []string{"prod1"})
+			So(config.Plugins, ShouldResemble, []string{"auth", "metrics"})
+
+			var plugins []string
+			So(ms.Sub("plugins").ConvertTo(&plugins), ShouldBeNil)
+			So(plugins, ShouldResemble, []string{"auth", "metrics"})
+			var servers []string
+			So(ms.Sub("servers").ConvertTo(&servers), ShouldBeNil)
+			So(servers, ShouldResemble, []string{"prod1"})
+
+			paths, err := ms.(Matcher).Match("plugins[*]")
+			So(err, ShouldBeNil)
+			So(paths, ShouldResemble, []string{"plugins[0]", "plugins[1]"})
+		})
+
+		Convey("其他类型的存储源按顺序覆盖", func() {
+			flat := NewFlatStorage(map[string]interface{}{"database.port": 5432})
+			env := NewMapStorage(map[string]interface{}{"plugins": []interface{}{"trace"}})
+			ms := NewMultiStorageWithMergeOptions([]Storage{base, override, flat, env}, &MergeOptions{Slice: SliceMergeAppend})
+			var config Config
+			So(ms.ConvertTo(&config), ShouldBeNil)
+			So(config.Database.Host, ShouldEqual, "db.prod")
+			So(config.Database.Port, ShouldEqual, 5432)
+			So(config.Plugins, ShouldResemble, []string{"trace"})
+		})
+
+		Convey("合并之后仍然校验", func() {
+			type Required struct {
+				Name string `cfg:"name" validate:"required"`
+			}
+			ms := NewMultiStorageWithMergeOptions([]Storage{NewValidateStorage(NewMapStorage(map[string]interface{}{}))}, nil)
+			So(ms.ConvertTo(&Required{}), ShouldNotBeNil)
+		})
+	})
+}
@@ -16,8 +16,9 @@ type MultiStorage interface {
 
 // multiStorage 多配置存储的具体实现
 type multiStorage struct {
-	sources []Storage    // 配置源存储数组，索引越大优先级越高
-	mu      sync.RWMutex // 读写锁，保护并发访问
+	sources []Storage     // 配置源存储数组，索引越大优先级越高
+	merge   *MergeOptions // 合并选项，为 nil 时依次调用每个存储源的 ConvertTo
+	mu      sync.RWMutex  // 读写锁，保护并发访问
 }
 
 // NewMultiStorage 创建多配置存储
@@ -36,6 +37,18 @@ func NewMultiStorage(sources []Storage) MultiStorage {
 	}
 }
 
+// NewMultiStorageWithMergeOptions 创建按 options 合并数据的多配置存储
+// 相邻的 MapStorage 存储源（包括 ValidateStorage 包装的 MapStorage）先按 MergeData 合并数据，
+// 数组可以追加或者按下标合并，键名以 ForceReplaceSuffix 结尾时整体覆盖；其他存储源（如 FlatStorage）仍然按顺序调用 ConvertTo 覆盖
+func NewMultiStorageWithMergeOptions(sources []Storage, options *MergeOptions) MultiStorage {
+	ms := NewMultiStorage(sources).(*multiStorage)
+	if options == nil {
+		options = &MergeOptions{}
+	}
+	ms.merge = options
+	return ms
+}
+
 // UpdateStorage 更新指定索引的存储源，返回是否有变更
 func (ms *multiStorage) UpdateStorage(index int, storage Storage) bool {
 	ms.mu.Lock()
@@ -70,11 +83,16 @@ func (ms *multiStorage) ConvertTo(object any) error {
 	ms.mu.RLock()
 	defer ms.mu.RUnlock()
 
+	sources, err := ms.layers()
+	if err != nil {
+		return err
+	}
+
 	// 依次调用每个存储源的 ConvertTo，实现增量合并
 	// - 对于结构体：字段级覆盖，后面的配置覆盖前面的配置，不存在的字段保持原值
 	// - 对于 map：增量合并，新键被添加，已存在的键被覆盖，其他键被保留
 	// - 对于其他类型：按照各 Storage 实现的语义处理
-	for i, storage := range ms.sources {
+	for i, storage := range sources {
 		if storage != nil {
 			if err := storage.ConvertTo(object); err != nil {
 				return fmt.Errorf("failed to convert from source %d: %w", i, err)
@@ -92,18 +110,86 @@ func (ms *multiStorage) Sub(key string) Storage {
 	ms.mu.RLock()
 	defer ms.mu.RUnlock()
 
+	// 合并失败（如别名无法解析）时对原始的存储源取子存储
+	sources, err := ms.layers()
+	if err != nil {
+		sources = ms.sources
+	}
+
 	// 为每个存储源创建对应的子存储
-	subSources := make([]Storage, len(ms.sources))
-	for i, storage := range ms.sources {
+	subSources := make([]Storage, len(sources))
+	for i, storage := range sources {
 		if storage != nil {
 			subSources[i] = storage.Sub(key)
 		}
 		// 如果 storage 为 nil，subSources[i] 保持为 nil
 	}
 
+	if ms.merge != nil {
+		return NewMultiStorageWithMergeOptions(subSources, ms.merge)
+	}
 	return NewMultiStorage(subSources)
 }
 
+// layers 返回实际参与合并的存储源，未设置合并选项时为原始的存储源，
+// 否则相邻的 MapStorage 存储源按合并选项合并为一个存储，存储源中有 ValidateStorage 时合并结果同样校验
+func (ms *multiStorage) layers() ([]Storage, error) {
+	if ms.merge == nil {
+		return ms.sources, nil
+	}
+
+	var layers []Storage
+	var merged *MapStorage
+	validate := false
+	flush := func() {
+		if merged == nil {
+			return
+		}
+		if validate {
+			layers = append(layers, NewValidateStorage(merged))
+		} else {
+			layers = append(layers, merged)
+		}
+		merged, validate = nil, false
+	}
+	for i, source := range ms.sources {
+		mapStorage, wrapped, ok := unwrapMapStorage(source)
+		if !ok {
+			flush()
+			layers = append(layers, source)
+			continue
+		}
+		if mapStorage == nil {
+			continue
+		}
+		data, _, err := mapStorage.resolveAliases(mapStorage.data, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert from source %d: %w", i, err)
+		}
+		if merged == nil {
+			merged = NewMapStorage(MergeData(data, nil, ms.merge)).WithDefaults(mapStorage.enableDefaults)
+		} else {
+			merged = NewMapStorage(MergeData(merged.data, data, ms.merge)).WithDefaults(merged.enableDefaults || mapStorage.enableDefaults)
+		}
+		validate = validate || wrapped
+	}
+	flush()
+	return layers, nil
+}
+
+// unwrapMapStorage 获取存储源中的 MapStorage，wrapped 表示由 ValidateStorage 包装，
+// 存储源为 nil 时 ok 为 true、返回的 MapStorage 为 nil，表示没有数据
+func unwrapMapStorage(s Storage) (ms *MapStorage, wrapped bool, ok bool) {
+	if vs, isValidate := s.(*ValidateStorage); isValidate && vs != nil {
+		s, wrapped = vs.storage, true
+	}
+	if isNilStorage(s) {
+		return nil, wrapped, true
+	}
+	ms, ok = s.(*MapStorage)
+	return ms, wrapped, ok
+}
+
 // Equals 比较两个存储是否包含相同的数据内容
 func (ms *multiStorage) Equals(other Storage) bool {
 	if other == nil {
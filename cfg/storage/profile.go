@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ProfilesKey 配置文件中按环境覆盖配置的配置段
+const ProfilesKey = "profiles"
+
+// DefaultProfile profiles 中所有环境共用的覆盖配置，总是最先合并
+const DefaultProfile = "default"
+
+// ApplyProfile 将 profiles 段中的环境配置合并到顶层配置上，返回不包含 profiles 段的新存储，不修改原始数据
+//
+// 合并顺序为：顶层配置 < profiles.default < profile，profile 可以用逗号指定多个环境，如 "prod,canary"，
+// 后面的环境覆盖前面的环境；map 按键递归合并，数组和其他类型的值整体覆盖，键名以 ForceReplaceSuffix 结尾时整体覆盖
+//
+// profile 为空时只合并 profiles.default；指定的环境在 profiles 中不存在时返回错误，default 可以不存在
+//
+// 配置示例：
+//
+//	database:
+//	  host: localhost
+//	  port: 3306
+//	profiles:
+//	  prod:
+//	    database:
+//	      host: db.prod.internal
+func ApplyProfile(s Storage, profile string) (Storage, error) {
+	return ApplyProfileWithMergeOptions(s, profile, nil)
+}
+
+// ApplyProfileWithMergeOptions 与 ApplyProfile 相同，按 options 指定的策略合并环境配置，options 为 nil 时使用默认策略
+func ApplyProfileWithMergeOptions(s Storage, profile string, options *MergeOptions) (Storage, error) {
+	ms, ok := s.(*MapStorage)
+	if !ok || ms == nil {
+		return nil, fmt.Errorf("profile requires a map storage, got %T", s)
+	}
+	data, ok := ms.UnsafeData().(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("profile requires a map at the root of the config, got %T", ms.UnsafeData())
+	}
+
+	var profiles map[string]interface{}
+	if value, exists := data[ProfilesKey]; exists && value != nil {
+		if profiles, ok = value.(map[string]interface{}); !ok {
+			return nil, fmt.Errorf("%s must be a map, got %T", ProfilesKey, value)
+		}
+	}
+
+	names := []string{DefaultProfile}
+	for _, name := range strings.Split(profile, ",") {
+		if name = strings.TrimSpace(name); name != "" && name != DefaultProfile {
+			if _, exists := profiles[name]; !exists {
+				return nil, fmt.Errorf("profile %q not found in %s", name, ProfilesKey)
+			}
+			names = append(names, name)
+		}
+	}
+
+	base := make(map[string]interface{}, len(data))
+	for key, value := range data {
+		if key != ProfilesKey {
+			base[key] = value
+		}
+	}
+	result := MergeData(base, nil, options)
+	for _, name := range names {
+		override, exists := profiles[name]
+		if !exists || override == nil {
+			continue
+		}
+		overrideMap, ok := override.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s.%s must be a map, got %T", ProfilesKey, name, override)
+		}
+		result = MergeData(result, overrideMap, options)
+	}
+
+	applied := NewMapStorage(result)
+	applied.enableDefaults = ms.enableDefaults
+	applied.redactPaths = ms.redactPaths
+	return applied, nil
+}
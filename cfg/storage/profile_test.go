@@ -0,0 +1,118 @@
+package storage
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestApplyProfile(t *testing.T) {
+	Convey("ApplyProfile 测试", t, func() {
+		data := map[string]interface{}{
+			"database": map[string]interface{}{
+				"host": "localhost",
+				"port": 3306,
+			},
+			"servers": []interface{}{"web1", "web2"},
+			"profiles": map[string]interface{}{
+				"default": map[string]interface{}{
+					"database": map[string]interface{}{"user": "app"},
+				},
+				"prod": map[string]interface{}{
+					"database": map[string]interface{}{"host": "db.prod"},
+					"servers":  []interface{}{"prod1"},
+				},
+				"canary": map[string]interface{}{
+					"database": map[string]interface{}{"host": "db.canary"},
+				},
+				"empty": nil,
+			},
+		}
+
+		Convey("合并 default 和指定环境，数组整体覆盖", func() {
+			s, err := ApplyProfile(NewMapStorage(data), "prod")
+			So(err, ShouldBeNil)
+			So(s.(*MapStorage).Data(), ShouldResemble, map[string]interface{}{
+				"database": map[string]interface{}{"host": "db.prod", "port": 3306, "user": "app"},
+				"servers":  []interface{}{"prod1"},
+			})
+
+			// 原始数据不变
+			So(data["database"], ShouldResemble, map[string]interface{}{"host": "localhost", "port": 3306})
+			So(data, ShouldContainKey, "profiles")
+		})
+
+		Convey("多个环境按顺序覆盖", func() {
+			s, err := ApplyProfile(NewMapStorage(data), "prod, canary")
+			So(err, ShouldBeNil)
+			var host string
+			So(s.Sub("database.host").ConvertTo(&host), ShouldBeNil)
+			So(host, ShouldEqual, "db.canary")
+		})
+
+		Convey("profile 为空时只合并 default", func() {
+			s, err := ApplyProfile(NewMapStorage(data), "")
+			So(err, ShouldBeNil)
+			So(s.(*MapStorage).Data(), ShouldResemble, map[string]interface{}{
+				"database": map[string]interface{}{"host": "localhost", "port": 3306, "user": "app"},
+				"servers":  []interface{}{"web1", "web2"},
+			})
+
+			s, err = ApplyProfile(NewMapStorage(data), "empty")
+			So(err, ShouldBeNil)
+			So(s.Sub("database.user"), ShouldNotBeNil)
+		})
+
+		Convey("没有 profiles 段时返回顶层配置", func() {
+			s, err := ApplyProfile(NewMapStorage(map[string]interface{}{"name": "app"}), DefaultProfile)
+			So(err, ShouldBeNil)
+			So(s.(*MapStorage).Data(), ShouldResemble, map[string]interface{}{"name": "app"})
+		})
+
+		Convey("按合并选项追加数组，键名以 ! 结尾时整体覆盖", func() {
+			s, err := ApplyProfileWithMergeOptions(NewMapStorage(data), "prod", &MergeOptions{Slice: SliceMergeAppend})
+			So(err, ShouldBeNil)
+			So(s.(*MapStorage).Data().(map[string]interface{})["servers"], ShouldResemble, []interface{}{"web1", "web2", "prod1"})
+
+			s, err = ApplyProfile(NewMapStorage(map[string]interface{}{
+				"database": map[string]interface{}{"host": "localhost", "port": 3306},
+				"profiles": map[string]interface{}{
+					"prod": map[string]interface{}{"database!": map[string]interface{}{"dsn": "prod"}},
+				},
+			}), "prod")
+			So(err, ShouldBeNil)
+			So(s.(*MapStorage).Data(), ShouldResemble, map[string]interface{}{
+				"database": map[string]interface{}{"dsn": "prod"},
+			})
+		})
+
+		Convey("脱敏路径保留到合并之后的存储", func() {
+			s, err := ApplyProfile(NewMapStorage(data).Redact("database.host"), "prod")
+			So(err, ShouldBeNil)
+			redacted := s.(*MapStorage).Data().(map[string]interface{})
+			So(redacted["database"].(map[string]interface{})["host"], ShouldEqual, RedactedValue)
+			raw := s.(*MapStorage).UnsafeData().(map[string]interface{})
+			So(raw["database"].(map[string]interface{})["host"], ShouldNotEqual, RedactedValue)
+		})
+
+		Convey("错误处理", func() {
+			_, err := ApplyProfile(NewMapStorage(data), "staging")
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, `"staging"`)
+
+			_, err = ApplyProfile(NewMapStorage(map[string]interface{}{"profiles": "prod"}), "prod")
+			So(err, ShouldNotBeNil)
+
+			_, err = ApplyProfile(NewMapStorage(map[string]interface{}{
+				"profiles": map[string]interface{}{"prod": []interface{}{1}},
+			}), "prod")
+			So(err, ShouldNotBeNil)
+
+			_, err = ApplyProfile(NewMapStorage([]interface{}{1}), "prod")
+			So(err, ShouldNotBeNil)
+
+			_, err = ApplyProfile(NewFlatStorage(map[string]interface{}{"a": 1}), "prod")
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"strconv"
+	"strings"
+)
+
+// RedactedValue 脱敏后的值
+const RedactedValue = "******"
+
+// matchRedactSegment 判断脱敏路径的一段是否匹配 key 的一段
+func matchRedactSegment(pattern, segment string, ignoreCase bool) bool {
+	if pattern == Wildcard {
+		return true
+	}
+	if ignoreCase {
+		return strings.EqualFold(pattern, segment)
+	}
+	return pattern == segment
+}
+
+// isRedacted 判断 key 是否等于某个脱敏路径或位于其下
+func isRedacted(paths [][]string, key []string, ignoreCase bool) bool {
+	for _, path := range paths {
+		if len(path) > len(key) {
+			continue
+		}
+		matched := true
+		for i, segment := range path {
+			if !matchRedactSegment(segment, key[i], ignoreCase) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// subRedactPaths 计算子配置 key 下的脱敏路径
+// key 本身位于脱敏路径下时返回一个空路径，表示整个子配置都需要脱敏
+func subRedactPaths(paths [][]string, key []string) [][]string {
+	if isRedacted(paths, key, false) {
+		return [][]string{{}}
+	}
+
+	var result [][]string
+	for _, path := range paths {
+		if len(path) <= len(key) {
+			continue
+		}
+		matched := true
+		for i, segment := range key {
+			if !matchRedactSegment(path[i], segment, false) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			result = append(result, path[len(key):])
+		}
+	}
+	return result
+}
+
+// redactValue 返回将 path 指向的值替换为 RedactedValue 后的数据，不修改原数据
+// 路径经过的 map 和 slice 会被复制，不存在的路径不做处理
+func redactValue(data interface{}, path []string) interface{} {
+	if len(path) == 0 {
+		return RedactedValue
+	}
+
+	switch v := data.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			if matchRedactSegment(path[0], key, false) {
+				value = redactValue(value, path[1:])
+			}
+			result[key] = value
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, value := range v {
+			if matchRedactSegment(path[0], strconv.Itoa(i), false) {
+				value = redactValue(value, path[1:])
+			}
+			result[i] = value
+		}
+		return result
+	}
+	return data
+}
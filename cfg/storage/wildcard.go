@@ -0,0 +1,351 @@
+package storage
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Wildcard 路径中匹配任意 map 键或数组下标的段，如 "services[*].name"、"endpoints.*.url"
+const Wildcard = "*"
+
+// Matcher 支持通配路径的存储，Collect 据此将通配路径展开为具体路径
+type Matcher interface {
+	// Match 返回 path 匹配的所有具体路径，数组按下标顺序，map 按键的字典序
+	// path 不包含通配符时，配置存在则返回 path 本身
+	Match(path string) ([]string, error)
+}
+
+// Collect 返回 path 匹配的所有子配置，如 Collect(storage, "writers[*]") 返回每个 writer 的配置，
+// 用于按配置的数量创建组件；没有匹配时返回空切片
+//
+// storage 未实现 Matcher 时只支持不含通配符的路径
+func Collect(storage Storage, path string) ([]Storage, error) {
+	if storage == nil {
+		return nil, nil
+	}
+	matcher, ok := storage.(Matcher)
+	if !ok {
+		if strings.Contains(path, Wildcard) {
+			return nil, fmt.Errorf("storage %T does not support wildcard path %q", storage, path)
+		}
+		if sub := storage.Sub(path); !isNilStorage(sub) {
+			return []Storage{sub}, nil
+		}
+		return nil, nil
+	}
+
+	paths, err := matcher.Match(path)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]Storage, 0, len(paths))
+	for _, p := range paths {
+		result = append(result, storage.Sub(p))
+	}
+	return result, nil
+}
+
+// hasWildcard 判断解析后的路径是否包含通配段
+func hasWildcard(keys []string) bool {
+	for _, key := range keys {
+		if key == Wildcard {
+			return true
+		}
+	}
+	return false
+}
+
+// formatPath 将路径段格式化为 Sub 可以解析的路径，数字段使用 [i]，包含 "."、"[" 或 "]" 的键使用 [key]
+func formatPath(keys []string) string {
+	var b strings.Builder
+	for i, key := range keys {
+		_, err := strconv.Atoi(key)
+		switch {
+		case err == nil || strings.ContainsAny(key, ".["):
+			b.WriteString("[" + key + "]")
+		case i > 0:
+			b.WriteString("." + key)
+		default:
+			b.WriteString(key)
+		}
+	}
+	return b.String()
+}
+
+// sortPathSegments 数字按大小排序，其余按字典序，数字在前
+func sortPathSegments(segments []string) {
+	sort.Slice(segments, func(i, j int) bool {
+		a, errA := strconv.Atoi(segments[i])
+		b, errB := strconv.Atoi(segments[j])
+		switch {
+		case errA == nil && errB == nil:
+			return a < b
+		case errA == nil || errB == nil:
+			return errA == nil
+		}
+		return segments[i] < segments[j]
+	})
+}
+
+// isNilStorage 判断 Storage 是否为 nil 或类型化的 nil
+func isNilStorage(storage Storage) bool {
+	if storage == nil {
+		return true
+	}
+	rv := reflect.ValueOf(storage)
+	return rv.Kind() == reflect.Ptr && rv.IsNil()
+}
+
+// Match 返回 path 匹配的所有具体路径，路径上的别名节点解析后继续匹配，别名无法解析时返回错误
+func (ms *MapStorage) Match(path string) ([]string, error) {
+	if ms == nil {
+		return nil, nil
+	}
+	matches, _, err := ms.matchKeys(ms.data, ms.parseKey(path), nil)
+	if err != nil {
+		return nil, err
+	}
+	paths := make([]string, len(matches))
+	for i, keys := range matches {
+		paths[i] = formatPath(keys)
+	}
+	return paths, nil
+}
+
+// Collect 返回 path 匹配的所有子配置，参考 Collect
+func (ms *MapStorage) Collect(path string) ([]Storage, error) {
+	return Collect(ms, path)
+}
+
+// matchKeys 从 node 开始匹配路径，返回匹配的具体路径和对应的值
+// 与 getValue 一致，路径上的别名节点解析为指向的子树，最后一级保留别名节点
+func (ms *MapStorage) matchKeys(node interface{}, keys []string, prefix []string) ([][]string, []interface{}, error) {
+	if len(keys) == 0 {
+		return [][]string{append([]string(nil), prefix...)}, []interface{}{node}, nil
+	}
+
+	resolved, _, err := ms.resolveAlias(node, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var children []string
+	if keys[0] == Wildcard {
+		children = childKeys(resolved)
+	} else {
+		children = []string{keys[0]}
+	}
+
+	var paths [][]string
+	var values []interface{}
+	for _, child := range children {
+		value := ms.getValueByKey(resolved, child)
+		if value == nil {
+			continue
+		}
+		childPaths, childValues, err := ms.matchKeys(value, keys[1:], append(prefix, child))
+		if err != nil {
+			return nil, nil, err
+		}
+		paths = append(paths, childPaths...)
+		values = append(values, childValues...)
+	}
+	return paths, values, nil
+}
+
+// childKeys 返回 map 的所有键或数组的所有下标，其他类型返回 nil
+func childKeys(node interface{}) []string {
+	rv := reflect.ValueOf(node)
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+
+	var keys []string
+	switch rv.Kind() {
+	case reflect.Map:
+		for _, key := range rv.MapKeys() {
+			if key.Kind() == reflect.Interface {
+				key = key.Elem()
+			}
+			if key.Kind() == reflect.String {
+				keys = append(keys, key.String())
+			}
+		}
+		sort.Strings(keys)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			keys = append(keys, strconv.Itoa(i))
+		}
+	}
+	return keys
+}
+
+// subWildcard 通配路径的子配置，匹配的值按 Match 的顺序组成数组，没有匹配时返回 nil MapStorage
+func (ms *MapStorage) subWildcard(keys []string) Storage {
+	matches, values, err := ms.matchKeys(ms.data, keys, nil)
+	if err != nil || len(matches) == 0 {
+		var nilStorage *MapStorage = nil
+		return nilStorage
+	}
+
+	subStorage := NewMapStorage(values)
+	subStorage.root = ms.root
+	subStorage.enableDefaults = ms.enableDefaults
+	// 每个元素继承其具体路径下的脱敏路径
+	for i, match := range matches {
+		for _, path := range subRedactPaths(ms.redactPaths, match) {
+			subStorage.redactPaths = append(subStorage.redactPaths, append([]string{strconv.Itoa(i)}, path...))
+		}
+	}
+	return subStorage
+}
+
+// Match 返回 path 匹配的所有具体路径，通配段匹配扁平键中该位置出现过的所有段
+// 启用大小写转换时按转换后的键匹配，返回的路径可以直接传给 Sub
+func (fs *FlatStorage) Match(path string) ([]string, error) {
+	if fs == nil {
+		return nil, nil
+	}
+	root, keys := fs, fs.parseKey(path)
+	var prefixKeys []string
+	if fs.parent != nil && fs.prefix != "" {
+		prefixKeys = strings.Split(fs.prefix, fs.separator)
+	}
+	if fs.parent != nil {
+		root, keys = fs.parent, append(prefixKeys, keys...)
+	}
+
+	prefixes := [][]string{nil}
+	for _, key := range keys {
+		var next [][]string
+		for _, prefix := range prefixes {
+			if key != Wildcard {
+				next = append(next, append(append([]string(nil), prefix...), key))
+				continue
+			}
+			for _, segment := range root.childSegments(prefix) {
+				next = append(next, append(append([]string(nil), prefix...), segment))
+			}
+		}
+		prefixes = next
+	}
+
+	var paths []string
+	for _, prefix := range prefixes {
+		if !hasFlatKey(root.data, root.applyCase(strings.Join(prefix, root.separator)), root.separator) {
+			continue
+		}
+		// 子配置的路径相对于自身
+		prefix = prefix[len(prefixKeys):]
+		paths = append(paths, formatPath(prefix))
+	}
+	return paths, nil
+}
+
+// Collect 返回 path 匹配的所有子配置，参考 Collect
+func (fs *FlatStorage) Collect(path string) ([]Storage, error) {
+	return Collect(fs, path)
+}
+
+// childSegments 返回扁平键中紧跟在 prefix 之后的所有段
+func (fs *FlatStorage) childSegments(prefix []string) []string {
+	keyPrefix := ""
+	if len(prefix) > 0 {
+		keyPrefix = fs.applyCase(strings.Join(prefix, fs.separator)) + fs.separator
+	}
+
+	seen := map[string]bool{}
+	var segments []string
+	for key := range fs.data {
+		if !strings.HasPrefix(key, keyPrefix) {
+			continue
+		}
+		segment := strings.SplitN(key[len(keyPrefix):], fs.separator, 2)[0]
+		if segment != "" && !seen[segment] {
+			seen[segment] = true
+			segments = append(segments, segment)
+		}
+	}
+	sortPathSegments(segments)
+	return segments
+}
+
+// subWildcard 通配路径的子配置，每个匹配的叶子值或以其为前缀的键组成的 map 按 Match 的顺序组成数组
+func (fs *FlatStorage) subWildcard(path string) Storage {
+	paths, err := fs.Match(path)
+	if err != nil || len(paths) == 0 {
+		var nilStorage *MapStorage = nil
+		return nilStorage
+	}
+
+	values := make([]interface{}, 0, len(paths))
+	for _, p := range paths {
+		sub := fs.Sub(p).(*FlatStorage)
+		if value := sub.get(""); value != nil {
+			values = append(values, value)
+			continue
+		}
+		value := map[string]interface{}{}
+		if err := sub.WithDefaults(false).ConvertTo(&value); err != nil {
+			continue
+		}
+		values = append(values, value)
+	}
+	return NewMapStorage(values)
+}
+
+// Match 合并所有存储源匹配的路径，按存储源的顺序去重
+func (ms *multiStorage) Match(path string) ([]string, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	sources, err := ms.layers()
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	var paths []string
+	for i, source := range sources {
+		if isNilStorage(source) {
+			continue
+		}
+		matcher, ok := source.(Matcher)
+		if !ok {
+			return nil, fmt.Errorf("source %d of type %T does not support wildcard path", i, source)
+		}
+		sourcePaths, err := matcher.Match(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to match source %d: %w", i, err)
+		}
+		for _, p := range sourcePaths {
+			if !seen[p] {
+				seen[p] = true
+				paths = append(paths, p)
+			}
+		}
+	}
+	return paths, nil
+}
+
+// Collect 返回 path 匹配的所有子配置，参考 Collect
+func (ms *multiStorage) Collect(path string) ([]Storage, error) {
+	return Collect(ms, path)
+}
+
+// Match 返回内部存储匹配的路径
+func (vs *ValidateStorage) Match(path string) ([]string, error) {
+	if vs.storage == nil {
+		return nil, nil
+	}
+	if matcher, ok := vs.storage.(Matcher); ok {
+		return matcher.Match(path)
+	}
+	return nil, fmt.Errorf("storage %T does not support wildcard path", vs.storage)
+}
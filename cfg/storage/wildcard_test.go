@@ -0,0 +1,164 @@
+package storage
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMapStorage_Wildcard(t *testing.T) {
+	data := map[string]interface{}{
+		"services": []interface{}{
+			map[string]interface{}{"name": "user", "endpoint": "http://user", "token": "t1"},
+			map[string]interface{}{"name": "order", "endpoint": "http://order", "token": "t2"},
+			map[string]interface{}{"endpoint": "http://anonymous"},
+		},
+		"endpoints": map[string]interface{}{
+			"b":   map[string]interface{}{"url": "http://b"},
+			"a":   map[string]interface{}{"url": "http://a"},
+			"c.d": map[string]interface{}{"url": "http://cd"},
+		},
+		"shared":  map[string]interface{}{"name": "shared"},
+		"aliased": []interface{}{map[string]interface{}{"alias": "#/shared"}},
+		"broken":  []interface{}{map[string]interface{}{"alias": "#/missing"}},
+	}
+
+	Convey("测试通配路径", t, func() {
+		storage := NewMapStorage(data)
+
+		Convey("Sub 返回所有匹配值组成的数组", func() {
+			var names []string
+			So(storage.Sub("services[*].name").ConvertTo(&names), ShouldBeNil)
+			So(names, ShouldResemble, []string{"user", "order"})
+
+			var urls []string
+			So(storage.Sub("endpoints.*.url").ConvertTo(&urls), ShouldBeNil)
+			So(urls, ShouldResemble, []string{"http://a", "http://b", "http://cd"})
+
+			type Service struct {
+				Name     string `cfg:"name" def:"default"`
+				Endpoint string `cfg:"endpoint"`
+			}
+			var services []Service
+			So(storage.Sub("services[*]").ConvertTo(&services), ShouldBeNil)
+			So(services, ShouldHaveLength, 3)
+			So(services[2], ShouldResemble, Service{Name: "default", Endpoint: "http://anonymous"})
+
+			// 没有匹配时与不存在的 key 一样返回 nil
+			So(storage.Sub("services[*].missing"), ShouldEqual, (*MapStorage)(nil))
+		})
+
+		Convey("Match 和 Collect", func() {
+			paths, err := storage.Match("endpoints.*")
+			So(err, ShouldBeNil)
+			So(paths, ShouldResemble, []string{"endpoints.a", "endpoints.b", "endpoints[c.d]"})
+
+			paths, err = storage.Match("services[*].name")
+			So(err, ShouldBeNil)
+			So(paths, ShouldResemble, []string{"services[0].name", "services[1].name"})
+
+			subs, err := storage.Collect("endpoints.*")
+			So(err, ShouldBeNil)
+			So(subs, ShouldHaveLength, 3)
+			var url string
+			So(subs[2].Sub("url").ConvertTo(&url), ShouldBeNil)
+			So(url, ShouldEqual, "http://cd")
+
+			subs, err = Collect(storage.Sub("services"), "[*]")
+			So(err, ShouldBeNil)
+			So(subs, ShouldHaveLength, 3)
+
+			subs, err = storage.Collect("missing[*]")
+			So(err, ShouldBeNil)
+			So(subs, ShouldBeEmpty)
+		})
+
+		Convey("路径上的别名解析后继续匹配", func() {
+			var names []string
+			So(storage.Sub("aliased[*].name").ConvertTo(&names), ShouldBeNil)
+			So(names, ShouldResemble, []string{"shared"})
+
+			_, err := storage.Match("broken[*].name")
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("子配置继承脱敏路径", func() {
+			redacted := NewMapStorage(data).Redact("services[*].token")
+			sub := redacted.Sub("services[*]").(*MapStorage)
+			items := sub.Data().([]interface{})
+			So(items[0].(map[string]interface{})["token"], ShouldEqual, RedactedValue)
+			So(items[1].(map[string]interface{})["token"], ShouldEqual, RedactedValue)
+			So(items[0].(map[string]interface{})["name"], ShouldEqual, "user")
+		})
+	})
+}
+
+func TestFlatStorage_Wildcard(t *testing.T) {
+	Convey("测试扁平存储的通配路径", t, func() {
+		storage := NewFlatStorage(map[string]interface{}{
+			"SERVERS_0_HOST":  "server1",
+			"SERVERS_0_PORT":  8080,
+			"SERVERS_1_HOST":  "server2",
+			"SERVERS_10_HOST": "server11",
+			"NAME":            "app",
+		}).WithSeparator("_").WithUppercase(true)
+
+		var hosts []string
+		So(storage.Sub("servers[*].host").ConvertTo(&hosts), ShouldBeNil)
+		So(hosts, ShouldResemble, []string{"server1", "server2", "server11"})
+
+		paths, err := storage.Match("servers[*]")
+		So(err, ShouldBeNil)
+		So(paths, ShouldResemble, []string{"servers[0]", "servers[1]", "servers[10]"})
+
+		type Server struct {
+			Host string `cfg:"host"`
+			Port int    `cfg:"port" def:"80"`
+		}
+		subs, err := storage.Collect("servers[*]")
+		So(err, ShouldBeNil)
+		So(subs, ShouldHaveLength, 3)
+		var server Server
+		So(subs[0].ConvertTo(&server), ShouldBeNil)
+		So(server, ShouldResemble, Server{Host: "server1", Port: 8080})
+
+		// 子配置中的通配路径
+		subs, err = Collect(storage.Sub("servers"), "*")
+		So(err, ShouldBeNil)
+		So(subs, ShouldHaveLength, 3)
+	})
+}
+
+func TestMultiStorage_Wildcard(t *testing.T) {
+	Convey("测试多配置存储的通配路径", t, func() {
+		storage := NewMultiStorage([]Storage{
+			NewMapStorage(map[string]interface{}{
+				"writers": []interface{}{
+					map[string]interface{}{"type": "file"},
+				},
+			}),
+			nil,
+			NewMapStorage(map[string]interface{}{
+				"writers": []interface{}{
+					map[string]interface{}{"type": "console"},
+					map[string]interface{}{"type": "kafka"},
+				},
+			}),
+		})
+
+		paths, err := storage.(Matcher).Match("writers[*]")
+		So(err, ShouldBeNil)
+		So(paths, ShouldResemble, []string{"writers[0]", "writers[1]"})
+
+		subs, err := Collect(storage, "writers[*].type")
+		So(err, ShouldBeNil)
+		So(subs, ShouldHaveLength, 2)
+		var kind string
+		So(subs[0].ConvertTo(&kind), ShouldBeNil)
+		So(kind, ShouldEqual, "console")
+
+		subs, err = Collect(NewValidateStorage(NewMapStorage(map[string]interface{}{"a": []interface{}{1, 2}})), "a[*]")
+		So(err, ShouldBeNil)
+		So(subs, ShouldHaveLength, 2)
+	})
+}
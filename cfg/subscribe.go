@@ -0,0 +1,61 @@
+package cfg
+
+import (
+	"sync"
+
+	"github.com/hatlonely/gox/cfg/storage"
+)
+
+// Subscribe 订阅 key 对应的配置变更，返回接收变更之后子配置的通道以及取消订阅的函数，key 为空时订阅整个配置
+// 与 OnKeyChange 回调相比，适合在 select 循环中与其他事件一起处理配置变更
+//
+// 通道只缓存最新的一次变更，消费者来不及处理时旧的变更被新的变更替换，不会阻塞变更回调；
+// 与 OnChange 一样需要调用 Watch 之后才会收到变更。cancel 之后不再发送变更并关闭通道，可以多次调用；
+// 配置关闭时通道不会关闭，需要调用 cancel
+//
+//	ch, cancel := cfg.Subscribe(config, "database")
+//	defer cancel()
+//	for {
+//		select {
+//		case s := <-ch:
+//			// 使用新的数据库配置
+//		case <-ctx.Done():
+//			return
+//		}
+//	}
+func Subscribe(config Config, key string) (<-chan storage.Storage, func()) {
+	sub := &subscription{ch: make(chan storage.Storage, 1)}
+	config.Sub(key).OnChange(sub.publish)
+	return sub.ch, sub.cancel
+}
+
+// subscription 一个订阅的通道，取消之后注册的回调不再发送变更
+type subscription struct {
+	mu       sync.Mutex
+	ch       chan storage.Storage
+	canceled bool
+}
+
+// publish 发送最新的配置，通道中有未读取的旧配置时先丢弃
+func (s *subscription) publish(value storage.Storage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.canceled {
+		return nil
+	}
+	select {
+	case <-s.ch:
+	default:
+	}
+	s.ch <- value
+	return nil
+}
+
+func (s *subscription) cancel() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.canceled {
+		s.canceled = true
+		close(s.ch)
+	}
+}
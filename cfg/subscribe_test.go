@@ -0,0 +1,91 @@
+package cfg
+
+import (
+	"testing"
+
+	"github.com/hatlonely/gox/cfg/storage"
+)
+
+func TestSubscribe(t *testing.T) {
+	config := newGetterTestConfig(t, "config.json", `{"database": {"host": "db", "port": 3306}, "mode": "a"}`, jsonDecoderOptions)
+	config.handlerExecution.Async = false
+
+	databaseCh, cancelDatabase := Subscribe(config, "database")
+	defer cancelDatabase()
+	rootCh, cancelRoot := Subscribe(config, "")
+	defer cancelRoot()
+
+	port := func(s storage.Storage) int {
+		var port int
+		if err := s.Sub("port").ConvertTo(&port); err != nil {
+			t.Fatalf("ConvertTo() error = %v", err)
+		}
+		return port
+	}
+
+	// 未读取的变更被最新的变更替换
+	for _, data := range []string{
+		`{"database": {"host": "db", "port": 3307}, "mode": "a"}`,
+		`{"database": {"host": "db", "port": 3308}, "mode": "a"}`,
+	} {
+		if err := config.handleProviderChange([]byte(data)); err != nil {
+			t.Fatalf("handleProviderChange() error = %v", err)
+		}
+	}
+	select {
+	case s := <-databaseCh:
+		if got := port(s); got != 3308 {
+			t.Errorf("expected latest port 3308, got %d", got)
+		}
+	default:
+		t.Fatal("expected database change")
+	}
+	select {
+	case s := <-databaseCh:
+		t.Fatalf("expected only the latest change, got %v", s)
+	default:
+	}
+	select {
+	case s := <-rootCh:
+		if got := port(s.Sub("database")); got != 3308 {
+			t.Errorf("expected root storage with port 3308, got %d", got)
+		}
+	default:
+		t.Fatal("expected root change")
+	}
+
+	// 其他键的变更不会发送到 database 的通道
+	if err := config.handleProviderChange([]byte(`{"database": {"host": "db", "port": 3308}, "mode": "b"}`)); err != nil {
+		t.Fatalf("handleProviderChange() error = %v", err)
+	}
+	select {
+	case s := <-databaseCh:
+		t.Fatalf("unexpected database change %v", s)
+	default:
+	}
+	<-rootCh
+
+	// 取消之后关闭通道，不再发送变更
+	cancelDatabase()
+	cancelDatabase()
+	if err := config.handleProviderChange([]byte(`{"database": {"host": "db", "port": 3309}, "mode": "b"}`)); err != nil {
+		t.Fatalf("handleProviderChange() error = %v", err)
+	}
+	if s, ok := <-databaseCh; ok {
+		t.Errorf("expected closed channel, got %v", s)
+	}
+	if _, ok := <-rootCh; !ok {
+		t.Error("expected root subscription to keep receiving changes")
+	}
+
+	// 子配置上的订阅相对于子配置
+	subCh, cancelSub := Subscribe(config.Sub("database"), "port")
+	defer cancelSub()
+	if err := config.handleProviderChange([]byte(`{"database": {"host": "db", "port": 3310}, "mode": "b"}`)); err != nil {
+		t.Fatalf("handleProviderChange() error = %v", err)
+	}
+	var got int
+	if err := (<-subCh).ConvertTo(&got); err != nil || got != 3310 {
+		t.Errorf("expected port 3310, got %d, err %v", got, err)
+	}
+}
@@ -0,0 +1,85 @@
+package cfg
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/hatlonely/gox/cfg/storage"
+	"github.com/hatlonely/gox/cfg/validator"
+	"github.com/hatlonely/gox/log/logger"
+)
+
+// ValidationError 配置变更未通过 OnValidate 注册的校验，变更被拒绝，继续使用原来的配置
+type ValidationError struct {
+	// Source 发生变更的配置源索引，SingleConfig 固定为 0
+	Source int
+	// Key 未通过校验的配置路径，相对于根配置，空字符串表示根配置
+	Key string
+	Err error
+}
+
+func (e *ValidationError) Error() string {
+	key := e.Key
+	if key == "" {
+		key = "<root>"
+	}
+	return fmt.Sprintf("config change rejected: %s: %v", key, e.Err)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// ValidateAs 返回按结构体校验配置的函数，用于 OnValidate
+// 每次校验将配置转换成 object 类型的新实例，转换失败或者不满足 validate 标签的规则时返回错误
+func ValidateAs(object any) func(storage.Storage) error {
+	typ := reflect.TypeOf(object)
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	return func(s storage.Storage) error {
+		target := reflect.New(typ).Interface()
+		if err := s.ConvertTo(target); err != nil {
+			return err
+		}
+		return validator.ValidateStruct(target)
+	}
+}
+
+// configValidator OnValidate 注册的校验函数
+type configValidator struct {
+	key string // 相对于根配置的路径
+	fn  func(storage.Storage) error
+}
+
+// validateChange 依次执行校验函数，返回第一个未通过的校验
+func validateChange(validators []configValidator, source int, newStorage storage.Storage) *ValidationError {
+	for _, v := range validators {
+		if err := v.fn(newStorage.Sub(v.key)); err != nil {
+			return &ValidationError{Source: source, Key: v.key, Err: err}
+		}
+	}
+	return nil
+}
+
+// rejectChange 记录被拒绝的配置变更
+func rejectChange(l logger.Logger, status *statusTracker, err *ValidationError) {
+	status.recordError(err.Source, err)
+	if l != nil {
+		l.Error("config change rejected",
+			"source", err.Source,
+			"key", err.Key,
+			"error", err.Err)
+	}
+}
+
+// joinKey 拼接子配置前缀和相对路径
+func joinKey(prefix string, key string) string {
+	if prefix == "" {
+		return key
+	}
+	if key == "" {
+		return prefix
+	}
+	return prefix + "." + key
+}
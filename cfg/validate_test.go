@@ -0,0 +1,126 @@
+package cfg
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hatlonely/gox/cfg/storage"
+)
+
+type validateTestDatabase struct {
+	Host string `cfg:"host" validate:"required"`
+	Port int    `cfg:"port" validate:"min=1,max=65535"`
+}
+
+func TestSingleConfig_OnValidate(t *testing.T) {
+	config := newGetterTestConfig(t, "config.json", `{"database": {"host": "db", "port": 3306}, "mode": "a"}`, jsonDecoderOptions)
+
+	var changes int
+	config.OnChange(func(storage.Storage) error {
+		changes++
+		return nil
+	})
+	config.handlerExecution.Async = false
+
+	config.OnValidate("database", ValidateAs(&validateTestDatabase{}))
+	// 子配置注册的校验使用相对路径
+	config.Sub("database").OnValidate("host", func(s storage.Storage) error {
+		var host string
+		if err := s.ConvertTo(&host); err != nil {
+			return err
+		}
+		if host == "localhost" {
+			return errors.New("localhost is not allowed")
+		}
+		return nil
+	})
+
+	// 结构体校验未通过
+	err := config.handleProviderChange([]byte(`{"database": {"host": "db", "port": 0}, "mode": "b"}`))
+	var verr *ValidationError
+	if !errors.As(err, &verr) || verr.Key != "database" {
+		t.Fatalf("expected validation error for database, got %v", err)
+	}
+	if got := GetString(config, "mode", ""); got != "a" {
+		t.Errorf("expected old config to be kept, got mode %q", got)
+	}
+	if changes != 0 {
+		t.Errorf("expected no change handler for rejected change, got %d", changes)
+	}
+	if status := config.Status(); status.Healthy() || !errors.As(status.Sources[0].LastError, &verr) {
+		t.Errorf("expected rejected change in status, got %+v", status)
+	}
+
+	// 转换失败同样被拒绝
+	if err := config.handleProviderChange([]byte(`{"database": {"host": "db", "port": "abc"}}`)); !errors.As(err, &verr) {
+		t.Fatalf("expected validation error for conversion failure, got %v", err)
+	}
+
+	// 自定义校验未通过
+	if err := config.handleProviderChange([]byte(`{"database": {"host": "localhost", "port": 3306}}`)); !errors.As(err, &verr) || verr.Key != "database.host" {
+		t.Fatalf("expected validation error for database.host, got %v", err)
+	}
+
+	// 校验通过后生效
+	if err := config.handleProviderChange([]byte(`{"database": {"host": "db2", "port": 3307}, "mode": "c"}`)); err != nil {
+		t.Fatalf("handleProviderChange() error = %v", err)
+	}
+	if got := GetString(config, "database.host", ""); got != "db2" {
+		t.Errorf("expected new config, got host %q", got)
+	}
+	if changes != 1 || !config.Status().Healthy() {
+		t.Errorf("expected 1 change and healthy status, got %d, %+v", changes, config.Status())
+	}
+}
+
+func TestMultiConfig_OnValidate(t *testing.T) {
+	tempDir := t.TempDir()
+	baseFile := filepath.Join(tempDir, "base.json")
+	overrideFile := filepath.Join(tempDir, "override.json")
+	if err := os.WriteFile(baseFile, []byte(`{"database": {"host": "db", "port": 3306}}`), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	if err := os.WriteFile(overrideFile, []byte(`{}`), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	baseSource, err := createFileSourceOptions(baseFile)
+	if err != nil {
+		t.Fatalf("Failed to create file source options: %v", err)
+	}
+	overrideSource, err := createFileSourceOptions(overrideFile)
+	if err != nil {
+		t.Fatalf("Failed to create file source options: %v", err)
+	}
+
+	config, err := NewMultiConfigWithOptions(&MultiConfigOptions{
+		Sources: []*ConfigSourceOptions{baseSource, overrideSource},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create config: %v", err)
+	}
+	defer config.Close()
+
+	config.OnValidate("database", ValidateAs(validateTestDatabase{}))
+
+	// 校验的是合并之后的配置
+	err = config.handleSourceChange(1, []byte(`{"database": {"port": 70000}}`))
+	var verr *ValidationError
+	if !errors.As(err, &verr) || verr.Source != 1 {
+		t.Fatalf("expected validation error from source 1, got %v", err)
+	}
+	if got := GetInt(config, "database.port", 0); got != 3306 {
+		t.Errorf("expected old config to be kept, got port %d", got)
+	}
+	if config.Status().Sources[1].LastError == nil {
+		t.Error("expected rejected change recorded for source 1")
+	}
+
+	if err := config.handleSourceChange(1, []byte(`{"database": {"port": 3307}}`)); err != nil {
+		t.Fatalf("handleSourceChange() error = %v", err)
+	}
+	if got := GetInt(config, "database.port", 0); got != 3307 {
+		t.Errorf("expected new config, got port %d", got)
+	}
+}
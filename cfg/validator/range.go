@@ -0,0 +1,180 @@
+package validator
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// 范围校验标签
+//
+//	durmin=1s        时长不小于 1s，字段类型为 time.Duration 或时长字符串
+//	durmax=1h        时长不大于 1h
+//	between=1~100    数值在 [1, 100] 内，time.Duration 字段的边界写作时长，如 between=100ms~5s
+//
+// 由于 validator 使用逗号分隔规则，between 的上下界使用 ~ 分隔
+const (
+	tagDurMin  = "durmin"
+	tagDurMax  = "durmax"
+	tagBetween = "between"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// newValidate 创建注册了自定义标签的 validator
+func newValidate() *validator.Validate {
+	validate := validator.New()
+	validate.RegisterValidation(tagDurMin, validateDurMin)
+	validate.RegisterValidation(tagDurMax, validateDurMax)
+	validate.RegisterValidation(tagBetween, validateBetween)
+	return validate
+}
+
+func validateDurMin(fl validator.FieldLevel) bool {
+	d, ok := durationValue(fl.Field())
+	return ok && d >= mustParseDuration(fl.Param())
+}
+
+func validateDurMax(fl validator.FieldLevel) bool {
+	d, ok := durationValue(fl.Field())
+	return ok && d <= mustParseDuration(fl.Param())
+}
+
+func validateBetween(fl validator.FieldLevel) bool {
+	field := fl.Field()
+	low, high := splitBetween(fl.Param())
+
+	if field.Type() == durationType || field.Kind() == reflect.String {
+		d, ok := durationValue(field)
+		return ok && d >= mustParseDuration(low) && d <= mustParseDuration(high)
+	}
+
+	switch field.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return field.Int() >= mustParseInt(low) && field.Int() <= mustParseInt(high)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return field.Uint() >= mustParseUint(low) && field.Uint() <= mustParseUint(high)
+	case reflect.Float32, reflect.Float64:
+		return field.Float() >= mustParseFloat(low) && field.Float() <= mustParseFloat(high)
+	}
+	// 与 validator 内置标签一致，标签用在不支持的类型上属于编码错误
+	panic(fmt.Sprintf("Bad field type %T for tag %s", field.Interface(), tagBetween))
+}
+
+// durationValue 读取 time.Duration 字段或时长字符串字段的值
+func durationValue(field reflect.Value) (time.Duration, bool) {
+	if field.Type() == durationType {
+		return time.Duration(field.Int()), true
+	}
+	if field.Kind() == reflect.String {
+		d, err := time.ParseDuration(field.String())
+		return d, err == nil
+	}
+	panic(fmt.Sprintf("Bad field type %T for duration tag", field.Interface()))
+}
+
+func splitBetween(param string) (string, string) {
+	low, high, ok := strings.Cut(param, "~")
+	if !ok {
+		panic(fmt.Sprintf("invalid between param %q, expected low~high", param))
+	}
+	return strings.TrimSpace(low), strings.TrimSpace(high)
+}
+
+func mustParseDuration(param string) time.Duration {
+	d, err := time.ParseDuration(param)
+	if err != nil {
+		panic(fmt.Sprintf("invalid duration param %q: %v", param, err))
+	}
+	return d
+}
+
+func mustParseInt(param string) int64 {
+	i, err := strconv.ParseInt(param, 10, 64)
+	if err != nil {
+		panic(fmt.Sprintf("invalid int param %q: %v", param, err))
+	}
+	return i
+}
+
+func mustParseUint(param string) uint64 {
+	i, err := strconv.ParseUint(param, 10, 64)
+	if err != nil {
+		panic(fmt.Sprintf("invalid uint param %q: %v", param, err))
+	}
+	return i
+}
+
+func mustParseFloat(param string) float64 {
+	f, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		panic(fmt.Sprintf("invalid float param %q: %v", param, err))
+	}
+	return f
+}
+
+// validationError 为范围校验标签生成可读的错误信息，其他标签保留 validator 的原始信息
+// 通过 errors.As 仍然可以取到 validator.ValidationErrors
+type validationError struct {
+	errs validator.ValidationErrors
+}
+
+func wrapValidationError(err error) error {
+	var errs validator.ValidationErrors
+	if !errors.As(err, &errs) {
+		return err
+	}
+	return &validationError{errs: errs}
+}
+
+func (e *validationError) Error() string {
+	messages := make([]string, 0, len(e.errs))
+	for _, fe := range e.errs {
+		messages = append(messages, formatFieldError(fe))
+	}
+	return strings.Join(messages, "\n")
+}
+
+func (e *validationError) Unwrap() error {
+	return e.errs
+}
+
+func formatFieldError(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case tagDurMin, tagDurMax, tagBetween:
+		return fe.Namespace() + ": " + Describe(fe)
+	}
+	return fe.Error()
+}
+
+// Describe 返回字段未通过校验的原因，不包含字段路径，用于按配置路径输出校验结果
+func Describe(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case tagDurMin:
+		return fmt.Sprintf("must be at least %s, got %s", fe.Param(), formatValue(fe.Value()))
+	case tagDurMax:
+		return fmt.Sprintf("must be at most %s, got %s", fe.Param(), formatValue(fe.Value()))
+	case tagBetween:
+		low, high, _ := strings.Cut(fe.Param(), "~")
+		return fmt.Sprintf("must be between %s and %s, got %s", strings.TrimSpace(low), strings.TrimSpace(high), formatValue(fe.Value()))
+	case "required":
+		return "is required"
+	}
+	rule := fe.Tag()
+	if fe.Param() != "" {
+		rule += "=" + fe.Param()
+	}
+	return fmt.Sprintf("must satisfy %s, got %s", rule, formatValue(fe.Value()))
+}
+
+func formatValue(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return strconv.Quote(s)
+	}
+	return fmt.Sprintf("%v", value)
+}
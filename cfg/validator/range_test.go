@@ -0,0 +1,90 @@
+package validator
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRangeValidation(t *testing.T) {
+	Convey("范围校验标签测试", t, func() {
+		type Config struct {
+			Timeout  time.Duration `validate:"durmin=100ms,durmax=30s"`
+			Interval string        `validate:"omitempty,durmin=1s"`
+			Retry    int           `validate:"between=0~10"`
+			Port     uint16        `validate:"between=1024~65535"`
+			Ratio    float64       `validate:"between=0~1"`
+			TTL      time.Duration `validate:"between=1m~24h"`
+		}
+		valid := func() Config {
+			return Config{Timeout: time.Second, Retry: 3, Port: 8080, Ratio: 0.5, TTL: time.Hour}
+		}
+
+		Convey("范围内的值校验通过", func() {
+			config := valid()
+			config.Interval = "5s"
+			So(ValidateStruct(&config), ShouldBeNil)
+		})
+
+		Convey("边界值包含在范围内", func() {
+			config := Config{Timeout: 100 * time.Millisecond, Retry: 10, Port: 1024, Ratio: 1, TTL: time.Minute}
+			So(ValidateStruct(&config), ShouldBeNil)
+		})
+
+		Convey("时长小于下限", func() {
+			config := valid()
+			config.Timeout = 50 * time.Millisecond
+			err := ValidateStruct(&config)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldEqual, "Config.Timeout: must be at least 100ms, got 50ms")
+		})
+
+		Convey("时长大于上限", func() {
+			config := valid()
+			config.Timeout = time.Minute
+			So(ValidateStruct(&config).Error(), ShouldEqual, "Config.Timeout: must be at most 30s, got 1m0s")
+		})
+
+		Convey("时长字符串", func() {
+			config := valid()
+			config.Interval = "500ms"
+			So(ValidateStruct(&config).Error(), ShouldEqual, `Config.Interval: must be at least 1s, got "500ms"`)
+
+			config.Interval = "abc"
+			So(ValidateStruct(&config), ShouldNotBeNil)
+		})
+
+		Convey("数值超出范围，多个错误逐行输出", func() {
+			config := valid()
+			config.Retry = 11
+			config.Port = 80
+			config.Ratio = 1.5
+			config.TTL = time.Second
+			So(ValidateStruct(&config).Error(), ShouldEqual, "Config.Retry: must be between 0 and 10, got 11\n"+
+				"Config.Port: must be between 1024 and 65535, got 80\n"+
+				"Config.Ratio: must be between 0 and 1, got 1.5\n"+
+				"Config.TTL: must be between 1m and 24h, got 1s")
+		})
+
+		Convey("可以取到原始的 ValidationErrors", func() {
+			config := valid()
+			config.Retry = -1
+			var errs validator.ValidationErrors
+			So(errors.As(ValidateStruct(&config), &errs), ShouldBeTrue)
+			So(errs[0].Tag(), ShouldEqual, "between")
+			So(errs[0].Field(), ShouldEqual, "Retry")
+		})
+
+		Convey("其他标签保留原始错误信息", func() {
+			type User struct {
+				Name string `validate:"required"`
+			}
+			err := ValidateStruct(&User{})
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "'required' tag")
+		})
+	})
+}
@@ -2,10 +2,11 @@ package validator
 
 import (
 	"reflect"
-
-	"github.com/go-playground/validator/v10"
 )
 
+// validate 注册了自定义标签的 validator，会缓存结构体的解析结果，可以并发使用
+var validate = newValidate()
+
 // ValidateStruct 使用 validator 校验结构体
 // 这是一个通用的结构体校验函数，提供了比直接使用 validator.Struct() 更好的容错性和类型检查
 func ValidateStruct(object interface{}) error {
@@ -50,12 +51,10 @@ func ValidateStruct(object interface{}) error {
 		if elem.Kind() == reflect.Ptr && !elem.IsNil() {
 			return ValidateStruct(elem.Interface())
 		} else if elem.Kind() == reflect.Struct {
-			validate := validator.New()
-			return validate.Struct(elem.Interface())
+			return wrapValidationError(validate.Struct(elem.Interface()))
 		}
 	}
 
 	// 对于非指针的结构体
-	validate := validator.New()
-	return validate.Struct(object)
+	return wrapValidationError(validate.Struct(object))
 }
\ No newline at end of file
@@ -32,7 +32,7 @@ func NewLoaderWithOptions[K, V any](options *ref.TypeOptions) (Loader[K, V], err
 	ref.RegisterT[KVFileLoader[K, V]](NewKVFileLoaderWithOptions[K, V])
 	ref.RegisterT[FileTrigger[K, V]](NewFileTriggerWithOptions[K, V])
 
-	loader, err := ref.New(options.Namespace, options.Type, options.Options)
+	loader, err := ref.NewWithOptions(options)
 	if err != nil {
 		return nil, errors.WithMessage(err, "refx.NewT failed")
 	}
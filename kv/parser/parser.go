@@ -38,7 +38,7 @@ func NewParserWithOptions[K, V any](options *ref.TypeOptions) (Parser[K, V], err
 		}
 	}
 
-	parser, err := ref.New(actualOptions.Namespace, actualOptions.Type, actualOptions.Options)
+	parser, err := ref.NewWithOptions(actualOptions)
 	if err != nil {
 		return nil, errors.WithMessage(err, "refx.NewT failed")
 	}
@@ -33,7 +33,7 @@ func NewByteSerializerWithOptions[T any](options *ref.TypeOptions) (Serializer[T
 		}
 	}
 
-	serializer, err := ref.New(actualOptions.Namespace, actualOptions.Type, actualOptions.Options)
+	serializer, err := ref.NewWithOptions(actualOptions)
 	if err != nil {
 		return nil, errors.WithMessage(err, "refx.NewT failed")
 	}
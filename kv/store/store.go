@@ -66,7 +66,7 @@ func NewStoreWithOptions[K comparable, V any](options *ref.TypeOptions) (Store[K
 	ref.RegisterT[*TieredStore[K, V]](NewTieredStoreWithOptions[K, V])
 	ref.RegisterT[*ObservableStore[K, V]](NewObservableStoreWithOptions[K, V])
 
-	store, err := ref.New(options.Namespace, options.Type, options.Options)
+	store, err := ref.NewWithOptions(options)
 	if err != nil {
 		return nil, errors.WithMessage(err, "refx.NewT failed")
 	}
@@ -0,0 +1,172 @@
+package log
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hatlonely/gox/log/logger"
+	"github.com/hatlonely/gox/ref"
+)
+
+// ErrBenchmarkTargetNotMet 日志写入性能未达到 BenchmarkOptions 中设置的目标
+var ErrBenchmarkTargetNotMet = errors.New("logging benchmark target not met")
+
+// BenchmarkOptions 日志写入性能自测配置
+type BenchmarkOptions struct {
+	// Logger 被测日志器配置，使用与生产环境相同的配置，为空时使用默认日志器
+	Logger *ref.TypeOptions `cfg:"logger"`
+	// Duration 测试时长，默认 1s
+	Duration time.Duration `cfg:"duration" def:"1s"`
+	// Concurrency 并发写入的 goroutine 数，默认为 GOMAXPROCS
+	Concurrency int `cfg:"concurrency"`
+	// Fields 每条日志附加的键值对数量，字段值为字符串、整数、时长交替
+	Fields int `cfg:"fields"`
+	// Level 写入日志的级别，低于日志器级别时测量的是被过滤的开销，默认 info
+	Level string `cfg:"level" def:"info" validate:"omitempty,oneof=debug info warn error"`
+
+	// MinRecordsPerSecond 吞吐下限，为 0 时不检查
+	MinRecordsPerSecond float64 `cfg:"minRecordsPerSecond"`
+	// MaxAllocsPerRecord 每条日志内存分配次数上限，为 0 时不检查
+	MaxAllocsPerRecord float64 `cfg:"maxAllocsPerRecord"`
+}
+
+// BenchmarkResult 日志写入性能自测结果
+type BenchmarkResult struct {
+	Records          int64         // 写入的日志条数
+	Duration         time.Duration // 实际耗时
+	RecordsPerSecond float64       // 每秒写入的日志条数
+	AllocsPerRecord  float64       // 每条日志的内存分配次数
+	BytesPerRecord   float64       // 每条日志分配的字节数
+	Dropped          uint64        // 写入失败丢弃的日志条数，日志器不支持统计时为 0
+}
+
+func (r *BenchmarkResult) String() string {
+	return fmt.Sprintf("%d records in %v, %.0f records/s, %.1f allocs/record, %.0f B/record, %d dropped",
+		r.Records, r.Duration, r.RecordsPerSecond, r.AllocsPerRecord, r.BytesPerRecord, r.Dropped)
+}
+
+// BenchmarkPipeline 按配置创建日志器并持续写入日志，测量吞吐和内存分配，用于上线前验证生产日志配置能否满足吞吐要求
+//
+// 日志会真实写入配置的输出目标。内存分配按进程全局统计，应在没有其他负载时执行。
+// 设置了 MinRecordsPerSecond 或 MaxAllocsPerRecord 时，未达标返回结果和 ErrBenchmarkTargetNotMet，可以作为发布流程的性能门禁
+func BenchmarkPipeline(options *BenchmarkOptions) (*BenchmarkResult, error) {
+	if options == nil {
+		options = &BenchmarkOptions{}
+	}
+	duration := options.Duration
+	if duration <= 0 {
+		duration = time.Second
+	}
+	concurrency := options.Concurrency
+	if concurrency <= 0 {
+		concurrency = runtime.GOMAXPROCS(0)
+	}
+
+	l, err := NewLoggerWithOptions(options.Logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create logger: %w", err)
+	}
+	write, err := benchmarkWriter(l, options.Level)
+	if err != nil {
+		return nil, err
+	}
+	args := benchmarkArgs(options.Fields)
+
+	// 预热，避免首次写入的初始化开销计入结果
+	ctx := context.Background()
+	for i := 0; i < 100; i++ {
+		write(ctx, "benchmark record", args...)
+	}
+
+	var dropped uint64
+	counter, hasDropped := l.(interface{ Dropped() uint64 })
+	if hasDropped {
+		dropped = counter.Dropped()
+	}
+
+	runtime.GC()
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+
+	var records atomic.Int64
+	var stop atomic.Bool
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var n int64
+			for !stop.Load() {
+				write(ctx, "benchmark record", args...)
+				n++
+			}
+			records.Add(n)
+		}()
+	}
+	time.Sleep(duration)
+	stop.Store(true)
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	runtime.ReadMemStats(&after)
+
+	result := &BenchmarkResult{
+		Records:  records.Load(),
+		Duration: elapsed,
+	}
+	if result.Records > 0 {
+		result.RecordsPerSecond = float64(result.Records) / elapsed.Seconds()
+		result.AllocsPerRecord = float64(after.Mallocs-before.Mallocs) / float64(result.Records)
+		result.BytesPerRecord = float64(after.TotalAlloc-before.TotalAlloc) / float64(result.Records)
+	}
+	if hasDropped {
+		result.Dropped = counter.Dropped() - dropped
+	}
+
+	if options.MinRecordsPerSecond > 0 && result.RecordsPerSecond < options.MinRecordsPerSecond {
+		return result, fmt.Errorf("%w: %.0f records/s, want at least %.0f", ErrBenchmarkTargetNotMet, result.RecordsPerSecond, options.MinRecordsPerSecond)
+	}
+	if options.MaxAllocsPerRecord > 0 && result.AllocsPerRecord > options.MaxAllocsPerRecord {
+		return result, fmt.Errorf("%w: %.1f allocs/record, want at most %.1f", ErrBenchmarkTargetNotMet, result.AllocsPerRecord, options.MaxAllocsPerRecord)
+	}
+	return result, nil
+}
+
+// benchmarkWriter 按级别选择日志方法
+func benchmarkWriter(l logger.Logger, level string) (func(ctx context.Context, msg string, args ...any), error) {
+	switch level {
+	case "", "info":
+		return l.InfoContext, nil
+	case "debug":
+		return l.DebugContext, nil
+	case "warn":
+		return l.WarnContext, nil
+	case "error":
+		return l.ErrorContext, nil
+	}
+	return nil, fmt.Errorf("unsupported level: %s", level)
+}
+
+// benchmarkArgs 生成 n 个键值对，值为字符串、整数、时长交替
+func benchmarkArgs(n int) []any {
+	args := make([]any, 0, n*2)
+	for i := 0; i < n; i++ {
+		key := "field" + strconv.Itoa(i)
+		switch i % 3 {
+		case 0:
+			args = append(args, key, "value"+strconv.Itoa(i))
+		case 1:
+			args = append(args, key, i)
+		default:
+			args = append(args, key, time.Duration(i)*time.Millisecond)
+		}
+	}
+	return args
+}
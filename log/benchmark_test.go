@@ -0,0 +1,133 @@
+package log
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hatlonely/gox/log/logger"
+	"github.com/hatlonely/gox/log/writer"
+	"github.com/hatlonely/gox/ref"
+)
+
+func fileLoggerOptions(path string, options *logger.SLogOptions) *ref.TypeOptions {
+	options.Output = &ref.TypeOptions{
+		Namespace: "github.com/hatlonely/gox/log/writer",
+		Type:      "FileWriter",
+		Options:   &writer.FileWriterOptions{Path: path},
+	}
+	return &ref.TypeOptions{
+		Namespace: "github.com/hatlonely/gox/log/logger",
+		Type:      "SLog",
+		Options:   options,
+	}
+}
+
+func TestBenchmarkPipeline(t *testing.T) {
+	logFile := t.TempDir() + "/bench.log"
+	result, err := BenchmarkPipeline(&BenchmarkOptions{
+		Logger:      fileLoggerOptions(logFile, &logger.SLogOptions{Level: "info", Format: "json"}),
+		Duration:    50 * time.Millisecond,
+		Concurrency: 2,
+		Fields:      4,
+	})
+	if err != nil {
+		t.Fatalf("BenchmarkPipeline() error = %v", err)
+	}
+	if result.Records <= 0 || result.RecordsPerSecond <= 0 {
+		t.Fatalf("unexpected result: %v", result)
+	}
+	if result.Duration < 50*time.Millisecond {
+		t.Errorf("duration = %v, want at least 50ms", result.Duration)
+	}
+
+	content, err := os.ReadFile(logFile)
+	if err != nil {
+		t.Fatalf("Failed to read log file: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	// 包含 100 条预热日志
+	if int64(len(lines)) != result.Records+100 {
+		t.Errorf("expected %d lines, got %d", result.Records+100, len(lines))
+	}
+	if !strings.Contains(lines[0], `"field0":"value0"`) || !strings.Contains(lines[0], `"field3":"value3"`) {
+		t.Errorf("line 0 = %s, want fields", lines[0])
+	}
+
+	// 未达到目标
+	result, err = BenchmarkPipeline(&BenchmarkOptions{
+		Logger:              fileLoggerOptions(t.TempDir()+"/gate.log", &logger.SLogOptions{Level: "info", Format: "text"}),
+		Duration:            10 * time.Millisecond,
+		MinRecordsPerSecond: 1e12,
+	})
+	if !errors.Is(err, ErrBenchmarkTargetNotMet) || result == nil {
+		t.Errorf("expected ErrBenchmarkTargetNotMet with result, got %v, %v", result, err)
+	}
+
+	// 被级别过滤的日志
+	filteredFile := t.TempDir() + "/filtered.log"
+	result, err = BenchmarkPipeline(&BenchmarkOptions{
+		Logger:   fileLoggerOptions(filteredFile, &logger.SLogOptions{Level: "warn", Format: "json"}),
+		Duration: 10 * time.Millisecond,
+		Level:    "debug",
+	})
+	if err != nil || result.Records <= 0 {
+		t.Fatalf("unexpected result: %v, %v", result, err)
+	}
+	if content, _ := os.ReadFile(filteredFile); len(content) != 0 {
+		t.Errorf("expected no output, got %s", content)
+	}
+
+	if _, err := BenchmarkPipeline(&BenchmarkOptions{Level: "trace"}); err == nil {
+		t.Error("expected error for unsupported level")
+	}
+}
+
+func benchmarkSLog(b *testing.B, options *logger.SLogOptions, ctx context.Context) {
+	options.Output = &ref.TypeOptions{
+		Namespace: "github.com/hatlonely/gox/log/writer",
+		Type:      "FileWriter",
+		Options:   &writer.FileWriterOptions{Path: b.TempDir() + "/bench.log"},
+	}
+	l, err := logger.NewSLogWithOptions(options)
+	if err != nil {
+		b.Fatalf("NewSLogWithOptions() error = %v", err)
+	}
+	args := benchmarkArgs(5)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			l.InfoContext(ctx, "benchmark record", args...)
+		}
+	})
+}
+
+func BenchmarkSLogText(b *testing.B) {
+	benchmarkSLog(b, &logger.SLogOptions{Level: "info", Format: "text"}, context.Background())
+}
+
+func BenchmarkSLogJSON(b *testing.B) {
+	benchmarkSLog(b, &logger.SLogOptions{Level: "info", Format: "json"}, context.Background())
+}
+
+func BenchmarkSLogJSONSource(b *testing.B) {
+	benchmarkSLog(b, &logger.SLogOptions{Level: "info", Format: "json", AddSource: true}, context.Background())
+}
+
+func BenchmarkSLogJSONSequence(b *testing.B) {
+	benchmarkSLog(b, &logger.SLogOptions{Level: "info", Format: "json", Sequence: true, SequenceKey: "seq"}, context.Background())
+}
+
+func BenchmarkSLogJSONCorrelationID(b *testing.B) {
+	ctx, _ := NewCorrelationID(context.Background())
+	benchmarkSLog(b, &logger.SLogOptions{Level: "info", Format: "json"}, ctx)
+}
+
+func BenchmarkSLogFiltered(b *testing.B) {
+	benchmarkSLog(b, &logger.SLogOptions{Level: "warn", Format: "json"}, context.Background())
+}
@@ -0,0 +1,127 @@
+package log
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/hatlonely/gox/log/logger"
+)
+
+// BufferDroppedKey 缓冲区溢出时，触发输出的 Error 日志附加的丢弃条数字段名
+const BufferDroppedKey = "bufferDropped"
+
+// WithFlushOnError 请求日志器的 Debug、Info 日志先进入容量为 maxRecords 的环形缓冲区，不立即输出；
+// 请求中输出 Error 日志时，先按原始时间和顺序输出缓冲的日志，再输出 Error 日志，请求正常结束时缓冲的日志直接丢弃。
+// 缓冲的日志输出时不再经过级别过滤，因此 Debug 日志也会输出；maxRecords 小于等于 0 时不缓冲
+func WithFlushOnError(maxRecords int) RequestOption {
+	return func(o *requestOptions) {
+		o.buffer = maxRecords
+	}
+}
+
+// Flush 立即输出 ctx 中请求日志器缓冲的日志，用于没有 Error 日志但需要保留上下文的场景，如返回 5xx 响应；
+// ctx 中没有请求日志器或者未启用 WithFlushOnError 时不做处理
+func Flush(ctx context.Context) {
+	if l, ok := ctx.Value(requestLoggerContextKey{}).(*contextLogger); ok {
+		l.buffer.flush()
+	}
+}
+
+// recordHandler 可以直接写入 slog.Record 的日志器，如 *logger.SLog
+type recordHandler interface {
+	Handler() slog.Handler
+}
+
+// requestBuffer 请求范围内缓冲的 Debug、Info 日志，nil buffer 不做缓冲
+type requestBuffer struct {
+	mu      sync.Mutex
+	records []bufferedRecord // 环形缓冲区，start 为最早的一条
+	start   int
+	size    int
+	dropped int
+}
+
+// bufferedRecord 缓冲的一条日志，记录输出时使用的日志器和 ctx
+type bufferedRecord struct {
+	logger logger.Logger
+	ctx    context.Context
+	record slog.Record
+}
+
+func newRequestBuffer(maxRecords int) *requestBuffer {
+	return &requestBuffer{records: make([]bufferedRecord, maxRecords)}
+}
+
+// hold 缓冲一条 Debug、Info 日志，返回 false 时调用方直接输出；缓冲区已满时覆盖最早的日志
+func (b *requestBuffer) hold(l logger.Logger, ctx context.Context, level slog.Level, msg string, args []any) bool {
+	if b == nil || level > slog.LevelInfo {
+		return false
+	}
+
+	// 跳过 runtime.Callers、hold 和 contextLogger 的方法，与 slog 一样记录调用者
+	var pcs [1]uintptr
+	runtime.Callers(3, pcs[:])
+	record := slog.NewRecord(time.Now(), level, msg, pcs[0])
+	record.Add(args...)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	index := (b.start + b.size) % len(b.records)
+	if b.size == len(b.records) {
+		b.start = (b.start + 1) % len(b.records)
+		b.dropped++
+	} else {
+		b.size++
+	}
+	b.records[index] = bufferedRecord{logger: l, ctx: ctx, record: record}
+	return true
+}
+
+// flushArgs 输出缓冲的日志，返回需要附加到 Error 日志的字段
+func (b *requestBuffer) flushArgs(args []any) []any {
+	if dropped := b.flush(); dropped > 0 {
+		return append(args[:len(args):len(args)], slog.Int(BufferDroppedKey, dropped))
+	}
+	return args
+}
+
+// flush 按顺序输出并清空缓冲的日志，返回输出之前因缓冲区溢出丢弃的条数
+func (b *requestBuffer) flush() int {
+	if b == nil {
+		return 0
+	}
+
+	// 持有锁输出，保证并发的 Error 日志之间缓冲的日志不会交错
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i := 0; i < b.size; i++ {
+		index := (b.start + i) % len(b.records)
+		writeRecord(b.records[index])
+		b.records[index] = bufferedRecord{}
+	}
+	dropped := b.dropped
+	b.start, b.size, b.dropped = 0, 0, 0
+	return dropped
+}
+
+// writeRecord 输出缓冲的日志，日志器支持直接写入 slog.Record 时保留原始时间和调用者，否则按级别重新输出
+func writeRecord(r bufferedRecord) {
+	if h, ok := r.logger.(recordHandler); ok {
+		_ = h.Handler().Handle(r.ctx, r.record)
+		return
+	}
+
+	args := make([]any, 0, r.record.NumAttrs())
+	r.record.Attrs(func(attr slog.Attr) bool {
+		args = append(args, attr)
+		return true
+	})
+	if r.record.Level < slog.LevelInfo {
+		r.logger.DebugContext(r.ctx, r.record.Message, args...)
+	} else {
+		r.logger.InfoContext(r.ctx, r.record.Message, args...)
+	}
+}
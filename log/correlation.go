@@ -0,0 +1,81 @@
+package log
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/hatlonely/gox/log/logger"
+)
+
+// CorrelationIDHeader 在服务之间传递关联 ID 的 HTTP 头
+const CorrelationIDHeader = "X-Correlation-ID"
+
+// maxCorrelationIDLength 上游传入的关联 ID 最大长度，超长或包含不可见字符的 ID 被丢弃并重新生成
+const maxCorrelationIDLength = 128
+
+// NewCorrelationID 获取请求的关联 ID，ctx 中没有时生成新的 ID（UUIDv7）并写入返回的 ctx
+// 之后使用返回的 ctx 输出的日志都会附加 correlationId 字段
+func NewCorrelationID(ctx context.Context) (context.Context, string) {
+	if id, ok := logger.CorrelationIDFromContext(ctx); ok {
+		return ctx, id
+	}
+	id := generateCorrelationID()
+	return logger.ContextWithCorrelationID(ctx, id), id
+}
+
+// WithCorrelationID 将上游传入的关联 ID 写入 ctx，id 为空时返回原 ctx
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	if id == "" {
+		return ctx
+	}
+	return logger.ContextWithCorrelationID(ctx, id)
+}
+
+// CorrelationID 获取 ctx 中的关联 ID，不存在时返回空字符串
+func CorrelationID(ctx context.Context) string {
+	id, _ := logger.CorrelationIDFromContext(ctx)
+	return id
+}
+
+// CorrelationIDMiddleware HTTP 中间件，沿用请求头 X-Correlation-ID 中的关联 ID，没有时生成新的 ID，
+// 写入请求的 ctx 并通过响应头返回，调用方和下游服务可以用同一个 ID 检索日志
+// 请求的 ctx 中同时写入 ForRequest 创建的请求日志器，handler 通过 FromContext 获取
+func CorrelationIDMiddleware(next http.Handler) http.Handler {
+	return RequestMiddleware()(next)
+}
+
+// RequestMiddleware 与 CorrelationIDMiddleware 相同，opts 传给 ForRequest，用于指定日志器、启用错误快照等
+func RequestMiddleware(opts ...RequestOption) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			if id := r.Header.Get(CorrelationIDHeader); validCorrelationID(id) {
+				ctx = logger.ContextWithCorrelationID(ctx, id)
+			}
+			ctx, _ = ForRequest(ctx, opts...)
+			w.Header().Set(CorrelationIDHeader, CorrelationID(ctx))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+func generateCorrelationID() string {
+	if id, err := uuid.NewV7(); err == nil {
+		return id.String()
+	}
+	return uuid.NewString()
+}
+
+// validCorrelationID 只接受长度有限的可见 ASCII 字符，避免日志注入
+func validCorrelationID(id string) bool {
+	if id == "" || len(id) > maxCorrelationIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,117 @@
+package log
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/hatlonely/gox/log/logger"
+	"github.com/hatlonely/gox/log/writer"
+	"github.com/hatlonely/gox/ref"
+)
+
+func TestNewCorrelationID(t *testing.T) {
+	ctx, id := NewCorrelationID(context.Background())
+	if id == "" {
+		t.Fatal("expected generated correlation id")
+	}
+	if CorrelationID(ctx) != id {
+		t.Errorf("CorrelationID() = %q, want %q", CorrelationID(ctx), id)
+	}
+
+	// 已有关联 ID 时沿用
+	ctx2, id2 := NewCorrelationID(ctx)
+	if id2 != id || ctx2 != ctx {
+		t.Errorf("NewCorrelationID() = %q, want existing %q", id2, id)
+	}
+
+	if CorrelationID(context.Background()) != "" {
+		t.Error("expected empty correlation id")
+	}
+	if CorrelationID(WithCorrelationID(context.Background(), "upstream")) != "upstream" {
+		t.Error("expected upstream correlation id")
+	}
+	if ctx := context.Background(); WithCorrelationID(ctx, "") != ctx {
+		t.Error("expected original context for empty id")
+	}
+}
+
+func TestCorrelationIDLogging(t *testing.T) {
+	logFile := t.TempDir() + "/correlation.log"
+	l, err := logger.NewSLogWithOptions(&logger.SLogOptions{
+		Level:  "info",
+		Format: "json",
+		Output: &ref.TypeOptions{
+			Namespace: "github.com/hatlonely/gox/log/writer",
+			Type:      "FileWriter",
+			Options:   &writer.FileWriterOptions{Path: logFile},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewSLogWithOptions() error = %v", err)
+	}
+
+	ctx, id := NewCorrelationID(context.Background())
+	l.InfoContext(ctx, "first")
+	l.With("component", "db").WithGroup("query").InfoContext(ctx, "second", "sql", "select")
+	l.Info("third")
+
+	content, err := os.ReadFile(logFile)
+	if err != nil {
+		t.Fatalf("Failed to read log file: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 lines, got %d: %s", len(lines), content)
+	}
+	if !strings.Contains(lines[0], `"correlationId":"`+id+`"`) {
+		t.Errorf("line 0 = %s, want correlationId", lines[0])
+	}
+	if !strings.Contains(lines[1], `"component":"db","correlationId":"`+id+`","query":{"sql":"select"}`) {
+		t.Errorf("line 1 = %s, want correlationId at top level", lines[1])
+	}
+	if strings.Contains(lines[2], "correlationId") {
+		t.Errorf("line 2 = %s, want no correlationId", lines[2])
+	}
+}
+
+func TestCorrelationIDMiddleware(t *testing.T) {
+	var got string
+	handler := CorrelationIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = CorrelationID(r.Context())
+	}))
+
+	tests := []struct {
+		name     string
+		header   string
+		expected string // 为空时表示重新生成
+	}{
+		{"沿用上游 ID", "req-123", "req-123"},
+		{"没有 ID 时生成", "", ""},
+		{"包含换行的 ID 被丢弃", "evil\nid", ""},
+		{"超长 ID 被丢弃", strings.Repeat("a", maxCorrelationIDLength+1), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set(CorrelationIDHeader, tt.header)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if got == "" || rec.Header().Get(CorrelationIDHeader) != got {
+				t.Fatalf("response header = %q, context id = %q", rec.Header().Get(CorrelationIDHeader), got)
+			}
+			if tt.expected != "" && got != tt.expected {
+				t.Errorf("correlation id = %q, want %q", got, tt.expected)
+			}
+			if tt.expected == "" && got == tt.header {
+				t.Errorf("correlation id = %q, want regenerated", got)
+			}
+		})
+	}
+}
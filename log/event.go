@@ -0,0 +1,91 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sort"
+
+	"github.com/hatlonely/gox/log/logger"
+)
+
+// 结构化事件的字段名，下游按这些字段识别事件并解析载荷
+const (
+	EventKey             = "event"
+	EventVersionKey      = "eventVersion"
+	EventPayloadKey      = "payload"
+	EventPayloadErrorKey = "payloadError"
+)
+
+// EventVersioner 事件载荷实现该接口时声明 schema 版本，载荷结构发生不兼容变更时递增，未实现时版本为 1
+type EventVersioner interface {
+	EventVersion() int
+}
+
+// Event 以一条 Info 日志输出结构化事件，日志消息和 event 字段为事件名称，eventVersion 字段为载荷的 schema 版本，
+// 载荷按 JSON 序列化规则转换后输出到 payload 字段下，字段名和顺序稳定，下游可以按事件名称和版本确定地解析
+// l 为 nil 时使用默认日志器，args 为附加的日志字段
+func Event[T any](l logger.Logger, name string, payload T, args ...any) {
+	EventContext(context.Background(), l, name, payload, args...)
+}
+
+// EventContext 与 Event 相同，使用指定的 context 输出日志
+func EventContext[T any](ctx context.Context, l logger.Logger, name string, payload T, args ...any) {
+	if l == nil {
+		l = Default()
+	}
+	fields := []any{slog.String(EventKey, name), slog.Int(EventVersionKey, eventVersion(payload))}
+	if value, err := eventPayloadValue(payload); err != nil {
+		// 载荷无法序列化时仍然输出事件，便于发现问题
+		fields = append(fields, slog.String(EventPayloadErrorKey, err.Error()))
+	} else {
+		fields = append(fields, slog.Attr{Key: EventPayloadKey, Value: value})
+	}
+	l.InfoContext(ctx, name, append(fields, args...)...)
+}
+
+// eventVersion 返回载荷声明的 schema 版本，值接收者和指针接收者实现的 EventVersioner 都可以识别
+func eventVersion[T any](payload T) int {
+	if v, ok := any(payload).(EventVersioner); ok {
+		return v.EventVersion()
+	}
+	if v, ok := any(&payload).(EventVersioner); ok {
+		return v.EventVersion()
+	}
+	return 1
+}
+
+// eventPayloadValue 将载荷按 JSON 序列化规则转换为日志值，对象展开为按键排序的分组
+func eventPayloadValue(payload any) (slog.Value, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return slog.Value{}, err
+	}
+	// 数字保留为 json.Number，避免大整数丢失精度
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var normalized any
+	if err := decoder.Decode(&normalized); err != nil {
+		return slog.Value{}, err
+	}
+	return eventValue(normalized), nil
+}
+
+func eventValue(value any) slog.Value {
+	// 空对象作为分组输出时会被省略，保留为 {}
+	object, ok := value.(map[string]any)
+	if !ok || len(object) == 0 {
+		return slog.AnyValue(value)
+	}
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	attrs := make([]slog.Attr, 0, len(keys))
+	for _, key := range keys {
+		attrs = append(attrs, slog.Attr{Key: key, Value: eventValue(object[key])})
+	}
+	return slog.GroupValue(attrs...)
+}
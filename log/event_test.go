@@ -0,0 +1,67 @@
+package log
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+type orderCreated struct {
+	OrderID  int64             `json:"orderId"`
+	Amount   float64           `json:"amount"`
+	Items    []string          `json:"items"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	internal string
+}
+
+func (orderCreated) EventVersion() int { return 2 }
+
+type userLogin struct {
+	User string `json:"user"`
+}
+
+func TestEvent(t *testing.T) {
+	l, lines := newRequestTestLogger(t)
+
+	Event(l, "order.created", orderCreated{
+		OrderID: 9007199254740993,
+		Amount:  12.5,
+		Items:   []string{"a", "b"},
+		Labels:  map[string]string{"z": "1", "a": "2"},
+	}, "source", "api")
+	Event(l, "user.login", &userLogin{User: "alice"})
+	Event(l, "counter", 3)
+	Event(l, "broken", func() {})
+
+	logs := lines()
+	if len(logs) != 4 {
+		t.Fatalf("expected 4 lines, got %d: %v", len(logs), logs)
+	}
+
+	// 大整数保留精度，map 的键按字典序输出
+	if !strings.Contains(logs[0], `"payload":{"amount":12.5,"items":["a","b"],"labels":{"a":"2","z":"1"},"orderId":9007199254740993}`) {
+		t.Errorf("unexpected payload: %s", logs[0])
+	}
+
+	var record map[string]any
+	if err := json.Unmarshal([]byte(logs[0]), &record); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+	if record["msg"] != "order.created" || record[EventKey] != "order.created" || record[EventVersionKey] != float64(2) || record["source"] != "api" {
+		t.Errorf("unexpected event record: %v", record)
+	}
+
+	if err := json.Unmarshal([]byte(logs[1]), &record); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+	if record[EventVersionKey] != float64(1) || record[EventPayloadKey].(map[string]any)["user"] != "alice" {
+		t.Errorf("unexpected event record: %v", record)
+	}
+
+	if !strings.Contains(logs[2], `"payload":3`) {
+		t.Errorf("unexpected payload: %s", logs[2])
+	}
+	if !strings.Contains(logs[3], `"payloadError":`) || strings.Contains(logs[3], `"payload":`) {
+		t.Errorf("expected payload error: %s", logs[3])
+	}
+}
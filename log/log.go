@@ -67,3 +67,18 @@ func NewLoggerWithOptions(options *ref.TypeOptions) (logger.Logger, error) {
 	}
 	return logger.NewLoggerWithOptions(options)
 }
+
+// SetErrorHandler 设置日志系统内部错误回调，传入 nil 时输出到标准错误
+func SetErrorHandler(handler logger.ErrorHandler) {
+	logger.SetErrorHandler(handler)
+}
+
+// RegisterFieldProvider 按名称注册动态字段提供者，在日志器配置的 fieldProviders 中引用
+func RegisterFieldProvider(name string, provider logger.FieldProvider) {
+	logger.RegisterFieldProvider(name, provider)
+}
+
+// RegisterProcessor 按名称注册日志处理器，在日志器配置的 processors 中通过 type 引用
+func RegisterProcessor(name string, processor logger.Processor) {
+	logger.RegisterProcessor(name, processor)
+}
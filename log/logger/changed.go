@@ -0,0 +1,177 @@
+package logger
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ChangeKind 变更类型
+type ChangeKind string
+
+const (
+	ChangeAdded    ChangeKind = "added"
+	ChangeRemoved  ChangeKind = "removed"
+	ChangeModified ChangeKind = "modified"
+)
+
+// Change 一处变更，Path 形如 "db.hosts[0].port"
+type Change struct {
+	Path   string     `json:"path"`
+	Kind   ChangeKind `json:"kind"`
+	Before any        `json:"before,omitempty"`
+	After  any        `json:"after,omitempty"`
+}
+
+// Changes 按路径排序的变更列表
+// 作为日志字段输出时按路径展开为 before/after，如 JSON 格式输出为 {"db.port":{"before":3306,"after":3307}}
+type Changes []Change
+
+// Diff 对比新旧值，返回所有变更的叶子节点
+// 结构体等非 map/slice 的值先按 JSON 序列化规则转换，map 按键、slice 按下标逐层对比
+func Diff(oldValue, newValue any) Changes {
+	var changes Changes
+	diffValue("", oldValue, newValue, &changes)
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes
+}
+
+// normalizeDiffValue 将任意值转换为由 map[string]any、[]any 和基础类型组成的值
+func normalizeDiffValue(value any) any {
+	switch value.(type) {
+	case nil, string, bool, float64, map[string]any, []any:
+		return value
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return value
+	}
+	var normalized any
+	if err := json.Unmarshal(data, &normalized); err != nil {
+		return value
+	}
+	return normalized
+}
+
+func diffValue(path string, oldValue, newValue any, changes *Changes) {
+	oldValue, newValue = normalizeDiffValue(oldValue), normalizeDiffValue(newValue)
+
+	oldMap, oldIsMap := oldValue.(map[string]any)
+	newMap, newIsMap := newValue.(map[string]any)
+	if oldIsMap && newIsMap {
+		for key, oldItem := range oldMap {
+			if newItem, ok := newMap[key]; ok {
+				diffValue(joinDiffPath(path, key), oldItem, newItem, changes)
+			} else {
+				*changes = append(*changes, Change{Path: joinDiffPath(path, key), Kind: ChangeRemoved, Before: oldItem})
+			}
+		}
+		for key, newItem := range newMap {
+			if _, ok := oldMap[key]; !ok {
+				*changes = append(*changes, Change{Path: joinDiffPath(path, key), Kind: ChangeAdded, After: newItem})
+			}
+		}
+		return
+	}
+
+	oldSlice, oldIsSlice := oldValue.([]any)
+	newSlice, newIsSlice := newValue.([]any)
+	if oldIsSlice && newIsSlice {
+		for i := 0; i < max(len(oldSlice), len(newSlice)); i++ {
+			itemPath := fmt.Sprintf("%s[%d]", path, i)
+			switch {
+			case i >= len(newSlice):
+				*changes = append(*changes, Change{Path: itemPath, Kind: ChangeRemoved, Before: oldSlice[i]})
+			case i >= len(oldSlice):
+				*changes = append(*changes, Change{Path: itemPath, Kind: ChangeAdded, After: newSlice[i]})
+			default:
+				diffValue(itemPath, oldSlice[i], newSlice[i], changes)
+			}
+		}
+		return
+	}
+
+	if !reflect.DeepEqual(oldValue, newValue) {
+		*changes = append(*changes, Change{Path: path, Kind: ChangeModified, Before: oldValue, After: newValue})
+	}
+}
+
+func joinDiffPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// LogValue 实现 slog.LogValuer，按路径展开为 before/after
+func (c Changes) LogValue() slog.Value {
+	attrs := make([]slog.Attr, 0, len(c))
+	for _, change := range c {
+		var values []any
+		if change.Kind != ChangeAdded {
+			values = append(values, slog.Any("before", change.Before))
+		}
+		if change.Kind != ChangeRemoved {
+			values = append(values, slog.Any("after", change.After))
+		}
+		attrs = append(attrs, slog.Group(change.Path, values...))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+const (
+	colorReset  = "\033[0m"
+	colorRed    = "\033[31m"
+	colorGreen  = "\033[32m"
+	colorYellow = "\033[33m"
+)
+
+// Format 格式化为 diff 风格的多行文本，新增以 + 开头、删除以 - 开头、修改以 ~ 开头
+// color 为 true 时使用终端颜色，适合在控制台或命令行工具中展示
+func (c Changes) Format(color bool) string {
+	var sb strings.Builder
+	for i, change := range c {
+		if i > 0 {
+			sb.WriteByte('\n')
+		}
+
+		var line, lineColor string
+		switch change.Kind {
+		case ChangeAdded:
+			line, lineColor = fmt.Sprintf("+ %s: %v", change.Path, change.After), colorGreen
+		case ChangeRemoved:
+			line, lineColor = fmt.Sprintf("- %s: %v", change.Path, change.Before), colorRed
+		default:
+			line, lineColor = fmt.Sprintf("~ %s: %v -> %v", change.Path, change.Before, change.After), colorYellow
+		}
+
+		if color {
+			sb.WriteString(lineColor + line + colorReset)
+		} else {
+			sb.WriteString(line)
+		}
+	}
+	return sb.String()
+}
+
+// Changed 对比新旧值，有变更时以一条 Info 日志输出所有变更，没有变更时不输出
+// 用于配置热更新、应用状态切换等场景，args 为附加的日志字段
+func Changed(l Logger, name string, oldValue, newValue any, args ...any) {
+	ChangedContext(context.Background(), l, name, oldValue, newValue, args...)
+}
+
+// ChangedContext 与 Changed 相同，使用指定的 context 输出日志
+func ChangedContext(ctx context.Context, l Logger, name string, oldValue, newValue any, args ...any) {
+	changes := Diff(oldValue, newValue)
+	if len(changes) == 0 {
+		return
+	}
+	l.InfoContext(ctx, name+" changed", append([]any{"changes", changes}, args...)...)
+}
@@ -0,0 +1,102 @@
+package logger
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hatlonely/gox/log/writer"
+	"github.com/hatlonely/gox/ref"
+)
+
+func TestDiff(t *testing.T) {
+	type dbOptions struct {
+		Host  string   `json:"host"`
+		Port  int      `json:"port"`
+		Hosts []string `json:"hosts"`
+	}
+
+	oldValue := map[string]any{
+		"db":      dbOptions{Host: "localhost", Port: 3306, Hosts: []string{"a", "b"}},
+		"timeout": "1s",
+		"debug":   true,
+	}
+	newValue := map[string]any{
+		"db":      dbOptions{Host: "localhost", Port: 3307, Hosts: []string{"a"}},
+		"timeout": "1s",
+		"cache":   map[string]any{"ttl": 60},
+	}
+
+	changes := Diff(oldValue, newValue)
+	want := Changes{
+		{Path: "cache", Kind: ChangeAdded, After: map[string]any{"ttl": float64(60)}},
+		{Path: "db.hosts[1]", Kind: ChangeRemoved, Before: "b"},
+		{Path: "db.port", Kind: ChangeModified, Before: float64(3306), After: float64(3307)},
+		{Path: "debug", Kind: ChangeRemoved, Before: true},
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("Diff() = %+v, want %+v", changes, want)
+	}
+	for i := range want {
+		if changes[i].Path != want[i].Path || changes[i].Kind != want[i].Kind {
+			t.Errorf("change %d = %+v, want %+v", i, changes[i], want[i])
+		}
+	}
+	if changes[2].Before != float64(3306) || changes[2].After != float64(3307) {
+		t.Errorf("unexpected modified change: %+v", changes[2])
+	}
+
+	if len(Diff(oldValue, oldValue)) != 0 {
+		t.Error("expected no changes for equal values")
+	}
+	if changes := Diff(1, 2); len(changes) != 1 || changes[0].Path != "" {
+		t.Errorf("unexpected scalar diff: %+v", changes)
+	}
+
+	text := changes.Format(false)
+	wantText := "+ cache: map[ttl:60]\n- db.hosts[1]: b\n~ db.port: 3306 -> 3307\n- debug: true"
+	if text != wantText {
+		t.Errorf("Format(false) = %q, want %q", text, wantText)
+	}
+	if colored := changes.Format(true); !strings.Contains(colored, colorGreen+"+ cache") || !strings.Contains(colored, colorYellow+"~ db.port") {
+		t.Errorf("Format(true) = %q, want colored lines", colored)
+	}
+}
+
+func TestChanged(t *testing.T) {
+	logFile := t.TempDir() + "/changed.log"
+	logger, err := NewSLogWithOptions(&SLogOptions{
+		Level:  "info",
+		Format: "json",
+		Output: &ref.TypeOptions{
+			Namespace: "github.com/hatlonely/gox/log/writer",
+			Type:      "FileWriter",
+			Options:   &writer.FileWriterOptions{Path: logFile},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewSLogWithOptions() error = %v", err)
+	}
+
+	Changed(logger, "state", map[string]any{"phase": "starting", "workers": 1}, map[string]any{"phase": "running", "workers": 1, "since": time.Unix(0, 0).UTC()}, "component", "scheduler")
+	Changed(logger, "state", "same", "same")
+
+	content, err := os.ReadFile(logFile)
+	if err != nil {
+		t.Fatalf("Failed to read log file: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("expected 1 line, got %d: %s", len(lines), content)
+	}
+	for _, want := range []string{
+		`"msg":"state changed"`,
+		`"changes":{"phase":{"before":"starting","after":"running"},"since":{"after":"1970-01-01T00:00:00Z"}}`,
+		`"component":"scheduler"`,
+	} {
+		if !strings.Contains(lines[0], want) {
+			t.Errorf("log = %s, want contains %s", lines[0], want)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log/slog"
+	"net"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ClockSkewOptions 时钟偏差估计，定期通过 SNTP 查询 NTP 服务器，为每条日志附加本机时钟的偏差，
+// 用于跨主机关联日志时校正时间
+type ClockSkewOptions struct {
+	// NTP 服务器地址，如 pool.ntp.org:123，省略端口时使用 123
+	Server string `cfg:"server" def:"pool.ntp.org:123"`
+
+	// 查询间隔
+	Interval time.Duration `cfg:"interval" def:"10m"`
+
+	// 单次查询超时
+	Timeout time.Duration `cfg:"timeout" def:"5s"`
+
+	// 偏差字段名
+	Key string `cfg:"key" def:"clockSkew"`
+}
+
+// ntpEpochOffset NTP 时间戳从 1900 年开始，与 Unix 时间戳相差的秒数
+const ntpEpochOffset = 2208988800
+
+// clockSkew 日志器持有的偏差估计，日志器不再使用后通过 cleanup 停止后台查询
+type clockSkew struct {
+	*clockSkewEstimator
+}
+
+// clockSkewEstimator 后台定期查询 NTP 服务器，缓存最近一次成功的偏差估计
+// 偏差为 NTP 服务器时间减去本机时间，正数表示本机时钟慢
+type clockSkewEstimator struct {
+	server   string
+	interval time.Duration
+	timeout  time.Duration
+	key      string
+
+	skew  atomic.Int64
+	valid atomic.Bool
+
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+func newClockSkew(options *ClockSkewOptions) (*clockSkew, error) {
+	if options == nil {
+		return nil, fmt.Errorf("options cannot be nil")
+	}
+	estimator := &clockSkewEstimator{
+		server:   options.Server,
+		interval: options.Interval,
+		timeout:  options.Timeout,
+		key:      options.Key,
+		done:     make(chan struct{}),
+	}
+	if estimator.server == "" {
+		estimator.server = "pool.ntp.org:123"
+	}
+	if _, _, err := net.SplitHostPort(estimator.server); err != nil {
+		estimator.server = net.JoinHostPort(estimator.server, "123")
+	}
+	if estimator.interval <= 0 {
+		estimator.interval = 10 * time.Minute
+	}
+	if estimator.timeout <= 0 {
+		estimator.timeout = 5 * time.Second
+	}
+	if estimator.key == "" {
+		estimator.key = "clockSkew"
+	}
+
+	go estimator.run()
+	skew := &clockSkew{clockSkewEstimator: estimator}
+	runtime.AddCleanup(skew, (*clockSkewEstimator).stop, estimator)
+	return skew, nil
+}
+
+// fieldProvider 返回附加偏差字段的动态字段提供者，第一次查询成功之前不附加字段
+func (s *clockSkew) fieldProvider() FieldProvider {
+	return func(ctx context.Context) []any {
+		skew, ok := s.Skew()
+		if !ok {
+			return nil
+		}
+		return []any{slog.Duration(s.key, skew)}
+	}
+}
+
+// Skew 返回最近一次成功查询的偏差，尚未查询成功时 ok 为 false
+func (e *clockSkewEstimator) Skew() (skew time.Duration, ok bool) {
+	if !e.valid.Load() {
+		return 0, false
+	}
+	return time.Duration(e.skew.Load()), true
+}
+
+// run 立即查询一次，之后按间隔查询，查询失败时上报错误并保留上一次的估计
+func (e *clockSkewEstimator) run() {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+	for {
+		if skew, err := queryClockSkew(e.server, e.timeout); err != nil {
+			reportError(fmt.Errorf("failed to query ntp server %s: %w", e.server, err))
+		} else {
+			e.skew.Store(int64(skew))
+			e.valid.Store(true)
+		}
+
+		select {
+		case <-e.done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (e *clockSkewEstimator) stop() {
+	e.stopOnce.Do(func() { close(e.done) })
+}
+
+// queryClockSkew 发送一次 SNTP 请求，按 RFC 4330 计算偏差 ((T2 - T1) + (T3 - T4)) / 2
+func queryClockSkew(server string, timeout time.Duration) (time.Duration, error) {
+	conn, err := net.DialTimeout("udp", server, timeout)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return 0, err
+	}
+
+	// LI = 0，VN = 4，Mode = 3（客户端），发送时间写入 Transmit Timestamp，服务器原样放入 Originate Timestamp
+	request := make([]byte, 48)
+	request[0] = 0<<6 | 4<<3 | 3
+	sent := time.Now()
+	putNTPTime(request[40:48], sent)
+	if _, err := conn.Write(request); err != nil {
+		return 0, err
+	}
+
+	response := make([]byte, 48)
+	n, err := conn.Read(response)
+	received := time.Now()
+	if err != nil {
+		return 0, err
+	}
+	if n < 48 {
+		return 0, fmt.Errorf("short ntp response: %d bytes", n)
+	}
+	if mode := response[0] & 0x7; mode != 4 && mode != 5 {
+		return 0, fmt.Errorf("unexpected ntp mode %d", mode)
+	}
+	if response[1] == 0 {
+		return 0, fmt.Errorf("ntp server sent kiss-of-death %q", response[12:16])
+	}
+	if !bytes.Equal(response[24:32], request[40:48]) {
+		return 0, fmt.Errorf("ntp response does not match request")
+	}
+
+	serverReceived := ntpTime(response[32:40])
+	serverSent := ntpTime(response[40:48])
+	return (serverReceived.Sub(sent) + serverSent.Sub(received)) / 2, nil
+}
+
+// ntpTime 解析 64 位 NTP 时间戳，高 32 位为秒，低 32 位为秒的小数部分
+func ntpTime(b []byte) time.Time {
+	seconds := int64(binary.BigEndian.Uint32(b[0:4])) - ntpEpochOffset
+	fraction := int64(binary.BigEndian.Uint32(b[4:8]))
+	return time.Unix(seconds, fraction*int64(time.Second)>>32)
+}
+
+// putNTPTime 将时间写入 64 位 NTP 时间戳
+func putNTPTime(b []byte, t time.Time) {
+	binary.BigEndian.PutUint32(b[0:4], uint32(t.Unix()+ntpEpochOffset))
+	binary.BigEndian.PutUint32(b[4:8], uint32((int64(t.Nanosecond())<<32)/int64(time.Second)))
+}
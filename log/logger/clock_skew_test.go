@@ -0,0 +1,140 @@
+package logger
+
+import (
+	"encoding/json"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hatlonely/gox/log/writer"
+	"github.com/hatlonely/gox/ref"
+)
+
+// startFakeNTPServer 启动返回 now + offset 的 SNTP 服务器
+func startFakeNTPServer(t *testing.T, offset time.Duration) string {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("ListenPacket() error = %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 48)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if n < 48 {
+				continue
+			}
+			response := make([]byte, 48)
+			response[0] = 0<<6 | 4<<3 | 4
+			response[1] = 2
+			copy(response[24:32], buf[40:48])
+			putNTPTime(response[32:40], time.Now().Add(offset))
+			putNTPTime(response[40:48], time.Now().Add(offset))
+			conn.WriteTo(response, addr)
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func TestQueryClockSkew(t *testing.T) {
+	server := startFakeNTPServer(t, 2*time.Second)
+
+	skew, err := queryClockSkew(server, time.Second)
+	if err != nil {
+		t.Fatalf("queryClockSkew() error = %v", err)
+	}
+	if skew < 2*time.Second-100*time.Millisecond || skew > 2*time.Second+100*time.Millisecond {
+		t.Errorf("skew = %v, want about 2s", skew)
+	}
+}
+
+func TestNTPTime(t *testing.T) {
+	want := time.Date(2024, 5, 1, 12, 30, 45, 123456789, time.UTC)
+	b := make([]byte, 8)
+	putNTPTime(b, want)
+	if got := ntpTime(b); got.Sub(want).Abs() > time.Microsecond {
+		t.Errorf("ntpTime() = %v, want %v", got, want)
+	}
+}
+
+func TestSLogClockSkew(t *testing.T) {
+	server := startFakeNTPServer(t, -time.Second)
+	logFile := t.TempDir() + "/skew.log"
+	logger, err := NewSLogWithOptions(&SLogOptions{
+		Format:       "json",
+		TimeLocation: "UTC",
+		ClockSkew:    &ClockSkewOptions{Server: server, Interval: time.Hour, Timeout: time.Second},
+		Output: &ref.TypeOptions{
+			Namespace: "github.com/hatlonely/gox/log/writer",
+			Type:      "FileWriter",
+			Options:   &writer.FileWriterOptions{Path: logFile},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewSLogWithOptions() error = %v", err)
+	}
+
+	// 等待第一次查询完成
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		logger.WithGroup("request").Info("hello", "path", "/")
+		content, err := os.ReadFile(logFile)
+		if err != nil {
+			t.Fatalf("Failed to read log file: %v", err)
+		}
+		lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+		var record map[string]any
+		if err := json.Unmarshal([]byte(lines[len(lines)-1]), &record); err != nil {
+			t.Fatalf("json.Unmarshal() error = %v", err)
+		}
+		if skew, ok := record["clockSkew"]; ok {
+			if d := time.Duration(skew.(float64)); d > -900*time.Millisecond || d < -1100*time.Millisecond {
+				t.Errorf("clockSkew = %v, want about -1s", d)
+			}
+			if !strings.HasSuffix(record["time"].(string), "Z") {
+				t.Errorf("time = %v, want UTC", record["time"])
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("clockSkew not found in %s", content)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSLogTimeLocation(t *testing.T) {
+	logFile := t.TempDir() + "/location.log"
+	logger, err := NewSLogWithOptions(&SLogOptions{
+		TimeFormat:   "2006-01-02 15:04:05 MST",
+		TimeLocation: "UTC",
+		Output: &ref.TypeOptions{
+			Namespace: "github.com/hatlonely/gox/log/writer",
+			Type:      "FileWriter",
+			Options:   &writer.FileWriterOptions{Path: logFile},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewSLogWithOptions() error = %v", err)
+	}
+	logger.Info("hello")
+
+	content, err := os.ReadFile(logFile)
+	if err != nil {
+		t.Fatalf("Failed to read log file: %v", err)
+	}
+	if !strings.Contains(string(content), ` UTC"`) {
+		t.Errorf("log = %s, want time in UTC", content)
+	}
+
+	if _, err := NewSLogWithOptions(&SLogOptions{TimeLocation: "Invalid/Zone"}); err == nil {
+		t.Error("expected error for invalid time location")
+	}
+}
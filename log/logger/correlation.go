@@ -0,0 +1,19 @@
+package logger
+
+import "context"
+
+// CorrelationIDKey 关联 ID 在日志中的字段名
+const CorrelationIDKey = "correlationId"
+
+type correlationIDContextKey struct{}
+
+// ContextWithCorrelationID 将关联 ID 写入 ctx，之后使用该 ctx 输出的日志都会附加 correlationId 字段
+func ContextWithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationIDContextKey{}, id)
+}
+
+// CorrelationIDFromContext 获取 ctx 中的关联 ID
+func CorrelationIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(correlationIDContextKey{}).(string)
+	return id, ok && id != ""
+}
@@ -0,0 +1,67 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync/atomic"
+)
+
+// ErrorHandler 日志系统内部错误回调
+// 日志系统自身出错时（输出器写入失败、字段序列化失败等）不能再通过日志器上报，
+// 否则可能递归失败，因此通过回调交给使用方处理
+type ErrorHandler func(err error)
+
+var errorHandler atomic.Pointer[ErrorHandler]
+
+// SetErrorHandler 设置日志系统内部错误回调，传入 nil 时恢复默认行为：输出到标准错误
+// 回调可能在任意记录日志的 goroutine 中被并发调用，且不应再调用日志器
+func SetErrorHandler(handler ErrorHandler) {
+	if handler == nil {
+		errorHandler.Store(nil)
+		return
+	}
+	errorHandler.Store(&handler)
+}
+
+// reportError 上报日志系统内部错误
+func reportError(err error) {
+	if handler := errorHandler.Load(); handler != nil {
+		(*handler)(err)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "log: internal error: %v\n", err)
+}
+
+// diagnosticHandler 捕获 handler 返回的错误
+// slog.Logger 会丢弃 Handle 返回的错误，这里统计写入失败而丢弃的日志条数并上报错误
+type diagnosticHandler struct {
+	handler slog.Handler
+	dropped *atomic.Uint64
+}
+
+func newDiagnosticHandler(handler slog.Handler, dropped *atomic.Uint64) *diagnosticHandler {
+	return &diagnosticHandler{handler: handler, dropped: dropped}
+}
+
+func (h *diagnosticHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+func (h *diagnosticHandler) Handle(ctx context.Context, record slog.Record) error {
+	if err := h.handler.Handle(ctx, record); err != nil {
+		dropped := h.dropped.Add(1)
+		reportError(fmt.Errorf("failed to write log record %q, dropped %d records: %w", record.Message, dropped, err))
+		return err
+	}
+	return nil
+}
+
+func (h *diagnosticHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &diagnosticHandler{handler: h.handler.WithAttrs(attrs), dropped: h.dropped}
+}
+
+func (h *diagnosticHandler) WithGroup(name string) slog.Handler {
+	return &diagnosticHandler{handler: h.handler.WithGroup(name), dropped: h.dropped}
+}
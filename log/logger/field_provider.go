@@ -0,0 +1,158 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+)
+
+// FieldProvider 动态字段提供者，在每条日志输出时调用，返回与日志参数相同格式的键值对
+// 用于附加 goroutine 标签、灰度分组等随请求变化的元数据，不需要在每个调用点传入。
+// 不带 context 的日志方法传入的是 context.Background()
+type FieldProvider func(ctx context.Context) []any
+
+var fieldProviders sync.Map
+
+// RegisterFieldProvider 按名称注册动态字段提供者，供 SLogOptions.FieldProviders 引用
+func RegisterFieldProvider(name string, provider FieldProvider) {
+	fieldProviders.Store(name, provider)
+}
+
+// lookupFieldProviders 按名称查找已注册的动态字段提供者
+func lookupFieldProviders(names []string) ([]FieldProvider, error) {
+	providers := make([]FieldProvider, 0, len(names))
+	for _, name := range names {
+		provider, ok := fieldProviders.Load(name)
+		if !ok {
+			return nil, fmt.Errorf("field provider %s is not registered", name)
+		}
+		providers = append(providers, provider.(FieldProvider))
+	}
+	return providers, nil
+}
+
+// fieldProviderHandler 在日志输出时调用动态字段提供者
+// 同一日志器及其 With/WithGroup 派生的日志器共享提供者列表，在根日志器上添加的提供者对派生日志器同样生效
+type fieldProviderHandler struct {
+	handler   slog.Handler
+	providers *atomic.Pointer[[]FieldProvider]
+
+	// 动态字段位于顶层，不受 WithGroup 影响：base 为第一个 WithGroup 之前的 handler，
+	// ops 为之后的 With/WithGroup，有动态字段时在 base 上添加字段后重新应用
+	base slog.Handler
+	ops  []handlerOp
+}
+
+// handlerOp 第一个 WithGroup 之后的 With/WithGroup 操作，group 为空时是 With
+type handlerOp struct {
+	group string
+	attrs []slog.Attr
+}
+
+func newFieldProviderHandler(handler slog.Handler, providers []FieldProvider) *fieldProviderHandler {
+	h := &fieldProviderHandler{handler: handler, base: handler, providers: &atomic.Pointer[[]FieldProvider]{}}
+	h.providers.Store(&providers)
+	return h
+}
+
+// add 追加提供者，写时复制，不影响正在输出的日志
+func (h *fieldProviderHandler) add(providers ...FieldProvider) {
+	for {
+		old := h.providers.Load()
+		merged := make([]FieldProvider, 0, len(*old)+len(providers))
+		merged = append(append(merged, *old...), providers...)
+		if h.providers.CompareAndSwap(old, &merged) {
+			return
+		}
+	}
+}
+
+func (h *fieldProviderHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+// Handle 只为实际输出的日志调用提供者，被级别过滤的日志不会调用
+// ctx 中有关联 ID 时附加 correlationId 字段，不需要注册提供者，与动态字段一样位于顶层
+func (h *fieldProviderHandler) Handle(ctx context.Context, record slog.Record) error {
+	providers := *h.providers.Load()
+	correlationID, hasCorrelationID := CorrelationIDFromContext(ctx)
+	if len(providers) == 0 && !hasCorrelationID {
+		return h.handler.Handle(ctx, record)
+	}
+
+	var fields []any
+	if hasCorrelationID {
+		fields = append(fields, slog.String(CorrelationIDKey, correlationID))
+	}
+	for _, provider := range providers {
+		fields = append(fields, callFieldProvider(ctx, provider)...)
+	}
+	if len(fields) == 0 {
+		return h.handler.Handle(ctx, record)
+	}
+
+	// 没有分组时直接添加到日志记录，否则在分组之外添加后重新应用分组
+	if len(h.ops) == 0 {
+		record = record.Clone()
+		record.Add(fields...)
+		return h.handler.Handle(ctx, record)
+	}
+	return h.topLevel(fields).Handle(ctx, record)
+}
+
+// topLevel 返回在顶层附加了字段的 handler
+func (h *fieldProviderHandler) topLevel(fields []any) slog.Handler {
+	// 借助 slog.Record 解析键值对，与日志参数的处理方式一致
+	var parsed slog.Record
+	parsed.Add(fields...)
+	attrs := make([]slog.Attr, 0, parsed.NumAttrs())
+	parsed.Attrs(func(attr slog.Attr) bool {
+		attrs = append(attrs, attr)
+		return true
+	})
+
+	handler := h.base.WithAttrs(attrs)
+	for _, op := range h.ops {
+		if op.group != "" {
+			handler = handler.WithGroup(op.group)
+		} else {
+			handler = handler.WithAttrs(op.attrs)
+		}
+	}
+	return handler
+}
+
+// callFieldProvider 调用提供者，提供者 panic 时上报错误并忽略其字段，不影响日志输出
+func callFieldProvider(ctx context.Context, provider FieldProvider) (fields []any) {
+	defer func() {
+		if r := recover(); r != nil {
+			reportError(fmt.Errorf("field provider panic: %v", r))
+			fields = nil
+		}
+	}()
+	return provider(ctx)
+}
+
+func (h *fieldProviderHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	derived := &fieldProviderHandler{handler: h.handler.WithAttrs(attrs), providers: h.providers, base: h.base, ops: h.ops}
+	if len(h.ops) == 0 {
+		derived.base = derived.handler
+	} else {
+		derived.ops = append(h.ops[:len(h.ops):len(h.ops)], handlerOp{attrs: attrs})
+	}
+	return derived
+}
+
+func (h *fieldProviderHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &fieldProviderHandler{
+		handler:   h.handler.WithGroup(name),
+		providers: h.providers,
+		base:      h.base,
+		ops:       append(h.ops[:len(h.ops):len(h.ops)], handlerOp{group: name}),
+	}
+}
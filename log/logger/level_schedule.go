@@ -0,0 +1,224 @@
+package logger
+
+import (
+	"fmt"
+	"log/slog"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// LevelScheduleOptions 定时日志级别，在 [Start, End) 时间段内使用 Level 覆盖默认级别
+type LevelScheduleOptions struct {
+	// 开始时间，格式 HH:MM
+	Start string `cfg:"start" validate:"required"`
+
+	// 结束时间，格式 HH:MM，早于开始时间时表示跨过零点，如 23:00-01:00
+	End string `cfg:"end" validate:"required"`
+
+	// 时间段内的日志级别：debug, info, warn, error
+	Level string `cfg:"level" validate:"required,oneof=debug info warn error"`
+
+	// 生效的星期，如 ["sat", "sun"]，为空时每天生效；跨过零点的时间段以开始的那天为准
+	Weekdays []string `cfg:"weekdays"`
+}
+
+type levelSchedule struct {
+	start    time.Duration
+	end      time.Duration
+	level    slog.Level
+	weekdays map[time.Weekday]bool
+}
+
+// scheduledLevel 实现 slog.Leveler，缓存当前生效的级别，在时间段的边界由定时器切换，判断级别时不需要读取时间
+// 多个时间段重叠时使用配置中靠前的时间段，不在任何时间段内时使用默认级别
+type scheduledLevel struct {
+	*levelScheduler
+}
+
+// levelScheduler 计算并切换生效的级别
+// 定时器只引用 levelScheduler，日志器不再使用 scheduledLevel 后定时器随之停止
+type levelScheduler struct {
+	level     slog.Level
+	schedules []*levelSchedule
+	location  *time.Location
+	now       func() time.Time
+
+	current atomic.Int64
+	mu      sync.Mutex
+	timer   *time.Timer
+	stopped bool
+}
+
+// levelRefreshInterval 两次切换检查的最长间隔，系统时间被调整时最多一个间隔后纠正
+const levelRefreshInterval = time.Hour
+
+func newScheduledLevel(level slog.Level, options []*LevelScheduleOptions, timezone string) (*scheduledLevel, error) {
+	location := time.Local
+	if timezone != "" {
+		var err error
+		if location, err = time.LoadLocation(timezone); err != nil {
+			return nil, fmt.Errorf("invalid timezone %s: %w", timezone, err)
+		}
+	}
+
+	schedules := make([]*levelSchedule, 0, len(options))
+	for i, opt := range options {
+		schedule, err := newLevelSchedule(opt)
+		if err != nil {
+			return nil, fmt.Errorf("invalid level schedule [%d]: %w", i, err)
+		}
+		schedules = append(schedules, schedule)
+	}
+
+	scheduler := &levelScheduler{level: level, schedules: schedules, location: location, now: time.Now}
+	scheduler.refresh()
+	l := &scheduledLevel{levelScheduler: scheduler}
+	runtime.AddCleanup(l, (*levelScheduler).stop, scheduler)
+	return l, nil
+}
+
+func newLevelSchedule(options *LevelScheduleOptions) (*levelSchedule, error) {
+	if options == nil {
+		return nil, fmt.Errorf("options cannot be nil")
+	}
+	start, err := parseClock(options.Start)
+	if err != nil {
+		return nil, err
+	}
+	end, err := parseClock(options.End)
+	if err != nil {
+		return nil, err
+	}
+	if start == end {
+		return nil, fmt.Errorf("start and end cannot be the same: %s", options.Start)
+	}
+	level, err := parseLevel(options.Level)
+	if err != nil {
+		return nil, err
+	}
+
+	var weekdays map[time.Weekday]bool
+	if len(options.Weekdays) > 0 {
+		weekdays = make(map[time.Weekday]bool, len(options.Weekdays))
+		for _, name := range options.Weekdays {
+			weekday, err := parseWeekday(name)
+			if err != nil {
+				return nil, err
+			}
+			weekdays[weekday] = true
+		}
+	}
+
+	return &levelSchedule{start: start, end: end, level: level, weekdays: weekdays}, nil
+}
+
+// parseClock 解析 HH:MM 格式的时间，返回距零点的时长
+func parseClock(clock string) (time.Duration, error) {
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", clock)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+func parseWeekday(name string) (time.Weekday, error) {
+	switch strings.ToLower(name) {
+	case "sun", "sunday":
+		return time.Sunday, nil
+	case "mon", "monday":
+		return time.Monday, nil
+	case "tue", "tuesday":
+		return time.Tuesday, nil
+	case "wed", "wednesday":
+		return time.Wednesday, nil
+	case "thu", "thursday":
+		return time.Thursday, nil
+	case "fri", "friday":
+		return time.Friday, nil
+	case "sat", "saturday":
+		return time.Saturday, nil
+	default:
+		return time.Sunday, fmt.Errorf("unknown weekday: %s", name)
+	}
+}
+
+// contains 判断 t 是否在时间段内，t 已转换到配置的时区
+func (s *levelSchedule) contains(t time.Time) bool {
+	clock := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second + time.Duration(t.Nanosecond())
+	weekday := t.Weekday()
+
+	if s.start < s.end {
+		return clock >= s.start && clock < s.end && s.matchWeekday(weekday)
+	}
+	// 跨过零点：零点前算当天开始，零点后算前一天开始
+	if clock >= s.start {
+		return s.matchWeekday(weekday)
+	}
+	return clock < s.end && s.matchWeekday((weekday+6)%7)
+}
+
+func (s *levelSchedule) matchWeekday(weekday time.Weekday) bool {
+	return s.weekdays == nil || s.weekdays[weekday]
+}
+
+func (l *scheduledLevel) Level() slog.Level {
+	return slog.Level(l.current.Load())
+}
+
+// refresh 按当前时间更新生效的级别，并在下一个时间段边界再次更新
+func (s *levelScheduler) refresh() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopped {
+		return
+	}
+
+	now := s.now().In(s.location)
+	s.current.Store(int64(s.levelAt(now)))
+
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+	s.timer = time.AfterFunc(min(s.nextBoundary(now).Sub(now), levelRefreshInterval), s.refresh)
+}
+
+func (s *levelScheduler) stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stopped = true
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+}
+
+// levelAt 返回 now 时生效的级别
+func (s *levelScheduler) levelAt(now time.Time) slog.Level {
+	for _, schedule := range s.schedules {
+		if schedule.contains(now) {
+			return schedule.level
+		}
+	}
+	return s.level
+}
+
+// nextBoundary 返回 now 之后最近的时间段开始或结束时间，级别只会在这些时间变化
+func (s *levelScheduler) nextBoundary(now time.Time) time.Time {
+	var next time.Time
+	for _, schedule := range s.schedules {
+		for _, clock := range []time.Duration{schedule.start, schedule.end} {
+			hour, minute := int(clock/time.Hour), int(clock%time.Hour/time.Minute)
+			t := time.Date(now.Year(), now.Month(), now.Day(), hour, minute, 0, 0, s.location)
+			if !t.After(now) {
+				t = time.Date(now.Year(), now.Month(), now.Day()+1, hour, minute, 0, 0, s.location)
+			}
+			if next.IsZero() || t.Before(next) {
+				next = t
+			}
+		}
+	}
+	return next
+}
@@ -0,0 +1,120 @@
+package logger
+
+import (
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestScheduledLevel(t *testing.T) {
+	level, err := newScheduledLevel(slog.LevelInfo, []*LevelScheduleOptions{
+		{Start: "02:00", End: "03:00", Level: "debug"},
+		{Start: "23:00", End: "01:00", Level: "error", Weekdays: []string{"fri"}},
+		{Start: "02:00", End: "04:00", Level: "warn"},
+	}, "UTC")
+	if err != nil {
+		t.Fatalf("newScheduledLevel failed: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		now  time.Time
+		want slog.Level
+	}{
+		{"before window", time.Date(2024, 1, 1, 1, 59, 59, 0, time.UTC), slog.LevelInfo},
+		{"window start", time.Date(2024, 1, 1, 2, 0, 0, 0, time.UTC), slog.LevelDebug},
+		{"window end is exclusive", time.Date(2024, 1, 1, 3, 0, 0, 0, time.UTC), slog.LevelWarn},
+		{"after all windows", time.Date(2024, 1, 1, 4, 0, 0, 0, time.UTC), slog.LevelInfo},
+		{"friday night", time.Date(2024, 1, 5, 23, 30, 0, 0, time.UTC), slog.LevelError},
+		{"after midnight counts as friday", time.Date(2024, 1, 6, 0, 30, 0, 0, time.UTC), slog.LevelError},
+		{"thursday night", time.Date(2024, 1, 4, 23, 30, 0, 0, time.UTC), slog.LevelInfo},
+		{"after midnight on friday", time.Date(2024, 1, 5, 0, 30, 0, 0, time.UTC), slog.LevelInfo},
+		{"converted to timezone", time.Date(2024, 1, 1, 10, 30, 0, 0, time.FixedZone("UTC+8", 8*3600)), slog.LevelDebug},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			level.now = func() time.Time { return tt.now }
+			level.refresh()
+			if got := level.Level(); got != tt.want {
+				t.Errorf("Level() at %v = %v, want %v", tt.now, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScheduledLevelTimer(t *testing.T) {
+	level, err := newScheduledLevel(slog.LevelInfo, []*LevelScheduleOptions{
+		{Start: "02:00", End: "03:00", Level: "debug"},
+		{Start: "23:00", End: "01:00", Level: "error"},
+	}, "UTC")
+	if err != nil {
+		t.Fatalf("newScheduledLevel failed: %v", err)
+	}
+	defer level.stop()
+
+	boundaries := []struct {
+		now  time.Time
+		want time.Time
+	}{
+		{time.Date(2024, 1, 1, 1, 30, 0, 0, time.UTC), time.Date(2024, 1, 1, 2, 0, 0, 0, time.UTC)},
+		{time.Date(2024, 1, 1, 2, 0, 0, 0, time.UTC), time.Date(2024, 1, 1, 3, 0, 0, 0, time.UTC)},
+		{time.Date(2024, 1, 1, 23, 30, 0, 0, time.UTC), time.Date(2024, 1, 2, 1, 0, 0, 0, time.UTC)},
+	}
+	for _, b := range boundaries {
+		if got := level.nextBoundary(b.now); !got.Equal(b.want) {
+			t.Errorf("nextBoundary(%v) = %v, want %v", b.now, got, b.want)
+		}
+	}
+
+	// 到达边界时由定时器切换级别
+	var mu sync.Mutex
+	now := time.Date(2024, 1, 1, 1, 59, 59, 950*int(time.Millisecond), time.UTC)
+	level.now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+	level.refresh()
+	if got := level.Level(); got != slog.LevelInfo {
+		t.Fatalf("Level() = %v, want %v", got, slog.LevelInfo)
+	}
+	mu.Lock()
+	now = time.Date(2024, 1, 1, 2, 0, 0, 0, time.UTC)
+	mu.Unlock()
+
+	deadline := time.Now().Add(time.Second)
+	for level.Level() != slog.LevelDebug && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := level.Level(); got != slog.LevelDebug {
+		t.Errorf("Level() after boundary = %v, want %v", got, slog.LevelDebug)
+	}
+}
+
+func TestScheduledLevelInvalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		schedule *LevelScheduleOptions
+		timezone string
+	}{
+		{"invalid start", &LevelScheduleOptions{Start: "25:00", End: "03:00", Level: "debug"}, ""},
+		{"same start and end", &LevelScheduleOptions{Start: "02:00", End: "02:00", Level: "debug"}, ""},
+		{"invalid level", &LevelScheduleOptions{Start: "02:00", End: "03:00", Level: "trace"}, ""},
+		{"invalid weekday", &LevelScheduleOptions{Start: "02:00", End: "03:00", Level: "debug", Weekdays: []string{"someday"}}, ""},
+		{"invalid timezone", &LevelScheduleOptions{Start: "02:00", End: "03:00", Level: "debug"}, "Mars/Olympus"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewSLogWithOptions(&SLogOptions{
+				LevelSchedules: []*LevelScheduleOptions{tt.schedule},
+				Timezone:       tt.timezone,
+			})
+			if err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
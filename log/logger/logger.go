@@ -35,7 +35,7 @@ func NewLoggerWithOptions(options *ref.TypeOptions) (Logger, error) {
 	if options == nil {
 		return nil, errors.New("options cannot be nil")
 	}
-	logger, err := ref.New(options.Namespace, options.Type, options.Options)
+	logger, err := ref.NewWithOptions(options)
 	if err != nil {
 		return nil, errors.WithMessage(err, "refx.NewT failed")
 	}
@@ -0,0 +1,310 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Record 处理器看到的日志记录，Attrs 包含 With 添加的字段、动态字段和日志参数，WithGroup 的分组展开为嵌套的 slog.Group
+type Record struct {
+	Time    time.Time
+	Level   slog.Level
+	Message string
+	Attrs   []slog.Attr
+}
+
+// Processor 日志处理器，在格式化之前按配置顺序执行，用于重命名、删除字段或附加主机元数据等
+// 处理器不能修改传入的 Attrs 切片，需要修改时返回新的切片
+type Processor func(record Record) Record
+
+// ProcessorOptions 处理器配置
+type ProcessorOptions struct {
+	// 处理器类型：rename, drop, lowercaseLevel, host, k8s，其他名称需要先通过 RegisterProcessor 注册
+	Type string `cfg:"type" validate:"required"`
+
+	// rename 的字段名映射，旧字段名 -> 新字段名，嵌套字段用 . 分隔，如 http.status，只替换最后一级的名称
+	// 旧字段名为 time、level、msg、source 时重命名内置字段
+	Mapping map[string]string `cfg:"mapping"`
+
+	// drop 删除的字段，嵌套字段用 . 分隔
+	Keys []string `cfg:"keys"`
+
+	// host、k8s 附加元数据的分组名，默认为 host、k8s
+	Key string `cfg:"key"`
+}
+
+var processors sync.Map
+
+// RegisterProcessor 按名称注册处理器，供 ProcessorOptions.Type 引用
+func RegisterProcessor(name string, processor Processor) {
+	processors.Store(name, processor)
+}
+
+// replaceAttrFunc 与 slog.HandlerOptions.ReplaceAttr 相同，用于处理格式化时才生成的内置字段
+type replaceAttrFunc func(groups []string, a slog.Attr) slog.Attr
+
+// newProcessors 按配置创建处理器，内置字段的重命名和级别小写在格式化时处理，通过 replaceAttrs 返回
+func newProcessors(options []*ProcessorOptions) ([]Processor, []replaceAttrFunc, error) {
+	var result []Processor
+	var replaceAttrs []replaceAttrFunc
+	for _, opts := range options {
+		if opts == nil {
+			continue
+		}
+		switch opts.Type {
+		case "rename":
+			result = append(result, RenameKeys(opts.Mapping))
+			if replace := renameBuiltinKeys(opts.Mapping); replace != nil {
+				replaceAttrs = append(replaceAttrs, replace)
+			}
+		case "drop":
+			result = append(result, DropKeys(opts.Keys...))
+		case "lowercaseLevel":
+			replaceAttrs = append(replaceAttrs, lowercaseLevel)
+		case "host":
+			result = append(result, HostMetadata(opts.Key))
+		case "k8s":
+			result = append(result, K8sMetadata(opts.Key))
+		default:
+			processor, ok := processors.Load(opts.Type)
+			if !ok {
+				return nil, nil, fmt.Errorf("processor %s is not registered", opts.Type)
+			}
+			result = append(result, processor.(Processor))
+		}
+	}
+	return result, replaceAttrs, nil
+}
+
+// RenameKeys 重命名字段，mapping 为旧字段名到新字段名的映射，嵌套字段用 . 分隔，只替换最后一级的名称
+func RenameKeys(mapping map[string]string) Processor {
+	return func(record Record) Record {
+		for path, name := range mapping {
+			record.Attrs = updateAttrs(record.Attrs, strings.Split(path, "."), func(a slog.Attr) (slog.Attr, bool) {
+				a.Key = name
+				return a, true
+			})
+		}
+		return record
+	}
+}
+
+// DropKeys 删除字段，嵌套字段用 . 分隔，删除分组时删除分组内的所有字段
+func DropKeys(keys ...string) Processor {
+	return func(record Record) Record {
+		for _, key := range keys {
+			record.Attrs = updateAttrs(record.Attrs, strings.Split(key, "."), func(a slog.Attr) (slog.Attr, bool) {
+				return a, false
+			})
+		}
+		return record
+	}
+}
+
+// HostMetadata 在分组 key 下附加主机名，key 为空时为 host，主机名在创建时获取一次
+func HostMetadata(key string) Processor {
+	if key == "" {
+		key = "host"
+	}
+	var attrs []slog.Attr
+	if hostname, err := os.Hostname(); err == nil {
+		attrs = append(attrs, slog.String("name", hostname))
+	}
+	return metadata(key, attrs)
+}
+
+// k8sNamespaceFile 容器内 service account 挂载的命名空间文件
+var k8sNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+// K8sMetadata 在分组 key 下附加 Pod 名称、命名空间和节点，key 为空时为 k8s
+// 从 Downward API 注入的环境变量 POD_NAME、POD_NAMESPACE、NODE_NAME 读取，
+// 未注入时 Pod 名称使用 HOSTNAME，命名空间读取 service account 挂载的文件，不在 Kubernetes 中运行时不附加
+func K8sMetadata(key string) Processor {
+	if key == "" {
+		key = "k8s"
+	}
+	if os.Getenv("KUBERNETES_SERVICE_HOST") == "" && os.Getenv("POD_NAME") == "" {
+		return metadata(key, nil)
+	}
+
+	pod := os.Getenv("POD_NAME")
+	if pod == "" {
+		pod = os.Getenv("HOSTNAME")
+	}
+	namespace := os.Getenv("POD_NAMESPACE")
+	if namespace == "" {
+		if data, err := os.ReadFile(k8sNamespaceFile); err == nil {
+			namespace = strings.TrimSpace(string(data))
+		}
+	}
+	var attrs []slog.Attr
+	for _, attr := range []slog.Attr{
+		slog.String("pod", pod),
+		slog.String("namespace", namespace),
+		slog.String("node", os.Getenv("NODE_NAME")),
+	} {
+		if attr.Value.String() != "" {
+			attrs = append(attrs, attr)
+		}
+	}
+	return metadata(key, attrs)
+}
+
+// metadata 在顶层附加固定的分组，没有字段时不附加
+func metadata(key string, attrs []slog.Attr) Processor {
+	if len(attrs) == 0 {
+		return func(record Record) Record { return record }
+	}
+	group := slog.Attr{Key: key, Value: slog.GroupValue(attrs...)}
+	return func(record Record) Record {
+		record.Attrs = append(slices.Clip(record.Attrs), group)
+		return record
+	}
+}
+
+// updateAttrs 按路径查找字段并替换，fn 返回 false 时删除字段，返回新的切片，不修改传入的切片
+func updateAttrs(attrs []slog.Attr, path []string, fn func(slog.Attr) (slog.Attr, bool)) []slog.Attr {
+	result := make([]slog.Attr, 0, len(attrs))
+	for _, attr := range attrs {
+		switch {
+		case attr.Key != path[0]:
+		case len(path) > 1:
+			if attr.Value.Kind() == slog.KindGroup {
+				attr.Value = slog.GroupValue(updateAttrs(attr.Value.Group(), path[1:], fn)...)
+			}
+		default:
+			var ok bool
+			if attr, ok = fn(attr); !ok {
+				continue
+			}
+		}
+		result = append(result, attr)
+	}
+	return result
+}
+
+// renameBuiltinKeys 重命名格式化时生成的内置字段
+func renameBuiltinKeys(mapping map[string]string) replaceAttrFunc {
+	builtin := map[string]string{}
+	for _, key := range []string{slog.TimeKey, slog.LevelKey, slog.MessageKey, slog.SourceKey} {
+		if name, ok := mapping[key]; ok {
+			builtin[key] = name
+		}
+	}
+	if len(builtin) == 0 {
+		return nil
+	}
+	return func(groups []string, a slog.Attr) slog.Attr {
+		if name, ok := builtin[a.Key]; ok && len(groups) == 0 {
+			a.Key = name
+		}
+		return a
+	}
+}
+
+// lowercaseLevel 以小写输出日志级别，按值的类型识别，在 rename 重命名内置字段之后同样生效
+func lowercaseLevel(groups []string, a slog.Attr) slog.Attr {
+	if level, ok := a.Value.Any().(slog.Level); ok && len(groups) == 0 {
+		a.Value = slog.StringValue(strings.ToLower(level.String()))
+	}
+	return a
+}
+
+// processorHandler 在格式化之前执行处理器
+// 自行保存 With/WithGroup 的字段和分组，输出时与日志参数合并为完整的字段列表交给处理器，底层 handler 不带字段和分组
+type processorHandler struct {
+	handler    slog.Handler
+	processors []Processor
+	attrs      []slog.Attr
+	groups     []processorGroup
+}
+
+// processorGroup WithGroup 的分组及之后通过 With 添加的字段
+type processorGroup struct {
+	name  string
+	attrs []slog.Attr
+}
+
+func newProcessorHandler(handler slog.Handler, processors []Processor) *processorHandler {
+	return &processorHandler{handler: handler, processors: processors}
+}
+
+func (h *processorHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+func (h *processorHandler) Handle(ctx context.Context, record slog.Record) error {
+	attrs := make([]slog.Attr, 0, record.NumAttrs())
+	record.Attrs(func(attr slog.Attr) bool {
+		attrs = append(attrs, attr)
+		return true
+	})
+	for i := len(h.groups) - 1; i >= 0; i-- {
+		group := h.groups[i]
+		attrs = []slog.Attr{{Key: group.name, Value: slog.GroupValue(append(slices.Clip(group.attrs), attrs...)...)}}
+	}
+	attrs = resolveAttrs(append(slices.Clip(h.attrs), attrs...))
+
+	processed := Record{Time: record.Time, Level: record.Level, Message: record.Message, Attrs: attrs}
+	for _, processor := range h.processors {
+		processed = callProcessor(processor, processed)
+	}
+
+	out := slog.NewRecord(processed.Time, processed.Level, processed.Message, record.PC)
+	out.AddAttrs(processed.Attrs...)
+	return h.handler.Handle(ctx, out)
+}
+
+// callProcessor 调用处理器，处理器 panic 时上报错误并跳过该处理器，不影响日志输出
+func callProcessor(processor Processor, record Record) (result Record) {
+	defer func() {
+		if r := recover(); r != nil {
+			reportError(fmt.Errorf("log processor panic: %v", r))
+			result = record
+		}
+	}()
+	return processor(record)
+}
+
+// resolveAttrs 解析 slog.LogValuer，处理器看到的是最终输出的值，返回新的切片
+func resolveAttrs(attrs []slog.Attr) []slog.Attr {
+	result := make([]slog.Attr, len(attrs))
+	for i, attr := range attrs {
+		attr.Value = attr.Value.Resolve()
+		if attr.Value.Kind() == slog.KindGroup {
+			attr.Value = slog.GroupValue(resolveAttrs(attr.Value.Group())...)
+		}
+		result[i] = attr
+	}
+	return result
+}
+
+func (h *processorHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	derived := &processorHandler{handler: h.handler, processors: h.processors, attrs: h.attrs, groups: h.groups}
+	if len(h.groups) == 0 {
+		derived.attrs = append(slices.Clip(h.attrs), attrs...)
+		return derived
+	}
+	derived.groups = slices.Clone(h.groups)
+	last := &derived.groups[len(derived.groups)-1]
+	last.attrs = append(slices.Clip(last.attrs), attrs...)
+	return derived
+}
+
+func (h *processorHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &processorHandler{
+		handler:    h.handler,
+		processors: h.processors,
+		attrs:      h.attrs,
+		groups:     append(slices.Clip(h.groups), processorGroup{name: name}),
+	}
+}
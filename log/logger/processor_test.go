@@ -0,0 +1,157 @@
+package logger
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hatlonely/gox/log/writer"
+	"github.com/hatlonely/gox/ref"
+)
+
+func newProcessorTestLogger(t *testing.T, processors ...*ProcessorOptions) (*SLog, string) {
+	t.Helper()
+	logFile := filepath.Join(t.TempDir(), "processor.log")
+	logger, err := NewSLogWithOptions(&SLogOptions{
+		Level:      "info",
+		Format:     "json",
+		Processors: processors,
+		Output: &ref.TypeOptions{
+			Namespace: "github.com/hatlonely/gox/log/writer",
+			Type:      "FileWriter",
+			Options:   &writer.FileWriterOptions{Path: logFile},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewSLogWithOptions() error = %v", err)
+	}
+	return logger, logFile
+}
+
+func readJSONLines(t *testing.T, logFile string) []map[string]any {
+	t.Helper()
+	content, err := os.ReadFile(logFile)
+	if err != nil {
+		t.Fatalf("Failed to read log file: %v", err)
+	}
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(string(content)), "\n") {
+		var record map[string]any
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("invalid json line %s: %v", line, err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestSLogProcessors(t *testing.T) {
+	logger, logFile := newProcessorTestLogger(t,
+		&ProcessorOptions{Type: "rename", Mapping: map[string]string{"msg": "message", "level": "severity", "req.status": "code"}},
+		&ProcessorOptions{Type: "drop", Keys: []string{"password", "req.headers"}},
+		&ProcessorOptions{Type: "lowercaseLevel"},
+		&ProcessorOptions{Type: "host"},
+	)
+
+	// With 和 WithGroup 的字段同样经过处理器
+	logger.With("password", "secret", "user", "tom").WithGroup("req").With("headers", "x").
+		Info("request done", "status", 200, "path", "/")
+	logger.Debug("filtered")
+
+	records := readJSONLines(t, logFile)
+	if len(records) != 1 {
+		t.Fatalf("expected 1 record, got %d", len(records))
+	}
+	record := records[0]
+	if record["message"] != "request done" || record["severity"] != "info" {
+		t.Errorf("unexpected builtin fields: %v", record)
+	}
+	if _, ok := record["msg"]; ok {
+		t.Errorf("expected msg renamed: %v", record)
+	}
+	if _, ok := record["password"]; ok || record["user"] != "tom" {
+		t.Errorf("unexpected top level fields: %v", record)
+	}
+	if want := map[string]any{"code": float64(200), "path": "/"}; !reflect.DeepEqual(record["req"], want) {
+		t.Errorf("req = %v, want %v", record["req"], want)
+	}
+	hostname, _ := os.Hostname()
+	if want := map[string]any{"name": hostname}; !reflect.DeepEqual(record["host"], want) {
+		t.Errorf("host = %v, want %v", record["host"], want)
+	}
+}
+
+func TestSLogRegisteredProcessor(t *testing.T) {
+	RegisterProcessor("maskUser", func(record Record) Record {
+		record.Attrs = updateAttrs(record.Attrs, []string{"user"}, func(a slog.Attr) (slog.Attr, bool) {
+			return slog.String(a.Key, "***"), true
+		})
+		record.Message = strings.ToUpper(record.Message)
+		return record
+	})
+	RegisterProcessor("panic", func(record Record) Record {
+		panic("boom")
+	})
+
+	var reported []error
+	SetErrorHandler(func(err error) {
+		reported = append(reported, err)
+	})
+	defer SetErrorHandler(nil)
+
+	logger, logFile := newProcessorTestLogger(t, &ProcessorOptions{Type: "panic"}, &ProcessorOptions{Type: "maskUser"})
+	logger.AddFieldProvider(func(ctx context.Context) []any {
+		return []any{"user", "from-provider"}
+	})
+	logger.Info("login", "id", 1)
+
+	records := readJSONLines(t, logFile)
+	if records[0]["msg"] != "LOGIN" || records[0]["user"] != "***" || records[0]["id"] != float64(1) {
+		t.Errorf("unexpected record: %v", records[0])
+	}
+	if len(reported) != 1 || !strings.Contains(reported[0].Error(), "boom") {
+		t.Errorf("unexpected reported errors: %v", reported)
+	}
+
+	if _, err := NewSLogWithOptions(&SLogOptions{Processors: []*ProcessorOptions{{Type: "not_exists"}}}); err == nil {
+		t.Error("expected error for unregistered processor")
+	}
+}
+
+func TestK8sMetadata(t *testing.T) {
+	t.Setenv("KUBERNETES_SERVICE_HOST", "10.0.0.1")
+	t.Setenv("POD_NAME", "api-7d9f")
+	t.Setenv("POD_NAMESPACE", "")
+	t.Setenv("NODE_NAME", "node-1")
+	namespaceFile := filepath.Join(t.TempDir(), "namespace")
+	if err := os.WriteFile(namespaceFile, []byte("prod\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	old := k8sNamespaceFile
+	k8sNamespaceFile = namespaceFile
+	defer func() { k8sNamespaceFile = old }()
+
+	record := K8sMetadata("")(Record{Attrs: []slog.Attr{slog.Int("id", 1)}})
+	if len(record.Attrs) != 2 || record.Attrs[1].Key != "k8s" {
+		t.Fatalf("unexpected attrs: %v", record.Attrs)
+	}
+	got := map[string]string{}
+	for _, attr := range record.Attrs[1].Value.Group() {
+		got[attr.Key] = attr.Value.String()
+	}
+	if want := map[string]string{"pod": "api-7d9f", "namespace": "prod", "node": "node-1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("k8s = %v, want %v", got, want)
+	}
+
+	// 不在 Kubernetes 中运行时不附加
+	t.Setenv("KUBERNETES_SERVICE_HOST", "")
+	t.Setenv("POD_NAME", "")
+	if record := K8sMetadata("")(Record{}); len(record.Attrs) != 0 {
+		t.Errorf("expected no attrs, got %v", record.Attrs)
+	}
+}
@@ -0,0 +1,39 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+)
+
+// sequenceHandler 为每条日志附加单调递增的序列号
+// 同一日志器及其 With/WithGroup 派生的日志器共享同一个计数器，
+// 下游可以根据序列号是否连续检测日志丢失和乱序
+type sequenceHandler struct {
+	handler slog.Handler
+	key     string
+	seq     *atomic.Uint64
+}
+
+func newSequenceHandler(handler slog.Handler, key string) *sequenceHandler {
+	return &sequenceHandler{handler: handler, key: key, seq: &atomic.Uint64{}}
+}
+
+func (h *sequenceHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+// Handle 只为实际输出的日志分配序列号，被级别过滤的日志不占用序列号
+func (h *sequenceHandler) Handle(ctx context.Context, record slog.Record) error {
+	record = record.Clone()
+	record.AddAttrs(slog.Uint64(h.key, h.seq.Add(1)))
+	return h.handler.Handle(ctx, record)
+}
+
+func (h *sequenceHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &sequenceHandler{handler: h.handler.WithAttrs(attrs), key: h.key, seq: h.seq}
+}
+
+func (h *sequenceHandler) WithGroup(name string) slog.Handler {
+	return &sequenceHandler{handler: h.handler.WithGroup(name), key: h.key, seq: h.seq}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log/slog"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/hatlonely/gox/log/writer"
@@ -25,15 +26,41 @@ type SLogOptions struct {
 	// 时间格式
 	TimeFormat string `cfg:"timeFormat"`
 
+	// 输出时间使用的时区，如 UTC、Asia/Shanghai，设置后不受主机 TZ 影响，默认为本地时区
+	TimeLocation string `cfg:"timeLocation"`
+
+	// 时钟偏差估计，设置后为每条日志附加本机时钟与 NTP 服务器的偏差
+	ClockSkew *ClockSkewOptions `cfg:"clockSkew"`
+
 	// 是否显示调用者信息
 	AddSource bool `cfg:"addSource"`
 
 	// 自定义字段
 	Fields map[string]any `cfg:"fields"`
+
+	// 是否为每条日志附加单调递增的序列号，用于下游检测日志丢失和乱序
+	Sequence bool `cfg:"sequence"`
+
+	// 序列号字段名
+	SequenceKey string `cfg:"sequenceKey" def:"seq"`
+
+	// 动态字段提供者名称，需要先通过 RegisterFieldProvider 注册
+	FieldProviders []string `cfg:"fieldProviders"`
+
+	// 处理器，在格式化之前按顺序执行，用于重命名、删除字段或附加主机元数据
+	Processors []*ProcessorOptions `cfg:"processors"`
+
+	// 定时日志级别，如夜间批处理期间临时开启 debug，时间段外自动恢复为 Level
+	LevelSchedules []*LevelScheduleOptions `cfg:"levelSchedules"`
+
+	// 定时日志级别使用的时区，如 Asia/Shanghai，默认为本地时区
+	Timezone string `cfg:"timezone"`
 }
 
 type SLog struct {
-	slogger *slog.Logger
+	slogger   *slog.Logger
+	dropped   *atomic.Uint64
+	providers *fieldProviderHandler
 }
 
 func NewSLogWithOptions(options *SLogOptions) (*SLog, error) {
@@ -51,12 +78,45 @@ func NewSLogWithOptions(options *SLogOptions) (*SLog, error) {
 	if options.TimeFormat == "" {
 		options.TimeFormat = time.RFC3339
 	}
+	if options.SequenceKey == "" {
+		options.SequenceKey = "seq"
+	}
 
 	// 解析日志级别
 	level, err := parseLevel(options.Level)
 	if err != nil {
 		return nil, fmt.Errorf("invalid log level: %w", err)
 	}
+	var leveler slog.Leveler = level
+	if len(options.LevelSchedules) > 0 {
+		if leveler, err = newScheduledLevel(level, options.LevelSchedules, options.Timezone); err != nil {
+			return nil, err
+		}
+	}
+
+	location := time.Local
+	if options.TimeLocation != "" {
+		if location, err = time.LoadLocation(options.TimeLocation); err != nil {
+			return nil, fmt.Errorf("invalid time location %s: %w", options.TimeLocation, err)
+		}
+	}
+
+	providers, err := lookupFieldProviders(options.FieldProviders)
+	if err != nil {
+		return nil, err
+	}
+	if options.ClockSkew != nil {
+		skew, err := newClockSkew(options.ClockSkew)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create clock skew estimator: %w", err)
+		}
+		providers = append([]FieldProvider{skew.fieldProvider()}, providers...)
+	}
+
+	processors, replaceAttrs, err := newProcessors(options.Processors)
+	if err != nil {
+		return nil, err
+	}
 
 	// 创建输出器
 	w, err := writer.NewWriterWithOptions(options.Output)
@@ -67,20 +127,32 @@ func NewSLogWithOptions(options *SLogOptions) (*SLog, error) {
 	// 创建 handler
 	var handler slog.Handler
 	handlerOpts := &slog.HandlerOptions{
-		Level:     level,
+		Level:     leveler,
 		AddSource: options.AddSource,
 	}
 
-	// 自定义时间格式
-	if options.TimeFormat != time.RFC3339 {
-		handlerOpts.ReplaceAttr = func(groups []string, a slog.Attr) slog.Attr {
+	// 自定义时间格式和时区
+	if options.TimeFormat != time.RFC3339 || options.TimeLocation != "" {
+		replaceAttrs = append([]replaceAttrFunc{func(groups []string, a slog.Attr) slog.Attr {
 			if a.Key == slog.TimeKey && len(groups) == 0 {
+				t := a.Value.Time().In(location)
+				if options.TimeFormat == time.RFC3339 {
+					return slog.Time(a.Key, t)
+				}
 				return slog.Attr{
 					Key:   a.Key,
-					Value: slog.StringValue(a.Value.Time().Format(options.TimeFormat)),
+					Value: slog.StringValue(t.Format(options.TimeFormat)),
 				}
 			}
 			return a
+		}}, replaceAttrs...)
+	}
+	if len(replaceAttrs) > 0 {
+		handlerOpts.ReplaceAttr = func(groups []string, a slog.Attr) slog.Attr {
+			for _, replace := range replaceAttrs {
+				a = replace(groups, a)
+			}
+			return a
 		}
 	}
 
@@ -94,6 +166,23 @@ func NewSLogWithOptions(options *SLogOptions) (*SLog, error) {
 		return nil, fmt.Errorf("unsupported format: %s", options.Format)
 	}
 
+	// 处理器位于格式化之前，能看到序列号、动态字段和 With 添加的所有字段
+	if len(processors) > 0 {
+		handler = newProcessorHandler(handler, processors)
+	}
+
+	// 附加序列号
+	if options.Sequence {
+		handler = newSequenceHandler(handler, options.SequenceKey)
+	}
+
+	// 动态字段
+	fieldProviderHandler := newFieldProviderHandler(handler, providers)
+
+	// 上报写入失败，避免日志被静默丢弃
+	dropped := &atomic.Uint64{}
+	handler = newDiagnosticHandler(fieldProviderHandler, dropped)
+
 	// 创建 logger
 	slogger := slog.New(handler)
 
@@ -106,7 +195,7 @@ func NewSLogWithOptions(options *SLogOptions) (*SLog, error) {
 		slogger = slogger.With(args...)
 	}
 
-	return &SLog{slogger: slogger}, nil
+	return &SLog{slogger: slogger, dropped: dropped, providers: fieldProviderHandler}, nil
 }
 
 func parseLevel(level string) (slog.Level, error) {
@@ -157,9 +246,26 @@ func (l *SLog) ErrorContext(ctx context.Context, msg string, args ...any) {
 }
 
 func (l *SLog) With(args ...any) Logger {
-	return &SLog{slogger: l.slogger.With(args...)}
+	return &SLog{slogger: l.slogger.With(args...), dropped: l.dropped, providers: l.providers}
 }
 
 func (l *SLog) WithGroup(name string) Logger {
-	return &SLog{slogger: l.slogger.WithGroup(name)}
+	return &SLog{slogger: l.slogger.WithGroup(name), dropped: l.dropped, providers: l.providers}
+}
+
+// Handler 返回底层的 slog.Handler，包含 With/WithGroup 添加的字段，用于直接写入构造好的 slog.Record，
+// 如按原始时间补写缓存的日志；直接调用 Handle 不经过级别过滤
+func (l *SLog) Handler() slog.Handler {
+	return l.slogger.Handler()
+}
+
+// AddFieldProvider 添加动态字段提供者，同一日志器及其 With/WithGroup 派生的日志器共享提供者列表
+// 在 WithGroup 派生的日志器上输出时，动态字段位于顶层，不在分组内
+func (l *SLog) AddFieldProvider(providers ...FieldProvider) {
+	l.providers.add(providers...)
+}
+
+// Dropped 返回因写入失败而丢弃的日志条数，With/WithGroup 派生的日志器共享该计数
+func (l *SLog) Dropped() uint64 {
+	return l.dropped.Load()
 }
@@ -1,6 +1,8 @@
 package logger
 
 import (
+	"context"
+	"errors"
 	"os"
 	"strings"
 	"testing"
@@ -202,3 +204,195 @@ func TestMultiWriter(t *testing.T) {
 		t.Errorf("Log file doesn't contain expected message")
 	}
 }
+
+func TestSLogSequence(t *testing.T) {
+	logFile := t.TempDir() + "/seq.log"
+	logger, err := NewSLogWithOptions(&SLogOptions{
+		Level:    "info",
+		Format:   "json",
+		Sequence: true,
+		Output: &ref.TypeOptions{
+			Namespace: "github.com/hatlonely/gox/log/writer",
+			Type:      "FileWriter",
+			Options:   &writer.FileWriterOptions{Path: logFile},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewSLogWithOptions() error = %v", err)
+	}
+
+	logger.Info("first")
+	logger.Debug("filtered")
+	logger.With("component", "db").Info("second")
+	logger.Warn("third")
+
+	content, err := os.ReadFile(logFile)
+	if err != nil {
+		t.Fatalf("Failed to read log file: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 lines, got %d: %s", len(lines), content)
+	}
+	for i, line := range lines {
+		want := `"seq":` + string(rune('1'+i))
+		if !strings.Contains(line, want) {
+			t.Errorf("line %d = %s, want contains %s", i, line, want)
+		}
+	}
+}
+
+// failingWriter 总是写入失败的输出器
+type failingWriter struct{}
+
+func (w *failingWriter) Write(p []byte) (int, error) { return 0, errors.New("disk full") }
+func (w *failingWriter) Close() error                { return nil }
+
+func TestSLogErrorHandler(t *testing.T) {
+	ref.MustRegisterT[*failingWriter](func() *failingWriter { return &failingWriter{} })
+
+	var reported []error
+	SetErrorHandler(func(err error) {
+		reported = append(reported, err)
+	})
+	defer SetErrorHandler(nil)
+
+	logger, err := NewSLogWithOptions(&SLogOptions{
+		Level: "info",
+		Output: &ref.TypeOptions{
+			Namespace: "github.com/hatlonely/gox/log/logger",
+			Type:      "failingWriter",
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewSLogWithOptions() error = %v", err)
+	}
+
+	logger.Info("first")
+	logger.With("k", "v").Error("second")
+	logger.Debug("filtered")
+
+	if len(reported) != 2 {
+		t.Fatalf("expected 2 reported errors, got %d", len(reported))
+	}
+	if !strings.Contains(reported[0].Error(), "disk full") || !strings.Contains(reported[0].Error(), "first") {
+		t.Errorf("unexpected error: %v", reported[0])
+	}
+	if logger.Dropped() != 2 {
+		t.Errorf("Dropped() = %d, want 2", logger.Dropped())
+	}
+}
+
+type featureKey struct{}
+
+func TestSLogFieldProvider(t *testing.T) {
+	RegisterFieldProvider("feature", func(ctx context.Context) []any {
+		if variant, ok := ctx.Value(featureKey{}).(string); ok {
+			return []any{"variant", variant}
+		}
+		return nil
+	})
+
+	logFile := t.TempDir() + "/fields.log"
+	logger, err := NewSLogWithOptions(&SLogOptions{
+		Level:          "info",
+		Format:         "json",
+		FieldProviders: []string{"feature"},
+		Output: &ref.TypeOptions{
+			Namespace: "github.com/hatlonely/gox/log/writer",
+			Type:      "FileWriter",
+			Options:   &writer.FileWriterOptions{Path: logFile},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewSLogWithOptions() error = %v", err)
+	}
+
+	ctx := context.WithValue(context.Background(), featureKey{}, "B")
+	logger.InfoContext(ctx, "first")
+	logger.Info("second")
+
+	// 在根日志器上添加的提供者对派生日志器同样生效
+	derived := logger.With("component", "db")
+	calls := 0
+	logger.AddFieldProvider(func(ctx context.Context) []any {
+		calls++
+		return []any{"goroutine", "worker-1"}
+	})
+	derived.InfoContext(ctx, "third")
+	logger.Debug("filtered")
+
+	// 提供者 panic 时忽略其字段
+	var reported []error
+	SetErrorHandler(func(err error) {
+		reported = append(reported, err)
+	})
+	defer SetErrorHandler(nil)
+	logger.AddFieldProvider(func(ctx context.Context) []any {
+		panic("boom")
+	})
+	logger.Info("fourth")
+
+	content, err := os.ReadFile(logFile)
+	if err != nil {
+		t.Fatalf("Failed to read log file: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("expected 4 lines, got %d: %s", len(lines), content)
+	}
+	if !strings.Contains(lines[0], `"variant":"B"`) {
+		t.Errorf("line 0 = %s, want variant", lines[0])
+	}
+	if strings.Contains(lines[1], `"variant"`) {
+		t.Errorf("line 1 = %s, want no variant", lines[1])
+	}
+	if !strings.Contains(lines[2], `"component":"db"`) || !strings.Contains(lines[2], `"variant":"B"`) || !strings.Contains(lines[2], `"goroutine":"worker-1"`) {
+		t.Errorf("line 2 = %s, want component, variant and goroutine", lines[2])
+	}
+	if !strings.Contains(lines[3], `"goroutine":"worker-1"`) {
+		t.Errorf("line 3 = %s, want goroutine", lines[3])
+	}
+	if calls != 2 {
+		t.Errorf("expected provider called 2 times, got %d", calls)
+	}
+	if len(reported) != 1 || !strings.Contains(reported[0].Error(), "boom") {
+		t.Errorf("unexpected reported errors: %v", reported)
+	}
+
+	// 未注册的提供者
+	if _, err := NewSLogWithOptions(&SLogOptions{FieldProviders: []string{"not_exists"}}); err == nil {
+		t.Error("expected error for unregistered field provider")
+	}
+}
+
+func TestSLogFieldProviderWithGroup(t *testing.T) {
+	logFile := t.TempDir() + "/group.log"
+	logger, err := NewSLogWithOptions(&SLogOptions{
+		Level:  "info",
+		Format: "json",
+		Output: &ref.TypeOptions{
+			Namespace: "github.com/hatlonely/gox/log/writer",
+			Type:      "FileWriter",
+			Options:   &writer.FileWriterOptions{Path: logFile},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewSLogWithOptions() error = %v", err)
+	}
+	logger.AddFieldProvider(func(ctx context.Context) []any {
+		return []any{"goroutine", "worker-1"}
+	})
+
+	// 动态字段位于顶层，With 和日志参数位于分组内
+	logger.With("component", "db").WithGroup("req").With("id", 1).WithGroup("inner").Info("grouped", "path", "/")
+
+	content, err := os.ReadFile(logFile)
+	if err != nil {
+		t.Fatalf("Failed to read log file: %v", err)
+	}
+	line := strings.TrimSpace(string(content))
+	if !strings.Contains(line, `"component":"db","goroutine":"worker-1","req":{"id":1,"inner":{"path":"/"}}`) {
+		t.Errorf("line = %s, want goroutine at top level", line)
+	}
+}
@@ -26,7 +26,7 @@ func NewLogManagerWithOptions(options Options) (*LogManager, error) {
 		}
 
 		// 使用 ref 创建日志器实例
-		loggerObj, err := ref.New(typeOpts.Namespace, typeOpts.Type, typeOpts.Options)
+		loggerObj, err := ref.NewWithOptions(typeOpts)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create logger '%s': %w", name, err)
 		}
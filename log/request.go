@@ -0,0 +1,265 @@
+package log
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/hatlonely/gox/log/logger"
+)
+
+type requestLoggerContextKey struct{}
+
+// RequestOption ForRequest 的选项
+type RequestOption func(*requestOptions)
+
+type requestOptions struct {
+	logger   logger.Logger
+	id       string
+	fields   []any
+	snapshot int
+	buffer   int
+}
+
+// WithRequestLogger 指定派生请求日志器的日志器，默认使用 Default()
+func WithRequestLogger(l logger.Logger) RequestOption {
+	return func(o *requestOptions) {
+		o.logger = l
+	}
+}
+
+// WithRequestID 使用上游传入的关联 ID，ctx 中已有关联 ID 时以 ctx 中的为准，不合法的 ID 被忽略
+func WithRequestID(id string) RequestOption {
+	return func(o *requestOptions) {
+		o.id = id
+	}
+}
+
+// WithRequestFields 为请求日志器附加固定字段，如 "method", r.Method
+func WithRequestFields(args ...any) RequestOption {
+	return func(o *requestOptions) {
+		o.fields = append(o.fields, args...)
+	}
+}
+
+// WithErrorSnapshot 缓存请求日志器最近的 maxRecords 条日志（包括被级别过滤的 Debug 日志），
+// 请求中第一次输出 Error 日志时将缓存的日志和请求字段作为 errorSnapshot 字段附加到该日志，maxRecords 小于等于 0 时不缓存
+func WithErrorSnapshot(maxRecords int) RequestOption {
+	return func(o *requestOptions) {
+		o.snapshot = maxRecords
+	}
+}
+
+// ForRequest 为一个请求创建日志器
+// ctx 中没有关联 ID 时生成新的 ID（UUIDv7），返回的 ctx 携带关联 ID 和请求日志器，之后可以通过 FromContext 取出；
+// 请求日志器不带 context 的方法同样附加 correlationId 字段
+func ForRequest(ctx context.Context, opts ...RequestOption) (context.Context, logger.Logger) {
+	options := &requestOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+	if options.logger == nil {
+		options.logger = Default()
+	}
+
+	if _, ok := logger.CorrelationIDFromContext(ctx); !ok && validCorrelationID(options.id) {
+		ctx = logger.ContextWithCorrelationID(ctx, options.id)
+	}
+	ctx, _ = NewCorrelationID(ctx)
+
+	l := options.logger
+	if len(options.fields) > 0 {
+		l = l.With(options.fields...)
+	}
+	requestLogger := &contextLogger{Logger: l, ctx: ctx}
+	if options.snapshot > 0 {
+		requestLogger.scope = &requestScope{maxRecords: options.snapshot, fields: argsToMap(options.fields)}
+	}
+	if options.buffer > 0 {
+		requestLogger.buffer = newRequestBuffer(options.buffer)
+	}
+	return context.WithValue(ctx, requestLoggerContextKey{}, requestLogger), requestLogger
+}
+
+// FromContext 获取 ForRequest 写入 ctx 的请求日志器，没有时返回 Default()
+func FromContext(ctx context.Context) logger.Logger {
+	if l, ok := ctx.Value(requestLoggerContextKey{}).(logger.Logger); ok {
+		return l
+	}
+	return Default()
+}
+
+// contextLogger 绑定请求 ctx 的日志器，不带 context 的方法使用绑定的 ctx 输出
+// 带 context 的方法使用传入的 ctx，传入的 ctx 中没有关联 ID 时补充绑定的关联 ID
+type contextLogger struct {
+	logger.Logger
+	ctx    context.Context
+	scope  *requestScope  // 启用 WithErrorSnapshot 时派生的日志器共享
+	buffer *requestBuffer // 启用 WithFlushOnError 时派生的日志器共享
+}
+
+func (l *contextLogger) Debug(msg string, args ...any) {
+	l.scope.record(slog.LevelDebug, msg, args)
+	if l.buffer.hold(l.Logger, l.ctx, slog.LevelDebug, msg, args) {
+		return
+	}
+	l.Logger.DebugContext(l.ctx, msg, args...)
+}
+
+func (l *contextLogger) Info(msg string, args ...any) {
+	l.scope.record(slog.LevelInfo, msg, args)
+	if l.buffer.hold(l.Logger, l.ctx, slog.LevelInfo, msg, args) {
+		return
+	}
+	l.Logger.InfoContext(l.ctx, msg, args...)
+}
+
+func (l *contextLogger) Warn(msg string, args ...any) {
+	l.scope.record(slog.LevelWarn, msg, args)
+	l.Logger.WarnContext(l.ctx, msg, args...)
+}
+
+func (l *contextLogger) Error(msg string, args ...any) {
+	args = l.buffer.flushArgs(args)
+	l.Logger.ErrorContext(l.ctx, msg, l.scope.attachSnapshot(args)...)
+}
+
+func (l *contextLogger) DebugContext(ctx context.Context, msg string, args ...any) {
+	l.scope.record(slog.LevelDebug, msg, args)
+	ctx = l.withCorrelationID(ctx)
+	if l.buffer.hold(l.Logger, ctx, slog.LevelDebug, msg, args) {
+		return
+	}
+	l.Logger.DebugContext(ctx, msg, args...)
+}
+
+func (l *contextLogger) InfoContext(ctx context.Context, msg string, args ...any) {
+	l.scope.record(slog.LevelInfo, msg, args)
+	ctx = l.withCorrelationID(ctx)
+	if l.buffer.hold(l.Logger, ctx, slog.LevelInfo, msg, args) {
+		return
+	}
+	l.Logger.InfoContext(ctx, msg, args...)
+}
+
+func (l *contextLogger) WarnContext(ctx context.Context, msg string, args ...any) {
+	l.scope.record(slog.LevelWarn, msg, args)
+	l.Logger.WarnContext(l.withCorrelationID(ctx), msg, args...)
+}
+
+func (l *contextLogger) ErrorContext(ctx context.Context, msg string, args ...any) {
+	args = l.buffer.flushArgs(args)
+	l.Logger.ErrorContext(l.withCorrelationID(ctx), msg, l.scope.attachSnapshot(args)...)
+}
+
+func (l *contextLogger) With(args ...any) logger.Logger {
+	return &contextLogger{Logger: l.Logger.With(args...), ctx: l.ctx, scope: l.scope, buffer: l.buffer}
+}
+
+func (l *contextLogger) WithGroup(name string) logger.Logger {
+	return &contextLogger{Logger: l.Logger.WithGroup(name), ctx: l.ctx, scope: l.scope, buffer: l.buffer}
+}
+
+// withCorrelationID ctx 中没有关联 ID 时补充请求的关联 ID
+func (l *contextLogger) withCorrelationID(ctx context.Context) context.Context {
+	if _, ok := logger.CorrelationIDFromContext(ctx); ok {
+		return ctx
+	}
+	if id, ok := logger.CorrelationIDFromContext(l.ctx); ok {
+		return logger.ContextWithCorrelationID(ctx, id)
+	}
+	return ctx
+}
+
+// ErrorSnapshotKey 请求中第一条 Error 日志附加的快照字段名
+const ErrorSnapshotKey = "errorSnapshot"
+
+// requestScope 请求范围内缓存的日志，用于第一次出现 Error 日志时附加快照，nil scope 不做缓存
+type requestScope struct {
+	mu         sync.Mutex
+	maxRecords int
+	fields     map[string]any
+	records    []snapshotRecord
+	dropped    int
+	fired      bool
+}
+
+// snapshotRecord 快照中的一条日志
+type snapshotRecord struct {
+	Time    time.Time      `json:"time"`
+	Level   string         `json:"level"`
+	Message string         `json:"msg"`
+	Fields  map[string]any `json:"fields,omitempty"`
+}
+
+// record 缓存一条日志，超过 maxRecords 时丢弃最早的日志；已经附加过快照后不再缓存
+func (s *requestScope) record(level slog.Level, msg string, args []any) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fired {
+		return
+	}
+	if len(s.records) == s.maxRecords {
+		copy(s.records, s.records[1:])
+		s.records = s.records[:len(s.records)-1]
+		s.dropped++
+	}
+	s.records = append(s.records, snapshotRecord{Time: time.Now(), Level: level.String(), Message: msg, Fields: argsToMap(args)})
+}
+
+// attachSnapshot 请求中第一次调用时在 args 后追加快照字段，之后原样返回 args
+func (s *requestScope) attachSnapshot(args []any) []any {
+	if s == nil {
+		return args
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fired {
+		return args
+	}
+	s.fired = true
+
+	snapshot := []any{slog.Any("records", s.records)}
+	if s.dropped > 0 {
+		snapshot = append(snapshot, slog.Int("dropped", s.dropped))
+	}
+	if len(s.fields) > 0 {
+		snapshot = append(snapshot, slog.Any("fields", s.fields))
+	}
+	s.records = nil
+	return append(args[:len(args):len(args)], slog.Group(ErrorSnapshotKey, snapshot...))
+}
+
+// argsToMap 按 slog 的规则将键值对参数转换为 map，键不是字符串的值使用 !BADKEY 作为键
+func argsToMap(args []any) map[string]any {
+	if len(args) == 0 {
+		return nil
+	}
+	fields := make(map[string]any, len(args)/2)
+	for _, attr := range slog.Group("", args...).Value.Group() {
+		fields[attr.Key] = attrValue(attr.Value)
+	}
+	return fields
+}
+
+// attrValue 将 slog.Value 转换为可以 JSON 序列化的值，分组转换为 map
+func attrValue(v slog.Value) any {
+	v = v.Resolve()
+	if v.Kind() != slog.KindGroup {
+		if err, ok := v.Any().(error); ok {
+			return err.Error()
+		}
+		return v.Any()
+	}
+	group := make(map[string]any, len(v.Group()))
+	for _, attr := range v.Group() {
+		group[attr.Key] = attrValue(attr.Value)
+	}
+	return group
+}
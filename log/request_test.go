@@ -0,0 +1,228 @@
+package log
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hatlonely/gox/log/logger"
+	"github.com/hatlonely/gox/log/writer"
+	"github.com/hatlonely/gox/ref"
+)
+
+func newRequestTestLogger(t *testing.T) (logger.Logger, func() []string) {
+	logFile := t.TempDir() + "/request.log"
+	l, err := logger.NewSLogWithOptions(&logger.SLogOptions{
+		Level:  "info",
+		Format: "json",
+		Output: &ref.TypeOptions{
+			Namespace: "github.com/hatlonely/gox/log/writer",
+			Type:      "FileWriter",
+			Options:   &writer.FileWriterOptions{Path: logFile},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewSLogWithOptions() error = %v", err)
+	}
+	return l, func() []string {
+		content, err := os.ReadFile(logFile)
+		if err != nil {
+			t.Fatalf("Failed to read log file: %v", err)
+		}
+		return strings.Split(strings.TrimSpace(string(content)), "\n")
+	}
+}
+
+func TestForRequest(t *testing.T) {
+	l, lines := newRequestTestLogger(t)
+
+	ctx, requestLogger := ForRequest(context.Background(), WithRequestLogger(l), WithRequestFields("method", "GET"))
+	id := CorrelationID(ctx)
+	if id == "" {
+		t.Fatal("expected generated correlation id")
+	}
+	if FromContext(ctx) != requestLogger {
+		t.Error("FromContext() should return the request logger")
+	}
+
+	requestLogger.Info("first")
+	requestLogger.WithGroup("db").Info("second", "sql", "select")
+	requestLogger.InfoContext(context.Background(), "third")
+
+	got := lines()
+	if len(got) != 3 {
+		t.Fatalf("expected 3 lines, got %d: %v", len(got), got)
+	}
+	for i, line := range got {
+		if !strings.Contains(line, `"method":"GET"`) || strings.Count(line, `"correlationId":"`+id+`"`) != 1 {
+			t.Errorf("line %d = %s, want method and correlationId", i, line)
+		}
+	}
+	if !strings.Contains(got[1], `"db":{"sql":"select"}`) {
+		t.Errorf("line 1 = %s, want grouped field", got[1])
+	}
+}
+
+func TestForRequest_PropagateID(t *testing.T) {
+	ctx, _ := ForRequest(context.Background(), WithRequestID("upstream"))
+	if CorrelationID(ctx) != "upstream" {
+		t.Errorf("CorrelationID() = %q, want upstream", CorrelationID(ctx))
+	}
+
+	// ctx 中已有的关联 ID 优先
+	ctx, _ = ForRequest(WithCorrelationID(context.Background(), "existing"), WithRequestID("upstream"))
+	if CorrelationID(ctx) != "existing" {
+		t.Errorf("CorrelationID() = %q, want existing", CorrelationID(ctx))
+	}
+
+	// 不合法的 ID 被忽略
+	ctx, _ = ForRequest(context.Background(), WithRequestID("evil\nid"))
+	if id := CorrelationID(ctx); id == "" || id == "evil\nid" {
+		t.Errorf("CorrelationID() = %q, want regenerated", id)
+	}
+
+	if FromContext(context.Background()) != Default() {
+		t.Error("FromContext() should fall back to Default()")
+	}
+}
+
+func TestCorrelationIDMiddleware_RequestLogger(t *testing.T) {
+	var got logger.Logger
+	var id string
+	handler := CorrelationIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = FromContext(r.Context())
+		id = CorrelationID(r.Context())
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(CorrelationIDHeader, "req-123")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if _, ok := got.(*contextLogger); !ok {
+		t.Errorf("FromContext() = %T, want request logger", got)
+	}
+	if id != "req-123" {
+		t.Errorf("correlation id = %q, want req-123", id)
+	}
+}
+
+func TestForRequest_ErrorSnapshot(t *testing.T) {
+	l, lines := newRequestTestLogger(t)
+
+	_, requestLogger := ForRequest(context.Background(), WithRequestLogger(l), WithRequestFields("path", "/orders"), WithErrorSnapshot(2))
+	requestLogger.Debug("parse request", "size", 10)
+	requestLogger.With("component", "db").Debug("query", "sql", "select")
+	requestLogger.Info("cache miss", "key", "order:1")
+	requestLogger.Error("failed", "error", errors.New("timeout"))
+	requestLogger.Error("failed again")
+
+	got := lines()
+	if len(got) != 3 {
+		t.Fatalf("expected 3 lines (info and errors), got %d: %v", len(got), got)
+	}
+
+	var first map[string]any
+	if err := json.Unmarshal([]byte(got[1]), &first); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	snapshot, ok := first[ErrorSnapshotKey].(map[string]any)
+	if !ok {
+		t.Fatalf("line 1 = %s, want errorSnapshot", got[1])
+	}
+	records := snapshot["records"].([]any)
+	if len(records) != 2 || snapshot["dropped"] != float64(1) {
+		t.Fatalf("snapshot = %v, want 2 records and 1 dropped", snapshot)
+	}
+	query := records[0].(map[string]any)
+	if query["level"] != "DEBUG" || query["msg"] != "query" || query["fields"].(map[string]any)["sql"] != "select" {
+		t.Errorf("records[0] = %v, want debug query record", query)
+	}
+	if snapshot["fields"].(map[string]any)["path"] != "/orders" {
+		t.Errorf("snapshot fields = %v, want request fields", snapshot["fields"])
+	}
+	if first["error"] != "timeout" {
+		t.Errorf("error = %v, want timeout", first["error"])
+	}
+
+	// 只有第一条 Error 日志附加快照
+	if strings.Contains(got[2], ErrorSnapshotKey) {
+		t.Errorf("line 2 = %s, want no snapshot", got[2])
+	}
+}
+
+func TestForRequest_FlushOnError(t *testing.T) {
+	l, lines := newRequestTestLogger(t)
+
+	ctx, requestLogger := ForRequest(context.Background(), WithRequestLogger(l), WithFlushOnError(2))
+	requestLogger.Debug("parse request", "size", 10)
+	requestLogger.With("component", "db").Debug("query", "sql", "select")
+	requestLogger.InfoContext(context.Background(), "cache miss", "key", "order:1")
+	requestLogger.Warn("slow")
+	requestLogger.Error("failed", "error", errors.New("timeout"))
+	requestLogger.Info("retry")
+	requestLogger.Error("failed again")
+	requestLogger.Info("discarded")
+
+	got := lines()
+	if len(got) != 6 {
+		t.Fatalf("expected 6 lines, got %d: %v", len(got), got)
+	}
+	records := make([]map[string]any, len(got))
+	for i, line := range got {
+		if err := json.Unmarshal([]byte(line), &records[i]); err != nil {
+			t.Fatalf("Unmarshal() error = %v", err)
+		}
+	}
+
+	// Warn 直接输出，缓冲的日志在 Error 之前按顺序输出，Debug 日志不经过级别过滤
+	want := []struct{ level, msg string }{
+		{"WARN", "slow"}, {"DEBUG", "query"}, {"INFO", "cache miss"}, {"ERROR", "failed"}, {"INFO", "retry"}, {"ERROR", "failed again"},
+	}
+	for i, w := range want {
+		if records[i]["level"] != w.level || records[i]["msg"] != w.msg {
+			t.Errorf("line %d = %s, want %s %s", i, got[i], w.level, w.msg)
+		}
+	}
+	if records[1]["component"] != "db" || records[1]["sql"] != "select" {
+		t.Errorf("line 1 = %s, want With fields", got[1])
+	}
+	for _, i := range []int{1, 2} {
+		if records[i][logger.CorrelationIDKey] != CorrelationID(ctx) {
+			t.Errorf("line %d = %s, want correlation id", i, got[i])
+		}
+	}
+	if records[3][BufferDroppedKey] != float64(1) {
+		t.Errorf("line 3 = %s, want %s=1", got[3], BufferDroppedKey)
+	}
+	if _, ok := records[5][BufferDroppedKey]; ok {
+		t.Errorf("line 5 = %s, want no %s", got[5], BufferDroppedKey)
+	}
+
+	// 缓冲的日志保留原始时间
+	first, _ := time.Parse(time.RFC3339, records[1]["time"].(string))
+	errTime, _ := time.Parse(time.RFC3339, records[3]["time"].(string))
+	if first.After(errTime) {
+		t.Errorf("buffered record time %v should not be after error time %v", first, errTime)
+	}
+}
+
+func TestFlush(t *testing.T) {
+	l, lines := newRequestTestLogger(t)
+
+	ctx, requestLogger := ForRequest(context.Background(), WithRequestLogger(l), WithFlushOnError(8))
+	requestLogger.Info("handled", "status", 503)
+	Flush(ctx)
+	Flush(ctx)
+	Flush(context.Background())
+
+	got := lines()
+	if len(got) != 1 || !strings.Contains(got[0], `"msg":"handled"`) {
+		t.Fatalf("lines = %v, want the flushed record", got)
+	}
+}
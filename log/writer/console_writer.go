@@ -11,12 +11,18 @@ type ConsoleWriterOptions struct {
 	Color bool `cfg:"color"`
 	// 输出目标：stdout, stderr
 	Target string `cfg:"target"`
+	// 容器日志兼容模式，避免 kubelet 把一条日志拆成多条：
+	// cri 按 CRI 日志格式输出带时间、stream 和 P/F 标记的行；singleLine 保证每条日志为单行且不超过 MaxLineSize。为空时原样输出
+	Compat string `cfg:"compat" validate:"omitempty,oneof=cri singleLine"`
+	// 兼容模式下单行内容的最大字节数，默认与 kubelet 的拆分阈值一致，为 16KB
+	MaxLineSize int `cfg:"maxLineSize" def:"16384"`
 }
 
 // ConsoleWriter 控制台输出器
 type ConsoleWriter struct {
-	writer io.Writer
-	color  bool
+	writer    io.Writer
+	color     bool
+	formatter *lineFormatter
 }
 
 // NewConsoleWriterWithOptions 创建控制台输出器
@@ -29,24 +35,41 @@ func NewConsoleWriterWithOptions(options *ConsoleWriterOptions) (*ConsoleWriter,
 	}
 
 	var writer io.Writer
+	stream := "stdout"
 	switch options.Target {
 	case "stderr":
 		writer = os.Stderr
+		stream = "stderr"
 	case "stdout", "":
 		writer = os.Stdout
 	default:
 		writer = os.Stdout
 	}
 
+	var formatter *lineFormatter
+	if options.Compat != "" {
+		var err error
+		if formatter, err = newLineFormatter(options.Compat, stream, options.MaxLineSize); err != nil {
+			return nil, err
+		}
+	}
+
 	return &ConsoleWriter{
-		writer: writer,
-		color:  options.Color,
+		writer:    writer,
+		color:     options.Color,
+		formatter: formatter,
 	}, nil
 }
 
-// Write 实现 io.Writer 接口
+// Write 实现 io.Writer 接口，兼容模式下 p 作为一条日志整理后一次写出
 func (c *ConsoleWriter) Write(p []byte) (n int, err error) {
-	return c.writer.Write(p)
+	if c.formatter == nil {
+		return c.writer.Write(p)
+	}
+	if _, err := c.writer.Write(c.formatter.format(p)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
 }
 
 // Close 实现 io.Closer 接口
@@ -0,0 +1,145 @@
+package writer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+// 容器日志兼容模式
+const (
+	// CompatCRI 按 CRI 日志格式输出 "<时间> <stream> <P|F> <内容>"，超长日志拆分为多个 P 行，最后一行为 F
+	CompatCRI = "cri"
+	// CompatSingleLine 保证每条日志为单行且不超过单行大小上限，超长日志截断
+	CompatSingleLine = "singleLine"
+)
+
+// defaultMaxLineSize kubelet 拆分日志行的默认阈值
+const defaultMaxLineSize = 16 * 1024
+
+// lineFormatter 将一条日志整理为容器运行时不会拆分的行
+// 日志中间的换行转义为 \n，避免一条日志被采集为多条
+type lineFormatter struct {
+	compat      string
+	stream      string
+	maxLineSize int
+	now         func() time.Time
+}
+
+func newLineFormatter(compat string, stream string, maxLineSize int) (*lineFormatter, error) {
+	if compat != CompatCRI && compat != CompatSingleLine {
+		return nil, fmt.Errorf("unsupported compat mode: %s", compat)
+	}
+	if maxLineSize <= 0 {
+		maxLineSize = defaultMaxLineSize
+	}
+	return &lineFormatter{compat: compat, stream: stream, maxLineSize: maxLineSize, now: time.Now}, nil
+}
+
+// format 将 p 作为一条日志整理为输出的行，JSON 日志去掉格式化的空白，其他日志转义换行
+func (f *lineFormatter) format(p []byte) []byte {
+	record := bytes.TrimSuffix(p, []byte("\n"))
+	isJSON := isJSONRecord(record)
+	if isJSON {
+		var buf bytes.Buffer
+		json.Compact(&buf, record)
+		record = buf.Bytes()
+	} else {
+		record = escapeNewlines(record)
+	}
+
+	if f.compat == CompatCRI {
+		return f.formatCRI(record)
+	}
+	return f.formatSingleLine(record, len(p), isJSON)
+}
+
+// formatCRI 每行内容不超过 maxLineSize，拆分时不切断 UTF-8 字符
+func (f *lineFormatter) formatCRI(record []byte) []byte {
+	prefix := f.now().Format(time.RFC3339Nano) + " " + f.stream + " "
+	var buf bytes.Buffer
+	for {
+		chunk, tag := record, "F"
+		if len(record) > f.maxLineSize {
+			chunk, tag = record[:runeBoundary(record, f.maxLineSize)], "P"
+		}
+		buf.WriteString(prefix)
+		buf.WriteString(tag)
+		buf.WriteByte(' ')
+		buf.Write(chunk)
+		buf.WriteByte('\n')
+		record = record[len(chunk):]
+		if tag == "F" {
+			return buf.Bytes()
+		}
+	}
+}
+
+// formatSingleLine 超长的 JSON 日志替换为 {"truncated":true,"size":原大小,"record":"截断后的内容"}，保证输出仍是合法的 JSON
+func (f *lineFormatter) formatSingleLine(record []byte, size int, isJSON bool) []byte {
+	limit := f.maxLineSize - 1 // 换行符
+	if len(record) <= limit {
+		return append(record, '\n')
+	}
+
+	if isJSON {
+		head := `{"truncated":true,"size":` + strconv.Itoa(size) + `,"record":`
+		budget := limit - len(head) - 1
+		content := record[:runeBoundary(record, budget)]
+		for {
+			quoted, _ := json.Marshal(string(content))
+			if len(quoted) <= budget || len(content) == 0 {
+				return append(append(append([]byte(head), quoted...), '}'), '\n')
+			}
+			content = content[:runeBoundary(content, len(content)-(len(quoted)-budget))]
+		}
+	}
+
+	suffix := fmt.Sprintf("...[truncated %d bytes]", size)
+	cut := runeBoundary(record, limit-len(suffix))
+	return append(append(append([]byte{}, record[:cut]...), suffix...), '\n')
+}
+
+// isJSONRecord 日志是否为 JSON 对象
+func isJSONRecord(p []byte) bool {
+	p = bytes.TrimSpace(p)
+	return len(p) > 0 && p[0] == '{' && json.Valid(p)
+}
+
+// escapeNewlines 将换行和回车转义为字面的 \n 和 \r
+func escapeNewlines(p []byte) []byte {
+	if bytes.IndexAny(p, "\r\n") < 0 {
+		return append([]byte{}, p...)
+	}
+	out := make([]byte, 0, len(p)+8)
+	for _, b := range p {
+		switch b {
+		case '\n':
+			out = append(out, '\\', 'n')
+		case '\r':
+			out = append(out, '\\', 'r')
+		default:
+			out = append(out, b)
+		}
+	}
+	return out
+}
+
+// runeBoundary 返回不超过 n 的最大 UTF-8 字符边界，前 n 个字节内没有边界时返回 n
+func runeBoundary(p []byte, n int) int {
+	if n <= 0 {
+		return 0
+	}
+	if n >= len(p) {
+		return len(p)
+	}
+	for i := n; i > 0; i-- {
+		if utf8.RuneStart(p[i]) {
+			return i
+		}
+	}
+	return n
+}
@@ -0,0 +1,99 @@
+package writer
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestLineFormatter(t *testing.T, compat string, maxLineSize int) *lineFormatter {
+	f, err := newLineFormatter(compat, "stdout", maxLineSize)
+	if err != nil {
+		t.Fatalf("newLineFormatter failed: %v", err)
+	}
+	f.now = func() time.Time { return time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC) }
+	return f
+}
+
+func TestLineFormatterCRI(t *testing.T) {
+	f := newTestLineFormatter(t, CompatCRI, 10)
+	prefix := "2024-01-02T03:04:05.000000006Z stdout "
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"short record", "hello\n", prefix + "F hello\n"},
+		{"multi-line record", "a\nb\r\n", prefix + `F a\nb\r` + "\n"},
+		{"long record", "0123456789abcdefghijXYZ\n", prefix + "P 0123456789\n" + prefix + "P abcdefghij\n" + prefix + "F XYZ\n"},
+		{"exact size", "0123456789\n", prefix + "F 0123456789\n"},
+		{"utf8 boundary", "一二三四五\n", prefix + "P 一二三\n" + prefix + "F 四五\n"},
+		{"pretty json", "{\n  \"a\": 1\n}\n", prefix + `F {"a":1}` + "\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(f.format([]byte(tt.input))); got != tt.expected {
+				t.Errorf("format(%q) = %q, want %q", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestLineFormatterSingleLine(t *testing.T) {
+	f := newTestLineFormatter(t, CompatSingleLine, 80)
+
+	if got := string(f.format([]byte("line1\nline2\n"))); got != `line1\nline2`+"\n" {
+		t.Errorf("unexpected output %q", got)
+	}
+
+	text := strings.Repeat("x", 100) + "\n"
+	got := f.format([]byte(text))
+	if len(got) > 80 || !bytes.HasSuffix(got, []byte("...[truncated 101 bytes]\n")) {
+		t.Errorf("unexpected truncated text %q", got)
+	}
+
+	record, _ := json.Marshal(map[string]string{"msg": strings.Repeat("\"中文\"", 30)})
+	got = f.format(append(record, '\n'))
+	if len(got) > 80 || bytes.Count(got, []byte("\n")) != 1 {
+		t.Fatalf("output %q exceeds limit or is not single line", got)
+	}
+	var truncated struct {
+		Truncated bool   `json:"truncated"`
+		Size      int    `json:"size"`
+		Record    string `json:"record"`
+	}
+	if err := json.Unmarshal(got, &truncated); err != nil {
+		t.Fatalf("truncated output %q is not valid json: %v", got, err)
+	}
+	if !truncated.Truncated || truncated.Size != len(record)+1 || !strings.HasPrefix(string(record), truncated.Record) {
+		t.Errorf("unexpected truncated record %+v", truncated)
+	}
+}
+
+func TestConsoleWriterCompat(t *testing.T) {
+	if _, err := NewConsoleWriterWithOptions(&ConsoleWriterOptions{Compat: "docker"}); err == nil {
+		t.Error("expected error for unsupported compat mode")
+	}
+
+	w, err := NewConsoleWriterWithOptions(&ConsoleWriterOptions{Target: "stderr", Compat: CompatCRI})
+	if err != nil {
+		t.Fatalf("NewConsoleWriterWithOptions failed: %v", err)
+	}
+	if w.formatter.stream != "stderr" || w.formatter.maxLineSize != defaultMaxLineSize {
+		t.Errorf("unexpected formatter %+v", w.formatter)
+	}
+
+	var buf bytes.Buffer
+	w.writer = &buf
+	n, err := w.Write([]byte("a\nb\n"))
+	if err != nil || n != 4 {
+		t.Fatalf("Write returned %d, %v", n, err)
+	}
+	if !strings.HasSuffix(buf.String(), ` stderr F a\nb`+"\n") {
+		t.Errorf("unexpected output %q", buf.String())
+	}
+}
@@ -0,0 +1,204 @@
+package writer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"filippo.io/age"
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/hatlonely/gox/ref"
+)
+
+const (
+	// EncryptionFormatAge age 格式（X25519），用 age -d -i <私钥文件> 解密
+	EncryptionFormatAge = "age"
+	// EncryptionFormatGPG OpenPGP 格式，用 gpg --decrypt 解密
+	EncryptionFormatGPG = "gpg"
+)
+
+// EncryptingWriterOptions 加密输出配置
+type EncryptingWriterOptions struct {
+	// 加密之后写入的输出器，如 FileWriter、NetworkWriter
+	Writer ref.TypeOptions `cfg:"writer"`
+	// 加密格式，age 或 gpg，默认为 age
+	Format string `cfg:"format" def:"age"`
+	// 接收者公钥，age 为 age1 开头的公钥，gpg 为 ASCII armor 格式的公钥；指定多个时任意一个私钥都可以解密
+	Recipients []string `cfg:"recipients"`
+	// 公钥文件，age 每行一个公钥（忽略空行和 # 开头的注释），gpg 为 ASCII armor 格式的公钥环
+	RecipientFiles []string `cfg:"recipientFiles"`
+}
+
+// EncryptingWriter 加密输出器，用配置的公钥加密每次写入的日志块之后写入底层输出器，用于要求日志落盘前在应用侧加密的场景
+//
+// 每个日志块独立加密为一条完整的 age 或 OpenPGP 消息，以标准 base64 编码为一行输出，
+// 篡改过的块无法解密。解密时逐行 base64 解码之后解密：
+//
+//	while read -r line; do echo "$line" | base64 -d | age -d -i key.txt; done < app.log.age
+//
+// 每块有数百字节的固定开销，适合与缓冲或批量写入的日志配合使用
+type EncryptingWriter struct {
+	writer    Writer
+	encrypter encrypter
+
+	mu sync.Mutex
+}
+
+// encrypter 将一个日志块加密为一条完整的消息
+type encrypter interface {
+	encrypt(plaintext []byte) ([]byte, error)
+}
+
+// NewEncryptingWriterWithOptions 创建加密输出器
+func NewEncryptingWriterWithOptions(options *EncryptingWriterOptions) (*EncryptingWriter, error) {
+	if options == nil {
+		return nil, fmt.Errorf("options cannot be nil")
+	}
+
+	recipients := options.Recipients
+	for _, file := range options.RecipientFiles {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read recipient file %s: %w", file, err)
+		}
+		if options.Format == EncryptionFormatGPG {
+			recipients = append(recipients, string(data))
+			continue
+		}
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		for scanner.Scan() {
+			if line := strings.TrimSpace(scanner.Text()); line != "" && !strings.HasPrefix(line, "#") {
+				recipients = append(recipients, line)
+			}
+		}
+	}
+
+	writer, err := NewWriterWithOptions(&options.Writer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create writer: %w", err)
+	}
+	encryptingWriter, err := NewEncryptingWriterFromWriter(writer, options.Format, recipients...)
+	if err != nil {
+		writer.Close()
+		return nil, err
+	}
+	return encryptingWriter, nil
+}
+
+// NewEncryptingWriterFromWriter 用已有的 Writer 创建加密输出器，format 为空时使用 age 格式
+func NewEncryptingWriterFromWriter(writer Writer, format string, recipients ...string) (*EncryptingWriter, error) {
+	var e encrypter
+	var err error
+	switch format {
+	case "", EncryptionFormatAge:
+		e, err = newAgeEncrypter(recipients)
+	case EncryptionFormatGPG:
+		e, err = newGPGEncrypter(recipients)
+	default:
+		return nil, fmt.Errorf("unsupported encryption format: %s", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &EncryptingWriter{writer: writer, encrypter: e}, nil
+}
+
+// Write 实现 io.Writer 接口，加密 p 之后整行写入底层输出器
+func (w *EncryptingWriter) Write(p []byte) (n int, err error) {
+	ciphertext, err := w.encrypter.encrypt(p)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encrypt log: %w", err)
+	}
+	line := make([]byte, base64.StdEncoding.EncodedLen(len(ciphertext))+1)
+	base64.StdEncoding.Encode(line, ciphertext)
+	line[len(line)-1] = '\n'
+
+	// 加锁保证并发写入时每一行完整输出
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := writeFull(w.writer, line); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close 实现 io.Closer 接口，关闭底层输出器
+func (w *EncryptingWriter) Close() error {
+	return w.writer.Close()
+}
+
+// ageEncrypter 按 age 格式加密，每次加密生成独立的文件密钥，任意一个接收者的私钥都可以解密
+type ageEncrypter struct {
+	recipients []age.Recipient
+}
+
+// newAgeEncrypter 解析 age1 开头的 X25519 公钥
+func newAgeEncrypter(recipients []string) (*ageEncrypter, error) {
+	e := &ageEncrypter{}
+	for _, recipient := range recipients {
+		r, err := age.ParseX25519Recipient(strings.TrimSpace(recipient))
+		if err != nil {
+			return nil, fmt.Errorf("invalid age recipient %q: %w", recipient, err)
+		}
+		e.recipients = append(e.recipients, r)
+	}
+	if len(e.recipients) == 0 {
+		return nil, fmt.Errorf("at least one age recipient is required")
+	}
+	return e, nil
+}
+
+func (e *ageEncrypter) encrypt(plaintext []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := age.Encrypt(&buf, e.recipients...)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(plaintext); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// gpgEncrypter 按 OpenPGP 格式加密，消息带有 MDC 完整性校验
+type gpgEncrypter struct {
+	recipients openpgp.EntityList
+}
+
+// newGPGEncrypter 解析 ASCII armor 格式的公钥，一个公钥块中可以包含多个公钥
+func newGPGEncrypter(recipients []string) (*gpgEncrypter, error) {
+	e := &gpgEncrypter{}
+	for _, recipient := range recipients {
+		entities, err := openpgp.ReadArmoredKeyRing(strings.NewReader(recipient))
+		if err != nil {
+			return nil, fmt.Errorf("invalid gpg recipient: %w", err)
+		}
+		e.recipients = append(e.recipients, entities...)
+	}
+	if len(e.recipients) == 0 {
+		return nil, fmt.Errorf("at least one gpg recipient is required")
+	}
+	return e, nil
+}
+
+func (e *gpgEncrypter) encrypt(plaintext []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := openpgp.Encrypt(&buf, e.recipients, nil, &openpgp.FileHints{IsBinary: true}, nil)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(plaintext); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,222 @@
+package writer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/hatlonely/gox/ref"
+)
+
+func TestNewAgeEncrypter(t *testing.T) {
+	// age 文档中的示例公钥
+	if _, err := newAgeEncrypter([]string{"age1ql3z7hjy54pw3hyww5ayyfg7zqgvc7w3j2elw8zmrj2kg5sfn9aqmcac8p"}); err != nil {
+		t.Fatalf("newAgeEncrypter() error = %v", err)
+	}
+
+	for _, recipients := range [][]string{
+		{"age1ql3z7hjy54pw3hyww5ayyfg7zqgvc7w3j2elw8zmrj2kg5sfn9aqmcac8q"}, // 校验和错误
+		{"bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4"},                     // 前缀错误
+		{"age1"},
+		nil,
+	} {
+		if _, err := newAgeEncrypter(recipients); err == nil {
+			t.Errorf("expected error for %q", recipients)
+		}
+	}
+}
+
+func TestEncryptingWriter_Age(t *testing.T) {
+	identity1, recipient1 := newAgeTestKey(t)
+	identity2, recipient2 := newAgeTestKey(t)
+
+	out := &toggleWriter{}
+	w, err := NewEncryptingWriterFromWriter(out, EncryptionFormatAge, recipient1, recipient2)
+	if err != nil {
+		t.Fatalf("NewEncryptingWriterFromWriter() error = %v", err)
+	}
+
+	// 超过 64KiB 的日志块分多个 STREAM 块加密
+	large := bytes.Repeat([]byte("x"), 64*1024+10)
+	chunks := [][]byte{[]byte("line 1\n"), large, {}}
+	for _, chunk := range chunks {
+		if n, err := w.Write(chunk); err != nil || n != len(chunk) {
+			t.Fatalf("Write() = %d, %v", n, err)
+		}
+	}
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != len(chunks) {
+		t.Fatalf("expected %d lines, got %d", len(chunks), len(lines))
+	}
+	for i, line := range lines {
+		data, err := base64.StdEncoding.DecodeString(line)
+		if err != nil {
+			t.Fatalf("line %d is not base64: %v", i, err)
+		}
+		if !bytes.HasPrefix(data, []byte("age-encryption.org/v1\n")) {
+			t.Errorf("line %d does not start with age header", i)
+		}
+		for _, identity := range []*age.X25519Identity{identity1, identity2} {
+			plaintext, err := ageTestDecrypt(identity, data)
+			if err != nil {
+				t.Fatalf("decrypt line %d: %v", i, err)
+			}
+			if !bytes.Equal(plaintext, chunks[i]) {
+				t.Errorf("line %d: decrypted %d bytes, expected %d", i, len(plaintext), len(chunks[i]))
+			}
+		}
+
+		// 篡改的数据无法解密
+		data[len(data)-1] ^= 1
+		if _, err := ageTestDecrypt(identity1, data); err == nil {
+			t.Errorf("line %d: expected tampered data to fail", i)
+		}
+	}
+
+	// 同样的内容每次加密结果不同
+	out.buf.Reset()
+	w.Write([]byte("same"))
+	w.Write([]byte("same"))
+	if lines := strings.Split(out.String(), "\n"); lines[0] == lines[1] {
+		t.Error("expected different ciphertext for each chunk")
+	}
+
+	if err := w.Close(); err != nil || !out.closed {
+		t.Errorf("Close() = %v, closed = %v", err, out.closed)
+	}
+}
+
+func TestEncryptingWriter_GPG(t *testing.T) {
+	entity, err := openpgp.NewEntity("log", "", "log@example.com", nil)
+	if err != nil {
+		t.Fatalf("NewEntity() error = %v", err)
+	}
+	var key bytes.Buffer
+	armored, err := armor.Encode(&key, openpgp.PublicKeyType, nil)
+	if err != nil {
+		t.Fatalf("armor.Encode() error = %v", err)
+	}
+	if err := entity.Serialize(armored); err != nil {
+		t.Fatalf("Serialize() error = %v", err)
+	}
+	armored.Close()
+
+	out := &toggleWriter{}
+	w, err := NewEncryptingWriterFromWriter(out, EncryptionFormatGPG, key.String())
+	if err != nil {
+		t.Fatalf("NewEncryptingWriterFromWriter() error = %v", err)
+	}
+	if _, err := w.Write([]byte("secret log\n")); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSuffix(out.String(), "\n"))
+	if err != nil {
+		t.Fatalf("output is not base64: %v", err)
+	}
+	md, err := openpgp.ReadMessage(bytes.NewReader(data), openpgp.EntityList{entity}, nil, nil)
+	if err != nil {
+		t.Fatalf("ReadMessage() error = %v", err)
+	}
+	plaintext, err := io.ReadAll(md.UnverifiedBody)
+	if err != nil {
+		t.Fatalf("read body error = %v", err)
+	}
+	if string(plaintext) != "secret log\n" {
+		t.Errorf("expected decrypted log, got %q", plaintext)
+	}
+
+	if _, err := NewEncryptingWriterFromWriter(out, EncryptionFormatGPG, "not a key"); err == nil {
+		t.Error("expected error for invalid gpg key")
+	}
+}
+
+func TestEncryptingWriter_WithOptions(t *testing.T) {
+	identity, recipient := newAgeTestKey(t)
+	dir := t.TempDir()
+	recipientFile := filepath.Join(dir, "recipients.txt")
+	if err := os.WriteFile(recipientFile, []byte("# ops team\n"+recipient+"\n\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	logFile := filepath.Join(dir, "app.log.age")
+
+	w, err := NewWriterWithOptions(&ref.TypeOptions{
+		Namespace: "github.com/hatlonely/gox/log/writer",
+		Type:      "EncryptingWriter",
+		Options: &EncryptingWriterOptions{
+			Writer: ref.TypeOptions{
+				Namespace: "github.com/hatlonely/gox/log/writer",
+				Type:      "FileWriter",
+				Options:   &FileWriterOptions{Path: logFile},
+			},
+			Format:         EncryptionFormatAge,
+			RecipientFiles: []string{recipientFile},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewWriterWithOptions() error = %v", err)
+	}
+	w.Write([]byte("line 1\n"))
+	w.Write([]byte("line 2\n"))
+	w.Close()
+
+	f, err := os.Open(logFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var plaintext bytes.Buffer
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		data, err := base64.StdEncoding.DecodeString(scanner.Text())
+		if err != nil {
+			t.Fatal(err)
+		}
+		chunk, err := ageTestDecrypt(identity, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		plaintext.Write(chunk)
+	}
+	if plaintext.String() != "line 1\nline 2\n" {
+		t.Errorf("unexpected decrypted log %q", plaintext.String())
+	}
+
+	for _, options := range []*EncryptingWriterOptions{
+		{Writer: ref.TypeOptions{Namespace: "github.com/hatlonely/gox/log/writer", Type: "ConsoleWriter"}},
+		{Writer: ref.TypeOptions{Namespace: "github.com/hatlonely/gox/log/writer", Type: "ConsoleWriter"}, Format: "rot13", Recipients: []string{recipient}},
+		{Writer: ref.TypeOptions{Namespace: "github.com/hatlonely/gox/log/writer", Type: "ConsoleWriter"}, RecipientFiles: []string{filepath.Join(dir, "missing")}},
+		nil,
+	} {
+		if _, err := NewEncryptingWriterWithOptions(options); err == nil {
+			t.Errorf("expected error for options %+v", options)
+		}
+	}
+}
+
+// newAgeTestKey 生成 X25519 私钥和对应的 age1 公钥
+func newAgeTestKey(t *testing.T) (*age.X25519Identity, string) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return identity, identity.Recipient().String()
+}
+
+// ageTestDecrypt 用 X25519 私钥解密一条 age 消息
+func ageTestDecrypt(identity age.Identity, data []byte) ([]byte, error) {
+	r, err := age.Decrypt(bytes.NewReader(data), identity)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
@@ -0,0 +1,148 @@
+package writer
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hatlonely/gox/ref"
+)
+
+// FailoverWriterOptions 故障转移输出配置
+type FailoverWriterOptions struct {
+	// 输出器列表，按优先级排序，第一个为主输出器
+	Writers []ref.TypeOptions `cfg:"writers"`
+	// 当前输出器连续失败多少次后切换到下一个，小于等于 0 时为 3
+	FailureThreshold int `cfg:"failureThreshold" def:"3"`
+	// 切换后每隔多久尝试写入更高优先级的输出器，写入成功时切回，小于等于 0 时为 30s
+	ProbeInterval time.Duration `cfg:"probeInterval" def:"30s"`
+}
+
+// FailoverWriter 故障转移输出器，依次使用按优先级排列的输出器（如 Kafka → 本地文件）
+//
+// 当前输出器写入失败时，这条日志依次尝试后面的输出器，不会丢失；连续失败 FailureThreshold 次后切换到下一个输出器。
+// 切换后每隔 ProbeInterval 用一条日志尝试更高优先级的输出器，写入成功时切回
+type FailoverWriter struct {
+	writers          []Writer
+	failureThreshold int
+	probeInterval    time.Duration
+
+	mu        sync.Mutex
+	active    int
+	failures  int
+	lastProbe time.Time
+}
+
+// NewFailoverWriterWithOptions 创建故障转移输出器
+func NewFailoverWriterWithOptions(options *FailoverWriterOptions) (*FailoverWriter, error) {
+	if options == nil || len(options.Writers) == 0 {
+		return nil, fmt.Errorf("at least one writer is required")
+	}
+
+	writers := make([]Writer, 0, len(options.Writers))
+	for i, writerOpt := range options.Writers {
+		writer, err := NewWriterWithOptions(&writerOpt)
+		if err != nil {
+			for _, w := range writers {
+				w.Close()
+			}
+			return nil, fmt.Errorf("failed to create writer %d: %w", i, err)
+		}
+		writers = append(writers, writer)
+	}
+
+	return NewFailoverWriterFromWriters(options.FailureThreshold, options.ProbeInterval, writers...), nil
+}
+
+// NewFailoverWriterFromWriters 从已有的 Writer 创建故障转移输出器，writers 按优先级排序
+func NewFailoverWriterFromWriters(failureThreshold int, probeInterval time.Duration, writers ...Writer) *FailoverWriter {
+	if failureThreshold <= 0 {
+		failureThreshold = 3
+	}
+	if probeInterval <= 0 {
+		probeInterval = 30 * time.Second
+	}
+	return &FailoverWriter{
+		writers:          writers,
+		failureThreshold: failureThreshold,
+		probeInterval:    probeInterval,
+	}
+}
+
+// Write 实现 io.Writer 接口，所有输出器都失败时返回所有错误
+func (f *FailoverWriter) Write(p []byte) (n int, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if len(f.writers) == 0 {
+		return 0, fmt.Errorf("no writer available")
+	}
+
+	// 已切换到备用输出器时，定期尝试更高优先级的输出器
+	if f.active > 0 && time.Since(f.lastProbe) >= f.probeInterval {
+		f.lastProbe = time.Now()
+		for i := 0; i < f.active; i++ {
+			if err := writeFull(f.writers[i], p); err == nil {
+				f.active = i
+				f.failures = 0
+				return len(p), nil
+			}
+		}
+	}
+
+	var errs []error
+	for i := f.active; i < len(f.writers); i++ {
+		err := writeFull(f.writers[i], p)
+		if err == nil {
+			if i == f.active {
+				f.failures = 0
+			}
+			return len(p), nil
+		}
+		errs = append(errs, fmt.Errorf("writer %d failed: %w", i, err))
+
+		if i == f.active {
+			f.failures++
+			if f.failures >= f.failureThreshold && f.active < len(f.writers)-1 {
+				f.active++
+				f.failures = 0
+				f.lastProbe = time.Now()
+			}
+		}
+	}
+	return 0, errors.Join(errs...)
+}
+
+// Active 返回当前使用的输出器下标，0 为主输出器
+func (f *FailoverWriter) Active() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.active
+}
+
+// Close 实现 io.Closer 接口，关闭所有输出器
+func (f *FailoverWriter) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var errs []error
+	for i, writer := range f.writers {
+		if err := writer.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close writer %d: %w", i, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// writeFull 写入整条日志，部分写入视为失败
+func writeFull(w Writer, p []byte) error {
+	n, err := w.Write(p)
+	if err != nil {
+		return err
+	}
+	if n != len(p) {
+		return fmt.Errorf("short write: %d of %d bytes", n, len(p))
+	}
+	return nil
+}
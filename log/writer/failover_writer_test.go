@@ -0,0 +1,152 @@
+package writer
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hatlonely/gox/ref"
+)
+
+// toggleWriter 是用于测试的可以切换是否失败的 writer
+type toggleWriter struct {
+	mu     sync.Mutex
+	fail   bool
+	buf    bytes.Buffer
+	closed bool
+}
+
+func (w *toggleWriter) setFail(fail bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.fail = fail
+}
+
+func (w *toggleWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.String()
+}
+
+func (w *toggleWriter) Write(p []byte) (n int, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.fail {
+		return 0, fmt.Errorf("write failed")
+	}
+	return w.buf.Write(p)
+}
+
+func (w *toggleWriter) Close() error {
+	w.closed = true
+	return nil
+}
+
+func TestFailoverWriter_Failover(t *testing.T) {
+	primary, secondary := &toggleWriter{}, &toggleWriter{}
+	w := NewFailoverWriterFromWriters(2, 50*time.Millisecond, primary, secondary)
+
+	w.Write([]byte("a\n"))
+	primary.setFail(true)
+
+	// 未达到阈值前继续使用主输出器，失败的日志写入备用输出器
+	w.Write([]byte("b\n"))
+	if w.Active() != 0 {
+		t.Errorf("expected primary active after 1 failure, got %d", w.Active())
+	}
+	w.Write([]byte("c\n"))
+	if w.Active() != 1 {
+		t.Errorf("expected secondary active after 2 failures, got %d", w.Active())
+	}
+	w.Write([]byte("d\n"))
+
+	if primary.String() != "a\n" || secondary.String() != "b\nc\nd\n" {
+		t.Errorf("primary = %q, secondary = %q", primary.String(), secondary.String())
+	}
+
+	// 探测间隔内不尝试主输出器
+	primary.setFail(false)
+	w.Write([]byte("e\n"))
+	if w.Active() != 1 || primary.String() != "a\n" {
+		t.Errorf("expected no probe before interval, active = %d, primary = %q", w.Active(), primary.String())
+	}
+
+	// 探测成功后切回主输出器
+	time.Sleep(60 * time.Millisecond)
+	w.Write([]byte("f\n"))
+	if w.Active() != 0 || primary.String() != "a\nf\n" {
+		t.Errorf("expected fallback to primary, active = %d, primary = %q", w.Active(), primary.String())
+	}
+	if secondary.String() != "b\nc\nd\ne\n" {
+		t.Errorf("secondary = %q, want no duplicate of probed record", secondary.String())
+	}
+}
+
+func TestFailoverWriter_AllFailed(t *testing.T) {
+	primary, secondary := &toggleWriter{fail: true}, &toggleWriter{fail: true}
+	w := NewFailoverWriterFromWriters(1, time.Minute, primary, secondary)
+
+	if _, err := w.Write([]byte("a\n")); err == nil {
+		t.Fatal("expected error when all writers fail")
+	}
+	// 最后一个输出器失败时不再切换
+	for i := 0; i < 3; i++ {
+		w.Write([]byte("a\n"))
+	}
+	if w.Active() != 1 {
+		t.Errorf("expected last writer active, got %d", w.Active())
+	}
+
+	secondary.setFail(false)
+	if n, err := w.Write([]byte("b\n")); err != nil || n != 2 {
+		t.Errorf("Write() = %d, %v", n, err)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+	if !primary.closed || !secondary.closed {
+		t.Error("expected all writers closed")
+	}
+}
+
+func TestNewFailoverWriterWithOptions(t *testing.T) {
+	if _, err := NewFailoverWriterWithOptions(&FailoverWriterOptions{}); err == nil {
+		t.Error("expected error for empty writers")
+	}
+
+	path := filepath.Join(t.TempDir(), "fallback.log")
+	writer, err := NewWriterWithOptions(&ref.TypeOptions{
+		Namespace: "github.com/hatlonely/gox/log/writer",
+		Type:      "FailoverWriter",
+		Options: &FailoverWriterOptions{
+			Writers: []ref.TypeOptions{
+				{
+					Namespace: "github.com/hatlonely/gox/log/writer",
+					Type:      "FileWriter",
+					Options:   &FileWriterOptions{Path: path},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewWriterWithOptions() error = %v", err)
+	}
+	defer writer.Close()
+
+	failover := writer.(*FailoverWriter)
+	if failover.failureThreshold != 3 || failover.probeInterval != 30*time.Second {
+		t.Errorf("expected defaults, got %d, %v", failover.failureThreshold, failover.probeInterval)
+	}
+	if _, err := writer.Write([]byte("hello\n")); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil || string(content) != "hello\n" {
+		t.Errorf("content = %q, %v", content, err)
+	}
+}
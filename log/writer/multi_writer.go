@@ -28,7 +28,7 @@ func NewMultiWriterWithOptions(options *MultiWriterOptions) (*MultiWriter, error
 
 	for i, writerOpt := range options.Writers {
 		// 使用 ref 创建输出器
-		writerObj, err := ref.New(writerOpt.Namespace, writerOpt.Type, writerOpt.Options)
+		writerObj, err := ref.NewWithOptions(&writerOpt)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create writer %d: %w", i, err)
 		}
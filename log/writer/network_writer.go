@@ -0,0 +1,254 @@
+package writer
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/hatlonely/gox/cfg/commonopt"
+)
+
+// NetworkWriterOptions 网络输出配置
+type NetworkWriterOptions struct {
+	// 协议：tcp, udp
+	Protocol string `cfg:"protocol" def:"tcp" validate:"omitempty,oneof=tcp udp"`
+	// 地址，如 fluent-bit:5170
+	Address string `cfg:"address" validate:"required"`
+	// 每条日志的分隔符，日志末尾的换行替换为分隔符，默认为换行
+	Delimiter string `cfg:"delimiter"`
+	// TLS 配置，只用于 tcp
+	TLS *commonopt.TLSOptions `cfg:"tls"`
+	// 建立连接的超时时间，小于等于 0 时为 5s
+	DialTimeout time.Duration `cfg:"dialTimeout" def:"5s"`
+	// 写入超时时间，小于等于 0 时为 5s
+	WriteTimeout time.Duration `cfg:"writeTimeout" def:"5s"`
+	// 连接失败后第一次重连的等待时间，之后每次翻倍，小于等于 0 时为 1s
+	ReconnectInterval time.Duration `cfg:"reconnectInterval" def:"1s"`
+	// 重连等待时间的上限，小于等于 0 时为 30s
+	MaxReconnectInterval time.Duration `cfg:"maxReconnectInterval" def:"30s"`
+	// 断开连接期间最多缓存的日志条数，小于等于 0 时为 1000
+	BufferSize int `cfg:"bufferSize" def:"1000"`
+}
+
+// ErrNetworkBufferFull 断开连接期间缓存已满，日志被丢弃
+var ErrNetworkBufferFull = errors.New("network writer buffer is full")
+
+// NetworkWriter 网络输出器，通过 TCP 或 UDP 将日志发送到 Fluent Bit、Vector 等日志采集服务
+//
+// 连接断开或者写入失败时，日志缓存在内存中，到达重连时间后的下一次写入时重新连接，并按顺序补发缓存的日志；
+// 缓存已满时新的日志被丢弃并返回 ErrNetworkBufferFull。重连的等待时间从 ReconnectInterval 开始翻倍，
+// 不超过 MaxReconnectInterval，连接成功后重置
+type NetworkWriter struct {
+	protocol             string
+	address              string
+	delimiter            []byte
+	tlsConfig            *tls.Config
+	dialTimeout          time.Duration
+	writeTimeout         time.Duration
+	reconnectInterval    time.Duration
+	maxReconnectInterval time.Duration
+	bufferSize           int
+
+	mu            sync.Mutex
+	conn          net.Conn
+	buffer        [][]byte
+	nextDial      time.Time
+	retryInterval time.Duration
+	closed        bool
+}
+
+// NewNetworkWriterWithOptions 创建网络输出器，不在创建时建立连接，日志采集服务暂时不可用时不影响启动
+func NewNetworkWriterWithOptions(options *NetworkWriterOptions) (*NetworkWriter, error) {
+	if options == nil || options.Address == "" {
+		return nil, fmt.Errorf("network address is required")
+	}
+
+	protocol := options.Protocol
+	if protocol == "" {
+		protocol = "tcp"
+	}
+	if protocol != "tcp" && protocol != "udp" {
+		return nil, fmt.Errorf("unsupported protocol: %s", protocol)
+	}
+
+	tlsConfig, err := commonopt.NewTLSConfigWithOptions(options.TLS)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tls config: %w", err)
+	}
+	if tlsConfig != nil && protocol != "tcp" {
+		return nil, fmt.Errorf("tls is only supported for tcp")
+	}
+
+	w := &NetworkWriter{
+		protocol:             protocol,
+		address:              options.Address,
+		delimiter:            []byte(options.Delimiter),
+		tlsConfig:            tlsConfig,
+		dialTimeout:          options.DialTimeout,
+		writeTimeout:         options.WriteTimeout,
+		reconnectInterval:    options.ReconnectInterval,
+		maxReconnectInterval: options.MaxReconnectInterval,
+		bufferSize:           options.BufferSize,
+	}
+	if len(w.delimiter) == 0 {
+		w.delimiter = []byte("\n")
+	}
+	if w.dialTimeout <= 0 {
+		w.dialTimeout = 5 * time.Second
+	}
+	if w.writeTimeout <= 0 {
+		w.writeTimeout = 5 * time.Second
+	}
+	if w.reconnectInterval <= 0 {
+		w.reconnectInterval = time.Second
+	}
+	if w.maxReconnectInterval <= 0 {
+		w.maxReconnectInterval = 30 * time.Second
+	}
+	if w.bufferSize <= 0 {
+		w.bufferSize = 1000
+	}
+	w.retryInterval = w.reconnectInterval
+	return w, nil
+}
+
+// Write 实现 io.Writer 接口，日志已发送或者已缓存时返回成功
+func (w *NetworkWriter) Write(p []byte) (n int, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return 0, fmt.Errorf("network writer is closed")
+	}
+
+	frame := w.frame(p)
+	if w.conn == nil && !w.connect() {
+		return w.enqueue(p, frame)
+	}
+	if err := w.flush(); err != nil {
+		return w.enqueue(p, frame)
+	}
+	if err := w.send(frame); err != nil {
+		w.disconnect()
+		return w.enqueue(p, frame)
+	}
+	return len(p), nil
+}
+
+// Buffered 返回断开连接期间缓存的日志条数
+func (w *NetworkWriter) Buffered() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.buffer)
+}
+
+// Close 实现 io.Closer 接口，尝试发送缓存的日志后关闭连接，仍未发送的日志被丢弃
+func (w *NetworkWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	var err error
+	if len(w.buffer) > 0 {
+		if w.conn == nil {
+			w.nextDial = time.Time{}
+			w.connect()
+		}
+		if w.conn == nil {
+			err = fmt.Errorf("failed to connect to %s, %d buffered records dropped", w.address, len(w.buffer))
+		} else if ferr := w.flush(); ferr != nil {
+			err = fmt.Errorf("%d buffered records dropped: %w", len(w.buffer), ferr)
+		}
+		w.buffer = nil
+	}
+	if w.conn != nil {
+		err = errors.Join(err, w.conn.Close())
+		w.conn = nil
+	}
+	return err
+}
+
+// frame 将日志末尾的换行替换为分隔符
+func (w *NetworkWriter) frame(p []byte) []byte {
+	frame := make([]byte, 0, len(p)+len(w.delimiter))
+	frame = append(frame, bytes.TrimSuffix(p, []byte("\n"))...)
+	return append(frame, w.delimiter...)
+}
+
+// connect 到达重连时间时建立连接，失败时将下一次重连的等待时间翻倍
+func (w *NetworkWriter) connect() bool {
+	if time.Now().Before(w.nextDial) {
+		return false
+	}
+
+	dialer := &net.Dialer{Timeout: w.dialTimeout}
+	var conn net.Conn
+	var err error
+	if w.tlsConfig != nil {
+		conn, err = tls.DialWithDialer(dialer, w.protocol, w.address, w.tlsConfig)
+	} else {
+		conn, err = dialer.Dial(w.protocol, w.address)
+	}
+	if err != nil {
+		w.nextDial = time.Now().Add(w.retryInterval)
+		w.retryInterval = min(w.retryInterval*2, w.maxReconnectInterval)
+		return false
+	}
+
+	w.conn = conn
+	w.retryInterval = w.reconnectInterval
+	return true
+}
+
+// disconnect 关闭出错的连接，等待 ReconnectInterval 后重连
+func (w *NetworkWriter) disconnect() {
+	if w.conn != nil {
+		w.conn.Close()
+		w.conn = nil
+	}
+	w.nextDial = time.Now().Add(w.retryInterval)
+	w.retryInterval = min(w.retryInterval*2, w.maxReconnectInterval)
+}
+
+// flush 按顺序发送缓存的日志，失败时断开连接，未发送的日志保留在缓存中
+func (w *NetworkWriter) flush() error {
+	for len(w.buffer) > 0 {
+		if err := w.send(w.buffer[0]); err != nil {
+			w.disconnect()
+			return err
+		}
+		w.buffer[0] = nil
+		w.buffer = w.buffer[1:]
+	}
+	w.buffer = nil
+	return nil
+}
+
+// send 发送一条日志，TCP 部分写入时重连后重新发送整条日志
+func (w *NetworkWriter) send(frame []byte) error {
+	if err := w.conn.SetWriteDeadline(time.Now().Add(w.writeTimeout)); err != nil {
+		return err
+	}
+	n, err := w.conn.Write(frame)
+	if err == nil && n < len(frame) {
+		err = fmt.Errorf("short write: %d/%d", n, len(frame))
+	}
+	return err
+}
+
+// enqueue 缓存未发送的日志，缓存已满时丢弃并返回错误
+func (w *NetworkWriter) enqueue(p []byte, frame []byte) (int, error) {
+	if len(w.buffer) >= w.bufferSize {
+		return 0, ErrNetworkBufferFull
+	}
+	w.buffer = append(w.buffer, frame)
+	return len(p), nil
+}
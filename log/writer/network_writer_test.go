@@ -0,0 +1,176 @@
+package writer
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hatlonely/gox/ref"
+)
+
+// startLineServer 启动 TCP 服务器，按行收集收到的数据
+func startLineServer(t *testing.T, address string) (string, <-chan string) {
+	t.Helper()
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		t.Fatalf("net.Listen() error = %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	lines := make(chan string, 100)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				scanner := bufio.NewScanner(conn)
+				for scanner.Scan() {
+					lines <- scanner.Text()
+				}
+			}()
+		}
+	}()
+	return listener.Addr().String(), lines
+}
+
+func receiveLines(t *testing.T, lines <-chan string, n int) []string {
+	t.Helper()
+	var result []string
+	for len(result) < n {
+		select {
+		case line := <-lines:
+			result = append(result, line)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timeout waiting for lines, got %v", result)
+		}
+	}
+	return result
+}
+
+func TestNetworkWriter_TCP(t *testing.T) {
+	address, lines := startLineServer(t, "127.0.0.1:0")
+
+	w, err := NewNetworkWriterWithOptions(&NetworkWriterOptions{Address: address})
+	if err != nil {
+		t.Fatalf("NewNetworkWriterWithOptions() error = %v", err)
+	}
+	defer w.Close()
+
+	for _, msg := range []string{"first\n", "second"} {
+		if n, err := w.Write([]byte(msg)); err != nil || n != len(msg) {
+			t.Fatalf("Write() = %d, %v", n, err)
+		}
+	}
+	if got := receiveLines(t, lines, 2); got[0] != "first" || got[1] != "second" {
+		t.Errorf("received %v", got)
+	}
+}
+
+func TestNetworkWriter_Reconnect(t *testing.T) {
+	// 获取一个空闲端口，服务器稍后启动
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen() error = %v", err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	w, err := NewNetworkWriterWithOptions(&NetworkWriterOptions{
+		Address:           address,
+		ReconnectInterval: 10 * time.Millisecond,
+		BufferSize:        2,
+	})
+	if err != nil {
+		t.Fatalf("NewNetworkWriterWithOptions() error = %v", err)
+	}
+	defer w.Close()
+
+	// 连接失败时缓存日志，缓存已满时丢弃
+	for _, msg := range []string{"a\n", "b\n"} {
+		if _, err := w.Write([]byte(msg)); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if _, err := w.Write([]byte("c\n")); !errors.Is(err, ErrNetworkBufferFull) {
+		t.Fatalf("expected ErrNetworkBufferFull, got %v", err)
+	}
+	if w.Buffered() != 2 {
+		t.Errorf("Buffered() = %d, want 2", w.Buffered())
+	}
+
+	// 服务恢复后按顺序补发缓存的日志
+	_, lines := startLineServer(t, address)
+	time.Sleep(50 * time.Millisecond)
+	if _, err := w.Write([]byte("d\n")); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if got := receiveLines(t, lines, 3); strings.Join(got, ",") != "a,b,d" {
+		t.Errorf("received %v", got)
+	}
+	if w.Buffered() != 0 {
+		t.Errorf("Buffered() = %d, want 0", w.Buffered())
+	}
+}
+
+func TestNetworkWriter_UDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.ListenPacket() error = %v", err)
+	}
+	defer conn.Close()
+
+	w, err := NewWriterWithOptions(&ref.TypeOptions{
+		Namespace: "github.com/hatlonely/gox/log/writer",
+		Type:      "NetworkWriter",
+		Options: &NetworkWriterOptions{
+			Protocol:  "udp",
+			Address:   conn.LocalAddr().String(),
+			Delimiter: "\x00",
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewWriterWithOptions() error = %v", err)
+	}
+	defer w.Close()
+
+	if _, err := w.Write([]byte(`{"msg":"hello"}` + "\n")); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	buf := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("ReadFrom() error = %v", err)
+	}
+	if got := string(buf[:n]); got != `{"msg":"hello"}`+"\x00" {
+		t.Errorf("received %q", got)
+	}
+}
+
+func TestNetworkWriter_InvalidOptions(t *testing.T) {
+	tests := []*NetworkWriterOptions{
+		nil,
+		{Protocol: "tcp"},
+		{Protocol: "unix", Address: "/tmp/log.sock"},
+	}
+	for _, options := range tests {
+		if _, err := NewNetworkWriterWithOptions(options); err == nil {
+			t.Errorf("expected error for %+v", options)
+		}
+	}
+
+	w, err := NewNetworkWriterWithOptions(&NetworkWriterOptions{Address: "127.0.0.1:1"})
+	if err != nil {
+		t.Fatalf("NewNetworkWriterWithOptions() error = %v", err)
+	}
+	w.Close()
+	if _, err := w.Write([]byte("closed\n")); err == nil {
+		t.Error("expected error after close")
+	}
+}
@@ -12,10 +12,16 @@ func init() {
 	ref.MustRegisterT[ConsoleWriter](NewConsoleWriterWithOptions)
 	ref.MustRegisterT[FileWriter](NewFileWriterWithOptions)
 	ref.MustRegisterT[MultiWriter](NewMultiWriterWithOptions)
+	ref.MustRegisterT[FailoverWriter](NewFailoverWriterWithOptions)
+	ref.MustRegisterT[NetworkWriter](NewNetworkWriterWithOptions)
+	ref.MustRegisterT[EncryptingWriter](NewEncryptingWriterWithOptions)
 
 	ref.MustRegisterT[*ConsoleWriter](NewConsoleWriterWithOptions)
 	ref.MustRegisterT[*FileWriter](NewFileWriterWithOptions)
 	ref.MustRegisterT[*MultiWriter](NewMultiWriterWithOptions)
+	ref.MustRegisterT[*FailoverWriter](NewFailoverWriterWithOptions)
+	ref.MustRegisterT[*NetworkWriter](NewNetworkWriterWithOptions)
+	ref.MustRegisterT[*EncryptingWriter](NewEncryptingWriterWithOptions)
 }
 
 // Writer 日志输出器接口
@@ -34,7 +40,7 @@ func NewWriterWithOptions(options *ref.TypeOptions) (Writer, error) {
 		}
 	}
 
-	writer, err := ref.New(actualOptions.Namespace, actualOptions.Type, actualOptions.Options)
+	writer, err := ref.NewWithOptions(actualOptions)
 	if err != nil {
 		return nil, errors.WithMessage(err, "refx.NewT failed")
 	}
@@ -4,15 +4,18 @@ package aggregation
 type AggregationType string
 
 const (
-	AggTypeSum         AggregationType = "sum"
-	AggTypeAvg         AggregationType = "avg"
-	AggTypeMax         AggregationType = "max"
-	AggTypeMin         AggregationType = "min"
-	AggTypeCount       AggregationType = "count"
-	AggTypeTerms       AggregationType = "terms"
-	AggTypeHistogram   AggregationType = "histogram"
-	AggTypeDateHisto   AggregationType = "date_histogram"
-	AggTypeComposite   AggregationType = "composite"
+	AggTypeSum          AggregationType = "sum"
+	AggTypeAvg          AggregationType = "avg"
+	AggTypeMax          AggregationType = "max"
+	AggTypeMin          AggregationType = "min"
+	AggTypeCount        AggregationType = "count"
+	AggTypeTerms        AggregationType = "terms"
+	AggTypeHistogram    AggregationType = "histogram"
+	AggTypeDateHisto    AggregationType = "date_histogram"
+	AggTypeComposite    AggregationType = "composite"
+	AggTypePipeline     AggregationType = "pipeline"
+	AggTypeLookup       AggregationType = "lookup"
+	AggTypeBucketScript AggregationType = "bucket_script"
 )
 
 // Aggregation 聚合接口
@@ -25,4 +28,3 @@ type Aggregation interface {
 	ToSQL() (string, []interface{}, error)
 	ToMongo() (map[string]interface{}, error)
 }
-
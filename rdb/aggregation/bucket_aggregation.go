@@ -11,6 +11,40 @@ func (b *BucketAggregation) Name() string {
 	return b.AggName
 }
 
+// GetSubAggregations 获取子聚合
+func (b *BucketAggregation) GetSubAggregations() []Aggregation {
+	return b.SubAggregations
+}
+
+// BucketAggregator 可嵌套子聚合的桶聚合
+// 数据库后端通过分组表达式逐层展开桶聚合，子聚合结果挂在对应的父桶下
+type BucketAggregator interface {
+	Aggregation
+
+	// SQLGroupExpr SQL 分组表达式
+	SQLGroupExpr() string
+
+	// MongoGroupExpr Mongo $group 阶段的 _id 表达式
+	MongoGroupExpr() interface{}
+
+	// GetSubAggregations 获取子聚合
+	GetSubAggregations() []Aggregation
+}
+
+// SplitAggregations 将聚合拆分为指标聚合和桶聚合
+func SplitAggregations(aggs []Aggregation) ([]Aggregation, []BucketAggregator) {
+	var metrics []Aggregation
+	var buckets []BucketAggregator
+	for _, agg := range aggs {
+		if bucket, ok := agg.(BucketAggregator); ok {
+			buckets = append(buckets, bucket)
+		} else {
+			metrics = append(metrics, agg)
+		}
+	}
+	return metrics, buckets
+}
+
 // 构建子聚合的通用方法
 func buildSubAggregations(subAggs []Aggregation) map[string]interface{} {
 	if len(subAggs) == 0 {
@@ -32,7 +66,10 @@ func buildSubAggregationsSQL(subAggs []Aggregation) ([]string, []interface{}, er
 	var sqls []string
 	var args []interface{}
 	
-	for _, subAgg := range subAggs {
+	// 嵌套的桶聚合由数据库后端逐层展开，派生指标在查询后计算，这里只处理指标聚合
+	_, subAggs = SplitScriptAggregations(subAggs)
+	metrics, _ := SplitAggregations(subAggs)
+	for _, subAgg := range metrics {
 		sql, subArgs, err := subAgg.ToSQL()
 		if err != nil {
 			return nil, nil, err
@@ -50,7 +87,9 @@ func buildSubAggregationsMongo(subAggs []Aggregation) (map[string]interface{}, e
 	}
 	
 	pipeline := make(map[string]interface{})
-	for _, subAgg := range subAggs {
+	_, subAggs = SplitScriptAggregations(subAggs)
+	metrics, _ := SplitAggregations(subAggs)
+	for _, subAgg := range metrics {
 		subResult, err := subAgg.ToMongo()
 		if err != nil {
 			return nil, err
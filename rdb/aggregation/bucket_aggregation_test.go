@@ -1 +1,108 @@
-package aggregation
\ No newline at end of file
+package aggregation
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitAggregations(t *testing.T) {
+	avgAgg := &AvgAggregation{MetricAggregation: MetricAggregation{AggName: "avg_score", Field: "score"}}
+	termsAgg := &TermsAggregation{BucketAggregation: BucketAggregation{AggName: "by_age", Field: "age"}}
+	dateHistoAgg := &DateHistogramAggregation{
+		BucketAggregation: BucketAggregation{AggName: "by_month", Field: "created_at"},
+		Interval:          "1M",
+	}
+
+	metrics, buckets := SplitAggregations([]Aggregation{avgAgg, termsAgg, dateHistoAgg})
+	if len(metrics) != 1 || metrics[0] != avgAgg {
+		t.Errorf("Expected 1 metric aggregation, got %v", metrics)
+	}
+	if len(buckets) != 2 || buckets[0] != termsAgg || buckets[1] != dateHistoAgg {
+		t.Errorf("Expected 2 bucket aggregations, got %v", buckets)
+	}
+}
+
+func TestBucketAggregator_GroupExpr(t *testing.T) {
+	termsAgg := &TermsAggregation{BucketAggregation: BucketAggregation{AggName: "by_age", Field: "age"}}
+	if expr := termsAgg.SQLGroupExpr(); expr != "age" {
+		t.Errorf("Expected SQL group expr 'age', got %s", expr)
+	}
+	if expr := termsAgg.MongoGroupExpr(); expr != "$age" {
+		t.Errorf("Expected Mongo group expr '$age', got %v", expr)
+	}
+
+	dateHistoAgg := &DateHistogramAggregation{
+		BucketAggregation: BucketAggregation{AggName: "by_month", Field: "created_at"},
+		Interval:          "1M",
+	}
+	if expr := dateHistoAgg.SQLGroupExpr(); expr != "DATE_FORMAT(created_at, '%Y-%m-01')" {
+		t.Errorf("Expected monthly SQL group expr, got %s", expr)
+	}
+	expected := map[string]interface{}{
+		"$dateToString": map[string]interface{}{
+			"format": "%Y-%m",
+			"date":   "$created_at",
+		},
+	}
+	if expr := dateHistoAgg.MongoGroupExpr(); !reflect.DeepEqual(expr, expected) {
+		t.Errorf("Expected %v, got %v", expected, expr)
+	}
+}
+
+func TestBucketAggregation_NestedSubAggregations(t *testing.T) {
+	cityAgg := &TermsAggregation{
+		BucketAggregation: BucketAggregation{
+			AggName: "by_city",
+			Field:   "city",
+			SubAggregations: []Aggregation{
+				&AvgAggregation{MetricAggregation: MetricAggregation{AggName: "city_avg_score", Field: "score"}},
+			},
+		},
+	}
+	agg := &TermsAggregation{
+		BucketAggregation: BucketAggregation{
+			AggName: "by_age",
+			Field:   "age",
+			SubAggregations: []Aggregation{
+				&AvgAggregation{MetricAggregation: MetricAggregation{AggName: "avg_score", Field: "score"}},
+				cityAgg,
+			},
+		},
+	}
+
+	// ES 原生支持嵌套聚合
+	aggs := agg.ToES()["aggs"].(map[string]interface{})
+	if _, ok := aggs["avg_score"]; !ok {
+		t.Error("Expected 'avg_score' in ES sub aggregations")
+	}
+	nested, ok := aggs["by_city"].(map[string]interface{})
+	if !ok || nested["aggs"] == nil {
+		t.Errorf("Expected nested 'by_city' aggregation with sub aggregations, got %v", aggs["by_city"])
+	}
+
+	// SQL/Mongo 只展开指标子聚合，嵌套桶聚合由数据库后端逐层执行
+	sql, _, err := agg.ToSQL()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if strings.Contains(sql, "city") {
+		t.Errorf("Expected nested bucket aggregation to be skipped in SQL, got: %s", sql)
+	}
+	if !strings.Contains(sql, "AVG(score) AS avg_score") {
+		t.Errorf("Expected SQL to contain metric sub aggregation, got: %s", sql)
+	}
+
+	mongo, err := agg.ToMongo()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	pipeline := mongo["$facet"].(map[string]interface{})["by_age"].([]interface{})
+	group := pipeline[0].(map[string]interface{})["$group"].(map[string]interface{})
+	if _, ok := group["by_city"]; ok {
+		t.Error("Expected nested bucket aggregation to be skipped in Mongo $group")
+	}
+	if _, ok := group["avg_score"]; !ok {
+		t.Error("Expected 'avg_score' in Mongo $group")
+	}
+}
@@ -0,0 +1,384 @@
+package aggregation
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ScriptAggregator 由同级聚合结果计算的派生指标，不参与数据库查询，在同级指标聚合之后计算
+type ScriptAggregator interface {
+	Aggregation
+
+	// Validate 检查表达式是否合法
+	Validate() error
+
+	// Evaluate 计算派生指标，value 获取同级聚合的结果，变量缺失或除数为 0 时返回 false
+	Evaluate(value func(aggName string) (float64, bool)) (float64, bool, error)
+}
+
+// SplitScriptAggregations 将派生指标从聚合列表中拆分出来
+func SplitScriptAggregations(aggs []Aggregation) ([]ScriptAggregator, []Aggregation) {
+	var scripts []ScriptAggregator
+	var others []Aggregation
+	for _, agg := range aggs {
+		if script, ok := agg.(ScriptAggregator); ok {
+			scripts = append(scripts, script)
+		} else {
+			others = append(others, agg)
+		}
+	}
+	return scripts, others
+}
+
+// ValidateScriptAggregations 递归检查聚合（包括桶聚合的子聚合）中的派生指标表达式
+func ValidateScriptAggregations(aggs []Aggregation) error {
+	for _, agg := range aggs {
+		if script, ok := agg.(ScriptAggregator); ok {
+			if err := script.Validate(); err != nil {
+				return err
+			}
+		}
+		if bucket, ok := agg.(BucketAggregator); ok {
+			if err := ValidateScriptAggregations(bucket.GetSubAggregations()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// BucketScriptAggregation 派生指标，按表达式由同级的其他聚合结果计算，如 error_rate = errors / total
+//
+// Script 支持 + - * /、括号、数字和变量，变量通过 BucketsPath 映射到同级聚合名，BucketsPath 为空时变量名即聚合名；
+// 桶内可以用 _count 引用桶的文档数。任一变量缺失或除数为 0 时不输出结果。
+// Elasticsearch 中作为桶聚合的子聚合时转换为 bucket_script 由服务端计算，其余情况在客户端计算
+type BucketScriptAggregation struct {
+	AggName     string
+	Script      string
+	BucketsPath map[string]string // 变量名 -> 同级聚合名
+}
+
+func (a *BucketScriptAggregation) Type() AggregationType {
+	return AggTypeBucketScript
+}
+
+func (a *BucketScriptAggregation) Name() string {
+	return a.AggName
+}
+
+func (a *BucketScriptAggregation) Validate() error {
+	_, err := a.parse()
+	return err
+}
+
+func (a *BucketScriptAggregation) Evaluate(value func(aggName string) (float64, bool)) (float64, bool, error) {
+	expr, err := a.parse()
+	if err != nil {
+		return 0, false, err
+	}
+	v, ok := expr.eval(func(variable string) (float64, bool) {
+		return value(a.path(variable))
+	})
+	return v, ok, nil
+}
+
+// ToES 转换为 bucket_script，表达式无效时返回 nil，由 Validate 报告错误
+func (a *BucketScriptAggregation) ToES() map[string]interface{} {
+	expr, err := a.parse()
+	if err != nil {
+		return nil
+	}
+
+	bucketsPath := make(map[string]interface{})
+	for _, variable := range variables(expr) {
+		bucketsPath[variable] = a.path(variable)
+	}
+
+	// 除数为 0 时返回 null，ES 不为该桶输出结果，与客户端计算一致
+	var guards []string
+	for _, divisor := range divisors(expr) {
+		guards = append(guards, divisor.painless()+" == 0")
+	}
+	script := "return " + expr.painless() + ";"
+	if len(guards) > 0 {
+		script = "if (" + strings.Join(guards, " || ") + ") { return null; } " + script
+	}
+
+	return map[string]interface{}{
+		"bucket_script": map[string]interface{}{
+			"buckets_path": bucketsPath,
+			"script":       script,
+		},
+	}
+}
+
+func (a *BucketScriptAggregation) ToSQL() (string, []interface{}, error) {
+	return "", nil, fmt.Errorf("bucket script aggregation %s is evaluated from other aggregation results and has no sql", a.AggName)
+}
+
+func (a *BucketScriptAggregation) ToMongo() (map[string]interface{}, error) {
+	return nil, fmt.Errorf("bucket script aggregation %s is evaluated from other aggregation results and has no mongo stage", a.AggName)
+}
+
+// path 变量对应的聚合名
+func (a *BucketScriptAggregation) path(variable string) string {
+	if p, ok := a.BucketsPath[variable]; ok {
+		return p
+	}
+	return variable
+}
+
+func (a *BucketScriptAggregation) parse() (scriptExpr, error) {
+	p := &scriptParser{src: a.Script}
+	expr, err := p.parse()
+	if err != nil {
+		return nil, fmt.Errorf("invalid bucket script %q of aggregation %s: %v", a.Script, a.AggName, err)
+	}
+	return expr, nil
+}
+
+// scriptExpr 派生指标表达式的语法树节点
+type scriptExpr interface {
+	eval(value func(variable string) (float64, bool)) (float64, bool)
+	painless() string
+	walk(fn func(scriptExpr))
+}
+
+type scriptNumber float64
+
+type scriptVariable string
+
+type scriptNeg struct {
+	x scriptExpr
+}
+
+type scriptBinary struct {
+	op   byte
+	l, r scriptExpr
+}
+
+func (e scriptNumber) eval(func(string) (float64, bool)) (float64, bool) {
+	return float64(e), true
+}
+
+func (e scriptVariable) eval(value func(string) (float64, bool)) (float64, bool) {
+	return value(string(e))
+}
+
+func (e *scriptNeg) eval(value func(string) (float64, bool)) (float64, bool) {
+	v, ok := e.x.eval(value)
+	return -v, ok
+}
+
+func (e *scriptBinary) eval(value func(string) (float64, bool)) (float64, bool) {
+	l, ok := e.l.eval(value)
+	if !ok {
+		return 0, false
+	}
+	r, ok := e.r.eval(value)
+	if !ok {
+		return 0, false
+	}
+	switch e.op {
+	case '+':
+		return l + r, true
+	case '-':
+		return l - r, true
+	case '*':
+		return l * r, true
+	default:
+		if r == 0 {
+			return 0, false
+		}
+		return l / r, true
+	}
+}
+
+// painless 数字统一输出为浮点字面量，避免 Painless 整数除法
+func (e scriptNumber) painless() string {
+	s := strconv.FormatFloat(float64(e), 'f', -1, 64)
+	if !strings.Contains(s, ".") {
+		s += ".0"
+	}
+	return s
+}
+
+func (e scriptVariable) painless() string {
+	return "params." + string(e)
+}
+
+func (e *scriptNeg) painless() string {
+	return "(-" + e.x.painless() + ")"
+}
+
+func (e *scriptBinary) painless() string {
+	return "(" + e.l.painless() + " " + string(e.op) + " " + e.r.painless() + ")"
+}
+
+func (e scriptNumber) walk(fn func(scriptExpr))   { fn(e) }
+func (e scriptVariable) walk(fn func(scriptExpr)) { fn(e) }
+
+func (e *scriptNeg) walk(fn func(scriptExpr)) {
+	fn(e)
+	e.x.walk(fn)
+}
+
+func (e *scriptBinary) walk(fn func(scriptExpr)) {
+	fn(e)
+	e.l.walk(fn)
+	e.r.walk(fn)
+}
+
+// variables 表达式引用的变量，按名称排序
+func variables(expr scriptExpr) []string {
+	seen := make(map[string]bool)
+	var names []string
+	expr.walk(func(e scriptExpr) {
+		if v, ok := e.(scriptVariable); ok && !seen[string(v)] {
+			seen[string(v)] = true
+			names = append(names, string(v))
+		}
+	})
+	sort.Strings(names)
+	return names
+}
+
+// divisors 表达式中所有除法的除数
+func divisors(expr scriptExpr) []scriptExpr {
+	var result []scriptExpr
+	expr.walk(func(e scriptExpr) {
+		if b, ok := e.(*scriptBinary); ok && b.op == '/' {
+			result = append(result, b.r)
+		}
+	})
+	return result
+}
+
+// scriptParser 派生指标表达式的递归下降解析器
+//
+//	expr   = term { ("+" | "-") term }
+//	term   = unary { ("*" | "/") unary }
+//	unary  = "-" unary | factor
+//	factor = number | variable | "(" expr ")"
+type scriptParser struct {
+	src string
+	pos int
+}
+
+func (p *scriptParser) parse() (scriptExpr, error) {
+	expr, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if p.skipSpace(); p.pos < len(p.src) {
+		return nil, fmt.Errorf("unexpected %q at %d", p.src[p.pos], p.pos)
+	}
+	return expr, nil
+}
+
+func (p *scriptParser) parseExpr() (scriptExpr, error) {
+	l, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek('+') || p.peek('-') {
+		op := p.next()
+		r, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		l = &scriptBinary{op: op, l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *scriptParser) parseTerm() (scriptExpr, error) {
+	l, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek('*') || p.peek('/') {
+		op := p.next()
+		r, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		l = &scriptBinary{op: op, l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *scriptParser) parseUnary() (scriptExpr, error) {
+	if p.peek('-') {
+		p.next()
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &scriptNeg{x: x}, nil
+	}
+	return p.parseFactor()
+}
+
+func (p *scriptParser) parseFactor() (scriptExpr, error) {
+	p.skipSpace()
+	if p.pos >= len(p.src) {
+		return nil, fmt.Errorf("unexpected end of script")
+	}
+
+	c := p.src[p.pos]
+	switch {
+	case c == '(':
+		p.pos++
+		expr, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.peek(')') {
+			return nil, fmt.Errorf("missing ) at %d", p.pos)
+		}
+		p.pos++
+		return expr, nil
+	case c >= '0' && c <= '9' || c == '.':
+		start := p.pos
+		for p.pos < len(p.src) && (p.src[p.pos] >= '0' && p.src[p.pos] <= '9' || p.src[p.pos] == '.') {
+			p.pos++
+		}
+		v, err := strconv.ParseFloat(p.src[start:p.pos], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q at %d", p.src[start:p.pos], start)
+		}
+		return scriptNumber(v), nil
+	case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+		start := p.pos
+		for p.pos < len(p.src) && isScriptIdentChar(p.src[p.pos]) {
+			p.pos++
+		}
+		return scriptVariable(p.src[start:p.pos]), nil
+	}
+	return nil, fmt.Errorf("unexpected %q at %d", c, p.pos)
+}
+
+// peek 跳过空白后判断下一个字符
+func (p *scriptParser) peek(c byte) bool {
+	p.skipSpace()
+	return p.pos < len(p.src) && p.src[p.pos] == c
+}
+
+func (p *scriptParser) next() byte {
+	c := p.src[p.pos]
+	p.pos++
+	return c
+}
+
+func (p *scriptParser) skipSpace() {
+	for p.pos < len(p.src) && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t' || p.src[p.pos] == '\n') {
+		p.pos++
+	}
+}
+
+func isScriptIdentChar(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
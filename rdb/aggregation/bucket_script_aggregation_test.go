@@ -0,0 +1,118 @@
+package aggregation
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBucketScriptAggregation_Evaluate(t *testing.T) {
+	values := map[string]float64{"errors": 5, "total": 200, "_count": 4, "zero": 0}
+	value := func(aggName string) (float64, bool) {
+		v, ok := values[aggName]
+		return v, ok
+	}
+
+	tests := []struct {
+		name        string
+		script      string
+		bucketsPath map[string]string
+		expected    float64
+		ok          bool
+	}{
+		{"除法", "errors / total", nil, 0.025, true},
+		{"优先级", "errors + total * 2 - 1", nil, 404, true},
+		{"括号与负号", "-(errors - total) / _count", nil, 48.75, true},
+		{"变量映射", "e / t * 100", map[string]string{"e": "errors", "t": "total"}, 2.5, true},
+		{"小数", "total * 0.5", nil, 100, true},
+		{"变量缺失", "errors / missing", nil, 0, false},
+		{"除数为 0", "errors / zero", nil, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			agg := &BucketScriptAggregation{AggName: "rate", Script: tt.script, BucketsPath: tt.bucketsPath}
+			v, ok, err := agg.Evaluate(value)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if ok != tt.ok || v != tt.expected {
+				t.Errorf("Expected (%v, %v), got (%v, %v)", tt.expected, tt.ok, v, ok)
+			}
+		})
+	}
+}
+
+func TestBucketScriptAggregation_Invalid(t *testing.T) {
+	for _, script := range []string{"", "a +", "(a / b", "a b", "a % b", "1..2"} {
+		agg := &BucketScriptAggregation{AggName: "rate", Script: script}
+		if err := agg.Validate(); err == nil {
+			t.Errorf("Expected error for script %q", script)
+		}
+		if agg.ToES() != nil {
+			t.Errorf("Expected nil ES aggregation for script %q", script)
+		}
+	}
+
+	terms := &TermsAggregation{BucketAggregation: BucketAggregation{
+		AggName:         "by_status",
+		Field:           "status",
+		SubAggregations: []Aggregation{&BucketScriptAggregation{AggName: "rate", Script: "a /"}},
+	}}
+	if err := ValidateScriptAggregations([]Aggregation{terms}); err == nil {
+		t.Error("Expected error for nested invalid script")
+	}
+}
+
+func TestBucketScriptAggregation_ToES(t *testing.T) {
+	agg := &BucketScriptAggregation{
+		AggName:     "error_rate",
+		Script:      "e / (total - 1) * 100",
+		BucketsPath: map[string]string{"e": "errors"},
+	}
+
+	expected := map[string]interface{}{
+		"bucket_script": map[string]interface{}{
+			"buckets_path": map[string]interface{}{"e": "errors", "total": "total"},
+			"script":       "if ((params.total - 1.0) == 0) { return null; } return ((params.e / (params.total - 1.0)) * 100.0);",
+		},
+	}
+	if result := agg.ToES(); !reflect.DeepEqual(result, expected) {
+		t.Errorf("Expected %v, got %v", expected, result)
+	}
+
+	if _, _, err := agg.ToSQL(); err == nil {
+		t.Error("Expected error for ToSQL")
+	}
+	if _, err := agg.ToMongo(); err == nil {
+		t.Error("Expected error for ToMongo")
+	}
+}
+
+func TestBucketScriptAggregation_SubAggregation(t *testing.T) {
+	terms := &TermsAggregation{BucketAggregation: BucketAggregation{
+		AggName: "by_status",
+		Field:   "status",
+		SubAggregations: []Aggregation{
+			&SumAggregation{MetricAggregation: MetricAggregation{AggName: "errors", Field: "errors"}},
+			&BucketScriptAggregation{AggName: "rate", Script: "errors / _count"},
+		},
+	}}
+
+	es := terms.ToES()
+	subAggs := es["aggs"].(map[string]interface{})
+	if _, ok := subAggs["rate"].(map[string]interface{})["bucket_script"]; !ok {
+		t.Errorf("Expected bucket_script sub aggregation, got %v", subAggs["rate"])
+	}
+
+	sql, _, err := terms.ToSQL()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if sql != "GROUP BY status SUM(errors) AS errors" {
+		t.Errorf("Unexpected sql: %s", sql)
+	}
+
+	if _, err := terms.ToMongo(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
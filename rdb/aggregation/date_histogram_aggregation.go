@@ -17,6 +17,16 @@ func (a *DateHistogramAggregation) Type() AggregationType {
 	return AggTypeDateHisto
 }
 
+// Validate 检查 SQL 和 MongoDB 是否支持该间隔，只支持 1h、1d、1M、1y（或 hour、day、month、year）
+// Elasticsearch 支持更多的间隔，只用于 Elasticsearch 时不需要检查
+func (a *DateHistogramAggregation) Validate() error {
+	switch a.Interval {
+	case "1h", "hour", "1d", "day", "1M", "month", "1y", "year":
+		return nil
+	}
+	return fmt.Errorf("date histogram aggregation %s: unsupported interval %q, expected 1h, 1d, 1M or 1y", a.AggName, a.Interval)
+}
+
 func (a *DateHistogramAggregation) ToES() map[string]interface{} {
 	dateHisto := map[string]interface{}{
 		"field":    a.Field,
@@ -42,7 +52,7 @@ func (a *DateHistogramAggregation) ToES() map[string]interface{} {
 	return result
 }
 
-func (a *DateHistogramAggregation) ToSQL() (string, []interface{}, error) {
+func (a *DateHistogramAggregation) SQLGroupExpr() string {
 	var dateFormat string
 
 	switch a.Interval {
@@ -58,7 +68,55 @@ func (a *DateHistogramAggregation) ToSQL() (string, []interface{}, error) {
 		dateFormat = "DATE(%s)"
 	}
 
-	groupBy := fmt.Sprintf("GROUP BY %s", fmt.Sprintf(dateFormat, a.Field))
+	return fmt.Sprintf(dateFormat, a.Field)
+}
+
+// SQLiteGroupExpr SQLite 的分组表达式，SQLite 没有 DATE_FORMAT，使用 strftime，桶的键与 SQLGroupExpr 的格式一致
+func (a *DateHistogramAggregation) SQLiteGroupExpr() string {
+	var dateFormat string
+
+	switch a.Interval {
+	case "1d", "day":
+		dateFormat = "DATE(%s)"
+	case "1M", "month":
+		dateFormat = "STRFTIME('%%Y-%%m-01', %s)"
+	case "1y", "year":
+		dateFormat = "STRFTIME('%%Y-01-01', %s)"
+	case "1h", "hour":
+		dateFormat = "STRFTIME('%%Y-%%m-%%d %%H:00:00', %s)"
+	default:
+		dateFormat = "DATE(%s)"
+	}
+
+	return fmt.Sprintf(dateFormat, a.Field)
+}
+
+func (a *DateHistogramAggregation) MongoGroupExpr() interface{} {
+	var format string
+
+	switch a.Interval {
+	case "1d", "day":
+		format = "%Y-%m-%d"
+	case "1M", "month":
+		format = "%Y-%m"
+	case "1y", "year":
+		format = "%Y"
+	case "1h", "hour":
+		format = "%Y-%m-%d %H:00:00"
+	default:
+		return "$" + a.Field
+	}
+
+	return map[string]interface{}{
+		"$dateToString": map[string]interface{}{
+			"format": format,
+			"date":   "$" + a.Field,
+		},
+	}
+}
+
+func (a *DateHistogramAggregation) ToSQL() (string, []interface{}, error) {
+	groupBy := fmt.Sprintf("GROUP BY %s", a.SQLGroupExpr())
 
 	var parts []string
 	var args []interface{}
@@ -76,37 +134,9 @@ func (a *DateHistogramAggregation) ToSQL() (string, []interface{}, error) {
 }
 
 func (a *DateHistogramAggregation) ToMongo() (map[string]interface{}, error) {
-	var dateExpression interface{}
-
-	switch a.Interval {
-	case "1d", "day":
-		dateExpression = map[string]interface{}{
-			"$dateToString": map[string]interface{}{
-				"format": "%Y-%m-%d",
-				"date":   "$" + a.Field,
-			},
-		}
-	case "1M", "month":
-		dateExpression = map[string]interface{}{
-			"$dateToString": map[string]interface{}{
-				"format": "%Y-%m",
-				"date":   "$" + a.Field,
-			},
-		}
-	case "1y", "year":
-		dateExpression = map[string]interface{}{
-			"$dateToString": map[string]interface{}{
-				"format": "%Y",
-				"date":   "$" + a.Field,
-			},
-		}
-	default:
-		dateExpression = "$" + a.Field
-	}
-
 	groupStage := map[string]interface{}{
 		"$group": map[string]interface{}{
-			"_id": dateExpression,
+			"_id": a.MongoGroupExpr(),
 		},
 	}
 
@@ -90,4 +90,63 @@ func TestDateHistogramAggregation_ToSQL(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+func TestDateHistogramAggregation_SQLiteGroupExpr(t *testing.T) {
+	tests := map[string]string{
+		"1d": "DATE(created_at)",
+		"1M": "STRFTIME('%Y-%m-01', created_at)",
+		"1y": "STRFTIME('%Y-01-01', created_at)",
+		"1h": "STRFTIME('%Y-%m-%d %H:00:00', created_at)",
+	}
+
+	for interval, expected := range tests {
+		agg := newTestDateHistogram(interval)
+		if got := agg.SQLiteGroupExpr(); got != expected {
+			t.Errorf("interval %s: expected %s, got %s", interval, expected, got)
+		}
+	}
+}
+
+func TestDateHistogramAggregation_MongoGroupExpr(t *testing.T) {
+	tests := map[string]string{
+		"1d": "%Y-%m-%d",
+		"1M": "%Y-%m",
+		"1y": "%Y",
+		"1h": "%Y-%m-%d %H:00:00",
+	}
+
+	for interval, expected := range tests {
+		agg := newTestDateHistogram(interval)
+		expr, ok := agg.MongoGroupExpr().(map[string]interface{})
+		if !ok {
+			t.Fatalf("interval %s: expected $dateToString expression, got %v", interval, agg.MongoGroupExpr())
+		}
+		dateToString := expr["$dateToString"].(map[string]interface{})
+		if dateToString["format"] != expected {
+			t.Errorf("interval %s: expected format %s, got %v", interval, expected, dateToString["format"])
+		}
+	}
+}
+
+func TestDateHistogramAggregation_Validate(t *testing.T) {
+	for _, interval := range []string{"1h", "hour", "1d", "day", "1M", "month", "1y", "year"} {
+		if err := newTestDateHistogram(interval).Validate(); err != nil {
+			t.Errorf("interval %s: unexpected error: %v", interval, err)
+		}
+	}
+	for _, interval := range []string{"5m", "1w", ""} {
+		if err := newTestDateHistogram(interval).Validate(); err == nil {
+			t.Errorf("interval %s: expected error", interval)
+		}
+	}
+}
+
+func newTestDateHistogram(interval string) *DateHistogramAggregation {
+	return &DateHistogramAggregation{
+		BucketAggregation: BucketAggregation{
+			AggName: "by_time",
+			Field:   "created_at",
+		},
+		Interval: interval,
+	}
+}
@@ -0,0 +1,70 @@
+package aggregation
+
+import "fmt"
+
+// LookupAggregation 关联另一个集合，相当于 LEFT OUTER JOIN，仅 MongoDB 支持
+// 每个文档的关联结果写入 As 字段，Pipeline 在关联之后执行，可用于 $project、$sort、$limit 等
+type LookupAggregation struct {
+	AggName      string
+	From         string // 关联的集合
+	LocalField   string // 当前集合的关联字段
+	ForeignField string // 关联集合的关联字段
+	As           string // 关联结果写入的字段，为空时与 From 相同
+	Unwind       bool   // 是否将关联结果展开为单个文档，未关联到的文档保留
+	Pipeline     interface{}
+}
+
+func (a *LookupAggregation) Type() AggregationType {
+	return AggTypeLookup
+}
+
+func (a *LookupAggregation) Name() string {
+	return a.AggName
+}
+
+func (a *LookupAggregation) MongoPipeline() ([]interface{}, error) {
+	if a.From == "" || a.LocalField == "" || a.ForeignField == "" {
+		return nil, fmt.Errorf("lookup aggregation %s requires from, localField and foreignField", a.AggName)
+	}
+	as := a.As
+	if as == "" {
+		as = a.From
+	}
+
+	stages := []interface{}{
+		map[string]interface{}{
+			"$lookup": map[string]interface{}{
+				"from":         a.From,
+				"localField":   a.LocalField,
+				"foreignField": a.ForeignField,
+				"as":           as,
+			},
+		},
+	}
+	if a.Unwind {
+		stages = append(stages, map[string]interface{}{
+			"$unwind": map[string]interface{}{
+				"path":                       "$" + as,
+				"preserveNullAndEmptyArrays": true,
+			},
+		})
+	}
+
+	extra, err := pipelineStages(a.Pipeline)
+	if err != nil {
+		return nil, err
+	}
+	return append(stages, extra...), nil
+}
+
+func (a *LookupAggregation) ToES() map[string]interface{} {
+	return nil
+}
+
+func (a *LookupAggregation) ToSQL() (string, []interface{}, error) {
+	return "", nil, fmt.Errorf("lookup aggregation %s is only supported by mongo", a.AggName)
+}
+
+func (a *LookupAggregation) ToMongo() (map[string]interface{}, error) {
+	return pipelineFacet(a)
+}
@@ -0,0 +1,88 @@
+package aggregation
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// PipelineAggregator 以聚合管道执行的聚合，仅 MongoDB 支持
+// 管道在查询条件的 $match 之后执行，输出的文档列表通过 AggregationResult.GetDocuments 获取
+type PipelineAggregator interface {
+	Aggregation
+
+	// MongoPipeline 管道的各个阶段
+	MongoPipeline() ([]interface{}, error)
+}
+
+// SplitPipelineAggregations 将管道聚合从聚合列表中拆分出来
+func SplitPipelineAggregations(aggs []Aggregation) ([]PipelineAggregator, []Aggregation) {
+	var pipelines []PipelineAggregator
+	var others []Aggregation
+	for _, agg := range aggs {
+		if pipeline, ok := agg.(PipelineAggregator); ok {
+			pipelines = append(pipelines, pipeline)
+		} else {
+			others = append(others, agg)
+		}
+	}
+	return pipelines, others
+}
+
+// PipelineAggregation 原生聚合管道，用于通用聚合无法表达的统计，如 $unwind、$bucketAuto、$setWindowFields
+type PipelineAggregation struct {
+	AggName  string
+	Pipeline interface{} // 管道阶段，如 []bson.M、mongo.Pipeline、[]map[string]interface{}
+}
+
+func (a *PipelineAggregation) Type() AggregationType {
+	return AggTypePipeline
+}
+
+func (a *PipelineAggregation) Name() string {
+	return a.AggName
+}
+
+func (a *PipelineAggregation) MongoPipeline() ([]interface{}, error) {
+	return pipelineStages(a.Pipeline)
+}
+
+func (a *PipelineAggregation) ToES() map[string]interface{} {
+	return nil
+}
+
+func (a *PipelineAggregation) ToSQL() (string, []interface{}, error) {
+	return "", nil, fmt.Errorf("pipeline aggregation %s is only supported by mongo", a.AggName)
+}
+
+func (a *PipelineAggregation) ToMongo() (map[string]interface{}, error) {
+	return pipelineFacet(a)
+}
+
+// pipelineFacet 将管道聚合包装为 $facet 阶段，便于与其他管道组合
+func pipelineFacet(a PipelineAggregator) (map[string]interface{}, error) {
+	stages, err := a.MongoPipeline()
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"$facet": map[string]interface{}{
+			a.Name(): stages,
+		},
+	}, nil
+}
+
+// pipelineStages 将切片形式的管道转换为阶段列表，nil 表示空管道
+func pipelineStages(pipeline interface{}) ([]interface{}, error) {
+	if pipeline == nil {
+		return nil, nil
+	}
+	rv := reflect.ValueOf(pipeline)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, fmt.Errorf("pipeline must be a slice of stages, got %T", pipeline)
+	}
+	stages := make([]interface{}, 0, rv.Len())
+	for i := 0; i < rv.Len(); i++ {
+		stages = append(stages, rv.Index(i).Interface())
+	}
+	return stages, nil
+}
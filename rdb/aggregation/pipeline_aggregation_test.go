@@ -0,0 +1,108 @@
+package aggregation
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPipelineAggregation_MongoPipeline(t *testing.T) {
+	agg := &PipelineAggregation{
+		AggName: "top_tags",
+		Pipeline: []map[string]interface{}{
+			{"$unwind": "$tags"},
+			{"$sortByCount": "$tags"},
+		},
+	}
+
+	stages, err := agg.MongoPipeline()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []interface{}{
+		map[string]interface{}{"$unwind": "$tags"},
+		map[string]interface{}{"$sortByCount": "$tags"},
+	}
+	if !reflect.DeepEqual(stages, expected) {
+		t.Errorf("Expected %v, got %v", expected, stages)
+	}
+
+	facet, err := agg.ToMongo()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(facet, map[string]interface{}{"$facet": map[string]interface{}{"top_tags": expected}}) {
+		t.Errorf("Unexpected facet: %v", facet)
+	}
+
+	if _, _, err := agg.ToSQL(); err == nil {
+		t.Error("Expected error for ToSQL")
+	}
+	if _, err := (&PipelineAggregation{AggName: "bad", Pipeline: "$match"}).MongoPipeline(); err == nil {
+		t.Error("Expected error for non-slice pipeline")
+	}
+}
+
+func TestLookupAggregation_MongoPipeline(t *testing.T) {
+	tests := []struct {
+		name     string
+		agg      *LookupAggregation
+		expected []interface{}
+	}{
+		{
+			name: "lookup",
+			agg:  &LookupAggregation{AggName: "orders", From: "users", LocalField: "user_id", ForeignField: "_id"},
+			expected: []interface{}{
+				map[string]interface{}{"$lookup": map[string]interface{}{"from": "users", "localField": "user_id", "foreignField": "_id", "as": "users"}},
+			},
+		},
+		{
+			name: "lookup with unwind and pipeline",
+			agg: &LookupAggregation{
+				AggName: "orders", From: "users", LocalField: "user_id", ForeignField: "_id", As: "user", Unwind: true,
+				Pipeline: []map[string]interface{}{{"$project": map[string]interface{}{"amount": 1, "user.name": 1}}},
+			},
+			expected: []interface{}{
+				map[string]interface{}{"$lookup": map[string]interface{}{"from": "users", "localField": "user_id", "foreignField": "_id", "as": "user"}},
+				map[string]interface{}{"$unwind": map[string]interface{}{"path": "$user", "preserveNullAndEmptyArrays": true}},
+				map[string]interface{}{"$project": map[string]interface{}{"amount": 1, "user.name": 1}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stages, err := tt.agg.MongoPipeline()
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(stages, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, stages)
+			}
+		})
+	}
+
+	if _, err := (&LookupAggregation{AggName: "orders", From: "users"}).MongoPipeline(); err == nil {
+		t.Error("Expected error for missing fields")
+	}
+}
+
+func TestSplitPipelineAggregations(t *testing.T) {
+	lookup := &LookupAggregation{AggName: "lookup"}
+	count := &CountAggregation{MetricAggregation: MetricAggregation{AggName: "count"}}
+	pipelines, others := SplitPipelineAggregations([]Aggregation{lookup, count})
+	if len(pipelines) != 1 || pipelines[0] != lookup {
+		t.Errorf("Unexpected pipelines: %v", pipelines)
+	}
+	if len(others) != 1 || others[0] != count {
+		t.Errorf("Unexpected others: %v", others)
+	}
+
+	result := NewAggregationResult()
+	result.SetResult("lookup", []map[string]interface{}{{"_id": 1}})
+	if docs := result.GetDocuments("lookup"); len(docs) != 1 || docs[0]["_id"] != 1 {
+		t.Errorf("Unexpected documents: %v", docs)
+	}
+	if docs := result.GetDocuments("count"); docs != nil {
+		t.Errorf("Expected nil documents, got %v", docs)
+	}
+}
@@ -1,5 +1,7 @@
 package aggregation
 
+import "fmt"
+
 // AggregationResult 聚合结果接口
 type AggregationResult interface {
 	// Get 获取聚合结果
@@ -8,11 +10,17 @@ type AggregationResult interface {
 	// GetBuckets 获取桶聚合结果
 	GetBuckets(aggName string) []Bucket
 	
+	// GetBucket 按桶键获取桶，配合 Bucket.SubAggregations 逐层访问桶树
+	GetBucket(aggName string, key interface{}) Bucket
+	
 	// GetValue 获取指标聚合的数值结果
 	GetValue(aggName string) float64
 	
 	// GetCount 获取文档计数
 	GetCount(aggName string) int64
+
+	// GetDocuments 获取管道聚合输出的文档列表
+	GetDocuments(aggName string) []map[string]interface{}
 }
 
 // Bucket 桶结果接口
@@ -53,6 +61,17 @@ func (r *DefaultAggregationResult) GetBuckets(aggName string) []Bucket {
 	return nil
 }
 
+func (r *DefaultAggregationResult) GetBucket(aggName string, key interface{}) Bucket {
+	// 不同后端返回的键类型可能不同（如 int32/int64），按字符串形式比较
+	target := fmt.Sprint(key)
+	for _, bucket := range r.GetBuckets(aggName) {
+		if fmt.Sprint(bucket.Key()) == target {
+			return bucket
+		}
+	}
+	return nil
+}
+
 func (r *DefaultAggregationResult) GetValue(aggName string) float64 {
 	if value, ok := r.results[aggName].(float64); ok {
 		return value
@@ -60,6 +79,9 @@ func (r *DefaultAggregationResult) GetValue(aggName string) float64 {
 	if value, ok := r.results[aggName].(int64); ok {
 		return float64(value)
 	}
+	if value, ok := r.results[aggName].(int32); ok {
+		return float64(value)
+	}
 	if value, ok := r.results[aggName].(int); ok {
 		return float64(value)
 	}
@@ -70,12 +92,22 @@ func (r *DefaultAggregationResult) GetCount(aggName string) int64 {
 	if value, ok := r.results[aggName].(int64); ok {
 		return value
 	}
+	if value, ok := r.results[aggName].(int32); ok {
+		return int64(value)
+	}
 	if value, ok := r.results[aggName].(int); ok {
 		return int64(value)
 	}
 	return 0
 }
 
+func (r *DefaultAggregationResult) GetDocuments(aggName string) []map[string]interface{} {
+	if docs, ok := r.results[aggName].([]map[string]interface{}); ok {
+		return docs
+	}
+	return nil
+}
+
 // DefaultBucket 默认桶实现
 type DefaultBucket struct {
 	key             interface{}
@@ -140,4 +140,39 @@ func TestAggregationResult_TypeConversions(t *testing.T) {
 	if count := result.GetCount("string_value"); count != 0 {
 		t.Errorf("Expected 0 for string value, got %v", count)
 	}
-}
\ No newline at end of file
+}
+func TestDefaultAggregationResult_BucketTree(t *testing.T) {
+	result := NewAggregationResult()
+
+	// by_age -> by_city -> avg_score
+	city := NewBucket("Beijing", 2)
+	city.SetSubAggregation("avg_score", 90.0)
+	age := NewBucket(int64(30), 3)
+	age.SetSubAggregation("avg_score", 92.0)
+	age.SetSubAggregation("by_city", []Bucket{city})
+	result.SetResult("by_age", []Bucket{age})
+
+	// 键类型不同时按字符串形式匹配
+	bucket := result.GetBucket("by_age", 30)
+	if bucket == nil {
+		t.Fatal("Expected bucket with key 30")
+	}
+	if bucket.DocCount() != 3 {
+		t.Errorf("Expected doc count 3, got %v", bucket.DocCount())
+	}
+	if avg := bucket.SubAggregations().GetValue("avg_score"); avg != 92.0 {
+		t.Errorf("Expected avg_score 92.0, got %v", avg)
+	}
+
+	cityBucket := bucket.SubAggregations().GetBucket("by_city", "Beijing")
+	if cityBucket == nil {
+		t.Fatal("Expected nested bucket 'Beijing'")
+	}
+	if avg := cityBucket.SubAggregations().GetValue("avg_score"); avg != 90.0 {
+		t.Errorf("Expected avg_score 90.0, got %v", avg)
+	}
+
+	if missing := result.GetBucket("by_age", 99); missing != nil {
+		t.Errorf("Expected nil for missing bucket, got %v", missing)
+	}
+}
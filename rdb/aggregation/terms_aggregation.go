@@ -16,6 +16,14 @@ func (a *TermsAggregation) Type() AggregationType {
 	return AggTypeTerms
 }
 
+func (a *TermsAggregation) SQLGroupExpr() string {
+	return a.Field
+}
+
+func (a *TermsAggregation) MongoGroupExpr() interface{} {
+	return "$" + a.Field
+}
+
 func (a *TermsAggregation) ToES() map[string]interface{} {
 	terms := map[string]interface{}{
 		"field": a.Field,
@@ -44,7 +52,7 @@ func (a *TermsAggregation) ToSQL() (string, []interface{}, error) {
 	var parts []string
 	var args []interface{}
 
-	groupBy := fmt.Sprintf("GROUP BY %s", a.Field)
+	groupBy := fmt.Sprintf("GROUP BY %s", a.SQLGroupExpr())
 	parts = append(parts, groupBy)
 
 	if subSQLs, subArgs, err := buildSubAggregationsSQL(a.SubAggregations); err != nil {
@@ -72,7 +80,7 @@ func (a *TermsAggregation) ToSQL() (string, []interface{}, error) {
 func (a *TermsAggregation) ToMongo() (map[string]interface{}, error) {
 	groupStage := map[string]interface{}{
 		"$group": map[string]interface{}{
-			"_id": "$" + a.Field,
+			"_id": a.MongoGroupExpr(),
 		},
 	}
 
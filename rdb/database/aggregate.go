@@ -0,0 +1,173 @@
+package database
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hatlonely/gox/rdb/aggregation"
+)
+
+// 桶聚合结果中的内部字段名，避免与用户定义的聚合名冲突
+const (
+	aggDocCountField  = "_doc_count"
+	aggKeyFieldPrefix = "_key"
+	aggRankField      = "_rank"
+)
+
+// bucketLevel 桶聚合层级
+// 每一层按 从顶层到当前层 的全部分组表达式分组，统计当前层的指标子聚合
+type bucketLevel struct {
+	agg      aggregation.BucketAggregator
+	metrics  []aggregation.Aggregation
+	scripts  []aggregation.ScriptAggregator
+	children []*bucketLevel
+}
+
+func newBucketLevel(agg aggregation.BucketAggregator) *bucketLevel {
+	scripts, subAggs := aggregation.SplitScriptAggregations(agg.GetSubAggregations())
+	metrics, buckets := aggregation.SplitAggregations(subAggs)
+	level := &bucketLevel{agg: agg, metrics: metrics, scripts: scripts}
+	for _, bucket := range buckets {
+		level.children = append(level.children, newBucketLevel(bucket))
+	}
+	return level
+}
+
+// bucketRow 某一层级下单个分组的统计结果
+type bucketRow struct {
+	keys     []interface{} // 从顶层到当前层的桶键
+	docCount int64
+	values   map[string]interface{}
+}
+
+// bucketFetcher 按层级链查询分组统计结果，chain 最后一个元素为当前层级
+type bucketFetcher func(chain []*bucketLevel) ([]bucketRow, error)
+
+// buildBucketTree 逐层查询并组装桶树，返回以父桶键路径为索引的桶列表，顶层桶的路径为空字符串
+func buildBucketTree(chain []*bucketLevel, fetch bucketFetcher) (map[string][]aggregation.Bucket, error) {
+	level := chain[len(chain)-1]
+	rows, err := fetch(chain)
+	if err != nil {
+		return nil, err
+	}
+
+	grouped := make(map[string][]aggregation.Bucket)
+	bucketsByPath := make(map[string]*aggregation.DefaultBucket)
+	for _, row := range rows {
+		depth := len(row.keys) - 1
+		bucket := aggregation.NewBucket(row.keys[depth], row.docCount)
+		for _, metric := range level.metrics {
+			bucket.SetSubAggregation(metric.Name(), row.values[metric.Name()])
+		}
+		if err := applyScriptAggregations(level.scripts, bucketValue(bucket), bucket.SetSubAggregation); err != nil {
+			return nil, err
+		}
+		parentPath := bucketPath(row.keys[:depth])
+		grouped[parentPath] = append(grouped[parentPath], bucket)
+		bucketsByPath[bucketPath(row.keys)] = bucket
+	}
+
+	for _, child := range level.children {
+		childChain := append(chain[:len(chain):len(chain)], child)
+		childBuckets, err := buildBucketTree(childChain, fetch)
+		if err != nil {
+			return nil, err
+		}
+		for path, bucket := range bucketsByPath {
+			bucket.SetSubAggregation(child.agg.Name(), childBuckets[path])
+		}
+	}
+
+	return grouped, nil
+}
+
+// bucketPath 桶键路径，用于将子桶挂到对应的父桶下
+func bucketPath(keys []interface{}) string {
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		parts[i] = fmt.Sprint(key)
+	}
+	return strings.Join(parts, "\x00")
+}
+
+// applyScriptAggregations 按顺序计算派生指标，get 获取同级聚合结果，结果通过 set 写入，后面的派生指标可以引用前面的
+// 变量缺失或除数为 0 时不写入结果
+func applyScriptAggregations(scripts []aggregation.ScriptAggregator, get func(aggName string) interface{}, set func(aggName string, value interface{})) error {
+	for _, script := range scripts {
+		value, ok, err := script.Evaluate(func(aggName string) (float64, bool) {
+			return toFloat64(get(aggName))
+		})
+		if err != nil {
+			return err
+		}
+		if ok {
+			set(script.Name(), value)
+		}
+	}
+	return nil
+}
+
+// bucketValue 获取桶内的聚合结果，_count 为桶的文档数
+func bucketValue(bucket aggregation.Bucket) func(aggName string) interface{} {
+	return func(aggName string) interface{} {
+		if aggName == "_count" {
+			return bucket.DocCount()
+		}
+		return bucket.SubAggregations().Get(aggName)
+	}
+}
+
+// toFloat64 将各后端返回的数值统一为 float64，非数值返回 false
+func toFloat64(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+// normalizeSQLAggValue 驱动可能以 []byte 返回 DECIMAL 和字符串列，数值转为 float64，其余转为 string
+func normalizeSQLAggValue(value interface{}) interface{} {
+	if v, ok := value.([]byte); ok {
+		if f, err := strconv.ParseFloat(string(v), 64); err == nil {
+			return f
+		}
+		return string(v)
+	}
+	return value
+}
+
+// toInt64 将各后端返回的整数（文档计数、统计值）统一为 int64
+func toInt64(value interface{}) int64 {
+	switch v := value.(type) {
+	case int64:
+		return v
+	case int32:
+		return int64(v)
+	case int:
+		return int64(v)
+	case float64:
+		return int64(v)
+	case []byte:
+		n, _ := strconv.ParseInt(string(v), 10, 64)
+		return n
+	}
+	return 0
+}
+
+// checkPipelineAggregations 不支持聚合管道的后端拒绝管道聚合
+func checkPipelineAggregations(backend string, aggs []aggregation.Aggregation) error {
+	if pipelines, _ := aggregation.SplitPipelineAggregations(aggs); len(pipelines) > 0 {
+		return fmt.Errorf("%s aggregation %s is not supported by %s", pipelines[0].Type(), pipelines[0].Name(), backend)
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// FieldChange 记录中一个字段的变化
+type FieldChange struct {
+	Field  string `json:"field"`
+	Before any    `json:"before"`
+	After  any    `json:"after"`
+}
+
+// DiffRecords 比较两条记录，返回值不同的字段及其前后的值，按字段名排序
+// before 为 nil 时所有字段视为新增，after 为 nil 时视为删除，只在一边存在的字段另一边的值为 nil；
+// 值的比较规则与 Record.Diff 相同
+func DiffRecords(before, after Record) []FieldChange {
+	var beforeFields, afterFields map[string]any
+	if before != nil {
+		beforeFields = before.Fields()
+	}
+	if after != nil {
+		afterFields = after.Fields()
+	}
+
+	fields := make(map[string]struct{}, len(beforeFields)+len(afterFields))
+	for field := range beforeFields {
+		fields[field] = struct{}{}
+	}
+	for field := range afterFields {
+		fields[field] = struct{}{}
+	}
+
+	var changes []FieldChange
+	for field := range fields {
+		beforeValue, afterValue := beforeFields[field], afterFields[field]
+		if fieldValuesEqual(beforeValue, afterValue) {
+			continue
+		}
+		changes = append(changes, FieldChange{Field: field, Before: beforeValue, After: afterValue})
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Field < changes[j].Field
+	})
+	return changes
+}
+
+// DefaultAuditTable 审计日志的默认表名
+const DefaultAuditTable = "audit_log"
+
+// AuditOptions 审计日志配置
+type AuditOptions struct {
+	// Table 审计日志表名
+	Table string `cfg:"table" def:"audit_log"`
+	// Tables 需要审计的表，为空时审计所有表
+	Tables []string `cfg:"tables"`
+}
+
+// AuditEntry 审计日志表中的一条记录，一次操作中每条变化的记录对应一条
+type AuditEntry struct {
+	ID        string    `rdb:"id,primary,idgen=uuidv7"`
+	Table     string    `rdb:"table_name,required,index,size=128"`
+	Operation string    `rdb:"operation,required,size=32"`
+	PK        string    `rdb:"pk,required,size=512"` // 主键的 JSON
+	Changes   string    `rdb:"changes,size=8192"`    // []FieldChange 的 JSON
+	Actor     string    `rdb:"actor,size=128"`       // 操作人，通过 WithAuditActor 设置
+	CreatedAt time.Time `rdb:"created_at,required,index"`
+}
+
+// AuditTableModel 返回审计日志表的表模型，用于迁移审计日志表
+func AuditTableModel(table string) (*TableModel, error) {
+	model, err := NewTableModelBuilder().FromStruct(&AuditEntry{})
+	if err != nil {
+		return nil, err
+	}
+	model.Table = table
+	return model, nil
+}
+
+type auditActorContextKey struct{}
+
+// WithAuditActor 返回携带操作人的 context，审计日志记录该操作人
+func WithAuditActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, auditActorContextKey{}, actor)
+}
+
+// AuditActorFromContext 获取 context 中的操作人
+func AuditActorFromContext(ctx context.Context) (string, bool) {
+	actor, ok := ctx.Value(auditActorContextKey{}).(string)
+	return actor, ok && actor != ""
+}
+
+// NewAuditInterceptor 创建审计日志拦截器，Update、UpdatePartial、Upsert、Increment、Delete 及批量更新、删除时
+// 读取修改前后的记录，将变化的字段写入审计日志表，没有变化的记录不写入
+//
+// 读取记录和写入审计日志使用执行操作的数据库，在事务中执行时与数据变更一起提交或回滚；
+// 写入审计日志失败时返回错误，不在事务中时数据变更已经生效
+func NewAuditInterceptor(options *AuditOptions) Interceptor {
+	auditTable := DefaultAuditTable
+	var tables map[string]bool
+	if options != nil {
+		if options.Table != "" {
+			auditTable = options.Table
+		}
+		if len(options.Tables) > 0 {
+			tables = make(map[string]bool, len(options.Tables))
+			for _, table := range options.Tables {
+				tables[table] = true
+			}
+		}
+	}
+
+	return func(ctx context.Context, op OperationInfo, next Handler) error {
+		var pks []map[string]any
+		switch op.Operation {
+		case OpUpdate, OpUpdatePartial, OpUpsert, OpIncrement, OpDelete:
+			pks = []map[string]any{op.PK}
+		case OpBatchUpdate, OpBatchDelete:
+			pks = op.PKs
+		default:
+			return next(ctx, op)
+		}
+		if op.Table == auditTable || tables != nil && !tables[op.Table] {
+			return next(ctx, op)
+		}
+
+		db := op.Database()
+		befores := make([]Record, len(pks))
+		for i, pk := range pks {
+			record, err := db.Get(ctx, op.Table, pk)
+			if err != nil && !errors.Is(err, ErrRecordNotFound) {
+				return errors.WithMessage(err, "failed to read record for audit")
+			}
+			befores[i] = record
+		}
+
+		if err := next(ctx, op); err != nil {
+			return err
+		}
+
+		actor, _ := AuditActorFromContext(ctx)
+		deleted := op.Operation == OpDelete || op.Operation == OpBatchDelete
+		for i, pk := range pks {
+			if befores[i] == nil {
+				continue
+			}
+			var after Record
+			if !deleted {
+				record, err := db.Get(ctx, op.Table, pk)
+				if err != nil && !errors.Is(err, ErrRecordNotFound) {
+					return errors.WithMessage(err, "failed to read record for audit")
+				}
+				after = record
+			}
+
+			changes := DiffRecords(befores[i], after)
+			if len(changes) == 0 {
+				continue
+			}
+			if err := writeAuditEntry(ctx, db, auditTable, op, pk, changes, actor); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// writeAuditEntry 写入一条审计日志
+func writeAuditEntry(ctx context.Context, db Database, table string, op OperationInfo, pk map[string]any, changes []FieldChange, actor string) error {
+	pkJSON, err := json.Marshal(pk)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal audit pk")
+	}
+	changesJSON, err := json.Marshal(changes)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal audit changes")
+	}
+	id, err := IDStrategyUUIDv7.Generate()
+	if err != nil {
+		return err
+	}
+
+	entry := &AuditEntry{
+		ID:        id,
+		Table:     op.Table,
+		Operation: string(op.Operation),
+		PK:        string(pkJSON),
+		Changes:   string(changesJSON),
+		Actor:     actor,
+		CreatedAt: time.Now(),
+	}
+	if err := db.Create(ctx, table, db.GetBuilder().FromStruct(entry)); err != nil {
+		return errors.WithMessage(err, "failed to write audit log")
+	}
+	return nil
+}
+
+// newAuditInterceptorWithOptions 迁移审计日志表后创建审计日志拦截器
+func newAuditInterceptorWithOptions(db Database, options *AuditOptions) (Interceptor, error) {
+	table := options.Table
+	if table == "" {
+		table = DefaultAuditTable
+	}
+	model, err := AuditTableModel(table)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Migrate(context.Background(), model); err != nil {
+		return nil, errors.WithMessage(err, "failed to migrate audit table")
+	}
+	return NewAuditInterceptor(options), nil
+}
@@ -0,0 +1,133 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/hatlonely/gox/rdb/query"
+	"github.com/hatlonely/gox/ref"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDiffRecords(t *testing.T) {
+	Convey("测试 DiffRecords", t, func() {
+		builder := &SQLRecordBuilder{}
+		before := builder.FromMap(map[string]any{"id": int64(1), "name": "alice", "age": int64(20), "active": int64(1)}, "users")
+		after := builder.FromMap(map[string]any{"id": 1, "name": "bob", "age": 20, "active": true, "email": "bob@example.com"}, "users")
+
+		So(DiffRecords(before, after), ShouldResemble, []FieldChange{
+			{Field: "email", Before: nil, After: "bob@example.com"},
+			{Field: "name", Before: "alice", After: "bob"},
+		})
+		So(DiffRecords(before, before), ShouldBeEmpty)
+
+		// 删除时所有字段的值变为 nil
+		changes := DiffRecords(before, nil)
+		So(changes, ShouldHaveLength, 4)
+		So(changes[0], ShouldResemble, FieldChange{Field: "active", Before: int64(1)})
+	})
+}
+
+func TestAuditInterceptor(t *testing.T) {
+	Convey("测试审计日志拦截器", t, func() {
+		sql, err := NewSQLWithOptions(&SQLOptions{Driver: "sqlite3", Database: ":memory:", MaxConns: 1, MaxIdle: 1})
+		So(err, ShouldBeNil)
+		defer sql.Close()
+
+		ctx := WithAuditActor(context.Background(), "admin")
+		So(sql.Migrate(ctx, &TableModel{
+			Table: "test_audit_users",
+			Fields: []FieldDefinition{
+				{Name: "id", Type: FieldTypeInt, Required: true},
+				{Name: "name", Type: FieldTypeString, Size: 100},
+				{Name: "age", Type: FieldTypeInt},
+			},
+			PrimaryKey: []string{"id"},
+		}), ShouldBeNil)
+		auditModel, err := AuditTableModel("test_audit_log")
+		So(err, ShouldBeNil)
+		So(sql.Migrate(ctx, auditModel), ShouldBeNil)
+
+		db := NewInterceptorDatabase(sql, NewAuditInterceptor(&AuditOptions{Table: "test_audit_log"}))
+		builder := db.GetBuilder()
+		for _, fields := range []map[string]any{{"id": 1, "name": "alice", "age": 20}, {"id": 2, "name": "bob", "age": 30}} {
+			So(db.Create(ctx, "test_audit_users", builder.FromMap(fields, "test_audit_users")), ShouldBeNil)
+		}
+
+		entries := func() []AuditEntry {
+			records, err := sql.Find(ctx, "test_audit_log", &query.ExistsQuery{Field: "id"}, func(opts *QueryOptions) {
+				opts.OrderBy = "id"
+			})
+			So(err, ShouldBeNil)
+			entries := make([]AuditEntry, len(records))
+			for i, record := range records {
+				So(record.ScanStruct(&entries[i]), ShouldBeNil)
+			}
+			return entries
+		}
+		changesOf := func(entry AuditEntry) []FieldChange {
+			var changes []FieldChange
+			So(json.Unmarshal([]byte(entry.Changes), &changes), ShouldBeNil)
+			return changes
+		}
+
+		Convey("更新和删除写入变化的字段", func() {
+			So(db.UpdatePartial(ctx, "test_audit_users", map[string]any{"id": 1}, map[string]any{"name": "alice2"}), ShouldBeNil)
+			So(db.Delete(ctx, "test_audit_users", map[string]any{"id": 2}), ShouldBeNil)
+
+			got := entries()
+			So(got, ShouldHaveLength, 2)
+			So(got[0].Table, ShouldEqual, "test_audit_users")
+			So(got[0].Operation, ShouldEqual, string(OpUpdatePartial))
+			So(got[0].PK, ShouldEqual, `{"id":1}`)
+			So(got[0].Actor, ShouldEqual, "admin")
+			So(changesOf(got[0]), ShouldResemble, []FieldChange{{Field: "name", Before: "alice", After: "alice2"}})
+
+			So(got[1].Operation, ShouldEqual, string(OpDelete))
+			So(changesOf(got[1]), ShouldHaveLength, 3)
+		})
+
+		Convey("没有变化或记录不存在时不写入", func() {
+			So(db.UpdatePartial(ctx, "test_audit_users", map[string]any{"id": 1}, map[string]any{"name": "alice"}), ShouldBeNil)
+			db.Delete(ctx, "test_audit_users", map[string]any{"id": 3})
+			So(entries(), ShouldBeEmpty)
+		})
+
+		Convey("审计日志随事务回滚", func() {
+			tx, err := db.BeginTx(ctx)
+			So(err, ShouldBeNil)
+			So(tx.Increment(ctx, "test_audit_users", map[string]any{"id": 1}, "age", 1), ShouldBeNil)
+			So(tx.Rollback(), ShouldBeNil)
+			So(entries(), ShouldBeEmpty)
+
+			So(db.WithTx(ctx, func(tx Transaction) error {
+				return tx.BatchDelete(ctx, "test_audit_users", []map[string]any{{"id": 1}, {"id": 2}})
+			}), ShouldBeNil)
+			So(entries(), ShouldHaveLength, 2)
+		})
+
+		Convey("只审计指定的表", func() {
+			db := NewInterceptorDatabase(sql, NewAuditInterceptor(&AuditOptions{Table: "test_audit_log", Tables: []string{"other"}}))
+			So(db.UpdatePartial(ctx, "test_audit_users", map[string]any{"id": 1}, map[string]any{"name": "alice2"}), ShouldBeNil)
+			So(entries(), ShouldBeEmpty)
+		})
+	})
+
+	Convey("测试通过配置启用审计日志", t, func() {
+		db, err := NewInterceptorDatabaseWithOptions(&InterceptorDatabaseOptions{
+			Database: &ref.TypeOptions{
+				Namespace: "github.com/hatlonely/gox/rdb/database",
+				Type:      "SQL",
+				Options:   &SQLOptions{Driver: "sqlite3", Database: ":memory:", MaxConns: 1, MaxIdle: 1},
+			},
+			Audit: &AuditOptions{},
+		})
+		So(err, ShouldBeNil)
+		defer db.Close()
+
+		count, err := db.Count(context.Background(), DefaultAuditTable, &query.ExistsQuery{Field: "id"})
+		So(err, ShouldBeNil)
+		So(count, ShouldEqual, 0)
+	})
+}
@@ -0,0 +1,482 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/hatlonely/gox/ref"
+	"github.com/pkg/errors"
+)
+
+func init() {
+	ref.RegisterT[*FileBlobStore](NewFileBlobStoreWithOptions)
+}
+
+// ErrBlobNotFound blob 内容不存在
+var ErrBlobNotFound = errors.New("blob not found")
+
+// BlobStore 大字段的存储，FieldTypeBlob 字段的内容写入 BlobStore，记录中只保存内容的键
+type BlobStore interface {
+	// Put 写入 r 的全部内容，key 已存在时覆盖
+	Put(ctx context.Context, key string, r io.Reader) error
+	// Open 打开 key 对应的内容，由调用方关闭，不存在时返回 ErrBlobNotFound
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete 删除 key 对应的内容，不存在时不返回错误
+	Delete(ctx context.Context, key string) error
+}
+
+// NewBlobStoreWithOptions 通过 ref 创建 BlobStore
+func NewBlobStoreWithOptions(options *ref.TypeOptions) (BlobStore, error) {
+	store, err := ref.NewWithOptions(options)
+	if err != nil {
+		return nil, errors.WithMessage(err, "ref.New failed")
+	}
+	if _, ok := store.(BlobStore); !ok {
+		return nil, errors.New("store is not a BlobStore")
+	}
+	return store.(BlobStore), nil
+}
+
+// BlobOptions 大字段存储配置，见 NewBlobInterceptor
+type BlobOptions struct {
+	// Store 存储实现，通过 ref 注册，如 FileBlobStore；为空且底层数据库为 Mongo 时使用 GridFS
+	Store *ref.TypeOptions `cfg:"store"`
+	// Bucket 使用 GridFS 时的 bucket 名
+	Bucket string `cfg:"bucket" def:"fs"`
+	// Tables 各表的 blob 字段名，经过拦截器的 Migrate 也会登记表模型中的 blob 字段
+	Tables map[string][]string `cfg:"tables"`
+}
+
+// newBlobInterceptorWithOptions 按配置创建大字段拦截器
+// 未配置存储时解开包装查找底层的 Mongo 使用 GridFS，底层不是 Mongo 时返回错误
+func newBlobInterceptorWithOptions(db Database, options *BlobOptions) (Interceptor, error) {
+	var store BlobStore
+	if options.Store != nil {
+		var err error
+		if store, err = NewBlobStoreWithOptions(options.Store); err != nil {
+			return nil, err
+		}
+	} else if m, ok := Unwrap(db).(*Mongo); ok {
+		store = NewGridFSBlobStore(m, options.Bucket)
+	} else {
+		return nil, errors.Errorf("blob store is required for %T, GridFS is only available when the underlying database is mongo", Unwrap(db))
+	}
+
+	var models []*TableModel
+	for table, fields := range options.Tables {
+		model := &TableModel{Table: table}
+		for _, field := range fields {
+			model.Fields = append(model.Fields, FieldDefinition{Name: field, Type: FieldTypeBlob})
+		}
+		models = append(models, model)
+	}
+	return NewBlobInterceptor(store, models...), nil
+}
+
+// Blob FieldTypeBlob 字段的值
+//
+// 写入时用 NewBlob 包装数据流，由 NewBlobInterceptor 写入 BlobStore；
+// 读取时记录中的 blob 字段为只包含键的 Blob，通过 Open 流式读取内容，不会加载到内存
+type Blob struct {
+	key    string
+	reader io.Reader
+	store  BlobStore
+}
+
+// NewBlob 创建待写入的 blob，内容在记录写入时从 r 读取
+func NewBlob(r io.Reader) *Blob {
+	return &Blob{reader: r}
+}
+
+// Key 内容在 BlobStore 中的键，待写入的 blob 返回空字符串
+func (b *Blob) Key() string {
+	return b.key
+}
+
+// Open 打开 blob 的内容，由调用方关闭
+func (b *Blob) Open(ctx context.Context) (io.ReadCloser, error) {
+	if b.store == nil || b.key == "" {
+		return nil, errors.Wrap(ErrBlobNotFound, "blob is not stored")
+	}
+	return b.store.Open(ctx, b.key)
+}
+
+// Value 实现 driver.Valuer，数据库中保存的是 blob 的键
+func (b *Blob) Value() (driver.Value, error) {
+	if b.reader != nil {
+		return nil, errors.New("blob has not been uploaded, use NewBlobInterceptor to store blob fields")
+	}
+	return b.key, nil
+}
+
+// DeleteBlobs 删除记录中 blob 字段的内容，用于删除记录或替换 blob 字段后清理旧内容
+// record 需要是经过 NewBlobInterceptor 读取的记录
+func DeleteBlobs(ctx context.Context, record Record) error {
+	for field, value := range record.Fields() {
+		blob, ok := value.(*Blob)
+		if !ok || blob == nil || blob.store == nil || blob.key == "" {
+			continue
+		}
+		if err := blob.store.Delete(ctx, blob.key); err != nil {
+			return errors.WithMessagef(err, "failed to delete blob of field %s", field)
+		}
+	}
+	return nil
+}
+
+// blobTables 各表的 blob 字段
+type blobTables struct {
+	tables sync.Map // map[string][]string
+}
+
+func (t *blobTables) register(model *TableModel) {
+	var fields []string
+	for _, field := range model.Fields {
+		if field.Type == FieldTypeBlob {
+			fields = append(fields, field.Name)
+		}
+	}
+	if len(fields) == 0 {
+		t.tables.Delete(model.Table)
+		return
+	}
+	t.tables.Store(model.Table, fields)
+}
+
+func (t *blobTables) fields(table string) []string {
+	fields, _ := t.tables.Load(table)
+	v, _ := fields.([]string)
+	return v
+}
+
+// NewBlobInterceptor 创建大字段拦截器，models 中 FieldTypeBlob 字段的内容存储在 store 中，记录中只保存键
+// 经过拦截器的 Migrate 同样会登记表模型中的 blob 字段
+//
+// 写入时 blob 字段的值可以是 *Blob、io.Reader 或 []byte，写入 store 后替换为键，记录写入失败时删除已写入的内容；
+// 字符串视为已存储的键原样写入。Get、Find、FindStream 返回的记录中 blob 字段为 *Blob，结构体字段也需要声明为 *Blob
+//
+// 删除记录或替换 blob 字段时不会删除旧的内容，需要时先读取记录再调用 DeleteBlobs
+func NewBlobInterceptor(store BlobStore, models ...*TableModel) Interceptor {
+	tables := &blobTables{}
+	for _, model := range models {
+		tables.register(model)
+	}
+
+	return func(ctx context.Context, op OperationInfo, next Handler) error {
+		if op.Operation == OpMigrate {
+			if err := next(ctx, op); err != nil {
+				return err
+			}
+			tables.register(op.Model)
+			return nil
+		}
+
+		fields := tables.fields(op.Table)
+		if len(fields) == 0 {
+			return next(ctx, op)
+		}
+
+		uploader := &blobUploader{store: store, table: op.Table, fields: fields}
+		var err error
+		switch op.Operation {
+		case OpCreate, OpUpdate, OpUpsert:
+			op.Record, err = uploader.uploadRecord(ctx, op.Record)
+		case OpUpdatePartial:
+			op.Fields, err = uploader.uploadFields(ctx, op.Fields)
+		case OpBatchCreate, OpBatchUpdate:
+			records := make([]Record, len(op.Records))
+			for i, record := range op.Records {
+				if records[i], err = uploader.uploadRecord(ctx, record); err != nil {
+					break
+				}
+			}
+			op.Records = records
+		}
+		if err != nil {
+			uploader.rollback(ctx)
+			return err
+		}
+
+		if err := next(ctx, op); err != nil {
+			uploader.rollback(ctx)
+			return err
+		}
+
+		switch op.Operation {
+		case OpGet:
+			if record, ok := op.Result().(Record); ok && record != nil {
+				op.SetResult(wrapBlobRecord(record, fields, store))
+			}
+		case OpFind:
+			if records, ok := op.Result().([]Record); ok {
+				for i, record := range records {
+					records[i] = wrapBlobRecord(record, fields, store)
+				}
+			}
+		case OpFindStream:
+			if cursor, ok := op.Result().(RecordCursor); ok && cursor != nil {
+				op.SetResult(&blobCursor{RecordCursor: cursor, fields: fields, store: store})
+			}
+		}
+		return nil
+	}
+}
+
+// blobUploader 将一次操作中的 blob 字段写入 store，记录已写入的键，操作失败时删除
+type blobUploader struct {
+	store  BlobStore
+	table  string
+	fields []string
+	keys   []string
+}
+
+// uploadRecord 写入记录中的 blob 字段，返回字段替换为键的记录副本，DirtyRecord 的修改字段同样替换
+func (u *blobUploader) uploadRecord(ctx context.Context, record Record) (Record, error) {
+	if record == nil {
+		return nil, nil
+	}
+
+	uploaded := make(map[string]any)
+	fields, err := u.upload(ctx, record.Fields(), uploaded)
+	if err != nil {
+		return nil, err
+	}
+	if dirty, ok := record.(*dirtyRecord); ok {
+		return &dirtyRecord{Record: withRecordFields(dirty.Record, fields), dirty: replaceFields(dirty.dirty, uploaded)}, nil
+	}
+	return withRecordFields(record, fields), nil
+}
+
+// uploadFields 写入字段中的 blob 字段，返回替换为键的副本
+func (u *blobUploader) uploadFields(ctx context.Context, values map[string]any) (map[string]any, error) {
+	return u.upload(ctx, values, make(map[string]any))
+}
+
+// upload 写入 values 中的 blob 字段，返回替换为键的副本，uploaded 中记录字段替换后的值
+func (u *blobUploader) upload(ctx context.Context, values map[string]any, uploaded map[string]any) (map[string]any, error) {
+	for _, field := range u.fields {
+		value, ok := values[field]
+		if !ok {
+			continue
+		}
+		key, err := u.uploadValue(ctx, field, value)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "failed to store blob field %s", field)
+		}
+		uploaded[field] = key
+	}
+	return replaceFields(values, uploaded), nil
+}
+
+// uploadValue 写入单个 blob 字段的值，返回记录中保存的值
+func (u *blobUploader) uploadValue(ctx context.Context, field string, value any) (any, error) {
+	var r io.Reader
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case *Blob:
+		if v == nil {
+			return nil, nil
+		}
+		if v.reader == nil {
+			return v.key, nil
+		}
+		r = v.reader
+	case string:
+		return v, nil
+	case []byte:
+		r = bytes.NewReader(v)
+	case io.Reader:
+		r = v
+	default:
+		return nil, fmt.Errorf("unsupported blob value type %T", value)
+	}
+
+	id, err := uuid.NewV7()
+	if err != nil {
+		return nil, err
+	}
+	key := fmt.Sprintf("%s/%s/%s", u.table, field, id)
+	if err := u.store.Put(ctx, key, r); err != nil {
+		return nil, err
+	}
+	u.keys = append(u.keys, key)
+	return key, nil
+}
+
+// rollback 删除已写入的内容，ctx 已取消时仍然执行
+func (u *blobUploader) rollback(ctx context.Context) {
+	ctx = context.WithoutCancel(ctx)
+	for _, key := range u.keys {
+		_ = u.store.Delete(ctx, key)
+	}
+	u.keys = nil
+}
+
+// replaceFields 返回 values 中字段替换为 replaced 的副本，没有需要替换的字段时返回 values
+func replaceFields(values map[string]any, replaced map[string]any) map[string]any {
+	if len(replaced) == 0 || values == nil {
+		return values
+	}
+	result := make(map[string]any, len(values))
+	for k, v := range values {
+		if r, ok := replaced[k]; ok {
+			v = r
+		}
+		result[k] = v
+	}
+	return result
+}
+
+// wrapBlobRecord 将记录中保存的键替换为可读取内容的 *Blob
+func wrapBlobRecord(record Record, fields []string, store BlobStore) Record {
+	if record == nil {
+		return nil
+	}
+
+	values := record.Fields()
+	blobs := make(map[string]any)
+	for _, field := range fields {
+		switch key := values[field].(type) {
+		case string:
+			if key != "" {
+				blobs[field] = &Blob{key: key, store: store}
+			}
+		case []byte:
+			if len(key) > 0 {
+				blobs[field] = &Blob{key: string(key), store: store}
+			}
+		}
+	}
+	if len(blobs) == 0 {
+		return record
+	}
+	return withRecordFields(record, replaceFields(values, blobs))
+}
+
+// fieldsReplacer 由各后端的记录实现，返回字段替换后的副本，保留 ES 文档版本等元数据
+type fieldsReplacer interface {
+	withFields(fields map[string]any) Record
+}
+
+// withRecordFields 返回字段替换为 fields 的记录
+func withRecordFields(record Record, fields map[string]any) Record {
+	if replacer, ok := record.(fieldsReplacer); ok {
+		return replacer.withFields(fields)
+	}
+	return &SQLRecord{data: fields}
+}
+
+// blobCursor 将游标返回的记录中的 blob 字段替换为 *Blob
+type blobCursor struct {
+	RecordCursor
+	fields []string
+	store  BlobStore
+}
+
+func (c *blobCursor) Record() Record {
+	return wrapBlobRecord(c.RecordCursor.Record(), c.fields, c.store)
+}
+
+func (c *blobCursor) Scan(dest any) error {
+	record := c.Record()
+	if record == nil {
+		return c.RecordCursor.Scan(dest)
+	}
+	return record.Scan(dest)
+}
+
+// FileBlobStoreOptions 本地文件系统 blob 存储配置
+type FileBlobStoreOptions struct {
+	// Root 存储目录，blob 的键作为相对路径
+	Root string `cfg:"root" validate:"required"`
+}
+
+// FileBlobStore 将 blob 存储在本地文件系统，适用于单机部署或挂载了共享存储的场景
+type FileBlobStore struct {
+	root string
+}
+
+// NewFileBlobStoreWithOptions 创建本地文件系统 blob 存储，目录不存在时创建
+func NewFileBlobStoreWithOptions(options *FileBlobStoreOptions) (*FileBlobStore, error) {
+	if err := os.MkdirAll(options.Root, 0755); err != nil {
+		return nil, errors.Wrap(err, "failed to create blob root")
+	}
+	return &FileBlobStore{root: options.Root}, nil
+}
+
+func (s *FileBlobStore) path(key string) (string, error) {
+	if !filepath.IsLocal(key) {
+		return "", fmt.Errorf("invalid blob key %q", key)
+	}
+	return filepath.Join(s.root, filepath.FromSlash(key)), nil
+}
+
+// Put 先写入同目录下的临时文件再重命名，读取方不会看到写了一半的内容
+func (s *FileBlobStore) Put(ctx context.Context, key string, r io.Reader) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return errors.Wrap(err, "failed to create blob directory")
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), ".blob-*")
+	if err != nil {
+		return errors.Wrap(err, "failed to create blob file")
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := io.Copy(f, &contextReader{ctx: ctx, r: r}); err != nil {
+		f.Close()
+		return errors.Wrap(err, "failed to write blob")
+	}
+	if err := f.Close(); err != nil {
+		return errors.Wrap(err, "failed to write blob")
+	}
+	return os.Rename(f.Name(), path)
+}
+
+func (s *FileBlobStore) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, errors.Wrapf(ErrBlobNotFound, "key %s", key)
+	}
+	return f, err
+}
+
+func (s *FileBlobStore) Delete(ctx context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// contextReader 在 ctx 取消后停止读取，避免大文件写入无法中断
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
@@ -0,0 +1,255 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hatlonely/gox/rdb/query"
+	"github.com/hatlonely/gox/ref"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type blobTestDocument struct {
+	ID      int    `rdb:"id,primary"`
+	Name    string `rdb:"name"`
+	Content *Blob  `rdb:"content"`
+}
+
+func readBlob(blob *Blob) string {
+	r, err := blob.Open(context.Background())
+	So(err, ShouldBeNil)
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	So(err, ShouldBeNil)
+	return string(data)
+}
+
+func TestFileBlobStore(t *testing.T) {
+	Convey("测试本地文件 blob 存储", t, func() {
+		ctx := context.Background()
+		store, err := NewFileBlobStoreWithOptions(&FileBlobStoreOptions{Root: t.TempDir()})
+		So(err, ShouldBeNil)
+
+		So(store.Put(ctx, "docs/content/1", strings.NewReader("hello")), ShouldBeNil)
+		r, err := store.Open(ctx, "docs/content/1")
+		So(err, ShouldBeNil)
+		data, _ := io.ReadAll(r)
+		r.Close()
+		So(string(data), ShouldEqual, "hello")
+
+		So(store.Put(ctx, "docs/content/1", strings.NewReader("world")), ShouldBeNil)
+		r, err = store.Open(ctx, "docs/content/1")
+		So(err, ShouldBeNil)
+		data, _ = io.ReadAll(r)
+		r.Close()
+		So(string(data), ShouldEqual, "world")
+
+		So(store.Delete(ctx, "docs/content/1"), ShouldBeNil)
+		So(store.Delete(ctx, "docs/content/1"), ShouldBeNil)
+		_, err = store.Open(ctx, "docs/content/1")
+		So(errors.Is(err, ErrBlobNotFound), ShouldBeTrue)
+
+		So(store.Put(ctx, "../escape", strings.NewReader("x")), ShouldNotBeNil)
+
+		Convey("ctx 取消时停止写入", func() {
+			ctx, cancel := context.WithCancel(ctx)
+			cancel()
+			So(store.Put(ctx, "docs/content/2", strings.NewReader("x")), ShouldNotBeNil)
+			_, err := store.Open(context.Background(), "docs/content/2")
+			So(errors.Is(err, ErrBlobNotFound), ShouldBeTrue)
+		})
+	})
+}
+
+func TestBlobInterceptor(t *testing.T) {
+	Convey("测试大字段拦截器", t, func() {
+		ctx := context.Background()
+		root := t.TempDir()
+		store, err := NewFileBlobStoreWithOptions(&FileBlobStoreOptions{Root: root})
+		So(err, ShouldBeNil)
+
+		sqlDB, err := NewSQLWithOptions(&SQLOptions{Driver: "sqlite3", Database: ":memory:", MaxConns: 1, MaxIdle: 1})
+		So(err, ShouldBeNil)
+		defer sqlDB.Close()
+
+		db := NewInterceptorDatabase(sqlDB, NewBlobInterceptor(store))
+		model, err := NewTableModelBuilder().FromStruct(&blobTestDocument{})
+		So(err, ShouldBeNil)
+		model.Table = "test_blob_documents"
+		So(model.Fields[2].Type, ShouldEqual, FieldTypeBlob)
+		So(db.Migrate(ctx, model), ShouldBeNil)
+
+		pk := map[string]any{"id": 1}
+		So(db.Create(ctx, model.Table, db.GetBuilder().FromStruct(&blobTestDocument{
+			ID: 1, Name: "a", Content: NewBlob(strings.NewReader("large content")),
+		})), ShouldBeNil)
+
+		Convey("数据库中只保存键，内容在存储中", func() {
+			record, err := sqlDB.Get(ctx, model.Table, pk)
+			So(err, ShouldBeNil)
+			key, _ := record.Fields()["content"].(string)
+			So(key, ShouldStartWith, "test_blob_documents/content/")
+			_, err = os.Stat(filepath.Join(root, filepath.FromSlash(key)))
+			So(err, ShouldBeNil)
+		})
+
+		Convey("读取时返回可流式读取的 Blob", func() {
+			record, err := db.Get(ctx, model.Table, pk)
+			So(err, ShouldBeNil)
+			var doc blobTestDocument
+			So(record.Scan(&doc), ShouldBeNil)
+			So(doc.Name, ShouldEqual, "a")
+			So(readBlob(doc.Content), ShouldEqual, "large content")
+			So(readBlob(record.Fields()["content"].(*Blob)), ShouldEqual, "large content")
+
+			records, err := db.Find(ctx, model.Table, &query.TermQuery{Field: "id", Value: 1})
+			So(err, ShouldBeNil)
+			So(records, ShouldHaveLength, 1)
+			So(readBlob(records[0].Fields()["content"].(*Blob)), ShouldEqual, "large content")
+
+			cursor, err := db.FindStream(ctx, model.Table, &query.TermQuery{Field: "id", Value: 1})
+			So(err, ShouldBeNil)
+			defer cursor.Close()
+			So(cursor.Next(), ShouldBeTrue)
+			var streamed blobTestDocument
+			So(cursor.Scan(&streamed), ShouldBeNil)
+			So(readBlob(streamed.Content), ShouldEqual, "large content")
+		})
+
+		Convey("更新 blob 字段", func() {
+			So(db.UpdatePartial(ctx, model.Table, pk, map[string]any{"content": []byte("partial")}), ShouldBeNil)
+			record, err := db.Get(ctx, model.Table, pk)
+			So(err, ShouldBeNil)
+			So(readBlob(record.Fields()["content"].(*Blob)), ShouldEqual, "partial")
+
+			// 未修改的 Blob 原样写回键，不重新上传
+			var doc blobTestDocument
+			So(record.Scan(&doc), ShouldBeNil)
+			key := doc.Content.Key()
+			doc.Name = "b"
+			So(db.Update(ctx, model.Table, pk, TrackDirty(db.GetBuilder(), record).FromStruct(&doc)), ShouldBeNil)
+			So(db.Update(ctx, model.Table, pk, db.GetBuilder().FromStruct(&doc)), ShouldBeNil)
+			record, err = db.Get(ctx, model.Table, pk)
+			So(err, ShouldBeNil)
+			So(record.Fields()["content"].(*Blob).Key(), ShouldEqual, key)
+			So(record.Fields()["name"], ShouldEqual, "b")
+
+			// 清理旧内容
+			So(DeleteBlobs(ctx, record), ShouldBeNil)
+			_, err = record.Fields()["content"].(*Blob).Open(ctx)
+			So(errors.Is(err, ErrBlobNotFound), ShouldBeTrue)
+		})
+
+		Convey("写入失败时删除已写入的内容", func() {
+			err := db.Create(ctx, model.Table, db.GetBuilder().FromMap(map[string]any{
+				"id": 1, "name": "dup", "content": bytes.NewReader([]byte("orphan")),
+			}, model.Table))
+			So(err, ShouldNotBeNil)
+
+			entries, err := os.ReadDir(filepath.Join(root, model.Table, "content"))
+			So(err, ShouldBeNil)
+			So(entries, ShouldHaveLength, 1)
+		})
+
+		Convey("批量写入", func() {
+			So(db.BatchCreate(ctx, model.Table, []Record{
+				db.GetBuilder().FromStruct(&blobTestDocument{ID: 2, Content: NewBlob(strings.NewReader("two"))}),
+				db.GetBuilder().FromStruct(&blobTestDocument{ID: 3}),
+			}), ShouldBeNil)
+			record, err := db.Get(ctx, model.Table, map[string]any{"id": 2})
+			So(err, ShouldBeNil)
+			So(readBlob(record.Fields()["content"].(*Blob)), ShouldEqual, "two")
+			record, err = db.Get(ctx, model.Table, map[string]any{"id": 3})
+			So(err, ShouldBeNil)
+			So(record.Fields()["content"], ShouldBeNil)
+		})
+
+		Convey("未经过拦截器的 Blob 不能写入", func() {
+			err := sqlDB.Create(ctx, model.Table, sqlDB.GetBuilder().FromStruct(&blobTestDocument{
+				ID: 4, Content: NewBlob(strings.NewReader("x")),
+			}))
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestNewInterceptorDatabaseWithBlobOptions(t *testing.T) {
+	Convey("测试通过配置启用大字段存储", t, func() {
+		ctx := context.Background()
+		db, err := NewInterceptorDatabaseWithOptions(&InterceptorDatabaseOptions{
+			Database: &ref.TypeOptions{
+				Namespace: "github.com/hatlonely/gox/rdb/database",
+				Type:      "SQL",
+				Options:   &SQLOptions{Driver: "sqlite3", Database: ":memory:", MaxConns: 1, MaxIdle: 1},
+			},
+			Blob: &BlobOptions{
+				Store: &ref.TypeOptions{
+					Namespace: "github.com/hatlonely/gox/rdb/database",
+					Type:      "FileBlobStore",
+					Options:   &FileBlobStoreOptions{Root: t.TempDir()},
+				},
+				Tables: map[string][]string{"test_blob_config": {"content"}},
+			},
+		})
+		So(err, ShouldBeNil)
+		defer db.Close()
+
+		So(db.Database.Migrate(ctx, &TableModel{
+			Table:      "test_blob_config",
+			Fields:     []FieldDefinition{{Name: "id", Type: FieldTypeInt}, {Name: "content", Type: FieldTypeBlob}},
+			PrimaryKey: []string{"id"},
+		}), ShouldBeNil)
+		So(db.Create(ctx, "test_blob_config", db.GetBuilder().FromMap(map[string]any{"id": 1, "content": []byte("hello")}, "test_blob_config")), ShouldBeNil)
+		record, err := db.Get(ctx, "test_blob_config", map[string]any{"id": 1})
+		So(err, ShouldBeNil)
+		So(readBlob(record.Fields()["content"].(*Blob)), ShouldEqual, "hello")
+
+		_, err = NewInterceptorDatabaseWithOptions(&InterceptorDatabaseOptions{
+			Database: &ref.TypeOptions{
+				Namespace: "github.com/hatlonely/gox/rdb/database",
+				Type:      "SQL",
+				Options:   &SQLOptions{Driver: "sqlite3", Database: ":memory:"},
+			},
+			Blob: &BlobOptions{},
+		})
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldContainSubstring, "*database.SQL")
+
+		// 包装的数据库逐层解开到底层数据库
+		So(Unwrap(NewCoalescingDatabase(db)), ShouldEqual, db.Database)
+		So(Unwrap(db.Database), ShouldEqual, db.Database)
+	})
+}
+
+func TestMongoGridFSBlobStore(t *testing.T) {
+	Convey("测试 GridFS blob 存储", t, func() {
+		ctx := context.Background()
+		m, err := NewMongoWithOptions(testMongoOptions)
+		So(err, ShouldBeNil)
+		defer m.Close()
+
+		store := NewGridFSBlobStore(m, "test_blobs")
+		So(store.Put(ctx, "docs/content/1", strings.NewReader("hello")), ShouldBeNil)
+		So(store.Put(ctx, "docs/content/1", strings.NewReader("world")), ShouldBeNil)
+		r, err := store.Open(ctx, "docs/content/1")
+		So(err, ShouldBeNil)
+		data, _ := io.ReadAll(r)
+		r.Close()
+		So(string(data), ShouldEqual, "world")
+
+		So(store.Delete(ctx, "docs/content/1"), ShouldBeNil)
+		So(store.Delete(ctx, "docs/content/1"), ShouldBeNil)
+		_, err = store.Open(ctx, "docs/content/1")
+		So(errors.Is(err, ErrBlobNotFound), ShouldBeTrue)
+
+		// 包装后的 Mongo 未配置存储时同样使用 GridFS
+		_, err = newBlobInterceptorWithOptions(NewCoalescingDatabase(NewInterceptorDatabase(m)), &BlobOptions{Bucket: "test_blobs"})
+		So(err, ShouldBeNil)
+	})
+}
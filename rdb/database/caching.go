@@ -0,0 +1,514 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hatlonely/gox/kv/store"
+	"github.com/hatlonely/gox/rdb/query"
+	"github.com/hatlonely/gox/ref"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func init() {
+	// 缓存的记录以 gob 编码，字段值为 any，需要注册各后端返回的值类型
+	gob.Register(time.Time{})
+	gob.Register(map[string]any{})
+	gob.Register([]any{})
+	gob.Register(primitive.ObjectID{})
+	gob.Register(primitive.DateTime(0))
+	gob.Register(primitive.M{})
+	gob.Register(primitive.A{})
+	gob.Register(primitive.D{})
+	gob.Register(primitive.Decimal128{})
+	gob.Register(primitive.Binary{})
+}
+
+// CachingOptions 查询缓存配置
+type CachingOptions struct {
+	// Database 被包装的底层数据库配置
+	Database *ref.TypeOptions `cfg:"database" validate:"required"`
+	// Store 缓存存储，kv/store 中值类型为 []byte 的实现，如进程内的 FreeCacheStore[string,[]uint8]、多实例共享的 RedisStore[string,[]uint8]
+	Store *ref.TypeOptions `cfg:"store" validate:"required"`
+	// TTL 缓存有效期，写操作之外的数据变更（如其他服务直接写库）最多延迟 TTL 后可见
+	TTL time.Duration `cfg:"ttl" def:"1m" validate:"gt=0"`
+	// Prefix 缓存键前缀，多个服务共用 Redis 时用于区分
+	Prefix string `cfg:"prefix" def:"rdb"`
+	// Tables 只缓存这些表，为空时缓存所有表
+	Tables []string `cfg:"tables"`
+	// Metrics 缓存命中和失效指标，注册到 prometheus.DefaultRegisterer，Name 作为 database 标签
+	Metrics *MetricsOptions `cfg:"metrics"`
+}
+
+// CacheStats 缓存统计
+type CacheStats struct {
+	Hits          int64
+	Misses        int64
+	Invalidations int64
+}
+
+// CachingDatabase 缓存 Get 和 Find 的结果
+//
+// 缓存键由表名、表的版本号和主键或查询条件的哈希组成，同一个表的写操作成功后更新表的版本号，
+// 之前的缓存不再命中并随 TTL 过期；版本号保存在缓存存储中，使用 Redis 时多个实例之间同样生效。
+// 事务中的读取不使用缓存，事务中写过的表在提交后失效。
+// 使用 WithPrimaryRead 的读取跳过缓存。记录不存在时不缓存。
+// 命中缓存时记录由 GetBuilder().FromMap 重建，只包含字段，不包含 ES 文档版本等元数据
+type CachingDatabase struct {
+	Database
+
+	cache   store.Store[string, []byte]
+	ttl     time.Duration
+	prefix  string
+	tables  map[string]bool
+	metrics *cacheMetrics
+	name    string
+
+	hits          atomic.Int64
+	misses        atomic.Int64
+	invalidations atomic.Int64
+}
+
+// NewCachingDatabase 使用已创建的数据库和缓存存储创建查询缓存包装，缓存所有表
+func NewCachingDatabase(db Database, cache store.Store[string, []byte], ttl time.Duration) *CachingDatabase {
+	return &CachingDatabase{Database: db, cache: cache, ttl: ttl, prefix: "rdb"}
+}
+
+// Unwrap 返回被包装的数据库
+func (c *CachingDatabase) Unwrap() Database {
+	return c.Database
+}
+
+// NewCachingDatabaseWithOptions 使用配置创建查询缓存包装
+func NewCachingDatabaseWithOptions(options *CachingOptions) (*CachingDatabase, error) {
+	if options == nil {
+		return nil, errors.New("options is nil")
+	}
+
+	cache, err := store.NewStoreWithOptions[string, []byte](options.Store)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to create cache store")
+	}
+	db, err := NewDatabaseWithOptions(options.Database)
+	if err != nil {
+		cache.Close()
+		return nil, errors.WithMessage(err, "failed to create underlying database")
+	}
+
+	c := NewCachingDatabase(db, cache, options.TTL)
+	c.prefix = options.Prefix
+	if len(options.Tables) > 0 {
+		c.tables = make(map[string]bool, len(options.Tables))
+		for _, table := range options.Tables {
+			c.tables[table] = true
+		}
+	}
+	if options.Metrics != nil {
+		if c.metrics, err = newCacheMetrics(nil); err != nil {
+			c.Close()
+			return nil, errors.WithMessage(err, "failed to register metrics")
+		}
+		c.name = options.Metrics.Name
+	}
+	return c, nil
+}
+
+// Stats 返回缓存的命中、未命中和失效次数
+func (c *CachingDatabase) Stats() CacheStats {
+	return CacheStats{Hits: c.hits.Load(), Misses: c.misses.Load(), Invalidations: c.invalidations.Load()}
+}
+
+// Invalidate 使表的缓存失效，用于通过其他途径（如 Watch 订阅到的变更、其他服务的写入）得知数据变化时主动失效
+// ctx 中带有租户标识时只失效该租户的缓存
+func (c *CachingDatabase) Invalidate(ctx context.Context, tables ...string) error {
+	for _, table := range tables {
+		if !c.cacheable(table) {
+			continue
+		}
+		token := make([]byte, 8)
+		if _, err := rand.Read(token); err != nil {
+			return err
+		}
+		if err := c.cache.Set(ctx, c.versionKey(ctx, table), []byte(hex.EncodeToString(token))); err != nil {
+			return errors.WithMessagef(err, "failed to invalidate cache of table %s", table)
+		}
+		c.invalidations.Add(1)
+		if c.metrics != nil {
+			c.metrics.invalidations.WithLabelValues(c.name, table).Inc()
+		}
+	}
+	return nil
+}
+
+// Close 关闭底层数据库和缓存存储
+func (c *CachingDatabase) Close() error {
+	err := c.Database.Close()
+	if cerr := c.cache.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func (c *CachingDatabase) cacheable(table string) bool {
+	return c.tables == nil || c.tables[table]
+}
+
+// namespace 缓存键的前缀，ctx 中带有租户标识时加上租户，底层为 Router 时不同租户的缓存和版本号互相隔离
+func (c *CachingDatabase) namespace(ctx context.Context) string {
+	if tenant, ok := TenantFromContext(ctx); ok {
+		return fmt.Sprintf("%s:tenant:%s", c.prefix, tenant)
+	}
+	return c.prefix
+}
+
+func (c *CachingDatabase) versionKey(ctx context.Context, table string) string {
+	return fmt.Sprintf("%s:%s:version", c.namespace(ctx), table)
+}
+
+// entryKey 读取表的当前版本号，与操作和参数的哈希组成缓存键，表还没有版本号时使用 0
+func (c *CachingDatabase) entryKey(ctx context.Context, table string, operation Operation, payload string) (string, error) {
+	version := []byte("0")
+	v, err := c.cache.Get(ctx, c.versionKey(ctx, table))
+	if err == nil {
+		version = v
+	} else if !errors.Is(err, store.ErrKeyNotFound) {
+		return "", err
+	}
+
+	sum := sha256.Sum256([]byte(payload))
+	return fmt.Sprintf("%s:%s:%s:%s:%s", c.namespace(ctx), table, version, operation, hex.EncodeToString(sum[:16])), nil
+}
+
+// cached 从缓存读取记录，未命中时调用 load 并写入缓存，缓存存储不可用时直接调用 load
+func (c *CachingDatabase) cached(ctx context.Context, table string, operation Operation, payload string, load func() ([]Record, error)) ([]Record, error) {
+	if !c.cacheable(table) || isPrimaryRead(ctx) {
+		return load()
+	}
+
+	key, err := c.entryKey(ctx, table, operation, payload)
+	if err != nil {
+		return load()
+	}
+	if data, err := c.cache.Get(ctx, key); err == nil {
+		if records, err := c.decodeRecords(table, data); err == nil {
+			c.record(table, operation, true)
+			return records, nil
+		}
+	}
+	c.record(table, operation, false)
+
+	records, err := load()
+	if err != nil {
+		return nil, err
+	}
+	// 字段中有未注册的类型时无法编码，不缓存
+	if data, err := encodeRecords(records); err == nil {
+		_ = c.cache.Set(ctx, key, data, store.WithExpiration(c.ttl))
+	}
+	return records, nil
+}
+
+func (c *CachingDatabase) record(table string, operation Operation, hit bool) {
+	result := "miss"
+	if hit {
+		c.hits.Add(1)
+		result = "hit"
+	} else {
+		c.misses.Add(1)
+	}
+	if c.metrics != nil {
+		c.metrics.requests.WithLabelValues(c.name, table, string(operation), result).Inc()
+	}
+}
+
+func encodeRecords(records []Record) ([]byte, error) {
+	fields := make([]map[string]any, len(records))
+	for i, record := range records {
+		fields[i] = record.Fields()
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(fields); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (c *CachingDatabase) decodeRecords(table string, data []byte) ([]Record, error) {
+	var fields []map[string]any
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&fields); err != nil {
+		return nil, err
+	}
+	builder := c.Database.GetBuilder()
+	records := make([]Record, len(fields))
+	for i, f := range fields {
+		records[i] = builder.FromMap(f, table)
+	}
+	return records, nil
+}
+
+// Get 根据主键获取记录，优先从缓存读取
+func (c *CachingDatabase) Get(ctx context.Context, table string, pk map[string]any, opts ...QueryOption) (Record, error) {
+	records, err := c.cached(ctx, table, OpGet, coalescingGetKey(table, pk, opts), func() ([]Record, error) {
+		record, err := c.Database.Get(ctx, table, pk, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return []Record{record}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return records[0], nil
+}
+
+// Find 查询记录，优先从缓存读取，查询条件和查询选项相同的查询共用缓存
+func (c *CachingDatabase) Find(ctx context.Context, table string, query query.Query, opts ...QueryOption) ([]Record, error) {
+	payload, err := findCachePayload(query, opts)
+	if err != nil {
+		return c.Database.Find(ctx, table, query, opts...)
+	}
+	return c.cached(ctx, table, OpFind, payload, func() ([]Record, error) {
+		return c.Database.Find(ctx, table, query, opts...)
+	})
+}
+
+// findCachePayload 由查询条件的规范形式以及查询选项组成缓存键的参数
+func findCachePayload(q query.Query, opts []QueryOption) (string, error) {
+	options := &QueryOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+	// 缓存只保存记录的字段，无法还原相关度评分，需要评分的查询不使用缓存
+	if options.Score {
+		return "", fmt.Errorf("query with score is not cacheable")
+	}
+	// 超时不影响查询结果，不同超时的相同查询共用缓存
+	options.Timeout = 0
+	data, err := json.Marshal(struct {
+		Query   any
+		Options *QueryOptions
+	}{canonicalQuery(q), options})
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// canonicalQuery 返回带有每一层查询类型的规范形式
+// 只序列化查询内容时，嵌套在 BoolQuery 中字段相同的 TermQuery 和 PrefixQuery 会得到相同的结果
+func canonicalQuery(q query.Query) any {
+	bq, ok := q.(*query.BoolQuery)
+	if !ok {
+		return struct {
+			Type  string
+			Query query.Query
+		}{fmt.Sprintf("%T", q), q}
+	}
+
+	canonical := func(queries []query.Query) []any {
+		result := make([]any, len(queries))
+		for i, sub := range queries {
+			result[i] = canonicalQuery(sub)
+		}
+		return result
+	}
+	return struct {
+		Type           string
+		Must           []any
+		Should         []any
+		MustNot        []any
+		Filter         []any
+		MinShouldMatch *int
+	}{fmt.Sprintf("%T", q), canonical(bq.Must), canonical(bq.Should), canonical(bq.MustNot), canonical(bq.Filter), bq.MinShouldMatch}
+}
+
+// invalidateAfter 写操作完成后使表的缓存失效，写操作失败时也可能已部分写入，同样失效
+func (c *CachingDatabase) invalidateAfter(ctx context.Context, table string, err error) error {
+	if ierr := c.Invalidate(context.WithoutCancel(ctx), table); err == nil {
+		err = ierr
+	}
+	return err
+}
+
+func (c *CachingDatabase) Migrate(ctx context.Context, model *TableModel) error {
+	return c.invalidateAfter(ctx, model.Table, c.Database.Migrate(ctx, model))
+}
+
+func (c *CachingDatabase) DropTable(ctx context.Context, table string) error {
+	return c.invalidateAfter(ctx, table, c.Database.DropTable(ctx, table))
+}
+
+func (c *CachingDatabase) Create(ctx context.Context, table string, record Record, opts ...CreateOption) error {
+	return c.invalidateAfter(ctx, table, c.Database.Create(ctx, table, record, opts...))
+}
+
+func (c *CachingDatabase) Update(ctx context.Context, table string, pk map[string]any, record Record, opts ...UpdateOption) error {
+	return c.invalidateAfter(ctx, table, c.Database.Update(ctx, table, pk, record, opts...))
+}
+
+func (c *CachingDatabase) UpdatePartial(ctx context.Context, table string, pk map[string]any, fields map[string]any, opts ...UpdateOption) error {
+	return c.invalidateAfter(ctx, table, c.Database.UpdatePartial(ctx, table, pk, fields, opts...))
+}
+
+func (c *CachingDatabase) Upsert(ctx context.Context, table string, pk map[string]any, record Record) error {
+	return c.invalidateAfter(ctx, table, c.Database.Upsert(ctx, table, pk, record))
+}
+
+func (c *CachingDatabase) Increment(ctx context.Context, table string, pk map[string]any, field string, delta any) error {
+	return c.invalidateAfter(ctx, table, c.Database.Increment(ctx, table, pk, field, delta))
+}
+
+func (c *CachingDatabase) Delete(ctx context.Context, table string, pk map[string]any) error {
+	return c.invalidateAfter(ctx, table, c.Database.Delete(ctx, table, pk))
+}
+
+func (c *CachingDatabase) BatchCreate(ctx context.Context, table string, records []Record, opts ...CreateOption) error {
+	return c.invalidateAfter(ctx, table, c.Database.BatchCreate(ctx, table, records, opts...))
+}
+
+func (c *CachingDatabase) BatchUpdate(ctx context.Context, table string, pks []map[string]any, records []Record) error {
+	return c.invalidateAfter(ctx, table, c.Database.BatchUpdate(ctx, table, pks, records))
+}
+
+func (c *CachingDatabase) BatchDelete(ctx context.Context, table string, pks []map[string]any) error {
+	return c.invalidateAfter(ctx, table, c.Database.BatchDelete(ctx, table, pks))
+}
+
+// BeginTx 开启事务，事务中写过的表在提交后失效
+func (c *CachingDatabase) BeginTx(ctx context.Context) (Transaction, error) {
+	tx, err := c.Database.BeginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &cachingTransaction{Transaction: tx, ctx: ctx, cache: c}, nil
+}
+
+// WithTx 在事务中执行 fn，事务中写过的表在提交后失效
+func (c *CachingDatabase) WithTx(ctx context.Context, fn func(tx Transaction) error) error {
+	var written *cachingTransaction
+	err := c.Database.WithTx(ctx, func(tx Transaction) error {
+		written = &cachingTransaction{Transaction: tx, ctx: ctx, cache: c}
+		return fn(written)
+	})
+	if err != nil {
+		return err
+	}
+	return c.Invalidate(context.WithoutCancel(ctx), written.writtenTables()...)
+}
+
+// cachingTransaction 记录事务中写过的表，事务中的读取直接使用事务
+type cachingTransaction struct {
+	Transaction
+
+	ctx    context.Context
+	cache  *CachingDatabase
+	mu     sync.Mutex
+	tables []string
+}
+
+func (tx *cachingTransaction) write(table string, err error) error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	for _, t := range tx.tables {
+		if t == table {
+			return err
+		}
+	}
+	tx.tables = append(tx.tables, table)
+	return err
+}
+
+func (tx *cachingTransaction) writtenTables() []string {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	return append([]string(nil), tx.tables...)
+}
+
+func (tx *cachingTransaction) Commit() error {
+	if err := tx.Transaction.Commit(); err != nil {
+		return err
+	}
+	return tx.cache.Invalidate(context.WithoutCancel(tx.ctx), tx.writtenTables()...)
+}
+
+func (tx *cachingTransaction) Migrate(ctx context.Context, model *TableModel) error {
+	return tx.write(model.Table, tx.Transaction.Migrate(ctx, model))
+}
+
+func (tx *cachingTransaction) DropTable(ctx context.Context, table string) error {
+	return tx.write(table, tx.Transaction.DropTable(ctx, table))
+}
+
+func (tx *cachingTransaction) Create(ctx context.Context, table string, record Record, opts ...CreateOption) error {
+	return tx.write(table, tx.Transaction.Create(ctx, table, record, opts...))
+}
+
+func (tx *cachingTransaction) Update(ctx context.Context, table string, pk map[string]any, record Record, opts ...UpdateOption) error {
+	return tx.write(table, tx.Transaction.Update(ctx, table, pk, record, opts...))
+}
+
+func (tx *cachingTransaction) UpdatePartial(ctx context.Context, table string, pk map[string]any, fields map[string]any, opts ...UpdateOption) error {
+	return tx.write(table, tx.Transaction.UpdatePartial(ctx, table, pk, fields, opts...))
+}
+
+func (tx *cachingTransaction) Upsert(ctx context.Context, table string, pk map[string]any, record Record) error {
+	return tx.write(table, tx.Transaction.Upsert(ctx, table, pk, record))
+}
+
+func (tx *cachingTransaction) Increment(ctx context.Context, table string, pk map[string]any, field string, delta any) error {
+	return tx.write(table, tx.Transaction.Increment(ctx, table, pk, field, delta))
+}
+
+func (tx *cachingTransaction) Delete(ctx context.Context, table string, pk map[string]any) error {
+	return tx.write(table, tx.Transaction.Delete(ctx, table, pk))
+}
+
+func (tx *cachingTransaction) BatchCreate(ctx context.Context, table string, records []Record, opts ...CreateOption) error {
+	return tx.write(table, tx.Transaction.BatchCreate(ctx, table, records, opts...))
+}
+
+func (tx *cachingTransaction) BatchUpdate(ctx context.Context, table string, pks []map[string]any, records []Record) error {
+	return tx.write(table, tx.Transaction.BatchUpdate(ctx, table, pks, records))
+}
+
+func (tx *cachingTransaction) BatchDelete(ctx context.Context, table string, pks []map[string]any) error {
+	return tx.write(table, tx.Transaction.BatchDelete(ctx, table, pks))
+}
+
+// cacheMetrics 查询缓存的 Prometheus 指标
+type cacheMetrics struct {
+	requests      *prometheus.CounterVec
+	invalidations *prometheus.CounterVec
+}
+
+func newCacheMetrics(registerer prometheus.Registerer) (*cacheMetrics, error) {
+	if registerer == nil {
+		registerer = prometheus.DefaultRegisterer
+	}
+	requests, err := registerCollector(registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "rdb_cache_requests_total",
+		Help: "Total number of cached reads, labeled by hit or miss.",
+	}, []string{"database", "table", "operation", "result"}))
+	if err != nil {
+		return nil, err
+	}
+	invalidations, err := registerCollector(registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "rdb_cache_invalidations_total",
+		Help: "Total number of table cache invalidations.",
+	}, []string{"database", "table"}))
+	if err != nil {
+		return nil, err
+	}
+	return &cacheMetrics{requests: requests, invalidations: invalidations}, nil
+}
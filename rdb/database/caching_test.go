@@ -0,0 +1,252 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hatlonely/gox/kv/store"
+	"github.com/hatlonely/gox/rdb/query"
+	"github.com/hatlonely/gox/ref"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCachingDatabase(t *testing.T) {
+	type user struct {
+		ID      int       `rdb:"id"`
+		Name    string    `rdb:"name"`
+		Created time.Time `rdb:"created"`
+	}
+
+	Convey("测试 CachingDatabase 查询缓存", t, func() {
+		ctx := context.Background()
+		sqlDB, err := NewSQLWithOptions(&SQLOptions{Driver: "sqlite3", Database: ":memory:", MaxConns: 1, MaxIdle: 1})
+		So(err, ShouldBeNil)
+		db := NewCachingDatabase(sqlDB, store.NewMapStoreWithOptions[string, []byte](), time.Minute)
+		defer db.Close()
+
+		So(db.Migrate(ctx, &TableModel{
+			Table: "test_cache_users",
+			Fields: []FieldDefinition{
+				{Name: "id", Type: FieldTypeInt, Required: true},
+				{Name: "name", Type: FieldTypeString, Size: 100},
+				{Name: "created", Type: FieldTypeDate},
+			},
+			PrimaryKey: []string{"id"},
+		}), ShouldBeNil)
+		created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+		So(db.Create(ctx, "test_cache_users", db.GetBuilder().FromStruct(&user{ID: 1, Name: "alice", Created: created})), ShouldBeNil)
+		pk := map[string]any{"id": 1}
+
+		Convey("Get 命中缓存", func() {
+			record, err := db.Get(ctx, "test_cache_users", pk)
+			So(err, ShouldBeNil)
+			So(db.Stats(), ShouldResemble, CacheStats{Misses: 1, Invalidations: 2})
+
+			// 绕过缓存直接修改，缓存中仍为旧值
+			So(sqlDB.UpdatePartial(ctx, "test_cache_users", pk, map[string]any{"name": "bob"}), ShouldBeNil)
+			cached, err := db.Get(ctx, "test_cache_users", pk)
+			So(err, ShouldBeNil)
+			So(db.Stats().Hits, ShouldEqual, 1)
+
+			var u user
+			So(cached.Scan(&u), ShouldBeNil)
+			So(u.Name, ShouldEqual, "alice")
+			So(u.Created.Equal(created), ShouldBeTrue)
+			So(cached.Fields(), ShouldResemble, record.Fields())
+
+			// 只返回部分字段的查询单独缓存
+			partial, err := db.Get(ctx, "test_cache_users", pk, WithFields("name"))
+			So(err, ShouldBeNil)
+			So(partial.Fields(), ShouldResemble, map[string]any{"name": "bob"})
+			So(db.Stats().Misses, ShouldEqual, 2)
+
+			// 主动失效
+			So(db.Invalidate(ctx, "test_cache_users"), ShouldBeNil)
+			record, err = db.Get(ctx, "test_cache_users", pk)
+			So(err, ShouldBeNil)
+			So(record.Fields()["name"], ShouldEqual, "bob")
+		})
+
+		Convey("写操作使表的缓存失效", func() {
+			q := &query.TermQuery{Field: "name", Value: "alice"}
+			records, err := db.Find(ctx, "test_cache_users", q)
+			So(err, ShouldBeNil)
+			So(records, ShouldHaveLength, 1)
+			_, err = db.Find(ctx, "test_cache_users", q)
+			So(err, ShouldBeNil)
+			So(db.Stats().Hits, ShouldEqual, 1)
+
+			So(db.UpdatePartial(ctx, "test_cache_users", pk, map[string]any{"name": "bob"}), ShouldBeNil)
+			records, err = db.Find(ctx, "test_cache_users", q)
+			So(err, ShouldBeNil)
+			So(records, ShouldBeEmpty)
+			So(db.Stats().Hits, ShouldEqual, 1)
+
+			// 查询选项不同的查询不共用缓存
+			_, err = db.Find(ctx, "test_cache_users", &query.RangeQuery{Field: "id", Gte: 0})
+			So(err, ShouldBeNil)
+			_, err = db.Find(ctx, "test_cache_users", &query.RangeQuery{Field: "id", Gte: 0}, func(o *QueryOptions) { o.Limit = 1 })
+			So(err, ShouldBeNil)
+			So(db.Stats().Hits, ShouldEqual, 1)
+		})
+
+		Convey("嵌套查询类型不同的查询不共用缓存", func() {
+			term := &query.BoolQuery{Must: []query.Query{&query.TermQuery{Field: "name", Value: "al"}}}
+			prefix := &query.BoolQuery{Must: []query.Query{&query.PrefixQuery{Field: "name", Value: "al"}}}
+			termPayload, err := findCachePayload(term, nil)
+			So(err, ShouldBeNil)
+			prefixPayload, err := findCachePayload(prefix, nil)
+			So(err, ShouldBeNil)
+			So(termPayload, ShouldNotEqual, prefixPayload)
+
+			// 缓存无法还原相关度评分
+			_, err = findCachePayload(term, []QueryOption{WithScore()})
+			So(err, ShouldNotBeNil)
+
+			records, err := db.Find(ctx, "test_cache_users", term)
+			So(err, ShouldBeNil)
+			So(records, ShouldBeEmpty)
+			records, err = db.Find(ctx, "test_cache_users", prefix)
+			So(err, ShouldBeNil)
+			So(records, ShouldHaveLength, 1)
+			So(db.Stats().Hits, ShouldEqual, 0)
+		})
+
+		Convey("记录不存在时不缓存", func() {
+			_, err := db.Get(ctx, "test_cache_users", map[string]any{"id": 2})
+			So(err, ShouldEqual, ErrRecordNotFound)
+			So(sqlDB.Create(ctx, "test_cache_users", db.GetBuilder().FromStruct(&user{ID: 2, Name: "carol"})), ShouldBeNil)
+			record, err := db.Get(ctx, "test_cache_users", map[string]any{"id": 2})
+			So(err, ShouldBeNil)
+			So(record.Fields()["name"], ShouldEqual, "carol")
+		})
+
+		Convey("读主库时跳过缓存", func() {
+			_, err := db.Get(ctx, "test_cache_users", pk)
+			So(err, ShouldBeNil)
+			So(sqlDB.UpdatePartial(ctx, "test_cache_users", pk, map[string]any{"name": "bob"}), ShouldBeNil)
+			record, err := db.Get(WithPrimaryRead(ctx), "test_cache_users", pk)
+			So(err, ShouldBeNil)
+			So(record.Fields()["name"], ShouldEqual, "bob")
+		})
+
+		Convey("事务提交后失效", func() {
+			_, err := db.Get(ctx, "test_cache_users", pk)
+			So(err, ShouldBeNil)
+
+			So(db.WithTx(ctx, func(tx Transaction) error {
+				return tx.UpdatePartial(ctx, "test_cache_users", pk, map[string]any{"name": "bob"})
+			}), ShouldBeNil)
+			record, err := db.Get(ctx, "test_cache_users", pk)
+			So(err, ShouldBeNil)
+			So(record.Fields()["name"], ShouldEqual, "bob")
+
+			tx, err := db.BeginTx(ctx)
+			So(err, ShouldBeNil)
+			So(tx.UpdatePartial(ctx, "test_cache_users", pk, map[string]any{"name": "carol"}), ShouldBeNil)
+			So(tx.Commit(), ShouldBeNil)
+			record, err = db.Get(ctx, "test_cache_users", pk)
+			So(err, ShouldBeNil)
+			So(record.Fields()["name"], ShouldEqual, "carol")
+		})
+	})
+
+	Convey("测试 CachingDatabase 按租户隔离缓存", t, func() {
+		template := testRouterTemplate()
+		template.Options.(map[string]any)["database"] = "file:cache_router_{tenant}?mode=memory&cache=shared"
+		router, err := NewRouterWithOptions(&RouterOptions{Database: template})
+		So(err, ShouldBeNil)
+		db := NewCachingDatabase(router, store.NewMapStoreWithOptions[string, []byte](), time.Minute)
+		defer db.Close()
+
+		ctxA := WithTenant(context.Background(), "a")
+		ctxB := WithTenant(context.Background(), "b")
+		pk := map[string]any{"id": 1}
+		for ctx, name := range map[context.Context]string{ctxA: "alice", ctxB: "bob"} {
+			So(db.Migrate(ctx, testRouterModel), ShouldBeNil)
+			So(db.Create(ctx, "test_router_users", db.GetBuilder().FromMap(map[string]any{"id": 1, "name": name}, "test_router_users")), ShouldBeNil)
+		}
+
+		// 相同的主键在不同租户中读到各自的记录
+		record, err := db.Get(ctxA, "test_router_users", pk)
+		So(err, ShouldBeNil)
+		So(record.Fields()["name"], ShouldEqual, "alice")
+		record, err = db.Get(ctxB, "test_router_users", pk)
+		So(err, ShouldBeNil)
+		So(record.Fields()["name"], ShouldEqual, "bob")
+		So(db.Stats().Misses, ShouldEqual, 2)
+
+		// 一个租户的写操作不使其他租户的缓存失效
+		So(db.UpdatePartial(ctxB, "test_router_users", pk, map[string]any{"name": "carol"}), ShouldBeNil)
+		record, err = db.Get(ctxA, "test_router_users", pk)
+		So(err, ShouldBeNil)
+		So(record.Fields()["name"], ShouldEqual, "alice")
+		So(db.Stats().Hits, ShouldEqual, 1)
+		record, err = db.Get(ctxB, "test_router_users", pk)
+		So(err, ShouldBeNil)
+		So(record.Fields()["name"], ShouldEqual, "carol")
+		So(db.Stats().Misses, ShouldEqual, 3)
+	})
+}
+
+func TestNewCachingDatabaseWithOptions(t *testing.T) {
+	Convey("测试通过配置创建 CachingDatabase", t, func() {
+		ctx := context.Background()
+		db, err := NewDatabaseWithOptions(&ref.TypeOptions{
+			Namespace: "github.com/hatlonely/gox/rdb/database",
+			Type:      "CachingDatabase",
+			Options: &CachingOptions{
+				Database: &ref.TypeOptions{
+					Namespace: "github.com/hatlonely/gox/rdb/database",
+					Type:      "SQL",
+					Options:   &SQLOptions{Driver: "sqlite3", Database: ":memory:", MaxConns: 1, MaxIdle: 1},
+				},
+				Store: &ref.TypeOptions{
+					Namespace: "github.com/hatlonely/gox/kv/store",
+					Type:      "FreeCacheStore[string,[]uint8]",
+					Options:   &store.FreeCacheStoreOptions{Size: 1024 * 1024},
+				},
+				TTL:     time.Minute,
+				Prefix:  "test",
+				Tables:  []string{"test_cache_config"},
+				Metrics: &MetricsOptions{Name: "test_cache"},
+			},
+		})
+		So(err, ShouldBeNil)
+		defer db.Close()
+		c := db.(*CachingDatabase)
+
+		So(db.Migrate(ctx, &TableModel{
+			Table:      "test_cache_config",
+			Fields:     []FieldDefinition{{Name: "id", Type: FieldTypeInt}, {Name: "name", Type: FieldTypeString}},
+			PrimaryKey: []string{"id"},
+		}), ShouldBeNil)
+		So(db.Migrate(ctx, &TableModel{
+			Table:      "test_cache_other",
+			Fields:     []FieldDefinition{{Name: "id", Type: FieldTypeInt}},
+			PrimaryKey: []string{"id"},
+		}), ShouldBeNil)
+		So(db.Create(ctx, "test_cache_config", db.GetBuilder().FromMap(map[string]any{"id": 1, "name": "a"}, "test_cache_config")), ShouldBeNil)
+		So(db.Create(ctx, "test_cache_other", db.GetBuilder().FromMap(map[string]any{"id": 1}, "test_cache_other")), ShouldBeNil)
+
+		for i := 0; i < 2; i++ {
+			_, err = db.Get(ctx, "test_cache_config", map[string]any{"id": 1})
+			So(err, ShouldBeNil)
+			_, err = db.Get(ctx, "test_cache_other", map[string]any{"id": 1})
+			So(err, ShouldBeNil)
+		}
+		// 只缓存配置的表
+		So(c.Stats(), ShouldResemble, CacheStats{Hits: 1, Misses: 1, Invalidations: 2})
+
+		hits := testutil.ToFloat64(c.metrics.requests.WithLabelValues("test_cache", "test_cache_config", "Get", "hit"))
+		So(hits, ShouldBeGreaterThanOrEqualTo, 1)
+		So(testutil.CollectAndCount(c.metrics.invalidations), ShouldBeGreaterThan, 0)
+
+		// 重复创建时复用已注册的指标
+		_, err = newCacheMetrics(prometheus.DefaultRegisterer)
+		So(err, ShouldBeNil)
+	})
+}
@@ -0,0 +1,67 @@
+package database
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrWatchNotSupported 数据库没有配置变更订阅
+var ErrWatchNotSupported = errors.New("watch not supported")
+
+// ChangeType 数据变更类型
+type ChangeType string
+
+const (
+	ChangeInsert ChangeType = "insert"
+	ChangeUpdate ChangeType = "update"
+	ChangeDelete ChangeType = "delete"
+)
+
+// ChangeEvent 一条数据变更
+type ChangeEvent struct {
+	Type  ChangeType
+	Table string
+	PK    map[string]any // 变更记录的主键
+	// Record 变更后的完整记录，删除时为 nil
+	Record Record
+	// Time 变更提交的时间
+	Time time.Time
+	// ResumeToken 断点续订的位置，通过 WithResumeToken 从该事件之后继续订阅
+	ResumeToken []byte
+	// Err 订阅出错时推送一条只带 Err 的事件，之后 channel 关闭
+	Err error
+}
+
+// WatchOptions 变更订阅选项
+type WatchOptions struct {
+	ResumeToken []byte // 从该位置之后开始订阅，为空时从当前开始
+	BufferSize  int    // 事件 channel 的缓冲大小
+}
+
+type WatchOption func(*WatchOptions)
+
+// WithResumeToken 从 ChangeEvent.ResumeToken 对应的事件之后继续订阅
+func WithResumeToken(token []byte) WatchOption {
+	return func(opts *WatchOptions) {
+		opts.ResumeToken = token
+	}
+}
+
+// WithWatchBufferSize 设置事件 channel 的缓冲大小，默认 64
+func WithWatchBufferSize(size int) WatchOption {
+	return func(opts *WatchOptions) {
+		opts.BufferSize = size
+	}
+}
+
+func newWatchOptions(opts []WatchOption) *WatchOptions {
+	options := &WatchOptions{BufferSize: 64}
+	for _, opt := range opts {
+		opt(options)
+	}
+	if options.BufferSize < 0 {
+		options.BufferSize = 0
+	}
+	return options
+}
@@ -0,0 +1,133 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/hatlonely/gox/rdb/query"
+	"github.com/hatlonely/gox/ref"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// testChangeProvider 按订阅的表推送预设的事件
+type testChangeProvider struct {
+	events []ChangeEvent
+	closed bool
+}
+
+func newTestChangeProvider() *testChangeProvider {
+	return &testChangeProvider{events: []ChangeEvent{
+		{Type: ChangeInsert, PK: map[string]any{"id": 1}, Record: (&SQLRecordBuilder{}).FromMap(map[string]any{"id": 1, "name": "alice"}, "users")},
+		{Type: ChangeDelete, PK: map[string]any{"id": 1}},
+	}}
+}
+
+func (p *testChangeProvider) Watch(ctx context.Context, table string, query query.Query, opts ...WatchOption) (<-chan ChangeEvent, error) {
+	events := make(chan ChangeEvent)
+	go func() {
+		defer close(events)
+		for _, event := range p.events {
+			event.Table = table
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events, nil
+}
+
+func (p *testChangeProvider) Close() error {
+	p.closed = true
+	return nil
+}
+
+var testChangeProviderInstance = newTestChangeProvider()
+
+func TestSQLWatch(t *testing.T) {
+	ref.MustRegister("test", "ChangeProvider", func() *testChangeProvider { return testChangeProviderInstance })
+
+	Convey("测试 SQL 变更订阅", t, func() {
+		ctx := context.Background()
+
+		Convey("未配置 ChangeProvider 时不支持订阅", func() {
+			db, err := NewSQLWithOptions(&SQLOptions{Driver: "sqlite3", Database: ":memory:", MaxConns: 1, MaxIdle: 1})
+			So(err, ShouldBeNil)
+			defer db.Close()
+
+			_, err = db.Watch(ctx, "users", nil)
+			So(err, ShouldEqual, ErrWatchNotSupported)
+		})
+
+		Convey("转发 ChangeProvider 的事件", func() {
+			db, err := NewSQLWithOptions(&SQLOptions{
+				Driver: "sqlite3", Database: ":memory:", MaxConns: 1, MaxIdle: 1, CloseTimeout: time.Second,
+				ChangeProvider: &ref.TypeOptions{Namespace: "test", Type: "ChangeProvider"},
+			})
+			So(err, ShouldBeNil)
+
+			var watcher ChangeWatcher = db
+			events, err := watcher.Watch(ctx, "users", &query.TermQuery{Field: "name", Value: "alice"})
+			So(err, ShouldBeNil)
+
+			var received []ChangeEvent
+			for event := range events {
+				received = append(received, event)
+			}
+			So(len(received), ShouldEqual, 2)
+			So(received[0].Type, ShouldEqual, ChangeInsert)
+			So(received[0].Table, ShouldEqual, "users")
+			So(received[0].Record.Fields()["name"], ShouldEqual, "alice")
+			So(received[1].Type, ShouldEqual, ChangeDelete)
+			So(received[1].Record, ShouldBeNil)
+
+			So(db.Close(), ShouldBeNil)
+			So(testChangeProviderInstance.closed, ShouldBeTrue)
+			_, err = db.Watch(ctx, "users", nil)
+			So(err, ShouldEqual, ErrClosed)
+		})
+
+		Convey("ChangeProvider 必须实现 ChangeWatcher", func() {
+			_, err := NewSQLWithOptions(&SQLOptions{
+				Driver: "sqlite3", Database: ":memory:", MaxConns: 1, MaxIdle: 1,
+				ChangeProvider: &ref.TypeOptions{Namespace: "test", Type: "NotChangeProvider"},
+			})
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestWatchPipeline(t *testing.T) {
+	Convey("测试 change stream 管道", t, func() {
+		Convey("不带查询条件时只过滤事件类型", func() {
+			pipeline, err := mongoWatchPipeline(nil)
+			So(err, ShouldBeNil)
+			So(len(pipeline), ShouldEqual, 1)
+		})
+
+		Convey("查询条件作用于 fullDocument，删除事件不过滤", func() {
+			q := &query.BoolQuery{
+				Must:   []query.Query{&query.TermQuery{Field: "status", Value: "paid"}},
+				Should: []query.Query{&query.RangeQuery{Field: "amount", Gte: 100}, &query.TermQuery{Field: "vip", Value: true}},
+			}
+			pipeline, err := mongoWatchPipeline(q)
+			So(err, ShouldBeNil)
+			So(len(pipeline), ShouldEqual, 2)
+
+			match := pipeline[1][0].Value.(bson.M)["$or"].(bson.A)
+			So(match[0], ShouldResemble, bson.M{"operationType": bson.M{"$in": bson.A{"delete", "invalidate"}}})
+
+			filter := match[1].(map[string]interface{})
+			So(filter, ShouldContainKey, "$and")
+			conditions := filter["$and"].([]interface{})
+			So(conditions[0], ShouldResemble, map[string]interface{}{"fullDocument.status": "paid"})
+			should := conditions[1].(map[string]interface{})["$or"].([]interface{})
+			So(should[0], ShouldContainKey, "fullDocument.amount")
+			So(should[1], ShouldResemble, map[string]interface{}{"fullDocument.vip": true})
+		})
+	})
+}
@@ -0,0 +1,160 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hatlonely/gox/ref"
+	"github.com/pkg/errors"
+	"golang.org/x/sync/singleflight"
+)
+
+// CoalescingOptions 请求合并配置
+type CoalescingOptions struct {
+	// Database 被包装的底层数据库配置
+	Database *ref.TypeOptions `cfg:"database" validate:"required"`
+	// Timeout 合并后的后端查询的最长时间，发起者 ctx 的剩余时间更长时以剩余时间为准
+	Timeout time.Duration `cfg:"timeout" def:"30s"`
+}
+
+// defaultCoalescingTimeout NewCoalescingDatabase 合并后的后端查询的默认最长时间
+const defaultCoalescingTimeout = 30 * time.Second
+
+// CoalescingDatabase 合并并发的相同主键查询
+//
+// 同一时刻对同一租户、同一表、同一主键的多个 Get 只会向后端发起一次查询，其余调用等待并共享结果，
+// 用于缓解热点键在缓存击穿时对数据库的冲击。除 Get 和 GetMany 外的方法直接透传给底层数据库，
+// 事务中的读取也不做合并。
+// 合并后的调用方拿到的是同一个 Record，调用方不应修改其内容
+type CoalescingDatabase struct {
+	Database
+
+	group   singleflight.Group
+	timeout time.Duration
+}
+
+// NewCoalescingDatabase 使用已创建的数据库创建请求合并包装
+func NewCoalescingDatabase(db Database) *CoalescingDatabase {
+	return &CoalescingDatabase{Database: db, timeout: defaultCoalescingTimeout}
+}
+
+// Unwrap 返回被包装的数据库
+func (c *CoalescingDatabase) Unwrap() Database {
+	return c.Database
+}
+
+// NewCoalescingDatabaseWithOptions 使用配置创建请求合并包装
+func NewCoalescingDatabaseWithOptions(options *CoalescingOptions) (*CoalescingDatabase, error) {
+	if options == nil {
+		return nil, errors.New("options is nil")
+	}
+
+	db, err := NewDatabaseWithOptions(options.Database)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to create underlying database")
+	}
+
+	c := NewCoalescingDatabase(db)
+	if options.Timeout > 0 {
+		c.timeout = options.Timeout
+	}
+	return c, nil
+}
+
+// coalescingGetManyWorkers GetMany 并发查询的最大数量
+const coalescingGetManyWorkers = 16
+
+// Get 根据主键获取记录，并发的相同查询合并为一次后端调用
+// 要求读主库（WithPrimaryRead）的查询只与同样读主库的查询合并
+//
+// 合并后的查询不受发起者 ctx 取消的影响，否则发起者取消会让所有等待者一起失败；
+// 查询的最长时间为 Timeout 与发起者 ctx 剩余时间中的较大者，避免后端卡住时合并键一直被占用。
+// 每个调用方只等待到自己的 ctx 结束，提前返回 ctx 的错误
+func (c *CoalescingDatabase) Get(ctx context.Context, table string, pk map[string]any, opts ...QueryOption) (Record, error) {
+	key := coalescingGetKey(table, pk, opts)
+	if isPrimaryRead(ctx) {
+		key = "primary\x00" + key
+	}
+	// 底层为 Router 时不同租户的相同主键是不同的记录
+	if tenant, ok := TenantFromContext(ctx); ok {
+		key = "tenant=" + tenant + "\x00" + key
+	}
+	timeout := c.timeout
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) > timeout {
+		timeout = time.Until(deadline)
+	}
+	ch := c.group.DoChan(key, func() (interface{}, error) {
+		detached, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+		defer cancel()
+		return c.Database.Get(detached, table, pk, opts...)
+	})
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case r := <-ch:
+		if r.Err != nil {
+			return nil, r.Err
+		}
+		return r.Val.(Record), nil
+	}
+}
+
+// GetMany 根据主键批量获取记录，每个主键的查询独立合并，最多 coalescingGetManyWorkers 个查询并发执行
+// 返回结果与 pks 一一对应，记录不存在时对应位置为 nil
+func (c *CoalescingDatabase) GetMany(ctx context.Context, table string, pks []map[string]any) ([]Record, error) {
+	records := make([]Record, len(pks))
+	errs := make([]error, len(pks))
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for range min(coalescingGetManyWorkers, len(pks)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				records[i], errs[i] = c.Get(ctx, table, pks[i])
+			}
+		}()
+	}
+	for i := range pks {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil && !errors.Is(err, ErrRecordNotFound) {
+			return nil, err
+		}
+	}
+	return records, nil
+}
+
+// coalescingKey 由表名和按字段名排序的主键组成合并键
+func coalescingKey(table string, pk map[string]any) string {
+	keys := make([]string, 0, len(pk))
+	for k := range pk {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	sb.WriteString(table)
+	for _, k := range keys {
+		fmt.Fprintf(&sb, "\x00%s=%T:%v", k, pk[k], pk[k])
+	}
+	return sb.String()
+}
+
+// coalescingGetKey 在 coalescingKey 之后追加 WithFields 指定的字段，只返回部分字段的查询不与其他查询共用结果
+func coalescingGetKey(table string, pk map[string]any, opts []QueryOption) string {
+	key := coalescingKey(table, pk)
+	if fields := newQueryOptions(opts).Fields; len(fields) > 0 {
+		key += "\x00fields=" + strings.Join(fields, ",")
+	}
+	return key
+}
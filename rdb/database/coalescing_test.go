@@ -0,0 +1,198 @@
+package database
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hatlonely/gox/ref"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// slowGetDatabase 记录 Get 调用次数并模拟慢查询
+type slowGetDatabase struct {
+	Database
+	calls       atomic.Int32
+	inFlight    atomic.Int32
+	maxInFlight atomic.Int32
+}
+
+func (d *slowGetDatabase) Get(ctx context.Context, table string, pk map[string]any, opts ...QueryOption) (Record, error) {
+	d.calls.Add(1)
+	n := d.inFlight.Add(1)
+	defer d.inFlight.Add(-1)
+	for m := d.maxInFlight.Load(); n > m && !d.maxInFlight.CompareAndSwap(m, n); m = d.maxInFlight.Load() {
+	}
+
+	select {
+	case <-time.After(50 * time.Millisecond):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if pk["id"] == 404 {
+		return nil, ErrRecordNotFound
+	}
+	data := map[string]any{"id": pk["id"]}
+	if tenant, ok := TenantFromContext(ctx); ok {
+		data["tenant"] = tenant
+	}
+	return &SQLRecord{data: data}, nil
+}
+
+func TestCoalescingDatabase(t *testing.T) {
+	Convey("测试 CoalescingDatabase 请求合并", t, func() {
+		ctx := context.Background()
+		backend := &slowGetDatabase{}
+		db := NewCoalescingDatabase(backend)
+
+		Convey("并发的相同主键查询只调用一次后端", func() {
+			var wg sync.WaitGroup
+			records := make([]Record, 10)
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					records[i], _ = db.Get(ctx, "users", map[string]any{"id": 1})
+				}(i)
+			}
+			wg.Wait()
+
+			So(backend.calls.Load(), ShouldEqual, 1)
+			for _, record := range records {
+				So(record, ShouldNotBeNil)
+				So(record.Fields()["id"], ShouldEqual, 1)
+			}
+		})
+
+		Convey("不同主键和不同表不合并", func() {
+			var wg sync.WaitGroup
+			for _, table := range []string{"users", "orders"} {
+				for _, id := range []int{1, 2} {
+					wg.Add(1)
+					go func(table string, id int) {
+						defer wg.Done()
+						db.Get(ctx, table, map[string]any{"id": id})
+					}(table, id)
+				}
+			}
+			wg.Wait()
+
+			So(backend.calls.Load(), ShouldEqual, 4)
+		})
+
+		Convey("错误同样共享", func() {
+			_, err := db.Get(ctx, "users", map[string]any{"id": 404})
+			So(err, ShouldEqual, ErrRecordNotFound)
+		})
+
+		Convey("批量查询合并重复主键", func() {
+			records, err := db.GetMany(ctx, "users", []map[string]any{{"id": 1}, {"id": 404}, {"id": 1}, {"id": 2}})
+			So(err, ShouldBeNil)
+			So(len(records), ShouldEqual, 4)
+			So(records[0].Fields()["id"], ShouldEqual, 1)
+			So(records[1], ShouldBeNil)
+			So(records[3].Fields()["id"], ShouldEqual, 2)
+			So(backend.calls.Load(), ShouldEqual, 3)
+		})
+
+		Convey("发起者取消不影响其他等待者", func() {
+			leaderCtx, cancel := context.WithCancel(ctx)
+			leaderErr := make(chan error, 1)
+			go func() {
+				_, err := db.Get(leaderCtx, "users", map[string]any{"id": 1})
+				leaderErr <- err
+			}()
+			time.Sleep(10 * time.Millisecond)
+
+			waiter := make(chan Record, 1)
+			go func() {
+				record, _ := db.Get(ctx, "users", map[string]any{"id": 1})
+				waiter <- record
+			}()
+			time.Sleep(10 * time.Millisecond)
+			cancel()
+
+			So(<-leaderErr, ShouldEqual, context.Canceled)
+			record := <-waiter
+			So(record, ShouldNotBeNil)
+			So(record.Fields()["id"], ShouldEqual, 1)
+			So(backend.calls.Load(), ShouldEqual, 1)
+		})
+
+		Convey("等待者 ctx 超时时提前返回", func() {
+			timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+			defer cancel()
+			start := time.Now()
+			_, err := db.Get(timeoutCtx, "users", map[string]any{"id": 1})
+			So(err, ShouldEqual, context.DeadlineExceeded)
+			So(time.Since(start), ShouldBeLessThan, 50*time.Millisecond)
+		})
+
+		Convey("不同租户不合并", func() {
+			var wg sync.WaitGroup
+			records := make([]Record, 2)
+			for i, tenant := range []string{"a", "b"} {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					records[i], _ = db.Get(WithTenant(ctx, tenant), "users", map[string]any{"id": 1})
+				}()
+			}
+			wg.Wait()
+
+			So(backend.calls.Load(), ShouldEqual, 2)
+			So(records[0].Fields()["tenant"], ShouldEqual, "a")
+			So(records[1].Fields()["tenant"], ShouldEqual, "b")
+		})
+
+		Convey("后端查询超时后释放合并键", func() {
+			db.timeout = 10 * time.Millisecond
+			_, err := db.Get(ctx, "users", map[string]any{"id": 1})
+			So(err, ShouldEqual, context.DeadlineExceeded)
+
+			// 发起者 ctx 的剩余时间更长时以剩余时间为准
+			longCtx, cancel := context.WithTimeout(ctx, time.Second)
+			defer cancel()
+			record, err := db.Get(longCtx, "users", map[string]any{"id": 1})
+			So(err, ShouldBeNil)
+			So(record.Fields()["id"], ShouldEqual, 1)
+			So(backend.calls.Load(), ShouldEqual, 2)
+		})
+
+		Convey("批量查询的并发数有上限", func() {
+			pks := make([]map[string]any, coalescingGetManyWorkers*3)
+			for i := range pks {
+				pks[i] = map[string]any{"id": i}
+			}
+			records, err := db.GetMany(ctx, "users", pks)
+			So(err, ShouldBeNil)
+			So(len(records), ShouldEqual, len(pks))
+			So(records[len(pks)-1].Fields()["id"], ShouldEqual, len(pks)-1)
+			So(backend.maxInFlight.Load(), ShouldBeLessThanOrEqualTo, coalescingGetManyWorkers)
+		})
+
+		Convey("合并键与主键字段顺序无关", func() {
+			So(coalescingKey("t", map[string]any{"a": 1, "b": "x"}), ShouldEqual, coalescingKey("t", map[string]any{"b": "x", "a": 1}))
+			So(coalescingKey("t", map[string]any{"id": 1}), ShouldNotEqual, coalescingKey("t", map[string]any{"id": "1"}))
+		})
+	})
+
+	Convey("测试通过配置创建 CoalescingDatabase", t, func() {
+		db, err := NewCoalescingDatabaseWithOptions(&CoalescingOptions{
+			Database: &ref.TypeOptions{
+				Namespace: "github.com/hatlonely/gox/rdb/database",
+				Type:      "SQL",
+				Options:   testSQLiteOptions,
+			},
+			Timeout: time.Second,
+		})
+		So(err, ShouldBeNil)
+		defer db.Close()
+		So(db.timeout, ShouldEqual, time.Second)
+
+		_, err = NewCoalescingDatabaseWithOptions(nil)
+		So(err, ShouldNotBeNil)
+	})
+}
@@ -2,6 +2,7 @@ package database
 
 import (
 	"context"
+	"time"
 
 	"github.com/hatlonely/gox/rdb/aggregation"
 	"github.com/hatlonely/gox/rdb/query"
@@ -13,18 +14,26 @@ func init() {
 	ref.RegisterT[*SQL](NewSQLWithOptions)
 	ref.RegisterT[*Mongo](NewMongoWithOptions)
 	ref.RegisterT[*ES](NewESWithOptions)
+	ref.RegisterT[*CoalescingDatabase](NewCoalescingDatabaseWithOptions)
+	ref.RegisterT[*CachingDatabase](NewCachingDatabaseWithOptions)
+	ref.RegisterT[*Router](NewRouterWithOptions)
+	ref.RegisterT[*ReadOnlyDatabase](NewReadOnlyDatabaseWithOptions)
+	ref.RegisterT[*InterceptorDatabase](NewInterceptorDatabaseWithOptions)
+	ref.RegisterT[*DualWriteDatabase](NewDualWriteDatabaseWithOptions)
 }
 
 var (
 	ErrRecordNotFound   = errors.New("record not found")
 	ErrDuplicateKey     = errors.New("duplicate key")
 	ErrInvalidCondition = errors.New("invalid condition")
+	ErrVersionConflict  = errors.New("version conflict")
 )
 
 // CreateOptions 创建记录时的选项
 type CreateOptions struct {
 	IgnoreConflict   bool
 	UpdateOnConflict bool
+	Timeout          time.Duration // 单次操作的超时，0 表示只受 ctx 和连接级超时的限制
 }
 
 type CreateOption func(*CreateOptions)
@@ -43,16 +52,246 @@ func WithUpdateOnConflict() CreateOption {
 	}
 }
 
+// UpdateOptions 更新记录时的选项
+type UpdateOptions struct {
+	VersionField string // 乐观锁版本字段，为空时不做版本检查
+	Version      any    // 期望的当前版本
+
+	Timeout time.Duration // 单次操作的超时，0 表示只受 ctx 和连接级超时的限制
+}
+
+type UpdateOption func(*UpdateOptions)
+
+// WithVersion 乐观锁更新：只有记录的 field 字段等于 version 时才更新，同时将版本加 1
+// 更新内容中的版本字段会被忽略；记录存在但版本不一致时返回 ErrVersionConflict
+func WithVersion(field string, version any) UpdateOption {
+	return func(opts *UpdateOptions) {
+		opts.VersionField = field
+		opts.Version = version
+	}
+}
+
+func newUpdateOptions(opts []UpdateOption) *UpdateOptions {
+	options := &UpdateOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+	return options
+}
+
+// versioned 是否启用乐观锁
+func (o *UpdateOptions) versioned() bool {
+	return o.VersionField != ""
+}
+
+// stripVersion 返回去掉版本字段的更新内容，版本由更新操作本身加 1，不修改原 map
+func (o *UpdateOptions) stripVersion(fields map[string]any) map[string]any {
+	if !o.versioned() {
+		return fields
+	}
+	if _, ok := fields[o.VersionField]; !ok {
+		return fields
+	}
+
+	result := make(map[string]any, len(fields))
+	for k, v := range fields {
+		if k != o.VersionField {
+			result[k] = v
+		}
+	}
+	return result
+}
+
 // QueryOptions 查询选项
 type QueryOptions struct {
 	Limit     int
 	Offset    int
 	OrderBy   string
 	OrderDesc bool
+	BatchSize int // 流式查询每批从服务端拉取的记录数，0 表示使用后端默认值
+
+	// ThenBy 次排序字段，方向与 OrderBy 相同，OrderBy 有重复值时保证顺序稳定
+	ThenBy string
+	// SearchAfter 只返回排序在 (OrderBy, ThenBy) 这组值之后的记录，用于游标分页，参考 FindPage
+	SearchAfter []any
+
+	// Score 返回相关度评分，通过 RecordScore 读取；SQL 和 Mongo 要求查询条件中包含 FullTextQuery
+	Score bool
+	// OrderByScore 按相关度评分降序排序，OrderBy、ThenBy 作为评分相同时的次排序，不支持 SearchAfter
+	OrderByScore bool
+
+	// Timeout 单次查询的超时，0 表示只受 ctx 和连接级超时的限制；FindStream 的超时覆盖整个遍历过程，直到游标关闭
+	Timeout time.Duration
+
+	// Fields 只返回指定的字段，为空时返回所有字段；SQL 转换为 SELECT 的列，Mongo 转换为 projection，ES 转换为 _source 过滤
+	Fields []string
+
+	// GroupBy Aggregate 按这些字段分组统计指标，参考 WithGroupBy
+	GroupBy []string
+	// Having 过滤 GroupBy 的分组统计结果，参考 WithHaving
+	Having query.Query
 }
 
 type QueryOption func(*QueryOptions)
 
+func newQueryOptions(opts []QueryOption) *QueryOptions {
+	options := &QueryOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+	return options
+}
+
+// WithBatchSize 设置流式查询的批大小
+func WithBatchSize(size int) QueryOption {
+	return func(opts *QueryOptions) {
+		opts.BatchSize = size
+	}
+}
+
+// WithFields 只返回指定的字段，适用于只需要少数字段的宽表查询，减少读取和传输的数据量
+// 记录的 Fields 只包含返回的字段；Mongo 未指定 _id 时不返回 _id，ES 的 _id 等元数据字段始终返回
+func WithFields(fields ...string) QueryOption {
+	return func(opts *QueryOptions) {
+		opts.Fields = fields
+	}
+}
+
+// WithScore 返回相关度评分
+func WithScore() QueryOption {
+	return func(opts *QueryOptions) {
+		opts.Score = true
+	}
+}
+
+// WithOrderByScore 返回相关度评分并按评分降序排序
+func WithOrderByScore() QueryOption {
+	return func(opts *QueryOptions) {
+		opts.Score = true
+		opts.OrderByScore = true
+	}
+}
+
+// ErrScoreUnsupported 查询无法计算相关度评分，SQL 和 Mongo 只有全文检索有评分
+var ErrScoreUnsupported = errors.New("relevance score requires a full text query")
+
+// scoring 返回是否需要计算相关度评分
+func (o *QueryOptions) scoring() (bool, error) {
+	if o.OrderByScore && len(o.SearchAfter) > 0 {
+		return false, errors.New("order by score does not support search after")
+	}
+	return o.Score || o.OrderByScore, nil
+}
+
+// fullTextQueries 返回参与评分的全文检索，与 ES 一致，只有 Must 和 Should 中的条件参与评分
+func fullTextQueries(q query.Query) []*query.FullTextQuery {
+	switch v := q.(type) {
+	case *query.FullTextQuery:
+		return []*query.FullTextQuery{v}
+	case *query.BoolQuery:
+		var result []*query.FullTextQuery
+		for _, group := range [][]query.Query{v.Must, v.Should} {
+			for _, sub := range group {
+				result = append(result, fullTextQueries(sub)...)
+			}
+		}
+		return result
+	}
+	return nil
+}
+
+// TableStats 表统计信息，各后端返回的均为估算值，适用于容量看板和测试断言
+type TableStats struct {
+	RowCount  int64 // 行数（文档数）
+	DataSize  int64 // 数据大小，单位字节
+	IndexSize int64 // 索引大小，单位字节
+}
+
+// StatsProvider 支持查询表统计信息的数据库
+type StatsProvider interface {
+	// Stats 获取表统计信息
+	Stats(ctx context.Context, table string) (*TableStats, error)
+}
+
+// MigrateOptions 增量迁移选项
+type MigrateOptions struct {
+	DryRun bool // 只生成计划执行的语句，不实际执行
+}
+
+type MigrateOption func(*MigrateOptions)
+
+// WithDryRun 只返回计划执行的迁移语句
+func WithDryRun() MigrateOption {
+	return func(opts *MigrateOptions) {
+		opts.DryRun = true
+	}
+}
+
+// SchemaMigrator 支持对比现有结构做增量迁移的数据库
+type SchemaMigrator interface {
+	// MigrateDiff 对比现有结构，补齐缺失的表、列（字段映射）和索引，返回计划执行的语句
+	// 只做新增，不删除或修改已有的列和索引
+	MigrateDiff(ctx context.Context, model *TableModel, opts ...MigrateOption) ([]string, error)
+}
+
+// ChangeWatcher 支持订阅数据变更的数据库
+type ChangeWatcher interface {
+	// Watch 订阅表中满足 query 的记录的变更，ctx 取消后停止订阅并关闭 channel
+	// 删除事件没有变更后的记录，不按 query 过滤
+	Watch(ctx context.Context, table string, query query.Query, opts ...WatchOption) (<-chan ChangeEvent, error)
+}
+
+// ExplainResult 查询翻译为后端语句的结果
+type ExplainResult struct {
+	// Backend 后端类型：sql、mongo、es
+	Backend string
+	// Statement 发送给后端的语句：SQL 为带占位符的 SQL，Mongo 为 mongo shell 语法的 find，ES 为搜索请求及请求体
+	Statement string
+	// Args SQL 的绑定参数，与 Statement 中的占位符一一对应，Mongo 和 ES 为空
+	Args []any
+}
+
+// Explainer 支持查看查询翻译结果的数据库，用于确认抽象层实际发送给后端的内容
+type Explainer interface {
+	// Explain 返回 Find 使用相同参数时发送给后端的语句，不执行查询
+	Explain(ctx context.Context, table string, query query.Query, opts ...QueryOption) (*ExplainResult, error)
+}
+
+// ErrExplainUnsupported 数据库不支持 Explain
+var ErrExplainUnsupported = errors.New("explain is not supported")
+
+// Explain 返回 Find 使用相同参数时发送给后端的语句，不执行查询
+// db 本身不支持时逐层解开包装，使用最先找到的支持 Explain 的数据库，都不支持时返回 ErrExplainUnsupported
+func Explain(ctx context.Context, db Database, table string, query query.Query, opts ...QueryOption) (*ExplainResult, error) {
+	for {
+		if explainer, ok := db.(Explainer); ok {
+			return explainer.Explain(ctx, table, query, opts...)
+		}
+		w, ok := db.(Unwrapper)
+		if !ok {
+			return nil, ErrExplainUnsupported
+		}
+		db = w.Unwrap()
+	}
+}
+
+// Unwrapper 包装其他数据库的实现，如 CachingDatabase、CoalescingDatabase、InterceptorDatabase
+type Unwrapper interface {
+	// Unwrap 返回被包装的数据库
+	Unwrap() Database
+}
+
+// Unwrap 逐层解开包装，返回最内层的数据库，db 没有包装时原样返回
+func Unwrap(db Database) Database {
+	for {
+		w, ok := db.(Unwrapper)
+		if !ok {
+			return db
+		}
+		db = w.Unwrap()
+	}
+}
+
 // Record 通用记录接口，用于数据转换
 type Record interface {
 	// 查询时的转换方法
@@ -61,6 +300,53 @@ type Record interface {
 
 	// 写入时的数据提取方法
 	Fields() map[string]any
+
+	// Diff 返回当前记录中与 other 不同的字段及当前记录的值，other 中没有的字段也包含在内
+	// 数值、字符串与 []byte、时间按值比较，不要求类型完全一致
+	Diff(other Record) map[string]any
+}
+
+// Scorer 带有相关度评分的记录，使用 WithScore 查询时各后端返回的记录实现该接口
+type Scorer interface {
+	// Score 返回相关度评分，分数越高越相关，没有评分时返回 false
+	Score() (float64, bool)
+}
+
+// RecordScore 返回记录的相关度评分，记录没有评分时返回 0 和 false
+//
+// 各后端的评分算法不同（MySQL 的 MATCH、SQLite FTS5 的 bm25 取反、Mongo 的 textScore、ES 的 _score），
+// 只适合用于同一次查询结果之间的比较
+func RecordScore(record Record) (float64, bool) {
+	if scorer, ok := record.(Scorer); ok {
+		return scorer.Score()
+	}
+	return 0, false
+}
+
+// RecordCursor 记录游标，用于流式遍历查询结果，避免一次性加载全部记录
+// 使用方式：
+//
+//	cursor, err := db.FindStream(ctx, table, q)
+//	defer cursor.Close()
+//	for cursor.Next() {
+//		cursor.Scan(&user)
+//	}
+//	err = cursor.Err()
+type RecordCursor interface {
+	// Next 移动到下一条记录，没有更多记录或出错时返回 false
+	Next() bool
+
+	// Scan 将当前记录扫描到 dest
+	Scan(dest any) error
+
+	// Record 获取当前记录
+	Record() Record
+
+	// Err 返回遍历过程中的错误
+	Err() error
+
+	// Close 关闭游标，释放底层连接
+	Close() error
 }
 
 // RecordBuilder 记录构建器，用于创建Record实例
@@ -87,11 +373,21 @@ type Database interface {
 	// Create 创建记录
 	Create(ctx context.Context, table string, record Record, opts ...CreateOption) error
 
-	// Get 根据主键获取记录
-	Get(ctx context.Context, table string, pk map[string]any) (Record, error)
+	// Get 根据主键获取记录，可以通过 WithFields 只返回部分字段
+	Get(ctx context.Context, table string, pk map[string]any, opts ...QueryOption) (Record, error)
+
+	// Update 更新记录（根据主键），可以通过 WithVersion 启用乐观锁
+	Update(ctx context.Context, table string, pk map[string]any, record Record, opts ...UpdateOption) error
+
+	// UpdatePartial 根据主键只更新指定字段，未指定的字段保持不变，可以通过 WithVersion 启用乐观锁
+	UpdatePartial(ctx context.Context, table string, pk map[string]any, fields map[string]any, opts ...UpdateOption) error
+
+	// Upsert 根据主键插入或更新记录，记录不存在时插入 record 与 pk 合并后的记录，存在时用 record 覆盖
+	// 与 Create 的 WithUpdateOnConflict 不同，冲突只由 pk 中的字段判断
+	Upsert(ctx context.Context, table string, pk map[string]any, record Record) error
 
-	// Update 更新记录（根据主键）
-	Update(ctx context.Context, table string, pk map[string]any, record Record) error
+	// Increment 根据主键对数值字段做原子增减，delta 为整数或浮点数
+	Increment(ctx context.Context, table string, pk map[string]any, field string, delta any) error
 
 	// Delete 根据主键删除记录
 	Delete(ctx context.Context, table string, pk map[string]any) error
@@ -99,6 +395,15 @@ type Database interface {
 	// Find 根据查询条件查询多条记录
 	Find(ctx context.Context, table string, query query.Query, opts ...QueryOption) ([]Record, error)
 
+	// FindStream 根据查询条件流式查询记录，返回的游标使用完毕后必须关闭
+	FindStream(ctx context.Context, table string, query query.Query, opts ...QueryOption) (RecordCursor, error)
+
+	// Count 统计满足查询条件的记录数
+	Count(ctx context.Context, table string, query query.Query) (int64, error)
+
+	// Exists 根据主键判断记录是否存在
+	Exists(ctx context.Context, table string, pk map[string]any) (bool, error)
+
 	// Aggregate 执行聚合查询
 	Aggregate(ctx context.Context, table string, query query.Query, aggs []aggregation.Aggregation, opts ...QueryOption) (aggregation.AggregationResult, error)
 
@@ -120,13 +425,16 @@ type Database interface {
 	// GetBuilder 获取记录构建器
 	GetBuilder() RecordBuilder
 
+	// Health 检查与后端的连接是否可用
+	Health(ctx context.Context) error
+
 	// Close 关闭连接
 	Close() error
 }
 
 // 工厂方法
 func NewDatabaseWithOptions(options *ref.TypeOptions) (Database, error) {
-	database, err := ref.New(options.Namespace, options.Type, options.Options)
+	database, err := ref.NewWithOptions(options)
 	if err != nil {
 		return nil, errors.WithMessage(err, "ref.New failed")
 	}
@@ -139,3 +447,29 @@ func NewDatabaseWithOptions(options *ref.TypeOptions) (Database, error) {
 
 	return database.(Database), nil
 }
+
+// upsertFields 合并 record 的字段和主键，主键的值覆盖 record 中的同名字段
+func upsertFields(pk map[string]any, record Record) (map[string]any, error) {
+	if len(pk) == 0 {
+		return nil, errors.New("upsert requires a primary key")
+	}
+	fields := make(map[string]any, len(pk))
+	if record != nil {
+		for k, v := range record.Fields() {
+			fields[k] = v
+		}
+	}
+	for k, v := range pk {
+		fields[k] = v
+	}
+	return fields, nil
+}
+
+// validateIncrementDelta 校验 Increment 的增量必须为数值类型
+func validateIncrementDelta(delta any) error {
+	switch delta.(type) {
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return nil
+	}
+	return errors.Errorf("increment delta must be a number, got %T", delta)
+}
@@ -0,0 +1,167 @@
+package database
+
+import (
+	"database/sql/driver"
+	"reflect"
+	"time"
+)
+
+// DirtyRecord 记录了相对原始记录修改过的字段，由 TrackDirty 返回的构建器创建
+//
+// Fields 返回完整字段，Create 插入时使用；
+// Update、BatchUpdate 只写入 DirtyFields，没有修改时不访问数据库；
+// Create 使用 WithUpdateOnConflict 冲突时只更新 DirtyFields，其他字段保留数据库中的值
+type DirtyRecord interface {
+	Record
+
+	// DirtyFields 修改过的字段及其新值
+	DirtyFields() map[string]any
+}
+
+// TrackDirty 返回脏字段跟踪模式的构建器，构建的记录与 original 比较得到修改过的字段
+//
+//	original, _ := db.Get(ctx, "users", pk)
+//	original.Scan(&user)
+//	user.Name = "bob"
+//	db.Update(ctx, "users", pk, database.TrackDirty(db.GetBuilder(), original).FromStruct(&user)) // 只更新 name
+func TrackDirty(builder RecordBuilder, original Record) RecordBuilder {
+	return &dirtyRecordBuilder{builder: builder, original: original}
+}
+
+type dirtyRecordBuilder struct {
+	builder  RecordBuilder
+	original Record
+}
+
+func (b *dirtyRecordBuilder) FromStruct(v any) Record {
+	return newDirtyRecord(b.builder.FromStruct(v), b.original)
+}
+
+func (b *dirtyRecordBuilder) FromMap(data map[string]any, table string) Record {
+	return newDirtyRecord(b.builder.FromMap(data, table), b.original)
+}
+
+// TagName 与被包装的构建器一致
+func (b *dirtyRecordBuilder) TagName() string {
+	return BuilderTagName(b.builder)
+}
+
+// dirtyRecord 在构建时计算修改过的字段，之后修改原始记录不影响结果
+type dirtyRecord struct {
+	Record
+	dirty map[string]any
+}
+
+func newDirtyRecord(record Record, original Record) *dirtyRecord {
+	return &dirtyRecord{Record: record, dirty: record.Diff(original)}
+}
+
+func (r *dirtyRecord) DirtyFields() map[string]any {
+	return r.dirty
+}
+
+// updateFields Update 写入的字段，DirtyRecord 只写入修改过的字段
+func updateFields(record Record) map[string]any {
+	if dirty, ok := record.(DirtyRecord); ok {
+		return dirty.DirtyFields()
+	}
+	return record.Fields()
+}
+
+// diffFields 返回 fields 中与 other 不同的字段，other 为 nil 时返回全部字段
+func diffFields(fields map[string]any, other Record) map[string]any {
+	var otherFields map[string]any
+	if other != nil {
+		otherFields = other.Fields()
+	}
+
+	diff := make(map[string]any)
+	for k, v := range fields {
+		if ov, ok := otherFields[k]; !ok || !fieldValuesEqual(v, ov) {
+			diff[k] = v
+		}
+	}
+	return diff
+}
+
+// fieldValuesEqual 比较字段值，兼容数据库返回值与结构体字段类型的差异，
+// 如 int 与 int64、string 与 []byte、bool 与 0/1、time.Time 的时区
+func fieldValuesEqual(a, b any) bool {
+	a, b = normalizeFieldValue(a), normalizeFieldValue(b)
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	if reflect.DeepEqual(a, b) {
+		return true
+	}
+
+	switch av := a.(type) {
+	case time.Time:
+		bv, ok := b.(time.Time)
+		return ok && av.Equal(bv)
+	case string:
+		bv, ok := b.([]byte)
+		return ok && av == string(bv)
+	case []byte:
+		bv, ok := b.(string)
+		return ok && string(av) == bv
+	}
+
+	ra, rb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if ra.Kind() == reflect.Bool {
+		ra, rb = rb, ra
+	}
+	if rb.Kind() == reflect.Bool {
+		if f, ok := numericValue(ra); ok {
+			return (f != 0) == rb.Bool()
+		}
+		return false
+	}
+	if ra.CanInt() && rb.CanInt() {
+		return ra.Int() == rb.Int()
+	}
+	if ra.CanUint() && rb.CanUint() {
+		return ra.Uint() == rb.Uint()
+	}
+	fa, okA := numericValue(ra)
+	fb, okB := numericValue(rb)
+	return okA && okB && fa == fb
+}
+
+// normalizeFieldValue 解引用指针并展开 driver.Valuer，nil 指针视为 nil
+func normalizeFieldValue(v any) any {
+	for v != nil {
+		if valuer, ok := v.(driver.Valuer); ok {
+			value, err := valuer.Value()
+			if err != nil {
+				return v
+			}
+			if _, same := value.(driver.Valuer); same {
+				return value
+			}
+			v = value
+			continue
+		}
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Ptr {
+			return v
+		}
+		if rv.IsNil() {
+			return nil
+		}
+		v = rv.Elem().Interface()
+	}
+	return v
+}
+
+func numericValue(rv reflect.Value) (float64, bool) {
+	switch {
+	case rv.CanInt():
+		return float64(rv.Int()), true
+	case rv.CanUint():
+		return float64(rv.Uint()), true
+	case rv.CanFloat():
+		return rv.Float(), true
+	}
+	return 0, false
+}
@@ -0,0 +1,155 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRecordDiff(t *testing.T) {
+	Convey("测试记录比较", t, func() {
+		builder := &SQLRecordBuilder{}
+		now := time.Now()
+		original := builder.FromMap(map[string]any{
+			"id":      int64(1),
+			"name":    []byte("alice"),
+			"enabled": int64(1),
+			"score":   float64(3),
+			"created": now.UTC(),
+			"note":    nil,
+		}, "users")
+
+		Convey("类型不同但值相同的字段视为相同", func() {
+			name := "alice"
+			record := builder.FromMap(map[string]any{
+				"id":      1,
+				"name":    &name,
+				"enabled": true,
+				"score":   3,
+				"created": now,
+				"note":    sql.NullString{},
+			}, "users")
+			So(record.Diff(original), ShouldBeEmpty)
+		})
+
+		Convey("返回修改过的字段和 other 中没有的字段", func() {
+			record := builder.FromMap(map[string]any{
+				"id":      1,
+				"name":    "bob",
+				"enabled": false,
+				"score":   3.5,
+				"extra":   "x",
+			}, "users")
+			So(record.Diff(original), ShouldResemble, map[string]any{
+				"name":    "bob",
+				"enabled": false,
+				"score":   3.5,
+				"extra":   "x",
+			})
+		})
+
+		Convey("other 为 nil 时返回全部字段", func() {
+			So(original.Diff(nil), ShouldResemble, original.Fields())
+		})
+
+		Convey("各实现一致", func() {
+			fields := map[string]any{"id": 1, "name": "bob"}
+			for _, b := range []RecordBuilder{&MongoRecordBuilder{}, &ESRecordBuilder{}} {
+				So(b.FromMap(fields, "users").Diff(original), ShouldResemble, map[string]any{"name": "bob"})
+			}
+		})
+	})
+}
+
+func TestTrackDirty(t *testing.T) {
+	type user struct {
+		ID    int    `rdb:"id"`
+		Name  string `rdb:"name"`
+		Email string `rdb:"email"`
+		Age   int    `rdb:"age"`
+	}
+
+	Convey("测试脏字段跟踪", t, func() {
+		db, err := NewSQLWithOptions(&SQLOptions{Driver: "sqlite3", Database: ":memory:", MaxConns: 1, MaxIdle: 1, StatementCacheSize: 16})
+		So(err, ShouldBeNil)
+		defer db.Close()
+
+		ctx := context.Background()
+		So(db.Migrate(ctx, &TableModel{
+			Table: "test_dirty_users",
+			Fields: []FieldDefinition{
+				{Name: "id", Type: FieldTypeInt, Required: true},
+				{Name: "name", Type: FieldTypeString, Size: 100},
+				{Name: "email", Type: FieldTypeString, Size: 100},
+				{Name: "age", Type: FieldTypeInt},
+			},
+			PrimaryKey: []string{"id"},
+		}), ShouldBeNil)
+		pk := map[string]any{"id": 1}
+		So(db.Create(ctx, "test_dirty_users", db.GetBuilder().FromStruct(&user{ID: 1, Name: "alice", Email: "alice@example.com", Age: 20})), ShouldBeNil)
+
+		original, err := db.Get(ctx, "test_dirty_users", pk)
+		So(err, ShouldBeNil)
+		var u user
+		So(original.Scan(&u), ShouldBeNil)
+
+		get := func() user {
+			record, err := db.Get(ctx, "test_dirty_users", pk)
+			So(err, ShouldBeNil)
+			var u user
+			So(record.Scan(&u), ShouldBeNil)
+			return u
+		}
+
+		Convey("Update 只写入修改过的字段", func() {
+			// 其他写入者同时修改了 email
+			So(db.UpdatePartial(ctx, "test_dirty_users", pk, map[string]any{"email": "new@example.com"}), ShouldBeNil)
+
+			u.Name = "bob"
+			record := TrackDirty(db.GetBuilder(), original).FromStruct(&u)
+			So(record.(DirtyRecord).DirtyFields(), ShouldResemble, map[string]any{"name": "bob"})
+			So(db.Update(ctx, "test_dirty_users", pk, record), ShouldBeNil)
+
+			So(get(), ShouldResemble, user{ID: 1, Name: "bob", Email: "new@example.com", Age: 20})
+		})
+
+		Convey("没有修改时 Update 不访问数据库", func() {
+			record := TrackDirty(db.GetBuilder(), original).FromStruct(&u)
+			So(record.(DirtyRecord).DirtyFields(), ShouldBeEmpty)
+			So(db.Update(ctx, "test_dirty_missing", pk, record), ShouldBeNil)
+			So(db.BatchUpdate(ctx, "test_dirty_missing", []map[string]any{pk}, []Record{record}), ShouldBeNil)
+		})
+
+		Convey("事务中的 Update", func() {
+			u.Age = 21
+			So(db.WithTx(ctx, func(tx Transaction) error {
+				return tx.Update(ctx, "test_dirty_users", pk, TrackDirty(db.GetBuilder(), original).FromStruct(&u))
+			}), ShouldBeNil)
+			So(get().Age, ShouldEqual, 21)
+		})
+
+		Convey("冲突时只更新修改过的字段", func() {
+			So(db.UpdatePartial(ctx, "test_dirty_users", pk, map[string]any{"email": "new@example.com"}), ShouldBeNil)
+
+			u.Age = 30
+			record := TrackDirty(db.GetBuilder(), original).FromStruct(&u)
+			So(db.Create(ctx, "test_dirty_users", record, WithUpdateOnConflict()), ShouldBeNil)
+			So(get(), ShouldResemble, user{ID: 1, Name: "alice", Email: "new@example.com", Age: 30})
+
+			// 记录不存在时插入完整记录
+			record = TrackDirty(db.GetBuilder(), original).FromStruct(&user{ID: 2, Name: "carol", Email: "carol@example.com", Age: 20})
+			So(db.Create(ctx, "test_dirty_users", record, WithUpdateOnConflict()), ShouldBeNil)
+			created, err := db.Get(ctx, "test_dirty_users", map[string]any{"id": 2})
+			So(err, ShouldBeNil)
+			So(created.Fields()["email"], ShouldEqual, "carol@example.com")
+
+			// 没有修改时冲突忽略
+			record = TrackDirty(db.GetBuilder(), original).FromStruct(&user{ID: 1, Name: "alice", Email: "alice@example.com", Age: 20})
+			So(db.Create(ctx, "test_dirty_users", record, WithUpdateOnConflict()), ShouldBeNil)
+			So(get(), ShouldResemble, user{ID: 1, Name: "alice", Email: "new@example.com", Age: 30})
+		})
+	})
+}
@@ -0,0 +1,194 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrClosed 数据库已关闭，Close 之后发起的操作返回该错误
+	ErrClosed = errors.New("database is closed")
+	// ErrCloseTimeout Close 等待进行中的操作超时，未完成的操作被中断
+	ErrCloseTimeout = errors.New("timed out waiting for in-flight operations")
+)
+
+// operationKey 标记 context 已在 tracker 中登记
+type operationKey struct {
+	tracker *operationTracker
+}
+
+// operationTracker 跟踪进行中的操作和事务，关闭时拒绝新操作并等待进行中的操作完成
+//
+// 操作通过 enter 登记，返回的 context 带有登记标记，同一操作内的嵌套调用（如 BatchCreate 调用 Create）
+// 不重复登记，关闭期间也不会被拒绝。nil tracker 不做跟踪
+type operationTracker struct {
+	mu      sync.Mutex
+	active  int
+	closed  bool
+	drained chan struct{}
+}
+
+func newOperationTracker() *operationTracker {
+	return &operationTracker{drained: make(chan struct{})}
+}
+
+// enter 登记一个操作，操作结束后必须调用返回的 done，done 可以重复调用
+func (t *operationTracker) enter(ctx context.Context) (context.Context, func(), error) {
+	if t == nil || ctx.Value(operationKey{t}) != nil {
+		return ctx, func() {}, nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return ctx, nil, ErrClosed
+	}
+	t.active++
+
+	var once sync.Once
+	return context.WithValue(ctx, operationKey{t}, true), func() { once.Do(t.leave) }, nil
+}
+
+func (t *operationTracker) leave() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.active--
+	if t.closed && t.active == 0 {
+		close(t.drained)
+	}
+}
+
+// close 拒绝新操作并等待进行中的操作完成，返回超时后仍未完成的操作数，重复调用返回 0
+func (t *operationTracker) close(timeout time.Duration) int {
+	if t == nil {
+		return 0
+	}
+
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return 0
+	}
+	t.closed = true
+	if t.active == 0 {
+		close(t.drained)
+	}
+	t.mu.Unlock()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-t.drained:
+		return 0
+	case <-timer.C:
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.active
+}
+
+// closeError 合并关闭连接的错误和被中断的操作数
+func closeError(aborted int, err error) error {
+	if aborted == 0 {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %d in-flight operations aborted, close error: %v", ErrCloseTimeout, aborted, err)
+	}
+	return fmt.Errorf("%w: %d in-flight operations aborted", ErrCloseTimeout, aborted)
+}
+
+// trackedCursor 关闭时结束操作登记
+type trackedCursor struct {
+	RecordCursor
+	done func()
+}
+
+func (c *trackedCursor) Close() error {
+	defer c.done()
+	return c.RecordCursor.Close()
+}
+
+// trackedTransaction 提交或回滚时结束操作登记，事务在此之前一直算作进行中的操作
+//
+// 事务绑定开启时的 context，context 取消或超时后自动回滚并结束登记，调用方忘记 Rollback 时也能释放连接；
+// 自动回滚之后 Commit 返回 ErrTxCanceled，Rollback 返回 nil
+type trackedTransaction struct {
+	Transaction
+	done func()
+
+	ctx      context.Context
+	mu       sync.Mutex
+	finished bool
+	canceled bool
+	stop     func() bool
+	leak     *time.Timer
+}
+
+// newTrackedTransaction 跟踪事务，leaks 不为 nil 时同时检测事务泄漏
+func newTrackedTransaction(ctx context.Context, tx Transaction, done func(), leaks *txLeakDetector) *trackedTransaction {
+	t := &trackedTransaction{Transaction: tx, done: done, ctx: ctx}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.leak = leaks.watch()
+	t.stop = context.AfterFunc(ctx, t.cancel)
+	return t
+}
+
+// finish 标记事务结束，事务已经结束时返回 false
+func (tx *trackedTransaction) finish(canceled bool) bool {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if tx.finished {
+		return false
+	}
+	tx.finished = true
+	tx.canceled = canceled
+	tx.stop()
+	if tx.leak != nil {
+		tx.leak.Stop()
+	}
+	return true
+}
+
+// isCanceled 事务是否因 context 结束而自动回滚
+func (tx *trackedTransaction) isCanceled() bool {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	return tx.canceled
+}
+
+// cancel 在 context 结束时回滚事务
+func (tx *trackedTransaction) cancel() {
+	if !tx.finish(true) {
+		return
+	}
+	defer tx.done()
+	tx.Transaction.Rollback()
+}
+
+func (tx *trackedTransaction) Commit() error {
+	// context 已结束但回滚尚未执行时，同样回滚而不是提交
+	if tx.ctx.Err() != nil {
+		tx.cancel()
+	}
+	if !tx.finish(false) && tx.isCanceled() {
+		return fmt.Errorf("%w: %v", ErrTxCanceled, context.Cause(tx.ctx))
+	}
+	defer tx.done()
+	return tx.Transaction.Commit()
+}
+
+func (tx *trackedTransaction) Rollback() error {
+	if !tx.finish(false) && tx.isCanceled() {
+		return nil
+	}
+	defer tx.done()
+	return tx.Transaction.Rollback()
+}
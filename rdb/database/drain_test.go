@@ -0,0 +1,122 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hatlonely/gox/rdb/query"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestOperationTracker(t *testing.T) {
+	Convey("测试进行中操作的跟踪", t, func() {
+		tracker := newOperationTracker()
+		ctx := context.Background()
+
+		Convey("关闭后拒绝新操作，已登记的操作内的嵌套调用不受影响", func() {
+			opCtx, done, err := tracker.enter(ctx)
+			So(err, ShouldBeNil)
+
+			closed := make(chan int)
+			go func() { closed <- tracker.close(time.Second) }()
+			time.Sleep(20 * time.Millisecond)
+
+			_, _, err = tracker.enter(ctx)
+			So(err, ShouldEqual, ErrClosed)
+			_, nestedDone, err := tracker.enter(opCtx)
+			So(err, ShouldBeNil)
+			nestedDone()
+
+			select {
+			case <-closed:
+				t.Fatal("close returned before operation done")
+			default:
+			}
+			done()
+			done()
+			So(<-closed, ShouldEqual, 0)
+			So(tracker.close(time.Second), ShouldEqual, 0)
+		})
+
+		Convey("等待超时返回未完成的操作数", func() {
+			_, _, err := tracker.enter(ctx)
+			So(err, ShouldBeNil)
+			_, _, err = tracker.enter(ctx)
+			So(err, ShouldBeNil)
+			So(tracker.close(10*time.Millisecond), ShouldEqual, 2)
+		})
+
+		Convey("nil tracker 不做跟踪", func() {
+			var nilTracker *operationTracker
+			_, done, err := nilTracker.enter(ctx)
+			So(err, ShouldBeNil)
+			done()
+			So(nilTracker.close(0), ShouldEqual, 0)
+		})
+	})
+}
+
+func TestSQLGracefulClose(t *testing.T) {
+	Convey("测试 SQL 关闭时等待进行中的操作", t, func() {
+		ctx := context.Background()
+		model := &TableModel{
+			Table:      "test_drain_users",
+			Fields:     []FieldDefinition{{Name: "id", Type: FieldTypeInt}, {Name: "name", Type: FieldTypeString}},
+			PrimaryKey: []string{"id"},
+		}
+		newSQL := func(closeTimeout time.Duration) *SQL {
+			db, err := NewSQLWithOptions(&SQLOptions{Driver: "sqlite3", Database: ":memory:", MaxConns: 1, MaxIdle: 1, CloseTimeout: closeTimeout})
+			So(err, ShouldBeNil)
+			So(db.Migrate(ctx, model), ShouldBeNil)
+			return db
+		}
+
+		Convey("事务提交后完成关闭，之后的操作返回 ErrClosed", func() {
+			db := newSQL(time.Second)
+			tx, err := db.BeginTx(ctx)
+			So(err, ShouldBeNil)
+			So(tx.Create(ctx, "test_drain_users", db.GetBuilder().FromMap(map[string]any{"id": 1, "name": "alice"}, "test_drain_users")), ShouldBeNil)
+
+			closed := make(chan error)
+			go func() { closed <- db.Close() }()
+			time.Sleep(20 * time.Millisecond)
+
+			_, err = db.Get(ctx, "test_drain_users", map[string]any{"id": 1})
+			So(err, ShouldEqual, ErrClosed)
+			_, err = db.BeginTx(ctx)
+			So(err, ShouldEqual, ErrClosed)
+
+			So(tx.Commit(), ShouldBeNil)
+			So(<-closed, ShouldBeNil)
+			So(db.Create(ctx, "test_drain_users", db.GetBuilder().FromMap(map[string]any{"id": 2}, "test_drain_users")), ShouldEqual, ErrClosed)
+		})
+
+		Convey("游标关闭前算作进行中的操作", func() {
+			db := newSQL(time.Second)
+			So(db.Create(ctx, "test_drain_users", db.GetBuilder().FromMap(map[string]any{"id": 1, "name": "alice"}, "test_drain_users")), ShouldBeNil)
+			cursor, err := db.FindStream(ctx, "test_drain_users", &query.TermQuery{Field: "id", Value: 1})
+			So(err, ShouldBeNil)
+
+			closed := make(chan error)
+			go func() { closed <- db.Close() }()
+			time.Sleep(20 * time.Millisecond)
+
+			So(cursor.Next(), ShouldBeTrue)
+			So(cursor.Record().Fields()["name"], ShouldEqual, "alice")
+			So(cursor.Close(), ShouldBeNil)
+			So(<-closed, ShouldBeNil)
+		})
+
+		Convey("等待超时后关闭连接并返回被中断的操作数", func() {
+			db := newSQL(20 * time.Millisecond)
+			_, err := db.BeginTx(ctx)
+			So(err, ShouldBeNil)
+
+			err = db.Close()
+			So(errors.Is(err, ErrCloseTimeout), ShouldBeTrue)
+			So(err.Error(), ShouldContainSubstring, "1 in-flight operations aborted")
+		})
+	})
+}
@@ -0,0 +1,620 @@
+package database
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hatlonely/gox/log"
+	"github.com/hatlonely/gox/log/logger"
+	"github.com/hatlonely/gox/rdb/query"
+	"github.com/hatlonely/gox/ref"
+	"github.com/pkg/errors"
+)
+
+// 双写迁移的阶段，按顺序推进，出现问题时可以退回上一阶段
+const (
+	// DualWritePhaseOld 先写旧库再写新库，读旧库，用于新库追平数据
+	DualWritePhaseOld = "old"
+	// DualWritePhaseCompare 写入与 old 相同，读旧库并与新库的结果对比，不一致时上报
+	DualWritePhaseCompare = "compare"
+	// DualWritePhaseNew 先写新库再写旧库，读新库，旧库保持同步以便回退
+	DualWritePhaseNew = "new"
+	// DualWritePhaseNewOnly 只读写新库，迁移完成
+	DualWritePhaseNewOnly = "newOnly"
+)
+
+// DualWriteOptions 双写迁移配置
+type DualWriteOptions struct {
+	// Old 迁移前的数据库配置
+	Old *ref.TypeOptions `cfg:"old" validate:"required"`
+	// New 迁移后的数据库配置
+	New *ref.TypeOptions `cfg:"new" validate:"required"`
+	// Phase 初始阶段：old, compare, new, newOnly
+	Phase string `cfg:"phase" def:"old" validate:"omitempty,oneof=old compare new newOnly"`
+	// StrictSecondary 写入次库失败时是否返回错误，为 false 时只输出日志，此时主库的写入已经生效
+	StrictSecondary bool `cfg:"strictSecondary"`
+	// SecondaryTimeout 事务提交后写入次库的超时时间，写入不受事务上下文取消的影响
+	SecondaryTimeout time.Duration `cfg:"secondaryTimeout" def:"30s"`
+	// IgnoreFields 对比时忽略的字段，如各后端自动生成的 _id、更新时间
+	IgnoreFields []string `cfg:"ignoreFields"`
+	// Logger 输出次库写入失败和对比不一致的日志器，为空时使用默认日志器
+	Logger *ref.TypeOptions `cfg:"logger"`
+}
+
+// DualWriteMismatch 新旧库的一次不一致
+type DualWriteMismatch struct {
+	Table     string
+	Operation Operation
+	PK        map[string]any // Get、Exists、Verify
+	Query     query.Query    // Find、Count
+	Changes   []FieldChange  // 同一条记录不同的字段，Before 为旧库的值，After 为新库的值
+	Old       any            // 记录不存在、数量不同时旧库的结果
+	New       any            // 记录不存在、数量不同时新库的结果
+	Err       error          // 查询新库失败
+}
+
+// DualWriteStats 双写统计
+type DualWriteStats struct {
+	SecondaryWrites int64 // 次库写入次数
+	SecondaryErrors int64 // 次库写入失败次数
+	Compared        int64 // 对比次数
+	Mismatches      int64 // 不一致次数
+}
+
+// DualWriteVerifyResult 全量校验的结果
+type DualWriteVerifyResult struct {
+	OldCount   int64               // 旧库满足条件的记录数
+	NewCount   int64               // 新库满足条件的记录数
+	Checked    int64               // 已校验的旧库记录数
+	Missing    int64               // 新库中不存在的记录数
+	Mismatched int64               // 字段不一致的记录数
+	Samples    []DualWriteMismatch // 不一致的记录，最多 maxDualWriteSamples 条
+}
+
+// maxDualWriteSamples Verify 返回的不一致记录的最大条数
+const maxDualWriteSamples = 100
+
+// defaultDualWriteSecondaryTimeout 事务提交后写入次库的默认超时时间
+const defaultDualWriteSecondaryTimeout = 30 * time.Second
+
+// DualWriteDatabase 同时写入新旧两个数据库，用于在不停机的情况下迁移存储引擎
+//
+// 写操作先写主库，成功后写次库：old、compare 阶段旧库为主库，new 阶段新库为主库，newOnly 阶段只写新库。
+// 写入次库的记录由次库的 GetBuilder 重建，不要求两个后端的记录类型相同。
+// 读操作在 old、compare 阶段读旧库，compare 阶段同步读取新库对比 Get、Exists、Count、Find 的结果，
+// 不一致时调用 OnMismatch 设置的回调，默认输出告警日志，始终返回旧库的结果；new、newOnly 阶段读新库。
+// 事务在主库上执行，事务中的写操作在提交成功后依次写入次库，不保证两个库的原子性；事务中的读操作不对比
+type DualWriteDatabase struct {
+	*InterceptorDatabase
+
+	old              Database
+	new              Database
+	phase            atomic.Pointer[string]
+	strict           atomic.Bool
+	secondaryTimeout time.Duration
+	ignoreFields     map[string]bool
+	logger           logger.Logger
+	onMismatch       func(ctx context.Context, mismatch DualWriteMismatch)
+
+	secondaryWrites atomic.Int64
+	secondaryErrors atomic.Int64
+	compared        atomic.Int64
+	mismatches      atomic.Int64
+}
+
+// NewDualWriteDatabase 创建双写数据库，初始阶段为 old
+func NewDualWriteDatabase(oldDB, newDB Database) *DualWriteDatabase {
+	d := &DualWriteDatabase{old: oldDB, new: newDB, logger: log.Default(), secondaryTimeout: defaultDualWriteSecondaryTimeout}
+	d.InterceptorDatabase = NewInterceptorDatabase(oldDB, d.intercept)
+	phase := DualWritePhaseOld
+	d.phase.Store(&phase)
+	d.onMismatch = d.logMismatch
+	return d
+}
+
+// NewDualWriteDatabaseWithOptions 使用配置创建双写数据库
+func NewDualWriteDatabaseWithOptions(options *DualWriteOptions) (*DualWriteDatabase, error) {
+	if options == nil {
+		return nil, errors.New("options is nil")
+	}
+
+	l, err := log.NewLoggerWithOptions(options.Logger)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to create dual write logger")
+	}
+	oldDB, err := NewDatabaseWithOptions(options.Old)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to create old database")
+	}
+	newDB, err := NewDatabaseWithOptions(options.New)
+	if err != nil {
+		oldDB.Close()
+		return nil, errors.WithMessage(err, "failed to create new database")
+	}
+
+	d := NewDualWriteDatabase(oldDB, newDB)
+	d.logger = l
+	d.strict.Store(options.StrictSecondary)
+	if options.SecondaryTimeout > 0 {
+		d.secondaryTimeout = options.SecondaryTimeout
+	}
+	d.SetIgnoreFields(options.IgnoreFields...)
+	if options.Phase != "" {
+		if err := d.SetPhase(options.Phase); err != nil {
+			d.Close()
+			return nil, err
+		}
+	}
+	return d, nil
+}
+
+// SetPhase 切换迁移阶段，并发安全，切换后的操作按新的阶段执行
+func (d *DualWriteDatabase) SetPhase(phase string) error {
+	switch phase {
+	case DualWritePhaseOld, DualWritePhaseCompare, DualWritePhaseNew, DualWritePhaseNewOnly:
+	default:
+		return errors.Errorf("unsupported dual write phase: %s", phase)
+	}
+	d.phase.Store(&phase)
+	return nil
+}
+
+// Phase 返回当前的迁移阶段
+func (d *DualWriteDatabase) Phase() string {
+	return *d.phase.Load()
+}
+
+// SetStrictSecondary 设置写入次库失败时是否返回错误，并发安全
+func (d *DualWriteDatabase) SetStrictSecondary(strict bool) {
+	d.strict.Store(strict)
+}
+
+// SetIgnoreFields 设置对比时忽略的字段，需要在使用前调用，非并发安全
+func (d *DualWriteDatabase) SetIgnoreFields(fields ...string) {
+	d.ignoreFields = make(map[string]bool, len(fields))
+	for _, field := range fields {
+		d.ignoreFields[field] = true
+	}
+}
+
+// OnMismatch 设置不一致时的回调，替换默认的告警日志，需要在使用前调用，非并发安全
+func (d *DualWriteDatabase) OnMismatch(fn func(ctx context.Context, mismatch DualWriteMismatch)) {
+	d.onMismatch = fn
+}
+
+// Old 返回旧库
+func (d *DualWriteDatabase) Old() Database {
+	return d.old
+}
+
+// New 返回新库
+func (d *DualWriteDatabase) New() Database {
+	return d.new
+}
+
+// Stats 返回双写统计
+func (d *DualWriteDatabase) Stats() DualWriteStats {
+	return DualWriteStats{
+		SecondaryWrites: d.secondaryWrites.Load(),
+		SecondaryErrors: d.secondaryErrors.Load(),
+		Compared:        d.compared.Load(),
+		Mismatches:      d.mismatches.Load(),
+	}
+}
+
+// roles 返回当前阶段的主库和次库，newOnly 阶段没有次库
+func (d *DualWriteDatabase) roles(phase string) (primary, secondary Database) {
+	switch phase {
+	case DualWritePhaseNew:
+		return d.new, d.old
+	case DualWritePhaseNewOnly:
+		return d.new, nil
+	default:
+		return d.old, d.new
+	}
+}
+
+// intercept 按阶段分发操作，next 在旧库上执行
+func (d *DualWriteDatabase) intercept(ctx context.Context, op OperationInfo, next Handler) error {
+	phase := d.Phase()
+	primary, secondary := d.roles(phase)
+	execute := func(ctx context.Context, op OperationInfo) error {
+		if primary == d.old {
+			return next(ctx, op)
+		}
+		return applyOperation(ctx, primary, op)
+	}
+
+	switch {
+	case isWriteOperation(op.Operation):
+		if err := execute(ctx, op); err != nil || secondary == nil {
+			return err
+		}
+		return d.writeSecondary(ctx, secondary, op)
+	case op.Operation == OpHealth:
+		if err := execute(ctx, op); err != nil || secondary == nil {
+			return err
+		}
+		return errors.WithMessage(secondary.Health(ctx), "secondary database")
+	case phase == DualWritePhaseCompare:
+		err := execute(ctx, op)
+		if err == nil || op.Operation == OpGet && errors.Is(err, ErrRecordNotFound) {
+			d.compare(ctx, op)
+		}
+		return err
+	default:
+		return execute(ctx, op)
+	}
+}
+
+// writeSecondary 在次库上重放写操作，记录由次库的构建器重建
+func (d *DualWriteDatabase) writeSecondary(ctx context.Context, db Database, op OperationInfo) error {
+	if op.Record != nil {
+		op.Record = rebuildRecord(db, op.Table, op.Record)
+	}
+	if op.Records != nil {
+		records := make([]Record, len(op.Records))
+		for i, record := range op.Records {
+			records[i] = rebuildRecord(db, op.Table, record)
+		}
+		op.Records = records
+	}
+	op.result = new(any)
+
+	d.secondaryWrites.Add(1)
+	err := applyOperation(ctx, db, op)
+	if err == nil {
+		return nil
+	}
+	d.secondaryErrors.Add(1)
+	d.logger.ErrorContext(ctx, "failed to write secondary database",
+		"table", op.Table,
+		"operation", op.Operation,
+		"phase", d.Phase(),
+		"error", err,
+	)
+	if d.strict.Load() {
+		return errors.WithMessage(err, "failed to write secondary database")
+	}
+	return nil
+}
+
+// rebuildRecord 使用目标数据库的构建器重建记录，字段复制一份，避免后端修改原记录的字段
+func rebuildRecord(db Database, table string, record Record) Record {
+	fields := make(map[string]any, len(record.Fields()))
+	for k, v := range record.Fields() {
+		fields[k] = v
+	}
+	return db.GetBuilder().FromMap(fields, table)
+}
+
+// compare 在新库上执行相同的读操作并与旧库的结果对比，op 中为旧库的结果
+func (d *DualWriteDatabase) compare(ctx context.Context, op OperationInfo) {
+	mismatch := DualWriteMismatch{Table: op.Table, Operation: op.Operation, PK: op.PK, Query: op.Query}
+	switch op.Operation {
+	case OpGet:
+		record, err := d.new.Get(ctx, op.Table, op.PK, op.QueryOpts...)
+		if err != nil && !errors.Is(err, ErrRecordNotFound) {
+			mismatch.Err = err
+			break
+		}
+		mismatch.Changes, mismatch.Old, mismatch.New = d.diff(resultAs[Record](op), record)
+	case OpExists:
+		exists, err := d.new.Exists(ctx, op.Table, op.PK)
+		mismatch.Err = err
+		if old := resultAs[bool](op); err == nil && old != exists {
+			mismatch.Old, mismatch.New = old, exists
+		}
+	case OpCount:
+		count, err := d.new.Count(ctx, op.Table, op.Query)
+		mismatch.Err = err
+		if old := resultAs[int64](op); err == nil && old != count {
+			mismatch.Old, mismatch.New = old, count
+		}
+	case OpFind:
+		records, err := d.new.Find(ctx, op.Table, op.Query, op.QueryOpts...)
+		mismatch.Err = err
+		if err == nil {
+			mismatch.Old, mismatch.New = d.diffRecords(resultAs[[]Record](op), records)
+		}
+	default:
+		// FindStream、Aggregate 不对比
+		return
+	}
+
+	d.compared.Add(1)
+	if mismatch.Err == nil && mismatch.Changes == nil && mismatch.Old == nil && mismatch.New == nil {
+		return
+	}
+	d.mismatches.Add(1)
+	if d.onMismatch != nil {
+		d.onMismatch(ctx, mismatch)
+	}
+}
+
+// diff 对比同一条记录，只在一边存在时通过 old、new 返回存在的记录的字段
+func (d *DualWriteDatabase) diff(oldRecord, newRecord Record) (changes []FieldChange, old, new any) {
+	switch {
+	case oldRecord == nil && newRecord == nil:
+		return nil, nil, nil
+	case oldRecord == nil:
+		return nil, nil, newRecord.Fields()
+	case newRecord == nil:
+		return nil, oldRecord.Fields(), nil
+	}
+	for _, change := range DiffRecords(oldRecord, newRecord) {
+		if !d.ignoreFields[change.Field] {
+			changes = append(changes, change)
+		}
+	}
+	return changes, nil, nil
+}
+
+// diffRecords 不考虑顺序对比两组记录，返回各自没有匹配上的记录的字段
+func (d *DualWriteDatabase) diffRecords(oldRecords, newRecords []Record) (old, new any) {
+	matched := make([]bool, len(newRecords))
+	var oldOnly []map[string]any
+	for _, oldRecord := range oldRecords {
+		found := false
+		for i, newRecord := range newRecords {
+			if matched[i] {
+				continue
+			}
+			if changes, _, _ := d.diff(oldRecord, newRecord); len(changes) == 0 {
+				matched[i], found = true, true
+				break
+			}
+		}
+		if !found {
+			oldOnly = append(oldOnly, oldRecord.Fields())
+		}
+	}
+	var newOnly []map[string]any
+	for i, newRecord := range newRecords {
+		if !matched[i] {
+			newOnly = append(newOnly, newRecord.Fields())
+		}
+	}
+	if oldOnly == nil && newOnly == nil {
+		return nil, nil
+	}
+	return oldOnly, newOnly
+}
+
+// logMismatch 默认的不一致回调，输出告警日志
+func (d *DualWriteDatabase) logMismatch(ctx context.Context, mismatch DualWriteMismatch) {
+	args := []any{"table", mismatch.Table, "operation", mismatch.Operation}
+	if mismatch.PK != nil {
+		args = append(args, "pk", mismatch.PK)
+	}
+	if mismatch.Err != nil {
+		args = append(args, "error", mismatch.Err)
+	}
+	if mismatch.Changes != nil {
+		args = append(args, "changes", mismatch.Changes)
+	}
+	if mismatch.Old != nil || mismatch.New != nil {
+		args = append(args, "old", mismatch.Old, "new", mismatch.New)
+	}
+	d.logger.WarnContext(ctx, "dual write mismatch", args...)
+}
+
+// Verify 全量校验，遍历旧库中满足条件的记录，按 pkFields 指定的主键字段在新库中读取并对比
+// 用于切换到 new 阶段之前确认新库已经追平，不一致的记录通过返回值给出，不调用 OnMismatch 的回调
+func (d *DualWriteDatabase) Verify(ctx context.Context, table string, q query.Query, pkFields []string) (*DualWriteVerifyResult, error) {
+	if len(pkFields) == 0 {
+		return nil, errors.New("pk fields are required")
+	}
+
+	result := &DualWriteVerifyResult{}
+	var err error
+	if result.OldCount, err = d.old.Count(ctx, table, q); err != nil {
+		return nil, errors.WithMessage(err, "failed to count old database")
+	}
+	if result.NewCount, err = d.new.Count(ctx, table, q); err != nil {
+		return nil, errors.WithMessage(err, "failed to count new database")
+	}
+
+	cursor, err := d.old.FindStream(ctx, table, q)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to scan old database")
+	}
+	defer cursor.Close()
+
+	for cursor.Next() {
+		oldRecord := cursor.Record()
+		fields := oldRecord.Fields()
+		pk := make(map[string]any, len(pkFields))
+		for _, field := range pkFields {
+			pk[field] = fields[field]
+		}
+
+		result.Checked++
+		mismatch := DualWriteMismatch{Table: table, Operation: OpGet, PK: pk}
+		newRecord, err := d.new.Get(ctx, table, pk)
+		switch {
+		case errors.Is(err, ErrRecordNotFound):
+			result.Missing++
+			mismatch.Old = fields
+		case err != nil:
+			return nil, errors.WithMessage(err, "failed to read new database")
+		default:
+			if mismatch.Changes, _, _ = d.diff(oldRecord, newRecord); mismatch.Changes == nil {
+				continue
+			}
+			result.Mismatched++
+		}
+		if len(result.Samples) < maxDualWriteSamples {
+			result.Samples = append(result.Samples, mismatch)
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, errors.WithMessage(err, "failed to scan old database")
+	}
+	return result, nil
+}
+
+// Unwrap 返回当前阶段的主库
+func (d *DualWriteDatabase) Unwrap() Database {
+	primary, _ := d.roles(d.Phase())
+	return primary
+}
+
+// GetBuilder 返回当前读取的数据库的记录构建器
+func (d *DualWriteDatabase) GetBuilder() RecordBuilder {
+	primary, _ := d.roles(d.Phase())
+	return primary.GetBuilder()
+}
+
+// BeginTx 在主库上开始事务，提交成功后将事务中的写操作依次写入次库
+func (d *DualWriteDatabase) BeginTx(ctx context.Context) (Transaction, error) {
+	primary, secondary := d.roles(d.Phase())
+	tx, err := primary.BeginTx(ctx)
+	if err != nil || secondary == nil {
+		return tx, err
+	}
+
+	dtx := &dualWriteTransaction{ctx: ctx, tx: tx, db: d, secondary: secondary}
+	dtx.InterceptorDatabase = NewInterceptorDatabase(tx, dtx.record)
+	return dtx, nil
+}
+
+func (d *DualWriteDatabase) WithTx(ctx context.Context, fn func(tx Transaction) error) error {
+	tx, err := d.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			panic(r)
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Close 关闭新旧两个数据库
+func (d *DualWriteDatabase) Close() error {
+	oldErr := d.old.Close()
+	newErr := d.new.Close()
+	if oldErr != nil {
+		return errors.WithMessage(oldErr, "failed to close old database")
+	}
+	return errors.WithMessage(newErr, "failed to close new database")
+}
+
+// dualWriteTransaction 记录事务中成功的写操作，提交后写入次库
+type dualWriteTransaction struct {
+	*InterceptorDatabase
+
+	ctx       context.Context
+	tx        Transaction
+	db        *DualWriteDatabase
+	secondary Database
+
+	mu  sync.Mutex
+	ops []OperationInfo
+}
+
+func (tx *dualWriteTransaction) record(ctx context.Context, op OperationInfo, next Handler) error {
+	if err := next(ctx, op); err != nil || !isWriteOperation(op.Operation) {
+		return err
+	}
+	tx.mu.Lock()
+	tx.ops = append(tx.ops, op)
+	tx.mu.Unlock()
+	return nil
+}
+
+// Commit 提交主库的事务，成功后依次写入次库，StrictSecondary 时返回第一个写入次库的错误
+// 主库已经提交，写入次库使用脱离事务上下文取消的新上下文，超时时间为 SecondaryTimeout
+func (tx *dualWriteTransaction) Commit() error {
+	if err := tx.tx.Commit(); err != nil {
+		return err
+	}
+
+	tx.mu.Lock()
+	ops := tx.ops
+	tx.ops = nil
+	tx.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(tx.ctx), tx.db.secondaryTimeout)
+	defer cancel()
+	for _, op := range ops {
+		if err := tx.db.writeSecondary(ctx, tx.secondary, op); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (tx *dualWriteTransaction) Rollback() error {
+	tx.mu.Lock()
+	tx.ops = nil
+	tx.mu.Unlock()
+	return tx.tx.Rollback()
+}
+
+// applyOperation 在指定的数据库上执行操作并设置返回值
+func applyOperation(ctx context.Context, db Database, op OperationInfo) error {
+	switch op.Operation {
+	case OpMigrate:
+		return db.Migrate(ctx, op.Model)
+	case OpDropTable:
+		return db.DropTable(ctx, op.Table)
+	case OpCreate:
+		return db.Create(ctx, op.Table, op.Record, op.CreateOpts...)
+	case OpGet:
+		record, err := db.Get(ctx, op.Table, op.PK, op.QueryOpts...)
+		op.SetResult(record)
+		return err
+	case OpUpdate:
+		return db.Update(ctx, op.Table, op.PK, op.Record, op.UpdateOpts...)
+	case OpUpdatePartial:
+		return db.UpdatePartial(ctx, op.Table, op.PK, op.Fields, op.UpdateOpts...)
+	case OpUpsert:
+		return db.Upsert(ctx, op.Table, op.PK, op.Record)
+	case OpIncrement:
+		return db.Increment(ctx, op.Table, op.PK, op.Field, op.Delta)
+	case OpDelete:
+		return db.Delete(ctx, op.Table, op.PK)
+	case OpFind:
+		records, err := db.Find(ctx, op.Table, op.Query, op.QueryOpts...)
+		op.SetResult(records)
+		return err
+	case OpFindStream:
+		cursor, err := db.FindStream(ctx, op.Table, op.Query, op.QueryOpts...)
+		op.SetResult(cursor)
+		return err
+	case OpCount:
+		count, err := db.Count(ctx, op.Table, op.Query)
+		op.SetResult(count)
+		return err
+	case OpExists:
+		exists, err := db.Exists(ctx, op.Table, op.PK)
+		op.SetResult(exists)
+		return err
+	case OpAggregate:
+		result, err := db.Aggregate(ctx, op.Table, op.Query, op.Aggs, op.QueryOpts...)
+		op.SetResult(result)
+		return err
+	case OpBatchCreate:
+		return db.BatchCreate(ctx, op.Table, op.Records, op.CreateOpts...)
+	case OpBatchUpdate:
+		return db.BatchUpdate(ctx, op.Table, op.PKs, op.Records)
+	case OpBatchDelete:
+		return db.BatchDelete(ctx, op.Table, op.PKs)
+	case OpHealth:
+		return db.Health(ctx)
+	}
+	return errors.Errorf("unsupported operation: %s", op.Operation)
+}
@@ -0,0 +1,264 @@
+package database
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/hatlonely/gox/rdb/query"
+	"github.com/hatlonely/gox/ref"
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func newDualWriteTestDB(ctx context.Context) *SQL {
+	sql, err := NewSQLWithOptions(&SQLOptions{Driver: "sqlite3", Database: ":memory:", MaxConns: 1, MaxIdle: 1})
+	So(err, ShouldBeNil)
+	So(sql.Migrate(ctx, &TableModel{
+		Table: "test_dual_write",
+		Fields: []FieldDefinition{
+			{Name: "id", Type: FieldTypeInt, Required: true},
+			{Name: "name", Type: FieldTypeString, Size: 100},
+			{Name: "score", Type: FieldTypeInt},
+		},
+		PrimaryKey: []string{"id"},
+	}), ShouldBeNil)
+	return sql
+}
+
+// cancelOnCommitDatabase 事务提交成功后取消上下文，模拟请求在主库提交后结束
+type cancelOnCommitDatabase struct {
+	Database
+	cancel context.CancelFunc
+}
+
+func (d *cancelOnCommitDatabase) BeginTx(ctx context.Context) (Transaction, error) {
+	tx, err := d.Database.BeginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &cancelOnCommitTransaction{Transaction: tx, cancel: d.cancel}, nil
+}
+
+type cancelOnCommitTransaction struct {
+	Transaction
+	cancel context.CancelFunc
+}
+
+func (tx *cancelOnCommitTransaction) Commit() error {
+	defer tx.cancel()
+	return tx.Transaction.Commit()
+}
+
+func TestDualWriteDatabase(t *testing.T) {
+	Convey("测试双写迁移", t, func() {
+		ctx := context.Background()
+		oldDB := newDualWriteTestDB(ctx)
+		newDB := newDualWriteTestDB(ctx)
+
+		db := NewDualWriteDatabase(oldDB, newDB)
+		defer db.Close()
+
+		var mismatches []DualWriteMismatch
+		db.OnMismatch(func(ctx context.Context, mismatch DualWriteMismatch) {
+			mismatches = append(mismatches, mismatch)
+		})
+
+		table := "test_dual_write"
+		create := func(db Database, id int, name string) error {
+			return db.Create(ctx, table, db.GetBuilder().FromMap(map[string]any{"id": id, "name": name, "score": id * 10}, table))
+		}
+		name := func(db Database, id int) any {
+			record, err := db.Get(ctx, table, map[string]any{"id": id})
+			if err != nil {
+				return err
+			}
+			return record.Fields()["name"]
+		}
+
+		Convey("old 阶段同时写入新旧库，读旧库", func() {
+			So(db.Phase(), ShouldEqual, DualWritePhaseOld)
+			So(create(db, 1, "alice"), ShouldBeNil)
+			So(db.UpdatePartial(ctx, table, map[string]any{"id": 1}, map[string]any{"name": "bob"}), ShouldBeNil)
+			So(db.Increment(ctx, table, map[string]any{"id": 1}, "score", 5), ShouldBeNil)
+
+			So(name(oldDB, 1), ShouldEqual, "bob")
+			So(name(newDB, 1), ShouldEqual, "bob")
+			record, err := newDB.Get(ctx, table, map[string]any{"id": 1})
+			So(err, ShouldBeNil)
+			So(record.Fields()["score"], ShouldEqual, 15)
+
+			So(db.Delete(ctx, table, map[string]any{"id": 1}), ShouldBeNil)
+			So(errors.Is(name(newDB, 1).(error), ErrRecordNotFound), ShouldBeTrue)
+			So(db.Stats().SecondaryWrites, ShouldEqual, 4)
+		})
+
+		Convey("次库写入失败时默认只记录，StrictSecondary 时返回错误", func() {
+			So(create(newDB, 1, "alice"), ShouldBeNil)
+			So(create(newDB, 2, "bob"), ShouldBeNil)
+
+			// 新库中已存在该记录，写入次库失败
+			So(create(db, 1, "alice"), ShouldBeNil)
+			So(name(oldDB, 1), ShouldEqual, "alice")
+			So(db.Stats().SecondaryErrors, ShouldEqual, 1)
+
+			db.SetStrictSecondary(true)
+			So(create(db, 2, "bob"), ShouldNotBeNil)
+			So(name(oldDB, 2), ShouldEqual, "bob")
+			So(db.Stats().SecondaryErrors, ShouldEqual, 2)
+		})
+
+		Convey("compare 阶段对比读结果并上报不一致", func() {
+			So(create(db, 1, "alice"), ShouldBeNil)
+			So(create(db, 2, "bob"), ShouldBeNil)
+			So(create(oldDB, 3, "carol"), ShouldBeNil)
+			So(newDB.UpdatePartial(ctx, table, map[string]any{"id": 2}, map[string]any{"name": "bobby"}), ShouldBeNil)
+			So(db.SetPhase(DualWritePhaseCompare), ShouldBeNil)
+
+			So(name(db, 1), ShouldEqual, "alice")
+			So(mismatches, ShouldBeEmpty)
+
+			So(name(db, 2), ShouldEqual, "bob")
+			So(mismatches, ShouldHaveLength, 1)
+			So(mismatches[0].Operation, ShouldEqual, OpGet)
+			So(mismatches[0].Changes, ShouldResemble, []FieldChange{{Field: "name", Before: "bob", After: "bobby"}})
+
+			// 始终返回旧库的结果
+			So(name(db, 3), ShouldEqual, "carol")
+			So(mismatches, ShouldHaveLength, 2)
+			So(mismatches[1].Old, ShouldNotBeNil)
+			So(mismatches[1].New, ShouldBeNil)
+
+			count, err := db.Count(ctx, table, &query.ExistsQuery{Field: "id"})
+			So(err, ShouldBeNil)
+			So(count, ShouldEqual, 3)
+			So(mismatches, ShouldHaveLength, 3)
+			So(mismatches[2].Old, ShouldEqual, 3)
+			So(mismatches[2].New, ShouldEqual, 2)
+
+			// 忽略字段后只有 id=3 不一致，且与顺序无关
+			db.SetIgnoreFields("name")
+			records, err := db.Find(ctx, table, &query.ExistsQuery{Field: "id"})
+			So(err, ShouldBeNil)
+			So(records, ShouldHaveLength, 3)
+			So(mismatches, ShouldHaveLength, 4)
+			So(mismatches[3].Old, ShouldHaveLength, 1)
+			So(mismatches[3].New, ShouldBeNil)
+
+			So(db.Stats().Compared, ShouldEqual, 5)
+			So(db.Stats().Mismatches, ShouldEqual, 4)
+		})
+
+		Convey("new 阶段先写新库，读新库，newOnly 阶段只读写新库", func() {
+			So(db.SetPhase(DualWritePhaseNew), ShouldBeNil)
+			So(create(db, 1, "alice"), ShouldBeNil)
+			So(name(oldDB, 1), ShouldEqual, "alice")
+			So(create(newDB, 2, "bob"), ShouldBeNil)
+			So(name(db, 2), ShouldEqual, "bob")
+			So(db.Unwrap(), ShouldEqual, newDB)
+
+			So(db.SetPhase(DualWritePhaseNewOnly), ShouldBeNil)
+			So(create(db, 3, "carol"), ShouldBeNil)
+			So(name(newDB, 3), ShouldEqual, "carol")
+			So(errors.Is(name(oldDB, 3).(error), ErrRecordNotFound), ShouldBeTrue)
+
+			So(db.SetPhase("unknown"), ShouldNotBeNil)
+			So(db.Phase(), ShouldEqual, DualWritePhaseNewOnly)
+		})
+
+		Convey("事务提交后写入次库，回滚时不写入", func() {
+			So(db.WithTx(ctx, func(tx Transaction) error {
+				if err := create(tx, 1, "alice"); err != nil {
+					return err
+				}
+				return tx.UpdatePartial(ctx, table, map[string]any{"id": 1}, map[string]any{"name": "bob"})
+			}), ShouldBeNil)
+			So(name(newDB, 1), ShouldEqual, "bob")
+
+			err := db.WithTx(ctx, func(tx Transaction) error {
+				if err := create(tx, 2, "carol"); err != nil {
+					return err
+				}
+				return errors.New("abort")
+			})
+			So(err, ShouldNotBeNil)
+			So(errors.Is(name(oldDB, 2).(error), ErrRecordNotFound), ShouldBeTrue)
+			So(errors.Is(name(newDB, 2).(error), ErrRecordNotFound), ShouldBeTrue)
+		})
+
+		Convey("主库提交后事务上下文取消时仍写入次库", func() {
+			txCtx, cancel := context.WithCancel(ctx)
+			db := NewDualWriteDatabase(&cancelOnCommitDatabase{Database: oldDB, cancel: cancel}, newDB)
+			So(db.WithTx(txCtx, func(tx Transaction) error {
+				return create(tx, 1, "alice")
+			}), ShouldBeNil)
+			So(txCtx.Err(), ShouldNotBeNil)
+			So(name(newDB, 1), ShouldEqual, "alice")
+			So(db.Stats().SecondaryErrors, ShouldEqual, 0)
+		})
+
+		Convey("并发切换 StrictSecondary", func() {
+			var wg sync.WaitGroup
+			for i := 1; i <= 10; i++ {
+				wg.Add(2)
+				go func(strict bool) {
+					defer wg.Done()
+					db.SetStrictSecondary(strict)
+				}(i%2 == 0)
+				go func(id int) {
+					defer wg.Done()
+					create(db, id, "alice")
+				}(i)
+			}
+			wg.Wait()
+			So(db.Stats().SecondaryWrites, ShouldEqual, 10)
+		})
+
+		Convey("全量校验", func() {
+			for i := 1; i <= 5; i++ {
+				So(create(oldDB, i, "user"), ShouldBeNil)
+			}
+			for i := 1; i <= 4; i++ {
+				So(create(newDB, i, "user"), ShouldBeNil)
+			}
+			So(newDB.UpdatePartial(ctx, table, map[string]any{"id": 2}, map[string]any{"score": 0}), ShouldBeNil)
+
+			result, err := db.Verify(ctx, table, &query.ExistsQuery{Field: "id"}, []string{"id"})
+			So(err, ShouldBeNil)
+			So(result.OldCount, ShouldEqual, 5)
+			So(result.NewCount, ShouldEqual, 4)
+			So(result.Checked, ShouldEqual, 5)
+			So(result.Missing, ShouldEqual, 1)
+			So(result.Mismatched, ShouldEqual, 1)
+			So(result.Samples, ShouldHaveLength, 2)
+			So(result.Samples[0].Changes, ShouldResemble, []FieldChange{{Field: "score", Before: int64(20), After: int64(0)}})
+
+			_, err = db.Verify(ctx, table, &query.ExistsQuery{Field: "id"}, nil)
+			So(err, ShouldNotBeNil)
+		})
+	})
+
+	Convey("测试使用配置创建双写数据库", t, func() {
+		sqlite := &ref.TypeOptions{
+			Namespace: "github.com/hatlonely/gox/rdb/database",
+			Type:      "SQL",
+			Options:   &SQLOptions{Driver: "sqlite3", Database: ":memory:", MaxConns: 1, MaxIdle: 1},
+		}
+		db, err := NewDatabaseWithOptions(&ref.TypeOptions{
+			Namespace: "github.com/hatlonely/gox/rdb/database",
+			Type:      "DualWriteDatabase",
+			Options: &DualWriteOptions{
+				Old:   sqlite,
+				New:   sqlite,
+				Phase: DualWritePhaseCompare,
+			},
+		})
+		So(err, ShouldBeNil)
+		defer db.Close()
+		So(db.(*DualWriteDatabase).Phase(), ShouldEqual, DualWritePhaseCompare)
+		So(db.Health(context.Background()), ShouldBeNil)
+
+		_, err = NewDualWriteDatabaseWithOptions(&DualWriteOptions{Old: sqlite, New: sqlite, Phase: "unknown"})
+		So(err, ShouldNotBeNil)
+	})
+}
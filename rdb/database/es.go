@@ -4,17 +4,37 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
 	"reflect"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/elastic/go-elasticsearch/v8"
 	"github.com/elastic/go-elasticsearch/v8/esapi"
+	"github.com/hatlonely/gox/cfg/commonopt"
 	"github.com/hatlonely/gox/rdb/aggregation"
 	"github.com/hatlonely/gox/rdb/query"
 )
 
+// ESResponseError Elasticsearch 返回错误状态码，可通过 errors.As 获取状态码判断错误类型
+type ESResponseError struct {
+	StatusCode int
+	message    string
+	response   string
+}
+
+func newESResponseError(message string, res *esapi.Response) *ESResponseError {
+	return &ESResponseError{StatusCode: res.StatusCode, message: message, response: res.String()}
+}
+
+func (e *ESResponseError) Error() string {
+	return fmt.Sprintf("%s: %s", e.message, e.response)
+}
+
 // ESOptions Elasticsearch连接选项
 type ESOptions struct {
 	Addresses []string      `cfg:"addresses" def:"[\"http://localhost:9200\"]"`
@@ -23,48 +43,189 @@ type ESOptions struct {
 	APIKey    string        `cfg:"apiKey"`
 	Timeout   time.Duration `cfg:"timeout" def:"30s"`
 	MaxRetries int          `cfg:"maxRetries" def:"3"`
+
+	// TLS 连接 HTTPS 地址时的 TLS 配置，为空时使用系统根证书
+	TLS *commonopt.TLSOptions `cfg:"tls"`
+
+	// MaxConnsPerHost 每个节点的最大连接数，达到上限时请求等待其他请求归还连接，为 0 时不限制
+	MaxConnsPerHost int `cfg:"maxConnsPerHost"`
+	// MaxIdleConnsPerHost 每个节点保留的最大空闲连接数
+	MaxIdleConnsPerHost int `cfg:"maxIdleConnsPerHost" def:"10"`
+	// MaxIdleTime 连接空闲超过该时间后关闭，为 0 时不关闭
+	MaxIdleTime time.Duration `cfg:"maxIdleTime" def:"90s"`
+	// HeartbeatInterval 连接的 TCP keep-alive 探测间隔，为 0 时使用默认的 15s，为负数时关闭
+	HeartbeatInterval time.Duration `cfg:"heartbeatInterval"`
+	// Compression 是否以 gzip 压缩请求体，响应默认协商 gzip 压缩
+	Compression bool `cfg:"compression"`
+
+	// Retry 启动时连接失败的重试策略
+	Retry RetryOptions `cfg:"retry"`
+	// HealthCheckInterval 定期 Ping 的间隔，为 0 时不做定期检查
+	// 检查失败时会重建客户端和底层连接池，从客户端持续不可用的状态中自动恢复
+	HealthCheckInterval time.Duration `cfg:"healthCheckInterval"`
+
+	// RetryBackoff 请求返回 429 或 5xx、批量操作中有文档被 429 拒绝时首次重试前的等待时间，之后每次翻倍，重试次数为 MaxRetries
+	RetryBackoff time.Duration `cfg:"retryBackoff" def:"100ms"`
+	// Refresh 写操作的刷新策略，wait_for 等待刷新后返回（默认），true 立即刷新，false 不等待刷新
+	Refresh string `cfg:"refresh" def:"wait_for"`
+	// BulkMaxActions 单个批量请求的最大操作数，超过时拆分为多个请求，为 0 时不限制
+	BulkMaxActions int `cfg:"bulkMaxActions" def:"1000"`
+	// BulkMaxBytes 单个批量请求体的最大字节数，超过时拆分为多个请求，为 0 时不限制
+	BulkMaxBytes int `cfg:"bulkMaxBytes" def:"5242880"`
+	// IndexAlias 为 true 时 Migrate 创建 <table>_v1 索引并以表名作为别名，便于之后重建索引后切换别名
+	IndexAlias bool `cfg:"indexAlias"`
+	// TimeSeries 按时间分区的表，键为表名，表名作为写别名指向当前周期的索引，见 ESTimeSeriesOptions
+	TimeSeries map[string]*ESTimeSeriesOptions `cfg:"timeSeries"`
+	// RolloverInterval 后台检查按时间分区的表是否需要滚动的间隔，为 0 时不在后台检查，需要调用 Rollover
+	RolloverInterval time.Duration `cfg:"rolloverInterval"`
+	// TagName 结构体字段与文档字段对应使用的标签，如 json，用于复用已有结构体的标签
+	TagName string `cfg:"tagName" def:"rdb"`
+	// RetryOnConflict 非乐观锁的更新遇到并发修改导致的版本冲突时由 ES 重试的次数，为 0 时直接返回 ErrVersionConflict
+	RetryOnConflict int `cfg:"retryOnConflict"`
+	// AllowMigrations 是否允许 Migrate 和 MigrateDiff 创建索引和修改映射，为空时允许
+	// 生产环境配置为 false，映射变更只能通过 ApplyMigrations 执行，开发和测试环境保持为空以自动创建
+	AllowMigrations *bool `cfg:"allowMigrations"`
+
+	// CloseTimeout Close 等待进行中的操作和事务完成的最长时间，超时后直接关闭连接，为 0 时不等待
+	CloseTimeout time.Duration `cfg:"closeTimeout" def:"30s"`
+	// TxLeak 事务泄漏检测，开启后超过阈值仍未提交或回滚的事务输出告警日志和开启事务的调用栈
+	TxLeak *TxLeakOptions `cfg:"txLeak"`
+
+	// Metrics 连接池指标，配置后在 prometheus.DefaultRegisterer 中注册 rdb_pool_* 指标，Close 时注销
+	Metrics *MetricsOptions `cfg:"metrics"`
 }
 
 // ES Elasticsearch数据库实现
 type ES struct {
-	client  *elasticsearch.Client
-	builder *ESRecordBuilder
+	mu        sync.RWMutex
+	client    *elasticsearch.Client
+	transport *http.Transport
+	builder   *ESRecordBuilder
+	options   ESOptions
+	checker   *healthChecker
+	ops       *operationTracker
+	txLeaks   *txLeakDetector
+
+	pool       *esPoolMonitor
+	unregister func()
+
+	timeSeriesTables map[string]*esTimeSeries
+	rolloverChecker  *healthChecker
 }
 
 // NewESWithOptions 创建Elasticsearch实例
 func NewESWithOptions(opts *ESOptions) (*ES, error) {
+	txLeaks, err := newTxLeakDetector(opts.TxLeak)
+	if err != nil {
+		return nil, err
+	}
+	timeSeriesTables, err := newESTimeSeriesMap(opts.TimeSeries)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := &esPoolMonitor{}
+	var client *elasticsearch.Client
+	var transport *http.Transport
+	err = retryConnect(opts.Retry, func() error {
+		var err error
+		client, transport, err = connectES(opts, pool)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	es := &ES{
+		client:    client,
+		transport: transport,
+		builder:   NewESRecordBuilder(opts.TagName),
+		options:   *opts,
+		ops:       newOperationTracker(),
+		txLeaks:   txLeaks,
+		pool:      pool,
+
+		timeSeriesTables: timeSeriesTables,
+	}
+	es.unregister, err = registerPoolMetrics(opts.Metrics, es)
+	if err != nil {
+		transport.CloseIdleConnections()
+		return nil, err
+	}
+	es.checker = startHealthChecker(opts.HealthCheckInterval, es.Health, es.reconnect)
+	if len(timeSeriesTables) > 0 {
+		es.rolloverChecker = startHealthChecker(opts.RolloverInterval, es.rolloverAll, nil)
+	}
+
+	return es, nil
+}
+
+// connectES 创建客户端并测试连接，每次都使用新的 Transport，不复用旧的连接池，连接统计累计到 pool
+func connectES(opts *ESOptions, pool *esPoolMonitor) (*elasticsearch.Client, *http.Transport, error) {
+	tlsConfig, err := commonopt.NewTLSConfigWithOptions(opts.TLS)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	transport := &http.Transport{
+		MaxConnsPerHost:       opts.MaxConnsPerHost,
+		MaxIdleConnsPerHost:   opts.MaxIdleConnsPerHost,
+		IdleConnTimeout:       opts.MaxIdleTime,
+		ResponseHeaderTimeout: opts.Timeout,
+		TLSClientConfig:       tlsConfig,
+	}
 	cfg := elasticsearch.Config{
-		Addresses: opts.Addresses,
-		Username:  opts.Username,
-		Password:  opts.Password,
-		APIKey:    opts.APIKey,
-		Transport: &http.Transport{
-			MaxIdleConnsPerHost:   10,
-			ResponseHeaderTimeout: opts.Timeout,
-		},
+		Addresses:  opts.Addresses,
+		Username:   opts.Username,
+		Password:   opts.Password,
+		APIKey:     opts.APIKey,
+		Transport:  pool.wrap(transport, &net.Dialer{KeepAlive: opts.HeartbeatInterval}),
 		MaxRetries: opts.MaxRetries,
+		// 默认只重试 502、503、504，集群繁忙时返回的 429 同样需要退避重试
+		RetryOnStatus:       []int{http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout},
+		RetryBackoff:        esRetryBackoff(opts.RetryBackoff),
+		CompressRequestBody: opts.Compression,
 	}
 
 	client, err := elasticsearch.NewClient(cfg)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create elasticsearch client: %v", err)
+		return nil, nil, fmt.Errorf("failed to create elasticsearch client: %v", err)
 	}
 
 	// 测试连接
 	res, err := client.Info()
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to elasticsearch: %v", err)
+		transport.CloseIdleConnections()
+		return nil, nil, fmt.Errorf("failed to connect to elasticsearch: %v", err)
 	}
 	defer res.Body.Close()
 
 	if res.IsError() {
-		return nil, fmt.Errorf("elasticsearch connection error: %s", res.String())
+		return nil, nil, newESResponseError("elasticsearch connection error", res)
 	}
 
-	return &ES{
-		client:  client,
-		builder: &ESRecordBuilder{},
-	}, nil
+	return client, transport, nil
+}
+
+// reconnect 重建客户端，新客户端连接成功后才替换旧客户端
+func (es *ES) reconnect(ctx context.Context) {
+	client, transport, err := connectES(&es.options, es.pool)
+	if err != nil {
+		return
+	}
+
+	es.mu.Lock()
+	old := es.transport
+	es.client, es.transport = client, transport
+	es.mu.Unlock()
+
+	old.CloseIdleConnections()
+}
+
+func (es *ES) getClient() *elasticsearch.Client {
+	es.mu.RLock()
+	defer es.mu.RUnlock()
+	return es.client
 }
 
 // ESRecord Elasticsearch记录实现
@@ -73,10 +234,25 @@ type ESRecord struct {
 	id     string
 	index  string
 	source map[string]any
+
+	// 文档的序列号和主分片任期，仅 Get 返回的记录有值，用于条件更新
+	seqNo       int
+	primaryTerm int
+
+	tagName string
+	score   *float64
+}
+
+// Score 返回相关度评分，实现 Scorer 接口
+func (r *ESRecord) Score() (float64, bool) {
+	if r.score == nil {
+		return 0, false
+	}
+	return *r.score, true
 }
 
 func (r *ESRecord) Scan(dest any) error {
-	return esMapToStruct(r.source, dest)
+	return esMapToStruct(r.source, dest, r.tagName)
 }
 
 func (r *ESRecord) ScanStruct(dest any) error {
@@ -90,16 +266,43 @@ func (r *ESRecord) Fields() map[string]any {
 	return r.data
 }
 
-// ESRecordBuilder Elasticsearch记录构建器
-type ESRecordBuilder struct{}
+func (r *ESRecord) Diff(other Record) map[string]any {
+	return diffFields(r.Fields(), other)
+}
+
+// withFields 保留文档 ID 和版本，条件更新仍然可用
+func (r *ESRecord) withFields(fields map[string]any) Record {
+	record := *r
+	record.source = fields
+	if r.data != nil {
+		record.data = fields
+	}
+	return &record
+}
+
+// ESRecordBuilder Elasticsearch记录构建器，零值使用 rdb 标签
+type ESRecordBuilder struct {
+	tagName string
+}
+
+// NewESRecordBuilder 创建使用 tagName 标签对应结构体字段和文档字段的构建器，如 json，为空时使用 rdb
+// 构建的记录以及查询返回的记录 Scan 时使用同一个标签
+func NewESRecordBuilder(tagName string) *ESRecordBuilder {
+	return &ESRecordBuilder{tagName: tagName}
+}
+
+// TagName 实现 TagNamer 接口
+func (b *ESRecordBuilder) TagName() string {
+	return tagNameOrDefault(b.tagName)
+}
 
 func (b *ESRecordBuilder) FromStruct(v any) Record {
-	data := esStructToMap(v)
-	return &ESRecord{data: data, source: data}
+	data := esStructToMap(v, b.tagName)
+	return &ESRecord{data: data, source: data, tagName: b.tagName}
 }
 
 func (b *ESRecordBuilder) FromMap(data map[string]any, table string) Record {
-	return &ESRecord{data: data, source: data, index: table}
+	return &ESRecord{data: data, source: data, index: table, tagName: b.tagName}
 }
 
 // 实现Database接口的基础方法
@@ -107,13 +310,60 @@ func (es *ES) GetBuilder() RecordBuilder {
 	return es.builder
 }
 
-func (es *ES) Close() error {
-	// Elasticsearch客户端不需要显式关闭
+// Health 通过 Ping 检查集群是否可用
+func (es *ES) Health(ctx context.Context) error {
+	ctx, done, err := es.ops.enter(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	res, err := esapi.PingRequest{}.Do(ctx, es.getClient())
+	if err != nil {
+		return fmt.Errorf("failed to ping elasticsearch: %v", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return newESResponseError("elasticsearch ping error", res)
+	}
 	return nil
 }
 
+// Close 拒绝新的操作并等待进行中的操作完成，等待超过 CloseTimeout 时返回 ErrCloseTimeout 并给出被中断的操作数
+func (es *ES) Close() error {
+	// Elasticsearch客户端不需要显式关闭，停止健康检查和后台滚动后关闭空闲连接
+	es.checker.stop()
+	es.rolloverChecker.stop()
+	err := es.ops.close(es.options.CloseTimeout)
+	es.unregister()
+
+	es.mu.RLock()
+	es.transport.CloseIdleConnections()
+	es.mu.RUnlock()
+	return closeError(err, nil)
+}
+
+// PoolStats 连接池统计，由 Transport 累计，使用中的连接为响应体未关闭的请求
+func (es *ES) PoolStats() PoolStats {
+	return es.pool.stats()
+}
+
 // Migrate 创建/更新索引映射
 func (es *ES) Migrate(ctx context.Context, model *TableModel) error {
+	if err := checkMigrationsAllowed(ctx, es.options.AllowMigrations, model.Table); err != nil {
+		return err
+	}
+	ctx, done, err := es.ops.enter(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	if ts := es.timeSeries(model.Table); ts != nil {
+		return es.migrateTimeSeries(ctx, ts, model)
+	}
+
 	// 构建索引映射
 	mapping := es.buildIndexMapping(model)
 	
@@ -122,21 +372,178 @@ func (es *ES) Migrate(ctx context.Context, model *TableModel) error {
 		Index: []string{model.Table},
 	}
 	
-	res, err := req.Do(ctx, es.client)
+	res, err := req.Do(ctx, es.getClient())
 	if err != nil {
 		return fmt.Errorf("failed to check index existence: %v", err)
 	}
 	defer res.Body.Close()
 
-	if res.StatusCode == 404 {
+	switch res.StatusCode {
+	case 404:
 		// 索引不存在，创建新索引
-		return es.createIndex(ctx, model.Table, mapping)
-	} else if res.StatusCode == 200 {
+		index, body := es.tableIndex(model.Table, mapping)
+		err = es.createIndex(ctx, index, body)
+	case 200:
 		// 索引存在，更新映射
-		return es.updateIndexMapping(ctx, model.Table, mapping)
+		err = es.updateIndexMapping(ctx, model.Table, mapping)
+	default:
+		return fmt.Errorf("unexpected response status: %d", res.StatusCode)
 	}
-	
-	return fmt.Errorf("unexpected response status: %d", res.StatusCode)
+	if err != nil {
+		return err
+	}
+
+	return es.migrateAliases(ctx, model)
+}
+
+// migrateAliases 将视图映射为索引的过滤别名，重复添加同名别名会覆盖原有的过滤条件
+func (es *ES) migrateAliases(ctx context.Context, model *TableModel) error {
+	if len(model.Views) == 0 {
+		return nil
+	}
+
+	indices, err := es.resolveIndices(ctx, model.Table)
+	if err != nil {
+		return err
+	}
+
+	actions := make([]map[string]any, 0, len(model.Views))
+	for _, view := range model.Views {
+		add := map[string]any{
+			"indices": indices,
+			"alias":   view.Name,
+		}
+		if view.Filter != nil {
+			if err := validateESQuery(view.Filter); err != nil {
+				return err
+			}
+			add["filter"] = view.Filter.ToES()
+		}
+		actions = append(actions, map[string]any{"add": add})
+	}
+
+	body, err := json.Marshal(map[string]any{"actions": actions})
+	if err != nil {
+		return fmt.Errorf("failed to marshal aliases: %v", err)
+	}
+
+	req := esapi.IndicesUpdateAliasesRequest{
+		Body: strings.NewReader(string(body)),
+	}
+
+	res, err := req.Do(ctx, es.getClient())
+	if err != nil {
+		return fmt.Errorf("failed to update aliases: %v", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return newESResponseError("failed to update aliases", res)
+	}
+
+	return nil
+}
+
+// MigrateDiff 对比现有映射增量迁移
+// 索引不存在时创建索引，否则只为映射中缺失的字段追加映射，返回的语句为等价的 REST 请求
+func (es *ES) MigrateDiff(ctx context.Context, model *TableModel, opts ...MigrateOption) ([]string, error) {
+	ctx, done, err := es.ops.enter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	migrateOpts := &MigrateOptions{}
+	for _, opt := range opts {
+		opt(migrateOpts)
+	}
+	if !migrateOpts.DryRun {
+		if err := checkMigrationsAllowed(ctx, es.options.AllowMigrations, model.Table); err != nil {
+			return nil, err
+		}
+	}
+	if ts := es.timeSeries(model.Table); ts != nil {
+		return es.migrateDiffTimeSeries(ctx, ts, model, migrateOpts.DryRun)
+	}
+
+	existing, found, err := es.getMappingProperties(ctx, model.Table)
+	if err != nil {
+		return nil, err
+	}
+
+	mapping := es.buildIndexMapping(model)
+	if !found {
+		index, indexBody := es.tableIndex(model.Table, mapping)
+		body, err := json.Marshal(indexBody)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal mapping: %v", err)
+		}
+		statements := []string{fmt.Sprintf("PUT /%s %s", index, body)}
+		if migrateOpts.DryRun {
+			return statements, nil
+		}
+		return statements, es.createIndex(ctx, index, indexBody)
+	}
+
+	missing := make(map[string]any)
+	for _, field := range model.Fields {
+		if _, ok := existing[field.Name]; !ok {
+			missing[field.Name] = es.mapFieldTypeToES(field.Type, field.Size)
+		}
+	}
+	if len(missing) == 0 {
+		return nil, nil
+	}
+
+	body, err := json.Marshal(map[string]any{"properties": missing})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal mapping: %v", err)
+	}
+	statements := []string{fmt.Sprintf("PUT /%s/_mapping %s", model.Table, body)}
+	if migrateOpts.DryRun {
+		return statements, nil
+	}
+	return statements, es.updateIndexMapping(ctx, model.Table, map[string]any{
+		"mappings": map[string]any{"properties": missing},
+	})
+}
+
+// getMappingProperties 获取索引现有的字段映射，索引不存在时 found 为 false
+func (es *ES) getMappingProperties(ctx context.Context, index string) (map[string]any, bool, error) {
+	req := esapi.IndicesGetMappingRequest{
+		Index: []string{index},
+	}
+
+	res, err := req.Do(ctx, es.getClient())
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get mapping: %v", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == 404 {
+		return nil, false, nil
+	}
+	if res.IsError() {
+		return nil, false, newESResponseError("failed to get mapping", res)
+	}
+
+	var result map[string]struct {
+		Mappings struct {
+			Properties map[string]any `json:"properties"`
+		} `json:"mappings"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return nil, false, fmt.Errorf("failed to decode mapping: %v", err)
+	}
+
+	properties := make(map[string]any)
+	for _, item := range result {
+		for name, property := range item.Mappings.Properties {
+			properties[name] = property
+		}
+	}
+
+	return properties, true, nil
 }
 
 // buildIndexMapping 构建索引映射
@@ -189,11 +596,72 @@ func (es *ES) mapFieldTypeToES(fieldType FieldType, size int) map[string]any {
 		}
 	case FieldTypeJSON:
 		return map[string]any{"type": "object"}
+	case FieldTypeBlob:
+		// 只保存 blob 的键，不需要检索
+		return map[string]any{"type": "keyword", "index": false}
+	case FieldTypeGeoPoint:
+		return map[string]any{"type": "geo_point"}
 	default:
 		return map[string]any{"type": "text"}
 	}
 }
 
+// tableIndex 返回表对应的实际索引名和创建索引的请求体
+// 启用 IndexAlias 时实际索引为 <table>_v1，表名作为指向它的写别名
+func (es *ES) tableIndex(table string, mapping map[string]any) (string, map[string]any) {
+	if !es.options.IndexAlias {
+		return table, mapping
+	}
+
+	body := make(map[string]any, len(mapping)+1)
+	for k, v := range mapping {
+		body[k] = v
+	}
+	body["aliases"] = map[string]any{
+		table: map[string]any{"is_write_index": true},
+	}
+	return table + "_v1", body
+}
+
+// resolveIndices 返回表对应的实际索引，启用 IndexAlias 时解析表名别名指向的索引，别名不存在时返回表名本身
+// 按时间分区的表返回匹配所有周期索引的通配符
+func (es *ES) resolveIndices(ctx context.Context, table string) ([]string, error) {
+	if ts := es.timeSeries(table); ts != nil {
+		return []string{ts.indexPattern()}, nil
+	}
+	if !es.options.IndexAlias {
+		return []string{table}, nil
+	}
+
+	req := esapi.IndicesGetAliasRequest{
+		Name: []string{table},
+	}
+
+	res, err := req.Do(ctx, es.getClient())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get alias: %v", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == 404 {
+		return []string{table}, nil
+	}
+	if res.IsError() {
+		return nil, newESResponseError("failed to get alias", res)
+	}
+
+	var result map[string]any
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode alias: %v", err)
+	}
+	indices := make([]string, 0, len(result))
+	for index := range result {
+		indices = append(indices, index)
+	}
+	sort.Strings(indices)
+	return indices, nil
+}
+
 // createIndex 创建新索引
 func (es *ES) createIndex(ctx context.Context, index string, mapping map[string]any) error {
 	body, err := json.Marshal(mapping)
@@ -206,14 +674,14 @@ func (es *ES) createIndex(ctx context.Context, index string, mapping map[string]
 		Body:  strings.NewReader(string(body)),
 	}
 	
-	res, err := req.Do(ctx, es.client)
+	res, err := req.Do(ctx, es.getClient())
 	if err != nil {
 		return fmt.Errorf("failed to create index: %v", err)
 	}
 	defer res.Body.Close()
 	
 	if res.IsError() {
-		return fmt.Errorf("failed to create index: %s", res.String())
+		return newESResponseError("failed to create index", res)
 	}
 	
 	return nil
@@ -236,40 +704,108 @@ func (es *ES) updateIndexMapping(ctx context.Context, index string, mapping map[
 		Body:  strings.NewReader(string(body)),
 	}
 	
-	res, err := req.Do(ctx, es.client)
+	res, err := req.Do(ctx, es.getClient())
 	if err != nil {
 		return fmt.Errorf("failed to update mapping: %v", err)
 	}
 	defer res.Body.Close()
 	
 	if res.IsError() {
-		return fmt.Errorf("failed to update mapping: %s", res.String())
+		return newESResponseError("failed to update mapping", res)
 	}
 	
 	return nil
 }
 
-// DropTable 删除索引
+// DropTable 删除索引，启用 IndexAlias 时删除别名指向的索引，按时间分区的表删除所有周期的索引和索引模板
 func (es *ES) DropTable(ctx context.Context, table string) error {
+	ctx, done, err := es.ops.enter(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	indices, err := es.resolveIndices(ctx, table)
+	if err != nil {
+		return err
+	}
+
 	req := esapi.IndicesDeleteRequest{
-		Index: []string{table},
+		Index: indices,
 	}
 	
-	res, err := req.Do(ctx, es.client)
+	res, err := req.Do(ctx, es.getClient())
 	if err != nil {
 		return fmt.Errorf("failed to delete index: %v", err)
 	}
 	defer res.Body.Close()
 	
 	if res.IsError() && res.StatusCode != 404 {
-		return fmt.Errorf("failed to delete index: %s", res.String())
+		return newESResponseError("failed to delete index", res)
+	}
+
+	if ts := es.timeSeries(table); ts != nil {
+		return es.deleteIndexTemplate(ctx, ts.table)
 	}
-	
 	return nil
 }
 
+// Stats 获取索引统计信息，基于 _stats 接口的主分片数据
+// ES 的文档存储即索引，不单独统计索引大小，IndexSize 为 0
+func (es *ES) Stats(ctx context.Context, table string) (*TableStats, error) {
+	ctx, done, err := es.ops.enter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	req := esapi.IndicesStatsRequest{
+		Index:  []string{table},
+		Metric: []string{"docs", "store"},
+	}
+
+	res, err := req.Do(ctx, es.getClient())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get index stats: %v", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return nil, newESResponseError("failed to get index stats", res)
+	}
+
+	var result struct {
+		All struct {
+			Primaries struct {
+				Docs struct {
+					Count int64 `json:"count"`
+				} `json:"docs"`
+				Store struct {
+					SizeInBytes int64 `json:"size_in_bytes"`
+				} `json:"store"`
+			} `json:"primaries"`
+		} `json:"_all"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode index stats: %v", err)
+	}
+
+	return &TableStats{
+		RowCount: result.All.Primaries.Docs.Count,
+		DataSize: result.All.Primaries.Store.SizeInBytes,
+	}, nil
+}
+
 // CRUD 操作实现
 func (es *ES) Create(ctx context.Context, table string, record Record, opts ...CreateOption) error {
+	ctx, done, err := es.ops.enter(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+	ctx, cancel := createContext(ctx, opts)
+	defer cancel()
+
 	// 解析创建选项
 	createOpts := &CreateOptions{}
 	for _, opt := range opts {
@@ -297,37 +833,48 @@ func (es *ES) Create(ctx context.Context, table string, record Record, opts ...C
 			Index:      table,
 			DocumentID: docID,
 			Body:       strings.NewReader(string(body)),
-			Refresh:    "wait_for",
+			Refresh:    es.refresh(),
 		}
 		
-		res, err := req.Do(ctx, es.client)
+		res, err := req.Do(ctx, es.getClient())
 		if err != nil {
 			return fmt.Errorf("failed to create document: %v", err)
 		}
 		defer res.Body.Close()
 		
 		if res.IsError() && res.StatusCode != 409 {
-			return fmt.Errorf("failed to create document: %s", res.String())
+			return newESResponseError("failed to create document", res)
 		}
 		
 		return nil
 	} else if createOpts.UpdateOnConflict {
+		// DirtyRecord 使用 update 操作，文档已存在时只更新修改过的字段，不存在时插入完整文档
+		if dirty, ok := record.(DirtyRecord); ok && docID != "" {
+			doc := make(map[string]any, len(dirty.DirtyFields()))
+			for k, v := range dirty.DirtyFields() {
+				if k != "_id" {
+					doc[k] = v
+				}
+			}
+			return es.updateDocument(ctx, table, map[string]any{"_id": docID}, map[string]any{"doc": doc, "upsert": fields}, nil, nil)
+		}
+
 		// 使用index操作，如果文档已存在则更新
 		req := esapi.IndexRequest{
 			Index:      table,
 			DocumentID: docID,
 			Body:       strings.NewReader(string(body)),
-			Refresh:    "wait_for",
+			Refresh:    es.refresh(),
 		}
 		
-		res, err := req.Do(ctx, es.client)
+		res, err := req.Do(ctx, es.getClient())
 		if err != nil {
 			return fmt.Errorf("failed to index document: %v", err)
 		}
 		defer res.Body.Close()
 		
 		if res.IsError() {
-			return fmt.Errorf("failed to index document: %s", res.String())
+			return newESResponseError("failed to index document", res)
 		}
 		
 		return nil
@@ -337,10 +884,10 @@ func (es *ES) Create(ctx context.Context, table string, record Record, opts ...C
 			Index:      table,
 			DocumentID: docID,
 			Body:       strings.NewReader(string(body)),
-			Refresh:    "wait_for",
+			Refresh:    es.refresh(),
 		}
 		
-		res, err := req.Do(ctx, es.client)
+		res, err := req.Do(ctx, es.getClient())
 		if err != nil {
 			return fmt.Errorf("failed to create document: %v", err)
 		}
@@ -350,14 +897,22 @@ func (es *ES) Create(ctx context.Context, table string, record Record, opts ...C
 			if res.StatusCode == 409 {
 				return ErrDuplicateKey
 			}
-			return fmt.Errorf("failed to create document: %s", res.String())
+			return newESResponseError("failed to create document", res)
 		}
 		
 		return nil
 	}
 }
 
-func (es *ES) Get(ctx context.Context, table string, pk map[string]any) (Record, error) {
+func (es *ES) Get(ctx context.Context, table string, pk map[string]any, opts ...QueryOption) (Record, error) {
+	ctx, done, err := es.ops.enter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	ctx, cancel := queryContext(ctx, opts)
+	defer cancel()
+
 	// ES中主键通常是_id字段
 	var docID string
 	if id, exists := pk["_id"]; exists {
@@ -369,11 +924,12 @@ func (es *ES) Get(ctx context.Context, table string, pk map[string]any) (Record,
 	}
 	
 	req := esapi.GetRequest{
-		Index:      table,
-		DocumentID: docID,
+		Index:          table,
+		DocumentID:     docID,
+		SourceIncludes: newQueryOptions(opts).Fields,
 	}
 	
-	res, err := req.Do(ctx, es.client)
+	res, err := req.Do(ctx, es.getClient())
 	if err != nil {
 		return nil, fmt.Errorf("failed to get document: %v", err)
 	}
@@ -384,7 +940,7 @@ func (es *ES) Get(ctx context.Context, table string, pk map[string]any) (Record,
 	}
 	
 	if res.IsError() {
-		return nil, fmt.Errorf("failed to get document: %s", res.String())
+		return nil, newESResponseError("failed to get document", res)
 	}
 	
 	// 解析响应
@@ -407,61 +963,248 @@ func (es *ES) Get(ctx context.Context, table string, pk map[string]any) (Record,
 	// 添加文档ID到源数据
 	source["_id"] = result["_id"]
 	
+	seqNo, _ := result["_seq_no"].(float64)
+	primaryTerm, _ := result["_primary_term"].(float64)
+
 	return &ESRecord{
-		id:     docID,
-		index:  table,
-		source: source,
+		id:          docID,
+		index:       table,
+		source:      source,
+		seqNo:       int(seqNo),
+		primaryTerm: int(primaryTerm),
+		tagName:     es.builder.tagName,
 	}, nil
 }
 
-func (es *ES) Update(ctx context.Context, table string, pk map[string]any, record Record) error {
-	// 提取文档ID
-	var docID string
-	if id, exists := pk["_id"]; exists {
-		docID = fmt.Sprintf("%v", id)
-	} else if id, exists := pk["id"]; exists {
-		docID = fmt.Sprintf("%v", id)
-	} else {
-		return fmt.Errorf("document ID not found in primary key")
+func (es *ES) Update(ctx context.Context, table string, pk map[string]any, record Record, opts ...UpdateOption) error {
+	ctx, done, err := es.ops.enter(ctx)
+	if err != nil {
+		return err
 	}
-	
-	fields := record.Fields()
-	
-	// 构建更新文档
-	updateDoc := map[string]any{
-		"doc": fields,
+	defer done()
+	ctx, cancel := updateContext(ctx, opts)
+	defer cancel()
+
+	fields := updateFields(record)
+	if newUpdateOptions(opts).versioned() {
+		return es.UpdatePartial(ctx, table, pk, fields, opts...)
 	}
-	
-	body, err := json.Marshal(updateDoc)
-	if err != nil {
-		return fmt.Errorf("failed to marshal update document: %v", err)
+	if len(fields) == 0 {
+		return nil
 	}
-	
-	req := esapi.UpdateRequest{
+	return es.updateDocument(ctx, table, pk, map[string]any{"doc": fields}, nil, nil)
+}
+
+// UpdatePartial 使用 partial doc 只更新指定字段
+// 启用乐观锁时先读取文档校验版本，再通过 if_seq_no/if_primary_term 条件更新，期间文档被修改同样返回 ErrVersionConflict
+func (es *ES) UpdatePartial(ctx context.Context, table string, pk map[string]any, fields map[string]any, opts ...UpdateOption) error {
+	ctx, done, err := es.ops.enter(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+	ctx, cancel := updateContext(ctx, opts)
+	defer cancel()
+
+	options := newUpdateOptions(opts)
+	if len(fields) == 0 && !options.versioned() {
+		return fmt.Errorf("no fields to update")
+	}
+	if !options.versioned() {
+		return es.updateDocument(ctx, table, pk, map[string]any{"doc": fields}, nil, nil)
+	}
+
+	doc, seqNo, primaryTerm, err := es.prepareVersionedUpdate(ctx, table, pk, fields, options)
+	if err != nil {
+		return err
+	}
+	return es.updateDocument(ctx, table, pk, map[string]any{"doc": doc}, &seqNo, &primaryTerm)
+}
+
+// Upsert 使用 index 操作按文档 ID 写入，文档已存在时整体替换
+func (es *ES) Upsert(ctx context.Context, table string, pk map[string]any, record Record) error {
+	ctx, done, err := es.ops.enter(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	docID, fields, err := esUpsertDocument(pk, record)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(fields)
+	if err != nil {
+		return fmt.Errorf("failed to marshal document: %v", err)
+	}
+
+	req := esapi.IndexRequest{
 		Index:      table,
 		DocumentID: docID,
 		Body:       strings.NewReader(string(body)),
-		Refresh:    "wait_for",
+		Refresh:    es.refresh(),
 	}
-	
-	res, err := req.Do(ctx, es.client)
+	res, err := req.Do(ctx, es.getClient())
+	if err != nil {
+		return fmt.Errorf("failed to index document: %v", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return newESResponseError("failed to index document", res)
+	}
+	return nil
+}
+
+// esUpsertDocument 从主键中提取文档 ID，返回不包含 _id 的文档内容
+func esUpsertDocument(pk map[string]any, record Record) (string, map[string]any, error) {
+	var docID string
+	if id, exists := pk["_id"]; exists {
+		docID = fmt.Sprintf("%v", id)
+	} else if id, exists := pk["id"]; exists {
+		docID = fmt.Sprintf("%v", id)
+	} else {
+		return "", nil, fmt.Errorf("document ID not found in primary key")
+	}
+
+	fields, err := upsertFields(pk, record)
+	if err != nil {
+		return "", nil, err
+	}
+	delete(fields, "_id")
+	return docID, fields, nil
+}
+
+// Increment 使用 painless 脚本原子增减，由 ES 在分片上完成读改写
+func (es *ES) Increment(ctx context.Context, table string, pk map[string]any, field string, delta any) error {
+	ctx, done, err := es.ops.enter(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	if err := validateIncrementDelta(delta); err != nil {
+		return err
+	}
+	return es.updateDocument(ctx, table, pk, map[string]any{"script": esIncrementScript(field, delta)}, nil, nil)
+}
+
+// prepareVersionedUpdate 读取文档校验版本，返回版本加 1 后的更新内容以及文档当前的序列号和主分片任期
+func (es *ES) prepareVersionedUpdate(ctx context.Context, table string, pk map[string]any, fields map[string]any, options *UpdateOptions) (map[string]any, int, int, error) {
+	expected, ok := esVersionNumber(options.Version)
+	if !ok {
+		return nil, 0, 0, fmt.Errorf("invalid version %v, version must be an integer", options.Version)
+	}
+
+	record, err := es.Get(ctx, table, pk)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	esRecord := record.(*ESRecord)
+	current, ok := esVersionNumber(esRecord.source[options.VersionField])
+	if !ok || current != expected {
+		return nil, 0, 0, ErrVersionConflict
+	}
+
+	doc := make(map[string]any, len(fields)+1)
+	for k, v := range options.stripVersion(fields) {
+		doc[k] = v
+	}
+	doc[options.VersionField] = current + 1
+
+	return doc, esRecord.seqNo, esRecord.primaryTerm, nil
+}
+
+// esVersionNumber 将版本转为整数，文档中的数值解码后为 float64
+func esVersionNumber(v any) (int64, bool) {
+	switch n := v.(type) {
+	case int:
+		return int64(n), true
+	case int32:
+		return int64(n), true
+	case int64:
+		return n, true
+	case uint:
+		return int64(n), true
+	case uint32:
+		return int64(n), true
+	case uint64:
+		return int64(n), true
+	case float64:
+		return int64(n), n == float64(int64(n))
+	case json.Number:
+		i, err := n.Int64()
+		return i, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// updateDocument 根据主键执行 _update 请求
+// ifSeqNo 和 ifPrimaryTerm 不为空时做条件更新，文档已被修改时返回 ErrVersionConflict
+func (es *ES) updateDocument(ctx context.Context, table string, pk map[string]any, updateDoc map[string]any, ifSeqNo *int, ifPrimaryTerm *int) error {
+	var docID string
+	if id, exists := pk["_id"]; exists {
+		docID = fmt.Sprintf("%v", id)
+	} else if id, exists := pk["id"]; exists {
+		docID = fmt.Sprintf("%v", id)
+	} else {
+		return fmt.Errorf("document ID not found in primary key")
+	}
+
+	body, err := json.Marshal(updateDoc)
+	if err != nil {
+		return fmt.Errorf("failed to marshal update document: %v", err)
+	}
+
+	req := esapi.UpdateRequest{
+		Index:         table,
+		DocumentID:    docID,
+		Body:          strings.NewReader(string(body)),
+		Refresh:       es.refresh(),
+		IfSeqNo:       ifSeqNo,
+		IfPrimaryTerm: ifPrimaryTerm,
+	}
+	// 条件更新不能与 retry_on_conflict 同时使用
+	if ifSeqNo == nil {
+		req.RetryOnConflict = es.retryOnConflict()
+	}
+
+	res, err := req.Do(ctx, es.getClient())
 	if err != nil {
 		return fmt.Errorf("failed to update document: %v", err)
 	}
 	defer res.Body.Close()
-	
+
 	if res.StatusCode == 404 {
 		return ErrRecordNotFound
 	}
-	
+	if res.StatusCode == 409 {
+		return ErrVersionConflict
+	}
 	if res.IsError() {
-		return fmt.Errorf("failed to update document: %s", res.String())
+		return newESResponseError("failed to update document", res)
 	}
-	
+
 	return nil
 }
 
+// esIncrementScript 构建字段增减脚本，字段名和增量通过参数传入
+func esIncrementScript(field string, delta any) map[string]any {
+	return map[string]any{
+		"source": "ctx._source[params.field] += params.delta",
+		"lang":   "painless",
+		"params": map[string]any{"field": field, "delta": delta},
+	}
+}
+
 func (es *ES) Delete(ctx context.Context, table string, pk map[string]any) error {
+	ctx, done, err := es.ops.enter(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
 	// 提取文档ID
 	var docID string
 	if id, exists := pk["_id"]; exists {
@@ -475,10 +1218,10 @@ func (es *ES) Delete(ctx context.Context, table string, pk map[string]any) error
 	req := esapi.DeleteRequest{
 		Index:      table,
 		DocumentID: docID,
-		Refresh:    "wait_for",
+		Refresh:    es.refresh(),
 	}
 	
-	res, err := req.Do(ctx, es.client)
+	res, err := req.Do(ctx, es.getClient())
 	if err != nil {
 		return fmt.Errorf("failed to delete document: %v", err)
 	}
@@ -489,45 +1232,36 @@ func (es *ES) Delete(ctx context.Context, table string, pk map[string]any) error
 	}
 	
 	if res.IsError() {
-		return fmt.Errorf("failed to delete document: %s", res.String())
+		return newESResponseError("failed to delete document", res)
 	}
 	
 	return nil
 }
 
+// validateESQuery 校验查询可以转换为 ES 查询，未提供 ES 表达式的 RawQuery 返回错误而不是不匹配任何文档
+// 查询方法的参数名 query 遮蔽了 query 包，通过该函数调用 query.ValidateES
+func validateESQuery(q query.Query) error {
+	return query.ValidateES(q)
+}
+
 // 查询和聚合功能实现
 func (es *ES) Find(ctx context.Context, table string, query query.Query, opts ...QueryOption) ([]Record, error) {
+	ctx, done, err := es.ops.enter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	ctx, cancel := queryContext(ctx, opts)
+	defer cancel()
+
 	// 解析查询选项
 	queryOpts := &QueryOptions{}
 	for _, opt := range opts {
 		opt(queryOpts)
 	}
-	
-	// 构建ES查询
-	esQuery := query.ToES()
-	
-	// 构建搜索请求体
-	searchBody := map[string]any{
-		"query": esQuery,
-	}
-	
-	// 添加分页
-	if queryOpts.Limit > 0 {
-		searchBody["size"] = queryOpts.Limit
-	}
-	if queryOpts.Offset > 0 {
-		searchBody["from"] = queryOpts.Offset
-	}
-	
-	// 添加排序
-	if queryOpts.OrderBy != "" {
-		order := "asc"
-		if queryOpts.OrderDesc {
-			order = "desc"
-		}
-		searchBody["sort"] = []map[string]any{
-			{queryOpts.OrderBy: map[string]any{"order": order}},
-		}
+	searchBody, scoring, err := esSearchBody(query, queryOpts)
+	if err != nil {
+		return nil, err
 	}
 	
 	// 序列化请求体
@@ -542,14 +1276,14 @@ func (es *ES) Find(ctx context.Context, table string, query query.Query, opts ..
 		Body:  strings.NewReader(string(body)),
 	}
 	
-	res, err := req.Do(ctx, es.client)
+	res, err := req.Do(ctx, es.getClient())
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute search: %v", err)
 	}
 	defer res.Body.Close()
 	
 	if res.IsError() {
-		return nil, fmt.Errorf("search error: %s", res.String())
+		return nil, newESResponseError("search error", res)
 	}
 	
 	// 解析搜索结果
@@ -585,23 +1319,428 @@ func (es *ES) Find(ctx context.Context, table string, query query.Query, opts ..
 		source["_id"] = hitMap["_id"]
 		source["_index"] = hitMap["_index"]
 		
-		records = append(records, &ESRecord{
-			id:     fmt.Sprintf("%v", hitMap["_id"]),
-			index:  table,
-			source: source,
-		})
+		record := &ESRecord{
+			id:      fmt.Sprintf("%v", hitMap["_id"]),
+			index:   table,
+			source:  source,
+			tagName: es.builder.tagName,
+		}
+		if score, ok := toFloat64(hitMap["_score"]); ok && scoring {
+			record.score = &score
+		}
+		records = append(records, record)
 	}
 	
 	return records, nil
 }
 
+// esSearchBody 构建 Find 的搜索请求体，返回是否需要计算相关度评分
+func esSearchBody(query query.Query, queryOpts *QueryOptions) (map[string]any, bool, error) {
+	if err := validateESQuery(query); err != nil {
+		return nil, false, err
+	}
+	scoring, err := queryOpts.scoring()
+	if err != nil {
+		return nil, false, err
+	}
+
+	// 构建ES查询
+	esQuery := query.ToES()
+
+	// 构建搜索请求体
+	searchBody := map[string]any{
+		"query": esQuery,
+	}
+
+	if len(queryOpts.Fields) > 0 {
+		searchBody["_source"] = queryOpts.Fields
+	}
+
+	// 添加分页
+	if queryOpts.Limit > 0 {
+		searchBody["size"] = queryOpts.Limit
+	}
+	if queryOpts.Offset > 0 {
+		searchBody["from"] = queryOpts.Offset
+	}
+
+	// 添加排序，游标分页时使用 search_after 从上一页的排序值之后继续
+	if sort := esSort(queryOpts); sort != nil {
+		searchBody["sort"] = sort
+		if len(queryOpts.SearchAfter) > 0 {
+			searchBody["search_after"] = esSearchAfter(queryOpts.SearchAfter)
+		}
+		// 指定排序时 ES 默认不计算评分
+		if scoring {
+			searchBody["track_scores"] = true
+		}
+	}
+
+	return searchBody, scoring, nil
+}
+
+// Explain 返回 Find 发送给 ES 的搜索请求体，不执行查询
+func (es *ES) Explain(ctx context.Context, table string, query query.Query, opts ...QueryOption) (*ExplainResult, error) {
+	queryOpts := &QueryOptions{}
+	for _, opt := range opts {
+		opt(queryOpts)
+	}
+
+	searchBody, _, err := esSearchBody(query, queryOpts)
+	if err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(searchBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal search body: %v", err)
+	}
+	return &ExplainResult{Backend: "es", Statement: fmt.Sprintf("GET /%s/_search %s", table, body)}, nil
+}
+
+// FindStream 流式查询，基于 scroll 接口按批拉取文档
+// scroll 不支持 from 参数，Offset 在客户端跳过
+// 游标关闭前算作进行中的操作，Close 会等待游标关闭
+func (es *ES) FindStream(ctx context.Context, table string, query query.Query, opts ...QueryOption) (RecordCursor, error) {
+	ctx, done, err := es.ops.enter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cursor, err := streamWithTimeout(ctx, opts, func(ctx context.Context) (RecordCursor, error) {
+		return es.findStream(ctx, table, query, opts...)
+	})
+	if err != nil {
+		done()
+		return nil, err
+	}
+	return &trackedCursor{RecordCursor: cursor, done: done}, nil
+}
+
+func (es *ES) findStream(ctx context.Context, table string, query query.Query, opts ...QueryOption) (RecordCursor, error) {
+	queryOpts := &QueryOptions{}
+	for _, opt := range opts {
+		opt(queryOpts)
+	}
+	if err := validateESQuery(query); err != nil {
+		return nil, err
+	}
+	scoring, err := queryOpts.scoring()
+	if err != nil {
+		return nil, err
+	}
+
+	batchSize := queryOpts.BatchSize
+	if batchSize <= 0 {
+		batchSize = 1000
+	}
+
+	// scroll 不支持 search_after，游标分页的位置转换为键集条件
+	searchBody := map[string]any{
+		"query": queryOpts.keysetQuery(query).ToES(),
+		"size":  batchSize,
+	}
+	if len(queryOpts.Fields) > 0 {
+		searchBody["_source"] = queryOpts.Fields
+	}
+	if sort := esSort(queryOpts); sort != nil {
+		searchBody["sort"] = sort
+		if scoring {
+			searchBody["track_scores"] = true
+		}
+	} else if !scoring {
+		// 不需要排序和评分时按索引顺序遍历，scroll 效率最高
+		searchBody["sort"] = []string{"_doc"}
+	}
+
+	body, err := json.Marshal(searchBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal search body: %v", err)
+	}
+
+	req := esapi.SearchRequest{
+		Index:  []string{table},
+		Body:   strings.NewReader(string(body)),
+		Scroll: esScrollKeepAlive,
+	}
+	res, err := req.Do(ctx, es.getClient())
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute search: %v", err)
+	}
+
+	cursor := &ESRecordCursor{
+		ctx:     ctx,
+		es:      es,
+		index:   table,
+		limit:   queryOpts.Limit,
+		skip:    queryOpts.Offset,
+		scoring: scoring,
+	}
+	if err := cursor.load(res); err != nil {
+		cursor.Close()
+		return nil, err
+	}
+
+	return cursor, nil
+}
+
+// esScrollKeepAlive scroll 上下文的保持时间，每次拉取后续批次时刷新
+const esScrollKeepAlive = time.Minute
+
+// ESRecordCursor 基于 scroll 接口的记录游标
+type ESRecordCursor struct {
+	ctx      context.Context
+	es       *ES
+	index    string
+	scrollID string
+	buffer   []Record
+	pos      int
+	limit    int // 剩余可返回的记录数，0 表示不限制
+	skip     int // 需要跳过的记录数
+	returned int
+	scoring  bool // 返回相关度评分
+	record   Record
+	done     bool
+	err      error
+}
+
+// load 解析一批搜索结果，没有命中时标记遍历结束
+func (c *ESRecordCursor) load(res *esapi.Response) error {
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return newESResponseError("search error", res)
+	}
+
+	var searchResult struct {
+		ScrollID string `json:"_scroll_id"`
+		Hits     struct {
+			Hits []struct {
+				ID     string         `json:"_id"`
+				Index  string         `json:"_index"`
+				Source map[string]any `json:"_source"`
+				Score  *float64       `json:"_score"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&searchResult); err != nil {
+		return fmt.Errorf("failed to decode search result: %v", err)
+	}
+
+	c.scrollID = searchResult.ScrollID
+	c.buffer = c.buffer[:0]
+	c.pos = 0
+	for _, hit := range searchResult.Hits.Hits {
+		if hit.Source == nil {
+			hit.Source = map[string]any{}
+		}
+		// 添加文档元数据
+		hit.Source["_id"] = hit.ID
+		hit.Source["_index"] = hit.Index
+		record := &ESRecord{id: hit.ID, index: c.index, source: hit.Source, tagName: c.es.builder.tagName}
+		if c.scoring {
+			record.score = hit.Score
+		}
+		c.buffer = append(c.buffer, record)
+	}
+	if len(c.buffer) == 0 {
+		c.done = true
+	}
+
+	return nil
+}
+
+// fetch 通过 scroll 拉取下一批结果
+func (c *ESRecordCursor) fetch() error {
+	req := esapi.ScrollRequest{
+		ScrollID: c.scrollID,
+		Scroll:   esScrollKeepAlive,
+	}
+	res, err := req.Do(c.ctx, c.es.getClient())
+	if err != nil {
+		return fmt.Errorf("failed to execute scroll: %v", err)
+	}
+	return c.load(res)
+}
+
+func (c *ESRecordCursor) Next() bool {
+	c.record = nil
+	for c.err == nil && !c.done {
+		if c.limit > 0 && c.returned >= c.limit {
+			c.done = true
+			break
+		}
+		if c.pos >= len(c.buffer) {
+			if err := c.fetch(); err != nil {
+				c.err = err
+			}
+			continue
+		}
+
+		record := c.buffer[c.pos]
+		c.pos++
+		if c.skip > 0 {
+			c.skip--
+			continue
+		}
+		c.record = record
+		c.returned++
+		return true
+	}
+	return false
+}
+
+func (c *ESRecordCursor) Scan(dest any) error {
+	if c.record == nil {
+		return fmt.Errorf("no current record")
+	}
+	return c.record.Scan(dest)
+}
+
+func (c *ESRecordCursor) Record() Record {
+	return c.record
+}
+
+func (c *ESRecordCursor) Err() error {
+	return c.err
+}
+
+// Close 清理服务端的 scroll 上下文
+func (c *ESRecordCursor) Close() error {
+	c.done = true
+	if c.scrollID == "" {
+		return nil
+	}
+
+	req := esapi.ClearScrollRequest{
+		ScrollID: []string{c.scrollID},
+	}
+	c.scrollID = ""
+	res, err := req.Do(context.Background(), c.es.getClient())
+	if err != nil {
+		return fmt.Errorf("failed to clear scroll: %v", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() && res.StatusCode != 404 {
+		return newESResponseError("failed to clear scroll", res)
+	}
+	return nil
+}
+
+// Count 使用 _count 接口统计文档数
+func (es *ES) Count(ctx context.Context, table string, query query.Query) (int64, error) {
+	ctx, done, err := es.ops.enter(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer done()
+
+	if err := validateESQuery(query); err != nil {
+		return 0, err
+	}
+	body, err := json.Marshal(map[string]any{"query": query.ToES()})
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal count body: %v", err)
+	}
+
+	req := esapi.CountRequest{
+		Index: []string{table},
+		Body:  strings.NewReader(string(body)),
+	}
+
+	res, err := req.Do(ctx, es.getClient())
+	if err != nil {
+		return 0, fmt.Errorf("failed to execute count: %v", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return 0, newESResponseError("count error", res)
+	}
+
+	var result struct {
+		Count int64 `json:"count"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return 0, fmt.Errorf("failed to decode count result: %v", err)
+	}
+
+	return result.Count, nil
+}
+
+// Exists 使用 HEAD 请求判断文档是否存在，不读取文档内容
+func (es *ES) Exists(ctx context.Context, table string, pk map[string]any) (bool, error) {
+	ctx, done, err := es.ops.enter(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer done()
+
+	var docID string
+	if id, exists := pk["_id"]; exists {
+		docID = fmt.Sprintf("%v", id)
+	} else if id, exists := pk["id"]; exists {
+		docID = fmt.Sprintf("%v", id)
+	} else {
+		return false, fmt.Errorf("document ID not found in primary key")
+	}
+
+	req := esapi.ExistsRequest{
+		Index:      table,
+		DocumentID: docID,
+	}
+
+	res, err := req.Do(ctx, es.getClient())
+	if err != nil {
+		return false, fmt.Errorf("failed to check document: %v", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == 404 {
+		return false, nil
+	}
+	if res.IsError() {
+		return false, newESResponseError("failed to check document", res)
+	}
+
+	return true, nil
+}
+
 func (es *ES) Aggregate(ctx context.Context, table string, query query.Query, aggs []aggregation.Aggregation, opts ...QueryOption) (aggregation.AggregationResult, error) {
+	ctx, done, err := es.ops.enter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	ctx, cancel := queryContext(ctx, opts)
+	defer cancel()
+
 	// 解析查询选项
 	queryOpts := &QueryOptions{}
 	for _, opt := range opts {
 		opt(queryOpts)
 	}
 	
+	if err := checkPipelineAggregations("elasticsearch", aggs); err != nil {
+		return nil, err
+	}
+	if err := aggregation.ValidateScriptAggregations(aggs); err != nil {
+		return nil, err
+	}
+	if err := validateESQuery(query); err != nil {
+		return nil, err
+	}
+	// 分组统计：composite 聚合按页拉取全部分组
+	if queryOpts.grouping() {
+		group, err := newGroupBy(aggs, queryOpts)
+		if err != nil {
+			return nil, err
+		}
+		return es.aggregateGroupBy(ctx, table, query, group)
+	}
+
+	// 顶层派生指标在客户端计算，bucket_script 只能作为桶聚合的子聚合
+	scripts, aggs := aggregation.SplitScriptAggregations(aggs)
+
 	// 构建ES查询
 	esQuery := query.ToES()
 	
@@ -621,86 +1760,317 @@ func (es *ES) Aggregate(ctx context.Context, table string, query query.Query, ag
 		"aggs":  esAggs,
 		"size":  0, // 只返回聚合结果，不返回文档
 	}
-	
-	// 序列化请求体
-	body, err := json.Marshal(searchBody)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal search body: %v", err)
+	
+	// 序列化请求体
+	body, err := json.Marshal(searchBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal search body: %v", err)
+	}
+	
+	// 执行搜索
+	req := esapi.SearchRequest{
+		Index: []string{table},
+		Body:  strings.NewReader(string(body)),
+	}
+	
+	res, err := req.Do(ctx, es.getClient())
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute aggregation: %v", err)
+	}
+	defer res.Body.Close()
+	
+	if res.IsError() {
+		return nil, newESResponseError("aggregation error", res)
+	}
+	
+	// 解析聚合结果
+	var searchResult map[string]any
+	if err := json.NewDecoder(res.Body).Decode(&searchResult); err != nil {
+		return nil, fmt.Errorf("failed to decode aggregation result: %v", err)
+	}
+	
+	// 提取聚合结果
+	aggregations, ok := searchResult["aggregations"].(map[string]any)
+	if !ok {
+		return aggregation.NewAggregationResult(), nil
+	}
+	
+	// 构建聚合结果，桶聚合递归解析子聚合
+	result := aggregation.NewAggregationResult()
+	parseESAggregations(aggs, aggregations, result.SetResult)
+	if err := applyScriptAggregations(scripts, result.Get, result.SetResult); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// esGroupByPageSize 分组统计时 composite 聚合每页的分组数
+const esGroupByPageSize = 1000
+
+// esHavingAggName 分组统计中 Having 对应的 bucket_selector 的名称
+const esHavingAggName = "_having"
+
+// aggregateGroupBy 通过 composite 聚合按页拉取全部分组，Having 转换为 bucket_selector 在服务端过滤
+// composite 聚合只能按分组键翻页，按指标排序以及 Limit、Offset 在客户端拉取全部分组之后执行
+func (es *ES) aggregateGroupBy(ctx context.Context, table string, query query.Query, group *groupBy) (aggregation.AggregationResult, error) {
+	var rows []map[string]any
+	var after map[string]any
+	for {
+		searchBody, err := esGroupBySearchBody(query, group, after)
+		if err != nil {
+			return nil, err
+		}
+		buckets, afterKey, err := es.searchGroupPage(ctx, table, searchBody)
+		if err != nil {
+			return nil, err
+		}
+		for _, bucket := range buckets {
+			key, _ := bucket["key"].(map[string]any)
+			values := map[string]any{GroupCountField: bucket["doc_count"]}
+			for i, field := range group.fields {
+				values[field] = key[fmt.Sprintf("%s%d", aggKeyFieldPrefix, i)]
+			}
+			for _, metric := range group.metrics {
+				if value, ok := bucket[metric.Name()].(map[string]any); ok {
+					values[metric.Name()] = value["value"]
+				}
+			}
+			row, err := group.row(values)
+			if err != nil {
+				return nil, err
+			}
+			rows = append(rows, row)
+		}
+		// 一页的分组全部被 Having 过滤时仍然返回 after_key，没有 after_key 时才是最后一页
+		if len(afterKey) == 0 {
+			break
+		}
+		after = afterKey
+	}
+	return group.result(group.page(rows)), nil
+}
+
+// searchGroupPage 执行分组统计的一页查询，返回分组桶和下一页的 after_key
+func (es *ES) searchGroupPage(ctx context.Context, table string, searchBody map[string]any) ([]map[string]any, map[string]any, error) {
+	body, err := json.Marshal(searchBody)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal search body: %v", err)
+	}
+	req := esapi.SearchRequest{
+		Index: []string{table},
+		Body:  strings.NewReader(string(body)),
+	}
+	res, err := req.Do(ctx, es.getClient())
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to execute aggregation: %v", err)
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return nil, nil, newESResponseError("aggregation error", res)
+	}
+
+	var searchResult struct {
+		Aggregations map[string]struct {
+			AfterKey map[string]any   `json:"after_key"`
+			Buckets  []map[string]any `json:"buckets"`
+		} `json:"aggregations"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&searchResult); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode aggregation result: %v", err)
+	}
+	groups := searchResult.Aggregations[GroupByResultName]
+	return groups.Buckets, groups.AfterKey, nil
+}
+
+// esGroupBySearchBody 构建分组统计的搜索请求体，分组字段作为 composite 聚合的源，缺少分组字段的文档归入 null 分组
+func esGroupBySearchBody(query query.Query, group *groupBy, after map[string]any) (map[string]any, error) {
+	sources := make([]map[string]any, len(group.fields))
+	for i, field := range group.fields {
+		sources[i] = map[string]any{
+			fmt.Sprintf("%s%d", aggKeyFieldPrefix, i): map[string]any{
+				"terms": map[string]any{"field": field, "missing_bucket": true},
+			},
+		}
+	}
+	composite := map[string]any{"size": esGroupByPageSize, "sources": sources}
+	if after != nil {
+		composite["after"] = after
+	}
+
+	groupAgg := map[string]any{"composite": composite}
+	subAggs := make(map[string]any)
+	for _, metric := range group.metrics {
+		subAggs[metric.Name()] = metric.ToES()
+	}
+	if group.having != nil {
+		selector, err := esHavingSelector(group.having)
+		if err != nil {
+			return nil, err
+		}
+		subAggs[esHavingAggName] = selector
+	}
+	if len(subAggs) > 0 {
+		groupAgg["aggs"] = subAggs
 	}
-	
-	// 执行搜索
-	req := esapi.SearchRequest{
-		Index: []string{table},
-		Body:  strings.NewReader(string(body)),
+
+	return map[string]any{
+		"query": query.ToES(),
+		"size":  0,
+		"aggs":  map[string]any{GroupByResultName: groupAgg},
+	}, nil
+}
+
+// esHavingSelector 将 Having 转换为 bucket_selector，指标和文档数通过 buckets_path 映射为脚本变量
+func esHavingSelector(having query.Query) (map[string]any, error) {
+	paths := make(map[string]any)
+	variables := make(map[string]string)
+	variable := func(field string) string {
+		name, ok := variables[field]
+		if !ok {
+			name = fmt.Sprintf("v%d", len(variables))
+			variables[field] = name
+			paths[name] = field
+		}
+		return "params." + name
 	}
-	
-	res, err := req.Do(ctx, es.client)
+	script, err := esHavingScript(having, variable)
 	if err != nil {
-		return nil, fmt.Errorf("failed to execute aggregation: %v", err)
-	}
-	defer res.Body.Close()
-	
-	if res.IsError() {
-		return nil, fmt.Errorf("aggregation error: %s", res.String())
+		return nil, err
 	}
-	
-	// 解析聚合结果
-	var searchResult map[string]any
-	if err := json.NewDecoder(res.Body).Decode(&searchResult); err != nil {
-		return nil, fmt.Errorf("failed to decode aggregation result: %v", err)
+	return map[string]any{
+		"bucket_selector": map[string]any{
+			"buckets_path": paths,
+			"script":       script,
+		},
+	}, nil
+}
+
+// esHavingScript 将 Having 转换为 painless 表达式，BoolQuery 的语义与 SQL 相同，Should 中至少满足一个
+func esHavingScript(having query.Query, variable func(field string) string) (string, error) {
+	switch v := having.(type) {
+	case *query.TermQuery:
+		value, err := esHavingValue(v.Value)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s == %s", variable(v.Field), value), nil
+	case *query.RangeQuery:
+		var conditions []string
+		for _, bound := range []struct {
+			op    string
+			value any
+		}{{">", v.Gt}, {">=", v.Gte}, {"<", v.Lt}, {"<=", v.Lte}} {
+			if bound.value == nil {
+				continue
+			}
+			value, err := esHavingValue(bound.value)
+			if err != nil {
+				return "", err
+			}
+			conditions = append(conditions, fmt.Sprintf("%s %s %s", variable(v.Field), bound.op, value))
+		}
+		if len(conditions) == 0 {
+			return "true", nil
+		}
+		return strings.Join(conditions, " && "), nil
+	case *query.BoolQuery:
+		if v.MinShouldMatch != nil && *v.MinShouldMatch != 1 {
+			return "", fmt.Errorf("having does not support minimum should match on elasticsearch")
+		}
+		var conditions []string
+		for _, sub := range append(append([]query.Query{}, v.Must...), v.Filter...) {
+			condition, err := esHavingScript(sub, variable)
+			if err != nil {
+				return "", err
+			}
+			conditions = append(conditions, "("+condition+")")
+		}
+		if len(v.Should) > 0 {
+			should := make([]string, len(v.Should))
+			for i, sub := range v.Should {
+				condition, err := esHavingScript(sub, variable)
+				if err != nil {
+					return "", err
+				}
+				should[i] = "(" + condition + ")"
+			}
+			conditions = append(conditions, "("+strings.Join(should, " || ")+")")
+		}
+		for _, sub := range v.MustNot {
+			condition, err := esHavingScript(sub, variable)
+			if err != nil {
+				return "", err
+			}
+			conditions = append(conditions, "!("+condition+")")
+		}
+		if len(conditions) == 0 {
+			return "true", nil
+		}
+		return strings.Join(conditions, " && "), nil
 	}
-	
-	// 提取聚合结果
-	aggregations, ok := searchResult["aggregations"].(map[string]any)
+	return "", fmt.Errorf("having does not support %s query", having.Type())
+}
+
+// esHavingValue 将 Having 中的值格式化为 painless 的数值字面量，指标和文档数只能与数值比较
+func esHavingValue(value any) (string, error) {
+	f, ok := toFloat64(value)
 	if !ok {
-		return aggregation.NewAggregationResult(), nil
+		return "", fmt.Errorf("having value %v is not a number", value)
 	}
-	
-	// 构建聚合结果
-	result := aggregation.NewAggregationResult()
+	return strconv.FormatFloat(f, 'f', -1, 64), nil
+}
+
+// 批量操作实现
+// parseESAggregations 按聚合定义解析 ES 聚合结果，set 用于写入结果（顶层结果或桶的子聚合）
+func parseESAggregations(aggs []aggregation.Aggregation, raw map[string]any, set func(aggName string, value interface{})) {
 	for _, agg := range aggs {
 		aggName := agg.Name()
 		if aggName == "" {
 			aggName = fmt.Sprintf("%s_agg", agg.Type())
 		}
-		
-		if aggResult, exists := aggregations[aggName]; exists {
-			// 根据聚合类型解析结果
-			switch agg.Type() {
-			case aggregation.AggTypeSum, aggregation.AggTypeAvg, 
-				 aggregation.AggTypeMax, aggregation.AggTypeMin:
-				if aggMap, ok := aggResult.(map[string]any); ok {
-					if value, exists := aggMap["value"]; exists {
-						result.SetResult(aggName, value)
-					}
-				}
-			case aggregation.AggTypeCount:
-				if aggMap, ok := aggResult.(map[string]any); ok {
-					if docCount, exists := aggMap["doc_count"]; exists {
-						result.SetResult(aggName, docCount)
-					}
-				}
-			case aggregation.AggTypeTerms:
-				if aggMap, ok := aggResult.(map[string]any); ok {
-					if buckets, exists := aggMap["buckets"]; exists {
-						result.SetResult(aggName, buckets)
-					}
-				}
-			case aggregation.AggTypeDateHisto:
-				if aggMap, ok := aggResult.(map[string]any); ok {
-					if buckets, exists := aggMap["buckets"]; exists {
-						result.SetResult(aggName, buckets)
-					}
-				}
+
+		aggMap, ok := raw[aggName].(map[string]any)
+		if !ok {
+			continue
+		}
+
+		bucketAgg, ok := agg.(aggregation.BucketAggregator)
+		if !ok {
+			if value, exists := aggMap["value"]; exists {
+				set(aggName, value)
+			}
+			continue
+		}
+
+		rawBuckets, _ := aggMap["buckets"].([]any)
+		buckets := make([]aggregation.Bucket, 0, len(rawBuckets))
+		for _, rawBucket := range rawBuckets {
+			bucketMap, ok := rawBucket.(map[string]any)
+			if !ok {
+				continue
+			}
+			key := bucketMap["key"]
+			if keyString, exists := bucketMap["key_as_string"]; exists {
+				key = keyString
 			}
+			bucket := aggregation.NewBucket(key, toInt64(bucketMap["doc_count"]))
+			parseESAggregations(bucketAgg.GetSubAggregations(), bucketMap, bucket.SetSubAggregation)
+			buckets = append(buckets, bucket)
 		}
+		set(aggName, buckets)
 	}
-	
-	return result, nil
 }
 
-// 批量操作实现
 func (es *ES) BatchCreate(ctx context.Context, table string, records []Record, opts ...CreateOption) error {
+	ctx, done, err := es.ops.enter(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+	ctx, cancel := createContext(ctx, opts)
+	defer cancel()
+
 	if len(records) == 0 {
 		return nil
 	}
@@ -711,87 +2081,46 @@ func (es *ES) BatchCreate(ctx context.Context, table string, records []Record, o
 		opt(createOpts)
 	}
 	
-	// 构建批量请求体
-	var bulkBody strings.Builder
-	
+	// 构建操作
+	action := "create"
+	if createOpts.UpdateOnConflict {
+		action = "index"
+	}
+	actions := make([][]byte, 0, len(records))
 	for _, record := range records {
 		fields := record.Fields()
 		
-		// 提取文档ID（如果存在）
-		var docID string
+		meta := map[string]any{"_index": table}
 		if id, exists := fields["_id"]; exists {
-			docID = fmt.Sprintf("%v", id)
+			meta["_id"] = fmt.Sprintf("%v", id)
 			delete(fields, "_id")
 		}
 		
-		// 构建操作头
-		var action string
-		if createOpts.UpdateOnConflict {
-			action = "index"
-		} else {
-			action = "create"
-		}
-		
-		actionHeader := map[string]any{
-			action: map[string]any{
-				"_index": table,
-			},
-		}
-		
-		if docID != "" {
-			actionHeader[action].(map[string]any)["_id"] = docID
-		}
-		
-		// 写入操作头
-		headerBytes, err := json.Marshal(actionHeader)
-		if err != nil {
-			return fmt.Errorf("failed to marshal action header: %v", err)
-		}
-		bulkBody.Write(headerBytes)
-		bulkBody.WriteString("\n")
-		
-		// 写入文档内容
-		docBytes, err := json.Marshal(fields)
+		buf, err := esBulkAction(action, meta, fields)
 		if err != nil {
-			return fmt.Errorf("failed to marshal document: %v", err)
+			return err
 		}
-		bulkBody.Write(docBytes)
-		bulkBody.WriteString("\n")
-	}
-	
-	// 执行批量操作
-	req := esapi.BulkRequest{
-		Body:    strings.NewReader(bulkBody.String()),
-		Refresh: "wait_for",
+		actions = append(actions, buf)
 	}
 	
-	res, err := req.Do(ctx, es.client)
+	items, err := es.bulk(ctx, actions)
 	if err != nil {
-		return fmt.Errorf("failed to execute bulk create: %v", err)
-	}
-	defer res.Body.Close()
-	
-	if res.IsError() {
-		return fmt.Errorf("bulk create error: %s", res.String())
-	}
-	
-	// 解析批量响应
-	var bulkResult map[string]any
-	if err := json.NewDecoder(res.Body).Decode(&bulkResult); err != nil {
-		return fmt.Errorf("failed to decode bulk result: %v", err)
-	}
-	
-	// 检查是否有错误
-	if errors, ok := bulkResult["errors"].(bool); ok && errors {
-		if !createOpts.IgnoreConflict {
-			return fmt.Errorf("bulk operation contains errors")
-		}
+		return err
 	}
 	
-	return nil
+	// 忽略冲突时跳过文档已存在的错误
+	return firstBulkError("create", items, func(item esBulkItem) bool {
+		return createOpts.IgnoreConflict && item.Status == http.StatusConflict
+	})
 }
 
 func (es *ES) BatchUpdate(ctx context.Context, table string, pks []map[string]any, records []Record) error {
+	ctx, done, err := es.ops.enter(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
 	if len(pks) != len(records) {
 		return fmt.Errorf("pks and records length mismatch")
 	}
@@ -800,10 +2129,13 @@ func (es *ES) BatchUpdate(ctx context.Context, table string, pks []map[string]an
 		return nil
 	}
 	
-	// 构建批量更新请求体
-	var bulkBody strings.Builder
-	
+	actions := make([][]byte, 0, len(records))
 	for i, record := range records {
+		fields := updateFields(record)
+		if len(fields) == 0 {
+			continue
+		}
+
 		// 提取文档ID
 		var docID string
 		if id, exists := pks[i]["_id"]; exists {
@@ -814,63 +2146,44 @@ func (es *ES) BatchUpdate(ctx context.Context, table string, pks []map[string]an
 			return fmt.Errorf("document ID not found in primary key at index %d", i)
 		}
 		
-		// 构建更新操作头
-		actionHeader := map[string]any{
-			"update": map[string]any{
-				"_index": table,
-				"_id":    docID,
-			},
-		}
-		
-		// 写入操作头
-		headerBytes, err := json.Marshal(actionHeader)
-		if err != nil {
-			return fmt.Errorf("failed to marshal action header: %v", err)
+		meta := map[string]any{
+			"_index": table,
+			"_id":    docID,
 		}
-		bulkBody.Write(headerBytes)
-		bulkBody.WriteString("\n")
-		
-		// 构建更新文档
-		updateDoc := map[string]any{
-			"doc": record.Fields(),
+		if retries := es.retryOnConflict(); retries != nil {
+			meta["retry_on_conflict"] = *retries
 		}
 		
-		// 写入更新内容
-		docBytes, err := json.Marshal(updateDoc)
+		buf, err := esBulkAction("update", meta, map[string]any{"doc": fields})
 		if err != nil {
-			return fmt.Errorf("failed to marshal update document: %v", err)
+			return err
 		}
-		bulkBody.Write(docBytes)
-		bulkBody.WriteString("\n")
+		actions = append(actions, buf)
 	}
-	
-	// 执行批量更新
-	req := esapi.BulkRequest{
-		Body:    strings.NewReader(bulkBody.String()),
-		Refresh: "wait_for",
+	if len(actions) == 0 {
+		return nil
 	}
 	
-	res, err := req.Do(ctx, es.client)
+	items, err := es.bulk(ctx, actions)
 	if err != nil {
-		return fmt.Errorf("failed to execute bulk update: %v", err)
-	}
-	defer res.Body.Close()
-	
-	if res.IsError() {
-		return fmt.Errorf("bulk update error: %s", res.String())
+		return err
 	}
 	
-	return nil
+	return firstBulkError("update", items, nil)
 }
 
 func (es *ES) BatchDelete(ctx context.Context, table string, pks []map[string]any) error {
+	ctx, done, err := es.ops.enter(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
 	if len(pks) == 0 {
 		return nil
 	}
 	
-	// 构建批量删除请求体
-	var bulkBody strings.Builder
-	
+	actions := make([][]byte, 0, len(pks))
 	for _, pk := range pks {
 		// 提取文档ID
 		var docID string
@@ -882,44 +2195,41 @@ func (es *ES) BatchDelete(ctx context.Context, table string, pks []map[string]an
 			return fmt.Errorf("document ID not found in primary key")
 		}
 		
-		// 构建删除操作头
-		actionHeader := map[string]any{
-			"delete": map[string]any{
-				"_index": table,
-				"_id":    docID,
-			},
-		}
-		
-		// 写入操作头
-		headerBytes, err := json.Marshal(actionHeader)
+		buf, err := esBulkAction("delete", map[string]any{
+			"_index": table,
+			"_id":    docID,
+		}, nil)
 		if err != nil {
-			return fmt.Errorf("failed to marshal action header: %v", err)
+			return err
 		}
-		bulkBody.Write(headerBytes)
-		bulkBody.WriteString("\n")
-	}
-	
-	// 执行批量删除
-	req := esapi.BulkRequest{
-		Body:    strings.NewReader(bulkBody.String()),
-		Refresh: "wait_for",
+		actions = append(actions, buf)
 	}
 	
-	res, err := req.Do(ctx, es.client)
+	items, err := es.bulk(ctx, actions)
 	if err != nil {
-		return fmt.Errorf("failed to execute bulk delete: %v", err)
-	}
-	defer res.Body.Close()
-	
-	if res.IsError() {
-		return fmt.Errorf("bulk delete error: %s", res.String())
+		return err
 	}
 	
-	return nil
+	return firstBulkError("delete", items, nil)
 }
 
 // 事务支持实现（ES不支持传统事务，使用文档版本控制模拟）
+
+// BeginTx 开启事务，提交或回滚前算作进行中的操作
 func (es *ES) BeginTx(ctx context.Context) (Transaction, error) {
+	ctx, done, err := es.ops.enter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := es.beginTx(ctx)
+	if err != nil {
+		done()
+		return nil, err
+	}
+	return newTrackedTransaction(ctx, tx, done, es.txLeaks), nil
+}
+
+func (es *ES) beginTx(ctx context.Context) (Transaction, error) {
 	// Elasticsearch不支持传统的ACID事务
 	// 这里返回一个模拟的事务实现，主要用于批量操作的一致性
 	return &ESTransaction{
@@ -956,6 +2266,11 @@ type ESOperation struct {
 	DocID  string
 	Data   map[string]any
 	PK     map[string]any
+	Script map[string]any
+
+	// 条件更新的序列号和主分片任期，用于乐观锁
+	IfSeqNo       *int
+	IfPrimaryTerm *int
 }
 
 // ESTransaction ES事务实现（模拟）
@@ -1006,76 +2321,74 @@ func (tx *ESTransaction) executeBulkOperations(ctx context.Context) error {
 		return nil
 	}
 
-	// 构建批量请求体
-	var bulkBody strings.Builder
-
+	actions := make([][]byte, 0, len(tx.operations))
+	types := make([]string, 0, len(tx.operations))
 	for _, op := range tx.operations {
+		var buf []byte
+		var err error
 		switch op.Type {
 		case "create":
-			actionHeader := map[string]any{
-				"create": map[string]any{
-					"_index": op.Table,
-				},
-			}
+			meta := map[string]any{"_index": op.Table}
 			if op.DocID != "" {
-				actionHeader["create"].(map[string]any)["_id"] = op.DocID
+				meta["_id"] = op.DocID
 			}
+			buf, err = esBulkAction("create", meta, op.Data)
 
-			headerBytes, _ := json.Marshal(actionHeader)
-			bulkBody.Write(headerBytes)
-			bulkBody.WriteString("\n")
-
-			docBytes, _ := json.Marshal(op.Data)
-			bulkBody.Write(docBytes)
-			bulkBody.WriteString("\n")
+		case "index":
+			buf, err = esBulkAction("index", map[string]any{
+				"_index": op.Table,
+				"_id":    op.DocID,
+			}, op.Data)
 
 		case "update":
-			actionHeader := map[string]any{
-				"update": map[string]any{
-					"_index": op.Table,
-					"_id":    op.DocID,
-				},
+			meta := map[string]any{
+				"_index": op.Table,
+				"_id":    op.DocID,
+			}
+			if op.IfSeqNo != nil && op.IfPrimaryTerm != nil {
+				meta["if_seq_no"] = *op.IfSeqNo
+				meta["if_primary_term"] = *op.IfPrimaryTerm
+			} else if retries := tx.es.retryOnConflict(); retries != nil {
+				meta["retry_on_conflict"] = *retries
 			}
-
-			headerBytes, _ := json.Marshal(actionHeader)
-			bulkBody.Write(headerBytes)
-			bulkBody.WriteString("\n")
 
 			updateDoc := map[string]any{"doc": op.Data}
-			docBytes, _ := json.Marshal(updateDoc)
-			bulkBody.Write(docBytes)
-			bulkBody.WriteString("\n")
+			if op.Script != nil {
+				updateDoc = map[string]any{"script": op.Script}
+			}
+			buf, err = esBulkAction("update", meta, updateDoc)
 
 		case "delete":
-			actionHeader := map[string]any{
-				"delete": map[string]any{
-					"_index": op.Table,
-					"_id":    op.DocID,
-				},
-			}
+			buf, err = esBulkAction("delete", map[string]any{
+				"_index": op.Table,
+				"_id":    op.DocID,
+			}, nil)
 
-			headerBytes, _ := json.Marshal(actionHeader)
-			bulkBody.Write(headerBytes)
-			bulkBody.WriteString("\n")
+		default:
+			continue
 		}
+		if err != nil {
+			return err
+		}
+		actions = append(actions, buf)
+		types = append(types, op.Type)
 	}
 
-	// 执行批量操作
-	req := esapi.BulkRequest{
-		Body:    strings.NewReader(bulkBody.String()),
-		Refresh: "wait_for",
-	}
-
-	res, err := req.Do(ctx, tx.es.client)
+	items, err := tx.es.bulk(ctx, actions)
 	if err != nil {
-		return fmt.Errorf("failed to execute bulk operations: %v", err)
+		return err
 	}
-	defer res.Body.Close()
 
-	if res.IsError() {
-		return fmt.Errorf("bulk operations error: %s", res.String())
-	}
+	return checkBulkVersionConflict(types, items)
+}
 
+// checkBulkVersionConflict 检查批量操作中是否有条件更新因文档已被修改而失败，types 为各操作的类型
+func checkBulkVersionConflict(types []string, items []esBulkItem) error {
+	for i, item := range items {
+		if types[i] == "update" && item.Status == http.StatusConflict {
+			return ErrVersionConflict
+		}
+	}
 	return nil
 }
 
@@ -1105,16 +2418,17 @@ func (tx *ESTransaction) Create(ctx context.Context, table string, record Record
 	return nil
 }
 
-func (tx *ESTransaction) Get(ctx context.Context, table string, pk map[string]any) (Record, error) {
+func (tx *ESTransaction) Get(ctx context.Context, table string, pk map[string]any, opts ...QueryOption) (Record, error) {
 	if tx.committed || tx.rolledBack {
 		return nil, fmt.Errorf("transaction is not active")
 	}
 
 	// 在事务中，直接调用ES的Get方法
-	return tx.es.Get(ctx, table, pk)
+	return tx.es.Get(ctx, table, pk, opts...)
 }
 
-func (tx *ESTransaction) Update(ctx context.Context, table string, pk map[string]any, record Record) error {
+// Update 将更新加入操作队列，启用乐观锁时立即校验版本，提交时按读取时的序列号条件更新
+func (tx *ESTransaction) Update(ctx context.Context, table string, pk map[string]any, record Record, opts ...UpdateOption) error {
 	if tx.committed || tx.rolledBack {
 		return fmt.Errorf("transaction is not active")
 	}
@@ -1129,14 +2443,87 @@ func (tx *ESTransaction) Update(ctx context.Context, table string, pk map[string
 		return fmt.Errorf("document ID not found in primary key")
 	}
 
+	operation := ESOperation{
+		Type:  "update",
+		Table: table,
+		DocID: docID,
+		Data:  updateFields(record),
+		PK:    pk,
+	}
+	options := newUpdateOptions(opts)
+	if len(operation.Data) == 0 && !options.versioned() {
+		return nil
+	}
+	if options.versioned() {
+		doc, seqNo, primaryTerm, err := tx.es.prepareVersionedUpdate(ctx, table, pk, operation.Data, options)
+		if err != nil {
+			return err
+		}
+		operation.Data = doc
+		operation.IfSeqNo = &seqNo
+		operation.IfPrimaryTerm = &primaryTerm
+	}
+
 	// 添加到操作队列
+	tx.operations = append(tx.operations, operation)
+
+	return nil
+}
+
+func (tx *ESTransaction) UpdatePartial(ctx context.Context, table string, pk map[string]any, fields map[string]any, opts ...UpdateOption) error {
+	if len(fields) == 0 && !newUpdateOptions(opts).versioned() {
+		return fmt.Errorf("no fields to update")
+	}
+	return tx.Update(ctx, table, pk, &ESRecord{source: fields}, opts...)
+}
+
+func (tx *ESTransaction) Upsert(ctx context.Context, table string, pk map[string]any, record Record) error {
+	if tx.committed || tx.rolledBack {
+		return fmt.Errorf("transaction is not active")
+	}
+
+	docID, fields, err := esUpsertDocument(pk, record)
+	if err != nil {
+		return err
+	}
+
+	// 添加到操作队列，提交时以 index 操作执行
 	tx.operations = append(tx.operations, ESOperation{
-		Type:  "update",
+		Type:  "index",
 		Table: table,
 		DocID: docID,
-		Data:  record.Fields(),
+		Data:  fields,
 		PK:    pk,
 	})
+	return nil
+}
+
+func (tx *ESTransaction) Increment(ctx context.Context, table string, pk map[string]any, field string, delta any) error {
+	if tx.committed || tx.rolledBack {
+		return fmt.Errorf("transaction is not active")
+	}
+	if err := validateIncrementDelta(delta); err != nil {
+		return err
+	}
+
+	// 提取文档ID
+	var docID string
+	if id, exists := pk["_id"]; exists {
+		docID = fmt.Sprintf("%v", id)
+	} else if id, exists := pk["id"]; exists {
+		docID = fmt.Sprintf("%v", id)
+	} else {
+		return fmt.Errorf("document ID not found in primary key")
+	}
+
+	// 添加到操作队列，提交时以脚本更新执行
+	tx.operations = append(tx.operations, ESOperation{
+		Type:   "update",
+		Table:  table,
+		DocID:  docID,
+		PK:     pk,
+		Script: esIncrementScript(field, delta),
+	})
 
 	return nil
 }
@@ -1175,6 +2562,27 @@ func (tx *ESTransaction) Find(ctx context.Context, table string, query query.Que
 	return tx.es.Find(ctx, table, query, opts...)
 }
 
+func (tx *ESTransaction) FindStream(ctx context.Context, table string, query query.Query, opts ...QueryOption) (RecordCursor, error) {
+	if tx.committed || tx.rolledBack {
+		return nil, fmt.Errorf("transaction is not active")
+	}
+	return tx.es.FindStream(ctx, table, query, opts...)
+}
+
+func (tx *ESTransaction) Count(ctx context.Context, table string, query query.Query) (int64, error) {
+	if tx.committed || tx.rolledBack {
+		return 0, fmt.Errorf("transaction is not active")
+	}
+	return tx.es.Count(ctx, table, query)
+}
+
+func (tx *ESTransaction) Exists(ctx context.Context, table string, pk map[string]any) (bool, error) {
+	if tx.committed || tx.rolledBack {
+		return false, fmt.Errorf("transaction is not active")
+	}
+	return tx.es.Exists(ctx, table, pk)
+}
+
 func (tx *ESTransaction) Aggregate(ctx context.Context, table string, query query.Query, aggs []aggregation.Aggregation, opts ...QueryOption) (aggregation.AggregationResult, error) {
 	if tx.committed || tx.rolledBack {
 		return nil, fmt.Errorf("transaction is not active")
@@ -1245,14 +2653,21 @@ func (tx *ESTransaction) GetBuilder() RecordBuilder {
 	return tx.es.builder
 }
 
+func (tx *ESTransaction) Health(ctx context.Context) error {
+	if tx.committed || tx.rolledBack {
+		return fmt.Errorf("transaction is not active")
+	}
+	return tx.es.Health(ctx)
+}
+
 func (tx *ESTransaction) Close() error {
 	return nil
 }
 
 
 
-// ES 特定的结构体转换为 map 函数
-func esStructToMap(v any) map[string]any {
+// ES 特定的结构体转换为 map 函数，tagName 为空时使用 rdb 标签
+func esStructToMap(v any, tagName string) map[string]any {
 	result := make(map[string]any)
 	rv := reflect.ValueOf(v)
 	if rv.Kind() == reflect.Ptr {
@@ -1269,29 +2684,20 @@ func esStructToMap(v any) map[string]any {
 			continue
 		}
 
-		// 检查 rdb 标签
-		tag := field.Tag.Get("rdb")
+		// 检查 tagName 标签
+		tag := field.Tag.Get(tagNameOrDefault(tagName))
 		if tag == "-" {
 			continue // 跳过被忽略的字段
 		}
 
-		fieldName := field.Name
-		if tag != "" {
-			if idx := strings.Index(tag, ","); idx != -1 {
-				fieldName = tag[:idx]
-			} else {
-				fieldName = tag
-			}
-		}
-
 		value := rv.Field(i).Interface()
-		result[fieldName] = value
+		result[tagColumn(field, tag)] = value
 	}
 	return result
 }
 
-// ES 特定的 map 转换为结构体函数
-func esMapToStruct(data map[string]any, dest any) error {
+// ES 特定的 map 转换为结构体函数，tagName 为空时使用 rdb 标签
+func esMapToStruct(data map[string]any, dest any, tagName string) error {
 	rv := reflect.ValueOf(dest)
 	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
 		return fmt.Errorf("dest must be a pointer to struct")
@@ -1306,19 +2712,12 @@ func esMapToStruct(data map[string]any, dest any) error {
 			continue
 		}
 
-		fieldName := field.Name
-		if tag := field.Tag.Get("rdb"); tag != "" && tag != "-" {
-			if idx := strings.Index(tag, ","); idx != -1 {
-				fieldName = tag[:idx]
-			} else {
-				fieldName = tag
-			}
-		}
+		fieldName := tagColumn(field, field.Tag.Get(tagNameOrDefault(tagName)))
 
 		if value, exists := data[fieldName]; exists && value != nil {
 			fieldValue := rv.Field(i)
 			if fieldValue.CanSet() {
-				if err := setESFieldValue(fieldValue, value); err != nil {
+				if err := setESFieldValue(fieldValue, value, tagName); err != nil {
 					return fmt.Errorf("failed to set field %s: %v", fieldName, err)
 				}
 			}
@@ -1327,8 +2726,8 @@ func esMapToStruct(data map[string]any, dest any) error {
 	return nil
 }
 
-// 辅助函数：设置ES字段值
-func setESFieldValue(fieldValue reflect.Value, value any) error {
+// 辅助函数：设置ES字段值，嵌套结构体使用同一个标签
+func setESFieldValue(fieldValue reflect.Value, value any, tagName string) error {
 	if value == nil {
 		return nil
 	}
@@ -1408,7 +2807,7 @@ func setESFieldValue(fieldValue reflect.Value, value any) error {
 	if fieldType.Kind() == reflect.Struct && valueType.Kind() == reflect.Map {
 		if mapValue, ok := value.(map[string]any); ok {
 			newStruct := reflect.New(fieldType).Elem()
-			if err := esMapToStruct(mapValue, newStruct.Addr().Interface()); err != nil {
+			if err := esMapToStruct(mapValue, newStruct.Addr().Interface(), tagName); err != nil {
 				return err
 			}
 			fieldValue.Set(newStruct)
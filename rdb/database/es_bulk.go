@@ -0,0 +1,182 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// esRetryBackoff 请求重试的等待时间，从 base 开始每次翻倍，base 为 0 时不等待
+func esRetryBackoff(base time.Duration) func(attempt int) time.Duration {
+	return func(attempt int) time.Duration {
+		if base <= 0 || attempt < 1 {
+			return 0
+		}
+		if attempt > 16 {
+			attempt = 16
+		}
+		return base << (attempt - 1)
+	}
+}
+
+// refresh 写操作的刷新策略，未配置时等待刷新后返回
+func (es *ES) refresh() string {
+	if es.options.Refresh == "" {
+		return "wait_for"
+	}
+	return es.options.Refresh
+}
+
+// retryOnConflict 非乐观锁更新的冲突重试次数，未配置时返回 nil
+func (es *ES) retryOnConflict() *int {
+	if es.options.RetryOnConflict <= 0 {
+		return nil
+	}
+	retries := es.options.RetryOnConflict
+	return &retries
+}
+
+// esBulkItem 批量操作中单个操作的结果
+type esBulkItem struct {
+	Status int             `json:"status"`
+	Error  json.RawMessage `json:"error,omitempty"`
+}
+
+// failed 操作是否失败，删除不存在的文档返回 404 但没有 error，不算失败
+func (item esBulkItem) failed() bool {
+	return len(item.Error) > 0
+}
+
+// esBulkAction 构建批量请求中的一个操作，doc 为 nil 时只有操作头
+func esBulkAction(action string, meta map[string]any, doc any) ([]byte, error) {
+	header, err := json.Marshal(map[string]any{action: meta})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal action header: %v", err)
+	}
+	buf := append(header, '\n')
+	if doc == nil {
+		return buf, nil
+	}
+
+	body, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal document: %v", err)
+	}
+	buf = append(buf, body...)
+	return append(buf, '\n'), nil
+}
+
+// bulk 执行批量操作，返回与 actions 一一对应的结果
+// 操作按 BulkMaxActions 和 BulkMaxBytes 拆分为多个请求依次提交；
+// 集群繁忙时单个操作会被 429 拒绝而整个请求仍然成功，这些操作按 RetryBackoff 退避后重新提交，最多重试 MaxRetries 次
+func (es *ES) bulk(ctx context.Context, actions [][]byte) ([]esBulkItem, error) {
+	items := make([]esBulkItem, len(actions))
+	pending := make([]int, len(actions))
+	for i := range pending {
+		pending[i] = i
+	}
+
+	retryBackoff := esRetryBackoff(es.options.RetryBackoff)
+	for attempt := 1; ; attempt++ {
+		var rejected []int
+		for _, chunk := range es.bulkChunks(actions, pending) {
+			chunkItems, err := es.doBulk(ctx, actions, chunk)
+			if err != nil {
+				return nil, err
+			}
+			for j, i := range chunk {
+				items[i] = chunkItems[j]
+				if chunkItems[j].Status == http.StatusTooManyRequests {
+					rejected = append(rejected, i)
+				}
+			}
+		}
+		if len(rejected) == 0 || attempt > es.options.MaxRetries {
+			return items, nil
+		}
+
+		pending = rejected
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(retryBackoff(attempt)):
+		}
+	}
+}
+
+// bulkChunks 按操作数和请求体大小将待提交的操作拆分为多组，单个操作超过大小限制时单独成组
+func (es *ES) bulkChunks(actions [][]byte, pending []int) [][]int {
+	var chunks [][]int
+	var chunk []int
+	size := 0
+	for _, i := range pending {
+		full := es.options.BulkMaxActions > 0 && len(chunk) >= es.options.BulkMaxActions
+		oversize := es.options.BulkMaxBytes > 0 && size+len(actions[i]) > es.options.BulkMaxBytes
+		if len(chunk) > 0 && (full || oversize) {
+			chunks = append(chunks, chunk)
+			chunk, size = nil, 0
+		}
+		chunk = append(chunk, i)
+		size += len(actions[i])
+	}
+	if len(chunk) > 0 {
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+// doBulk 提交一个批量请求，返回与 chunk 一一对应的结果
+func (es *ES) doBulk(ctx context.Context, actions [][]byte, chunk []int) ([]esBulkItem, error) {
+	var body bytes.Buffer
+	for _, i := range chunk {
+		body.Write(actions[i])
+	}
+
+	req := esapi.BulkRequest{
+		Body:    &body,
+		Refresh: es.refresh(),
+	}
+
+	res, err := req.Do(ctx, es.getClient())
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute bulk request: %v", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return nil, newESResponseError("bulk request error", res)
+	}
+
+	var result struct {
+		Items []map[string]esBulkItem `json:"items"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode bulk response: %v", err)
+	}
+	if len(result.Items) != len(chunk) {
+		return nil, fmt.Errorf("bulk response has %d items, expected %d", len(result.Items), len(chunk))
+	}
+
+	items := make([]esBulkItem, len(chunk))
+	for j, item := range result.Items {
+		for _, v := range item {
+			items[j] = v
+		}
+	}
+	return items, nil
+}
+
+// firstBulkError 返回第一个失败操作的错误，skip 返回 true 的操作不算失败
+func firstBulkError(op string, items []esBulkItem, skip func(item esBulkItem) bool) error {
+	for i, item := range items {
+		if item.failed() && (skip == nil || !skip(item)) {
+			return fmt.Errorf("bulk %s failed at index %d: status %d, %s", op, i, item.Status, item.Error)
+		}
+	}
+	return nil
+}
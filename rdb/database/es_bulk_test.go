@@ -0,0 +1,297 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// fakeESRequest 模拟服务收到的请求
+type fakeESRequest struct {
+	Method string
+	Path   string
+	Query  string
+	Body   string
+}
+
+// fakeES 模拟 Elasticsearch 的 HTTP 服务，记录除 Info 以外的请求，由 handler 返回响应
+type fakeES struct {
+	server   *httptest.Server
+	mu       sync.Mutex
+	requests []fakeESRequest
+}
+
+func newFakeES(handler func(w http.ResponseWriter, r *http.Request, body string)) *fakeES {
+	f := &fakeES{}
+	f.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodGet && r.URL.Path == "/" {
+			io.WriteString(w, `{"version":{"number":"8.19.0"}}`)
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		f.mu.Lock()
+		f.requests = append(f.requests, fakeESRequest{Method: r.Method, Path: r.URL.Path, Query: r.URL.RawQuery, Body: string(body)})
+		f.mu.Unlock()
+		handler(w, r, string(body))
+	}))
+	return f
+}
+
+func (f *fakeES) options() *ESOptions {
+	return &ESOptions{Addresses: []string{f.server.URL}, Timeout: 5 * time.Second, MaxRetries: 3, RetryBackoff: time.Millisecond}
+}
+
+func (f *fakeES) paths() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	paths := make([]string, len(f.requests))
+	for i, req := range f.requests {
+		paths[i] = req.Method + " " + req.Path
+	}
+	return paths
+}
+
+// bulkResponse 为批量请求中的每个操作返回 status 给出的状态码
+func bulkResponse(w http.ResponseWriter, body string, status func(i int, action string, meta map[string]any) int) {
+	var items []map[string]any
+	errors := false
+	lines := strings.Split(strings.TrimSpace(body), "\n")
+	for i := 0; i < len(lines); i++ {
+		var header map[string]map[string]any
+		json.Unmarshal([]byte(lines[i]), &header)
+		for action, meta := range header {
+			code := status(len(items), action, meta)
+			item := map[string]any{"_id": meta["_id"], "status": code}
+			if code >= 300 {
+				item["error"] = map[string]any{"type": http.StatusText(code)}
+				errors = true
+			}
+			items = append(items, map[string]any{action: item})
+			if action != "delete" {
+				i++
+			}
+		}
+	}
+	json.NewEncoder(w).Encode(map[string]any{"errors": errors, "items": items})
+}
+
+func TestESBulk(t *testing.T) {
+	Convey("测试 ES 批量操作", t, func() {
+		ctx := context.Background()
+		records := func(n int) []Record {
+			var records []Record
+			for i := 0; i < n; i++ {
+				records = append(records, (&ESRecordBuilder{}).FromMap(map[string]any{"_id": i, "name": "user"}, "users"))
+			}
+			return records
+		}
+
+		Convey("按操作数和大小拆分请求", func() {
+			var sizes []int
+			var refresh string
+			fake := newFakeES(func(w http.ResponseWriter, r *http.Request, body string) {
+				sizes = append(sizes, strings.Count(body, "\n")/2)
+				refresh = r.URL.Query().Get("refresh")
+				bulkResponse(w, body, func(i int, action string, meta map[string]any) int { return 201 })
+			})
+			defer fake.server.Close()
+
+			options := fake.options()
+			options.BulkMaxActions = 2
+			options.Refresh = "false"
+			es, err := NewESWithOptions(options)
+			So(err, ShouldBeNil)
+			defer es.Close()
+
+			So(es.BatchCreate(ctx, "users", records(5)), ShouldBeNil)
+			So(sizes, ShouldResemble, []int{2, 2, 1})
+			So(refresh, ShouldEqual, "false")
+
+			sizes = nil
+			es.options.BulkMaxActions = 0
+			es.options.BulkMaxBytes = 100
+			So(es.BatchCreate(ctx, "users", records(5)), ShouldBeNil)
+			So(sizes, ShouldResemble, []int{1, 1, 1, 1, 1})
+		})
+
+		Convey("重试被 429 拒绝的操作", func() {
+			attempts := 0
+			var retried []string
+			fake := newFakeES(func(w http.ResponseWriter, r *http.Request, body string) {
+				attempts++
+				bulkResponse(w, body, func(i int, action string, meta map[string]any) int {
+					if attempts > 1 {
+						retried = append(retried, meta["_id"].(string))
+						return 201
+					}
+					if i == 1 || i == 2 {
+						return http.StatusTooManyRequests
+					}
+					return 201
+				})
+			})
+			defer fake.server.Close()
+
+			es, err := NewESWithOptions(fake.options())
+			So(err, ShouldBeNil)
+			defer es.Close()
+
+			So(es.BatchCreate(ctx, "users", records(4)), ShouldBeNil)
+			So(attempts, ShouldEqual, 2)
+			So(retried, ShouldResemble, []string{"1", "2"})
+		})
+
+		Convey("重试次数用完后返回错误", func() {
+			fake := newFakeES(func(w http.ResponseWriter, r *http.Request, body string) {
+				bulkResponse(w, body, func(i int, action string, meta map[string]any) int { return http.StatusTooManyRequests })
+			})
+			defer fake.server.Close()
+
+			options := fake.options()
+			options.MaxRetries = 1
+			es, err := NewESWithOptions(options)
+			So(err, ShouldBeNil)
+			defer es.Close()
+
+			err = es.BatchDelete(ctx, "users", []map[string]any{{"_id": 1}})
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "status 429")
+			So(len(fake.paths()), ShouldEqual, 2)
+		})
+
+		Convey("忽略冲突时只跳过文档已存在的错误", func() {
+			fake := newFakeES(func(w http.ResponseWriter, r *http.Request, body string) {
+				bulkResponse(w, body, func(i int, action string, meta map[string]any) int {
+					if i == 0 {
+						return http.StatusConflict
+					}
+					return 201
+				})
+			})
+			defer fake.server.Close()
+
+			es, err := NewESWithOptions(fake.options())
+			So(err, ShouldBeNil)
+			defer es.Close()
+
+			So(es.BatchCreate(ctx, "users", records(2), WithIgnoreConflict()), ShouldBeNil)
+			So(es.BatchCreate(ctx, "users", records(2)), ShouldNotBeNil)
+		})
+
+		Convey("冲突重试和事务中的版本冲突", func() {
+			var metas []map[string]any
+			fake := newFakeES(func(w http.ResponseWriter, r *http.Request, body string) {
+				bulkResponse(w, body, func(i int, action string, meta map[string]any) int {
+					metas = append(metas, meta)
+					if _, ok := meta["if_seq_no"]; ok {
+						return http.StatusConflict
+					}
+					return 200
+				})
+			})
+			defer fake.server.Close()
+
+			options := fake.options()
+			options.RetryOnConflict = 3
+			es, err := NewESWithOptions(options)
+			So(err, ShouldBeNil)
+			defer es.Close()
+
+			So(es.BatchUpdate(ctx, "users", []map[string]any{{"_id": 1}}, records(1)), ShouldBeNil)
+			So(metas[0]["retry_on_conflict"], ShouldEqual, 3)
+
+			seqNo, primaryTerm := 1, 1
+			tx := &ESTransaction{es: es, operations: []ESOperation{
+				{Type: "update", Table: "users", DocID: "1", Data: map[string]any{"name": "a"}},
+				{Type: "update", Table: "users", DocID: "2", Data: map[string]any{"name": "b"}, IfSeqNo: &seqNo, IfPrimaryTerm: &primaryTerm},
+			}}
+			So(tx.Commit(), ShouldEqual, ErrVersionConflict)
+			So(metas[1]["retry_on_conflict"], ShouldEqual, 3)
+			So(metas[2], ShouldNotContainKey, "retry_on_conflict")
+		})
+	})
+}
+
+func TestESHardening(t *testing.T) {
+	Convey("测试请求返回 429 时重试", t, func() {
+		calls := 0
+		fake := newFakeES(func(w http.ResponseWriter, r *http.Request, body string) {
+			calls++
+			if calls == 1 {
+				w.WriteHeader(http.StatusTooManyRequests)
+				io.WriteString(w, `{"error":"too many requests"}`)
+				return
+			}
+			io.WriteString(w, `{"_id":"1","found":true,"_seq_no":1,"_primary_term":1,"_source":{"name":"alice"}}`)
+		})
+		defer fake.server.Close()
+
+		es, err := NewESWithOptions(fake.options())
+		So(err, ShouldBeNil)
+		defer es.Close()
+
+		record, err := es.Get(context.Background(), "users", map[string]any{"_id": 1})
+		So(err, ShouldBeNil)
+		So(record.Fields()["name"], ShouldEqual, "alice")
+		So(calls, ShouldEqual, 2)
+	})
+
+	Convey("测试启用索引别名", t, func() {
+		var bodies []string
+		fake := newFakeES(func(w http.ResponseWriter, r *http.Request, body string) {
+			bodies = append(bodies, body)
+			switch {
+			case r.Method == http.MethodHead:
+				w.WriteHeader(http.StatusNotFound)
+			case r.URL.Path == "/_alias/users":
+				io.WriteString(w, `{"users_v1":{"aliases":{"users":{}}}}`)
+			default:
+				io.WriteString(w, `{"acknowledged":true}`)
+			}
+		})
+		defer fake.server.Close()
+
+		options := fake.options()
+		options.IndexAlias = true
+		es, err := NewESWithOptions(options)
+		So(err, ShouldBeNil)
+		defer es.Close()
+
+		ctx := context.Background()
+		So(es.Migrate(ctx, &TableModel{
+			Table:  "users",
+			Fields: []FieldDefinition{{Name: "name", Type: FieldTypeString}},
+			Views:  []ViewDefinition{{Name: "active_users"}},
+		}), ShouldBeNil)
+		So(es.DropTable(ctx, "users"), ShouldBeNil)
+
+		So(fake.paths(), ShouldResemble, []string{
+			"HEAD /users",
+			"PUT /users_v1",
+			"GET /_alias/users",
+			"POST /_aliases",
+			"GET /_alias/users",
+			"DELETE /users_v1",
+		})
+		So(bodies[1], ShouldContainSubstring, `"aliases":{"users":{"is_write_index":true}}`)
+		So(bodies[3], ShouldContainSubstring, `"indices":["users_v1"]`)
+	})
+
+	Convey("测试重试等待时间", t, func() {
+		backoff := esRetryBackoff(100 * time.Millisecond)
+		So(backoff(1), ShouldEqual, 100*time.Millisecond)
+		So(backoff(3), ShouldEqual, 400*time.Millisecond)
+		So(esRetryBackoff(0)(3), ShouldEqual, 0)
+	})
+}
@@ -3,6 +3,8 @@ package database
 import (
 	"context"
 	"fmt"
+	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -27,16 +29,32 @@ func (u TestESUser) Table() string {
 	return "users"
 }
 
-// 测试配置
+// 测试配置，通过环境变量 ES_TEST_ADDRESSES 指定以逗号分隔的集群地址
 var testESOptions = &ESOptions{
-	Addresses:  []string{"http://localhost:9200"},
+	Addresses:  esTestAddresses(),
 	Timeout:    30 * time.Second,
 	MaxRetries: 3,
 }
 
+func esTestAddresses() []string {
+	if addresses := os.Getenv("ES_TEST_ADDRESSES"); addresses != "" {
+		return strings.Split(addresses, ",")
+	}
+	return []string{"http://localhost:9200"}
+}
+
+// esConvey 设置了 ES_TEST_ADDRESSES 时在指定的集群上执行测试，否则跳过
+func esConvey(items ...any) {
+	if os.Getenv("ES_TEST_ADDRESSES") == "" {
+		SkipConvey(items...)
+		return
+	}
+	Convey(items...)
+}
+
 func TestNewESWithOptions(t *testing.T) {
 	Convey("测试 NewESWithOptions 方法", t, func() {
-		SkipConvey("跳过 ES 测试 - 需要运行中的 Elasticsearch 实例", func() {
+		esConvey("需要运行中的 Elasticsearch 实例", func() {
 			Convey("使用完整配置创建连接", func() {
 				es, err := NewESWithOptions(testESOptions)
 				So(err, ShouldBeNil)
@@ -50,7 +68,7 @@ func TestNewESWithOptions(t *testing.T) {
 
 			Convey("使用认证配置", func() {
 				options := &ESOptions{
-					Addresses:  []string{"http://localhost:9200"},
+					Addresses:  esTestAddresses(),
 					Username:   "elastic",
 					Password:   "password",
 					Timeout:    30 * time.Second,
@@ -66,7 +84,7 @@ func TestNewESWithOptions(t *testing.T) {
 
 			Convey("使用 API Key 认证", func() {
 				options := &ESOptions{
-					Addresses:  []string{"http://localhost:9200"},
+					Addresses:  esTestAddresses(),
 					APIKey:     "test-api-key",
 					Timeout:    30 * time.Second,
 					MaxRetries: 3,
@@ -201,7 +219,7 @@ func TestESStructToMap(t *testing.T) {
 				Tags:  []string{"developer"},
 			}
 
-			result := esStructToMap(user)
+			result := esStructToMap(user, DefaultTagName)
 			So(result["id"], ShouldEqual, "user1")
 			So(result["name"], ShouldEqual, "John Doe")
 			So(result["email"], ShouldEqual, "john@example.com")
@@ -215,13 +233,13 @@ func TestESStructToMap(t *testing.T) {
 				Name: "John Doe",
 			}
 
-			result := esStructToMap(user)
+			result := esStructToMap(user, DefaultTagName)
 			So(result["id"], ShouldEqual, "user1")
 			So(result["name"], ShouldEqual, "John Doe")
 		})
 
 		Convey("非结构体类型", func() {
-			result := esStructToMap("not a struct")
+			result := esStructToMap("not a struct", DefaultTagName)
 			So(len(result), ShouldEqual, 0)
 		})
 	})
@@ -242,7 +260,7 @@ func TestESMapToStruct(t *testing.T) {
 			}
 
 			var user TestESUser
-			err := esMapToStruct(data, &user)
+			err := esMapToStruct(data, &user, DefaultTagName)
 			So(err, ShouldBeNil)
 			So(user.ID, ShouldEqual, "user1")
 			So(user.Name, ShouldEqual, "John Doe")
@@ -256,14 +274,14 @@ func TestESMapToStruct(t *testing.T) {
 		Convey("目标不是指针", func() {
 			data := map[string]any{"id": "user1"}
 			var user TestESUser
-			err := esMapToStruct(data, user)
+			err := esMapToStruct(data, user, DefaultTagName)
 			So(err, ShouldNotBeNil)
 		})
 
 		Convey("目标不是结构体指针", func() {
 			data := map[string]any{"value": 1}
 			var value int
-			err := esMapToStruct(data, &value)
+			err := esMapToStruct(data, &value, DefaultTagName)
 			So(err, ShouldNotBeNil)
 		})
 	})
@@ -271,7 +289,7 @@ func TestESMapToStruct(t *testing.T) {
 
 func TestESMigrate(t *testing.T) {
 	Convey("测试 ES Migrate 方法", t, func() {
-		SkipConvey("跳过 ES 测试 - 需要运行中的 Elasticsearch 实例", func() {
+		esConvey("需要运行中的 Elasticsearch 实例", func() {
 			es, err := NewESWithOptions(testESOptions)
 			So(err, ShouldBeNil)
 			defer es.Close()
@@ -323,7 +341,7 @@ func TestESMigrate(t *testing.T) {
 
 func TestESCRUDOperations(t *testing.T) {
 	Convey("测试 ES CRUD 操作", t, func() {
-		SkipConvey("跳过 ES 测试 - 需要运行中的 Elasticsearch 实例", func() {
+		esConvey("需要运行中的 Elasticsearch 实例", func() {
 			es, err := NewESWithOptions(testESOptions)
 			So(err, ShouldBeNil)
 			defer es.Close()
@@ -551,7 +569,7 @@ func TestESCRUDOperations(t *testing.T) {
 
 func TestESFind(t *testing.T) {
 	Convey("测试 ES Find 方法", t, func() {
-		SkipConvey("跳过 ES 测试 - 需要运行中的 Elasticsearch 实例", func() {
+		esConvey("需要运行中的 Elasticsearch 实例", func() {
 			es, err := NewESWithOptions(testESOptions)
 			So(err, ShouldBeNil)
 			defer es.Close()
@@ -623,7 +641,7 @@ func TestESFind(t *testing.T) {
 
 func TestESAggregate(t *testing.T) {
 	Convey("测试 ES Aggregate 方法", t, func() {
-		SkipConvey("跳过 ES 测试 - 需要运行中的 Elasticsearch 实例", func() {
+		esConvey("需要运行中的 Elasticsearch 实例", func() {
 			es, err := NewESWithOptions(testESOptions)
 			So(err, ShouldBeNil)
 			defer es.Close()
@@ -700,7 +718,7 @@ func TestESAggregate(t *testing.T) {
 
 func TestESBatchOperations(t *testing.T) {
 	Convey("测试 ES 批量操作", t, func() {
-		SkipConvey("跳过 ES 测试 - 需要运行中的 Elasticsearch 实例", func() {
+		esConvey("需要运行中的 Elasticsearch 实例", func() {
 			es, err := NewESWithOptions(testESOptions)
 			So(err, ShouldBeNil)
 			defer es.Close()
@@ -836,7 +854,7 @@ func TestESBatchOperations(t *testing.T) {
 
 func TestESTransaction(t *testing.T) {
 	Convey("测试 ES 事务操作", t, func() {
-		SkipConvey("跳过 ES 测试 - 需要运行中的 Elasticsearch 实例", func() {
+		esConvey("需要运行中的 Elasticsearch 实例", func() {
 			es, err := NewESWithOptions(testESOptions)
 			So(err, ShouldBeNil)
 			defer es.Close()
@@ -1021,6 +1039,10 @@ func TestESFieldTypeMapping(t *testing.T) {
 			// JSON 类型
 			jsonMapping := es.mapFieldTypeToES(FieldTypeJSON, 0)
 			So(jsonMapping["type"], ShouldEqual, "object")
+
+			// GeoPoint 类型
+			geoMapping := es.mapFieldTypeToES(FieldTypeGeoPoint, 0)
+			So(geoMapping["type"], ShouldEqual, "geo_point")
 		})
 
 		Convey("测试 buildIndexMapping", func() {
@@ -1055,4 +1077,234 @@ func TestESFieldTypeMapping(t *testing.T) {
 			So(properties["created_at"], ShouldNotBeNil)
 		})
 	})
-}
\ No newline at end of file
+}
+func TestESStats(t *testing.T) {
+	Convey("测试 ES Stats 方法", t, func() {
+		esConvey("需要运行中的 Elasticsearch 实例", func() {
+			es, err := NewESWithOptions(testESOptions)
+			So(err, ShouldBeNil)
+			defer es.Close()
+
+			ctx := context.Background()
+			model := &TableModel{
+				Table: "test_stats_users",
+				Fields: []FieldDefinition{
+					{Name: "id", Type: FieldTypeString, Required: true},
+					{Name: "name", Type: FieldTypeString, Required: true},
+				},
+				PrimaryKey: []string{"id"},
+			}
+			So(es.Migrate(ctx, model), ShouldBeNil)
+			defer es.DropTable(ctx, "test_stats_users")
+
+			stats, err := es.Stats(ctx, "test_stats_users")
+			So(err, ShouldBeNil)
+			So(stats.RowCount, ShouldEqual, 0)
+			So(stats.IndexSize, ShouldEqual, 0)
+		})
+	})
+}
+
+func TestESFindStream(t *testing.T) {
+	Convey("测试 ES FindStream 方法", t, func() {
+		esConvey("需要运行中的 Elasticsearch 实例", func() {
+			es, err := NewESWithOptions(testESOptions)
+			So(err, ShouldBeNil)
+			defer es.Close()
+
+			ctx := context.Background()
+			defer es.DropTable(ctx, "test_stream_users")
+
+			for i := 1; i <= 5; i++ {
+				record := es.builder.FromStruct(TestESUser{ID: fmt.Sprint(i), Name: "user", Age: 20 + i})
+				So(es.Create(ctx, "test_stream_users", record), ShouldBeNil)
+			}
+			time.Sleep(1 * time.Second)
+
+			cursor, err := es.FindStream(ctx, "test_stream_users", &query.TermQuery{Field: "name", Value: "user"}, WithBatchSize(2), func(opts *QueryOptions) {
+				opts.Offset = 1
+				opts.Limit = 3
+			})
+			So(err, ShouldBeNil)
+			defer cursor.Close()
+
+			count := 0
+			for cursor.Next() {
+				count++
+			}
+			So(cursor.Err(), ShouldBeNil)
+			So(count, ShouldEqual, 3)
+		})
+	})
+}
+
+func TestESCountExists(t *testing.T) {
+	Convey("测试 ES Count 和 Exists 方法", t, func() {
+		esConvey("需要运行中的 Elasticsearch 实例", func() {
+			es, err := NewESWithOptions(testESOptions)
+			So(err, ShouldBeNil)
+			defer es.Close()
+
+			ctx := context.Background()
+			defer es.DropTable(ctx, "test_count_users")
+
+			for i := 1; i <= 5; i++ {
+				record := es.builder.FromStruct(TestESUser{ID: fmt.Sprint(i), Name: "user", Age: 20 + i})
+				So(es.Create(ctx, "test_count_users", record), ShouldBeNil)
+			}
+			time.Sleep(1 * time.Second)
+
+			count, err := es.Count(ctx, "test_count_users", &query.RangeQuery{Field: "age", Gte: 23})
+			So(err, ShouldBeNil)
+			So(count, ShouldEqual, 3)
+
+			exists, err := es.Exists(ctx, "test_count_users", map[string]any{"id": "3"})
+			So(err, ShouldBeNil)
+			So(exists, ShouldBeTrue)
+
+			exists, err = es.Exists(ctx, "test_count_users", map[string]any{"id": "100"})
+			So(err, ShouldBeNil)
+			So(exists, ShouldBeFalse)
+		})
+	})
+}
+
+func TestESUpdatePartialIncrement(t *testing.T) {
+	Convey("测试 ES UpdatePartial 和 Increment 方法", t, func() {
+		esConvey("需要运行中的 Elasticsearch 实例", func() {
+			es, err := NewESWithOptions(testESOptions)
+			So(err, ShouldBeNil)
+			defer es.Close()
+
+			ctx := context.Background()
+			defer es.DropTable(ctx, "test_partial_users")
+
+			record := es.builder.FromStruct(TestESUser{ID: "1", Name: "alice", Age: 20})
+			So(es.Create(ctx, "test_partial_users", record), ShouldBeNil)
+			pk := map[string]any{"id": "1"}
+
+			So(es.UpdatePartial(ctx, "test_partial_users", pk, map[string]any{"name": "bob"}), ShouldBeNil)
+			So(es.Increment(ctx, "test_partial_users", pk, "age", 5), ShouldBeNil)
+
+			var user TestESUser
+			got, err := es.Get(ctx, "test_partial_users", pk)
+			So(err, ShouldBeNil)
+			So(got.Scan(&user), ShouldBeNil)
+			So(user.Name, ShouldEqual, "bob")
+			So(user.Age, ShouldEqual, 25)
+		})
+	})
+}
+
+func TestESMigrateDiff(t *testing.T) {
+	Convey("测试 ES MigrateDiff 方法", t, func() {
+		esConvey("需要运行中的 Elasticsearch 实例", func() {
+			es, err := NewESWithOptions(testESOptions)
+			So(err, ShouldBeNil)
+			defer es.Close()
+
+			ctx := context.Background()
+			defer es.DropTable(ctx, "test_diff_users")
+
+			model := &TableModel{
+				Table:  "test_diff_users",
+				Fields: []FieldDefinition{{Name: "name", Type: FieldTypeString}},
+			}
+			So(es.Migrate(ctx, model), ShouldBeNil)
+
+			model.Fields = append(model.Fields, FieldDefinition{Name: "age", Type: FieldTypeInt})
+			statements, err := es.MigrateDiff(ctx, model, WithDryRun())
+			So(err, ShouldBeNil)
+			So(statements, ShouldResemble, []string{`PUT /test_diff_users/_mapping {"properties":{"age":{"type":"long"}}}`})
+
+			_, err = es.MigrateDiff(ctx, model)
+			So(err, ShouldBeNil)
+
+			statements, err = es.MigrateDiff(ctx, model)
+			So(err, ShouldBeNil)
+			So(statements, ShouldBeEmpty)
+		})
+	})
+}
+
+func TestESHealth(t *testing.T) {
+	Convey("测试 ES 健康检查和重连", t, func() {
+		esConvey("需要运行中的 Elasticsearch 实例", func() {
+			options := *testESOptions
+			options.HealthCheckInterval = time.Second
+			es, err := NewESWithOptions(&options)
+			So(err, ShouldBeNil)
+			defer es.Close()
+
+			ctx := context.Background()
+			So(es.Health(ctx), ShouldBeNil)
+
+			old := es.getClient()
+			es.reconnect(ctx)
+			So(es.getClient(), ShouldNotEqual, old)
+			So(es.Health(ctx), ShouldBeNil)
+		})
+	})
+}
+
+func TestESOptimisticLock(t *testing.T) {
+	Convey("测试 ES 乐观锁", t, func() {
+		esConvey("需要运行中的 Elasticsearch 实例", func() {
+			es, err := NewESWithOptions(testESOptions)
+			So(err, ShouldBeNil)
+			defer es.Close()
+
+			ctx := context.Background()
+			defer es.DropTable(ctx, "test_version_users")
+
+			So(es.Create(ctx, "test_version_users", es.builder.FromMap(map[string]any{"_id": "1", "name": "alice", "version": 0}, "test_version_users")), ShouldBeNil)
+			pk := map[string]any{"_id": "1"}
+
+			So(es.UpdatePartial(ctx, "test_version_users", pk, map[string]any{"name": "bob"}, WithVersion("version", 0)), ShouldBeNil)
+			So(es.UpdatePartial(ctx, "test_version_users", pk, map[string]any{"name": "carol"}, WithVersion("version", 0)), ShouldEqual, ErrVersionConflict)
+
+			record, err := es.Get(ctx, "test_version_users", pk)
+			So(err, ShouldBeNil)
+			So(record.Fields()["version"], ShouldEqual, 1)
+		})
+	})
+}
+
+func TestESVersionNumber(t *testing.T) {
+	Convey("测试 ES 版本号转换", t, func() {
+		v, ok := esVersionNumber(float64(3))
+		So(ok, ShouldBeTrue)
+		So(v, ShouldEqual, 3)
+		_, ok = esVersionNumber(1.5)
+		So(ok, ShouldBeFalse)
+		_, ok = esVersionNumber("1")
+		So(ok, ShouldBeFalse)
+	})
+}
+
+func TestESMigrateAliases(t *testing.T) {
+	Convey("测试 ES 过滤别名迁移", t, func() {
+		esConvey("需要运行中的 Elasticsearch 实例", func() {
+			es, err := NewESWithOptions(testESOptions)
+			So(err, ShouldBeNil)
+			defer es.Close()
+
+			ctx := context.Background()
+			defer es.DropTable(ctx, "test_view_orders")
+
+			model := &TableModel{
+				Table: "test_view_orders",
+				Fields: []FieldDefinition{
+					{Name: "status", Type: FieldTypeString, Size: 20},
+				},
+				Views: []ViewDefinition{
+					{Name: "test_view_paid_orders", Filter: &query.TermQuery{Field: "status", Value: "paid"}},
+				},
+			}
+			So(es.Migrate(ctx, model), ShouldBeNil)
+			// 重复迁移时覆盖过滤条件
+			model.Views[0].Filter = &query.TermQuery{Field: "status", Value: "created"}
+			So(es.Migrate(ctx, model), ShouldBeNil)
+		})
+	})
+}
@@ -0,0 +1,612 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+	"github.com/hatlonely/gox/log"
+)
+
+// ESTimeSeriesOptions 按时间分区的表，适合日志、事件等只追加、按时间查询和清理的数据
+//
+// 每个周期创建一个索引 <table>-<周期>-<序号>，如 events-2024.05.01-000001，映射由索引模板 <table> 提供；
+// 表名作为写别名只指向当前的写索引，读别名指向最近 ReadPeriods 个周期的索引
+type ESTimeSeriesOptions struct {
+	// Period 分区周期：hour, day, week, month, year，week 从周一开始
+	Period string `cfg:"period" def:"day" validate:"omitempty,oneof=hour day week month year"`
+	// ReadAlias 读别名，为空时为 <table>_read
+	ReadAlias string `cfg:"readAlias"`
+	// ReadPeriods 读别名覆盖的周期数（包括当前周期），为 0 时覆盖所有索引
+	ReadPeriods int `cfg:"readPeriods" def:"7"`
+	// Retention 保留的周期数（包括当前周期），更早的索引在滚动时删除，为 0 时不删除
+	Retention int `cfg:"retention"`
+	// MaxAge 写索引创建超过该时长时滚动到同一周期的新索引，为 0 时不限制
+	MaxAge time.Duration `cfg:"maxAge"`
+	// MaxSize 写索引主分片的大小超过该字节数时滚动到同一周期的新索引，为 0 时不限制
+	MaxSize int64 `cfg:"maxSize"`
+	// MaxDocs 写索引的文档数超过该值时滚动到同一周期的新索引，为 0 时不限制
+	MaxDocs int64 `cfg:"maxDocs"`
+	// Timezone 划分周期使用的时区，如 Asia/Shanghai，默认 UTC
+	Timezone string `cfg:"timezone"`
+}
+
+// esTimeSeries 解析之后的时间分区配置
+type esTimeSeries struct {
+	table     string
+	period    string
+	readAlias string
+	options   *ESTimeSeriesOptions
+	location  *time.Location
+}
+
+// esTimeSeriesIndex 按周期命名的索引
+type esTimeSeriesIndex struct {
+	name  string
+	start time.Time // 周期的开始时间
+	seq   int
+}
+
+// esPeriodLayouts 各周期在索引名中的格式
+var esPeriodLayouts = map[string]string{
+	"hour":  "2006.01.02.15",
+	"day":   "2006.01.02",
+	"week":  "2006.01.02",
+	"month": "2006.01",
+	"year":  "2006",
+}
+
+func newESTimeSeries(table string, options *ESTimeSeriesOptions) (*esTimeSeries, error) {
+	if options == nil {
+		return nil, fmt.Errorf("time series options of table %s cannot be nil", table)
+	}
+	ts := &esTimeSeries{table: table, period: options.Period, readAlias: options.ReadAlias, options: options, location: time.UTC}
+	if ts.period == "" {
+		ts.period = "day"
+	}
+	if _, ok := esPeriodLayouts[ts.period]; !ok {
+		return nil, fmt.Errorf("unsupported time series period %q of table %s", options.Period, table)
+	}
+	if ts.readAlias == "" {
+		ts.readAlias = table + "_read"
+	}
+	if options.Timezone != "" {
+		location, err := time.LoadLocation(options.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timezone %s of table %s: %w", options.Timezone, table, err)
+		}
+		ts.location = location
+	}
+	return ts, nil
+}
+
+// newESTimeSeriesMap 解析所有表的时间分区配置
+func newESTimeSeriesMap(options map[string]*ESTimeSeriesOptions) (map[string]*esTimeSeries, error) {
+	result := make(map[string]*esTimeSeries, len(options))
+	for table, opts := range options {
+		ts, err := newESTimeSeries(table, opts)
+		if err != nil {
+			return nil, err
+		}
+		result[table] = ts
+	}
+	return result, nil
+}
+
+// periodStart 返回 t 所在周期的开始时间
+func (ts *esTimeSeries) periodStart(t time.Time) time.Time {
+	t = t.In(ts.location)
+	switch ts.period {
+	case "hour":
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, ts.location)
+	case "week":
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, ts.location)
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	case "month":
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, ts.location)
+	case "year":
+		return time.Date(t.Year(), 1, 1, 0, 0, 0, 0, ts.location)
+	default:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, ts.location)
+	}
+}
+
+// addPeriods 返回 start 之后第 n 个周期的开始时间，n 可以为负数
+func (ts *esTimeSeries) addPeriods(start time.Time, n int) time.Time {
+	switch ts.period {
+	case "hour":
+		return start.Add(time.Duration(n) * time.Hour)
+	case "week":
+		return start.AddDate(0, 0, 7*n)
+	case "month":
+		return start.AddDate(0, n, 0)
+	case "year":
+		return start.AddDate(n, 0, 0)
+	default:
+		return start.AddDate(0, 0, n)
+	}
+}
+
+// indexName 返回周期和序号对应的索引名
+func (ts *esTimeSeries) indexName(start time.Time, seq int) string {
+	return fmt.Sprintf("%s-%s-%06d", ts.table, start.In(ts.location).Format(esPeriodLayouts[ts.period]), seq)
+}
+
+// parseIndex 解析按周期命名的索引，不是该表的索引时返回 false
+func (ts *esTimeSeries) parseIndex(name string) (esTimeSeriesIndex, bool) {
+	rest, ok := strings.CutPrefix(name, ts.table+"-")
+	if !ok {
+		return esTimeSeriesIndex{}, false
+	}
+	i := strings.LastIndex(rest, "-")
+	if i < 0 {
+		return esTimeSeriesIndex{}, false
+	}
+	seq, err := strconv.Atoi(rest[i+1:])
+	if err != nil {
+		return esTimeSeriesIndex{}, false
+	}
+	start, err := time.ParseInLocation(esPeriodLayouts[ts.period], rest[:i], ts.location)
+	if err != nil {
+		return esTimeSeriesIndex{}, false
+	}
+	return esTimeSeriesIndex{name: name, start: start, seq: seq}, true
+}
+
+// indexPattern 匹配该表所有索引的通配符
+func (ts *esTimeSeries) indexPattern() string {
+	return ts.table + "-*"
+}
+
+// timeSeries 返回表的时间分区配置，不是按时间分区的表时返回 nil
+func (es *ES) timeSeries(table string) *esTimeSeries {
+	return es.timeSeriesTables[table]
+}
+
+// timeSeriesTemplate 返回索引模板的请求体，模板为该表之后创建的索引提供设置、映射和视图对应的过滤别名
+func (es *ES) timeSeriesTemplate(ts *esTimeSeries, model *TableModel, mapping map[string]any) (map[string]any, error) {
+	template := make(map[string]any, len(mapping)+1)
+	for k, v := range mapping {
+		template[k] = v
+	}
+	if len(model.Views) > 0 {
+		aliases := make(map[string]any, len(model.Views))
+		for _, view := range model.Views {
+			alias := map[string]any{}
+			if view.Filter != nil {
+				if err := validateESQuery(view.Filter); err != nil {
+					return nil, err
+				}
+				alias["filter"] = view.Filter.ToES()
+			}
+			aliases[view.Name] = alias
+		}
+		template["aliases"] = aliases
+	}
+	return map[string]any{
+		"index_patterns": []string{ts.indexPattern()},
+		"template":       template,
+	}, nil
+}
+
+// timeSeriesFirstIndex 返回当前周期的第一个索引和创建索引的请求体，同时创建写别名和读别名
+func (es *ES) timeSeriesFirstIndex(ts *esTimeSeries, now time.Time) (string, map[string]any) {
+	return ts.indexName(ts.periodStart(now), 1), map[string]any{
+		"aliases": map[string]any{
+			ts.table:     map[string]any{"is_write_index": true},
+			ts.readAlias: map[string]any{},
+		},
+	}
+}
+
+// migrateTimeSeries 创建或更新索引模板，写别名不存在时创建当前周期的索引，否则为已有的索引追加映射
+func (es *ES) migrateTimeSeries(ctx context.Context, ts *esTimeSeries, model *TableModel) error {
+	mapping := es.buildIndexMapping(model)
+	template, err := es.timeSeriesTemplate(ts, model, mapping)
+	if err != nil {
+		return err
+	}
+	if err := es.putIndexTemplate(ctx, ts.table, template); err != nil {
+		return err
+	}
+
+	indices, err := es.aliasIndices(ctx, ts.table)
+	if err != nil {
+		return err
+	}
+	if len(indices) == 0 {
+		index, body := es.timeSeriesFirstIndex(ts, time.Now())
+		return es.createIndex(ctx, index, body)
+	}
+	if err := es.updateIndexMapping(ctx, ts.indexPattern(), mapping); err != nil {
+		return err
+	}
+	// 模板只作用于新建的索引，已有的索引需要单独添加视图别名
+	return es.migrateAliases(ctx, model)
+}
+
+// migrateDiffTimeSeries 与 MigrateDiff 相同，映射有变化时同时更新索引模板
+func (es *ES) migrateDiffTimeSeries(ctx context.Context, ts *esTimeSeries, model *TableModel, dryRun bool) ([]string, error) {
+	existing, found, err := es.getMappingProperties(ctx, ts.table)
+	if err != nil {
+		return nil, err
+	}
+
+	mapping := es.buildIndexMapping(model)
+	missing := make(map[string]any)
+	for _, field := range model.Fields {
+		if _, ok := existing[field.Name]; !ok {
+			missing[field.Name] = es.mapFieldTypeToES(field.Type, field.Size)
+		}
+	}
+	if found && len(missing) == 0 {
+		return nil, nil
+	}
+
+	template, err := es.timeSeriesTemplate(ts, model, mapping)
+	if err != nil {
+		return nil, err
+	}
+	templateBody, err := json.Marshal(template)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal index template: %v", err)
+	}
+	statements := []string{fmt.Sprintf("PUT /_index_template/%s %s", ts.table, templateBody)}
+
+	var index string
+	var indexBody map[string]any
+	if found {
+		indexBody = map[string]any{"mappings": map[string]any{"properties": missing}}
+		body, err := json.Marshal(map[string]any{"properties": missing})
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal mapping: %v", err)
+		}
+		statements = append(statements, fmt.Sprintf("PUT /%s/_mapping %s", ts.indexPattern(), body))
+	} else {
+		index, indexBody = es.timeSeriesFirstIndex(ts, time.Now())
+		body, err := json.Marshal(indexBody)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal mapping: %v", err)
+		}
+		statements = append(statements, fmt.Sprintf("PUT /%s %s", index, body))
+	}
+	if dryRun {
+		return statements, nil
+	}
+
+	if err := es.putIndexTemplate(ctx, ts.table, template); err != nil {
+		return nil, err
+	}
+	if found {
+		return statements, es.updateIndexMapping(ctx, ts.indexPattern(), indexBody)
+	}
+	return statements, es.createIndex(ctx, index, indexBody)
+}
+
+// putIndexTemplate 创建或覆盖索引模板
+func (es *ES) putIndexTemplate(ctx context.Context, name string, template map[string]any) error {
+	body, err := json.Marshal(template)
+	if err != nil {
+		return fmt.Errorf("failed to marshal index template: %v", err)
+	}
+
+	req := esapi.IndicesPutIndexTemplateRequest{
+		Name: name,
+		Body: strings.NewReader(string(body)),
+	}
+	res, err := req.Do(ctx, es.getClient())
+	if err != nil {
+		return fmt.Errorf("failed to put index template: %v", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return newESResponseError("failed to put index template", res)
+	}
+	return nil
+}
+
+// aliasIndices 返回别名指向的索引以及是否为写索引，别名不存在时返回空
+func (es *ES) aliasIndices(ctx context.Context, alias string) (map[string]bool, error) {
+	req := esapi.IndicesGetAliasRequest{
+		Name: []string{alias},
+	}
+	res, err := req.Do(ctx, es.getClient())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get alias: %v", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == 404 {
+		return nil, nil
+	}
+	if res.IsError() {
+		return nil, newESResponseError("failed to get alias", res)
+	}
+
+	var result map[string]struct {
+		Aliases map[string]struct {
+			IsWriteIndex *bool `json:"is_write_index"`
+		} `json:"aliases"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode alias: %v", err)
+	}
+	indices := make(map[string]bool, len(result))
+	for index, item := range result {
+		isWriteIndex := item.Aliases[alias].IsWriteIndex
+		indices[index] = isWriteIndex != nil && *isWriteIndex
+	}
+	// 只指向一个索引的别名没有显式设置 is_write_index 时，该索引就是写索引
+	if len(indices) == 1 {
+		for index := range indices {
+			indices[index] = true
+		}
+	}
+	return indices, nil
+}
+
+// Rollover 检查按时间分区的表是否需要滚动，需要时创建新的写索引并切换别名，返回是否发生了滚动
+//
+// 进入新的周期，或者写索引超过 MaxAge、MaxSize、MaxDocs 任一限制时滚动；滚动时读别名移除超出 ReadPeriods 的索引，
+// 并删除超出 Retention 的索引。设置了 ESOptions.RolloverInterval 时后台定期检查所有按时间分区的表
+func (es *ES) Rollover(ctx context.Context, table string) (bool, error) {
+	ctx, done, err := es.ops.enter(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer done()
+
+	return es.rollover(ctx, table, time.Now())
+}
+
+func (es *ES) rollover(ctx context.Context, table string, now time.Time) (bool, error) {
+	ts := es.timeSeries(table)
+	if ts == nil {
+		return false, fmt.Errorf("table %s is not a time series table", table)
+	}
+
+	writeIndex, err := es.timeSeriesWriteIndex(ctx, ts)
+	if err != nil {
+		return false, err
+	}
+
+	currentStart := ts.periodStart(now)
+	var newIndex string
+	switch {
+	case writeIndex.start.Before(currentStart):
+		newIndex = ts.indexName(currentStart, 1)
+	default:
+		exceeded, err := es.timeSeriesLimitExceeded(ctx, ts, writeIndex.name, now)
+		if err != nil {
+			return false, err
+		}
+		if !exceeded {
+			return false, nil
+		}
+		newIndex = ts.indexName(writeIndex.start, writeIndex.seq+1)
+	}
+
+	// 映射由索引模板提供，创建之后原子地切换写别名并加入读别名
+	if err := es.createIndex(ctx, newIndex, map[string]any{}); err != nil {
+		return false, err
+	}
+	actions := []map[string]any{
+		{"add": map[string]any{"index": newIndex, "alias": ts.table, "is_write_index": true}},
+		{"remove": map[string]any{"index": writeIndex.name, "alias": ts.table}},
+		{"add": map[string]any{"index": newIndex, "alias": ts.readAlias}},
+	}
+	if ts.options.ReadPeriods > 0 {
+		readIndices, err := es.aliasIndices(ctx, ts.readAlias)
+		if err != nil {
+			return false, err
+		}
+		readStart := ts.addPeriods(currentStart, -(ts.options.ReadPeriods - 1))
+		for _, name := range slices.Sorted(maps.Keys(readIndices)) {
+			if index, ok := ts.parseIndex(name); ok && index.start.Before(readStart) {
+				actions = append(actions, map[string]any{"remove": map[string]any{"index": name, "alias": ts.readAlias}})
+			}
+		}
+	}
+	if err := es.updateAliases(ctx, actions); err != nil {
+		return false, err
+	}
+
+	return true, es.deleteExpiredIndices(ctx, ts, currentStart)
+}
+
+// timeSeriesWriteIndex 返回写别名指向的索引
+func (es *ES) timeSeriesWriteIndex(ctx context.Context, ts *esTimeSeries) (esTimeSeriesIndex, error) {
+	indices, err := es.aliasIndices(ctx, ts.table)
+	if err != nil {
+		return esTimeSeriesIndex{}, err
+	}
+	for name, isWriteIndex := range indices {
+		if !isWriteIndex {
+			continue
+		}
+		index, ok := ts.parseIndex(name)
+		if !ok {
+			return esTimeSeriesIndex{}, fmt.Errorf("write index %s of table %s is not a time series index", name, ts.table)
+		}
+		return index, nil
+	}
+	return esTimeSeriesIndex{}, fmt.Errorf("write alias %s not found, call Migrate first", ts.table)
+}
+
+// timeSeriesLimitExceeded 检查写索引是否超过 MaxAge、MaxSize、MaxDocs 任一限制
+func (es *ES) timeSeriesLimitExceeded(ctx context.Context, ts *esTimeSeries, index string, now time.Time) (bool, error) {
+	options := ts.options
+	if options.MaxSize > 0 || options.MaxDocs > 0 {
+		req := esapi.IndicesStatsRequest{
+			Index:  []string{index},
+			Metric: []string{"docs", "store"},
+		}
+		var result struct {
+			All struct {
+				Primaries struct {
+					Docs struct {
+						Count int64 `json:"count"`
+					} `json:"docs"`
+					Store struct {
+						SizeInBytes int64 `json:"size_in_bytes"`
+					} `json:"store"`
+				} `json:"primaries"`
+			} `json:"_all"`
+		}
+		if err := es.doJSON(ctx, req, "failed to get index stats", &result); err != nil {
+			return false, err
+		}
+		primaries := result.All.Primaries
+		if options.MaxSize > 0 && primaries.Store.SizeInBytes >= options.MaxSize {
+			return true, nil
+		}
+		if options.MaxDocs > 0 && primaries.Docs.Count >= options.MaxDocs {
+			return true, nil
+		}
+	}
+
+	if options.MaxAge > 0 {
+		req := esapi.IndicesGetSettingsRequest{
+			Index: []string{index},
+			Name:  []string{"index.creation_date"},
+		}
+		var result map[string]struct {
+			Settings struct {
+				Index struct {
+					CreationDate string `json:"creation_date"`
+				} `json:"index"`
+			} `json:"settings"`
+		}
+		if err := es.doJSON(ctx, req, "failed to get index settings", &result); err != nil {
+			return false, err
+		}
+		millis, err := strconv.ParseInt(result[index].Settings.Index.CreationDate, 10, 64)
+		if err != nil {
+			return false, fmt.Errorf("invalid creation date of index %s: %v", index, err)
+		}
+		if now.Sub(time.UnixMilli(millis)) >= options.MaxAge {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// deleteExpiredIndices 删除超出 Retention 的索引，写索引不会被删除
+func (es *ES) deleteExpiredIndices(ctx context.Context, ts *esTimeSeries, currentStart time.Time) error {
+	if ts.options.Retention <= 0 {
+		return nil
+	}
+
+	req := esapi.CatIndicesRequest{
+		Index:  []string{ts.indexPattern()},
+		Format: "json",
+		H:      []string{"index"},
+	}
+	var result []struct {
+		Index string `json:"index"`
+	}
+	if err := es.doJSON(ctx, req, "failed to list indices", &result); err != nil {
+		return err
+	}
+
+	retentionStart := ts.addPeriods(currentStart, -(ts.options.Retention - 1))
+	var expired []string
+	for _, item := range result {
+		if index, ok := ts.parseIndex(item.Index); ok && index.start.Before(retentionStart) {
+			expired = append(expired, item.Index)
+		}
+	}
+	if len(expired) == 0 {
+		return nil
+	}
+	sort.Strings(expired)
+
+	deleteReq := esapi.IndicesDeleteRequest{Index: expired}
+	res, err := deleteReq.Do(ctx, es.getClient())
+	if err != nil {
+		return fmt.Errorf("failed to delete expired indices: %v", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() && res.StatusCode != 404 {
+		return newESResponseError("failed to delete expired indices", res)
+	}
+	return nil
+}
+
+// updateAliases 原子地执行别名操作
+func (es *ES) updateAliases(ctx context.Context, actions []map[string]any) error {
+	body, err := json.Marshal(map[string]any{"actions": actions})
+	if err != nil {
+		return fmt.Errorf("failed to marshal aliases: %v", err)
+	}
+
+	req := esapi.IndicesUpdateAliasesRequest{
+		Body: strings.NewReader(string(body)),
+	}
+	res, err := req.Do(ctx, es.getClient())
+	if err != nil {
+		return fmt.Errorf("failed to update aliases: %v", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return newESResponseError("failed to update aliases", res)
+	}
+	return nil
+}
+
+// deleteIndexTemplate 删除索引模板，模板不存在时忽略
+func (es *ES) deleteIndexTemplate(ctx context.Context, name string) error {
+	req := esapi.IndicesDeleteIndexTemplateRequest{
+		Name: name,
+	}
+	res, err := req.Do(ctx, es.getClient())
+	if err != nil {
+		return fmt.Errorf("failed to delete index template: %v", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() && res.StatusCode != 404 {
+		return newESResponseError("failed to delete index template", res)
+	}
+	return nil
+}
+
+// rolloverAll 后台定期检查所有按时间分区的表，失败时输出告警日志
+func (es *ES) rolloverAll(ctx context.Context) error {
+	var errs []error
+	for _, table := range slices.Sorted(maps.Keys(es.timeSeriesTables)) {
+		if _, err := es.Rollover(ctx, table); err != nil {
+			log.Default().WarnContext(ctx, "failed to rollover time series index", "table", table, "error", err)
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// doJSON 执行请求并解码 JSON 响应
+func (es *ES) doJSON(ctx context.Context, req esapi.Request, message string, out any) error {
+	res, err := req.Do(ctx, es.getClient())
+	if err != nil {
+		return fmt.Errorf("%s: %v", message, err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return newESResponseError(message, res)
+	}
+	if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+		return fmt.Errorf("%s: failed to decode response: %v", message, err)
+	}
+	return nil
+}
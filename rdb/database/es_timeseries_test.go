@@ -0,0 +1,304 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// fakeESIndex 模拟的索引，aliases 的值表示是否为写索引
+type fakeESIndex struct {
+	aliases map[string]bool
+	docs    int64
+	created time.Time
+}
+
+// fakeESIndices 在内存中模拟索引和别名，只支持时间分区用到的接口
+type fakeESIndices struct {
+	indices   map[string]*fakeESIndex
+	templates map[string]string
+}
+
+func newFakeESIndices() *fakeESIndices {
+	return &fakeESIndices{indices: map[string]*fakeESIndex{}, templates: map[string]string{}}
+}
+
+func (f *fakeESIndices) add(name string, created time.Time, aliases map[string]bool) {
+	f.indices[name] = &fakeESIndex{aliases: aliases, created: created}
+}
+
+// match 返回名称或通配符匹配的索引
+func (f *fakeESIndices) match(pattern string) []string {
+	var names []string
+	for _, p := range strings.Split(pattern, ",") {
+		for name := range f.indices {
+			if name == p || strings.HasSuffix(p, "*") && strings.HasPrefix(name, strings.TrimSuffix(p, "*")) {
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (f *fakeESIndices) aliasIndices(alias string) []string {
+	var names []string
+	for name, index := range f.indices {
+		if _, ok := index.aliases[alias]; ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (f *fakeESIndices) handle(w http.ResponseWriter, r *http.Request, body string) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case parts[0] == "_index_template":
+		if r.Method == http.MethodDelete {
+			delete(f.templates, parts[1])
+		} else {
+			f.templates[parts[1]] = body
+		}
+		w.Write([]byte(`{"acknowledged":true}`))
+	case parts[0] == "_alias":
+		result := map[string]any{}
+		for _, name := range f.aliasIndices(parts[1]) {
+			result[name] = map[string]any{"aliases": map[string]any{parts[1]: map[string]any{"is_write_index": f.indices[name].aliases[parts[1]]}}}
+		}
+		if len(result) == 0 {
+			w.WriteHeader(http.StatusNotFound)
+		}
+		json.NewEncoder(w).Encode(result)
+	case parts[0] == "_aliases":
+		var req struct {
+			Actions []map[string]map[string]any `json:"actions"`
+		}
+		json.Unmarshal([]byte(body), &req)
+		for _, action := range req.Actions {
+			for kind, args := range action {
+				index := f.indices[args["index"].(string)]
+				if kind == "add" {
+					index.aliases[args["alias"].(string)] = args["is_write_index"] == true
+				} else {
+					delete(index.aliases, args["alias"].(string))
+				}
+			}
+		}
+		w.Write([]byte(`{"acknowledged":true}`))
+	case parts[0] == "_cat":
+		var result []map[string]string
+		for _, name := range f.match(parts[2]) {
+			result = append(result, map[string]string{"index": name})
+		}
+		json.NewEncoder(w).Encode(result)
+	case len(parts) > 1 && parts[1] == "_stats":
+		index := f.indices[parts[0]]
+		json.NewEncoder(w).Encode(map[string]any{"_all": map[string]any{"primaries": map[string]any{
+			"docs":  map[string]any{"count": index.docs},
+			"store": map[string]any{"size_in_bytes": index.docs * 100},
+		}}})
+	case len(parts) > 1 && parts[1] == "_settings":
+		index := f.indices[parts[0]]
+		json.NewEncoder(w).Encode(map[string]any{parts[0]: map[string]any{"settings": map[string]any{
+			"index": map[string]any{"creation_date": strconv.FormatInt(index.created.UnixMilli(), 10)},
+		}}})
+	case len(parts) > 1 && parts[1] == "_mapping":
+		if r.Method == http.MethodGet {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{}`))
+			return
+		}
+		w.Write([]byte(`{"acknowledged":true}`))
+	case r.Method == http.MethodPut:
+		var req struct {
+			Aliases map[string]struct {
+				IsWriteIndex bool `json:"is_write_index"`
+			} `json:"aliases"`
+		}
+		json.Unmarshal([]byte(body), &req)
+		aliases := map[string]bool{}
+		for alias, item := range req.Aliases {
+			aliases[alias] = item.IsWriteIndex
+		}
+		f.add(parts[0], time.Now(), aliases)
+		w.Write([]byte(`{"acknowledged":true}`))
+	case r.Method == http.MethodDelete:
+		for _, name := range f.match(parts[0]) {
+			delete(f.indices, name)
+		}
+		w.Write([]byte(`{"acknowledged":true}`))
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{}`))
+	}
+}
+
+func TestESTimeSeriesNaming(t *testing.T) {
+	Convey("测试时间分区的索引命名", t, func() {
+		shanghai, err := time.LoadLocation("Asia/Shanghai")
+		So(err, ShouldBeNil)
+		now := time.Date(2024, 5, 1, 18, 30, 0, 0, time.UTC) // 周三，上海时间 5 月 2 日 02:30
+
+		for _, c := range []struct {
+			options *ESTimeSeriesOptions
+			name    string
+			prev    string
+		}{
+			{&ESTimeSeriesOptions{}, "events-2024.05.01-000001", "events-2024.04.30-000001"},
+			{&ESTimeSeriesOptions{Period: "hour"}, "events-2024.05.01.18-000001", "events-2024.05.01.17-000001"},
+			{&ESTimeSeriesOptions{Period: "week"}, "events-2024.04.29-000001", "events-2024.04.22-000001"},
+			{&ESTimeSeriesOptions{Period: "month"}, "events-2024.05-000001", "events-2024.04-000001"},
+			{&ESTimeSeriesOptions{Period: "year"}, "events-2024-000001", "events-2023-000001"},
+			{&ESTimeSeriesOptions{Timezone: "Asia/Shanghai"}, "events-2024.05.02-000001", "events-2024.05.01-000001"},
+		} {
+			ts, err := newESTimeSeries("events", c.options)
+			So(err, ShouldBeNil)
+			start := ts.periodStart(now)
+			So(ts.indexName(start, 1), ShouldEqual, c.name)
+			So(ts.indexName(ts.addPeriods(start, -1), 1), ShouldEqual, c.prev)
+
+			index, ok := ts.parseIndex(ts.indexName(start, 12))
+			So(ok, ShouldBeTrue)
+			So(index.start.Equal(start), ShouldBeTrue)
+			So(index.seq, ShouldEqual, 12)
+		}
+
+		ts, err := newESTimeSeries("events", &ESTimeSeriesOptions{Timezone: "Asia/Shanghai"})
+		So(err, ShouldBeNil)
+		So(ts.readAlias, ShouldEqual, "events_read")
+		So(ts.periodStart(now).Equal(time.Date(2024, 5, 2, 0, 0, 0, 0, shanghai)), ShouldBeTrue)
+		for _, name := range []string{"events", "events-2024.05.01", "events-x-000001", "events-2024.05.01-abc", "logs-2024.05.01-000001"} {
+			_, ok := ts.parseIndex(name)
+			So(ok, ShouldBeFalse)
+		}
+
+		_, err = newESTimeSeries("events", &ESTimeSeriesOptions{Period: "minute"})
+		So(err, ShouldNotBeNil)
+		_, err = newESTimeSeries("events", &ESTimeSeriesOptions{Timezone: "Mars/Olympus"})
+		So(err, ShouldNotBeNil)
+		_, err = newESTimeSeries("events", nil)
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestESTimeSeries(t *testing.T) {
+	Convey("测试 ES 时间分区", t, func() {
+		ctx := context.Background()
+		state := newFakeESIndices()
+		fake := newFakeES(state.handle)
+		defer fake.server.Close()
+
+		options := fake.options()
+		options.TimeSeries = map[string]*ESTimeSeriesOptions{
+			"events": {Period: "day", ReadPeriods: 3, Retention: 5, MaxDocs: 100},
+		}
+		es, err := NewESWithOptions(options)
+		So(err, ShouldBeNil)
+		defer es.Close()
+
+		model := &TableModel{
+			Table:  "events",
+			Fields: []FieldDefinition{{Name: "name", Type: FieldTypeString}, {Name: "ts", Type: FieldTypeDate}},
+		}
+
+		Convey("Migrate 创建索引模板和当前周期的索引", func() {
+			So(es.Migrate(ctx, model), ShouldBeNil)
+			So(state.templates["events"], ShouldContainSubstring, `"index_patterns":["events-*"]`)
+			So(state.templates["events"], ShouldContainSubstring, `"properties"`)
+
+			name := "events-" + time.Now().UTC().Format("2006.01.02") + "-000001"
+			So(state.match("events-*"), ShouldResemble, []string{name})
+			So(state.indices[name].aliases, ShouldResemble, map[string]bool{"events": true, "events_read": false})
+
+			// 再次迁移只更新模板和已有索引的映射
+			So(es.Migrate(ctx, model), ShouldBeNil)
+			So(state.match("events-*"), ShouldResemble, []string{name})
+			So(fake.paths()[len(fake.paths())-1], ShouldEqual, "PUT /events-*/_mapping")
+
+			So(es.DropTable(ctx, "events"), ShouldBeNil)
+			So(state.indices, ShouldBeEmpty)
+			So(state.templates, ShouldBeEmpty)
+		})
+
+		Convey("MigrateDiff 输出索引模板和索引的请求", func() {
+			statements, err := es.MigrateDiff(ctx, model, WithDryRun())
+			So(err, ShouldBeNil)
+			So(statements, ShouldHaveLength, 2)
+			So(statements[0], ShouldStartWith, "PUT /_index_template/events ")
+			So(statements[1], ShouldStartWith, "PUT /events-"+time.Now().UTC().Format("2006.01.02")+"-000001 ")
+			So(state.indices, ShouldBeEmpty)
+		})
+
+		Convey("进入新周期时滚动到新索引", func() {
+			day := func(d int) time.Time { return time.Date(2024, 5, d, 0, 0, 0, 0, time.UTC) }
+			for d := 1; d <= 6; d++ {
+				state.add("events-"+day(d).Format("2006.01.02")+"-000001", day(d), map[string]bool{})
+			}
+			for d := 4; d <= 6; d++ {
+				state.indices["events-"+day(d).Format("2006.01.02")+"-000001"].aliases["events_read"] = false
+			}
+			state.indices["events-2024.05.06-000001"].aliases["events"] = true
+
+			// 同一周期内未超过限制，不滚动
+			rolled, err := es.rollover(ctx, "events", day(6).Add(time.Hour))
+			So(err, ShouldBeNil)
+			So(rolled, ShouldBeFalse)
+
+			rolled, err = es.rollover(ctx, "events", day(7).Add(time.Hour))
+			So(err, ShouldBeNil)
+			So(rolled, ShouldBeTrue)
+			So(state.aliasIndices("events"), ShouldResemble, []string{"events-2024.05.07-000001"})
+			So(state.indices["events-2024.05.07-000001"].aliases["events"], ShouldBeTrue)
+			// 读别名覆盖最近 3 个周期
+			So(state.aliasIndices("events_read"), ShouldResemble, []string{
+				"events-2024.05.05-000001", "events-2024.05.06-000001", "events-2024.05.07-000001",
+			})
+			// 保留最近 5 个周期
+			So(state.match("events-*"), ShouldResemble, []string{
+				"events-2024.05.03-000001", "events-2024.05.04-000001", "events-2024.05.05-000001",
+				"events-2024.05.06-000001", "events-2024.05.07-000001",
+			})
+		})
+
+		Convey("写索引超过限制时滚动到同一周期的新索引", func() {
+			now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+			state.add("events-2024.05.01-000001", now, map[string]bool{"events": true, "events_read": false})
+			state.indices["events-2024.05.01-000001"].docs = 100
+
+			rolled, err := es.rollover(ctx, "events", now)
+			So(err, ShouldBeNil)
+			So(rolled, ShouldBeTrue)
+			So(state.aliasIndices("events"), ShouldResemble, []string{"events-2024.05.01-000002"})
+			So(state.aliasIndices("events_read"), ShouldResemble, []string{"events-2024.05.01-000001", "events-2024.05.01-000002"})
+
+			// 按创建时间滚动
+			es.timeSeries("events").options.MaxDocs = 0
+			es.timeSeries("events").options.MaxAge = time.Hour
+			state.indices["events-2024.05.01-000002"].created = now.Add(-30 * time.Minute)
+			rolled, err = es.rollover(ctx, "events", now)
+			So(err, ShouldBeNil)
+			So(rolled, ShouldBeFalse)
+			state.indices["events-2024.05.01-000002"].created = now.Add(-2 * time.Hour)
+			rolled, err = es.rollover(ctx, "events", now)
+			So(err, ShouldBeNil)
+			So(rolled, ShouldBeTrue)
+			So(state.aliasIndices("events"), ShouldResemble, []string{"events-2024.05.01-000003"})
+		})
+
+		Convey("不是时间分区的表或写别名不存在时返回错误", func() {
+			_, err := es.Rollover(ctx, "users")
+			So(err, ShouldNotBeNil)
+			_, err = es.Rollover(ctx, "events")
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,82 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hatlonely/gox/rdb/query"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestExplain(t *testing.T) {
+	ctx := context.Background()
+	q := &query.BoolQuery{
+		Must: []query.Query{
+			&query.TermQuery{Field: "status", Value: "active"},
+			&query.RangeQuery{Field: "age", Gte: 18},
+		},
+	}
+	opts := []QueryOption{func(o *QueryOptions) {
+		o.Limit = 10
+		o.Offset = 20
+		o.OrderBy = "age"
+		o.OrderDesc = true
+	}}
+
+	Convey("SQL 返回带占位符的语句和绑定参数", t, func() {
+		sql, err := NewSQLWithOptions(&SQLOptions{Driver: "sqlite3", Database: ":memory:", MaxConns: 1, MaxIdle: 1})
+		So(err, ShouldBeNil)
+		defer sql.Close()
+
+		result, err := sql.Explain(ctx, "users", q, opts...)
+		So(err, ShouldBeNil)
+		So(result.Backend, ShouldEqual, "sql")
+		So(result.Statement, ShouldContainSubstring, "FROM")
+		So(result.Statement, ShouldContainSubstring, "users")
+		So(result.Statement, ShouldContainSubstring, "LIMIT")
+		So(result.Args, ShouldResemble, []any{"active", 18})
+
+		// 包装的数据库逐层解开
+		wrapped, err := Explain(ctx, NewReadOnlyDatabase(NewCoalescingDatabase(sql)), "users", q, opts...)
+		So(err, ShouldBeNil)
+		So(wrapped, ShouldResemble, result)
+	})
+
+	Convey("Mongo 返回 mongo shell 语法的 find", t, func() {
+		result, err := (&Mongo{}).Explain(ctx, "users", q, opts...)
+		So(err, ShouldBeNil)
+		So(result.Backend, ShouldEqual, "mongo")
+		So(result.Statement, ShouldStartWith, "db.users.find({")
+		So(result.Statement, ShouldContainSubstring, `"status":"active"`)
+		So(result.Statement, ShouldContainSubstring, `"$gte":18`)
+		So(result.Statement, ShouldEndWith, `.sort({"age":-1}).skip(20).limit(10)`)
+		So(result.Args, ShouldBeNil)
+
+		_, err = (&Mongo{}).Explain(ctx, "users", q, WithScore())
+		So(err, ShouldEqual, ErrScoreUnsupported)
+
+		// 指定字段时转换为 projection，未指定 _id 时排除 _id
+		result, err = (&Mongo{}).Explain(ctx, "users", q, WithFields("name", "age"))
+		So(err, ShouldBeNil)
+		So(result.Statement, ShouldEndWith, `, {"_id":0,"name":1,"age":1})`)
+	})
+
+	Convey("ES 返回搜索请求和请求体", t, func() {
+		result, err := (&ES{}).Explain(ctx, "users", q, opts...)
+		So(err, ShouldBeNil)
+		So(result.Backend, ShouldEqual, "es")
+		So(result.Statement, ShouldStartWith, "GET /users/_search {")
+		So(result.Statement, ShouldContainSubstring, `"from":20`)
+		So(result.Statement, ShouldContainSubstring, `"size":10`)
+		So(result.Statement, ShouldContainSubstring, `"status":"active"`)
+
+		result, err = (&ES{}).Explain(ctx, "users", q, WithFields("name", "age"))
+		So(err, ShouldBeNil)
+		So(result.Statement, ShouldContainSubstring, `"_source":["name","age"]`)
+	})
+
+	Convey("不支持 Explain 的数据库", t, func() {
+		_, err := Explain(ctx, &slowGetDatabase{}, "users", q)
+		So(err, ShouldEqual, ErrExplainUnsupported)
+	})
+}
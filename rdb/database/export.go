@@ -0,0 +1,241 @@
+package database
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/hatlonely/gox/rdb/query"
+)
+
+// ExportOptions 导出选项
+type ExportOptions struct {
+	Fields    []string      // 导出的字段及顺序，为空时导出所有字段
+	QueryOpts []QueryOption // 传给 FindStream 的选项，如排序、Limit、BatchSize
+}
+
+type ExportOption func(*ExportOptions)
+
+// WithExportFields 设置导出的字段，CSV 的列和 JSON Lines 对象的键按 fields 的顺序输出
+func WithExportFields(fields ...string) ExportOption {
+	return func(opts *ExportOptions) {
+		opts.Fields = fields
+	}
+}
+
+// WithExportOrderBy 设置导出记录的排序字段
+func WithExportOrderBy(field string, desc bool) ExportOption {
+	return func(opts *ExportOptions) {
+		opts.QueryOpts = append(opts.QueryOpts, func(o *QueryOptions) {
+			o.OrderBy = field
+			o.OrderDesc = desc
+		})
+	}
+}
+
+// WithExportQueryOptions 设置查询选项
+func WithExportQueryOptions(opts ...QueryOption) ExportOption {
+	return func(o *ExportOptions) {
+		o.QueryOpts = append(o.QueryOpts, opts...)
+	}
+}
+
+// Export 通过 FindStream 流式查询 table 中满足 q 的记录，以 CSV 或 JSON Lines 写入 w，返回导出的记录数
+//
+// 格式与 Load 相同，导出的数据可以直接用 Load 导入。CSV 第一行为列名，未指定 Fields 时取第一条记录的字段并按名称排序，
+// 之后的记录缺少的字段输出为空，多出的字段忽略；没有记录且未指定 Fields 时不输出任何内容。
+// CSV 中时间格式化为 RFC3339，map、切片等复合类型输出为 JSON；JSON Lines 中 []byte 输出为字符串
+func Export(ctx context.Context, db Database, table string, q query.Query, w io.Writer, format LoadFormat, opts ...ExportOption) (int, error) {
+	options := &ExportOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	var writer exportWriter
+	switch format {
+	case LoadFormatCSV:
+		writer = &csvExportWriter{writer: csv.NewWriter(w), header: options.Fields}
+	case LoadFormatJSONL:
+		writer = &jsonExportWriter{writer: bufio.NewWriter(w), fields: options.Fields}
+	default:
+		return 0, errors.Errorf("unsupported export format %q", format)
+	}
+
+	cursor, err := db.FindStream(ctx, table, q, options.QueryOpts...)
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close()
+
+	count := 0
+	for cursor.Next() {
+		if err := writer.write(cursor.Record().Fields()); err != nil {
+			return count, errors.WithMessage(err, "failed to write record")
+		}
+		count++
+	}
+	if err := cursor.Err(); err != nil {
+		return count, err
+	}
+	if err := writer.flush(); err != nil {
+		return count, errors.WithMessage(err, "failed to write record")
+	}
+	return count, nil
+}
+
+// exportWriter 按格式输出记录
+type exportWriter interface {
+	write(fields map[string]any) error
+	flush() error
+}
+
+type csvExportWriter struct {
+	writer      *csv.Writer
+	header      []string
+	wroteHeader bool
+}
+
+func (e *csvExportWriter) write(fields map[string]any) error {
+	if !e.wroteHeader {
+		if len(e.header) == 0 {
+			e.header = sortedKeys(fields)
+		}
+		if err := e.writeHeader(); err != nil {
+			return err
+		}
+	}
+
+	row := make([]string, len(e.header))
+	for i, field := range e.header {
+		value, err := formatExportValue(fields[field])
+		if err != nil {
+			return errors.WithMessagef(err, "field %s", field)
+		}
+		row[i] = value
+	}
+	return e.writer.Write(row)
+}
+
+func (e *csvExportWriter) writeHeader() error {
+	e.wroteHeader = true
+	return e.writer.Write(e.header)
+}
+
+func (e *csvExportWriter) flush() error {
+	// 指定了字段时没有记录也输出列名
+	if !e.wroteHeader && len(e.header) > 0 {
+		if err := e.writeHeader(); err != nil {
+			return err
+		}
+	}
+	e.writer.Flush()
+	return e.writer.Error()
+}
+
+// formatExportValue 将字段值格式化为 CSV 中的字符串，nil 输出为空
+func formatExportValue(value any) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case []byte:
+		return string(v), nil
+	case time.Time:
+		return v.Format(time.RFC3339Nano), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprint(v), nil
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	}
+
+	// ObjectID 等实现了 json.Marshaler 的类型输出为字符串时去掉引号
+	data, err := json.Marshal(exportJSONValue(value))
+	if err != nil {
+		return "", err
+	}
+	var s string
+	if json.Unmarshal(data, &s) == nil {
+		return s, nil
+	}
+	return string(data), nil
+}
+
+type jsonExportWriter struct {
+	writer *bufio.Writer
+	fields []string
+}
+
+func (e *jsonExportWriter) write(fields map[string]any) error {
+	if len(e.fields) == 0 {
+		data, err := json.Marshal(exportJSONValue(fields))
+		if err != nil {
+			return err
+		}
+		return e.writeLine(data)
+	}
+
+	// 按指定的字段顺序输出，json.Marshal 对 map 的键排序，无法保留顺序
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, field := range e.fields {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(field)
+		value, err := json.Marshal(exportJSONValue(fields[field]))
+		if err != nil {
+			return errors.WithMessagef(err, "field %s", field)
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return e.writeLine(buf.Bytes())
+}
+
+func (e *jsonExportWriter) writeLine(data []byte) error {
+	if _, err := e.writer.Write(data); err != nil {
+		return err
+	}
+	return e.writer.WriteByte('\n')
+}
+
+func (e *jsonExportWriter) flush() error {
+	return e.writer.Flush()
+}
+
+// exportJSONValue 将 []byte 转换为字符串，避免 json.Marshal 输出为 base64
+func exportJSONValue(value any) any {
+	switch v := value.(type) {
+	case []byte:
+		return string(v)
+	case map[string]any:
+		result := make(map[string]any, len(v))
+		for key, item := range v {
+			result[key] = exportJSONValue(item)
+		}
+		return result
+	case []any:
+		result := make([]any, len(v))
+		for i, item := range v {
+			result[i] = exportJSONValue(item)
+		}
+		return result
+	default:
+		return value
+	}
+}
@@ -0,0 +1,134 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hatlonely/gox/rdb/query"
+	. "github.com/smartystreets/goconvey/convey"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestExport(t *testing.T) {
+	Convey("测试导出 CSV、JSON Lines", t, func() {
+		ctx := context.Background()
+		db, err := NewSQLWithOptions(&SQLOptions{Driver: "sqlite3", Database: ":memory:", MaxConns: 1, MaxIdle: 1})
+		So(err, ShouldBeNil)
+		defer db.Close()
+
+		model := func(table string) *TableModel {
+			return &TableModel{
+				Table: table,
+				Fields: []FieldDefinition{
+					{Name: "id", Type: FieldTypeInt, Required: true},
+					{Name: "name", Type: FieldTypeString, Size: 100, Required: true},
+					{Name: "age", Type: FieldTypeInt},
+				},
+				PrimaryKey: []string{"id"},
+			}
+		}
+		So(db.Migrate(ctx, model("export_users")), ShouldBeNil)
+		builder := db.GetBuilder()
+		for _, fields := range []map[string]any{
+			{"id": 1, "name": "alice", "age": 30},
+			{"id": 2, "name": "bob, jr", "age": 20},
+			{"id": 3, "name": "carol"},
+		} {
+			So(db.Create(ctx, "export_users", builder.FromMap(fields, "export_users")), ShouldBeNil)
+		}
+		all := &query.ExistsQuery{Field: "id"}
+
+		Convey("CSV 按指定字段和排序导出", func() {
+			var buf bytes.Buffer
+			n, err := Export(ctx, db, "export_users", all, &buf, LoadFormatCSV,
+				WithExportFields("name", "id", "age"), WithExportOrderBy("id", true))
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 3)
+			So(buf.String(), ShouldEqual, "name,id,age\ncarol,3,\n\"bob, jr\",2,20\nalice,1,30\n")
+		})
+
+		Convey("CSV 未指定字段时按名称排序", func() {
+			var buf bytes.Buffer
+			n, err := Export(ctx, db, "export_users", &query.TermQuery{Field: "id", Value: 1}, &buf, LoadFormatCSV)
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 1)
+			So(buf.String(), ShouldEqual, "age,id,name\n30,1,alice\n")
+
+			buf.Reset()
+			n, err = Export(ctx, db, "export_users", &query.TermQuery{Field: "id", Value: 100}, &buf, LoadFormatCSV)
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 0)
+			So(buf.String(), ShouldEqual, "")
+
+			n, err = Export(ctx, db, "export_users", &query.TermQuery{Field: "id", Value: 100}, &buf, LoadFormatCSV, WithExportFields("id"))
+			So(err, ShouldBeNil)
+			So(buf.String(), ShouldEqual, "id\n")
+		})
+
+		Convey("JSON Lines 按字段顺序输出", func() {
+			var buf bytes.Buffer
+			_, err := Export(ctx, db, "export_users", all, &buf, LoadFormatJSONL,
+				WithExportFields("name", "id"), WithExportOrderBy("id", false), WithExportQueryOptions(func(o *QueryOptions) { o.Limit = 2 }))
+			So(err, ShouldBeNil)
+			So(buf.String(), ShouldEqual, "{\"name\":\"alice\",\"id\":1}\n{\"name\":\"bob, jr\",\"id\":2}\n")
+		})
+
+		Convey("导出的数据可以用 Load 导入", func() {
+			So(db.Migrate(ctx, model("export_users_copy")), ShouldBeNil)
+			for _, format := range []LoadFormat{LoadFormatCSV, LoadFormatJSONL} {
+				So(db.DropTable(ctx, "export_users_copy"), ShouldBeNil)
+				So(db.Migrate(ctx, model("export_users_copy")), ShouldBeNil)
+
+				var buf bytes.Buffer
+				_, err := Export(ctx, db, "export_users", &query.RangeQuery{Field: "id", Lte: 2}, &buf, format)
+				So(err, ShouldBeNil)
+				result, err := Load(ctx, db, "export_users_copy", &buf, format)
+				So(err, ShouldBeNil)
+				So(result.Loaded, ShouldEqual, 2)
+
+				record, err := db.Get(ctx, "export_users_copy", map[string]any{"id": 2})
+				So(err, ShouldBeNil)
+				So(record.Fields()["name"], ShouldEqual, "bob, jr")
+				So(record.Fields()["age"], ShouldEqual, 20)
+			}
+		})
+
+		Convey("格式化字段值", func() {
+			ts := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+			id := primitive.NewObjectID()
+			for value, expected := range map[any]string{
+				"a":       "a",
+				true:      "true",
+				1.5:       "1.5",
+				ts:        "2024-01-02T03:04:05Z",
+				id:        id.Hex(),
+				nil:       "",
+				int64(42): "42",
+			} {
+				s, err := formatExportValue(value)
+				So(err, ShouldBeNil)
+				So(s, ShouldEqual, expected)
+			}
+			s, err := formatExportValue(map[string]any{"tags": []any{"x"}})
+			So(err, ShouldBeNil)
+			So(s, ShouldEqual, `{"tags":["x"]}`)
+		})
+
+		Convey("参数错误", func() {
+			var buf bytes.Buffer
+			_, err := Export(ctx, db, "export_users", all, &buf, LoadFormat("xml"))
+			So(err, ShouldNotBeNil)
+			_, err = Export(ctx, db, "export_users", all, errWriter{}, LoadFormatCSV)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+type errWriter struct{}
+
+func (errWriter) Write([]byte) (int, error) {
+	return 0, errors.New("disk full")
+}
@@ -0,0 +1,100 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"runtime"
+	"sync"
+
+	"github.com/hatlonely/gox/rdb/query"
+)
+
+// parallelScanThreshold 记录数达到该值时并行解码
+const parallelScanThreshold = 512
+
+// FindInto 查询记录并解码到 dest 指向的切片，等价于 Find 之后对每条记录调用 Scan
+// dest 必须是 *[]T 或 *[]*T，T 为结构体；切片按结果数预先分配，原有内容被覆盖
+// 结果较多时按 GOMAXPROCS 并行解码，任意一条记录解码失败时返回错误，dest 不做修改
+func FindInto(ctx context.Context, db Database, table string, q query.Query, dest any, opts ...QueryOption) error {
+	rv := reflect.ValueOf(dest)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("dest must be a pointer to slice, got %T", dest)
+	}
+	sliceType := rv.Elem().Type()
+	elemType := sliceType.Elem()
+	isPtr := elemType.Kind() == reflect.Ptr
+	structType := elemType
+	if isPtr {
+		structType = elemType.Elem()
+	}
+	if structType.Kind() != reflect.Struct {
+		return fmt.Errorf("dest must be a pointer to slice of struct, got %T", dest)
+	}
+
+	records, err := db.Find(ctx, table, q, opts...)
+	if err != nil {
+		return err
+	}
+
+	slice := reflect.MakeSlice(sliceType, len(records), len(records))
+	scan := func(i int) error {
+		item := slice.Index(i)
+		if isPtr {
+			item.Set(reflect.New(structType))
+		} else {
+			item = item.Addr()
+		}
+		if err := records[i].Scan(item.Interface()); err != nil {
+			return fmt.Errorf("failed to scan record %d: %w", i, err)
+		}
+		return nil
+	}
+
+	if err := scanRecords(len(records), scan); err != nil {
+		return err
+	}
+	rv.Elem().Set(slice)
+	return nil
+}
+
+// scanRecords 对 [0, n) 逐个调用 scan，n 较大时分段并行，返回下标最小的错误
+func scanRecords(n int, scan func(i int) error) error {
+	workers := runtime.GOMAXPROCS(0)
+	if n < parallelScanThreshold || workers <= 1 {
+		for i := 0; i < n; i++ {
+			if err := scan(i); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	chunk := (n + workers - 1) / workers
+	errs := make([]error, workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		start, end := w*chunk, min((w+1)*chunk, n)
+		if start >= end {
+			break
+		}
+		wg.Add(1)
+		go func(w, start, end int) {
+			defer wg.Done()
+			for i := start; i < end; i++ {
+				if err := scan(i); err != nil {
+					errs[w] = err
+					return
+				}
+			}
+		}(w, start, end)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
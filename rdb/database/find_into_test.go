@@ -0,0 +1,85 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hatlonely/gox/rdb/query"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestFindInto(t *testing.T) {
+	type User struct {
+		ID   int64  `rdb:"id"`
+		Name string `rdb:"name"`
+		Age  int    `rdb:"age"`
+	}
+
+	Convey("测试查询结果解码到切片", t, func() {
+		ctx := context.Background()
+		db, err := NewSQLWithOptions(&SQLOptions{Driver: "sqlite3", Database: ":memory:", MaxConns: 1, MaxIdle: 1})
+		So(err, ShouldBeNil)
+		defer db.Close()
+
+		So(db.Migrate(ctx, &TableModel{
+			Table: "into_users",
+			Fields: []FieldDefinition{
+				{Name: "id", Type: FieldTypeInt, Required: true},
+				{Name: "name", Type: FieldTypeString},
+				{Name: "age", Type: FieldTypeInt},
+			},
+			PrimaryKey: []string{"id"},
+		}), ShouldBeNil)
+
+		// 超过并行解码的阈值
+		n := parallelScanThreshold + 100
+		builder := db.GetBuilder()
+		records := make([]Record, 0, n)
+		for i := 1; i <= n; i++ {
+			records = append(records, builder.FromMap(map[string]any{"id": i, "name": "user", "age": i % 50}, "into_users"))
+		}
+		So(db.BatchCreate(ctx, "into_users", records), ShouldBeNil)
+		orderByID := func(o *QueryOptions) { o.OrderBy = "id" }
+
+		Convey("解码到结构体切片", func() {
+			users := []User{{ID: -1}}
+			So(FindInto(ctx, db, "into_users", &query.BoolQuery{}, &users, orderByID), ShouldBeNil)
+			So(len(users), ShouldEqual, n)
+			for i, user := range users {
+				So(user.ID, ShouldEqual, i+1)
+				So(user.Age, ShouldEqual, (i+1)%50)
+			}
+		})
+
+		Convey("解码到结构体指针切片", func() {
+			var users []*User
+			So(FindInto(ctx, db, "into_users", &query.RangeQuery{Field: "id", Lte: 3}, &users, orderByID), ShouldBeNil)
+			So(users, ShouldResemble, []*User{{ID: 1, Name: "user", Age: 1}, {ID: 2, Name: "user", Age: 2}, {ID: 3, Name: "user", Age: 3}})
+		})
+
+		Convey("没有结果时为空切片", func() {
+			users := []User{{ID: 1}}
+			So(FindInto(ctx, db, "into_users", &query.TermQuery{Field: "id", Value: -1}, &users), ShouldBeNil)
+			So(users, ShouldNotBeNil)
+			So(users, ShouldBeEmpty)
+		})
+
+		Convey("解码失败时不修改 dest", func() {
+			type BadUser struct {
+				Name []int `rdb:"name"`
+			}
+			users := []BadUser{{Name: []int{1}}}
+			err := FindInto(ctx, db, "into_users", &query.BoolQuery{}, &users)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "failed to scan record")
+			So(users, ShouldResemble, []BadUser{{Name: []int{1}}})
+		})
+
+		Convey("dest 类型不合法", func() {
+			var users []User
+			So(FindInto(ctx, db, "into_users", &query.BoolQuery{}, users), ShouldNotBeNil)
+			var ids []int
+			So(FindInto(ctx, db, "into_users", &query.BoolQuery{}, &ids), ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,212 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/hatlonely/gox/rdb/aggregation"
+	"github.com/hatlonely/gox/rdb/query"
+)
+
+// GroupByResultName 设置 GroupBy 时 Aggregate 返回的分组统计结果的名称，通过 GetDocuments 读取
+const GroupByResultName = "group_by"
+
+// GroupCountField 分组统计结果中每组的文档数，Having 和 OrderBy 中通过该名称引用
+const GroupCountField = "_count"
+
+// WithGroupBy 按 fields 分组统计，Aggregate 中的指标聚合和派生指标按组计算，
+// 每组一行，包含分组字段、GroupCountField 和各聚合的结果，通过 result.GetDocuments(GroupByResultName) 读取
+//
+// 默认按分组字段升序，OrderBy 可以是分组字段、指标聚合名或 GroupCountField，Limit、Offset 作用于分组
+func WithGroupBy(fields ...string) QueryOption {
+	return func(opts *QueryOptions) {
+		opts.GroupBy = fields
+	}
+}
+
+// WithHaving 过滤分组统计的结果，只支持 TermQuery、RangeQuery 和 BoolQuery，字段为指标聚合名或 GroupCountField
+// 分组字段的条件放到查询条件中，在分组之前过滤
+func WithHaving(having query.Query) QueryOption {
+	return func(opts *QueryOptions) {
+		opts.Having = having
+	}
+}
+
+// groupBy 分组统计的参数，由 newGroupBy 校验
+type groupBy struct {
+	fields  []string
+	metrics []aggregation.Aggregation
+	scripts []aggregation.ScriptAggregator
+	having  query.Query
+	options *QueryOptions
+}
+
+// newGroupBy 校验分组统计的参数，分组统计只支持指标聚合和派生指标
+func newGroupBy(aggs []aggregation.Aggregation, options *QueryOptions) (*groupBy, error) {
+	if len(options.GroupBy) == 0 {
+		return nil, errors.New("having requires group by")
+	}
+	if pipelines, _ := aggregation.SplitPipelineAggregations(aggs); len(pipelines) > 0 {
+		return nil, errors.Errorf("group by does not support %s aggregation %s", pipelines[0].Type(), pipelines[0].Name())
+	}
+	scripts, aggs := aggregation.SplitScriptAggregations(aggs)
+	metrics, buckets := aggregation.SplitAggregations(aggs)
+	if len(buckets) > 0 {
+		return nil, errors.Errorf("group by does not support %s aggregation %s", buckets[0].Type(), buckets[0].Name())
+	}
+
+	group := &groupBy{fields: options.GroupBy, metrics: metrics, scripts: scripts, having: options.Having, options: options}
+	columns := map[string]bool{GroupCountField: true}
+	for _, field := range group.fields {
+		if field == "" || columns[field] {
+			return nil, errors.Errorf("invalid group by field %q", field)
+		}
+		columns[field] = true
+	}
+	metricNames := map[string]bool{GroupCountField: true}
+	for _, metric := range metrics {
+		if metric.Name() == "" || columns[metric.Name()] {
+			return nil, errors.Errorf("invalid group by aggregation name %q", metric.Name())
+		}
+		columns[metric.Name()] = true
+		metricNames[metric.Name()] = true
+	}
+
+	if err := validateHaving(group.having, metricNames); err != nil {
+		return nil, err
+	}
+	if options.OrderBy != "" && !columns[options.OrderBy] {
+		return nil, errors.Errorf("group by order field %s is neither a group field nor an aggregation", options.OrderBy)
+	}
+	return group, nil
+}
+
+// grouping 是否按 GroupBy 分组统计，只设置 Having 时同样返回 true，由 newGroupBy 报错
+func (o *QueryOptions) grouping() bool {
+	return len(o.GroupBy) > 0 || o.Having != nil
+}
+
+// validateHaving 校验 Having 的查询类型和字段，字段只能是指标聚合名或 GroupCountField
+func validateHaving(having query.Query, metrics map[string]bool) error {
+	var field string
+	switch v := having.(type) {
+	case nil:
+		return nil
+	case *query.BoolQuery:
+		for _, group := range [][]query.Query{v.Must, v.Should, v.MustNot, v.Filter} {
+			for _, sub := range group {
+				if err := validateHaving(sub, metrics); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	case *query.TermQuery:
+		field = v.Field
+	case *query.RangeQuery:
+		field = v.Field
+	default:
+		return errors.Errorf("having does not support %s query", having.Type())
+	}
+	if !metrics[field] {
+		return errors.Errorf("having field %s is not an aggregation", field)
+	}
+	return nil
+}
+
+// sortKeys 分组结果的排序字段，OrderBy 之后按分组字段排序保证顺序稳定
+func (g *groupBy) sortKeys() []string {
+	keys := make([]string, 0, len(g.fields)+1)
+	if g.options.OrderBy != "" {
+		keys = append(keys, g.options.OrderBy)
+	}
+	for _, field := range g.fields {
+		if field != g.options.OrderBy {
+			keys = append(keys, field)
+		}
+	}
+	return keys
+}
+
+// row 将后端返回的一组统计结果转换为结果行，统一文档数的类型并计算派生指标
+func (g *groupBy) row(values map[string]any) (map[string]any, error) {
+	row := make(map[string]any, len(g.fields)+len(g.metrics)+len(g.scripts)+1)
+	for _, field := range g.fields {
+		row[field] = values[field]
+	}
+	row[GroupCountField] = toInt64(values[GroupCountField])
+	for _, metric := range g.metrics {
+		if value, ok := values[metric.Name()]; ok {
+			row[metric.Name()] = value
+		}
+	}
+	get := func(aggName string) any { return row[aggName] }
+	set := func(aggName string, value any) { row[aggName] = value }
+	if err := applyScriptAggregations(g.scripts, get, set); err != nil {
+		return nil, err
+	}
+	return row, nil
+}
+
+// result 将结果行包装为聚合结果
+func (g *groupBy) result(rows []map[string]any) aggregation.AggregationResult {
+	if rows == nil {
+		rows = []map[string]any{}
+	}
+	result := aggregation.NewAggregationResult()
+	result.SetResult(GroupByResultName, rows)
+	return result
+}
+
+// page 在客户端排序和分页，用于无法在服务端对全部分组排序的后端
+func (g *groupBy) page(rows []map[string]any) []map[string]any {
+	keys := g.sortKeys()
+	sort.SliceStable(rows, func(i, j int) bool {
+		for n, key := range keys {
+			c := compareGroupValues(rows[i][key], rows[j][key])
+			if c == 0 {
+				continue
+			}
+			if n == 0 && g.options.OrderBy != "" && g.options.OrderDesc {
+				return c > 0
+			}
+			return c < 0
+		}
+		return false
+	})
+
+	if g.options.Offset > 0 {
+		rows = rows[min(g.options.Offset, len(rows)):]
+	}
+	if g.options.Limit > 0 && len(rows) > g.options.Limit {
+		rows = rows[:g.options.Limit]
+	}
+	return rows
+}
+
+// compareGroupValues 比较两个分组值，数值按大小比较，其余按字符串形式比较，nil 最小
+func compareGroupValues(a, b any) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	}
+	if x, ok := toFloat64(a); ok {
+		if y, ok := toFloat64(b); ok {
+			switch {
+			case x < y:
+				return -1
+			case x > y:
+				return 1
+			}
+			return 0
+		}
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
@@ -0,0 +1,168 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/hatlonely/gox/rdb/aggregation"
+	"github.com/hatlonely/gox/rdb/query"
+	. "github.com/smartystreets/goconvey/convey"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestGroupBy(t *testing.T) {
+	total := &aggregation.SumAggregation{MetricAggregation: aggregation.MetricAggregation{AggName: "total", Field: "amount"}}
+	maxAmount := &aggregation.MaxAggregation{MetricAggregation: aggregation.MetricAggregation{AggName: "max_amount", Field: "amount"}}
+	average := &aggregation.BucketScriptAggregation{AggName: "average", Script: "total / _count"}
+	aggs := []aggregation.Aggregation{total, maxAmount, average}
+
+	Convey("测试 SQLite 分组统计", t, func() {
+		ctx := context.Background()
+		db, err := NewSQLWithOptions(&SQLOptions{Driver: "sqlite3", Database: ":memory:", MaxConns: 1, MaxIdle: 1})
+		So(err, ShouldBeNil)
+		defer db.Close()
+
+		So(db.Migrate(ctx, &TableModel{
+			Table: "group_orders",
+			Fields: []FieldDefinition{
+				{Name: "id", Type: FieldTypeInt, Required: true},
+				{Name: "city", Type: FieldTypeString, Size: 50},
+				{Name: "status", Type: FieldTypeString, Size: 20},
+				{Name: "amount", Type: FieldTypeInt},
+			},
+			PrimaryKey: []string{"id"},
+		}), ShouldBeNil)
+		for i, order := range []map[string]any{
+			{"city": "beijing", "status": "paid", "amount": 100},
+			{"city": "beijing", "status": "paid", "amount": 300},
+			{"city": "beijing", "status": "refund", "amount": 50},
+			{"city": "shanghai", "status": "paid", "amount": 200},
+			{"city": "shenzhen", "status": "paid", "amount": 10},
+			{"city": "shenzhen", "status": "paid", "amount": 20},
+		} {
+			order["id"] = i + 1
+			So(db.Create(ctx, "group_orders", db.GetBuilder().FromMap(order, "group_orders")), ShouldBeNil)
+		}
+		paid := &query.TermQuery{Field: "status", Value: "paid"}
+
+		Convey("按字段分组统计指标和派生指标", func() {
+			result, err := db.Aggregate(ctx, "group_orders", paid, aggs, WithGroupBy("city"))
+			So(err, ShouldBeNil)
+			rows := result.GetDocuments(GroupByResultName)
+			So(len(rows), ShouldEqual, 3)
+			So(rows[0], ShouldResemble, map[string]any{
+				"city": "beijing", GroupCountField: int64(2), "total": int64(400), "max_amount": int64(300), "average": float64(200),
+			})
+			So(rows[1]["city"], ShouldEqual, "shanghai")
+			So(rows[2]["city"], ShouldEqual, "shenzhen")
+		})
+
+		Convey("Having 过滤分组，按指标排序分页", func() {
+			having := &query.BoolQuery{Should: []query.Query{
+				&query.RangeQuery{Field: "total", Gte: 200},
+				&query.TermQuery{Field: GroupCountField, Value: 2},
+			}}
+			result, err := db.Aggregate(ctx, "group_orders", paid, aggs, WithGroupBy("city"), WithHaving(having),
+				func(opts *QueryOptions) { opts.OrderBy = "total"; opts.OrderDesc = true })
+			So(err, ShouldBeNil)
+			rows := result.GetDocuments(GroupByResultName)
+			So(len(rows), ShouldEqual, 3)
+			So([]any{rows[0]["city"], rows[1]["city"], rows[2]["city"]}, ShouldResemble, []any{"beijing", "shanghai", "shenzhen"})
+
+			result, err = db.Aggregate(ctx, "group_orders", paid, aggs, WithGroupBy("city"),
+				WithHaving(&query.RangeQuery{Field: "total", Gt: 100}), func(opts *QueryOptions) { opts.Limit = 1; opts.Offset = 1 })
+			So(err, ShouldBeNil)
+			rows = result.GetDocuments(GroupByResultName)
+			So(len(rows), ShouldEqual, 1)
+			So(rows[0]["city"], ShouldEqual, "shanghai")
+		})
+
+		Convey("多个分组字段", func() {
+			result, err := db.Aggregate(ctx, "group_orders", &query.TermQuery{Field: "city", Value: "beijing"},
+				[]aggregation.Aggregation{total}, WithGroupBy("city", "status"))
+			So(err, ShouldBeNil)
+			rows := result.GetDocuments(GroupByResultName)
+			So(len(rows), ShouldEqual, 2)
+			So(rows[1], ShouldResemble, map[string]any{"city": "beijing", "status": "refund", GroupCountField: int64(1), "total": int64(50)})
+		})
+
+		Convey("参数错误", func() {
+			terms := &aggregation.TermsAggregation{BucketAggregation: aggregation.BucketAggregation{AggName: "by_city", Field: "city"}}
+			for _, opts := range [][]QueryOption{
+				{WithHaving(&query.RangeQuery{Field: "total", Gt: 1})},
+				{WithGroupBy("city"), WithHaving(&query.RangeQuery{Field: "city", Gt: 1})},
+				{WithGroupBy("city"), WithHaving(&query.PrefixQuery{Field: "total", Value: "1"})},
+				{WithGroupBy("city"), func(opts *QueryOptions) { opts.OrderBy = "amount" }},
+				{WithGroupBy("city", "city")},
+				{WithGroupBy("city; DROP TABLE group_orders")},
+			} {
+				_, err := db.Aggregate(ctx, "group_orders", paid, []aggregation.Aggregation{total}, opts...)
+				So(err, ShouldNotBeNil)
+			}
+			_, err := db.Aggregate(ctx, "group_orders", paid, []aggregation.Aggregation{terms}, WithGroupBy("city"))
+			So(err, ShouldNotBeNil)
+		})
+	})
+
+	Convey("测试 Mongo 分组统计管道", t, func() {
+		group, err := newGroupBy(aggs, &QueryOptions{
+			GroupBy: []string{"city", "status"}, Having: &query.RangeQuery{Field: GroupCountField, Gte: 2},
+			OrderBy: "total", OrderDesc: true, Limit: 10, Offset: 5,
+		})
+		So(err, ShouldBeNil)
+		pipeline, err := mongoGroupByPipeline([]bson.M{{"$match": bson.M{"status": "paid"}}}, group)
+		So(err, ShouldBeNil)
+		So(len(pipeline), ShouldEqual, 6)
+		So(pipeline[1]["$group"], ShouldResemble, bson.M{
+			"_id":           bson.M{"_key0": "$city", "_key1": "$status"},
+			GroupCountField: bson.M{"$sum": 1},
+			"total":         map[string]interface{}{"$sum": "$amount"},
+			"max_amount":    map[string]interface{}{"$max": "$amount"},
+		})
+		So(pipeline[2], ShouldResemble, bson.M{"$match": map[string]interface{}{GroupCountField: map[string]interface{}{"$gte": 2}}})
+		So(pipeline[3], ShouldResemble, bson.M{"$sort": bson.D{{Key: "total", Value: -1}, {Key: "_id._key0", Value: 1}, {Key: "_id._key1", Value: 1}}})
+		So(pipeline[4], ShouldResemble, bson.M{"$skip": 5})
+		So(pipeline[5], ShouldResemble, bson.M{"$limit": 10})
+	})
+
+	Convey("测试 ES 分组统计请求", t, func() {
+		having := &query.BoolQuery{
+			Must:    []query.Query{&query.RangeQuery{Field: "total", Gt: 100, Lte: 1000.5}},
+			Should:  []query.Query{&query.TermQuery{Field: GroupCountField, Value: 2}, &query.RangeQuery{Field: "max_amount", Gte: 300}},
+			MustNot: []query.Query{&query.TermQuery{Field: "total", Value: 500}},
+		}
+		group, err := newGroupBy(aggs, &QueryOptions{GroupBy: []string{"city"}, Having: having})
+		So(err, ShouldBeNil)
+		body, err := esGroupBySearchBody(&query.TermQuery{Field: "status", Value: "paid"}, group, map[string]any{"_key0": "beijing"})
+		So(err, ShouldBeNil)
+		groupAgg := body["aggs"].(map[string]any)[GroupByResultName].(map[string]any)
+		So(groupAgg["aggs"].(map[string]any)[esHavingAggName], ShouldResemble, map[string]any{
+			"bucket_selector": map[string]any{
+				"buckets_path": map[string]any{"v0": "total", "v1": GroupCountField, "v2": "max_amount"},
+				"script":       "(params.v0 > 100 && params.v0 <= 1000.5) && ((params.v1 == 2) || (params.v2 >= 300)) && !(params.v0 == 500)",
+			},
+		})
+		data, err := json.Marshal(groupAgg["composite"])
+		So(err, ShouldBeNil)
+		So(string(data), ShouldEqual, `{"after":{"_key0":"beijing"},"size":1000,"sources":[{"_key0":{"terms":{"field":"city","missing_bucket":true}}}]}`)
+
+		_, err = esHavingSelector(&query.TermQuery{Field: "total", Value: "high"})
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("测试客户端排序分页", t, func() {
+		rows := []map[string]any{
+			{"city": "c", "total": float64(10)},
+			{"city": nil, "total": float64(30)},
+			{"city": "a", "total": float64(30)},
+			{"city": "b", "total": float64(20)},
+		}
+		group, err := newGroupBy(aggs, &QueryOptions{GroupBy: []string{"city"}, OrderBy: "total", OrderDesc: true, Offset: 1, Limit: 2})
+		So(err, ShouldBeNil)
+		page := group.page(rows)
+		So(len(page), ShouldEqual, 2)
+		So(page[0]["city"], ShouldEqual, "a")
+		So(page[1]["city"], ShouldEqual, "b")
+	})
+}
@@ -0,0 +1,70 @@
+package database
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/hatlonely/gox/cfg/commonopt"
+)
+
+// RetryOptions 启动时建立连接的重试策略
+type RetryOptions = commonopt.RetryOptions
+
+// retryConnect 按重试策略执行 connect，直到成功或达到最大尝试次数，返回最后一次的错误
+func retryConnect(options RetryOptions, connect func() error) error {
+	return options.Do(context.Background(), connect)
+}
+
+// healthChecker 定期执行健康检查，检查失败时调用 onFailure 尝试恢复（例如重建客户端）
+type healthChecker struct {
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// startHealthChecker 启动健康检查，interval 小于等于 0 时不启动并返回 nil
+// 每次检查和恢复的超时时间与检查间隔相同，onFailure 可以为 nil
+func startHealthChecker(interval time.Duration, check func(ctx context.Context) error, onFailure func(ctx context.Context)) *healthChecker {
+	if interval <= 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	h := &healthChecker{cancel: cancel}
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			checkCtx, checkCancel := context.WithTimeout(ctx, interval)
+			err := check(checkCtx)
+			checkCancel()
+			if err == nil || onFailure == nil || ctx.Err() != nil {
+				continue
+			}
+
+			recoverCtx, recoverCancel := context.WithTimeout(ctx, interval)
+			onFailure(recoverCtx)
+			recoverCancel()
+		}
+	}()
+
+	return h
+}
+
+// stop 停止健康检查并等待正在执行的检查结束，nil checker 上调用是空操作
+func (h *healthChecker) stop() {
+	if h == nil {
+		return
+	}
+	h.cancel()
+	h.wg.Wait()
+}
@@ -0,0 +1,80 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRetryConnect(t *testing.T) {
+	Convey("测试连接重试", t, func() {
+		Convey("成功后不再重试", func() {
+			calls := 0
+			err := retryConnect(RetryOptions{MaxAttempts: 5, Backoff: time.Millisecond}, func() error {
+				calls++
+				if calls < 3 {
+					return errors.New("connection refused")
+				}
+				return nil
+			})
+			So(err, ShouldBeNil)
+			So(calls, ShouldEqual, 3)
+		})
+
+		Convey("达到最大次数后返回最后一次的错误", func() {
+			calls := 0
+			err := retryConnect(RetryOptions{MaxAttempts: 3, Backoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond}, func() error {
+				calls++
+				return errors.New("connection refused")
+			})
+			So(err, ShouldNotBeNil)
+			So(calls, ShouldEqual, 3)
+		})
+
+		Convey("未配置重试时只尝试一次", func() {
+			calls := 0
+			err := retryConnect(RetryOptions{}, func() error {
+				calls++
+				return errors.New("connection refused")
+			})
+			So(err, ShouldNotBeNil)
+			So(calls, ShouldEqual, 1)
+		})
+	})
+}
+
+func TestHealthChecker(t *testing.T) {
+	Convey("测试定期健康检查", t, func() {
+		Convey("检查失败时调用恢复函数", func() {
+			var checks, recoveries atomic.Int32
+			checker := startHealthChecker(5*time.Millisecond, func(ctx context.Context) error {
+				if checks.Add(1)%2 == 0 {
+					return errors.New("ping failed")
+				}
+				return nil
+			}, func(ctx context.Context) {
+				recoveries.Add(1)
+			})
+			time.Sleep(50 * time.Millisecond)
+			checker.stop()
+
+			n := recoveries.Load()
+			So(checks.Load(), ShouldBeGreaterThan, 2)
+			So(n, ShouldBeGreaterThan, 0)
+
+			// 停止后不再检查
+			time.Sleep(20 * time.Millisecond)
+			So(recoveries.Load(), ShouldEqual, n)
+		})
+
+		Convey("间隔为 0 时不启动", func() {
+			checker := startHealthChecker(0, func(ctx context.Context) error { return nil }, nil)
+			So(checker, ShouldBeNil)
+			checker.stop()
+		})
+	})
+}
@@ -0,0 +1,118 @@
+package database
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"reflect"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ErrUnknownIDStrategy 不支持的主键生成策略
+var ErrUnknownIDStrategy = errors.New("unknown id strategy")
+
+// IDStrategy 主键生成策略，Create 时主键为空则按策略生成字符串主键，各后端生成的主键格式一致
+type IDStrategy string
+
+const (
+	IDStrategyUUIDv4   IDStrategy = "uuidv4"   // 随机 UUID，无序
+	IDStrategyUUIDv7   IDStrategy = "uuidv7"   // 以毫秒时间戳开头的 UUID，按创建时间有序
+	IDStrategyKSUID    IDStrategy = "ksuid"    // 27 位 base62 字符串，按秒有序
+	IDStrategyObjectID IDStrategy = "objectid" // 24 位十六进制字符串，与 MongoDB ObjectID 格式一致，按秒有序
+)
+
+// Valid 是否为支持的主键生成策略
+func (s IDStrategy) Valid() bool {
+	switch s {
+	case IDStrategyUUIDv4, IDStrategyUUIDv7, IDStrategyKSUID, IDStrategyObjectID:
+		return true
+	}
+	return false
+}
+
+// Generate 按策略生成一个主键
+func (s IDStrategy) Generate() (string, error) {
+	switch s {
+	case IDStrategyUUIDv4:
+		id, err := uuid.NewRandom()
+		if err != nil {
+			return "", fmt.Errorf("failed to generate uuidv4: %v", err)
+		}
+		return id.String(), nil
+	case IDStrategyUUIDv7:
+		id, err := uuid.NewV7()
+		if err != nil {
+			return "", fmt.Errorf("failed to generate uuidv7: %v", err)
+		}
+		return id.String(), nil
+	case IDStrategyKSUID:
+		return newKSUID(time.Now())
+	case IDStrategyObjectID:
+		return primitive.NewObjectID().Hex(), nil
+	}
+	return "", fmt.Errorf("%w: %q", ErrUnknownIDStrategy, s)
+}
+
+// ksuidEpoch KSUID 时间戳的起点，2014-05-13 16:53:20 UTC
+const ksuidEpoch = 1400000000
+
+// newKSUID 生成 KSUID：4 字节秒级时间戳和 16 字节随机数，按 base62 编码为定长 27 位字符串，字典序与时间序一致
+func newKSUID(now time.Time) (string, error) {
+	var buf [20]byte
+	binary.BigEndian.PutUint32(buf[:4], uint32(now.Unix()-ksuidEpoch))
+	if _, err := rand.Read(buf[4:]); err != nil {
+		return "", fmt.Errorf("failed to generate ksuid: %v", err)
+	}
+
+	const alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	out := make([]byte, 27)
+	n := new(big.Int).SetBytes(buf[:])
+	base, mod := big.NewInt(62), new(big.Int)
+	for i := len(out) - 1; i >= 0; i-- {
+		n.DivMod(n, base, mod)
+		out[i] = alphabet[mod.Int64()]
+	}
+	return string(out), nil
+}
+
+// FillID 表模型配置了 IDStrategy 且 fields 中主键为空时生成主键写入 fields，返回生成的主键，未生成时返回空字符串
+func (m *TableModel) FillID(fields map[string]any) (string, error) {
+	if m.IDStrategy == "" {
+		return "", nil
+	}
+	if len(m.PrimaryKey) != 1 {
+		return "", fmt.Errorf("%w: table %s must have exactly one primary key to generate id, got %v", ErrInvalidPrimaryKey, m.Table, m.PrimaryKey)
+	}
+
+	column := m.PrimaryKey[0]
+	if v, ok := fields[column]; ok && v != nil && !reflect.ValueOf(v).IsZero() {
+		return "", nil
+	}
+
+	id, err := m.IDStrategy.Generate()
+	if err != nil {
+		return "", err
+	}
+	fields[column] = id
+	return id, nil
+}
+
+// CreateWithID 按表模型的 IDStrategy 补全主键后创建记录，返回记录的主键，主键已存在时原样返回
+func CreateWithID(ctx context.Context, db Database, model *TableModel, fields map[string]any, opts ...CreateOption) (any, error) {
+	if _, err := model.FillID(fields); err != nil {
+		return nil, err
+	}
+	if err := db.Create(ctx, model.Table, db.GetBuilder().FromMap(fields, model.Table), opts...); err != nil {
+		return nil, err
+	}
+	if len(model.PrimaryKey) != 1 {
+		return nil, nil
+	}
+	return fields[model.PrimaryKey[0]], nil
+}
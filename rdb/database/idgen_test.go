@@ -0,0 +1,120 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type testIDGenEvent struct {
+	ID   string `rdb:"id,primary,idgen=ksuid"`
+	Name string `rdb:"name"`
+}
+
+func TestIDStrategy(t *testing.T) {
+	Convey("测试主键生成策略", t, func() {
+		Convey("生成各策略的主键", func() {
+			id, err := IDStrategyUUIDv4.Generate()
+			So(err, ShouldBeNil)
+			So(uuid.MustParse(id).Version(), ShouldEqual, uuid.Version(4))
+
+			id, err = IDStrategyUUIDv7.Generate()
+			So(err, ShouldBeNil)
+			So(uuid.MustParse(id).Version(), ShouldEqual, uuid.Version(7))
+
+			id, err = IDStrategyKSUID.Generate()
+			So(err, ShouldBeNil)
+			So(id, ShouldHaveLength, 27)
+			So(regexp.MustCompile(`^[0-9A-Za-z]{27}$`).MatchString(id), ShouldBeTrue)
+
+			id, err = IDStrategyObjectID.Generate()
+			So(err, ShouldBeNil)
+			So(regexp.MustCompile(`^[0-9a-f]{24}$`).MatchString(id), ShouldBeTrue)
+
+			_, err = IDStrategy("snowflake").Generate()
+			So(errors.Is(err, ErrUnknownIDStrategy), ShouldBeTrue)
+		})
+
+		Convey("KSUID 按时间有序", func() {
+			now := time.Now()
+			earlier, err := newKSUID(now)
+			So(err, ShouldBeNil)
+			later, err := newKSUID(now.Add(time.Second))
+			So(err, ShouldBeNil)
+			So(later, ShouldBeGreaterThan, earlier)
+		})
+
+		Convey("从结构体解析生成策略", func() {
+			model, err := NewTableModelBuilder().FromStruct(testIDGenEvent{})
+			So(err, ShouldBeNil)
+			So(model.IDStrategy, ShouldEqual, IDStrategyKSUID)
+
+			_, err = NewTableModelBuilder().FromStruct(struct {
+				ID int `rdb:"id,primary,idgen=uuidv7"`
+			}{})
+			So(err, ShouldNotBeNil)
+
+			_, err = NewTableModelBuilder().FromStruct(struct {
+				ID string `rdb:"id,primary,idgen=snowflake"`
+			}{})
+			So(errors.Is(err, ErrUnknownIDStrategy), ShouldBeTrue)
+
+			_, err = NewTableModelBuilder().FromStruct(struct {
+				ID   string `rdb:"id,primary"`
+				Code string `rdb:"code,idgen=uuidv4"`
+			}{})
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("主键为空时补全主键", func() {
+			model := &TableModel{Table: "events", PrimaryKey: []string{"id"}, IDStrategy: IDStrategyObjectID}
+
+			fields := map[string]any{"name": "a"}
+			id, err := model.FillID(fields)
+			So(err, ShouldBeNil)
+			So(id, ShouldHaveLength, 24)
+			So(fields["id"], ShouldEqual, id)
+
+			fields = map[string]any{"id": "", "name": "b"}
+			id, err = model.FillID(fields)
+			So(err, ShouldBeNil)
+			So(fields["id"], ShouldEqual, id)
+
+			fields = map[string]any{"id": "e1"}
+			id, err = model.FillID(fields)
+			So(err, ShouldBeNil)
+			So(id, ShouldBeEmpty)
+			So(fields["id"], ShouldEqual, "e1")
+
+			model.IDStrategy = ""
+			fields = map[string]any{"name": "c"}
+			_, err = model.FillID(fields)
+			So(err, ShouldBeNil)
+			So(fields, ShouldNotContainKey, "id")
+		})
+
+		Convey("创建时生成主键", func() {
+			db, err := NewSQLWithOptions(&SQLOptions{Driver: "sqlite3", Database: ":memory:", MaxConns: 1, MaxIdle: 1})
+			So(err, ShouldBeNil)
+			defer db.Close()
+
+			ctx := context.Background()
+			model, err := NewTableModelBuilder().FromStruct(testIDGenEvent{})
+			So(err, ShouldBeNil)
+			So(db.Migrate(ctx, model), ShouldBeNil)
+
+			id, err := CreateWithID(ctx, db, model, map[string]any{"name": "signup"})
+			So(err, ShouldBeNil)
+			So(id, ShouldHaveLength, 27)
+
+			record, err := GetByID(ctx, db, model, id.(string))
+			So(err, ShouldBeNil)
+			So(record.Fields()["name"], ShouldEqual, "signup")
+		})
+	})
+}
@@ -0,0 +1,127 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hatlonely/gox/rdb/query"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestIndexKinds(t *testing.T) {
+	active := &query.TermQuery{Field: "status", Value: "active"}
+
+	Convey("校验索引类型的参数", t, func() {
+		So(IndexDefinition{Name: "idx", Fields: []string{"a"}}.validate(), ShouldBeNil)
+		So(IndexDefinition{Name: "ttl", Fields: []string{"a"}, Kind: IndexKindTTL}.validate(), ShouldNotBeNil)
+		So(IndexDefinition{Name: "ttl", Fields: []string{"a", "b"}, Kind: IndexKindTTL, ExpireAfter: time.Hour}.validate(), ShouldNotBeNil)
+		So(IndexDefinition{Name: "partial", Fields: []string{"a"}, Kind: IndexKindPartial}.validate(), ShouldNotBeNil)
+		So(IndexDefinition{Name: "ft", Fields: []string{"a"}, FullText: true, Kind: IndexKindGeo}.validate(), ShouldNotBeNil)
+		So(IndexDefinition{Name: "x", Fields: []string{"a"}, Kind: "hash"}.validate(), ShouldNotBeNil)
+	})
+
+	Convey("SQL 索引语句", t, func() {
+		partial := IndexDefinition{Name: "uk_email", Fields: []string{"email"}, Unique: true, Kind: IndexKindPartial, Expression: active}
+
+		indexSQL, err := sqlDialect("sqlite3").buildCreateIndexSQL("users", partial)
+		So(err, ShouldBeNil)
+		So(indexSQL, ShouldEqual, "CREATE UNIQUE INDEX IF NOT EXISTS `uk_email` ON `users` (`email`) WHERE status = 'active'")
+
+		indexSQL, err = sqlDialect("postgres").buildCreateIndexSQL("users", IndexDefinition{
+			Name: "idx_deleted", Fields: []string{"email"}, Kind: IndexKindPartial,
+			Expression: &query.TermQuery{Field: "deleted", Value: false},
+		})
+		So(err, ShouldBeNil)
+		So(indexSQL, ShouldEqual, `CREATE INDEX IF NOT EXISTS "idx_deleted" ON "users" ("email") WHERE deleted = false`)
+
+		// MySQL 不支持部分索引，非唯一索引退化为普通索引，唯一索引返回错误
+		_, err = sqlDialect("mysql").buildCreateIndexSQL("users", partial)
+		So(err, ShouldNotBeNil)
+		partial.Unique = false
+		indexSQL, err = sqlDialect("mysql").buildCreateIndexSQL("users", partial)
+		So(err, ShouldBeNil)
+		So(indexSQL, ShouldEqual, "CREATE INDEX `uk_email` ON `users` (`email`)")
+
+		// ttl 索引创建为普通索引，geo 索引只在 postgres 上创建
+		indexSQL, err = sqlDialect("sqlite3").buildCreateIndexSQL("events", IndexDefinition{Name: "ttl_created", Fields: []string{"created_at"}, Kind: IndexKindTTL, ExpireAfter: time.Hour})
+		So(err, ShouldBeNil)
+		So(indexSQL, ShouldEqual, "CREATE INDEX IF NOT EXISTS `ttl_created` ON `events` (`created_at`)")
+
+		geo := IndexDefinition{Name: "geo_location", Fields: []string{"location"}, Kind: IndexKindGeo}
+		indexSQL, err = sqlDialect("postgres").buildCreateIndexSQL("shops", geo)
+		So(err, ShouldBeNil)
+		So(indexSQL, ShouldEqual, `CREATE INDEX IF NOT EXISTS "geo_location" ON "shops" USING GIST ("location")`)
+		indexSQL, err = sqlDialect("mysql").buildCreateIndexSQL("shops", geo)
+		So(err, ShouldBeNil)
+		So(indexSQL, ShouldBeEmpty)
+
+		// 表达式中的字段同样需要校验
+		_, err = sqlDialect("sqlite3").buildCreateIndexSQL("users", IndexDefinition{
+			Name: "idx_bad", Fields: []string{"email"}, Kind: IndexKindPartial,
+			Expression: &query.TermQuery{Field: "status = 'a' OR 1", Value: 1},
+		})
+		So(err, ShouldWrap, ErrInvalidIdentifier)
+	})
+
+	Convey("SQLite 部分唯一索引只约束满足条件的记录", t, func() {
+		ctx := context.Background()
+		db, err := NewSQLWithOptions(&SQLOptions{Driver: "sqlite3", Database: ":memory:", MaxConns: 1, MaxIdle: 1})
+		So(err, ShouldBeNil)
+		defer db.Close()
+
+		table := "test_partial_index"
+		model := &TableModel{
+			Table: table,
+			Fields: []FieldDefinition{
+				{Name: "id", Type: FieldTypeInt, Required: true},
+				{Name: "email", Type: FieldTypeString, Size: 100},
+				{Name: "status", Type: FieldTypeString, Size: 20},
+			},
+			PrimaryKey: []string{"id"},
+			Indexes: []IndexDefinition{
+				{Name: "uk_active_email", Fields: []string{"email"}, Unique: true, Kind: IndexKindPartial, Expression: active},
+				{Name: "geo_ignored", Fields: []string{"email"}, Kind: IndexKindGeo},
+			},
+		}
+		So(db.Migrate(ctx, model), ShouldBeNil)
+
+		statements, err := db.MigrateDiff(ctx, model, WithDryRun())
+		So(err, ShouldBeNil)
+		So(statements, ShouldBeEmpty)
+
+		builder := db.GetBuilder()
+		So(db.Create(ctx, table, builder.FromMap(map[string]any{"id": 1, "email": "a@x.com", "status": "deleted"}, table)), ShouldBeNil)
+		So(db.Create(ctx, table, builder.FromMap(map[string]any{"id": 2, "email": "a@x.com", "status": "active"}, table)), ShouldBeNil)
+		So(db.Create(ctx, table, builder.FromMap(map[string]any{"id": 3, "email": "a@x.com", "status": "active"}, table)), ShouldNotBeNil)
+	})
+
+	Convey("Mongo 索引模型和 shell 命令", t, func() {
+		ttl := IndexDefinition{Name: "ttl_created", Fields: []string{"createdAt"}, Kind: IndexKindTTL, ExpireAfter: 24 * time.Hour}
+		indexModel, err := buildMongoIndexModel(ttl)
+		So(err, ShouldBeNil)
+		So(*indexModel.Options.ExpireAfterSeconds, ShouldEqual, 86400)
+		statement, err := mongoIndexStatement("events", ttl)
+		So(err, ShouldBeNil)
+		So(statement, ShouldEqual, `db.events.createIndex({"createdAt": 1}, {"name": "ttl_created", "unique": false, "expireAfterSeconds": 86400})`)
+
+		partial := IndexDefinition{Name: "uk_email", Fields: []string{"email"}, Unique: true, Sparse: true, Kind: IndexKindPartial, Expression: active}
+		indexModel, err = buildMongoIndexModel(partial)
+		So(err, ShouldBeNil)
+		So(*indexModel.Options.Sparse, ShouldBeTrue)
+		So(indexModel.Options.PartialFilterExpression, ShouldResemble, map[string]any{"status": "active"})
+		statement, err = mongoIndexStatement("users", partial)
+		So(err, ShouldBeNil)
+		So(statement, ShouldEqual, `db.users.createIndex({"email": 1}, {"name": "uk_email", "unique": true, "sparse": true, "partialFilterExpression": {"status":"active"}})`)
+
+		statement, err = mongoIndexStatement("articles", IndexDefinition{Name: "ft_articles", Fields: []string{"title", "body"}, Kind: IndexKindText})
+		So(err, ShouldBeNil)
+		So(statement, ShouldEqual, `db.articles.createIndex({"title": "text", "body": "text"}, {"name": "ft_articles", "unique": false})`)
+		statement, err = mongoIndexStatement("shops", IndexDefinition{Name: "geo_location", Fields: []string{"location"}, Kind: IndexKindGeo})
+		So(err, ShouldBeNil)
+		So(statement, ShouldEqual, `db.shops.createIndex({"location": "2dsphere"}, {"name": "geo_location", "unique": false})`)
+
+		_, err = buildMongoIndexModel(IndexDefinition{Name: "ttl", Fields: []string{"a"}, Kind: IndexKindTTL})
+		So(err, ShouldNotBeNil)
+	})
+}
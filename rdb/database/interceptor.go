@@ -0,0 +1,384 @@
+package database
+
+import (
+	"context"
+
+	"github.com/hatlonely/gox/rdb/aggregation"
+	"github.com/hatlonely/gox/rdb/query"
+)
+
+// Operation 数据库操作名
+type Operation string
+
+const (
+	OpMigrate       Operation = "Migrate"
+	OpDropTable     Operation = "DropTable"
+	OpCreate        Operation = "Create"
+	OpGet           Operation = "Get"
+	OpUpdate        Operation = "Update"
+	OpUpdatePartial Operation = "UpdatePartial"
+	OpUpsert        Operation = "Upsert"
+	OpIncrement     Operation = "Increment"
+	OpDelete        Operation = "Delete"
+	OpFind          Operation = "Find"
+	OpFindStream    Operation = "FindStream"
+	OpCount         Operation = "Count"
+	OpExists        Operation = "Exists"
+	OpAggregate     Operation = "Aggregate"
+	OpBatchCreate   Operation = "BatchCreate"
+	OpBatchUpdate   Operation = "BatchUpdate"
+	OpBatchDelete   Operation = "BatchDelete"
+	OpBeginTx       Operation = "BeginTx"
+	OpWithTx        Operation = "WithTx"
+	OpCommit        Operation = "Commit"
+	OpRollback      Operation = "Rollback"
+	OpHealth        Operation = "Health"
+)
+
+// OperationInfo 一次数据库操作的描述，只填充与操作相关的字段
+//
+// 拦截器可以修改字段后传给 next，如为表名加租户前缀、为查询追加过滤条件，最终按修改后的字段执行操作
+type OperationInfo struct {
+	Operation Operation
+	Table     string
+	InTx      bool // 是否在事务中执行
+
+	Model   *TableModel      // Migrate
+	PK      map[string]any   // Get、Update、UpdatePartial、Upsert、Increment、Delete、Exists
+	PKs     []map[string]any // BatchUpdate、BatchDelete
+	Record  Record           // Create、Update、Upsert
+	Records []Record         // BatchCreate、BatchUpdate
+	Fields  map[string]any   // UpdatePartial
+	Field   string           // Increment
+	Delta   any              // Increment
+	Query   query.Query      // Find、FindStream、Count、Aggregate
+	Aggs    []aggregation.Aggregation
+
+	CreateOpts []CreateOption
+	UpdateOpts []UpdateOption
+	QueryOpts  []QueryOption
+
+	result *any
+	db     Database
+}
+
+// Result 获取操作的返回值，如 Get 的 Record、Find 的 []Record、Count 的 int64，在 next 返回后可用
+func (op OperationInfo) Result() any {
+	return *op.result
+}
+
+// SetResult 设置操作的返回值，拦截器不调用 next 直接返回时（如命中缓存）通过它提供结果
+// 返回值类型必须与操作一致，否则调用方拿到零值
+func (op OperationInfo) SetResult(v any) {
+	*op.result = v
+}
+
+// Database 获取执行操作的数据库，事务内为事务本身
+// 拦截器需要读写其他表时使用，如审计日志在同一事务内写入；通过它执行的操作不再经过拦截器
+func (op OperationInfo) Database() Database {
+	return op.db
+}
+
+func newOperationInfo(operation Operation, table string, inTx bool) OperationInfo {
+	return OperationInfo{Operation: operation, Table: table, InTx: inTx, result: new(any)}
+}
+
+// Handler 执行数据库操作
+type Handler func(ctx context.Context, op OperationInfo) error
+
+// Interceptor 数据库操作拦截器，调用 next 继续执行后续拦截器和实际操作，不调用则中断操作
+type Interceptor func(ctx context.Context, op OperationInfo, next Handler) error
+
+// InterceptorDatabase 在数据库操作外包裹拦截器链，用于统一接入日志、监控、链路追踪、租户隔离、缓存等逻辑
+//
+// 拦截器按注册顺序执行，第一个注册的在最外层。BeginTx 和 WithTx 返回的事务同样经过拦截器，
+// 事务内的操作 OperationInfo.InTx 为 true
+type InterceptorDatabase struct {
+	Database
+
+	interceptors []Interceptor
+	inTx         bool
+}
+
+// NewInterceptorDatabase 为数据库添加拦截器
+func NewInterceptorDatabase(db Database, interceptors ...Interceptor) *InterceptorDatabase {
+	return &InterceptorDatabase{Database: db, interceptors: interceptors}
+}
+
+// Unwrap 返回被包装的数据库
+func (d *InterceptorDatabase) Unwrap() Database {
+	return d.Database
+}
+
+// Use 追加拦截器，需要在使用数据库前调用，非并发安全
+func (d *InterceptorDatabase) Use(interceptors ...Interceptor) {
+	d.interceptors = append(d.interceptors, interceptors...)
+}
+
+// invoke 依次经过拦截器后执行 handler
+func (d *InterceptorDatabase) invoke(ctx context.Context, op OperationInfo, handler Handler) error {
+	op.db = d.Database
+	for i := len(d.interceptors) - 1; i >= 0; i-- {
+		interceptor, next := d.interceptors[i], handler
+		handler = func(ctx context.Context, op OperationInfo) error {
+			return interceptor(ctx, op, next)
+		}
+	}
+	return handler(ctx, op)
+}
+
+// resultAs 取出指定类型的返回值，类型不符时返回零值
+func resultAs[T any](op OperationInfo) T {
+	v, _ := op.Result().(T)
+	return v
+}
+
+func (d *InterceptorDatabase) Migrate(ctx context.Context, model *TableModel) error {
+	op := newOperationInfo(OpMigrate, model.Table, d.inTx)
+	op.Model = model
+	return d.invoke(ctx, op, func(ctx context.Context, op OperationInfo) error {
+		return d.Database.Migrate(ctx, op.Model)
+	})
+}
+
+func (d *InterceptorDatabase) DropTable(ctx context.Context, table string) error {
+	op := newOperationInfo(OpDropTable, table, d.inTx)
+	return d.invoke(ctx, op, func(ctx context.Context, op OperationInfo) error {
+		return d.Database.DropTable(ctx, op.Table)
+	})
+}
+
+func (d *InterceptorDatabase) Create(ctx context.Context, table string, record Record, opts ...CreateOption) error {
+	op := newOperationInfo(OpCreate, table, d.inTx)
+	op.Record = record
+	op.CreateOpts = opts
+	return d.invoke(ctx, op, func(ctx context.Context, op OperationInfo) error {
+		return d.Database.Create(ctx, op.Table, op.Record, op.CreateOpts...)
+	})
+}
+
+func (d *InterceptorDatabase) Get(ctx context.Context, table string, pk map[string]any, opts ...QueryOption) (Record, error) {
+	op := newOperationInfo(OpGet, table, d.inTx)
+	op.PK = pk
+	op.QueryOpts = opts
+	err := d.invoke(ctx, op, func(ctx context.Context, op OperationInfo) error {
+		record, err := d.Database.Get(ctx, op.Table, op.PK, op.QueryOpts...)
+		op.SetResult(record)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resultAs[Record](op), nil
+}
+
+func (d *InterceptorDatabase) Update(ctx context.Context, table string, pk map[string]any, record Record, opts ...UpdateOption) error {
+	op := newOperationInfo(OpUpdate, table, d.inTx)
+	op.PK = pk
+	op.Record = record
+	op.UpdateOpts = opts
+	return d.invoke(ctx, op, func(ctx context.Context, op OperationInfo) error {
+		return d.Database.Update(ctx, op.Table, op.PK, op.Record, op.UpdateOpts...)
+	})
+}
+
+func (d *InterceptorDatabase) UpdatePartial(ctx context.Context, table string, pk map[string]any, fields map[string]any, opts ...UpdateOption) error {
+	op := newOperationInfo(OpUpdatePartial, table, d.inTx)
+	op.PK = pk
+	op.Fields = fields
+	op.UpdateOpts = opts
+	return d.invoke(ctx, op, func(ctx context.Context, op OperationInfo) error {
+		return d.Database.UpdatePartial(ctx, op.Table, op.PK, op.Fields, op.UpdateOpts...)
+	})
+}
+
+func (d *InterceptorDatabase) Upsert(ctx context.Context, table string, pk map[string]any, record Record) error {
+	op := newOperationInfo(OpUpsert, table, d.inTx)
+	op.PK = pk
+	op.Record = record
+	return d.invoke(ctx, op, func(ctx context.Context, op OperationInfo) error {
+		return d.Database.Upsert(ctx, op.Table, op.PK, op.Record)
+	})
+}
+
+func (d *InterceptorDatabase) Increment(ctx context.Context, table string, pk map[string]any, field string, delta any) error {
+	op := newOperationInfo(OpIncrement, table, d.inTx)
+	op.PK = pk
+	op.Field = field
+	op.Delta = delta
+	return d.invoke(ctx, op, func(ctx context.Context, op OperationInfo) error {
+		return d.Database.Increment(ctx, op.Table, op.PK, op.Field, op.Delta)
+	})
+}
+
+func (d *InterceptorDatabase) Delete(ctx context.Context, table string, pk map[string]any) error {
+	op := newOperationInfo(OpDelete, table, d.inTx)
+	op.PK = pk
+	return d.invoke(ctx, op, func(ctx context.Context, op OperationInfo) error {
+		return d.Database.Delete(ctx, op.Table, op.PK)
+	})
+}
+
+func (d *InterceptorDatabase) Find(ctx context.Context, table string, query query.Query, opts ...QueryOption) ([]Record, error) {
+	op := newOperationInfo(OpFind, table, d.inTx)
+	op.Query = query
+	op.QueryOpts = opts
+	err := d.invoke(ctx, op, func(ctx context.Context, op OperationInfo) error {
+		records, err := d.Database.Find(ctx, op.Table, op.Query, op.QueryOpts...)
+		op.SetResult(records)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resultAs[[]Record](op), nil
+}
+
+func (d *InterceptorDatabase) FindStream(ctx context.Context, table string, query query.Query, opts ...QueryOption) (RecordCursor, error) {
+	op := newOperationInfo(OpFindStream, table, d.inTx)
+	op.Query = query
+	op.QueryOpts = opts
+	err := d.invoke(ctx, op, func(ctx context.Context, op OperationInfo) error {
+		cursor, err := d.Database.FindStream(ctx, op.Table, op.Query, op.QueryOpts...)
+		op.SetResult(cursor)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resultAs[RecordCursor](op), nil
+}
+
+func (d *InterceptorDatabase) Count(ctx context.Context, table string, query query.Query) (int64, error) {
+	op := newOperationInfo(OpCount, table, d.inTx)
+	op.Query = query
+	err := d.invoke(ctx, op, func(ctx context.Context, op OperationInfo) error {
+		count, err := d.Database.Count(ctx, op.Table, op.Query)
+		op.SetResult(count)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return resultAs[int64](op), nil
+}
+
+func (d *InterceptorDatabase) Exists(ctx context.Context, table string, pk map[string]any) (bool, error) {
+	op := newOperationInfo(OpExists, table, d.inTx)
+	op.PK = pk
+	err := d.invoke(ctx, op, func(ctx context.Context, op OperationInfo) error {
+		exists, err := d.Database.Exists(ctx, op.Table, op.PK)
+		op.SetResult(exists)
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+	return resultAs[bool](op), nil
+}
+
+func (d *InterceptorDatabase) Aggregate(ctx context.Context, table string, query query.Query, aggs []aggregation.Aggregation, opts ...QueryOption) (aggregation.AggregationResult, error) {
+	op := newOperationInfo(OpAggregate, table, d.inTx)
+	op.Query = query
+	op.Aggs = aggs
+	op.QueryOpts = opts
+	err := d.invoke(ctx, op, func(ctx context.Context, op OperationInfo) error {
+		result, err := d.Database.Aggregate(ctx, op.Table, op.Query, op.Aggs, op.QueryOpts...)
+		op.SetResult(result)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resultAs[aggregation.AggregationResult](op), nil
+}
+
+func (d *InterceptorDatabase) BatchCreate(ctx context.Context, table string, records []Record, opts ...CreateOption) error {
+	op := newOperationInfo(OpBatchCreate, table, d.inTx)
+	op.Records = records
+	op.CreateOpts = opts
+	return d.invoke(ctx, op, func(ctx context.Context, op OperationInfo) error {
+		return d.Database.BatchCreate(ctx, op.Table, op.Records, op.CreateOpts...)
+	})
+}
+
+func (d *InterceptorDatabase) BatchUpdate(ctx context.Context, table string, pks []map[string]any, records []Record) error {
+	op := newOperationInfo(OpBatchUpdate, table, d.inTx)
+	op.PKs = pks
+	op.Records = records
+	return d.invoke(ctx, op, func(ctx context.Context, op OperationInfo) error {
+		return d.Database.BatchUpdate(ctx, op.Table, op.PKs, op.Records)
+	})
+}
+
+func (d *InterceptorDatabase) BatchDelete(ctx context.Context, table string, pks []map[string]any) error {
+	op := newOperationInfo(OpBatchDelete, table, d.inTx)
+	op.PKs = pks
+	return d.invoke(ctx, op, func(ctx context.Context, op OperationInfo) error {
+		return d.Database.BatchDelete(ctx, op.Table, op.PKs)
+	})
+}
+
+func (d *InterceptorDatabase) BeginTx(ctx context.Context) (Transaction, error) {
+	op := newOperationInfo(OpBeginTx, "", d.inTx)
+	err := d.invoke(ctx, op, func(ctx context.Context, op OperationInfo) error {
+		tx, err := d.Database.BeginTx(ctx)
+		if err != nil {
+			return err
+		}
+		op.SetResult(d.wrapTx(ctx, tx))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resultAs[Transaction](op), nil
+}
+
+// WithTx 整个事务作为一次 OpWithTx 操作，fn 中的事务操作也会经过拦截器
+func (d *InterceptorDatabase) WithTx(ctx context.Context, fn func(tx Transaction) error) error {
+	op := newOperationInfo(OpWithTx, "", d.inTx)
+	return d.invoke(ctx, op, func(ctx context.Context, op OperationInfo) error {
+		return d.Database.WithTx(ctx, func(tx Transaction) error {
+			return fn(d.wrapTx(ctx, tx))
+		})
+	})
+}
+
+func (d *InterceptorDatabase) Health(ctx context.Context) error {
+	op := newOperationInfo(OpHealth, "", d.inTx)
+	return d.invoke(ctx, op, func(ctx context.Context, op OperationInfo) error {
+		return d.Database.Health(ctx)
+	})
+}
+
+func (d *InterceptorDatabase) wrapTx(ctx context.Context, tx Transaction) *interceptorTransaction {
+	return &interceptorTransaction{
+		InterceptorDatabase: &InterceptorDatabase{Database: tx, interceptors: d.interceptors, inTx: true},
+		ctx:                 ctx,
+		tx:                  tx,
+	}
+}
+
+// interceptorTransaction 事务内的操作、提交和回滚都经过拦截器
+// Commit 和 Rollback 没有 context 参数，拦截器收到的是开始事务时的 context
+type interceptorTransaction struct {
+	*InterceptorDatabase
+
+	ctx context.Context
+	tx  Transaction
+}
+
+func (tx *interceptorTransaction) Commit() error {
+	op := newOperationInfo(OpCommit, "", true)
+	return tx.invoke(tx.ctx, op, func(ctx context.Context, op OperationInfo) error {
+		return tx.tx.Commit()
+	})
+}
+
+func (tx *interceptorTransaction) Rollback() error {
+	op := newOperationInfo(OpRollback, "", true)
+	return tx.invoke(tx.ctx, op, func(ctx context.Context, op OperationInfo) error {
+		return tx.tx.Rollback()
+	})
+}
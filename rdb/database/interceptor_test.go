@@ -0,0 +1,128 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/hatlonely/gox/rdb/query"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestInterceptorDatabase(t *testing.T) {
+	Convey("测试 InterceptorDatabase 拦截器链", t, func() {
+		sql, err := NewSQLWithOptions(&SQLOptions{Driver: "sqlite3", Database: ":memory:", MaxConns: 1, MaxIdle: 1})
+		So(err, ShouldBeNil)
+		defer sql.Close()
+
+		ctx := context.Background()
+		model := &TableModel{
+			Table: "test_interceptor_users",
+			Fields: []FieldDefinition{
+				{Name: "id", Type: FieldTypeInt, Required: true},
+				{Name: "name", Type: FieldTypeString, Size: 100},
+			},
+			PrimaryKey: []string{"id"},
+		}
+		So(sql.Migrate(ctx, model), ShouldBeNil)
+
+		var calls []string
+		record := func(name string) Interceptor {
+			return func(ctx context.Context, op OperationInfo, next Handler) error {
+				calls = append(calls, name+":"+string(op.Operation))
+				return next(ctx, op)
+			}
+		}
+		db := NewInterceptorDatabase(sql, record("outer"), record("inner"))
+		pk := map[string]any{"id": 1}
+
+		Convey("按注册顺序执行并返回结果", func() {
+			So(db.Create(ctx, "test_interceptor_users", db.GetBuilder().FromMap(map[string]any{"id": 1, "name": "alice"}, "test_interceptor_users")), ShouldBeNil)
+			got, err := db.Get(ctx, "test_interceptor_users", pk)
+			So(err, ShouldBeNil)
+			So(got.Fields()["name"], ShouldEqual, "alice")
+
+			count, err := db.Count(ctx, "test_interceptor_users", &query.TermQuery{Field: "name", Value: "alice"})
+			So(err, ShouldBeNil)
+			So(count, ShouldEqual, 1)
+
+			So(calls, ShouldResemble, []string{
+				"outer:Create", "inner:Create",
+				"outer:Get", "inner:Get",
+				"outer:Count", "inner:Count",
+			})
+		})
+
+		Convey("拦截器直接返回结果", func() {
+			cached := &SQLRecord{data: map[string]any{"id": 1, "name": "cached"}}
+			db.Use(func(ctx context.Context, op OperationInfo, next Handler) error {
+				if op.Operation == OpGet {
+					op.SetResult(cached)
+					return nil
+				}
+				return next(ctx, op)
+			})
+
+			got, err := db.Get(ctx, "test_interceptor_users", pk)
+			So(err, ShouldBeNil)
+			So(got, ShouldEqual, cached)
+
+			exists, err := db.Exists(ctx, "test_interceptor_users", pk)
+			So(err, ShouldBeNil)
+			So(exists, ShouldBeFalse)
+		})
+
+		Convey("拦截器修改操作参数", func() {
+			So(sql.Create(ctx, "test_interceptor_users", sql.GetBuilder().FromMap(map[string]any{"id": 2, "name": "bob"}, "test_interceptor_users")), ShouldBeNil)
+			db := NewInterceptorDatabase(sql, func(ctx context.Context, op OperationInfo, next Handler) error {
+				op.Table = "test_interceptor_" + op.Table
+				return next(ctx, op)
+			})
+
+			got, err := db.Get(ctx, "users", map[string]any{"id": 2})
+			So(err, ShouldBeNil)
+			So(got.Fields()["name"], ShouldEqual, "bob")
+		})
+
+		Convey("拦截器中断操作", func() {
+			denied := errors.New("denied")
+			db.Use(func(ctx context.Context, op OperationInfo, next Handler) error {
+				if op.Operation == OpDelete {
+					return denied
+				}
+				return next(ctx, op)
+			})
+
+			So(db.Delete(ctx, "test_interceptor_users", pk), ShouldEqual, denied)
+			_, err := db.Find(ctx, "test_interceptor_users", &query.TermQuery{Field: "id", Value: 1})
+			So(err, ShouldBeNil)
+		})
+
+		Convey("事务中的操作同样经过拦截器", func() {
+			var inTx []bool
+			db.Use(func(ctx context.Context, op OperationInfo, next Handler) error {
+				inTx = append(inTx, op.InTx)
+				return next(ctx, op)
+			})
+
+			So(db.WithTx(ctx, func(tx Transaction) error {
+				return tx.Create(ctx, "test_interceptor_users", tx.GetBuilder().FromMap(map[string]any{"id": 3, "name": "carol"}, "test_interceptor_users"))
+			}), ShouldBeNil)
+
+			tx, err := db.BeginTx(ctx)
+			So(err, ShouldBeNil)
+			So(tx.Delete(ctx, "test_interceptor_users", map[string]any{"id": 3}), ShouldBeNil)
+			So(tx.Rollback(), ShouldBeNil)
+
+			So(calls, ShouldResemble, []string{
+				"outer:WithTx", "inner:WithTx", "outer:Create", "inner:Create",
+				"outer:BeginTx", "inner:BeginTx", "outer:Delete", "inner:Delete", "outer:Rollback", "inner:Rollback",
+			})
+			So(inTx, ShouldResemble, []bool{false, true, false, true, true})
+
+			exists, err := sql.Exists(ctx, "test_interceptor_users", map[string]any{"id": 3})
+			So(err, ShouldBeNil)
+			So(exists, ShouldBeTrue)
+		})
+	})
+}
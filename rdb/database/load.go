@@ -0,0 +1,347 @@
+package database
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// LoadFormat 导入数据的格式
+type LoadFormat string
+
+const (
+	// LoadFormatCSV 第一行为列名，值均为字符串，由数据库按列类型转换
+	LoadFormatCSV LoadFormat = "csv"
+	// LoadFormatJSONL 每行一个 JSON 对象，空行忽略，整数转换为 int64，小数转换为 float64
+	LoadFormatJSONL LoadFormat = "jsonl"
+)
+
+// LoadErrorMode 导入时遇到错误记录的处理方式
+type LoadErrorMode string
+
+const (
+	// LoadErrorAbort 遇到错误立即返回，已写入的记录不回滚
+	LoadErrorAbort LoadErrorMode = "abort"
+	// LoadErrorSkip 跳过错误的记录，只统计跳过的条数
+	LoadErrorSkip LoadErrorMode = "skip"
+	// LoadErrorCollect 跳过错误的记录，并在结果中返回每条记录的错误
+	LoadErrorCollect LoadErrorMode = "collect"
+)
+
+// defaultLoadChunkSize 未指定每批写入的记录数时的默认值
+const defaultLoadChunkSize = 1000
+
+// LoadOptions 导入选项
+type LoadOptions struct {
+	ChunkSize  int               // 每次 BatchCreate 写入的记录数，默认 1000
+	Columns    map[string]string // 源列名到表字段名的映射，映射为空字符串时丢弃该列，未映射的列原样写入
+	OnError    LoadErrorMode     // 错误记录的处理方式，默认 LoadErrorAbort
+	CreateOpts []CreateOption    // 传给 BatchCreate 的选项，如 WithIgnoreConflict
+}
+
+type LoadOption func(*LoadOptions)
+
+// WithLoadChunkSize 设置每次 BatchCreate 写入的记录数
+func WithLoadChunkSize(size int) LoadOption {
+	return func(opts *LoadOptions) {
+		opts.ChunkSize = size
+	}
+}
+
+// WithLoadColumns 设置源列名到表字段名的映射
+func WithLoadColumns(columns map[string]string) LoadOption {
+	return func(opts *LoadOptions) {
+		opts.Columns = columns
+	}
+}
+
+// WithLoadOnError 设置错误记录的处理方式
+func WithLoadOnError(mode LoadErrorMode) LoadOption {
+	return func(opts *LoadOptions) {
+		opts.OnError = mode
+	}
+}
+
+// WithLoadCreateOptions 设置写入记录时使用的创建选项
+func WithLoadCreateOptions(opts ...CreateOption) LoadOption {
+	return func(o *LoadOptions) {
+		o.CreateOpts = opts
+	}
+}
+
+// LoadError 导入时一条记录的错误，Line 为记录在输入中的行号，从 1 开始
+type LoadError struct {
+	Line int
+	Err  error
+}
+
+func (e *LoadError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *LoadError) Unwrap() error {
+	return e.Err
+}
+
+// LoadResult 导入结果
+type LoadResult struct {
+	Loaded  int         // 写入的记录数
+	Skipped int         // 因错误跳过的记录数
+	Errors  []LoadError // LoadErrorCollect 模式下每条跳过的记录的错误
+}
+
+// Load 从 r 中流式读取 CSV 或 JSON Lines 数据，按 ChunkSize 分批通过 BatchCreate 写入 table
+//
+// 解析失败的记录按 OnError 处理；分批写入失败时，LoadErrorAbort 模式返回该批第一条记录的行号。
+// BatchCreate 失败时可能已经写入了部分记录，LoadErrorSkip、LoadErrorCollect 模式需要定位每条出错的记录，
+// 只有创建选项包含 WithIgnoreConflict 或 WithUpdateOnConflict 时分批写入，失败后逐条重试，否则逐条 Create 写入。
+// 返回错误时 LoadResult 中为已经写入的记录数
+func Load(ctx context.Context, db Database, table string, r io.Reader, format LoadFormat, opts ...LoadOption) (*LoadResult, error) {
+	options := &LoadOptions{ChunkSize: defaultLoadChunkSize, OnError: LoadErrorAbort}
+	for _, opt := range opts {
+		opt(options)
+	}
+	if options.ChunkSize <= 0 {
+		options.ChunkSize = defaultLoadChunkSize
+	}
+	switch options.OnError {
+	case LoadErrorAbort, LoadErrorSkip, LoadErrorCollect:
+	default:
+		return nil, errors.Errorf("unsupported load error mode %q", options.OnError)
+	}
+
+	var source loadSource
+	switch format {
+	case LoadFormatCSV:
+		source = newCSVLoadSource(r)
+	case LoadFormatJSONL:
+		source = newJSONLoadSource(r)
+	default:
+		return nil, errors.Errorf("unsupported load format %q", format)
+	}
+
+	l := &loader{db: db, table: table, options: options, result: &LoadResult{}}
+	for {
+		line, fields, err := source.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if errors.Is(err, errLoadSource) {
+				return l.result, err
+			}
+			if err := l.fail(line, err); err != nil {
+				return l.result, err
+			}
+			continue
+		}
+
+		l.add(line, l.mapColumns(fields))
+		if len(l.records) >= options.ChunkSize {
+			if err := l.flush(ctx); err != nil {
+				return l.result, err
+			}
+		}
+	}
+	if err := l.flush(ctx); err != nil {
+		return l.result, err
+	}
+	return l.result, nil
+}
+
+// errLoadSource 读取输入失败，无法继续读取后面的记录，不按 OnError 处理
+var errLoadSource = errors.New("failed to read load source")
+
+// loadSource 按行读取记录，返回记录的行号和字段
+type loadSource interface {
+	next() (int, map[string]any, error)
+}
+
+type csvLoadSource struct {
+	reader *csv.Reader
+	header []string
+}
+
+func newCSVLoadSource(r io.Reader) *csvLoadSource {
+	return &csvLoadSource{reader: csv.NewReader(r)}
+}
+
+func (s *csvLoadSource) next() (int, map[string]any, error) {
+	row, err := s.reader.Read()
+	if err == io.EOF {
+		return 0, nil, io.EOF
+	}
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		if s.header == nil {
+			return parseErr.StartLine, nil, fmt.Errorf("%w: %v", errLoadSource, err)
+		}
+		return parseErr.StartLine, nil, err
+	}
+	if err != nil {
+		return 0, nil, fmt.Errorf("%w: %v", errLoadSource, err)
+	}
+
+	if s.header == nil {
+		s.header = row
+		return s.next()
+	}
+	line, _ := s.reader.FieldPos(0)
+	fields := make(map[string]any, len(row))
+	for i, value := range row {
+		fields[s.header[i]] = value
+	}
+	return line, fields, nil
+}
+
+type jsonLoadSource struct {
+	reader *bufio.Reader
+	line   int
+}
+
+func newJSONLoadSource(r io.Reader) *jsonLoadSource {
+	return &jsonLoadSource{reader: bufio.NewReader(r)}
+}
+
+func (s *jsonLoadSource) next() (int, map[string]any, error) {
+	for {
+		data, err := s.reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return 0, nil, fmt.Errorf("%w: %v", errLoadSource, err)
+		}
+		if len(data) == 0 && err == io.EOF {
+			return 0, nil, io.EOF
+		}
+		s.line++
+		if data = bytes.TrimSpace(data); len(data) == 0 {
+			continue
+		}
+
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		var fields map[string]any
+		if err := decoder.Decode(&fields); err != nil {
+			return s.line, nil, errors.Wrap(err, "invalid json")
+		}
+		if fields == nil {
+			return s.line, nil, errors.New("invalid json: expected an object")
+		}
+		return s.line, loadJSONValue(fields).(map[string]any), nil
+	}
+}
+
+// loadJSONValue 将 json.Number 转换为 int64 或 float64
+func loadJSONValue(value any) any {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]any:
+		for key, item := range v {
+			v[key] = loadJSONValue(item)
+		}
+		return v
+	case []any:
+		for i, item := range v {
+			v[i] = loadJSONValue(item)
+		}
+		return v
+	default:
+		return value
+	}
+}
+
+// loader 缓存一批待写入的记录
+type loader struct {
+	db      Database
+	table   string
+	options *LoadOptions
+	result  *LoadResult
+
+	records []Record
+	lines   []int
+}
+
+func (l *loader) mapColumns(fields map[string]any) map[string]any {
+	if len(l.options.Columns) == 0 {
+		return fields
+	}
+	mapped := make(map[string]any, len(fields))
+	for key, value := range fields {
+		column, ok := l.options.Columns[key]
+		if !ok {
+			column = key
+		}
+		if column != "" {
+			mapped[column] = value
+		}
+	}
+	return mapped
+}
+
+func (l *loader) add(line int, fields map[string]any) {
+	l.records = append(l.records, l.db.GetBuilder().FromMap(fields, l.table))
+	l.lines = append(l.lines, line)
+}
+
+// flush 写入缓存的记录，写入失败时按 OnError 处理
+func (l *loader) flush(ctx context.Context) error {
+	if len(l.records) == 0 {
+		return nil
+	}
+	records, lines := l.records, l.lines
+	l.records, l.lines = l.records[:0], l.lines[:0]
+
+	if l.options.OnError == LoadErrorAbort || l.retryable() {
+		err := l.db.BatchCreate(ctx, l.table, records, l.options.CreateOpts...)
+		if err == nil {
+			l.result.Loaded += len(records)
+			return nil
+		}
+		if l.options.OnError == LoadErrorAbort || ctx.Err() != nil {
+			return &LoadError{Line: lines[0], Err: err}
+		}
+	}
+
+	for i, record := range records {
+		if err := l.db.Create(ctx, l.table, record, l.options.CreateOpts...); err != nil {
+			if ctx.Err() != nil {
+				return &LoadError{Line: lines[i], Err: err}
+			}
+			l.fail(lines[i], err)
+			continue
+		}
+		l.result.Loaded++
+	}
+	return nil
+}
+
+// retryable 冲突时忽略或更新的写入可以重复执行，分批写入失败后逐条重试不会把该批中已写入的记录误报为冲突
+func (l *loader) retryable() bool {
+	options := &CreateOptions{}
+	for _, opt := range l.options.CreateOpts {
+		opt(options)
+	}
+	return options.IgnoreConflict || options.UpdateOnConflict
+}
+
+// fail 记录一条错误的记录，LoadErrorAbort 模式返回错误
+func (l *loader) fail(line int, err error) error {
+	if l.options.OnError == LoadErrorAbort {
+		return &LoadError{Line: line, Err: err}
+	}
+	l.result.Skipped++
+	if l.options.OnError == LoadErrorCollect {
+		l.result.Errors = append(l.result.Errors, LoadError{Line: line, Err: err})
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/hatlonely/gox/rdb/query"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestLoad(t *testing.T) {
+	Convey("测试 CSV、JSON Lines 导入", t, func() {
+		ctx := context.Background()
+		db, err := NewSQLWithOptions(&SQLOptions{Driver: "sqlite3", Database: ":memory:", MaxConns: 1, MaxIdle: 1})
+		So(err, ShouldBeNil)
+		defer db.Close()
+
+		So(db.Migrate(ctx, &TableModel{
+			Table: "load_users",
+			Fields: []FieldDefinition{
+				{Name: "id", Type: FieldTypeInt, Required: true},
+				{Name: "name", Type: FieldTypeString, Size: 100, Required: true},
+				{Name: "age", Type: FieldTypeInt},
+			},
+			PrimaryKey: []string{"id"},
+		}), ShouldBeNil)
+
+		count := func() int64 {
+			n, err := db.Count(ctx, "load_users", &query.ExistsQuery{Field: "id"})
+			So(err, ShouldBeNil)
+			return n
+		}
+
+		Convey("CSV 分批写入并映射列名", func() {
+			input := "id,user_name,age,comment\n1,alice,20,x\n2,bob,30,y\n3,\"carol, jr\",40,z\n"
+			result, err := Load(ctx, db, "load_users", strings.NewReader(input), LoadFormatCSV,
+				WithLoadChunkSize(2), WithLoadColumns(map[string]string{"user_name": "name", "comment": ""}))
+			So(err, ShouldBeNil)
+			So(result.Loaded, ShouldEqual, 3)
+			So(count(), ShouldEqual, 3)
+
+			record, err := db.Get(ctx, "load_users", map[string]any{"id": 3})
+			So(err, ShouldBeNil)
+			So(record.Fields()["name"], ShouldEqual, "carol, jr")
+			So(record.Fields()["age"], ShouldEqual, 40)
+		})
+
+		Convey("JSON Lines 忽略空行，数字保留类型", func() {
+			input := "{\"id\": 1, \"name\": \"alice\", \"age\": 20}\n\n{\"id\": 2, \"name\": \"bob\"}"
+			result, err := Load(ctx, db, "load_users", strings.NewReader(input), LoadFormatJSONL)
+			So(err, ShouldBeNil)
+			So(result.Loaded, ShouldEqual, 2)
+
+			record, err := db.Get(ctx, "load_users", map[string]any{"id": 2})
+			So(err, ShouldBeNil)
+			So(record.Fields()["name"], ShouldEqual, "bob")
+		})
+
+		Convey("默认遇到错误立即返回", func() {
+			input := "{\"id\": 1, \"name\": \"alice\"}\n{\"id\": 2, \"name\": \n{\"id\": 3, \"name\": \"carol\"}\n"
+			result, err := Load(ctx, db, "load_users", strings.NewReader(input), LoadFormatJSONL, WithLoadChunkSize(1))
+			So(err, ShouldNotBeNil)
+			var loadErr *LoadError
+			So(errors.As(err, &loadErr), ShouldBeTrue)
+			So(loadErr.Line, ShouldEqual, 2)
+			So(result.Loaded, ShouldEqual, 1)
+			So(count(), ShouldEqual, 1)
+		})
+
+		Convey("跳过错误的记录", func() {
+			input := "id,name\n1,alice\n2\n1,duplicate\n3,carol\n"
+			result, err := Load(ctx, db, "load_users", strings.NewReader(input), LoadFormatCSV, WithLoadOnError(LoadErrorSkip))
+			So(err, ShouldBeNil)
+			So(result.Loaded, ShouldEqual, 2)
+			So(result.Skipped, ShouldEqual, 2)
+			So(result.Errors, ShouldBeEmpty)
+			So(count(), ShouldEqual, 2)
+		})
+
+		Convey("收集错误的记录", func() {
+			input := "{\"id\": 1, \"name\": \"alice\"}\n[1]\n{\"id\": 1, \"name\": \"duplicate\"}\n{\"id\": 2, \"name\": \"bob\"}\n"
+			result, err := Load(ctx, db, "load_users", strings.NewReader(input), LoadFormatJSONL, WithLoadOnError(LoadErrorCollect))
+			So(err, ShouldBeNil)
+			So(result.Loaded, ShouldEqual, 2)
+			So(result.Skipped, ShouldEqual, 2)
+			So(len(result.Errors), ShouldEqual, 2)
+			So(result.Errors[0].Line, ShouldEqual, 2)
+			So(result.Errors[1].Line, ShouldEqual, 3)
+		})
+
+		Convey("冲突时更新，可以重复导入", func() {
+			input := "id,name\n1,alice\n"
+			_, err := Load(ctx, db, "load_users", strings.NewReader(input), LoadFormatCSV)
+			So(err, ShouldBeNil)
+			result, err := Load(ctx, db, "load_users", strings.NewReader("id,name\n1,bob\n"), LoadFormatCSV,
+				WithLoadCreateOptions(WithUpdateOnConflict()))
+			So(err, ShouldBeNil)
+			So(result.Loaded, ShouldEqual, 1)
+
+			record, err := db.Get(ctx, "load_users", map[string]any{"id": 1})
+			So(err, ShouldBeNil)
+			So(record.Fields()["name"], ShouldEqual, "bob")
+		})
+
+		Convey("冲突时更新的写入分批失败后逐条重试", func() {
+			input := "{\"id\": 1, \"name\": \"alice\"}\n{\"id\": 2}\n{\"id\": 3, \"name\": \"carol\"}\n"
+			result, err := Load(ctx, db, "load_users", strings.NewReader(input), LoadFormatJSONL,
+				WithLoadOnError(LoadErrorCollect), WithLoadCreateOptions(WithUpdateOnConflict()))
+			So(err, ShouldBeNil)
+			So(result.Loaded, ShouldEqual, 2)
+			So(len(result.Errors), ShouldEqual, 1)
+			So(result.Errors[0].Line, ShouldEqual, 2)
+			So(count(), ShouldEqual, 2)
+		})
+
+		Convey("参数错误", func() {
+			_, err := Load(ctx, db, "load_users", strings.NewReader(""), LoadFormat("xml"))
+			So(err, ShouldNotBeNil)
+			_, err = Load(ctx, db, "load_users", strings.NewReader(""), LoadFormatCSV, WithLoadOnError("ignore"))
+			So(err, ShouldNotBeNil)
+
+			// 表头无法解析时不按 OnError 处理
+			_, err = Load(ctx, db, "load_users", strings.NewReader("id,\"name\n"), LoadFormatCSV, WithLoadOnError(LoadErrorSkip))
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,311 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+// MetricsOptions Prometheus 指标配置，指标注册到 prometheus.DefaultRegisterer
+type MetricsOptions struct {
+	// Name 数据库实例名，作为指标的 database 标签，同一进程中的多个数据库需要使用不同的名称
+	Name string `cfg:"name" validate:"required"`
+	// Buckets 操作耗时直方图的分桶，单位秒，为空时使用 prometheus.DefBuckets
+	Buckets []float64 `cfg:"buckets"`
+}
+
+// PoolStats 连接池统计
+type PoolStats struct {
+	OpenConnections int // 已建立的连接数
+	InUse           int // 使用中的连接数
+	Idle            int // 空闲连接数
+	// WaitCount、WaitDuration SQL 为等待空闲连接的次数和总时间，MongoDB、Elasticsearch 为获取连接的次数和总耗时
+	WaitCount    int64
+	WaitDuration time.Duration
+}
+
+// PoolStatsProvider 支持查询连接池统计的数据库
+type PoolStatsProvider interface {
+	PoolStats() PoolStats
+}
+
+// Metrics rdb 的 Prometheus 指标，同一 registry 中的数据库共享指标，通过 database 标签区分
+//
+//	rdb_operation_duration_seconds{database,table,operation} 操作耗时
+//	rdb_errors_total{database,table,operation}               操作错误数，ErrRecordNotFound 不计入
+//	rdb_pool_open_connections{database}                      已建立的连接数
+//	rdb_pool_in_use_connections{database}                    使用中的连接数
+//	rdb_pool_idle_connections{database}                      空闲连接数
+//	rdb_pool_wait_total{database}                            等待连接的次数
+//	rdb_pool_wait_seconds_total{database}                    等待连接的总时间
+type Metrics struct {
+	duration *prometheus.HistogramVec
+	errors   *prometheus.CounterVec
+	pools    *poolCollector
+}
+
+// NewMetrics 在 registerer 中注册 rdb 指标，registerer 为 nil 时使用 prometheus.DefaultRegisterer
+// 指标已注册时复用已注册的指标，buckets 以第一次注册的为准
+func NewMetrics(registerer prometheus.Registerer, buckets []float64) (*Metrics, error) {
+	if registerer == nil {
+		registerer = prometheus.DefaultRegisterer
+	}
+	if len(buckets) == 0 {
+		buckets = prometheus.DefBuckets
+	}
+
+	duration, err := registerCollector(registerer, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "rdb_operation_duration_seconds",
+		Help:    "Duration of database operations in seconds.",
+		Buckets: buckets,
+	}, []string{"database", "table", "operation"}))
+	if err != nil {
+		return nil, err
+	}
+	errorsTotal, err := registerCollector(registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "rdb_errors_total",
+		Help: "Total number of failed database operations.",
+	}, []string{"database", "table", "operation"}))
+	if err != nil {
+		return nil, err
+	}
+	pools, err := registerCollector(registerer, newPoolCollector())
+	if err != nil {
+		return nil, err
+	}
+
+	return &Metrics{duration: duration, errors: errorsTotal, pools: pools}, nil
+}
+
+// registerCollector 注册指标，已注册时返回已注册的指标
+func registerCollector[T prometheus.Collector](registerer prometheus.Registerer, collector T) (T, error) {
+	if err := registerer.Register(collector); err != nil {
+		var registered prometheus.AlreadyRegisteredError
+		if errors.As(err, &registered) {
+			if existing, ok := registered.ExistingCollector.(T); ok {
+				return existing, nil
+			}
+		}
+		return collector, err
+	}
+	return collector, nil
+}
+
+// Interceptor 返回记录操作耗时和错误数的拦截器，database 为指标的 database 标签
+func (m *Metrics) Interceptor(database string) Interceptor {
+	return func(ctx context.Context, op OperationInfo, next Handler) error {
+		start := time.Now()
+		err := next(ctx, op)
+		m.duration.WithLabelValues(database, op.Table, string(op.Operation)).Observe(time.Since(start).Seconds())
+		if err != nil && !errors.Is(err, ErrRecordNotFound) {
+			m.errors.WithLabelValues(database, op.Table, string(op.Operation)).Inc()
+		}
+		return err
+	}
+}
+
+// RegisterPool 注册连接池指标，在每次采集时调用 stats，返回的函数用于注销，数据库关闭时调用
+func (m *Metrics) RegisterPool(database string, stats func() PoolStats) func() {
+	return m.pools.add(database, stats)
+}
+
+// registerPoolMetrics 按配置将连接池注册到默认 registry，未配置时返回空函数
+func registerPoolMetrics(options *MetricsOptions, provider PoolStatsProvider) (func(), error) {
+	if options == nil {
+		return func() {}, nil
+	}
+	metrics, err := NewMetrics(nil, options.Buckets)
+	if err != nil {
+		return nil, err
+	}
+	return metrics.RegisterPool(options.Name, provider.PoolStats), nil
+}
+
+var (
+	poolOpenDesc  = prometheus.NewDesc("rdb_pool_open_connections", "Number of established connections.", []string{"database"}, nil)
+	poolInUseDesc = prometheus.NewDesc("rdb_pool_in_use_connections", "Number of connections currently in use.", []string{"database"}, nil)
+	poolIdleDesc  = prometheus.NewDesc("rdb_pool_idle_connections", "Number of idle connections.", []string{"database"}, nil)
+	poolWaitDesc  = prometheus.NewDesc("rdb_pool_wait_total", "Total number of connections waited for.", []string{"database"}, nil)
+	poolWaitTime  = prometheus.NewDesc("rdb_pool_wait_seconds_total", "Total time waited for connections in seconds.", []string{"database"}, nil)
+)
+
+// poolCollector 在采集时读取各数据库的连接池统计
+type poolCollector struct {
+	mu    sync.RWMutex
+	pools map[string]func() PoolStats
+}
+
+func newPoolCollector() *poolCollector {
+	return &poolCollector{pools: make(map[string]func() PoolStats)}
+}
+
+func (c *poolCollector) add(database string, stats func() PoolStats) func() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pools[database] = stats
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			delete(c.pools, database)
+		})
+	}
+}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- poolOpenDesc
+	ch <- poolInUseDesc
+	ch <- poolIdleDesc
+	ch <- poolWaitDesc
+	ch <- poolWaitTime
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for database, stats := range c.pools {
+		s := stats()
+		ch <- prometheus.MustNewConstMetric(poolOpenDesc, prometheus.GaugeValue, float64(s.OpenConnections), database)
+		ch <- prometheus.MustNewConstMetric(poolInUseDesc, prometheus.GaugeValue, float64(s.InUse), database)
+		ch <- prometheus.MustNewConstMetric(poolIdleDesc, prometheus.GaugeValue, float64(s.Idle), database)
+		ch <- prometheus.MustNewConstMetric(poolWaitDesc, prometheus.CounterValue, float64(s.WaitCount), database)
+		ch <- prometheus.MustNewConstMetric(poolWaitTime, prometheus.CounterValue, s.WaitDuration.Seconds(), database)
+	}
+}
+
+// mongoPoolMonitor 通过连接池事件统计 MongoDB 连接池，重建客户端后继续累计
+type mongoPoolMonitor struct {
+	open         atomic.Int64
+	inUse        atomic.Int64
+	waitCount    atomic.Int64
+	waitDuration atomic.Int64
+}
+
+func (p *mongoPoolMonitor) monitor() *event.PoolMonitor {
+	return &event.PoolMonitor{
+		Event: func(e *event.PoolEvent) {
+			switch e.Type {
+			case event.ConnectionCreated:
+				p.open.Add(1)
+			case event.ConnectionClosed:
+				p.open.Add(-1)
+			case event.GetSucceeded:
+				p.inUse.Add(1)
+				p.waitCount.Add(1)
+				p.waitDuration.Add(int64(e.Duration))
+			case event.GetFailed:
+				p.waitCount.Add(1)
+				p.waitDuration.Add(int64(e.Duration))
+			case event.ConnectionReturned:
+				p.inUse.Add(-1)
+			}
+		},
+	}
+}
+
+func (p *mongoPoolMonitor) stats() PoolStats {
+	open, inUse := int(p.open.Load()), int(p.inUse.Load())
+	return PoolStats{
+		OpenConnections: open,
+		InUse:           inUse,
+		Idle:            max(open-inUse, 0),
+		WaitCount:       p.waitCount.Load(),
+		WaitDuration:    time.Duration(p.waitDuration.Load()),
+	}
+}
+
+// esPoolMonitor 通过包装 http.Transport 统计 Elasticsearch 连接池，重建客户端后继续累计
+// 使用中的连接为进行中的请求，响应体关闭后归还
+type esPoolMonitor struct {
+	open         atomic.Int64
+	inUse        atomic.Int64
+	waitCount    atomic.Int64
+	waitDuration atomic.Int64
+}
+
+// wrap 统计 transport 通过 dialer 建立的连接和 transport 上进行中的请求
+func (p *esPoolMonitor) wrap(transport *http.Transport, dialer *net.Dialer) http.RoundTripper {
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dialer.DialContext(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		p.open.Add(1)
+		return &esPoolConn{Conn: conn, pool: p}, nil
+	}
+	return &esPoolTransport{Transport: transport, pool: p}
+}
+
+func (p *esPoolMonitor) stats() PoolStats {
+	open, inUse := int(p.open.Load()), int(p.inUse.Load())
+	return PoolStats{
+		OpenConnections: open,
+		InUse:           inUse,
+		Idle:            max(open-inUse, 0),
+		WaitCount:       p.waitCount.Load(),
+		WaitDuration:    time.Duration(p.waitDuration.Load()),
+	}
+}
+
+type esPoolTransport struct {
+	*http.Transport
+	pool *esPoolMonitor
+}
+
+func (t *esPoolTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+		GotConn: func(httptrace.GotConnInfo) {
+			t.pool.waitCount.Add(1)
+			t.pool.waitDuration.Add(int64(time.Since(start)))
+		},
+	}))
+
+	t.pool.inUse.Add(1)
+	res, err := t.Transport.RoundTrip(req)
+	if err != nil {
+		t.pool.inUse.Add(-1)
+		return nil, err
+	}
+	res.Body = &esPoolBody{ReadCloser: res.Body, pool: t.pool}
+	return res, nil
+}
+
+// esPoolBody 响应体关闭时结束请求
+type esPoolBody struct {
+	io.ReadCloser
+	pool   *esPoolMonitor
+	closed atomic.Bool
+}
+
+func (b *esPoolBody) Close() error {
+	if b.closed.CompareAndSwap(false, true) {
+		b.pool.inUse.Add(-1)
+	}
+	return b.ReadCloser.Close()
+}
+
+// esPoolConn 连接关闭时减少已建立的连接数
+type esPoolConn struct {
+	net.Conn
+	pool   *esPoolMonitor
+	closed atomic.Bool
+}
+
+func (c *esPoolConn) Close() error {
+	if c.closed.CompareAndSwap(false, true) {
+		c.pool.open.Add(-1)
+	}
+	return c.Conn.Close()
+}
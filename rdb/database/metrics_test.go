@@ -0,0 +1,184 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	. "github.com/smartystreets/goconvey/convey"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+func TestMetrics(t *testing.T) {
+	Convey("测试 Prometheus 指标", t, func() {
+		sql, err := NewSQLWithOptions(&SQLOptions{Driver: "sqlite3", Database: ":memory:", MaxConns: 1, MaxIdle: 1})
+		So(err, ShouldBeNil)
+		defer sql.Close()
+
+		ctx := context.Background()
+		So(sql.Migrate(ctx, &TableModel{
+			Table: "test_metrics_users",
+			Fields: []FieldDefinition{
+				{Name: "id", Type: FieldTypeInt, Required: true},
+				{Name: "name", Type: FieldTypeString, Size: 100},
+			},
+			PrimaryKey: []string{"id"},
+		}), ShouldBeNil)
+
+		registry := prometheus.NewRegistry()
+		metrics, err := NewMetrics(registry, []float64{0.001, 0.01, 0.1, 1})
+		So(err, ShouldBeNil)
+
+		Convey("记录操作耗时和错误数", func() {
+			f := &failingInterceptor{operation: OpUpdate, n: 1, err: errors.New("boom"), calls: map[Operation]int{}}
+			db := NewInterceptorDatabase(sql, metrics.Interceptor("main"), f.intercept)
+
+			So(db.Create(ctx, "test_metrics_users", sql.GetBuilder().FromMap(map[string]any{"id": 1, "name": "alice"}, "test_metrics_users")), ShouldBeNil)
+			_, err := db.Get(ctx, "test_metrics_users", map[string]any{"id": 1})
+			So(err, ShouldBeNil)
+			_, err = db.Get(ctx, "test_metrics_users", map[string]any{"id": 2})
+			So(errors.Is(err, ErrRecordNotFound), ShouldBeTrue)
+			So(db.Update(ctx, "test_metrics_users", map[string]any{"id": 1}, sql.GetBuilder().FromMap(map[string]any{"name": "bob"}, "test_metrics_users")), ShouldNotBeNil)
+
+			So(testutil.CollectAndCount(metrics.duration), ShouldEqual, 3)
+			So(testutil.CollectAndCount(metrics.errors), ShouldEqual, 1)
+			So(testutil.ToFloat64(metrics.errors.WithLabelValues("main", "test_metrics_users", string(OpUpdate))), ShouldEqual, 1)
+			// 记录不存在不计入错误
+			So(testutil.ToFloat64(metrics.errors.WithLabelValues("main", "test_metrics_users", string(OpGet))), ShouldEqual, 0)
+		})
+
+		Convey("重复注册复用已注册的指标", func() {
+			again, err := NewMetrics(registry, nil)
+			So(err, ShouldBeNil)
+			So(again.duration, ShouldEqual, metrics.duration)
+			So(again.errors, ShouldEqual, metrics.errors)
+			So(again.pools, ShouldEqual, metrics.pools)
+		})
+
+		Convey("连接池指标", func() {
+			unregister := metrics.RegisterPool("main", sql.PoolStats)
+			So(testutil.CollectAndCount(metrics.pools), ShouldEqual, 5)
+
+			families, err := registry.Gather()
+			So(err, ShouldBeNil)
+			values := map[string]float64{}
+			for _, family := range families {
+				for _, m := range family.GetMetric() {
+					if m.GetGauge() != nil {
+						values[family.GetName()] = m.GetGauge().GetValue()
+					}
+				}
+			}
+			So(values["rdb_pool_open_connections"], ShouldEqual, 1)
+			So(values["rdb_pool_idle_connections"], ShouldEqual, 1)
+			So(values["rdb_pool_in_use_connections"], ShouldEqual, 0)
+
+			unregister()
+			unregister()
+			So(testutil.CollectAndCount(metrics.pools), ShouldEqual, 0)
+		})
+	})
+
+	Convey("测试配置注册连接池指标", t, func() {
+		sql, err := NewSQLWithOptions(&SQLOptions{Driver: "sqlite3", Database: ":memory:", MaxConns: 1, MaxIdle: 1, Metrics: &MetricsOptions{Name: "test_metrics_options"}})
+		So(err, ShouldBeNil)
+
+		metrics, err := NewMetrics(nil, nil)
+		So(err, ShouldBeNil)
+		So(testutil.CollectAndCount(metrics.pools), ShouldBeGreaterThanOrEqualTo, 5)
+
+		So(sql.Close(), ShouldBeNil)
+		So(testutil.CollectAndCount(metrics.pools), ShouldEqual, 0)
+	})
+}
+
+func TestMongoPoolMonitor(t *testing.T) {
+	Convey("测试 MongoDB 连接池事件统计", t, func() {
+		pool := &mongoPoolMonitor{}
+		monitor := pool.monitor()
+		for _, t := range []string{
+			event.ConnectionCreated, event.ConnectionCreated,
+			event.GetSucceeded, event.GetSucceeded, event.ConnectionReturned,
+			event.GetFailed, event.ConnectionClosed,
+		} {
+			monitor.Event(&event.PoolEvent{Type: t, Duration: time.Millisecond})
+		}
+		stats := pool.stats()
+		So(stats.OpenConnections, ShouldEqual, 1)
+		So(stats.InUse, ShouldEqual, 1)
+		So(stats.Idle, ShouldEqual, 0)
+		So(stats.WaitCount, ShouldEqual, 3)
+		So(stats.WaitDuration, ShouldEqual, 3*time.Millisecond)
+	})
+
+	Convey("测试不支持的压缩算法", t, func() {
+		mongo, err := NewMongoWithOptions(&MongoOptions{Host: "localhost", Port: 27017, Compressors: []string{"snappy", "gzip"}})
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldContainSubstring, "unsupported compressor: gzip")
+		So(mongo, ShouldBeNil)
+	})
+}
+
+func TestESPoolMonitor(t *testing.T) {
+	Convey("测试 Elasticsearch 连接池统计", t, func() {
+		var contentEncoding atomic.Value
+		fake := newFakeES(func(w http.ResponseWriter, r *http.Request, body string) {
+			contentEncoding.Store(r.Header.Get("Content-Encoding"))
+			w.Write([]byte(`{}`))
+		})
+		defer fake.server.Close()
+
+		options := fake.options()
+		options.MaxConnsPerHost = 1
+		options.Compression = true
+		options.Metrics = &MetricsOptions{Name: "test_es_pool"}
+		es, err := NewESWithOptions(options)
+		So(err, ShouldBeNil)
+
+		metrics, err := NewMetrics(nil, nil)
+		So(err, ShouldBeNil)
+		So(testutil.CollectAndCount(metrics.pools), ShouldBeGreaterThanOrEqualTo, 5)
+
+		// 连接测试的 Info 请求建立一个连接
+		stats := es.PoolStats()
+		So(stats.OpenConnections, ShouldEqual, 1)
+		So(stats.InUse, ShouldEqual, 0)
+		So(stats.Idle, ShouldEqual, 1)
+		So(stats.WaitCount, ShouldEqual, 1)
+
+		// 响应体关闭之前连接处于使用中
+		req, err := http.NewRequest(http.MethodPost, "/users/_search", strings.NewReader(`{"query":{"match_all":{}}}`))
+		So(err, ShouldBeNil)
+		res, err := es.getClient().Perform(req)
+		So(err, ShouldBeNil)
+		So(contentEncoding.Load(), ShouldEqual, "gzip")
+		stats = es.PoolStats()
+		So(stats.OpenConnections, ShouldEqual, 1)
+		So(stats.InUse, ShouldEqual, 1)
+		So(stats.Idle, ShouldEqual, 0)
+		So(stats.WaitCount, ShouldEqual, 2)
+		So(stats.WaitDuration, ShouldBeGreaterThan, 0)
+
+		res.Body.Close()
+		res.Body.Close()
+		stats = es.PoolStats()
+		So(stats.InUse, ShouldEqual, 0)
+		So(stats.Idle, ShouldEqual, 1)
+
+		// 重建客户端后关闭旧的空闲连接，统计继续累计
+		es.reconnect(context.Background())
+		stats = es.PoolStats()
+		So(stats.OpenConnections, ShouldEqual, 1)
+		So(stats.WaitCount, ShouldEqual, 3)
+
+		So(es.Close(), ShouldBeNil)
+		So(es.PoolStats().OpenConnections, ShouldEqual, 0)
+		So(testutil.CollectAndCount(metrics.pools), ShouldEqual, 0)
+	})
+}
@@ -0,0 +1,314 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// MigrationFormat 迁移文件格式
+type MigrationFormat string
+
+const (
+	// MigrationFormatSQL DDL 语句，仅 SQL 数据库支持，通过 ApplySQLFile 执行
+	MigrationFormatSQL MigrationFormat = "sql"
+	// MigrationFormatJSON 表模型，通过 MigrateDiff（不支持时为 Migrate）应用，各后端通用
+	MigrationFormatJSON MigrationFormat = "json"
+)
+
+// defaultMigrationTable 默认的迁移历史表
+const defaultMigrationTable = "schema_migrations"
+
+// migrationFilePattern 迁移文件名 <version>_<name>.<format>，version 为数字
+var migrationFilePattern = regexp.MustCompile(`^([0-9]+)_([A-Za-z0-9_-]+)\.(sql|json)$`)
+
+// Migration 迁移文件
+type Migration struct {
+	Version string
+	Name    string
+	Format  MigrationFormat
+	Path    string // 文件在 fs.FS 中的路径
+}
+
+// MigrationOptions 迁移选项
+type MigrationOptions struct {
+	Table  string // 迁移历史表，默认为 schema_migrations
+	DryRun bool   // 只返回待应用的版本，不实际执行
+}
+
+type MigrationOption func(*MigrationOptions)
+
+// WithMigrationTable 设置迁移历史表
+func WithMigrationTable(table string) MigrationOption {
+	return func(opts *MigrationOptions) {
+		opts.Table = table
+	}
+}
+
+// WithMigrationDryRun 只返回待应用的版本
+func WithMigrationDryRun() MigrationOption {
+	return func(opts *MigrationOptions) {
+		opts.DryRun = true
+	}
+}
+
+// sqlFileApplier 支持执行 SQL 脚本的数据库
+type sqlFileApplier interface {
+	ApplySQLFile(ctx context.Context, fsys fs.FS, pattern string) error
+}
+
+// ExportSchema 将表模型以 JSON 格式写入 w
+// Elasticsearch 过滤别名的查询条件无法序列化，包含过滤条件的视图返回错误
+func ExportSchema(w io.Writer, models []*TableModel) error {
+	for _, model := range models {
+		for _, view := range model.Views {
+			if view.Filter != nil {
+				return fmt.Errorf("view %s of table %s has a filter query which cannot be exported", view.Name, model.Table)
+			}
+		}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(models)
+}
+
+// ImportSchema 读取 ExportSchema 导出的表模型
+func ImportSchema(r io.Reader) ([]*TableModel, error) {
+	var models []*TableModel
+	if err := json.NewDecoder(r).Decode(&models); err != nil {
+		return nil, errors.Wrap(err, "failed to decode schema")
+	}
+	return models, nil
+}
+
+// WriteMigration 在 dir 下生成迁移文件 <version>_<name>.<format>，返回文件路径，version 为空时使用当前 UTC 时间 yyyyMMddHHmmss
+//
+// JSON 格式写入全部表模型；SQL 格式写入 db 当前结构到表模型的增量 DDL（MigrateDiff 的 DryRun 结果），
+// 只有 SQL 数据库支持，没有需要执行的语句时不生成文件，返回空路径
+func WriteMigration(ctx context.Context, db Database, dir string, version string, name string, models []*TableModel, format MigrationFormat) (string, error) {
+	if version == "" {
+		version = time.Now().UTC().Format("20060102150405")
+	}
+	filename := fmt.Sprintf("%s_%s.%s", version, name, format)
+	if !migrationFilePattern.MatchString(filename) {
+		return "", fmt.Errorf("invalid migration file name %q", filename)
+	}
+
+	var content strings.Builder
+	switch format {
+	case MigrationFormatJSON:
+		if err := ExportSchema(&content, models); err != nil {
+			return "", err
+		}
+	case MigrationFormatSQL:
+		migrator, ok := db.(SchemaMigrator)
+		if _, isSQL := db.(sqlFileApplier); !ok || !isSQL {
+			return "", fmt.Errorf("sql migration is only supported by sql database, got %T", db)
+		}
+		for _, model := range models {
+			statements, err := migrator.MigrateDiff(ctx, model, WithDryRun())
+			if err != nil {
+				return "", errors.WithMessagef(err, "failed to diff table %s", model.Table)
+			}
+			for _, statement := range statements {
+				content.WriteString(statement)
+				content.WriteString(";\n")
+			}
+		}
+		if content.Len() == 0 {
+			return "", nil
+		}
+	default:
+		return "", fmt.Errorf("unsupported migration format %q", format)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", errors.Wrapf(err, "failed to create migration dir %s", dir)
+	}
+	file := filepath.Join(dir, filename)
+	if _, err := os.Stat(file); err == nil {
+		return "", fmt.Errorf("migration file %s already exists", file)
+	}
+	if err := os.WriteFile(file, []byte(content.String()), 0644); err != nil {
+		return "", errors.Wrapf(err, "failed to write migration file %s", file)
+	}
+	return file, nil
+}
+
+// ListMigrations 列出 fsys 中 dir 目录下的迁移文件，按版本号升序，不符合命名规则的文件被忽略
+func ListMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read migration dir %s", dir)
+	}
+
+	var migrations []Migration
+	versions := make(map[string]string)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		matches := migrationFilePattern.FindStringSubmatch(entry.Name())
+		if matches == nil {
+			continue
+		}
+		version := strings.TrimLeft(matches[1], "0")
+		if version == "" {
+			version = "0"
+		}
+		if other, exists := versions[version]; exists {
+			return nil, fmt.Errorf("duplicate migration version %s: %s and %s", version, other, entry.Name())
+		}
+		versions[version] = entry.Name()
+		migrations = append(migrations, Migration{
+			Version: version,
+			Name:    matches[2],
+			Format:  MigrationFormat(matches[3]),
+			Path:    path.Join(dir, entry.Name()),
+		})
+	}
+
+	// 版本号可能超出整数范围，按长度再按字典序比较
+	sort.Slice(migrations, func(i, j int) bool {
+		a, b := migrations[i].Version, migrations[j].Version
+		if len(a) != len(b) {
+			return len(a) < len(b)
+		}
+		return a < b
+	})
+	return migrations, nil
+}
+
+// ErrMigrationsDisabled 配置 AllowMigrations 为 false 时，ApplyMigrations 之外的 Migrate 和 MigrateDiff 返回的错误
+var ErrMigrationsDisabled = errors.New("migrations are disabled, apply schema changes with ApplyMigrations")
+
+type migrationsAllowedKey struct{}
+
+// WithMigrationsAllowed 返回允许迁移的 context，不受 AllowMigrations 限制
+// ApplyMigrations 使用它执行迁移，部署流程中的其它迁移工具也可以使用
+func WithMigrationsAllowed(ctx context.Context) context.Context {
+	return context.WithValue(ctx, migrationsAllowedKey{}, true)
+}
+
+// checkMigrationsAllowed allow 为空或为 true 时允许迁移，否则只允许 WithMigrationsAllowed 的 context
+func checkMigrationsAllowed(ctx context.Context, allow *bool, table string) error {
+	if allow == nil || *allow {
+		return nil
+	}
+	if allowed, _ := ctx.Value(migrationsAllowedKey{}).(bool); allowed {
+		return nil
+	}
+	return errors.WithMessagef(ErrMigrationsDisabled, "failed to migrate %s", table)
+}
+
+// ApplyMigrations 按版本号顺序应用 fsys 中 dir 目录下尚未应用的迁移，返回本次应用的版本
+//
+// 已应用的版本记录在迁移历史表中，表不存在时自动创建。每个迁移应用成功后立即写入历史，
+// 失败时返回错误，之前的迁移保持已应用状态，修复后重新执行会从失败的版本继续。
+// 多个实例同时执行时可能重复应用同一个迁移，应在部署流程中单独执行
+func ApplyMigrations(ctx context.Context, db Database, fsys fs.FS, dir string, opts ...MigrationOption) ([]string, error) {
+	options := &MigrationOptions{Table: defaultMigrationTable}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	migrations, err := ListMigrations(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx = WithMigrationsAllowed(ctx)
+	if err := db.Migrate(ctx, migrationHistoryModel(options.Table)); err != nil {
+		return nil, errors.WithMessage(err, "failed to create migration history table")
+	}
+
+	var applied []string
+	for _, migration := range migrations {
+		_, err := db.Get(ctx, options.Table, map[string]any{"version": migration.Version})
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, ErrRecordNotFound) {
+			return applied, errors.WithMessagef(err, "failed to get migration history %s", migration.Version)
+		}
+
+		if !options.DryRun {
+			if err := applyMigration(ctx, db, fsys, migration); err != nil {
+				return applied, errors.WithMessagef(err, "failed to apply migration %s", migration.Path)
+			}
+			record := db.GetBuilder().FromMap(map[string]any{
+				"version":    migration.Version,
+				"name":       migration.Name,
+				"applied_at": time.Now(),
+			}, options.Table)
+			if err := db.Create(ctx, options.Table, record); err != nil {
+				return applied, errors.WithMessagef(err, "failed to record migration %s", migration.Version)
+			}
+		}
+		applied = append(applied, migration.Version)
+	}
+
+	return applied, nil
+}
+
+func applyMigration(ctx context.Context, db Database, fsys fs.FS, migration Migration) error {
+	switch migration.Format {
+	case MigrationFormatSQL:
+		applier, ok := db.(sqlFileApplier)
+		if !ok {
+			return fmt.Errorf("sql migration is only supported by sql database, got %T", db)
+		}
+		return applier.ApplySQLFile(ctx, fsys, migration.Path)
+	default:
+		file, err := fsys.Open(migration.Path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		models, err := ImportSchema(file)
+		if err != nil {
+			return err
+		}
+		for _, model := range models {
+			// MigrateDiff 只补齐表、列和索引，视图仍由 Migrate 创建或替换
+			if migrator, ok := db.(SchemaMigrator); ok {
+				if _, err := migrator.MigrateDiff(ctx, model); err != nil {
+					return errors.WithMessagef(err, "failed to migrate table %s", model.Table)
+				}
+				if len(model.Views) == 0 {
+					continue
+				}
+			}
+			if err := db.Migrate(ctx, model); err != nil {
+				return errors.WithMessagef(err, "failed to migrate table %s", model.Table)
+			}
+		}
+		return nil
+	}
+}
+
+// migrationHistoryModel 迁移历史表结构
+func migrationHistoryModel(table string) *TableModel {
+	return &TableModel{
+		Table: table,
+		Fields: []FieldDefinition{
+			{Name: "version", Type: FieldTypeString, Size: 64, Required: true},
+			{Name: "name", Type: FieldTypeString, Size: 255},
+			{Name: "applied_at", Type: FieldTypeDate},
+		},
+		PrimaryKey: []string{"version"},
+	}
+}
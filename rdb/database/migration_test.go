@@ -0,0 +1,212 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hatlonely/gox/cfg/storage"
+	"github.com/hatlonely/gox/rdb/query"
+	"github.com/hatlonely/gox/ref"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestExportImportSchema(t *testing.T) {
+	Convey("测试表模型导出导入", t, func() {
+		models := []*TableModel{{
+			Table: "users",
+			Fields: []FieldDefinition{
+				{Name: "id", Type: FieldTypeString, Size: 36, Required: true},
+				{Name: "name", Type: FieldTypeString, Size: 100, Default: "anonymous"},
+				{Name: "active", Type: FieldTypeBool, Default: true},
+			},
+			PrimaryKey: []string{"id"},
+			Indexes:    []IndexDefinition{{Name: "idx_users_name", Fields: []string{"name"}, Unique: true}},
+			Version:    "version",
+			Views:      []ViewDefinition{{Name: "active_users", Select: "SELECT id, name FROM users WHERE active = 1"}},
+			IDStrategy: IDStrategyUUIDv7,
+		}}
+
+		var buf bytes.Buffer
+		So(ExportSchema(&buf, models), ShouldBeNil)
+		So(buf.String(), ShouldContainSubstring, `"primaryKey": [`)
+
+		imported, err := ImportSchema(&buf)
+		So(err, ShouldBeNil)
+		So(imported, ShouldResemble, models)
+
+		Convey("过滤别名无法导出", func() {
+			models[0].Views = []ViewDefinition{{Name: "active_users", Filter: &query.TermQuery{Field: "active", Value: true}}}
+			So(ExportSchema(&bytes.Buffer{}, models), ShouldNotBeNil)
+		})
+
+		Convey("格式错误", func() {
+			_, err := ImportSchema(bytes.NewBufferString("{"))
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestListMigrations(t *testing.T) {
+	Convey("测试列出迁移文件", t, func() {
+		dir := t.TempDir()
+		for _, name := range []string{"10_add_email.sql", "0002_create_orders.json", "1_init.json", "README.md", "3-bad.sql"} {
+			So(os.WriteFile(filepath.Join(dir, name), []byte("[]"), 0644), ShouldBeNil)
+		}
+
+		migrations, err := ListMigrations(os.DirFS(dir), ".")
+		So(err, ShouldBeNil)
+		So(migrations, ShouldResemble, []Migration{
+			{Version: "1", Name: "init", Format: MigrationFormatJSON, Path: "1_init.json"},
+			{Version: "2", Name: "create_orders", Format: MigrationFormatJSON, Path: "0002_create_orders.json"},
+			{Version: "10", Name: "add_email", Format: MigrationFormatSQL, Path: "10_add_email.sql"},
+		})
+
+		Convey("版本号重复", func() {
+			So(os.WriteFile(filepath.Join(dir, "01_init_again.sql"), []byte(""), 0644), ShouldBeNil)
+			_, err := ListMigrations(os.DirFS(dir), ".")
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestApplyMigrations(t *testing.T) {
+	Convey("测试生成和应用迁移文件", t, func() {
+		sql, err := NewSQLWithOptions(&SQLOptions{Driver: "sqlite3", Database: ":memory:", MaxConns: 1, MaxIdle: 1})
+		So(err, ShouldBeNil)
+		defer sql.Close()
+
+		ctx := context.Background()
+		dir := t.TempDir()
+		model := &TableModel{
+			Table: "test_migration_users",
+			Fields: []FieldDefinition{
+				{Name: "id", Type: FieldTypeInt, Required: true},
+				{Name: "name", Type: FieldTypeString, Size: 100},
+			},
+			PrimaryKey: []string{"id"},
+		}
+
+		file, err := WriteMigration(ctx, sql, dir, "1", "init", []*TableModel{model}, MigrationFormatJSON)
+		So(err, ShouldBeNil)
+		So(file, ShouldEqual, filepath.Join(dir, "1_init.json"))
+
+		_, err = WriteMigration(ctx, sql, dir, "1", "init", []*TableModel{model}, MigrationFormatJSON)
+		So(err, ShouldNotBeNil)
+
+		pending, err := ApplyMigrations(ctx, sql, os.DirFS(dir), ".", WithMigrationDryRun())
+		So(err, ShouldBeNil)
+		So(pending, ShouldResemble, []string{"1"})
+
+		applied, err := ApplyMigrations(ctx, sql, os.DirFS(dir), ".")
+		So(err, ShouldBeNil)
+		So(applied, ShouldResemble, []string{"1"})
+		So(sql.Create(ctx, "test_migration_users", sql.GetBuilder().FromMap(map[string]any{"id": 1, "name": "alice"}, "test_migration_users")), ShouldBeNil)
+
+		// 模型新增列，SQL 迁移文件写入增量 DDL
+		model.Fields = append(model.Fields, FieldDefinition{Name: "email", Type: FieldTypeString, Size: 255})
+		model.Indexes = []IndexDefinition{{Name: "idx_migration_users_email", Fields: []string{"email"}}}
+		file, err = WriteMigration(ctx, sql, dir, "2", "add_email", []*TableModel{model}, MigrationFormatSQL)
+		So(err, ShouldBeNil)
+		content, err := os.ReadFile(file)
+		So(err, ShouldBeNil)
+		So(string(content), ShouldContainSubstring, "ALTER TABLE")
+		So(string(content), ShouldContainSubstring, "idx_migration_users_email")
+
+		applied, err = ApplyMigrations(ctx, sql, os.DirFS(dir), ".")
+		So(err, ShouldBeNil)
+		So(applied, ShouldResemble, []string{"2"})
+		So(sql.Update(ctx, "test_migration_users", map[string]any{"id": 1}, sql.GetBuilder().FromMap(map[string]any{"id": 1, "name": "alice", "email": "alice@example.com"}, "test_migration_users")), ShouldBeNil)
+
+		// 结构已是最新，不生成文件，已应用的迁移不再执行
+		file, err = WriteMigration(ctx, sql, dir, "3", "noop", []*TableModel{model}, MigrationFormatSQL)
+		So(err, ShouldBeNil)
+		So(file, ShouldEqual, "")
+
+		applied, err = ApplyMigrations(ctx, sql, os.DirFS(dir), ".")
+		So(err, ShouldBeNil)
+		So(applied, ShouldBeEmpty)
+
+		history, err := sql.Get(ctx, defaultMigrationTable, map[string]any{"version": "2"})
+		So(err, ShouldBeNil)
+		So(history.Fields()["name"], ShouldEqual, "add_email")
+
+		Convey("迁移失败时之前的迁移保持已应用", func() {
+			So(os.WriteFile(filepath.Join(dir, "4_broken.sql"), []byte("ALTER TABLE missing_table ADD COLUMN x INT;"), 0644), ShouldBeNil)
+			So(os.WriteFile(filepath.Join(dir, "5_after.json"), []byte("[]"), 0644), ShouldBeNil)
+
+			applied, err := ApplyMigrations(ctx, sql, os.DirFS(dir), ".")
+			So(err, ShouldNotBeNil)
+			So(applied, ShouldBeEmpty)
+
+			_, err = sql.Get(ctx, defaultMigrationTable, map[string]any{"version": "5"})
+			So(err, ShouldEqual, ErrRecordNotFound)
+		})
+	})
+}
+
+func TestAllowMigrations(t *testing.T) {
+	Convey("测试禁止隐式迁移", t, func() {
+		db, err := NewDatabaseWithOptions(&ref.TypeOptions{
+			Namespace: "github.com/hatlonely/gox/rdb/database",
+			Type:      "SQL",
+			Options: storage.NewMapStorage(map[string]any{
+				"driver":          "sqlite3",
+				"database":        ":memory:",
+				"maxConns":        1,
+				"maxIdle":         1,
+				"allowMigrations": false,
+			}),
+		})
+		So(err, ShouldBeNil)
+		defer db.Close()
+
+		sql := db.(*SQL)
+		ctx := context.Background()
+		model := &TableModel{
+			Table: "test_allow_migrations",
+			Fields: []FieldDefinition{
+				{Name: "id", Type: FieldTypeInt, Required: true},
+				{Name: "name", Type: FieldTypeString, Size: 100},
+			},
+			PrimaryKey: []string{"id"},
+		}
+
+		So(errors.Is(sql.Migrate(ctx, model), ErrMigrationsDisabled), ShouldBeTrue)
+		_, err = sql.MigrateDiff(ctx, model)
+		So(errors.Is(err, ErrMigrationsDisabled), ShouldBeTrue)
+		So(errors.Is(sql.WithTx(ctx, func(tx Transaction) error {
+			return tx.Migrate(ctx, model)
+		}), ErrMigrationsDisabled), ShouldBeTrue)
+
+		// 只生成语句不修改表结构，仍然允许
+		statements, err := sql.MigrateDiff(ctx, model, WithDryRun())
+		So(err, ShouldBeNil)
+		So(statements, ShouldNotBeEmpty)
+
+		Convey("迁移执行器不受限制", func() {
+			dir := t.TempDir()
+			_, err := WriteMigration(ctx, sql, dir, "1", "init", []*TableModel{model}, MigrationFormatJSON)
+			So(err, ShouldBeNil)
+
+			applied, err := ApplyMigrations(ctx, sql, os.DirFS(dir), ".")
+			So(err, ShouldBeNil)
+			So(applied, ShouldResemble, []string{"1"})
+			So(sql.Create(ctx, model.Table, sql.GetBuilder().FromMap(map[string]any{"id": 1, "name": "alice"}, model.Table)), ShouldBeNil)
+		})
+
+		Convey("WithMigrationsAllowed 允许迁移", func() {
+			So(sql.Migrate(WithMigrationsAllowed(ctx), model), ShouldBeNil)
+		})
+
+		Convey("未配置时允许迁移", func() {
+			allowed, err := NewSQLWithOptions(&SQLOptions{Driver: "sqlite3", Database: ":memory:", MaxConns: 1, MaxIdle: 1})
+			So(err, ShouldBeNil)
+			defer allowed.Close()
+			So(allowed.Migrate(ctx, model), ShouldBeNil)
+		})
+	})
+}
@@ -5,23 +5,42 @@ import (
 	"reflect"
 	"strconv"
 	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hatlonely/gox/rdb/query"
 )
 
 // TableModel 表模型定义
 type TableModel struct {
-	Table      string // 表名
-	Fields     []FieldDefinition
-	PrimaryKey []string          // 主键字段名列表，支持复合主键
-	Indexes    []IndexDefinition // 普通索引
+	Table      string            `json:"table"` // 表名
+	Fields     []FieldDefinition `json:"fields"`
+	PrimaryKey []string          `json:"primaryKey,omitempty"` // 主键字段名列表，支持复合主键
+	Indexes    []IndexDefinition `json:"indexes,omitempty"`    // 普通索引
+	Version    string            `json:"version,omitempty"`    // 乐观锁版本字段名，为空时不启用乐观锁
+	Views      []ViewDefinition  `json:"views,omitempty"`      // 基于该表的视图，Migrate 时创建或替换
+	IDStrategy IDStrategy        `json:"idStrategy,omitempty"` // 主键生成策略，Create 时主键为空则自动生成，为空时不生成
 }
 
 // FieldDefinition 字段定义
 type FieldDefinition struct {
-	Name     string
-	Type     FieldType
-	Required bool
-	Default  any
-	Size     int // 字段长度，如 VARCHAR(255)
+	Name     string    `json:"name"`
+	Type     FieldType `json:"type"`
+	Required bool      `json:"required,omitempty"`
+	Default  any       `json:"default,omitempty"`
+	Size     int       `json:"size,omitempty"` // 字段长度，如 VARCHAR(255)
+}
+
+// ViewDefinition 视图定义，用于报表等只读场景的读模型，随表结构一起迁移
+// 各后端的视图定义方式不同，只需填写目标后端对应的字段
+type ViewDefinition struct {
+	Name string `json:"name"`
+	// Select SQL 视图的 SELECT 语句
+	Select string `json:"select,omitempty"`
+	// Pipeline MongoDB 视图基于 TableModel.Table 集合的聚合管道，如 mongo.Pipeline、[]bson.M
+	Pipeline any `json:"pipeline,omitempty"`
+	// Filter Elasticsearch 过滤别名的查询条件，为空时别名指向整个索引，不支持导出
+	Filter query.Query `json:"-"`
 }
 
 // FieldType 字段类型
@@ -34,26 +53,92 @@ const (
 	FieldTypeBool   FieldType = "bool"
 	FieldTypeDate   FieldType = "date"
 	FieldTypeJSON   FieldType = "json"
+	// FieldTypeBlob 大字段，内容存储在 BlobStore 中，记录中只保存键，见 NewBlobInterceptor
+	FieldTypeBlob FieldType = "blob"
+	// FieldTypeGeoPoint 经纬度位置，用于 GeoDistanceQuery 和 GeoBoundingBoxQuery
+	// ES 映射为 geo_point，Mongo 在 Migrate 时创建 2dsphere 索引，值需要以 GeoJSON 存储（见 query.GeoPoint.GeoJSON），
+	// postgres 映射为 PostGIS 的 geography，其他 SQL 数据库以 JSON 存储，不支持地理位置查询
+	FieldTypeGeoPoint FieldType = "geo_point"
+)
+
+// IndexKind 索引类型
+type IndexKind string
+
+const (
+	// IndexKindTTL 过期索引，Mongo 在 ExpireAfter 之后自动删除文档，只能有一个日期字段；SQL 创建普通索引，不会自动删除数据
+	IndexKindTTL IndexKind = "ttl"
+	// IndexKindText 全文索引，与 FullText 相同
+	IndexKindText IndexKind = "text"
+	// IndexKindGeo 地理位置索引，Mongo 为 2dsphere，postgres 为 GIST，其他 SQL 数据库忽略
+	IndexKindGeo IndexKind = "geo"
+	// IndexKindPartial 部分索引，只索引满足 Expression 的记录
+	IndexKindPartial IndexKind = "partial"
 )
 
 // IndexDefinition 索引定义
+// Elasticsearch 的字段默认都会被索引，迁移时忽略索引定义
 type IndexDefinition struct {
-	Name   string
-	Fields []string
-	Unique bool
+	Name     string    `json:"name"`
+	Fields   []string  `json:"fields"`
+	Unique   bool      `json:"unique,omitempty"`
+	FullText bool      `json:"fullText,omitempty"` // 全文索引，用于 FullTextQuery
+	Kind     IndexKind `json:"kind,omitempty"`     // 索引类型，为空时为普通索引
+	// Expression 部分索引的过滤条件，Kind 为 partial 时必须设置，其他类型的索引也可以附加，不支持导出
+	// Mongo 为 partialFilterExpression，SQLite 和 postgres 为 WHERE 子句，MySQL 不支持部分索引，非唯一索引退化为普通索引
+	Expression  query.Query   `json:"-"`
+	ExpireAfter time.Duration `json:"expireAfter,omitempty"` // 过期时间，Kind 为 ttl 时必须设置，精确到秒
+	Sparse      bool          `json:"sparse,omitempty"`      // 稀疏索引，只索引包含字段的文档，只对 Mongo 生效
+}
+
+// fullText 是否为全文索引
+func (index IndexDefinition) fullText() bool {
+	return index.FullText || index.Kind == IndexKindText
+}
+
+// validate 校验索引类型和对应的参数
+func (index IndexDefinition) validate() error {
+	switch index.Kind {
+	case "", IndexKindText, IndexKindGeo:
+	case IndexKindTTL:
+		if len(index.Fields) != 1 {
+			return fmt.Errorf("ttl index %s requires exactly one field", index.Name)
+		}
+		if index.ExpireAfter < time.Second {
+			return fmt.Errorf("ttl index %s requires expireAfter of at least 1s", index.Name)
+		}
+	case IndexKindPartial:
+		if index.Expression == nil {
+			return fmt.Errorf("partial index %s requires an expression", index.Name)
+		}
+	default:
+		return fmt.Errorf("unsupported index kind %q for index %s", index.Kind, index.Name)
+	}
+	if index.fullText() && index.Kind != "" && index.Kind != IndexKindText {
+		return fmt.Errorf("index %s cannot be both fulltext and %s", index.Name, index.Kind)
+	}
+	return nil
 }
 
 // TableModelBuilder 表模型构建器
-type TableModelBuilder struct{}
+type TableModelBuilder struct {
+	tagName string
+}
 
-// NewTableModelBuilder 创建新的表模型构建器
+// NewTableModelBuilder 创建新的表模型构建器，使用 rdb 标签
 func NewTableModelBuilder() *TableModelBuilder {
 	return &TableModelBuilder{}
 }
 
+// NewTableModelBuilderWithTag 创建使用 tagName 标签的表模型构建器，应与 RecordBuilder 使用的标签一致
+// 标签格式与 rdb 标签相同，db:"name" 这样只有列名的标签按推断的类型建表，其他库的选项（如 omitempty）被忽略
+func NewTableModelBuilderWithTag(tagName string) *TableModelBuilder {
+	return &TableModelBuilder{tagName: tagName}
+}
+
 // FromStruct 从结构体构建 TableModel
-// 支持的 tag 格式：
-// - `rdb:"column_name,type=string,size=255,required,primary,index,unique"`
+// 支持的 tag 格式（标签名可以通过 NewTableModelBuilderWithTag 修改）：
+// - `rdb:"column_name,type=string,size=255,required,primary,index,unique,version"`
+// - `rdb:"id,primary,idgen=uuidv7"` 为主键指定生成策略，见 IDStrategy
 // - `table:"table_name"` 用于指定表名（在结构体级别）
 func (b *TableModelBuilder) FromStruct(v any) (*TableModel, error) {
 	rv := reflect.ValueOf(v)
@@ -86,12 +171,12 @@ func (b *TableModelBuilder) FromStruct(v any) (*TableModel, error) {
 		}
 
 		// 解析 rdb tag
-		rdbTag := field.Tag.Get("rdb")
+		rdbTag := field.Tag.Get(tagNameOrDefault(b.tagName))
 		if rdbTag == "-" {
 			continue // 跳过被忽略的字段
 		}
 
-		fieldDef, isPrimary, indexes, err := b.parseFieldTag(field, rdbTag)
+		fieldDef, isPrimary, isVersion, indexes, err := b.parseFieldTag(field, rdbTag)
 		if err != nil {
 			return nil, fmt.Errorf("failed to parse field %s: %v", field.Name, err)
 		}
@@ -103,8 +188,33 @@ func (b *TableModelBuilder) FromStruct(v any) (*TableModel, error) {
 			primaryKeys = append(primaryKeys, fieldDef.Name)
 		}
 
+		// 处理主键生成策略
+		if strategy := tagOption(rdbTag, "idgen"); strategy != "" {
+			if !isPrimary {
+				return nil, fmt.Errorf("idgen on non-primary field %s", fieldDef.Name)
+			}
+			if !IDStrategy(strategy).Valid() {
+				return nil, fmt.Errorf("field %s: %w: %q", fieldDef.Name, ErrUnknownIDStrategy, strategy)
+			}
+			if fieldDef.Type != FieldTypeString {
+				return nil, fmt.Errorf("idgen requires string primary key, field %s is %s", fieldDef.Name, fieldDef.Type)
+			}
+			model.IDStrategy = IDStrategy(strategy)
+		}
+
+		// 处理版本字段
+		if isVersion {
+			if model.Version != "" {
+				return nil, fmt.Errorf("multiple version fields: %s, %s", model.Version, fieldDef.Name)
+			}
+			model.Version = fieldDef.Name
+		}
+
 		// 处理索引
 		for _, idx := range indexes {
+			if idx.fullText() && idx.Name == "" {
+				idx.Name = "ft_" + tableName
+			}
 			if existing, exists := indexMap[idx.Name]; exists {
 				// 合并字段到现有索引
 				existing.Fields = append(existing.Fields, fieldDef.Name)
@@ -116,6 +226,9 @@ func (b *TableModelBuilder) FromStruct(v any) (*TableModel, error) {
 	}
 
 	model.PrimaryKey = primaryKeys
+	if model.IDStrategy != "" && len(primaryKeys) != 1 {
+		return nil, fmt.Errorf("idgen requires exactly one primary key, got %v", primaryKeys)
+	}
 
 	// 添加索引到模型
 	for _, idx := range indexMap {
@@ -136,18 +249,28 @@ func (b *TableModelBuilder) getTableName(v any, rt reflect.Type) string {
 	return rt.Name()
 }
 
+// tagOption 获取 rdb tag 中键值对参数的值，不存在时返回空字符串
+func tagOption(tag string, key string) string {
+	for _, part := range strings.Split(tag, ",") {
+		if kv := strings.SplitN(strings.TrimSpace(part), "=", 2); len(kv) == 2 && strings.TrimSpace(kv[0]) == key {
+			return strings.TrimSpace(kv[1])
+		}
+	}
+	return ""
+}
+
 // parseFieldTag 解析字段的 rdb tag
-func (b *TableModelBuilder) parseFieldTag(field reflect.StructField, tag string) (FieldDefinition, bool, []IndexDefinition, error) {
+func (b *TableModelBuilder) parseFieldTag(field reflect.StructField, tag string) (FieldDefinition, bool, bool, []IndexDefinition, error) {
 	fieldDef := FieldDefinition{
 		Name: field.Name, // 默认使用字段名
 		Type: b.inferFieldType(field.Type),
 	}
 
-	var isPrimary bool
+	var isPrimary, isVersion bool
 	var indexes []IndexDefinition
 
 	if tag == "" {
-		return fieldDef, isPrimary, indexes, nil
+		return fieldDef, isPrimary, isVersion, indexes, nil
 	}
 
 	// 解析 tag 参数
@@ -193,6 +316,12 @@ func (b *TableModelBuilder) parseFieldTag(field reflect.StructField, tag string)
 					Name:   value,
 					Unique: true,
 				})
+			case "fulltext":
+				// 指定全文索引名，同名的字段组成一个全文索引
+				indexes = append(indexes, IndexDefinition{
+					Name:     value,
+					FullText: true,
+				})
 			}
 		} else {
 			// 布尔参数
@@ -201,6 +330,8 @@ func (b *TableModelBuilder) parseFieldTag(field reflect.StructField, tag string)
 				fieldDef.Required = true
 			case "primary", "pk":
 				isPrimary = true
+			case "version":
+				isVersion = true
 			case "index":
 				// 创建默认索引名
 				indexName := fmt.Sprintf("idx_%s", fieldDef.Name)
@@ -215,11 +346,16 @@ func (b *TableModelBuilder) parseFieldTag(field reflect.StructField, tag string)
 					Name:   indexName,
 					Unique: true,
 				})
+			case "fulltext":
+				// 默认全文索引名为 ft_<表名>，表中所有 fulltext 字段组成一个全文索引
+				indexes = append(indexes, IndexDefinition{
+					FullText: true,
+				})
 			}
 		}
 	}
 
-	return fieldDef, isPrimary, indexes, nil
+	return fieldDef, isPrimary, isVersion, indexes, nil
 }
 
 // inferFieldType 从 Go 类型推断字段类型
@@ -244,6 +380,16 @@ func (b *TableModelBuilder) inferFieldType(t reflect.Type) FieldType {
 		if t.String() == "time.Time" {
 			return FieldTypeDate
 		}
+		// uuid.UUID 以字符串形式存储
+		if t == reflect.TypeOf(uuid.UUID{}) {
+			return FieldTypeString
+		}
+		if t == reflect.TypeOf(Blob{}) {
+			return FieldTypeBlob
+		}
+		if t == reflect.TypeOf(query.GeoPoint{}) {
+			return FieldTypeGeoPoint
+		}
 		// 其他复杂类型默认为 JSON
 		return FieldTypeJSON
 	}
@@ -277,3 +423,14 @@ func (b *TableModelBuilder) parseDefaultValue(value string, fieldType FieldType)
 		return value
 	}
 }
+
+// geoPointFields 返回表模型中的地理位置字段
+func geoPointFields(model *TableModel) []string {
+	var fields []string
+	for _, field := range model.Fields {
+		if field.Type == FieldTypeGeoPoint {
+			fields = append(fields, field.Name)
+		}
+	}
+	return fields
+}
@@ -1,8 +1,11 @@
 package database
 
 import (
+	"reflect"
 	"testing"
 	"time"
+
+	"github.com/hatlonely/gox/rdb/query"
 )
 
 // 测试用的结构体
@@ -320,6 +323,7 @@ func TestTableModelBuilder_FieldTypeInference(t *testing.T) {
 		TimeField   time.Time
 		PtrField    *string
 		SliceField  []string
+		GeoField    query.GeoPoint
 	}
 
 	test := TestStruct{}
@@ -345,6 +349,7 @@ func TestTableModelBuilder_FieldTypeInference(t *testing.T) {
 		{"TimeField", FieldTypeDate},
 		{"PtrField", FieldTypeString},
 		{"SliceField", FieldTypeJSON},
+		{"GeoField", FieldTypeGeoPoint},
 	}
 
 	for _, test := range tests {
@@ -377,3 +382,54 @@ func TestTableModelBuilder_ErrorCases(t *testing.T) {
 		}
 	})
 }
+
+func TestTableModelBuilder_Version(t *testing.T) {
+	builder := NewTableModelBuilder()
+
+	type Versioned struct {
+		ID      int    `rdb:"id,primary"`
+		Name    string `rdb:"name"`
+		Version int    `rdb:"version,version"`
+	}
+	model, err := builder.FromStruct(Versioned{})
+	if err != nil {
+		t.Fatalf("FromStruct failed: %v", err)
+	}
+	if model.Version != "version" {
+		t.Errorf("Expected version field 'version', got '%s'", model.Version)
+	}
+
+	type MultipleVersions struct {
+		ID int `rdb:"id,primary,version"`
+		V  int `rdb:"v,version"`
+	}
+	if _, err := builder.FromStruct(MultipleVersions{}); err == nil {
+		t.Error("Expected error for multiple version fields")
+	}
+}
+
+func TestTableModelBuilder_FullText(t *testing.T) {
+	builder := NewTableModelBuilder()
+
+	type Article struct {
+		ID      int    `rdb:"id,primary"`
+		Title   string `rdb:"title,fulltext"`
+		Body    string `rdb:"body,fulltext"`
+		Summary string `rdb:"summary,fulltext=ft_summary"`
+	}
+	model, err := builder.FromStruct(Article{})
+	if err != nil {
+		t.Fatalf("FromStruct failed: %v", err)
+	}
+
+	indexes := map[string]IndexDefinition{}
+	for _, index := range model.Indexes {
+		indexes[index.Name] = index
+	}
+	if index := indexes["ft_Article"]; !index.FullText || !reflect.DeepEqual(index.Fields, []string{"title", "body"}) {
+		t.Errorf("Expected default fulltext index on title and body, got %+v", index)
+	}
+	if index := indexes["ft_summary"]; !index.FullText || !reflect.DeepEqual(index.Fields, []string{"summary"}) {
+		t.Errorf("Expected fulltext index ft_summary, got %+v", index)
+	}
+}
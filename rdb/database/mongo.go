@@ -4,7 +4,9 @@ import (
 	"context"
 	"fmt"
 	"reflect"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
@@ -13,6 +15,7 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.mongodb.org/mongo-driver/mongo/readpref"
 
+	"github.com/hatlonely/gox/cfg/commonopt"
 	"github.com/hatlonely/gox/rdb/aggregation"
 	"github.com/hatlonely/gox/rdb/query"
 )
@@ -29,14 +32,62 @@ type MongoOptions struct {
 	Timeout    time.Duration `cfg:"timeout" def:"30s"`
 	MaxPoolSize uint64       `cfg:"maxPoolSize" def:"100"`
 	MinPoolSize uint64       `cfg:"minPoolSize" def:"0"`
+
+	// MaxIdleTime 连接空闲超过该时间后从连接池中移除，为 0 时使用 URI 中的 maxIdleTimeMS，默认不移除
+	MaxIdleTime time.Duration `cfg:"maxIdleTime"`
+	// HeartbeatInterval 监控各节点状态的心跳间隔，为 0 时使用 URI 中的 heartbeatFrequencyMS，默认为 10s
+	HeartbeatInterval time.Duration `cfg:"heartbeatInterval"`
+	// Compressors 与服务端协商的网络压缩算法，按优先级排列：snappy、zlib、zstd，为空时使用 URI 中的 compressors，默认不压缩
+	Compressors []string `cfg:"compressors"`
+
+	// TLS 配置后使用 TLS 连接，也可以在 URI 中通过 tls=true 开启
+	TLS *commonopt.TLSOptions `cfg:"tls"`
+
+	// Retry 启动时连接失败的重试策略
+	Retry RetryOptions `cfg:"retry"`
+	// HealthCheckInterval 定期 Ping 的间隔，为 0 时不做定期检查
+	// 检查失败时会重建客户端，从客户端持续不可用的状态中自动恢复
+	HealthCheckInterval time.Duration `cfg:"healthCheckInterval"`
+
+	// TagName 结构体字段与文档字段对应使用的标签，如 bson，指定的标签不存在时使用 bson 标签
+	TagName string `cfg:"tagName" def:"rdb"`
+
+	// ReadPreference 读操作的读偏好：primary、primaryPreferred、secondary、secondaryPreferred、nearest
+	// 为空时使用 URI 中的配置，默认为 primary；nearest 选择延迟最低的节点。写操作始终使用主节点
+	ReadPreference string `cfg:"readPreference"`
+
+	// AllowMigrations 是否允许 Migrate 和 MigrateDiff 创建集合和索引，为空时允许
+	// 生产环境配置为 false，索引变更只能通过 ApplyMigrations 执行，开发和测试环境保持为空以自动创建
+	AllowMigrations *bool `cfg:"allowMigrations"`
+
+	// CloseTimeout Close 等待进行中的操作和事务完成的最长时间，超时后直接关闭连接，为 0 时不等待
+	CloseTimeout time.Duration `cfg:"closeTimeout" def:"30s"`
+	// TxLeak 事务泄漏检测，开启后超过阈值仍未提交或回滚的事务输出告警日志和开启事务的调用栈
+	TxLeak *TxLeakOptions `cfg:"txLeak"`
+
+	// Metrics 连接池指标，配置后在 prometheus.DefaultRegisterer 中注册 rdb_pool_* 指标，Close 时注销
+	Metrics *MetricsOptions `cfg:"metrics"`
 }
 
 // Mongo MongoDB数据库实现
 type Mongo struct {
+	mu       sync.RWMutex
 	client   *mongo.Client
 	database *mongo.Database
-	builder  *MongoRecordBuilder
-	dbName   string
+
+	builder       *MongoRecordBuilder
+	dbName        string
+	clientOptions *options.ClientOptions
+	timeout       time.Duration
+	checker       *healthChecker
+
+	ops          *operationTracker
+	closeTimeout time.Duration
+	txLeaks      *txLeakDetector
+	pool         *mongoPoolMonitor
+	unregister   func()
+	// allowMigrations 为空时允许迁移
+	allowMigrations *bool
 }
 
 // NewMongoWithOptions 创建MongoDB实例
@@ -52,12 +103,86 @@ func NewMongoWithOptions(opts *MongoOptions) (*Mongo, error) {
 		}
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), opts.Timeout)
-	defer cancel()
-
 	clientOptions := options.Client().ApplyURI(uri)
 	clientOptions.SetMaxPoolSize(opts.MaxPoolSize)
 	clientOptions.SetMinPoolSize(opts.MinPoolSize)
+	pool := &mongoPoolMonitor{}
+	clientOptions.SetPoolMonitor(pool.monitor())
+	if opts.MaxIdleTime > 0 {
+		clientOptions.SetMaxConnIdleTime(opts.MaxIdleTime)
+	}
+	if opts.HeartbeatInterval > 0 {
+		clientOptions.SetHeartbeatInterval(opts.HeartbeatInterval)
+	}
+	if len(opts.Compressors) > 0 {
+		for _, compressor := range opts.Compressors {
+			if compressor != "snappy" && compressor != "zlib" && compressor != "zstd" {
+				return nil, fmt.Errorf("unsupported compressor: %s", compressor)
+			}
+		}
+		clientOptions.SetCompressors(opts.Compressors)
+	}
+	if opts.TLS != nil {
+		tlsConfig, err := commonopt.NewTLSConfigWithOptions(opts.TLS)
+		if err != nil {
+			return nil, err
+		}
+		clientOptions.SetTLSConfig(tlsConfig)
+	}
+	if opts.ReadPreference != "" {
+		mode, err := readpref.ModeFromString(opts.ReadPreference)
+		if err != nil {
+			return nil, fmt.Errorf("invalid read preference: %v", err)
+		}
+		rp, err := readpref.New(mode)
+		if err != nil {
+			return nil, fmt.Errorf("invalid read preference: %v", err)
+		}
+		clientOptions.SetReadPreference(rp)
+	}
+
+	txLeaks, err := newTxLeakDetector(opts.TxLeak)
+	if err != nil {
+		return nil, err
+	}
+
+	var client *mongo.Client
+	err = retryConnect(opts.Retry, func() error {
+		var err error
+		client, err = connectMongo(clientOptions, opts.Timeout)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Mongo{
+		client:        client,
+		database:      client.Database(opts.Database),
+		builder:       NewMongoRecordBuilder(opts.TagName),
+		dbName:        opts.Database,
+		clientOptions: clientOptions,
+		timeout:       opts.Timeout,
+		ops:           newOperationTracker(),
+		closeTimeout:  opts.CloseTimeout,
+		txLeaks:       txLeaks,
+		pool:          pool,
+
+		allowMigrations: opts.AllowMigrations,
+	}
+	if m.unregister, err = registerPoolMetrics(opts.Metrics, m); err != nil {
+		client.Disconnect(context.Background())
+		return nil, err
+	}
+	m.checker = startHealthChecker(opts.HealthCheckInterval, m.Health, m.reconnect)
+
+	return m, nil
+}
+
+// connectMongo 创建客户端并测试连接，连接失败时释放客户端
+func connectMongo(clientOptions *options.ClientOptions, timeout time.Duration) (*mongo.Client, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
 
 	client, err := mongo.Connect(ctx, clientOptions)
 	if err != nil {
@@ -66,26 +191,78 @@ func NewMongoWithOptions(opts *MongoOptions) (*Mongo, error) {
 
 	// 测试连接
 	if err := client.Ping(ctx, readpref.Primary()); err != nil {
+		client.Disconnect(ctx)
 		return nil, fmt.Errorf("failed to ping mongodb: %v", err)
 	}
 
-	database := client.Database(opts.Database)
+	return client, nil
+}
 
-	return &Mongo{
-		client:   client,
-		database: database,
-		builder:  &MongoRecordBuilder{},
-		dbName:   opts.Database,
-	}, nil
+// reconnect 重建客户端，新客户端连接成功后才替换旧客户端
+// 替换前已经发起的操作和事务仍使用旧客户端，会随旧客户端断开而失败
+func (m *Mongo) reconnect(ctx context.Context) {
+	client, err := connectMongo(m.clientOptions, m.timeout)
+	if err != nil {
+		return
+	}
+
+	m.mu.Lock()
+	old := m.client
+	m.client = client
+	m.database = client.Database(m.dbName)
+	m.mu.Unlock()
+
+	old.Disconnect(ctx)
+}
+
+func (m *Mongo) getClient() *mongo.Client {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.client
+}
+
+func (m *Mongo) getDatabase() *mongo.Database {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.database
+}
+
+// readCollection 返回读操作使用的集合，context 要求读主库时使用 primary 读偏好
+func (m *Mongo) readCollection(ctx context.Context, table string) *mongo.Collection {
+	if isPrimaryRead(ctx) {
+		return m.getDatabase().Collection(table, options.Collection().SetReadPreference(readpref.Primary()))
+	}
+	return m.getDatabase().Collection(table)
 }
 
 // MongoRecord MongoDB记录实现
 type MongoRecord struct {
-	data bson.M
+	data    bson.M
+	tagName string
+	score   *float64
+}
+
+// Score 返回相关度评分，实现 Scorer 接口
+func (r *MongoRecord) Score() (float64, bool) {
+	if r.score == nil {
+		return 0, false
+	}
+	return *r.score, true
+}
+
+// takeScore 从文档中取出 textScore 投影的相关度评分
+func (r *MongoRecord) takeScore() *MongoRecord {
+	if value, ok := r.data[scoreColumn]; ok {
+		delete(r.data, scoreColumn)
+		if score, ok := toFloat64(value); ok {
+			r.score = &score
+		}
+	}
+	return r
 }
 
 func (r *MongoRecord) Scan(dest any) error {
-	return bsonToStruct(r.data, dest)
+	return bsonToStruct(r.data, dest, r.tagName)
 }
 
 func (r *MongoRecord) ScanStruct(dest any) error {
@@ -100,12 +277,33 @@ func (r *MongoRecord) Fields() map[string]any {
 	return result
 }
 
-// MongoRecordBuilder MongoDB记录构建器
-type MongoRecordBuilder struct{}
+func (r *MongoRecord) Diff(other Record) map[string]any {
+	return diffFields(r.data, other)
+}
+
+func (r *MongoRecord) withFields(fields map[string]any) Record {
+	return &MongoRecord{data: bson.M(fields), tagName: r.tagName, score: r.score}
+}
+
+// MongoRecordBuilder MongoDB记录构建器，零值使用 rdb 标签
+type MongoRecordBuilder struct {
+	tagName string
+}
+
+// NewMongoRecordBuilder 创建使用 tagName 标签对应结构体字段和文档字段的构建器，为空时使用 rdb
+// 指定的标签不存在时回退到 bson 标签，构建的记录以及查询返回的记录 Scan 时使用同一个标签
+func NewMongoRecordBuilder(tagName string) *MongoRecordBuilder {
+	return &MongoRecordBuilder{tagName: tagName}
+}
+
+// TagName 实现 TagNamer 接口
+func (b *MongoRecordBuilder) TagName() string {
+	return tagNameOrDefault(b.tagName)
+}
 
 func (b *MongoRecordBuilder) FromStruct(v any) Record {
-	data := structToBSON(v)
-	return &MongoRecord{data: data}
+	data := structToBSON(v, b.tagName)
+	return b.newRecord(data)
 }
 
 func (b *MongoRecordBuilder) FromMap(data map[string]any, table string) Record {
@@ -113,11 +311,17 @@ func (b *MongoRecordBuilder) FromMap(data map[string]any, table string) Record {
 	for k, v := range data {
 		bsonData[k] = v
 	}
-	return &MongoRecord{data: bsonData}
+	return b.newRecord(bsonData)
+}
+
+// newRecord 创建 Scan 时使用构建器标签的记录
+func (b *MongoRecordBuilder) newRecord(data bson.M) *MongoRecord {
+	return &MongoRecord{data: data, tagName: b.tagName}
 }
 
-// 辅助函数：结构体转换为BSON
-func structToBSON(v any) bson.M {
+// 辅助函数：结构体转换为BSON，优先使用 tagName 标签（为空时为 rdb），没有时使用 bson 标签
+func structToBSON(v any, tagName string) bson.M {
+	tagName = tagNameOrDefault(tagName)
 	result := make(bson.M)
 	rv := reflect.ValueOf(v)
 	if rv.Kind() == reflect.Ptr {
@@ -134,12 +338,12 @@ func structToBSON(v any) bson.M {
 			continue
 		}
 
-		// 检查 rdb 或 bson 标签
+		// 检查 tagName 或 bson 标签
 		fieldName := field.Name
 		omitEmpty := false
 		
-		// 优先使用 rdb 标签，但同时检查 bson 标签中的 omitempty
-		if tag := field.Tag.Get("rdb"); tag != "" && tag != "-" {
+		// 优先使用 tagName 标签，但同时检查 bson 标签中的 omitempty
+		if tag := field.Tag.Get(tagName); tag != "" && tag != "-" {
 			parts := strings.Split(tag, ",")
 			fieldName = parts[0]
 			for _, part := range parts[1:] {
@@ -157,7 +361,7 @@ func structToBSON(v any) bson.M {
 			}
 		}
 		
-		// 如果 rdb 标签没有 omitempty，检查 bson 标签是否有
+		// 如果 tagName 标签没有 omitempty，检查 bson 标签是否有
 		if !omitEmpty {
 			if bsonTag := field.Tag.Get("bson"); bsonTag != "" {
 				parts := strings.Split(bsonTag, ",")
@@ -189,8 +393,9 @@ func structToBSON(v any) bson.M {
 	return result
 }
 
-// 辅助函数：BSON转换为结构体
-func bsonToStruct(data bson.M, dest any) error {
+// 辅助函数：BSON转换为结构体，字段名的规则与 structToBSON 相同
+func bsonToStruct(data bson.M, dest any, tagName string) error {
+	tagName = tagNameOrDefault(tagName)
 	rv := reflect.ValueOf(dest)
 	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
 		return fmt.Errorf("dest must be a pointer to struct")
@@ -206,7 +411,7 @@ func bsonToStruct(data bson.M, dest any) error {
 		}
 
 		fieldName := field.Name
-		if tag := field.Tag.Get("rdb"); tag != "" && tag != "-" {
+		if tag := field.Tag.Get(tagName); tag != "" && tag != "-" {
 			if idx := strings.Index(tag, ","); idx != -1 {
 				fieldName = tag[:idx]
 			} else {
@@ -273,41 +478,58 @@ func (m *Mongo) GetBuilder() RecordBuilder {
 	return m.builder
 }
 
+// Health 通过 Ping 主节点检查连接是否可用
+func (m *Mongo) Health(ctx context.Context) error {
+	ctx, done, err := m.ops.enter(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	return m.getClient().Ping(ctx, readpref.Primary())
+}
+
+// Close 拒绝新的操作，等待进行中的操作和事务完成后断开连接
+// 等待超过 CloseTimeout 时直接断开，返回 ErrCloseTimeout 并给出被中断的操作数
 func (m *Mongo) Close() error {
-	if m.client != nil {
+	m.checker.stop()
+	m.unregister()
+	aborted := m.ops.close(m.closeTimeout)
+	if client := m.getClient(); client != nil {
 		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 		defer cancel()
-		return m.client.Disconnect(ctx)
+		return closeError(aborted, client.Disconnect(ctx))
 	}
-	return nil
+	return closeError(aborted, nil)
+}
+
+// PoolStats 连接池统计，由连接池事件累计
+func (m *Mongo) PoolStats() PoolStats {
+	return m.pool.stats()
 }
 
 // Migrate 创建/更新集合
 func (m *Mongo) Migrate(ctx context.Context, model *TableModel) error {
-	collection := m.database.Collection(model.Table)
+	if err := checkMigrationsAllowed(ctx, m.allowMigrations, model.Table); err != nil {
+		return err
+	}
+	ctx, done, err := m.ops.enter(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	collection := m.getDatabase().Collection(model.Table)
 
 	// MongoDB中表相当于集合，会在第一次写入时自动创建
 	// 这里主要是创建索引
 	for _, index := range model.Indexes {
-		keys := bson.D{}
-		for _, field := range index.Fields {
-			keys = append(keys, bson.E{Key: field, Value: 1})
-		}
-		
-		indexModel := mongo.IndexModel{
-			Keys: keys,
-		}
-
-		// 设置索引选项
-		indexOptions := options.Index()
-		if index.Unique {
-			indexOptions.SetUnique(true)
-		}
-		indexOptions.SetName(index.Name)
-		indexModel.Options = indexOptions
-
 		// 创建索引
-		_, err := collection.Indexes().CreateOne(ctx, indexModel)
+		indexModel, err := buildMongoIndexModel(index)
+		if err != nil {
+			return err
+		}
+		_, err = collection.Indexes().CreateOne(ctx, indexModel)
 		if err != nil {
 			// 如果索引已存在，忽略错误
 			if !strings.Contains(err.Error(), "already exists") {
@@ -316,24 +538,275 @@ func (m *Mongo) Migrate(ctx context.Context, model *TableModel) error {
 		}
 	}
 
+	// 地理位置字段创建 2dsphere 索引
+	for _, field := range geoPointFields(model) {
+		if _, err := collection.Indexes().CreateOne(ctx, buildMongoGeoIndexModel(field)); err != nil {
+			if !strings.Contains(err.Error(), "already exists") {
+				return fmt.Errorf("failed to create index %s: %v", mongoGeoIndexName(field), err)
+			}
+		}
+	}
+
+	return migrateMongoViews(ctx, m.getDatabase(), model)
+}
+
+// migrateMongoViews 创建或替换基于集合的视图，已存在的视图通过 collMod 更新聚合管道
+func migrateMongoViews(ctx context.Context, database *mongo.Database, model *TableModel) error {
+	for _, view := range model.Views {
+		if view.Pipeline == nil {
+			return fmt.Errorf("view %s has no pipeline definition", view.Name)
+		}
+
+		specs, err := database.ListCollectionSpecifications(ctx, bson.M{"name": view.Name})
+		if err != nil {
+			return fmt.Errorf("failed to list collections: %v", err)
+		}
+		if len(specs) == 0 {
+			if err := database.CreateView(ctx, view.Name, model.Table, view.Pipeline); err != nil {
+				return fmt.Errorf("failed to create view %s: %v", view.Name, err)
+			}
+			continue
+		}
+		if specs[0].Type != "view" {
+			return fmt.Errorf("failed to create view %s: collection already exists", view.Name)
+		}
+		command := bson.D{{Key: "collMod", Value: view.Name}, {Key: "viewOn", Value: model.Table}, {Key: "pipeline", Value: view.Pipeline}}
+		if err := database.RunCommand(ctx, command).Err(); err != nil {
+			return fmt.Errorf("failed to replace view %s: %v", view.Name, err)
+		}
+	}
 	return nil
 }
 
+// buildMongoIndexModel 将索引定义转换为 Mongo 索引模型
+func buildMongoIndexModel(index IndexDefinition) (mongo.IndexModel, error) {
+	if err := index.validate(); err != nil {
+		return mongo.IndexModel{}, err
+	}
+
+	keys := bson.D{}
+	for _, field := range index.Fields {
+		keys = append(keys, bson.E{Key: field, Value: mongoIndexKind(index)})
+	}
+
+	// 设置索引选项
+	indexOptions := options.Index()
+	if index.Unique {
+		indexOptions.SetUnique(true)
+	}
+	if index.Kind == IndexKindTTL {
+		indexOptions.SetExpireAfterSeconds(int32(index.ExpireAfter / time.Second))
+	}
+	if index.Sparse {
+		indexOptions.SetSparse(true)
+	}
+	if index.Expression != nil {
+		filter, err := index.Expression.ToMongo()
+		if err != nil {
+			return mongo.IndexModel{}, fmt.Errorf("failed to build index expression %s: %v", index.Name, err)
+		}
+		indexOptions.SetPartialFilterExpression(filter)
+	}
+	indexOptions.SetName(index.Name)
+
+	return mongo.IndexModel{Keys: keys, Options: indexOptions}, nil
+}
+
+// mongoIndexKind 索引键的类型，全文索引为 text，每个集合只能有一个 text 索引；地理位置索引为 2dsphere
+func mongoIndexKind(index IndexDefinition) any {
+	if index.fullText() {
+		return "text"
+	}
+	if index.Kind == IndexKindGeo {
+		return "2dsphere"
+	}
+	return 1
+}
+
+// mongoIndexStatement 创建索引等价的 mongo shell 命令
+func mongoIndexStatement(table string, index IndexDefinition) (string, error) {
+	indexModel, err := buildMongoIndexModel(index)
+	if err != nil {
+		return "", err
+	}
+	keys := make([]string, len(index.Fields))
+	for i, field := range index.Fields {
+		switch kind := mongoIndexKind(index).(type) {
+		case string:
+			keys[i] = fmt.Sprintf("%q: %q", field, kind)
+		default:
+			keys[i] = fmt.Sprintf("%q: %v", field, kind)
+		}
+	}
+	opts := fmt.Sprintf("\"name\": %q, \"unique\": %t", index.Name, index.Unique)
+	indexOptions := indexModel.Options
+	if indexOptions.ExpireAfterSeconds != nil {
+		opts += fmt.Sprintf(", \"expireAfterSeconds\": %d", *indexOptions.ExpireAfterSeconds)
+	}
+	if indexOptions.Sparse != nil {
+		opts += ", \"sparse\": true"
+	}
+	if indexOptions.PartialFilterExpression != nil {
+		filter, err := mongoShellArgs(indexOptions.PartialFilterExpression)
+		if err != nil {
+			return "", err
+		}
+		opts += fmt.Sprintf(", \"partialFilterExpression\": %s", filter)
+	}
+	return fmt.Sprintf("db.%s.createIndex({%s}, {%s})", table, strings.Join(keys, ", "), opts), nil
+}
+
+// mongoGeoIndexName 地理位置字段的 2dsphere 索引名
+func mongoGeoIndexName(field string) string {
+	return field + "_2dsphere"
+}
+
+// buildMongoGeoIndexModel 地理位置字段的 2dsphere 索引模型
+func buildMongoGeoIndexModel(field string) mongo.IndexModel {
+	return mongo.IndexModel{
+		Keys:    bson.D{{Key: field, Value: "2dsphere"}},
+		Options: options.Index().SetName(mongoGeoIndexName(field)),
+	}
+}
+
+// MigrateDiff 对比现有集合增量迁移
+// Mongo 没有固定的列结构，只补齐缺失的集合和索引，返回的语句为等价的 mongo shell 命令
+func (m *Mongo) MigrateDiff(ctx context.Context, model *TableModel, opts ...MigrateOption) ([]string, error) {
+	ctx, done, err := m.ops.enter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	migrateOpts := &MigrateOptions{}
+	for _, opt := range opts {
+		opt(migrateOpts)
+	}
+	if !migrateOpts.DryRun {
+		if err := checkMigrationsAllowed(ctx, m.allowMigrations, model.Table); err != nil {
+			return nil, err
+		}
+	}
+
+	names, err := m.getDatabase().ListCollectionNames(ctx, bson.M{"name": model.Table})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list collections: %v", err)
+	}
+	collectionExists := len(names) > 0
+
+	collection := m.getDatabase().Collection(model.Table)
+	existingIndexes := make(map[string]bool)
+	if collectionExists {
+		specs, err := collection.Indexes().ListSpecifications(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list indexes: %v", err)
+		}
+		for _, spec := range specs {
+			existingIndexes[spec.Name] = true
+		}
+	}
+
+	var statements []string
+	var missingIndexes []IndexDefinition
+	if !collectionExists {
+		statements = append(statements, fmt.Sprintf("db.createCollection(%q)", model.Table))
+	}
+	for _, index := range model.Indexes {
+		if existingIndexes[index.Name] {
+			continue
+		}
+		statement, err := mongoIndexStatement(model.Table, index)
+		if err != nil {
+			return nil, err
+		}
+		statements = append(statements, statement)
+		missingIndexes = append(missingIndexes, index)
+	}
+	var missingGeoFields []string
+	for _, field := range geoPointFields(model) {
+		if existingIndexes[mongoGeoIndexName(field)] {
+			continue
+		}
+		statements = append(statements, fmt.Sprintf("db.%s.createIndex({%q: \"2dsphere\"}, {\"name\": %q})",
+			model.Table, field, mongoGeoIndexName(field)))
+		missingGeoFields = append(missingGeoFields, field)
+	}
+
+	if migrateOpts.DryRun {
+		return statements, nil
+	}
+	if !collectionExists {
+		if err := m.getDatabase().CreateCollection(ctx, model.Table); err != nil {
+			return nil, fmt.Errorf("failed to create collection %s: %v", model.Table, err)
+		}
+	}
+	for _, index := range missingIndexes {
+		indexModel, err := buildMongoIndexModel(index)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := collection.Indexes().CreateOne(ctx, indexModel); err != nil {
+			return nil, fmt.Errorf("failed to create index %s: %v", index.Name, err)
+		}
+	}
+	for _, field := range missingGeoFields {
+		if _, err := collection.Indexes().CreateOne(ctx, buildMongoGeoIndexModel(field)); err != nil {
+			return nil, fmt.Errorf("failed to create index %s: %v", mongoGeoIndexName(field), err)
+		}
+	}
+
+	return statements, nil
+}
+
 // DropTable 删除集合
 func (m *Mongo) DropTable(ctx context.Context, table string) error {
-	collection := m.database.Collection(table)
+	ctx, done, err := m.ops.enter(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	collection := m.getDatabase().Collection(table)
 	return collection.Drop(ctx)
 }
 
+// Stats 获取集合统计信息，基于 collStats 命令
+func (m *Mongo) Stats(ctx context.Context, table string) (*TableStats, error) {
+	ctx, done, err := m.ops.enter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	var result bson.M
+	if err := m.getDatabase().RunCommand(ctx, bson.D{{Key: "collStats", Value: table}}).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to get collection stats: %v", err)
+	}
+
+	return &TableStats{
+		RowCount:  toInt64(result["count"]),
+		DataSize:  toInt64(result["size"]),
+		IndexSize: toInt64(result["totalIndexSize"]),
+	}, nil
+}
+
 // CRUD 操作实现
 func (m *Mongo) Create(ctx context.Context, table string, record Record, opts ...CreateOption) error {
+	ctx, done, err := m.ops.enter(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+	ctx, cancel := createContext(ctx, opts)
+	defer cancel()
+
 	// 解析创建选项
 	createOpts := &CreateOptions{}
 	for _, opt := range opts {
 		opt(createOpts)
 	}
 
-	collection := m.database.Collection(table)
+	collection := m.getDatabase().Collection(table)
 	fields := record.Fields()
 
 	// 处理主键：MongoDB使用_id作为主键，如果没有则自动生成
@@ -355,11 +828,7 @@ func (m *Mongo) Create(ctx context.Context, table string, record Record, opts ..
 		}
 		return err
 	} else if createOpts.UpdateOnConflict {
-		// 使用ReplaceOne with upsert选项在冲突时更新
-		filter := bson.M{"_id": doc["_id"]}
-		replaceOptions := options.Replace().SetUpsert(true)
-		_, err := collection.ReplaceOne(ctx, filter, doc, replaceOptions)
-		return err
+		return upsertMongo(ctx, collection, record, doc)
 	} else {
 		// 默认的插入操作
 		_, err := collection.InsertOne(ctx, doc)
@@ -370,8 +839,16 @@ func (m *Mongo) Create(ctx context.Context, table string, record Record, opts ..
 	}
 }
 
-func (m *Mongo) Get(ctx context.Context, table string, pk map[string]any) (Record, error) {
-	collection := m.database.Collection(table)
+func (m *Mongo) Get(ctx context.Context, table string, pk map[string]any, opts ...QueryOption) (Record, error) {
+	ctx, done, err := m.ops.enter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	ctx, cancel := queryContext(ctx, opts)
+	defer cancel()
+
+	collection := m.readCollection(ctx, table)
 
 	// 构建查询过滤器
 	filter := make(bson.M)
@@ -380,7 +857,7 @@ func (m *Mongo) Get(ctx context.Context, table string, pk map[string]any) (Recor
 	}
 
 	var result bson.M
-	err := collection.FindOne(ctx, filter).Decode(&result)
+	err = collection.FindOne(ctx, filter, mongoFindOneOptions(opts)).Decode(&result)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
 			return nil, ErrRecordNotFound
@@ -388,36 +865,170 @@ func (m *Mongo) Get(ctx context.Context, table string, pk map[string]any) (Recor
 		return nil, err
 	}
 
-	return &MongoRecord{data: result}, nil
+	return m.builder.newRecord(result), nil
 }
 
-func (m *Mongo) Update(ctx context.Context, table string, pk map[string]any, record Record) error {
-	collection := m.database.Collection(table)
+func (m *Mongo) Update(ctx context.Context, table string, pk map[string]any, record Record, opts ...UpdateOption) error {
+	ctx, done, err := m.ops.enter(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+	ctx, cancel := updateContext(ctx, opts)
+	defer cancel()
 
-	// 构建查询过滤器
-	filter := make(bson.M)
+	fields, options := updateFields(record), newUpdateOptions(opts)
+	if len(fields) == 0 && !options.versioned() {
+		return nil
+	}
+	return setMongo(ctx, m.getDatabase().Collection(table), pk, fields, options)
+}
+
+// UpdatePartial 使用 $set 只更新指定字段
+func (m *Mongo) UpdatePartial(ctx context.Context, table string, pk map[string]any, fields map[string]any, opts ...UpdateOption) error {
+	ctx, done, err := m.ops.enter(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+	ctx, cancel := updateContext(ctx, opts)
+	defer cancel()
+
+	options := newUpdateOptions(opts)
+	if len(fields) == 0 && !options.versioned() {
+		return fmt.Errorf("no fields to update")
+	}
+	return setMongo(ctx, m.getDatabase().Collection(table), pk, fields, options)
+}
+
+// Upsert 使用 ReplaceOne 的 upsert 选项按主键插入或整体替换文档
+func (m *Mongo) Upsert(ctx context.Context, table string, pk map[string]any, record Record) error {
+	ctx, done, err := m.ops.enter(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	fields, err := upsertFields(pk, record)
+	if err != nil {
+		return err
+	}
+	return replaceMongo(ctx, m.getDatabase().Collection(table), pk, fields)
+}
+
+// Increment 使用 $inc 原子增减
+func (m *Mongo) Increment(ctx context.Context, table string, pk map[string]any, field string, delta any) error {
+	ctx, done, err := m.ops.enter(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	if err := validateIncrementDelta(delta); err != nil {
+		return err
+	}
+	return updateMongo(ctx, m.getDatabase().Collection(table), pk, bson.M{"$inc": bson.M{field: delta}})
+}
+
+// replaceMongo 根据主键替换文档，文档不存在时插入，pk 中没有 _id 时由 MongoDB 生成
+func replaceMongo(ctx context.Context, collection *mongo.Collection, pk map[string]any, fields map[string]any) error {
+	filter := make(bson.M, len(pk))
 	for k, v := range pk {
 		filter[k] = v
 	}
+	doc := make(bson.M, len(fields))
+	for k, v := range fields {
+		doc[k] = v
+	}
+	_, err := collection.ReplaceOne(ctx, filter, doc, options.Replace().SetUpsert(true))
+	return err
+}
 
-	// 构建更新文档
-	fields := record.Fields()
-	update := bson.M{"$set": fields}
+// setMongo 使用 $set 根据主键更新字段
+// 启用乐观锁时在过滤条件中加入期望的版本，并通过 $inc 将版本加 1
+// upsertMongo 冲突时更新，普通记录整体替换；DirtyRecord 只更新修改过的字段，其他字段仅在插入时写入
+func upsertMongo(ctx context.Context, collection *mongo.Collection, record Record, doc bson.M) error {
+	filter := bson.M{"_id": doc["_id"]}
+	dirty, ok := record.(DirtyRecord)
+	if !ok {
+		// 使用ReplaceOne with upsert选项在冲突时更新
+		_, err := collection.ReplaceOne(ctx, filter, doc, options.Replace().SetUpsert(true))
+		return err
+	}
 
-	result, err := collection.UpdateOne(ctx, filter, update)
+	dirtyFields := dirty.DirtyFields()
+	set, setOnInsert := bson.M{}, bson.M{"_id": doc["_id"]}
+	for k, v := range doc {
+		if _, ok := dirtyFields[k]; ok && k != "_id" {
+			set[k] = v
+		} else {
+			setOnInsert[k] = v
+		}
+	}
+	update := bson.M{"$setOnInsert": setOnInsert}
+	if len(set) > 0 {
+		update["$set"] = set
+	}
+	_, err := collection.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	return err
+}
+
+func setMongo(ctx context.Context, collection *mongo.Collection, pk map[string]any, fields map[string]any, options *UpdateOptions) error {
+	if !options.versioned() {
+		return updateMongo(ctx, collection, pk, bson.M{"$set": fields})
+	}
+
+	update := bson.M{"$inc": bson.M{options.VersionField: 1}}
+	if fields = options.stripVersion(fields); len(fields) > 0 {
+		update["$set"] = fields
+	}
+	filter := make(map[string]any, len(pk)+1)
+	for k, v := range pk {
+		filter[k] = v
+	}
+	filter[options.VersionField] = options.Version
+
+	err := updateMongo(ctx, collection, filter, update)
+	if err != ErrRecordNotFound {
+		return err
+	}
+
+	// 未匹配到文档时区分记录不存在和版本冲突
+	exists, err := existsMongo(ctx, collection, pk)
 	if err != nil {
 		return err
 	}
+	if exists {
+		return ErrVersionConflict
+	}
+	return ErrRecordNotFound
+}
+
+// updateMongo 根据主键更新单个文档，未匹配到文档时返回 ErrRecordNotFound
+func updateMongo(ctx context.Context, collection *mongo.Collection, pk map[string]any, update bson.M) error {
+	filter := make(bson.M)
+	for k, v := range pk {
+		filter[k] = v
+	}
 
+	result, err := collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
 	if result.MatchedCount == 0 {
 		return ErrRecordNotFound
 	}
-
 	return nil
 }
 
 func (m *Mongo) Delete(ctx context.Context, table string, pk map[string]any) error {
-	collection := m.database.Collection(table)
+	ctx, done, err := m.ops.enter(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	collection := m.getDatabase().Collection(table)
 
 	// 构建查询过滤器
 	filter := make(bson.M)
@@ -439,11 +1050,19 @@ func (m *Mongo) Delete(ctx context.Context, table string, pk map[string]any) err
 
 // 批量操作实现
 func (m *Mongo) BatchCreate(ctx context.Context, table string, records []Record, opts ...CreateOption) error {
+	ctx, done, err := m.ops.enter(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+	ctx, cancel := createContext(ctx, opts)
+	defer cancel()
+
 	if len(records) == 0 {
 		return nil
 	}
 
-	collection := m.database.Collection(table)
+	collection := m.getDatabase().Collection(table)
 
 	// 转换为BSON文档数组
 	docs := make([]interface{}, len(records))
@@ -471,7 +1090,7 @@ func (m *Mongo) BatchCreate(ctx context.Context, table string, records []Record,
 		insertOptions.SetOrdered(false) // 允许部分失败
 	}
 
-	_, err := collection.InsertMany(ctx, docs, insertOptions)
+	_, err = collection.InsertMany(ctx, docs, insertOptions)
 	if err != nil && createOpts.IgnoreConflict && strings.Contains(err.Error(), "duplicate key") {
 		// 如果是重复键错误且设置了忽略冲突，则忽略错误
 		return nil
@@ -481,11 +1100,17 @@ func (m *Mongo) BatchCreate(ctx context.Context, table string, records []Record,
 }
 
 func (m *Mongo) BatchUpdate(ctx context.Context, table string, pks []map[string]any, records []Record) error {
+	ctx, done, err := m.ops.enter(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
 	if len(pks) != len(records) {
 		return fmt.Errorf("pks and records length mismatch")
 	}
 
-	collection := m.database.Collection(table)
+	collection := m.getDatabase().Collection(table)
 
 	for i, record := range records {
 		// 构建查询过滤器
@@ -494,227 +1119,705 @@ func (m *Mongo) BatchUpdate(ctx context.Context, table string, pks []map[string]
 			filter[k] = v
 		}
 
-		// 构建更新文档
-		fields := record.Fields()
-		update := bson.M{"$set": fields}
+		// 构建更新文档
+		fields := updateFields(record)
+		if len(fields) == 0 {
+			continue
+		}
+		update := bson.M{"$set": fields}
+
+		_, err := collection.UpdateOne(ctx, filter, update)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m *Mongo) BatchDelete(ctx context.Context, table string, pks []map[string]any) error {
+	ctx, done, err := m.ops.enter(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	if len(pks) == 0 {
+		return nil
+	}
+
+	collection := m.getDatabase().Collection(table)
+
+	// 构建批量删除过滤器
+	var filters []bson.M
+	for _, pk := range pks {
+		filter := make(bson.M)
+		for k, v := range pk {
+			filter[k] = v
+		}
+		filters = append(filters, filter)
+	}
+
+	// 使用$or查询删除多个文档
+	filter := bson.M{"$or": filters}
+	_, err = collection.DeleteMany(ctx, filter)
+	return err
+}
+
+// 查询和聚合功能实现
+func (m *Mongo) Find(ctx context.Context, table string, query query.Query, opts ...QueryOption) ([]Record, error) {
+	ctx, done, err := m.ops.enter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	ctx, cancel := queryContext(ctx, opts)
+	defer cancel()
+
+	// 解析查询选项
+	queryOpts := &QueryOptions{}
+	for _, opt := range opts {
+		opt(queryOpts)
+	}
+
+	collection := m.readCollection(ctx, table)
+
+	// 构建查询过滤器和查找选项
+	filter, findOptions, scoring, err := mongoFindArgs(query, queryOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	// 执行查询
+	cursor, err := collection.Find(ctx, filter, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	// 扫描结果
+	var records []Record
+	for cursor.Next(ctx) {
+		var doc bson.M
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, err
+		}
+		record := m.builder.newRecord(doc)
+		if scoring {
+			record.takeScore()
+		}
+		records = append(records, record)
+	}
+
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+// FindStream 流式查询，基于 Mongo 游标按批拉取文档
+// 游标关闭前算作进行中的操作，Close 会等待游标关闭
+func (m *Mongo) FindStream(ctx context.Context, table string, query query.Query, opts ...QueryOption) (RecordCursor, error) {
+	ctx, done, err := m.ops.enter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cursor, err := streamWithTimeout(ctx, opts, func(ctx context.Context) (RecordCursor, error) {
+		return m.findStream(ctx, table, query, opts...)
+	})
+	if err != nil {
+		done()
+		return nil, err
+	}
+	return &trackedCursor{RecordCursor: cursor, done: done}, nil
+}
+
+func (m *Mongo) findStream(ctx context.Context, table string, query query.Query, opts ...QueryOption) (RecordCursor, error) {
+	return findMongoStream(ctx, m.readCollection(ctx, table), m.builder, query, opts)
+}
+
+func findMongoStream(ctx context.Context, collection *mongo.Collection, builder *MongoRecordBuilder, query query.Query, opts []QueryOption) (RecordCursor, error) {
+	queryOpts := &QueryOptions{}
+	for _, opt := range opts {
+		opt(queryOpts)
+	}
+
+	filter, findOptions, scoring, err := mongoFindArgs(query, queryOpts)
+	if err != nil {
+		return nil, err
+	}
+	if queryOpts.BatchSize > 0 {
+		findOptions.SetBatchSize(int32(queryOpts.BatchSize))
+	}
+
+	cursor, err := collection.Find(ctx, filter, findOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MongoRecordCursor{ctx: ctx, cursor: cursor, builder: builder, scoring: scoring}, nil
+}
+
+// mongoFindArgs 构建查询过滤器和查找选项，游标分页时追加键集条件
+func mongoFindArgs(q query.Query, queryOpts *QueryOptions) (map[string]any, *options.FindOptions, bool, error) {
+	filter, err := queryOpts.keysetQuery(q).ToMongo()
+	if err != nil {
+		return nil, nil, false, fmt.Errorf("failed to convert query to mongo: %v", err)
+	}
+
+	findOptions := options.Find()
+	scoring, err := mongoScoreProjection(queryOpts, q, findOptions)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	if projection := mongoProjection(queryOpts.Fields); projection != nil {
+		if scoring {
+			projection = append(projection, bson.E{Key: scoreColumn, Value: bson.M{"$meta": "textScore"}})
+		}
+		findOptions.SetProjection(projection)
+	}
+	if sort := mongoSort(queryOpts); sort != nil {
+		findOptions.SetSort(sort)
+	}
+	if queryOpts.Limit > 0 {
+		findOptions.SetLimit(int64(queryOpts.Limit))
+	}
+	if queryOpts.Offset > 0 {
+		findOptions.SetSkip(int64(queryOpts.Offset))
+	}
+	if queryOpts.Timeout > 0 {
+		findOptions.SetMaxTime(queryOpts.Timeout)
+	}
+	return filter, findOptions, scoring, nil
+}
+
+// Explain 返回 Find 发送给 Mongo 的查询，以 mongo shell 的语法表示，不执行查询
+func (m *Mongo) Explain(ctx context.Context, table string, query query.Query, opts ...QueryOption) (*ExplainResult, error) {
+	queryOpts := &QueryOptions{}
+	for _, opt := range opts {
+		opt(queryOpts)
+	}
+
+	filter, findOptions, _, err := mongoFindArgs(query, queryOpts)
+	if err != nil {
+		return nil, err
+	}
+	statement, err := mongoShellArgs(filter)
+	if err != nil {
+		return nil, err
+	}
+	if findOptions.Projection != nil {
+		projection, err := mongoShellArgs(findOptions.Projection)
+		if err != nil {
+			return nil, err
+		}
+		statement += ", " + projection
+	}
+	statement = fmt.Sprintf("db.%s.find(%s)", table, statement)
+	if findOptions.Sort != nil {
+		sort, err := mongoShellArgs(findOptions.Sort)
+		if err != nil {
+			return nil, err
+		}
+		statement += fmt.Sprintf(".sort(%s)", sort)
+	}
+	if findOptions.Skip != nil {
+		statement += fmt.Sprintf(".skip(%d)", *findOptions.Skip)
+	}
+	if findOptions.Limit != nil {
+		statement += fmt.Sprintf(".limit(%d)", *findOptions.Limit)
+	}
+	if findOptions.MaxTime != nil {
+		statement += fmt.Sprintf(".maxTimeMS(%d)", findOptions.MaxTime.Milliseconds())
+	}
+	return &ExplainResult{Backend: "mongo", Statement: statement}, nil
+}
+
+// mongoShellArgs 将查询条件、投影、排序序列化为 relaxed Extended JSON，可以直接粘贴到 mongo shell 中执行
+func mongoShellArgs(value any) (string, error) {
+	data, err := bson.MarshalExtJSON(value, false, false)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal mongo shell args: %v", err)
+	}
+	return string(data), nil
+}
+
+// mongoScoreProjection 需要相关度评分时通过 $meta 投影 textScore，只有 $text 查询有评分
+func mongoScoreProjection(queryOpts *QueryOptions, q query.Query, findOptions *options.FindOptions) (bool, error) {
+	scoring, err := queryOpts.scoring()
+	if err != nil || !scoring {
+		return false, err
+	}
+	if len(fullTextQueries(q)) == 0 {
+		return false, ErrScoreUnsupported
+	}
+	findOptions.SetProjection(bson.M{scoreColumn: bson.M{"$meta": "textScore"}})
+	return true, nil
+}
+
+// mongoProjection 返回只包含 fields 的 projection，fields 中没有 _id 时排除 _id，fields 为空时返回 nil
+func mongoProjection(fields []string) bson.D {
+	if len(fields) == 0 {
+		return nil
+	}
+	projection := bson.D{}
+	if !slices.Contains(fields, "_id") {
+		projection = append(projection, bson.E{Key: "_id", Value: 0})
+	}
+	for _, field := range fields {
+		projection = append(projection, bson.E{Key: field, Value: 1})
+	}
+	return projection
+}
+
+// mongoFindOneOptions 构建 Get 的查找选项
+func mongoFindOneOptions(opts []QueryOption) *options.FindOneOptions {
+	queryOpts := newQueryOptions(opts)
+	findOneOptions := options.FindOne()
+	if projection := mongoProjection(queryOpts.Fields); projection != nil {
+		findOneOptions.SetProjection(projection)
+	}
+	if queryOpts.Timeout > 0 {
+		findOneOptions.SetMaxTime(queryOpts.Timeout)
+	}
+	return findOneOptions
+}
+
+// MongoRecordCursor 基于 Mongo 游标的记录游标
+type MongoRecordCursor struct {
+	ctx     context.Context
+	cursor  *mongo.Cursor
+	builder *MongoRecordBuilder
+	scoring bool
+	record  Record
+	err     error
+}
+
+func (c *MongoRecordCursor) Next() bool {
+	if c.err != nil || !c.cursor.Next(c.ctx) {
+		c.record = nil
+		return false
+	}
+
+	var doc bson.M
+	if err := c.cursor.Decode(&doc); err != nil {
+		c.err = err
+		c.record = nil
+		return false
+	}
+	record := c.builder.newRecord(doc)
+	if c.scoring {
+		record.takeScore()
+	}
+	c.record = record
+	return true
+}
+
+func (c *MongoRecordCursor) Scan(dest any) error {
+	if c.record == nil {
+		return fmt.Errorf("no current record")
+	}
+	return c.record.Scan(dest)
+}
+
+func (c *MongoRecordCursor) Record() Record {
+	return c.record
+}
+
+func (c *MongoRecordCursor) Err() error {
+	if c.err != nil {
+		return c.err
+	}
+	return c.cursor.Err()
+}
+
+func (c *MongoRecordCursor) Close() error {
+	return c.cursor.Close(context.Background())
+}
+
+// Count 使用 CountDocuments 统计文档数
+func (m *Mongo) Count(ctx context.Context, table string, query query.Query) (int64, error) {
+	ctx, done, err := m.ops.enter(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer done()
 
-		_, err := collection.UpdateOne(ctx, filter, update)
-		if err != nil {
-			return err
-		}
+	return countMongo(ctx, m.readCollection(ctx, table), query)
+}
+
+// Exists 根据主键判断文档是否存在，匹配到一条即返回
+func (m *Mongo) Exists(ctx context.Context, table string, pk map[string]any) (bool, error) {
+	ctx, done, err := m.ops.enter(ctx)
+	if err != nil {
+		return false, err
 	}
+	defer done()
 
-	return nil
+	return existsMongo(ctx, m.readCollection(ctx, table), pk)
 }
 
-func (m *Mongo) BatchDelete(ctx context.Context, table string, pks []map[string]any) error {
-	if len(pks) == 0 {
-		return nil
+func countMongo(ctx context.Context, collection *mongo.Collection, query query.Query) (int64, error) {
+	filter, err := query.ToMongo()
+	if err != nil {
+		return 0, fmt.Errorf("failed to convert query to mongo: %v", err)
 	}
 
-	collection := m.database.Collection(table)
+	return collection.CountDocuments(ctx, filter)
+}
 
-	// 构建批量删除过滤器
-	var filters []bson.M
-	for _, pk := range pks {
-		filter := make(bson.M)
-		for k, v := range pk {
-			filter[k] = v
-		}
-		filters = append(filters, filter)
+func existsMongo(ctx context.Context, collection *mongo.Collection, pk map[string]any) (bool, error) {
+	filter := make(bson.M)
+	for k, v := range pk {
+		filter[k] = v
 	}
 
-	// 使用$or查询删除多个文档
-	filter := bson.M{"$or": filters}
-	_, err := collection.DeleteMany(ctx, filter)
-	return err
+	count, err := collection.CountDocuments(ctx, filter, options.Count().SetLimit(1))
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
 }
 
-// 查询和聚合功能实现
-func (m *Mongo) Find(ctx context.Context, table string, query query.Query, opts ...QueryOption) ([]Record, error) {
+func (m *Mongo) Aggregate(ctx context.Context, table string, query query.Query, aggs []aggregation.Aggregation, opts ...QueryOption) (aggregation.AggregationResult, error) {
+	ctx, done, err := m.ops.enter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	ctx, cancel := queryContext(ctx, opts)
+	defer cancel()
+
 	// 解析查询选项
 	queryOpts := &QueryOptions{}
 	for _, opt := range opts {
 		opt(queryOpts)
 	}
 
-	collection := m.database.Collection(table)
+	if err := aggregation.ValidateScriptAggregations(aggs); err != nil {
+		return nil, err
+	}
+
+	collection := m.readCollection(ctx, table)
 
-	// 构建查询过滤器
+	// 匹配阶段
 	filter, err := query.ToMongo()
 	if err != nil {
 		return nil, fmt.Errorf("failed to convert query to mongo: %v", err)
 	}
+	var matchStages []bson.M
+	if len(filter) > 0 {
+		matchStages = append(matchStages, bson.M{"$match": filter})
+	}
 
-	// 创建查找选项
-	findOptions := options.Find()
-
-	// 添加排序
-	if queryOpts.OrderBy != "" {
-		direction := 1
-		if queryOpts.OrderDesc {
-			direction = -1
+	// 分组统计：一次 $group 统计每组的全部指标
+	if queryOpts.grouping() {
+		group, err := newGroupBy(aggs, queryOpts)
+		if err != nil {
+			return nil, err
 		}
-		findOptions.SetSort(bson.D{{Key: queryOpts.OrderBy, Value: direction}})
+		return m.aggregateGroupBy(ctx, collection, matchStages, group)
 	}
 
-	// 添加分页
-	if queryOpts.Limit > 0 {
-		findOptions.SetLimit(int64(queryOpts.Limit))
-	}
-	if queryOpts.Offset > 0 {
-		findOptions.SetSkip(int64(queryOpts.Offset))
+	pipelines, aggs := aggregation.SplitPipelineAggregations(aggs)
+	scripts, aggs := aggregation.SplitScriptAggregations(aggs)
+	metrics, buckets := aggregation.SplitAggregations(aggs)
+	result := aggregation.NewAggregationResult()
+
+	// 管道聚合：在匹配阶段之后直接执行，输出文档列表
+	for _, agg := range pipelines {
+		stages, err := agg.MongoPipeline()
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert aggregation to mongo: %v", err)
+		}
+		pipeline := make([]interface{}, 0, len(matchStages)+len(stages))
+		for _, stage := range matchStages {
+			pipeline = append(pipeline, stage)
+		}
+		docs, err := m.aggregateDocs(ctx, collection, append(pipeline, stages...), queryOpts.Timeout)
+		if err != nil {
+			return nil, err
+		}
+		documents := make([]map[string]interface{}, 0, len(docs))
+		for _, doc := range docs {
+			documents = append(documents, doc)
+		}
+		result.SetResult(agg.Name(), documents)
 	}
 
-	// 执行查询
-	cursor, err := collection.Find(ctx, filter, findOptions)
-	if err != nil {
-		return nil, err
+	// 指标聚合：全局分组，一次统计全部指标
+	if len(metrics) > 0 {
+		groupStage := bson.M{"_id": nil}
+		for _, agg := range metrics {
+			aggDoc, err := agg.ToMongo()
+			if err != nil {
+				return nil, fmt.Errorf("failed to convert aggregation to mongo: %v", err)
+			}
+			// 使用聚合名称作为字段名，aggDoc 包含完整的聚合操作符
+			if aggName := agg.Name(); aggName != "" {
+				groupStage[aggName] = aggDoc
+			}
+		}
+
+		pipeline := append(append([]bson.M{}, matchStages...), bson.M{"$group": groupStage})
+		docs, err := m.aggregateDocs(ctx, collection, pipeline, queryOpts.Timeout)
+		if err != nil {
+			return nil, err
+		}
+		for _, doc := range docs {
+			for _, agg := range metrics {
+				if value, exists := doc[agg.Name()]; exists {
+					result.SetResult(agg.Name(), value)
+				}
+			}
+		}
 	}
-	defer cursor.Close(ctx)
 
-	// 扫描结果
-	var records []Record
-	for cursor.Next(ctx) {
-		var doc bson.M
-		if err := cursor.Decode(&doc); err != nil {
+	// 桶聚合：逐层按复合 _id 分组，子聚合挂到对应的父桶下
+	fetch := func(chain []*bucketLevel) ([]bucketRow, error) {
+		return m.fetchBucketRows(ctx, collection, matchStages, chain, queryOpts)
+	}
+	for _, bucket := range buckets {
+		tree, err := buildBucketTree([]*bucketLevel{newBucketLevel(bucket)}, fetch)
+		if err != nil {
 			return nil, err
 		}
-		records = append(records, &MongoRecord{data: doc})
+		result.SetResult(bucket.Name(), tree[""])
 	}
 
-	if err := cursor.Err(); err != nil {
+	// 派生指标：由上面的聚合结果计算
+	if err := applyScriptAggregations(scripts, result.Get, result.SetResult); err != nil {
 		return nil, err
 	}
 
-	return records, nil
+	return result, nil
 }
 
-func (m *Mongo) Aggregate(ctx context.Context, table string, query query.Query, aggs []aggregation.Aggregation, opts ...QueryOption) (aggregation.AggregationResult, error) {
-	// 解析查询选项
-	queryOpts := &QueryOptions{}
-	for _, opt := range opts {
-		opt(queryOpts)
+// aggregateGroupBy 执行分组统计，结果行按 GroupByResultName 返回
+func (m *Mongo) aggregateGroupBy(ctx context.Context, collection *mongo.Collection, matchStages []bson.M, group *groupBy) (aggregation.AggregationResult, error) {
+	pipeline, err := mongoGroupByPipeline(matchStages, group)
+	if err != nil {
+		return nil, err
+	}
+	docs, err := m.aggregateDocs(ctx, collection, pipeline, group.options.Timeout)
+	if err != nil {
+		return nil, err
 	}
 
-	collection := m.database.Collection(table)
-
-	// 构建聚合管道
-	pipeline := make([]bson.M, 0)
+	rows := make([]map[string]any, 0, len(docs))
+	for _, doc := range docs {
+		id, _ := doc["_id"].(bson.M)
+		if d, ok := doc["_id"].(bson.D); ok {
+			id = d.Map()
+		}
+		for i, field := range group.fields {
+			doc[field] = id[fmt.Sprintf("%s%d", aggKeyFieldPrefix, i)]
+		}
+		row, err := group.row(doc)
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+	return group.result(rows), nil
+}
 
-	// 添加匹配阶段
-	filter, err := query.ToMongo()
-	if err != nil {
-		return nil, fmt.Errorf("failed to convert query to mongo: %v", err)
+// mongoGroupByPipeline 构建分组统计的管道，分组字段放在 _id 中，Having 转换为 $group 之后的 $match
+func mongoGroupByPipeline(matchStages []bson.M, group *groupBy) ([]bson.M, error) {
+	groupID := bson.M{}
+	keyFields := make(map[string]string, len(group.fields))
+	for i, field := range group.fields {
+		key := fmt.Sprintf("%s%d", aggKeyFieldPrefix, i)
+		groupID[key] = "$" + field
+		keyFields[field] = "_id." + key
+	}
+	groupStage := bson.M{
+		"_id":           groupID,
+		GroupCountField: bson.M{"$sum": 1},
+	}
+	for _, metric := range group.metrics {
+		aggDoc, err := metric.ToMongo()
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert aggregation to mongo: %v", err)
+		}
+		groupStage[metric.Name()] = aggDoc
 	}
-	if len(filter) > 0 {
+
+	pipeline := append(append([]bson.M{}, matchStages...), bson.M{"$group": groupStage})
+	if group.having != nil {
+		filter, err := group.having.ToMongo()
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert having to mongo: %v", err)
+		}
 		pipeline = append(pipeline, bson.M{"$match": filter})
 	}
 
-	// 构建聚合阶段
-	groupStage := bson.M{}
-	hasGrouping := false
+	sortStage := bson.D{}
+	for i, key := range group.sortKeys() {
+		order := 1
+		if i == 0 && group.options.OrderBy != "" && group.options.OrderDesc {
+			order = -1
+		}
+		if keyField, ok := keyFields[key]; ok {
+			key = keyField
+		}
+		sortStage = append(sortStage, bson.E{Key: key, Value: order})
+	}
+	pipeline = append(pipeline, bson.M{"$sort": sortStage})
+	if group.options.Offset > 0 {
+		pipeline = append(pipeline, bson.M{"$skip": group.options.Offset})
+	}
+	if group.options.Limit > 0 {
+		pipeline = append(pipeline, bson.M{"$limit": group.options.Limit})
+	}
+	return pipeline, nil
+}
+
+// fetchBucketRows 按层级链的分组表达式构建 $group 管道，统计当前层级的文档数和指标子聚合
+func (m *Mongo) fetchBucketRows(ctx context.Context, collection *mongo.Collection, matchStages []bson.M, chain []*bucketLevel, queryOpts *QueryOptions) ([]bucketRow, error) {
+	depth := len(chain) - 1
+	level := chain[depth]
 
-	for _, agg := range aggs {
-		aggDoc, err := agg.ToMongo()
+	groupID := bson.M{}
+	for i, l := range chain {
+		groupID[fmt.Sprintf("%s%d", aggKeyFieldPrefix, i)] = l.agg.MongoGroupExpr()
+	}
+	groupStage := bson.M{
+		"_id":            groupID,
+		aggDocCountField: bson.M{"$sum": 1},
+	}
+	for _, metric := range level.metrics {
+		aggDoc, err := metric.ToMongo()
 		if err != nil {
 			return nil, fmt.Errorf("failed to convert aggregation to mongo: %v", err)
 		}
+		groupStage[metric.Name()] = aggDoc
+	}
 
-		switch agg.Type() {
-		case aggregation.AggTypeSum, aggregation.AggTypeAvg, aggregation.AggTypeMax, 
-			 aggregation.AggTypeMin, aggregation.AggTypeCount:
-			// 度量聚合
-			if !hasGrouping {
-				groupStage["_id"] = nil // 全局聚合
-				hasGrouping = true
-			}
-			// 使用聚合名称作为字段名，而不是直接使用操作符
-			aggName := agg.Name()
-			if aggName != "" {
-				// aggDoc 包含完整的聚合操作符，直接使用
-				groupStage[aggName] = aggDoc
-			}
-		case aggregation.AggTypeTerms:
-			// 分桶聚合
-			if termsAgg, ok := agg.(*aggregation.TermsAggregation); ok {
-				groupStage["_id"] = "$" + termsAgg.Field
-				hasGrouping = true
+	pipeline := append(append([]bson.M{}, matchStages...), bson.M{"$group": groupStage})
+
+	// 排序：词条聚合的 Order 支持 _count、_key 和子聚合名，默认按桶键升序
+	keyField := fmt.Sprintf("_id.%s%d", aggKeyFieldPrefix, depth)
+	sortStage := bson.D{}
+	if termsAgg, ok := level.agg.(*aggregation.TermsAggregation); ok {
+		for field, direction := range termsAgg.Order {
+			switch field {
+			case "_count":
+				field = aggDocCountField
+			case "_key":
+				field = keyField
 			}
-		case aggregation.AggTypeDateHisto:
-			// 日期直方图聚合
-			if dateHistoAgg, ok := agg.(*aggregation.DateHistogramAggregation); ok {
-				// 简化实现：按日期字段分组
-				groupStage["_id"] = "$" + dateHistoAgg.Field
-				hasGrouping = true
+			order := 1
+			if strings.ToLower(direction) == "desc" {
+				order = -1
 			}
+			sortStage = append(sortStage, bson.E{Key: field, Value: order})
 		}
 	}
-
-	// 添加分组阶段
-	if hasGrouping && len(groupStage) > 0 {
-		pipeline = append(pipeline, bson.M{"$group": groupStage})
+	if depth == 0 && queryOpts.OrderBy != "" {
+		order := 1
+		if queryOpts.OrderDesc {
+			order = -1
+		}
+		sortStage = append(sortStage, bson.E{Key: queryOpts.OrderBy, Value: order})
 	}
+	if len(sortStage) == 0 {
+		sortStage = append(sortStage, bson.E{Key: keyField, Value: 1})
+	}
+	pipeline = append(pipeline, bson.M{"$sort": sortStage})
 
-	// 添加排序
-	if queryOpts.OrderBy != "" {
-		direction := 1
-		if queryOpts.OrderDesc {
-			direction = -1
+	// 分页只作用于顶层桶，嵌套层级的数量由父桶决定
+	if depth == 0 {
+		limit := queryOpts.Limit
+		if termsAgg, ok := level.agg.(*aggregation.TermsAggregation); ok && termsAgg.Size > 0 {
+			limit = termsAgg.Size
+		}
+		if queryOpts.Offset > 0 {
+			pipeline = append(pipeline, bson.M{"$skip": queryOpts.Offset})
+		}
+		if limit > 0 {
+			pipeline = append(pipeline, bson.M{"$limit": limit})
 		}
-		pipeline = append(pipeline, bson.M{"$sort": bson.M{queryOpts.OrderBy: direction}})
 	}
 
-	// 添加分页
-	if queryOpts.Offset > 0 {
-		pipeline = append(pipeline, bson.M{"$skip": queryOpts.Offset})
+	docs, err := m.aggregateDocs(ctx, collection, pipeline, queryOpts.Timeout)
+	if err != nil {
+		return nil, err
 	}
-	if queryOpts.Limit > 0 {
-		pipeline = append(pipeline, bson.M{"$limit": queryOpts.Limit})
+
+	rows := make([]bucketRow, 0, len(docs))
+	for _, doc := range docs {
+		id, _ := doc["_id"].(bson.M)
+		if d, ok := doc["_id"].(bson.D); ok {
+			id = d.Map()
+		}
+		row := bucketRow{
+			keys:     make([]interface{}, len(chain)),
+			docCount: toInt64(doc[aggDocCountField]),
+			values:   make(map[string]interface{}, len(level.metrics)),
+		}
+		for i := range chain {
+			row.keys[i] = id[fmt.Sprintf("%s%d", aggKeyFieldPrefix, i)]
+		}
+		for _, metric := range level.metrics {
+			row.values[metric.Name()] = doc[metric.Name()]
+		}
+		rows = append(rows, row)
 	}
 
-	// 执行聚合查询
-	cursor, err := collection.Aggregate(ctx, pipeline)
+	return rows, nil
+}
+
+// aggregateDocs 执行聚合管道并返回全部结果文档，maxTime 大于 0 时设置 maxTimeMS，超时后服务端停止执行
+func (m *Mongo) aggregateDocs(ctx context.Context, collection *mongo.Collection, pipeline interface{}, maxTime time.Duration) ([]bson.M, error) {
+	aggregateOptions := options.Aggregate()
+	if maxTime > 0 {
+		aggregateOptions.SetMaxTime(maxTime)
+	}
+	cursor, err := collection.Aggregate(ctx, pipeline, aggregateOptions)
 	if err != nil {
 		return nil, err
 	}
 	defer cursor.Close(ctx)
 
-	// 构建聚合结果
-	result := aggregation.NewAggregationResult()
-
+	var docs []bson.M
 	for cursor.Next(ctx) {
 		var doc bson.M
 		if err := cursor.Decode(&doc); err != nil {
 			return nil, err
 		}
-
-		// 简化处理：将聚合结果存储到结果中
-		for _, agg := range aggs {
-			aggName := agg.Name()
-			if value, exists := doc[aggName]; exists {
-				result.SetResult(aggName, value)
-			}
-		}
-	}
-
-	if err := cursor.Err(); err != nil {
-		return nil, err
+		docs = append(docs, doc)
 	}
 
-	return result, nil
+	return docs, cursor.Err()
 }
 
 // 事务支持实现
+
+// BeginTx 开启事务，提交或回滚前算作进行中的操作
 func (m *Mongo) BeginTx(ctx context.Context) (Transaction, error) {
-	session, err := m.client.StartSession()
+	ctx, done, err := m.ops.enter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := m.beginTx(ctx)
+	if err != nil {
+		done()
+		return nil, err
+	}
+	return newTrackedTransaction(ctx, tx, done, m.txLeaks), nil
+}
+
+func (m *Mongo) beginTx(ctx context.Context) (Transaction, error) {
+	session, err := m.getClient().StartSession()
 	if err != nil {
 		return nil, fmt.Errorf("failed to start session: %v", err)
 	}
 
 	return &MongoTransaction{
 		session:    session,
-		database:   m.database,
+		database:   m.getDatabase(),
 		builder:    m.builder,
 		hasStarted: false,
 	}, nil
@@ -767,6 +1870,9 @@ func (tx *MongoTransaction) Rollback() error {
 
 // 事务中的CRUD操作实现
 func (tx *MongoTransaction) Create(ctx context.Context, table string, record Record, opts ...CreateOption) error {
+	ctx, cancel := createContext(ctx, opts)
+	defer cancel()
+
 	// 解析创建选项
 	createOpts := &CreateOptions{}
 	for _, opt := range opts {
@@ -805,11 +1911,7 @@ func (tx *MongoTransaction) Create(ctx context.Context, table string, record Rec
 		}
 		return err
 	} else if createOpts.UpdateOnConflict {
-		// 使用ReplaceOne with upsert选项在冲突时更新
-		filter := bson.M{"_id": doc["_id"]}
-		replaceOptions := options.Replace().SetUpsert(true)
-		_, err := collection.ReplaceOne(sessionCtx, filter, doc, replaceOptions)
-		return err
+		return upsertMongo(sessionCtx, collection, record, doc)
 	} else {
 		// 默认的插入操作
 		_, err := collection.InsertOne(sessionCtx, doc)
@@ -820,7 +1922,10 @@ func (tx *MongoTransaction) Create(ctx context.Context, table string, record Rec
 	}
 }
 
-func (tx *MongoTransaction) Get(ctx context.Context, table string, pk map[string]any) (Record, error) {
+func (tx *MongoTransaction) Get(ctx context.Context, table string, pk map[string]any, opts ...QueryOption) (Record, error) {
+	ctx, cancel := queryContext(ctx, opts)
+	defer cancel()
+
 	// 确保事务已开始
 	if !tx.hasStarted {
 		if err := tx.session.StartTransaction(); err != nil {
@@ -840,7 +1945,7 @@ func (tx *MongoTransaction) Get(ctx context.Context, table string, pk map[string
 	}
 
 	var result bson.M
-	err := collection.FindOne(sessionCtx, filter).Decode(&result)
+	err := collection.FindOne(sessionCtx, filter, mongoFindOneOptions(opts)).Decode(&result)
 	if err == mongo.ErrNoDocuments {
 		return nil, ErrRecordNotFound
 	}
@@ -848,31 +1953,67 @@ func (tx *MongoTransaction) Get(ctx context.Context, table string, pk map[string
 		return nil, err
 	}
 	
-	return &MongoRecord{data: result}, nil
+	return tx.builder.newRecord(result), nil
 }
 
-func (tx *MongoTransaction) Update(ctx context.Context, table string, pk map[string]any, record Record) error {
+func (tx *MongoTransaction) Update(ctx context.Context, table string, pk map[string]any, record Record, opts ...UpdateOption) error {
+	ctx, cancel := updateContext(ctx, opts)
+	defer cancel()
+
 	collection := tx.database.Collection(table)
+	fields, options := updateFields(record), newUpdateOptions(opts)
+	if len(fields) == 0 && !options.versioned() {
+		return nil
+	}
+	callback := func(sessionContext mongo.SessionContext) (interface{}, error) {
+		return nil, setMongo(sessionContext, collection, pk, fields, options)
+	}
 
-	// 构建查询过滤器
-	filter := make(bson.M)
-	for k, v := range pk {
-		filter[k] = v
+	_, err := tx.session.WithTransaction(ctx, callback)
+	return err
+}
+
+func (tx *MongoTransaction) UpdatePartial(ctx context.Context, table string, pk map[string]any, fields map[string]any, opts ...UpdateOption) error {
+	ctx, cancel := updateContext(ctx, opts)
+	defer cancel()
+
+	options := newUpdateOptions(opts)
+	if len(fields) == 0 && !options.versioned() {
+		return fmt.Errorf("no fields to update")
 	}
 
-	// 构建更新文档
-	fields := record.Fields()
-	update := bson.M{"$set": fields}
+	collection := tx.database.Collection(table)
+	callback := func(sessionContext mongo.SessionContext) (interface{}, error) {
+		return nil, setMongo(sessionContext, collection, pk, fields, options)
+	}
+
+	_, err := tx.session.WithTransaction(ctx, callback)
+	return err
+}
+
+func (tx *MongoTransaction) Upsert(ctx context.Context, table string, pk map[string]any, record Record) error {
+	fields, err := upsertFields(pk, record)
+	if err != nil {
+		return err
+	}
 
+	collection := tx.database.Collection(table)
 	callback := func(sessionContext mongo.SessionContext) (interface{}, error) {
-		result, err := collection.UpdateOne(sessionContext, filter, update)
-		if err != nil {
-			return nil, err
-		}
-		if result.MatchedCount == 0 {
-			return nil, ErrRecordNotFound
-		}
-		return nil, nil
+		return nil, replaceMongo(sessionContext, collection, pk, fields)
+	}
+
+	_, err = tx.session.WithTransaction(ctx, callback)
+	return err
+}
+
+func (tx *MongoTransaction) Increment(ctx context.Context, table string, pk map[string]any, field string, delta any) error {
+	if err := validateIncrementDelta(delta); err != nil {
+		return err
+	}
+
+	collection := tx.database.Collection(table)
+	callback := func(sessionContext mongo.SessionContext) (interface{}, error) {
+		return nil, updateMongo(sessionContext, collection, pk, bson.M{"$inc": bson.M{field: delta}})
 	}
 
 	_, err := tx.session.WithTransaction(ctx, callback)
@@ -904,6 +2045,9 @@ func (tx *MongoTransaction) Delete(ctx context.Context, table string, pk map[str
 }
 
 func (tx *MongoTransaction) Find(ctx context.Context, table string, query query.Query, opts ...QueryOption) ([]Record, error) {
+	ctx, cancel := queryContext(ctx, opts)
+	defer cancel()
+
 	// 解析查询选项
 	queryOpts := &QueryOptions{}
 	for _, opt := range opts {
@@ -912,30 +2056,10 @@ func (tx *MongoTransaction) Find(ctx context.Context, table string, query query.
 
 	collection := tx.database.Collection(table)
 
-	// 构建查询过滤器
-	filter, err := query.ToMongo()
+	// 构建查询过滤器和查找选项
+	filter, findOptions, scoring, err := mongoFindArgs(query, queryOpts)
 	if err != nil {
-		return nil, fmt.Errorf("failed to convert query to mongo: %v", err)
-	}
-
-	// 创建查找选项
-	findOptions := options.Find()
-
-	// 添加排序
-	if queryOpts.OrderBy != "" {
-		direction := 1
-		if queryOpts.OrderDesc {
-			direction = -1
-		}
-		findOptions.SetSort(bson.D{{Key: queryOpts.OrderBy, Value: direction}})
-	}
-
-	// 添加分页
-	if queryOpts.Limit > 0 {
-		findOptions.SetLimit(int64(queryOpts.Limit))
-	}
-	if queryOpts.Offset > 0 {
-		findOptions.SetSkip(int64(queryOpts.Offset))
+		return nil, err
 	}
 
 	var records []Record
@@ -953,7 +2077,11 @@ func (tx *MongoTransaction) Find(ctx context.Context, table string, query query.
 			if err := cursor.Decode(&doc); err != nil {
 				return nil, err
 			}
-			records = append(records, &MongoRecord{data: doc})
+			record := tx.builder.newRecord(doc)
+			if scoring {
+				record.takeScore()
+			}
+			records = append(records, record)
 		}
 
 		return records, cursor.Err()
@@ -966,12 +2094,35 @@ func (tx *MongoTransaction) Find(ctx context.Context, table string, query query.
 	return res.([]Record), nil
 }
 
+// FindStream 在事务会话中流式查询
+func (tx *MongoTransaction) FindStream(ctx context.Context, table string, query query.Query, opts ...QueryOption) (RecordCursor, error) {
+	return streamWithTimeout(ctx, opts, func(ctx context.Context) (RecordCursor, error) {
+		sessionContext := mongo.NewSessionContext(ctx, tx.session)
+		return findMongoStream(sessionContext, tx.database.Collection(table), tx.builder, query, opts)
+	})
+}
+
+// Count 在事务会话中统计文档数
+func (tx *MongoTransaction) Count(ctx context.Context, table string, query query.Query) (int64, error) {
+	sessionContext := mongo.NewSessionContext(ctx, tx.session)
+	return countMongo(sessionContext, tx.database.Collection(table), query)
+}
+
+// Exists 在事务会话中根据主键判断文档是否存在
+func (tx *MongoTransaction) Exists(ctx context.Context, table string, pk map[string]any) (bool, error) {
+	sessionContext := mongo.NewSessionContext(ctx, tx.session)
+	return existsMongo(sessionContext, tx.database.Collection(table), pk)
+}
+
 func (tx *MongoTransaction) Aggregate(ctx context.Context, table string, query query.Query, aggs []aggregation.Aggregation, opts ...QueryOption) (aggregation.AggregationResult, error) {
 	// 简化实现：在事务中使用基本的聚合
 	return aggregation.NewAggregationResult(), nil
 }
 
 func (tx *MongoTransaction) BatchCreate(ctx context.Context, table string, records []Record, opts ...CreateOption) error {
+	ctx, cancel := createContext(ctx, opts)
+	defer cancel()
+
 	for _, record := range records {
 		if err := tx.Create(ctx, table, record, opts...); err != nil {
 			return err
@@ -1024,6 +2175,11 @@ func (tx *MongoTransaction) GetBuilder() RecordBuilder {
 	return tx.builder
 }
 
+// Health 通过事务所属的客户端 Ping 主节点
+func (tx *MongoTransaction) Health(ctx context.Context) error {
+	return tx.session.Client().Ping(ctx, readpref.Primary())
+}
+
 func (tx *MongoTransaction) Close() error {
 	return nil // 事务不需要单独关闭
 }
\ No newline at end of file
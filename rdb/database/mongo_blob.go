@@ -0,0 +1,80 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// GridFSBlobStore 将 blob 存储在 MongoDB GridFS 中，blob 的键作为文件 ID 和文件名
+type GridFSBlobStore struct {
+	mongo  *Mongo
+	bucket string
+}
+
+// NewGridFSBlobStore 使用 m 所在数据库的 GridFS bucket 存储 blob，bucket 为空时使用默认的 fs
+func NewGridFSBlobStore(m *Mongo, bucket string) *GridFSBlobStore {
+	if bucket == "" {
+		bucket = options.DefaultName
+	}
+	return &GridFSBlobStore{mongo: m, bucket: bucket}
+}
+
+// openBucket 每次使用当前的客户端打开 bucket，健康检查重建客户端后仍然可用
+// GridFS 的读写不接收 ctx，ctx 的截止时间作为 bucket 的读写截止时间
+func (s *GridFSBlobStore) openBucket(ctx context.Context) (*gridfs.Bucket, error) {
+	bucket, err := gridfs.NewBucket(s.mongo.getDatabase(), options.GridFSBucket().SetName(s.bucket))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open gridfs bucket: %v", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		bucket.SetReadDeadline(deadline)
+		bucket.SetWriteDeadline(deadline)
+	}
+	return bucket, nil
+}
+
+func (s *GridFSBlobStore) Put(ctx context.Context, key string, r io.Reader) error {
+	bucket, err := s.openBucket(ctx)
+	if err != nil {
+		return err
+	}
+	// GridFS 同一个 ID 不能重复上传，覆盖时先删除旧文件
+	if err := s.Delete(ctx, key); err != nil {
+		return err
+	}
+	if err := bucket.UploadFromStreamWithID(key, key, &contextReader{ctx: ctx, r: r}); err != nil {
+		return fmt.Errorf("failed to upload blob %s: %v", key, err)
+	}
+	return nil
+}
+
+func (s *GridFSBlobStore) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	bucket, err := s.openBucket(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stream, err := bucket.OpenDownloadStream(key)
+	if errors.Is(err, gridfs.ErrFileNotFound) {
+		return nil, fmt.Errorf("%w: key %s", ErrBlobNotFound, key)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open blob %s: %v", key, err)
+	}
+	return stream, nil
+}
+
+func (s *GridFSBlobStore) Delete(ctx context.Context, key string) error {
+	bucket, err := s.openBucket(ctx)
+	if err != nil {
+		return err
+	}
+	if err := bucket.DeleteContext(ctx, key); err != nil && !errors.Is(err, gridfs.ErrFileNotFound) {
+		return fmt.Errorf("failed to delete blob %s: %v", key, err)
+	}
+	return nil
+}
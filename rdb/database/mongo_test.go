@@ -7,6 +7,7 @@ import (
 	"testing"
 	"time"
 
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 
 	"github.com/hatlonely/gox/rdb/aggregation"
@@ -195,7 +196,7 @@ func TestStructToBSON(t *testing.T) {
 				Age:    30,
 			}
 
-			result := structToBSON(user)
+			result := structToBSON(user, DefaultTagName)
 			So(result["user_id"], ShouldEqual, 1)
 			So(result["name"], ShouldEqual, "John Doe")
 			So(result["email"], ShouldEqual, "john@example.com")
@@ -208,13 +209,13 @@ func TestStructToBSON(t *testing.T) {
 				Name:   "John Doe",
 			}
 
-			result := structToBSON(user)
+			result := structToBSON(user, DefaultTagName)
 			So(result["user_id"], ShouldEqual, 1)
 			So(result["name"], ShouldEqual, "John Doe")
 		})
 
 		Convey("非结构体类型", func() {
-			result := structToBSON("not a struct")
+			result := structToBSON("not a struct", DefaultTagName)
 			So(len(result), ShouldEqual, 0)
 		})
 	})
@@ -235,7 +236,7 @@ func TestBSONToStruct(t *testing.T) {
 			}
 
 			var user TestMongoUser
-			err := bsonToStruct(data, &user)
+			err := bsonToStruct(data, &user, DefaultTagName)
 			So(err, ShouldBeNil)
 			So(user.UserID, ShouldEqual, 1)
 			So(user.Name, ShouldEqual, "John Doe")
@@ -248,14 +249,14 @@ func TestBSONToStruct(t *testing.T) {
 		Convey("目标不是指针", func() {
 			data := map[string]any{"user_id": 1}
 			var user TestMongoUser
-			err := bsonToStruct(data, user)
+			err := bsonToStruct(data, user, DefaultTagName)
 			So(err, ShouldNotBeNil)
 		})
 
 		Convey("目标不是结构体指针", func() {
 			data := map[string]any{"value": 1}
 			var value int
-			err := bsonToStruct(data, &value)
+			err := bsonToStruct(data, &value, DefaultTagName)
 			So(err, ShouldNotBeNil)
 		})
 	})
@@ -667,6 +668,53 @@ func TestMongoAggregate(t *testing.T) {
 			So(count, ShouldEqual, 4)
 		})
 
+		Convey("管道聚合", func() {
+			termQuery := &query.TermQuery{Field: "active", Value: true}
+			pipelineAgg := &aggregation.PipelineAggregation{
+				AggName: "oldest",
+				Pipeline: []bson.M{
+					{"$sort": bson.M{"age": -1}},
+					{"$limit": 2},
+					{"$project": bson.M{"_id": 0, "name": 1, "age": 1}},
+				},
+			}
+			result, err := mongo.Aggregate(ctx, "test_agg_users", termQuery, []aggregation.Aggregation{pipelineAgg})
+			So(err, ShouldBeNil)
+			docs := result.GetDocuments("oldest")
+			So(len(docs), ShouldEqual, 2)
+			So(docs[0]["name"], ShouldEqual, "Charlie")
+			So(docs[1]["name"], ShouldEqual, "John")
+		})
+
+		Convey("关联查询", func() {
+			for _, order := range []map[string]any{
+				{"_id": "o1", "user_id": 1, "amount": 100},
+				{"_id": "o2", "user_id": 1, "amount": 50},
+				{"_id": "o3", "user_id": 3, "amount": 80},
+			} {
+				So(mongo.Create(ctx, "test_agg_orders", mongo.builder.FromMap(order, "test_agg_orders")), ShouldBeNil)
+			}
+			defer mongo.DropTable(ctx, "test_agg_orders")
+
+			lookupAgg := &aggregation.LookupAggregation{
+				AggName:      "orders_with_user",
+				From:         "test_agg_users",
+				LocalField:   "user_id",
+				ForeignField: "user_id",
+				As:           "user",
+				Unwind:       true,
+				Pipeline:     []bson.M{{"$sort": bson.M{"_id": 1}}},
+			}
+			termQuery := &query.RangeQuery{Field: "amount", Gte: 60}
+			result, err := mongo.Aggregate(ctx, "test_agg_orders", termQuery, []aggregation.Aggregation{lookupAgg})
+			So(err, ShouldBeNil)
+			docs := result.GetDocuments("orders_with_user")
+			So(len(docs), ShouldEqual, 2)
+			So(docs[0]["_id"], ShouldEqual, "o1")
+			So(docs[0]["user"].(bson.M)["name"], ShouldEqual, "John")
+			So(docs[1]["user"].(bson.M)["name"], ShouldEqual, "Bob")
+		})
+
 		Convey("Count 聚合 - COUNT(email)", func() {
 			// 测试 COUNT(email) - 统计有非空email的active用户
 			termQuery := &query.TermQuery{Field: "active", Value: true}
@@ -1130,7 +1178,7 @@ func TestMongoErrorHandling(t *testing.T) {
 			var user TestMongoUser
 			// 这个测试可能会成功，因为 Go 的反射会尝试类型转换
 			// 但我们至少验证了函数不会 panic
-			So(func() { bsonToStruct(data, &user) }, ShouldNotPanic)
+			So(func() { bsonToStruct(data, &user, DefaultTagName) }, ShouldNotPanic)
 		})
 	})
 }
@@ -1144,7 +1192,7 @@ func TestMongoEdgeCases(t *testing.T) {
 		Convey("测试空字段的结构体", func() {
 			type EmptyStruct struct{}
 			empty := EmptyStruct{}
-			result := structToBSON(empty)
+			result := structToBSON(empty, DefaultTagName)
 			So(len(result), ShouldEqual, 0)
 		})
 
@@ -1161,7 +1209,7 @@ func TestMongoEdgeCases(t *testing.T) {
 				Name:         "test",
 			}
 
-			result := structToBSON(s)
+			result := structToBSON(s, DefaultTagName)
 			So(result["user_id"], ShouldEqual, 1)
 			So(result["name"], ShouldEqual, "test")
 			So(result["privateField"], ShouldBeNil) // 未导出字段不应该被包含
@@ -1180,7 +1228,7 @@ func TestMongoEdgeCases(t *testing.T) {
 				Name:    "test",
 			}
 
-			result := structToBSON(s)
+			result := structToBSON(s, DefaultTagName)
 			So(result["user_id"], ShouldEqual, 1)
 			So(result["name"], ShouldEqual, "test")
 			_, exists := result["Ignored"]
@@ -1263,4 +1311,242 @@ func TestMongoTransactionMethods(t *testing.T) {
 			So(err.Error(), ShouldContainSubstring, "drop table not supported in transactions")
 		})
 	})
-}
\ No newline at end of file
+}
+func TestMongoStats(t *testing.T) {
+	Convey("测试 Mongo Stats 方法", t, func() {
+		mongo, err := NewMongoWithOptions(testMongoOptions)
+		So(err, ShouldBeNil)
+		defer mongo.Close()
+
+		ctx := context.Background()
+		defer mongo.DropTable(ctx, "test_stats_users")
+
+		for i := 1; i <= 3; i++ {
+			record := mongo.builder.FromStruct(TestMongoUser{UserID: i, Name: "user"})
+			So(mongo.Create(ctx, "test_stats_users", record), ShouldBeNil)
+		}
+
+		stats, err := mongo.Stats(ctx, "test_stats_users")
+		So(err, ShouldBeNil)
+		So(stats.RowCount, ShouldEqual, 3)
+		So(stats.DataSize, ShouldBeGreaterThan, 0)
+		So(stats.IndexSize, ShouldBeGreaterThan, 0)
+	})
+}
+
+func TestMongoFindStream(t *testing.T) {
+	Convey("测试 Mongo FindStream 方法", t, func() {
+		mongo, err := NewMongoWithOptions(testMongoOptions)
+		So(err, ShouldBeNil)
+		defer mongo.Close()
+
+		ctx := context.Background()
+		defer mongo.DropTable(ctx, "test_stream_users")
+
+		for i := 1; i <= 5; i++ {
+			record := mongo.builder.FromStruct(TestMongoUser{UserID: i, Name: "user", Age: 20 + i})
+			So(mongo.Create(ctx, "test_stream_users", record), ShouldBeNil)
+		}
+
+		Convey("按批拉取全部文档", func() {
+			rangeQuery := &query.RangeQuery{Field: "age", Gte: 22}
+			cursor, err := mongo.FindStream(ctx, "test_stream_users", rangeQuery, func(opts *QueryOptions) {
+				opts.OrderBy = "user_id"
+			}, WithBatchSize(2))
+			So(err, ShouldBeNil)
+			defer cursor.Close()
+
+			var ids []int
+			for cursor.Next() {
+				var user TestMongoUser
+				So(cursor.Scan(&user), ShouldBeNil)
+				ids = append(ids, user.UserID)
+			}
+			So(cursor.Err(), ShouldBeNil)
+			So(ids, ShouldResemble, []int{2, 3, 4, 5})
+		})
+	})
+}
+
+func TestMongoCountExists(t *testing.T) {
+	Convey("测试 Mongo Count 和 Exists 方法", t, func() {
+		mongo, err := NewMongoWithOptions(testMongoOptions)
+		So(err, ShouldBeNil)
+		defer mongo.Close()
+
+		ctx := context.Background()
+		defer mongo.DropTable(ctx, "test_count_users")
+
+		for i := 1; i <= 5; i++ {
+			record := mongo.builder.FromStruct(TestMongoUser{UserID: i, Name: "user", Age: 20 + i})
+			So(mongo.Create(ctx, "test_count_users", record), ShouldBeNil)
+		}
+
+		count, err := mongo.Count(ctx, "test_count_users", &query.RangeQuery{Field: "age", Gte: 23})
+		So(err, ShouldBeNil)
+		So(count, ShouldEqual, 3)
+
+		exists, err := mongo.Exists(ctx, "test_count_users", map[string]any{"user_id": 3})
+		So(err, ShouldBeNil)
+		So(exists, ShouldBeTrue)
+
+		exists, err = mongo.Exists(ctx, "test_count_users", map[string]any{"user_id": 100})
+		So(err, ShouldBeNil)
+		So(exists, ShouldBeFalse)
+	})
+}
+
+func TestMongoUpdatePartialIncrement(t *testing.T) {
+	Convey("测试 Mongo UpdatePartial 和 Increment 方法", t, func() {
+		mongo, err := NewMongoWithOptions(testMongoOptions)
+		So(err, ShouldBeNil)
+		defer mongo.Close()
+
+		ctx := context.Background()
+		defer mongo.DropTable(ctx, "test_partial_users")
+
+		record := mongo.builder.FromStruct(TestMongoUser{UserID: 1, Name: "alice", Age: 20})
+		So(mongo.Create(ctx, "test_partial_users", record), ShouldBeNil)
+		pk := map[string]any{"user_id": 1}
+
+		So(mongo.UpdatePartial(ctx, "test_partial_users", pk, map[string]any{"name": "bob"}), ShouldBeNil)
+		So(mongo.Increment(ctx, "test_partial_users", pk, "age", 5), ShouldBeNil)
+
+		var user TestMongoUser
+		got, err := mongo.Get(ctx, "test_partial_users", pk)
+		So(err, ShouldBeNil)
+		So(got.Scan(&user), ShouldBeNil)
+		So(user.Name, ShouldEqual, "bob")
+		So(user.Age, ShouldEqual, 25)
+
+		err = mongo.Increment(ctx, "test_partial_users", map[string]any{"user_id": 100}, "age", 1)
+		So(err, ShouldEqual, ErrRecordNotFound)
+	})
+}
+
+func TestMongoMigrateDiff(t *testing.T) {
+	Convey("测试 Mongo MigrateDiff 方法", t, func() {
+		mongo, err := NewMongoWithOptions(testMongoOptions)
+		So(err, ShouldBeNil)
+		defer mongo.Close()
+
+		ctx := context.Background()
+		defer mongo.DropTable(ctx, "test_diff_users")
+
+		model := &TableModel{
+			Table:   "test_diff_users",
+			Indexes: []IndexDefinition{{Name: "idx_diff_users_email", Fields: []string{"email"}, Unique: true}},
+		}
+
+		statements, err := mongo.MigrateDiff(ctx, model, WithDryRun())
+		So(err, ShouldBeNil)
+		So(statements, ShouldResemble, []string{
+			`db.createCollection("test_diff_users")`,
+			`db.test_diff_users.createIndex({"email": 1}, {"name": "idx_diff_users_email", "unique": true})`,
+		})
+
+		_, err = mongo.MigrateDiff(ctx, model)
+		So(err, ShouldBeNil)
+
+		statements, err = mongo.MigrateDiff(ctx, model)
+		So(err, ShouldBeNil)
+		So(statements, ShouldBeEmpty)
+	})
+}
+
+func TestMongoHealth(t *testing.T) {
+	Convey("测试 Mongo 健康检查和重连", t, func() {
+		options := *testMongoOptions
+		options.HealthCheckInterval = time.Second
+		mongo, err := NewMongoWithOptions(&options)
+		So(err, ShouldBeNil)
+		defer mongo.Close()
+
+		ctx := context.Background()
+		So(mongo.Health(ctx), ShouldBeNil)
+
+		// 重建客户端后继续可用
+		old := mongo.getClient()
+		mongo.reconnect(ctx)
+		So(mongo.getClient(), ShouldNotEqual, old)
+		So(mongo.Health(ctx), ShouldBeNil)
+
+		exists, err := mongo.Exists(ctx, "test_health_users", map[string]any{"user_id": 1})
+		So(err, ShouldBeNil)
+		So(exists, ShouldBeFalse)
+	})
+
+	Convey("测试 Mongo 启动时连接重试", t, func() {
+		start := time.Now()
+		_, err := NewMongoWithOptions(&MongoOptions{
+			Host:    "127.0.0.1",
+			Port:    1,
+			Timeout: 100 * time.Millisecond,
+			Retry:   RetryOptions{MaxAttempts: 3, Backoff: 50 * time.Millisecond},
+		})
+		So(err, ShouldNotBeNil)
+		So(time.Since(start), ShouldBeGreaterThanOrEqualTo, 150*time.Millisecond)
+	})
+}
+
+func TestMongoOptimisticLock(t *testing.T) {
+	Convey("测试 Mongo 乐观锁", t, func() {
+		mongo, err := NewMongoWithOptions(testMongoOptions)
+		So(err, ShouldBeNil)
+		defer mongo.Close()
+
+		ctx := context.Background()
+		defer mongo.DropTable(ctx, "test_version_users")
+
+		So(mongo.Create(ctx, "test_version_users", mongo.builder.FromMap(map[string]any{"user_id": 1, "name": "alice", "version": 0}, "test_version_users")), ShouldBeNil)
+		pk := map[string]any{"user_id": 1}
+
+		So(mongo.UpdatePartial(ctx, "test_version_users", pk, map[string]any{"name": "bob", "version": 0}, WithVersion("version", 0)), ShouldBeNil)
+		So(mongo.UpdatePartial(ctx, "test_version_users", pk, map[string]any{"name": "carol"}, WithVersion("version", 0)), ShouldEqual, ErrVersionConflict)
+		So(mongo.UpdatePartial(ctx, "test_version_users", map[string]any{"user_id": 2}, map[string]any{"name": "x"}, WithVersion("version", 0)), ShouldEqual, ErrRecordNotFound)
+
+		record, err := mongo.Get(ctx, "test_version_users", pk)
+		So(err, ShouldBeNil)
+		So(record.Fields()["name"], ShouldEqual, "bob")
+		So(record.Fields()["version"], ShouldEqual, 1)
+	})
+}
+
+func TestMongoMigrateViews(t *testing.T) {
+	Convey("测试 Mongo 视图迁移", t, func() {
+		mongo, err := NewMongoWithOptions(testMongoOptions)
+		So(err, ShouldBeNil)
+		defer mongo.Close()
+
+		ctx := context.Background()
+		defer mongo.DropTable(ctx, "test_view_orders")
+		defer mongo.DropTable(ctx, "test_view_paid_orders")
+
+		model := &TableModel{
+			Table: "test_view_orders",
+			Views: []ViewDefinition{
+				{Name: "test_view_paid_orders", Pipeline: []map[string]any{{"$match": map[string]any{"status": "paid"}}}},
+			},
+		}
+		So(mongo.Migrate(ctx, model), ShouldBeNil)
+
+		for i, status := range []string{"paid", "paid", "created"} {
+			So(mongo.Create(ctx, "test_view_orders", mongo.builder.FromMap(map[string]any{"order_id": i + 1, "status": status}, "test_view_orders")), ShouldBeNil)
+		}
+
+		count, err := mongo.Count(ctx, "test_view_paid_orders", &query.ExistsQuery{Field: "status"})
+		So(err, ShouldBeNil)
+		So(count, ShouldEqual, 2)
+
+		// 重复迁移时替换聚合管道
+		model.Views[0].Pipeline = []map[string]any{{"$match": map[string]any{"status": "created"}}}
+		So(mongo.Migrate(ctx, model), ShouldBeNil)
+		count, err = mongo.Count(ctx, "test_view_paid_orders", &query.ExistsQuery{Field: "status"})
+		So(err, ShouldBeNil)
+		So(count, ShouldEqual, 1)
+
+		// 与普通集合重名
+		model.Views[0].Name = "test_view_orders"
+		So(mongo.Migrate(ctx, model), ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,173 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/hatlonely/gox/rdb/query"
+)
+
+// mongoChangeEvent change stream 事件中用到的字段
+type mongoChangeEvent struct {
+	OperationType string              `bson:"operationType"`
+	FullDocument  bson.M              `bson:"fullDocument"`
+	DocumentKey   bson.M              `bson:"documentKey"`
+	ClusterTime   primitive.Timestamp `bson:"clusterTime"`
+}
+
+// Watch 基于 change stream 订阅集合的变更，需要副本集或分片集群
+// 更新事件通过 updateLookup 带上变更后的完整文档，query 作用于该文档；订阅期间算作进行中的操作
+func (m *Mongo) Watch(ctx context.Context, table string, query query.Query, opts ...WatchOption) (<-chan ChangeEvent, error) {
+	ctx, done, err := m.ops.enter(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pipeline, err := mongoWatchPipeline(query)
+	if err != nil {
+		done()
+		return nil, err
+	}
+
+	watchOpts := newWatchOptions(opts)
+	streamOptions := mongoChangeStreamOptions(watchOpts)
+	stream, err := m.getDatabase().Collection(table).Watch(ctx, pipeline, streamOptions)
+	if err != nil {
+		done()
+		return nil, fmt.Errorf("failed to watch collection %s: %v", table, err)
+	}
+
+	events := make(chan ChangeEvent, watchOpts.BufferSize)
+	go func() {
+		defer done()
+		defer close(events)
+		defer stream.Close(context.Background())
+
+		send := func(event ChangeEvent) bool {
+			select {
+			case events <- event:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		for stream.Next(ctx) {
+			var raw mongoChangeEvent
+			if err := stream.Decode(&raw); err != nil {
+				send(ChangeEvent{Table: table, Err: fmt.Errorf("failed to decode change event: %v", err)})
+				return
+			}
+			if raw.OperationType == "invalidate" {
+				send(ChangeEvent{Table: table, Err: fmt.Errorf("change stream of %s invalidated", table)})
+				return
+			}
+			if !send(mongoChangeToEvent(table, raw, stream.ResumeToken(), m.builder)) {
+				return
+			}
+		}
+		if err := stream.Err(); err != nil && ctx.Err() == nil {
+			send(ChangeEvent{Table: table, Err: err})
+		}
+	}()
+
+	return events, nil
+}
+
+// mongoChangeStreamOptions 将订阅选项转换为 change stream 选项
+func mongoChangeStreamOptions(opts *WatchOptions) *options.ChangeStreamOptions {
+	streamOptions := options.ChangeStream().SetFullDocument(options.UpdateLookup)
+	if len(opts.ResumeToken) > 0 {
+		streamOptions.SetResumeAfter(bson.Raw(opts.ResumeToken))
+	}
+	return streamOptions
+}
+
+func mongoChangeToEvent(table string, raw mongoChangeEvent, token bson.Raw, builder *MongoRecordBuilder) ChangeEvent {
+	event := ChangeEvent{
+		Table:       table,
+		PK:          map[string]any(raw.DocumentKey),
+		Time:        time.Unix(int64(raw.ClusterTime.T), 0),
+		ResumeToken: append([]byte(nil), token...),
+	}
+	switch raw.OperationType {
+	case "insert":
+		event.Type = ChangeInsert
+	case "delete":
+		event.Type = ChangeDelete
+	default:
+		event.Type = ChangeUpdate
+	}
+	// 更新后文档又被删除时 updateLookup 拿不到文档
+	if raw.FullDocument != nil {
+		event.Record = builder.newRecord(raw.FullDocument)
+	}
+	return event
+}
+
+// mongoWatchPipeline 只订阅增删改事件，query 中的字段对应 change stream 事件的 fullDocument，删除事件不过滤
+func mongoWatchPipeline(query query.Query) (mongo.Pipeline, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"operationType": bson.M{"$in": bson.A{"insert", "update", "replace", "delete", "invalidate"}}}}},
+	}
+	if query == nil {
+		return pipeline, nil
+	}
+
+	filter, err := query.ToMongo()
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert query to mongo: %v", err)
+	}
+	if len(filter) == 0 {
+		return pipeline, nil
+	}
+	return append(pipeline, bson.D{{Key: "$match", Value: bson.M{"$or": bson.A{
+		bson.M{"operationType": bson.M{"$in": bson.A{"delete", "invalidate"}}},
+		prefixMongoFilter(filter, "fullDocument."),
+	}}}}), nil
+}
+
+// prefixMongoFilter 为过滤条件中的字段名加上前缀，递归处理 $and、$or、$nor
+func prefixMongoFilter(filter map[string]interface{}, prefix string) map[string]interface{} {
+	result := make(map[string]interface{}, len(filter))
+	for key, value := range filter {
+		if !strings.HasPrefix(key, "$") {
+			result[prefix+key] = value
+			continue
+		}
+		switch key {
+		case "$and", "$or", "$nor":
+			rv := reflect.ValueOf(value)
+			if rv.Kind() != reflect.Slice {
+				result[key] = value
+				continue
+			}
+			conditions := make([]interface{}, 0, rv.Len())
+			for i := 0; i < rv.Len(); i++ {
+				conditions = append(conditions, prefixMongoCondition(rv.Index(i).Interface(), prefix))
+			}
+			result[key] = conditions
+		default:
+			result[key] = value
+		}
+	}
+	return result
+}
+
+func prefixMongoCondition(condition interface{}, prefix string) interface{} {
+	switch c := condition.(type) {
+	case map[string]interface{}:
+		return prefixMongoFilter(c, prefix)
+	case bson.M:
+		return prefixMongoFilter(c, prefix)
+	}
+	return condition
+}
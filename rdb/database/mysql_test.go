@@ -179,7 +179,7 @@ func TestStructToMap(t *testing.T) {
 				Age:   30,
 			}
 
-			result := structToMap(user)
+			result := structToMap(user, DefaultTagName)
 			So(result["id"], ShouldEqual, 1)
 			So(result["name"], ShouldEqual, "John Doe")
 			So(result["email"], ShouldEqual, "john@example.com")
@@ -192,13 +192,13 @@ func TestStructToMap(t *testing.T) {
 				Name: "John Doe",
 			}
 
-			result := structToMap(user)
+			result := structToMap(user, DefaultTagName)
 			So(result["id"], ShouldEqual, 1)
 			So(result["name"], ShouldEqual, "John Doe")
 		})
 
 		Convey("非结构体类型", func() {
-			result := structToMap("not a struct")
+			result := structToMap("not a struct", DefaultTagName)
 			So(len(result), ShouldEqual, 0)
 		})
 	})
@@ -217,7 +217,7 @@ func TestMapToStruct(t *testing.T) {
 			}
 
 			var user TestUser
-			err := mapToStruct(data, &user)
+			err := mapToStruct(data, &user, DefaultTagName)
 			So(err, ShouldBeNil)
 			So(user.ID, ShouldEqual, 1)
 			So(user.Name, ShouldEqual, "John Doe")
@@ -230,14 +230,14 @@ func TestMapToStruct(t *testing.T) {
 		Convey("目标不是指针", func() {
 			data := map[string]any{"id": 1}
 			var user TestUser
-			err := mapToStruct(data, user)
+			err := mapToStruct(data, user, DefaultTagName)
 			So(err, ShouldNotBeNil)
 		})
 
 		Convey("目标不是结构体指针", func() {
 			data := map[string]any{"value": 1}
 			var value int
-			err := mapToStruct(data, &value)
+			err := mapToStruct(data, &value, DefaultTagName)
 			So(err, ShouldNotBeNil)
 		})
 	})
@@ -960,17 +960,18 @@ func TestSQLBuildMethods(t *testing.T) {
 				PrimaryKey: []string{"id"},
 			}
 
-			sqlStr := sql.buildCreateTableSQL(model)
-			So(sqlStr, ShouldContainSubstring, "CREATE TABLE IF NOT EXISTS test_build_table")
-			So(sqlStr, ShouldContainSubstring, "id INT NOT NULL")
-			So(sqlStr, ShouldContainSubstring, "name VARCHAR(100) NOT NULL")
-			So(sqlStr, ShouldContainSubstring, "email VARCHAR(255)")
-			So(sqlStr, ShouldContainSubstring, "age INT DEFAULT 0")
-			So(sqlStr, ShouldContainSubstring, "active BOOLEAN DEFAULT 1")
-			So(sqlStr, ShouldContainSubstring, "score FLOAT")
-			So(sqlStr, ShouldContainSubstring, "data JSON")
-			So(sqlStr, ShouldContainSubstring, "created_at DATETIME")
-			So(sqlStr, ShouldContainSubstring, "PRIMARY KEY (id)")
+			sqlStr, err := sql.dialect().buildCreateTableSQL(model)
+			So(err, ShouldBeNil)
+			So(sqlStr, ShouldContainSubstring, "CREATE TABLE IF NOT EXISTS `test_build_table`")
+			So(sqlStr, ShouldContainSubstring, "`id` INT NOT NULL")
+			So(sqlStr, ShouldContainSubstring, "`name` VARCHAR(100) NOT NULL")
+			So(sqlStr, ShouldContainSubstring, "`email` VARCHAR(255)")
+			So(sqlStr, ShouldContainSubstring, "`age` INT DEFAULT 0")
+			So(sqlStr, ShouldContainSubstring, "`active` BOOLEAN DEFAULT 1")
+			So(sqlStr, ShouldContainSubstring, "`score` FLOAT")
+			So(sqlStr, ShouldContainSubstring, "`data` JSON")
+			So(sqlStr, ShouldContainSubstring, "`created_at` DATETIME")
+			So(sqlStr, ShouldContainSubstring, "PRIMARY KEY (`id`)")
 		})
 
 		Convey("测试 buildColumnDefinition", func() {
@@ -982,27 +983,27 @@ func TestSQLBuildMethods(t *testing.T) {
 				Default:  "default_value",
 			}
 
-			columnDef := sql.buildColumnDefinition(field)
-			So(columnDef, ShouldEqual, "test_field VARCHAR(50) NOT NULL DEFAULT 'default_value'")
+			columnDef := sql.dialect().buildColumnDefinition(field)
+			So(columnDef, ShouldEqual, "`test_field` VARCHAR(50) NOT NULL DEFAULT 'default_value'")
 		})
 
 		Convey("测试 mapFieldTypeToSQL", func() {
-			So(sql.mapFieldTypeToSQL(FieldTypeString, 100), ShouldEqual, "VARCHAR(100)")
-			So(sql.mapFieldTypeToSQL(FieldTypeString, 0), ShouldEqual, "VARCHAR(255)")
-			So(sql.mapFieldTypeToSQL(FieldTypeInt, 0), ShouldEqual, "INT")
-			So(sql.mapFieldTypeToSQL(FieldTypeFloat, 0), ShouldEqual, "FLOAT")
-			So(sql.mapFieldTypeToSQL(FieldTypeBool, 0), ShouldEqual, "BOOLEAN")
-			So(sql.mapFieldTypeToSQL(FieldTypeDate, 0), ShouldEqual, "DATETIME")
-			So(sql.mapFieldTypeToSQL(FieldTypeJSON, 0), ShouldEqual, "JSON")
+			So(sql.dialect().mapFieldTypeToSQL(FieldTypeString, 100), ShouldEqual, "VARCHAR(100)")
+			So(sql.dialect().mapFieldTypeToSQL(FieldTypeString, 0), ShouldEqual, "VARCHAR(255)")
+			So(sql.dialect().mapFieldTypeToSQL(FieldTypeInt, 0), ShouldEqual, "INT")
+			So(sql.dialect().mapFieldTypeToSQL(FieldTypeFloat, 0), ShouldEqual, "FLOAT")
+			So(sql.dialect().mapFieldTypeToSQL(FieldTypeBool, 0), ShouldEqual, "BOOLEAN")
+			So(sql.dialect().mapFieldTypeToSQL(FieldTypeDate, 0), ShouldEqual, "DATETIME")
+			So(sql.dialect().mapFieldTypeToSQL(FieldTypeJSON, 0), ShouldEqual, "JSON")
 		})
 
 		Convey("测试 formatDefaultValue", func() {
-			So(sql.formatDefaultValue("test"), ShouldEqual, "'test'")
-			So(sql.formatDefaultValue("test's"), ShouldEqual, "'test''s'")
-			So(sql.formatDefaultValue(true), ShouldEqual, "1")
-			So(sql.formatDefaultValue(false), ShouldEqual, "0")
-			So(sql.formatDefaultValue(123), ShouldEqual, "123")
-			So(sql.formatDefaultValue(12.34), ShouldEqual, "12.34")
+			So(sql.dialect().formatDefaultValue("test"), ShouldEqual, "'test'")
+			So(sql.dialect().formatDefaultValue("test's"), ShouldEqual, "'test''s'")
+			So(sql.dialect().formatDefaultValue(true), ShouldEqual, "1")
+			So(sql.dialect().formatDefaultValue(false), ShouldEqual, "0")
+			So(sql.dialect().formatDefaultValue(123), ShouldEqual, "123")
+			So(sql.dialect().formatDefaultValue(12.34), ShouldEqual, "12.34")
 		})
 
 		Convey("测试 buildCreateIndexSQL", func() {
@@ -1011,16 +1012,18 @@ func TestSQLBuildMethods(t *testing.T) {
 				Fields: []string{"name", "age"},
 				Unique: false,
 			}
-			indexSQL := sql.buildCreateIndexSQL("test_table", index)
-			So(indexSQL, ShouldEqual, "CREATE INDEX idx_test ON test_table (name, age)")
+			indexSQL, err := sql.dialect().buildCreateIndexSQL("test_table", index)
+			So(err, ShouldBeNil)
+			So(indexSQL, ShouldEqual, "CREATE INDEX `idx_test` ON `test_table` (`name`, `age`)")
 
 			uniqueIndex := IndexDefinition{
 				Name:   "idx_unique_email",
 				Fields: []string{"email"},
 				Unique: true,
 			}
-			uniqueIndexSQL := sql.buildCreateIndexSQL("test_table", uniqueIndex)
-			So(uniqueIndexSQL, ShouldEqual, "CREATE UNIQUE INDEX idx_unique_email ON test_table (email)")
+			uniqueIndexSQL, err := sql.dialect().buildCreateIndexSQL("test_table", uniqueIndex)
+			So(err, ShouldBeNil)
+			So(uniqueIndexSQL, ShouldEqual, "CREATE UNIQUE INDEX `idx_unique_email` ON `test_table` (`email`)")
 		})
 	})
 }
@@ -1132,7 +1135,7 @@ func TestSQLErrorHandling(t *testing.T) {
 			var user TestUser
 			// 这个测试可能会成功，因为 Go 的反射会尝试类型转换
 			// 但我们至少验证了函数不会 panic
-			So(func() { mapToStruct(data, &user) }, ShouldNotPanic)
+			So(func() { mapToStruct(data, &user, DefaultTagName) }, ShouldNotPanic)
 		})
 	})
 }
@@ -1146,7 +1149,7 @@ func TestSQLEdgeCases(t *testing.T) {
 		Convey("测试空字段的结构体", func() {
 			type EmptyStruct struct{}
 			empty := EmptyStruct{}
-			result := structToMap(empty)
+			result := structToMap(empty, DefaultTagName)
 			So(len(result), ShouldEqual, 0)
 		})
 
@@ -1163,7 +1166,7 @@ func TestSQLEdgeCases(t *testing.T) {
 				Name:         "test",
 			}
 
-			result := structToMap(s)
+			result := structToMap(s, DefaultTagName)
 			So(result["id"], ShouldEqual, 1)
 			So(result["name"], ShouldEqual, "test")
 			So(result["privateField"], ShouldBeNil) // 未导出字段不应该被包含
@@ -1182,7 +1185,7 @@ func TestSQLEdgeCases(t *testing.T) {
 				Name:    "test",
 			}
 
-			result := structToMap(s)
+			result := structToMap(s, DefaultTagName)
 			So(result["id"], ShouldEqual, 1)
 			So(result["name"], ShouldEqual, "test")
 			_, exists := result["Ignored"]
@@ -1349,3 +1352,183 @@ func TestSQLDropTable(t *testing.T) {
 		})
 	})
 }
+
+func TestSQLStats(t *testing.T) {
+	Convey("测试 SQL Stats 方法", t, func() {
+		sql, err := NewSQLWithOptions(testMySQLOptions)
+		So(err, ShouldBeNil)
+		defer sql.Close()
+
+		ctx := context.Background()
+		model := &TableModel{
+			Table: "test_stats_users",
+			Fields: []FieldDefinition{
+				{Name: "id", Type: FieldTypeInt, Required: true},
+				{Name: "name", Type: FieldTypeString, Size: 100, Required: true},
+			},
+			PrimaryKey: []string{"id"},
+		}
+		So(sql.Migrate(ctx, model), ShouldBeNil)
+		defer sql.DropTable(ctx, "test_stats_users")
+
+		Convey("统计存在的表", func() {
+			stats, err := sql.Stats(ctx, "test_stats_users")
+			So(err, ShouldBeNil)
+			So(stats.RowCount, ShouldBeGreaterThanOrEqualTo, 0)
+			So(stats.DataSize, ShouldBeGreaterThan, 0)
+		})
+
+		Convey("表不存在", func() {
+			_, err := sql.Stats(ctx, "test_stats_not_exists")
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestSQLFindStream(t *testing.T) {
+	Convey("测试 SQL FindStream 方法", t, func() {
+		sql, err := NewSQLWithOptions(testMySQLOptions)
+		So(err, ShouldBeNil)
+		defer sql.Close()
+
+		ctx := context.Background()
+		model := &TableModel{
+			Table: "test_stream_users",
+			Fields: []FieldDefinition{
+				{Name: "id", Type: FieldTypeInt, Required: true},
+				{Name: "name", Type: FieldTypeString, Size: 100, Required: true},
+			},
+			PrimaryKey: []string{"id"},
+		}
+		So(sql.Migrate(ctx, model), ShouldBeNil)
+		defer sql.DropTable(ctx, "test_stream_users")
+
+		for i := 1; i <= 3; i++ {
+			record := sql.builder.FromMap(map[string]any{"id": i, "name": "user"}, "test_stream_users")
+			So(sql.Create(ctx, "test_stream_users", record), ShouldBeNil)
+		}
+
+		cursor, err := sql.FindStream(ctx, "test_stream_users", &query.TermQuery{Field: "name", Value: "user"})
+		So(err, ShouldBeNil)
+		defer cursor.Close()
+
+		count := 0
+		for cursor.Next() {
+			count++
+		}
+		So(cursor.Err(), ShouldBeNil)
+		So(count, ShouldEqual, 3)
+	})
+}
+
+func TestSQLCountExists(t *testing.T) {
+	Convey("测试 SQL Count 和 Exists 方法", t, func() {
+		sql, err := NewSQLWithOptions(testMySQLOptions)
+		So(err, ShouldBeNil)
+		defer sql.Close()
+
+		ctx := context.Background()
+		model := &TableModel{
+			Table: "test_count_users",
+			Fields: []FieldDefinition{
+				{Name: "id", Type: FieldTypeInt, Required: true},
+				{Name: "name", Type: FieldTypeString, Size: 100, Required: true},
+			},
+			PrimaryKey: []string{"id"},
+		}
+		So(sql.Migrate(ctx, model), ShouldBeNil)
+		defer sql.DropTable(ctx, "test_count_users")
+
+		for i := 1; i <= 3; i++ {
+			record := sql.builder.FromMap(map[string]any{"id": i, "name": "user"}, "test_count_users")
+			So(sql.Create(ctx, "test_count_users", record), ShouldBeNil)
+		}
+
+		count, err := sql.Count(ctx, "test_count_users", &query.TermQuery{Field: "name", Value: "user"})
+		So(err, ShouldBeNil)
+		So(count, ShouldEqual, 3)
+
+		exists, err := sql.Exists(ctx, "test_count_users", map[string]any{"id": 2})
+		So(err, ShouldBeNil)
+		So(exists, ShouldBeTrue)
+
+		exists, err = sql.Exists(ctx, "test_count_users", map[string]any{"id": 100})
+		So(err, ShouldBeNil)
+		So(exists, ShouldBeFalse)
+	})
+}
+
+func TestSQLUpdatePartialIncrement(t *testing.T) {
+	Convey("测试 SQL UpdatePartial 和 Increment 方法", t, func() {
+		sql, err := NewSQLWithOptions(testMySQLOptions)
+		So(err, ShouldBeNil)
+		defer sql.Close()
+
+		ctx := context.Background()
+		model := &TableModel{
+			Table: "test_partial_users",
+			Fields: []FieldDefinition{
+				{Name: "id", Type: FieldTypeInt, Required: true},
+				{Name: "name", Type: FieldTypeString, Size: 100, Required: true},
+				{Name: "age", Type: FieldTypeInt},
+			},
+			PrimaryKey: []string{"id"},
+		}
+		So(sql.Migrate(ctx, model), ShouldBeNil)
+		defer sql.DropTable(ctx, "test_partial_users")
+
+		record := sql.builder.FromMap(map[string]any{"id": 1, "name": "alice", "age": 20}, "test_partial_users")
+		So(sql.Create(ctx, "test_partial_users", record), ShouldBeNil)
+		pk := map[string]any{"id": 1}
+
+		So(sql.UpdatePartial(ctx, "test_partial_users", pk, map[string]any{"name": "bob"}), ShouldBeNil)
+		So(sql.Increment(ctx, "test_partial_users", pk, "age", 5), ShouldBeNil)
+
+		var user struct {
+			Name string `rdb:"name"`
+			Age  int    `rdb:"age"`
+		}
+		got, err := sql.Get(ctx, "test_partial_users", pk)
+		So(err, ShouldBeNil)
+		So(got.Scan(&user), ShouldBeNil)
+		So(user.Name, ShouldEqual, "bob")
+		So(user.Age, ShouldEqual, 25)
+	})
+}
+
+func TestSQLMigrateDiff(t *testing.T) {
+	Convey("测试 SQL MigrateDiff 方法", t, func() {
+		sql, err := NewSQLWithOptions(testMySQLOptions)
+		So(err, ShouldBeNil)
+		defer sql.Close()
+
+		ctx := context.Background()
+		model := &TableModel{
+			Table: "test_diff_users",
+			Fields: []FieldDefinition{
+				{Name: "id", Type: FieldTypeInt, Required: true},
+				{Name: "name", Type: FieldTypeString, Size: 100},
+			},
+			PrimaryKey: []string{"id"},
+		}
+		So(sql.Migrate(ctx, model), ShouldBeNil)
+		defer sql.DropTable(ctx, "test_diff_users")
+
+		model.Fields = append(model.Fields, FieldDefinition{Name: "age", Type: FieldTypeInt})
+		model.Indexes = []IndexDefinition{{Name: "idx_diff_users_age", Fields: []string{"age"}}}
+
+		statements, err := sql.MigrateDiff(ctx, model, WithDryRun())
+		So(err, ShouldBeNil)
+		So(statements, ShouldResemble, []string{
+			"ALTER TABLE `test_diff_users` ADD COLUMN `age` INT",
+			"CREATE INDEX `idx_diff_users_age` ON `test_diff_users` (`age`)",
+		})
+
+		_, err = sql.MigrateDiff(ctx, model)
+		So(err, ShouldBeNil)
+
+		statements, err = sql.MigrateDiff(ctx, model)
+		So(err, ShouldBeNil)
+		So(statements, ShouldBeEmpty)
+	})
+}
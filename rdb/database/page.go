@@ -0,0 +1,322 @@
+package database
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/hatlonely/gox/rdb/query"
+)
+
+// ErrInvalidCursor 分页游标无法解析或与当前的排序方式不匹配
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// defaultPageSize 未指定每页记录数时的默认值
+const defaultPageSize = 20
+
+// PageRequest 分页请求
+type PageRequest struct {
+	Size      int    // 每页记录数，默认 20
+	Cursor    string // 上一页返回的 NextCursor，为空时查询第一页
+	OrderBy   string // 排序字段，为空时按 Key 排序
+	OrderDesc bool   // 是否降序
+	Key       string // 唯一键字段，作为 OrderBy 的次排序字段保证翻页不重不漏，默认 id
+	SkipTotal bool   // 不统计总数，省去一次 Count 查询，此时 Total 为 -1
+}
+
+// PageResult 分页结果
+type PageResult struct {
+	Records    []Record
+	Total      int64  // 满足查询条件的记录总数
+	NextCursor string // 下一页的游标，没有更多记录时为空
+}
+
+// FindPage 基于游标的分页查询
+// 翻页时从上一页最后一条记录的 (OrderBy, Key) 之后继续查询，SQL 和 Mongo 转换为键集条件，ES 使用 search_after，
+// 查询代价与页码无关，也不会因为翻页期间的写入而重复或遗漏记录
+// 游标是不透明的字符串，只能用于相同 OrderBy、Key 和排序方向的请求
+func FindPage(ctx context.Context, db Database, table string, q query.Query, page PageRequest) (*PageResult, error) {
+	if page.Size <= 0 {
+		page.Size = defaultPageSize
+	}
+	if page.Key == "" {
+		page.Key = "id"
+	}
+	if page.OrderBy == "" {
+		page.OrderBy = page.Key
+	}
+
+	options := &QueryOptions{
+		Limit:     page.Size + 1, // 多查一条判断是否还有下一页
+		OrderBy:   page.OrderBy,
+		OrderDesc: page.OrderDesc,
+	}
+	if page.Key != page.OrderBy {
+		options.ThenBy = page.Key
+	}
+	if page.Cursor != "" {
+		values, err := decodePageCursor(page)
+		if err != nil {
+			return nil, err
+		}
+		options.SearchAfter = values
+	}
+
+	records, err := db.Find(ctx, table, q, func(opts *QueryOptions) { *opts = *options })
+	if err != nil {
+		return nil, err
+	}
+
+	result := &PageResult{Records: records, Total: -1}
+	if len(records) > page.Size {
+		result.Records = records[:page.Size]
+		if result.NextCursor, err = encodePageCursor(page, options, result.Records[page.Size-1]); err != nil {
+			return nil, err
+		}
+	}
+
+	if !page.SkipTotal {
+		if result.Total, err = db.Count(ctx, table, q); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// pageCursor 游标中记录排序方式，防止游标被用于其他排序方式的请求
+type pageCursor struct {
+	OrderBy string        `json:"o"`
+	Key     string        `json:"k"`
+	Desc    bool          `json:"d,omitempty"`
+	Values  []cursorValue `json:"v"`
+}
+
+// cursorValue 带类型的排序值，JSON 反序列化后可以还原为原始类型
+type cursorValue struct {
+	Type  string          `json:"t"`
+	Value json.RawMessage `json:"v"`
+}
+
+func encodePageCursor(page PageRequest, options *QueryOptions, last Record) (string, error) {
+	fields := last.Fields()
+	cursor := pageCursor{OrderBy: page.OrderBy, Key: page.Key, Desc: page.OrderDesc}
+	for _, field := range options.sortFields() {
+		value, err := newCursorValue(field, fields[field])
+		if err != nil {
+			return "", err
+		}
+		cursor.Values = append(cursor.Values, value)
+	}
+
+	data, err := json.Marshal(cursor)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal cursor: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodePageCursor(page PageRequest) ([]any, error) {
+	data, err := base64.RawURLEncoding.DecodeString(page.Cursor)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	var cursor pageCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	if cursor.OrderBy != page.OrderBy || cursor.Key != page.Key || cursor.Desc != page.OrderDesc {
+		return nil, fmt.Errorf("%w: cursor is for order by %s, %s", ErrInvalidCursor, cursor.OrderBy, cursor.Key)
+	}
+
+	values := make([]any, 0, len(cursor.Values))
+	for _, value := range cursor.Values {
+		v, err := value.decode()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+func newCursorValue(field string, value any) (cursorValue, error) {
+	var typ string
+	switch v := value.(type) {
+	case nil:
+		return cursorValue{}, fmt.Errorf("sort field %s of the last record is null", field)
+	case time.Time:
+		typ, value = "time", v.Format(time.RFC3339Nano)
+	case primitive.DateTime:
+		typ, value = "time", v.Time().Format(time.RFC3339Nano)
+	case primitive.ObjectID:
+		typ, value = "oid", v.Hex()
+	case []byte:
+		typ, value = "string", string(v)
+	default:
+		switch reflect.ValueOf(v).Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			typ = "int"
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			typ = "uint"
+		case reflect.Float32, reflect.Float64:
+			typ = "float"
+		case reflect.String:
+			typ = "string"
+		case reflect.Bool:
+			typ = "bool"
+		default:
+			return cursorValue{}, fmt.Errorf("unsupported type %T of sort field %s", value, field)
+		}
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return cursorValue{}, fmt.Errorf("failed to marshal sort field %s: %v", field, err)
+	}
+	return cursorValue{Type: typ, Value: data}, nil
+}
+
+func (v cursorValue) decode() (any, error) {
+	var err error
+	switch v.Type {
+	case "int":
+		var i int64
+		err = json.Unmarshal(v.Value, &i)
+		return i, err
+	case "uint":
+		var u uint64
+		err = json.Unmarshal(v.Value, &u)
+		return u, err
+	case "float":
+		var f float64
+		err = json.Unmarshal(v.Value, &f)
+		return f, err
+	case "string":
+		var s string
+		err = json.Unmarshal(v.Value, &s)
+		return s, err
+	case "bool":
+		var b bool
+		err = json.Unmarshal(v.Value, &b)
+		return b, err
+	case "time":
+		var t time.Time
+		err = json.Unmarshal(v.Value, &t)
+		return t, err
+	case "oid":
+		var hex string
+		if err = json.Unmarshal(v.Value, &hex); err != nil {
+			return nil, err
+		}
+		return primitive.ObjectIDFromHex(hex)
+	}
+	return nil, fmt.Errorf("unknown value type %s", v.Type)
+}
+
+// sortFields 返回排序字段，ThenBy 只在 OrderBy 不为空时生效
+func (o *QueryOptions) sortFields() []string {
+	if o.OrderBy == "" {
+		return nil
+	}
+	if o.ThenBy == "" {
+		return []string{o.OrderBy}
+	}
+	return []string{o.OrderBy, o.ThenBy}
+}
+
+// keysetQuery 将 SearchAfter 转换为键集条件并与 q 合并
+// (a, b) > (x, y) 展开为 a > x OR (a = x AND b > y)，降序时比较方向相反
+func (o *QueryOptions) keysetQuery(q query.Query) query.Query {
+	fields := o.sortFields()
+	if len(o.SearchAfter) == 0 || len(fields) == 0 {
+		return q
+	}
+
+	after := func(field string, value any) query.Query {
+		if o.OrderDesc {
+			return &query.RangeQuery{Field: field, Lt: value}
+		}
+		return &query.RangeQuery{Field: field, Gt: value}
+	}
+
+	var condition query.Query
+	for i := min(len(fields), len(o.SearchAfter)) - 1; i >= 0; i-- {
+		if condition == nil {
+			condition = after(fields[i], o.SearchAfter[i])
+			continue
+		}
+		condition = &query.BoolQuery{Should: []query.Query{
+			after(fields[i], o.SearchAfter[i]),
+			&query.BoolQuery{Must: []query.Query{&query.TermQuery{Field: fields[i], Value: o.SearchAfter[i]}, condition}},
+		}}
+	}
+
+	if q == nil {
+		return condition
+	}
+	return &query.BoolQuery{Must: []query.Query{q, condition}}
+}
+
+// mongoSort 构建排序条件，没有排序字段时返回 nil
+func mongoSort(options *QueryOptions) bson.D {
+	fields := options.sortFields()
+	if len(fields) == 0 && !options.OrderByScore {
+		return nil
+	}
+	direction := 1
+	if options.OrderDesc {
+		direction = -1
+	}
+	sort := make(bson.D, 0, len(fields)+1)
+	if options.OrderByScore {
+		sort = append(sort, bson.E{Key: scoreColumn, Value: bson.M{"$meta": "textScore"}})
+	}
+	for _, field := range fields {
+		sort = append(sort, bson.E{Key: field, Value: direction})
+	}
+	return sort
+}
+
+// esSort 构建排序条件，没有排序字段时返回 nil
+func esSort(options *QueryOptions) []map[string]any {
+	fields := options.sortFields()
+	if len(fields) == 0 && !options.OrderByScore {
+		return nil
+	}
+	order := "asc"
+	if options.OrderDesc {
+		order = "desc"
+	}
+	sort := make([]map[string]any, 0, len(fields)+1)
+	if options.OrderByScore {
+		sort = append(sort, map[string]any{"_score": map[string]any{"order": "desc"}})
+	}
+	for _, field := range fields {
+		sort = append(sort, map[string]any{field: map[string]any{"order": order}})
+	}
+	return sort
+}
+
+// esSearchAfter 将 SearchAfter 转换为 search_after 参数，时间转换为 ES 排序值使用的毫秒时间戳
+func esSearchAfter(values []any) []any {
+	result := make([]any, 0, len(values))
+	for _, value := range values {
+		switch v := value.(type) {
+		case time.Time:
+			result = append(result, v.UnixMilli())
+		case primitive.ObjectID:
+			result = append(result, v.Hex())
+		default:
+			result = append(result, v)
+		}
+	}
+	return result
+}
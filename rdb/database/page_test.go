@@ -0,0 +1,151 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hatlonely/gox/rdb/query"
+	. "github.com/smartystreets/goconvey/convey"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestFindPage(t *testing.T) {
+	Convey("测试游标分页", t, func() {
+		ctx := context.Background()
+		db, err := NewSQLWithOptions(&SQLOptions{Driver: "sqlite3", Database: ":memory:", MaxConns: 1, MaxIdle: 1})
+		So(err, ShouldBeNil)
+		defer db.Close()
+
+		So(db.Migrate(ctx, &TableModel{
+			Table: "page_users",
+			Fields: []FieldDefinition{
+				{Name: "id", Type: FieldTypeInt, Required: true},
+				{Name: "age", Type: FieldTypeInt},
+			},
+			PrimaryKey: []string{"id"},
+		}), ShouldBeNil)
+
+		// age 有大量重复值，依赖 id 作为次排序字段
+		builder := db.GetBuilder()
+		for i := 1; i <= 10; i++ {
+			So(db.Create(ctx, "page_users", builder.FromMap(map[string]any{"id": i, "age": 20 + i%3}, "page_users")), ShouldBeNil)
+		}
+
+		collect := func(page PageRequest, q query.Query) ([]int64, []int64) {
+			var ids, totals []int64
+			for {
+				result, err := FindPage(ctx, db, "page_users", q, page)
+				So(err, ShouldBeNil)
+				So(len(result.Records), ShouldBeLessThanOrEqualTo, page.Size)
+				for _, record := range result.Records {
+					ids = append(ids, record.Fields()["id"].(int64))
+				}
+				totals = append(totals, result.Total)
+				if result.NextCursor == "" {
+					return ids, totals
+				}
+				page.Cursor = result.NextCursor
+			}
+		}
+
+		Convey("按主键升序翻页", func() {
+			ids, totals := collect(PageRequest{Size: 3}, &query.BoolQuery{})
+			So(ids, ShouldResemble, []int64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10})
+			So(totals, ShouldResemble, []int64{10, 10, 10, 10})
+		})
+
+		Convey("按重复值字段降序翻页不重不漏", func() {
+			ids, _ := collect(PageRequest{Size: 4, OrderBy: "age", OrderDesc: true, SkipTotal: true}, &query.BoolQuery{})
+			So(ids, ShouldResemble, []int64{8, 5, 2, 10, 7, 4, 1, 9, 6, 3})
+		})
+
+		Convey("带查询条件翻页", func() {
+			ids, totals := collect(PageRequest{Size: 2, OrderBy: "age"}, &query.RangeQuery{Field: "age", Gte: 21})
+			So(ids, ShouldResemble, []int64{1, 4, 7, 10, 2, 5, 8})
+			So(totals[0], ShouldEqual, 7)
+		})
+
+		Convey("最后一页刚好满页时没有下一页", func() {
+			result, err := FindPage(ctx, db, "page_users", &query.BoolQuery{}, PageRequest{Size: 10, SkipTotal: true})
+			So(err, ShouldBeNil)
+			So(len(result.Records), ShouldEqual, 10)
+			So(result.NextCursor, ShouldBeEmpty)
+			So(result.Total, ShouldEqual, -1)
+		})
+
+		Convey("游标不能用于其他排序方式", func() {
+			result, err := FindPage(ctx, db, "page_users", &query.BoolQuery{}, PageRequest{Size: 3, OrderBy: "age"})
+			So(err, ShouldBeNil)
+
+			_, err = FindPage(ctx, db, "page_users", &query.BoolQuery{}, PageRequest{Size: 3, Cursor: result.NextCursor})
+			So(err, ShouldWrap, ErrInvalidCursor)
+
+			_, err = FindPage(ctx, db, "page_users", &query.BoolQuery{}, PageRequest{Size: 3, Cursor: "not a cursor"})
+			So(err, ShouldWrap, ErrInvalidCursor)
+		})
+	})
+}
+
+func TestPageCursor(t *testing.T) {
+	Convey("测试游标编码", t, func() {
+		Convey("排序值还原为原始类型", func() {
+			oid := primitive.NewObjectID()
+			page := PageRequest{OrderBy: "name", Key: "_id"}
+			options := &QueryOptions{OrderBy: "name", ThenBy: "_id"}
+			cursor, err := encodePageCursor(page, options, &MongoRecord{data: map[string]any{"name": "alice", "_id": oid}})
+			So(err, ShouldBeNil)
+
+			page.Cursor = cursor
+			values, err := decodePageCursor(page)
+			So(err, ShouldBeNil)
+			So(values, ShouldResemble, []any{"alice", oid})
+		})
+
+		Convey("排序值为空时无法生成游标", func() {
+			_, err := encodePageCursor(PageRequest{OrderBy: "id", Key: "id"}, &QueryOptions{OrderBy: "id"}, &MongoRecord{data: map[string]any{}})
+			So(err, ShouldNotBeNil)
+		})
+	})
+
+	Convey("测试键集条件", t, func() {
+		options := &QueryOptions{OrderBy: "age", ThenBy: "id", OrderDesc: true, SearchAfter: []any{int64(20), int64(7)}}
+		sql, args, err := options.keysetQuery(&query.TermQuery{Field: "active", Value: true}).ToSQL()
+		So(err, ShouldBeNil)
+		So(sql, ShouldEqual, "(active = ? AND (age < ? OR (age = ? AND id < ?)))")
+		So(args, ShouldResemble, []any{true, int64(20), int64(20), int64(7)})
+		orderBy, err := sqlDialect("mysql").buildOrderBy(options)
+		So(err, ShouldBeNil)
+		So(orderBy, ShouldEqual, " ORDER BY `age` DESC, `id` DESC")
+	})
+
+	Convey("测试按相关度评分排序", t, func() {
+		sortOptions := &QueryOptions{OrderByScore: true, OrderBy: "id"}
+		So(mongoSort(sortOptions), ShouldResemble, bson.D{
+			{Key: scoreColumn, Value: bson.M{"$meta": "textScore"}},
+			{Key: "id", Value: 1},
+		})
+		So(esSort(sortOptions), ShouldResemble, []map[string]any{
+			{"_score": map[string]any{"order": "desc"}},
+			{"id": map[string]any{"order": "asc"}},
+		})
+
+		// Mongo 只有 $text 查询有评分
+		findOptions := options.Find()
+		scoring, err := mongoScoreProjection(&QueryOptions{Score: true}, &query.FullTextQuery{Text: "golang"}, findOptions)
+		So(err, ShouldBeNil)
+		So(scoring, ShouldBeTrue)
+		So(findOptions.Projection, ShouldResemble, bson.M{scoreColumn: bson.M{"$meta": "textScore"}})
+		_, err = mongoScoreProjection(&QueryOptions{Score: true}, &query.TermQuery{Field: "id", Value: 1}, findOptions)
+		So(err, ShouldEqual, ErrScoreUnsupported)
+		_, err = (&QueryOptions{OrderByScore: true, SearchAfter: []any{1}}).scoring()
+		So(err, ShouldNotBeNil)
+
+		record := (&MongoRecord{data: bson.M{"id": 1, scoreColumn: 1.5}}).takeScore()
+		score, ok := RecordScore(record)
+		So(ok, ShouldBeTrue)
+		So(score, ShouldEqual, 1.5)
+		So(record.Fields(), ShouldResemble, map[string]any{"id": 1})
+	})
+}
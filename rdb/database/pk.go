@@ -0,0 +1,76 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// ErrInvalidPrimaryKey 主键与表模型的主键定义不匹配
+var ErrInvalidPrimaryKey = errors.New("invalid primary key")
+
+// ID 可以作为单列主键的类型
+type ID interface {
+	~int | ~int32 | ~int64 | ~uint | ~uint32 | ~uint64 | ~string | uuid.UUID
+}
+
+// PK 按表模型的主键定义构建主键条件，替代手写 map[string]any{"id": id}
+// 表模型必须是单列主键，且主键字段的类型与 id 一致：整数对应 FieldTypeInt，字符串和 uuid.UUID 对应 FieldTypeString，
+// uuid.UUID 以字符串形式作为条件值，与各后端存储 UUID 的方式保持一致
+func PK[T ID](model *TableModel, id T) (map[string]any, error) {
+	if len(model.PrimaryKey) != 1 {
+		return nil, fmt.Errorf("%w: table %s must have exactly one primary key, got %v", ErrInvalidPrimaryKey, model.Table, model.PrimaryKey)
+	}
+	column := model.PrimaryKey[0]
+
+	var value any = id
+	fieldType := FieldTypeInt
+	if v, ok := any(id).(uuid.UUID); ok {
+		value, fieldType = v.String(), FieldTypeString
+	} else if reflect.ValueOf(id).Kind() == reflect.String {
+		fieldType = FieldTypeString
+	}
+
+	// 主键字段有定义时校验类型，没有定义时（如 ES 的 _id）不做校验
+	for _, field := range model.Fields {
+		if field.Name == column && field.Type != fieldType {
+			return nil, fmt.Errorf("%w: primary key %s of table %s is %s, got %T", ErrInvalidPrimaryKey, column, model.Table, field.Type, id)
+		}
+	}
+
+	return map[string]any{column: value}, nil
+}
+
+// GetByID 按类型化的主键获取记录
+func GetByID[T ID](ctx context.Context, db Database, model *TableModel, id T) (Record, error) {
+	pk, err := PK(model, id)
+	if err != nil {
+		return nil, err
+	}
+	return db.Get(ctx, model.Table, pk)
+}
+
+// DeleteByID 按类型化的主键删除记录
+func DeleteByID[T ID](ctx context.Context, db Database, model *TableModel, id T) error {
+	pk, err := PK(model, id)
+	if err != nil {
+		return err
+	}
+	return db.Delete(ctx, model.Table, pk)
+}
+
+// PKs 批量构建主键条件，用于 BatchDelete 等批量操作
+func PKs[T ID](model *TableModel, ids []T) ([]map[string]any, error) {
+	pks := make([]map[string]any, 0, len(ids))
+	for _, id := range ids {
+		pk, err := PK(model, id)
+		if err != nil {
+			return nil, err
+		}
+		pks = append(pks, pk)
+	}
+	return pks, nil
+}
@@ -0,0 +1,93 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type testPKUserID string
+
+type testPKOrder struct {
+	ID     uuid.UUID `rdb:"id,primary"`
+	Amount int       `rdb:"amount"`
+}
+
+func TestPK(t *testing.T) {
+	Convey("测试类型化主键", t, func() {
+		intModel := &TableModel{
+			Table:      "test_pk_users",
+			Fields:     []FieldDefinition{{Name: "id", Type: FieldTypeInt}, {Name: "name", Type: FieldTypeString}},
+			PrimaryKey: []string{"id"},
+		}
+		stringModel := &TableModel{
+			Table:      "test_pk_names",
+			Fields:     []FieldDefinition{{Name: "name", Type: FieldTypeString}},
+			PrimaryKey: []string{"name"},
+		}
+
+		Convey("构建主键条件", func() {
+			pk, err := PK(intModel, int64(1))
+			So(err, ShouldBeNil)
+			So(pk, ShouldResemble, map[string]any{"id": int64(1)})
+
+			pk, err = PK(stringModel, testPKUserID("alice"))
+			So(err, ShouldBeNil)
+			So(pk, ShouldResemble, map[string]any{"name": testPKUserID("alice")})
+
+			id := uuid.New()
+			pk, err = PK(stringModel, id)
+			So(err, ShouldBeNil)
+			So(pk, ShouldResemble, map[string]any{"name": id.String()})
+
+			pks, err := PKs(intModel, []int{1, 2})
+			So(err, ShouldBeNil)
+			So(pks, ShouldResemble, []map[string]any{{"id": 1}, {"id": 2}})
+		})
+
+		Convey("主键与表模型不匹配", func() {
+			_, err := PK(intModel, "1")
+			So(errors.Is(err, ErrInvalidPrimaryKey), ShouldBeTrue)
+			So(err.Error(), ShouldContainSubstring, "primary key id of table test_pk_users is int, got string")
+
+			_, err = PK(stringModel, 1)
+			So(errors.Is(err, ErrInvalidPrimaryKey), ShouldBeTrue)
+
+			_, err = PK(&TableModel{Table: "test_pk_composite", PrimaryKey: []string{"a", "b"}}, 1)
+			So(errors.Is(err, ErrInvalidPrimaryKey), ShouldBeTrue)
+
+			_, err = PKs(intModel, []string{"1"})
+			So(errors.Is(err, ErrInvalidPrimaryKey), ShouldBeTrue)
+		})
+
+		Convey("按主键读取和删除记录", func() {
+			db, err := NewSQLWithOptions(&SQLOptions{Driver: "sqlite3", Database: ":memory:", MaxConns: 1, MaxIdle: 1})
+			So(err, ShouldBeNil)
+			defer db.Close()
+
+			ctx := context.Background()
+			model, err := NewTableModelBuilder().FromStruct(&testPKOrder{})
+			So(err, ShouldBeNil)
+			So(db.Migrate(ctx, model), ShouldBeNil)
+
+			order := &testPKOrder{ID: uuid.New(), Amount: 100}
+			So(db.Create(ctx, model.Table, db.GetBuilder().FromStruct(order)), ShouldBeNil)
+
+			record, err := GetByID(ctx, db, model, order.ID)
+			So(err, ShouldBeNil)
+			var got testPKOrder
+			So(record.Scan(&got), ShouldBeNil)
+			So(got, ShouldResemble, *order)
+
+			_, err = GetByID(ctx, db, model, 1)
+			So(errors.Is(err, ErrInvalidPrimaryKey), ShouldBeTrue)
+
+			So(DeleteByID(ctx, db, model, order.ID), ShouldBeNil)
+			_, err = GetByID(ctx, db, model, order.ID)
+			So(err, ShouldEqual, ErrRecordNotFound)
+		})
+	})
+}
@@ -0,0 +1,142 @@
+package database
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hatlonely/gox/ref"
+	"github.com/pkg/errors"
+)
+
+// ErrReadOnly 数据库处于只读模式，写操作返回该错误
+var ErrReadOnly = errors.New("database is read-only")
+
+// ReadOnlyOptions 只读降级配置
+type ReadOnlyOptions struct {
+	// Database 被包装的底层数据库配置
+	Database *ref.TypeOptions `cfg:"database" validate:"required"`
+	// ReadOnly 创建时是否处于只读模式
+	ReadOnly bool `cfg:"readOnly"`
+	// HealthCheckInterval 定期调用底层数据库 Health 的间隔，检查失败时切换为只读，恢复后自动解除；为 0 时不检查
+	HealthCheckInterval time.Duration `cfg:"healthCheckInterval"`
+}
+
+// ReadOnlyDatabase 支持在运行时切换为只读的数据库包装，用于故障期间的降级
+//
+// 只读时 Migrate、DropTable 以及所有写操作返回 ErrReadOnly，读操作不受影响。
+// 手动切换（SetReadOnly）和健康检查失败任一成立即为只读，健康检查只在检查恢复后解除它自己触发的只读。
+// 事务中的写操作同样被拒绝，Commit 和 Rollback 不受影响
+type ReadOnlyDatabase struct {
+	*InterceptorDatabase
+
+	manual    atomic.Bool
+	unhealthy atomic.Bool
+
+	mu        sync.Mutex
+	healthErr error
+
+	checkerMu sync.Mutex
+	checker   *healthChecker
+}
+
+// NewReadOnlyDatabase 为数据库添加只读开关，创建时可写
+func NewReadOnlyDatabase(db Database) *ReadOnlyDatabase {
+	r := &ReadOnlyDatabase{}
+	r.InterceptorDatabase = NewInterceptorDatabase(db, r.intercept)
+	return r
+}
+
+// NewReadOnlyDatabaseWithOptions 使用配置创建只读开关包装
+func NewReadOnlyDatabaseWithOptions(options *ReadOnlyOptions) (*ReadOnlyDatabase, error) {
+	if options == nil {
+		return nil, errors.New("options is nil")
+	}
+
+	db, err := NewDatabaseWithOptions(options.Database)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to create underlying database")
+	}
+
+	r := NewReadOnlyDatabase(db)
+	r.SetReadOnly(options.ReadOnly)
+	r.StartHealthCheck(options.HealthCheckInterval, nil)
+	return r, nil
+}
+
+// Unwrap 返回被包装的数据库
+func (r *ReadOnlyDatabase) Unwrap() Database {
+	return r.InterceptorDatabase.Database
+}
+
+// SetReadOnly 手动切换只读模式，并发安全
+func (r *ReadOnlyDatabase) SetReadOnly(readOnly bool) {
+	r.manual.Store(readOnly)
+}
+
+// ReadOnly 当前是否处于只读模式
+func (r *ReadOnlyDatabase) ReadOnly() bool {
+	return r.manual.Load() || r.unhealthy.Load()
+}
+
+// HealthError 返回最近一次健康检查的错误，健康检查通过或未启动时返回 nil
+func (r *ReadOnlyDatabase) HealthError() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.healthErr
+}
+
+// StartHealthCheck 启动健康检查，check 返回错误时切换为只读，返回 nil 时解除
+// check 为 nil 时使用底层数据库的 Health，可以传入检查复制延迟等自定义逻辑；interval 小于等于 0 时不启动。
+// 重复调用时先停止之前的检查并清除它触发的只读，Close 时停止
+func (r *ReadOnlyDatabase) StartHealthCheck(interval time.Duration, check func(ctx context.Context) error) {
+	if check == nil {
+		check = r.Unwrap().Health
+	}
+
+	r.checkerMu.Lock()
+	defer r.checkerMu.Unlock()
+	r.checker.stop()
+	r.setHealth(nil)
+	r.checker = startHealthChecker(interval, func(ctx context.Context) error {
+		r.setHealth(check(ctx))
+		return nil
+	}, nil)
+}
+
+// setHealth 记录健康检查的结果
+func (r *ReadOnlyDatabase) setHealth(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.healthErr = err
+	r.unhealthy.Store(err != nil)
+}
+
+// intercept 只读时拒绝写操作
+func (r *ReadOnlyDatabase) intercept(ctx context.Context, op OperationInfo, next Handler) error {
+	if r.ReadOnly() && isWriteOperation(op.Operation) {
+		return ErrReadOnly
+	}
+	return next(ctx, op)
+}
+
+// Close 停止健康检查并关闭底层数据库
+func (r *ReadOnlyDatabase) Close() error {
+	r.checkerMu.Lock()
+	r.checker.stop()
+	r.checker = nil
+	r.checkerMu.Unlock()
+
+	return r.InterceptorDatabase.Close()
+}
+
+// isWriteOperation 操作是否修改数据或表结构
+func isWriteOperation(operation Operation) bool {
+	switch operation {
+	case OpMigrate, OpDropTable, OpCreate, OpUpdate, OpUpdatePartial, OpUpsert, OpIncrement, OpDelete,
+		OpBatchCreate, OpBatchUpdate, OpBatchDelete:
+		return true
+	}
+	return false
+}
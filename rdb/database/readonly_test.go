@@ -0,0 +1,118 @@
+package database
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hatlonely/gox/rdb/query"
+	"github.com/hatlonely/gox/ref"
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestReadOnlyDatabase(t *testing.T) {
+	Convey("测试只读降级", t, func() {
+		sql, err := NewSQLWithOptions(&SQLOptions{Driver: "sqlite3", Database: ":memory:", MaxConns: 1, MaxIdle: 1})
+		So(err, ShouldBeNil)
+
+		ctx := context.Background()
+		db := NewReadOnlyDatabase(sql)
+		defer db.Close()
+
+		So(db.Migrate(ctx, &TableModel{
+			Table: "test_readonly",
+			Fields: []FieldDefinition{
+				{Name: "id", Type: FieldTypeInt, Required: true},
+				{Name: "name", Type: FieldTypeString, Size: 100},
+			},
+			PrimaryKey: []string{"id"},
+		}), ShouldBeNil)
+		builder := db.GetBuilder()
+		So(db.Create(ctx, "test_readonly", builder.FromMap(map[string]any{"id": 1, "name": "alice"}, "test_readonly")), ShouldBeNil)
+
+		Convey("手动切换后拒绝写操作，读操作不受影响", func() {
+			db.SetReadOnly(true)
+			So(db.ReadOnly(), ShouldBeTrue)
+
+			So(db.Create(ctx, "test_readonly", builder.FromMap(map[string]any{"id": 2}, "test_readonly")), ShouldEqual, ErrReadOnly)
+			So(db.UpdatePartial(ctx, "test_readonly", map[string]any{"id": 1}, map[string]any{"name": "bob"}), ShouldEqual, ErrReadOnly)
+			So(db.Delete(ctx, "test_readonly", map[string]any{"id": 1}), ShouldEqual, ErrReadOnly)
+			So(db.Upsert(ctx, "test_readonly", map[string]any{"id": 1}, nil), ShouldEqual, ErrReadOnly)
+			So(db.DropTable(ctx, "test_readonly"), ShouldEqual, ErrReadOnly)
+
+			record, err := db.Get(ctx, "test_readonly", map[string]any{"id": 1})
+			So(err, ShouldBeNil)
+			So(record.Fields()["name"], ShouldEqual, "alice")
+			count, err := db.Count(ctx, "test_readonly", &query.ExistsQuery{Field: "id"})
+			So(err, ShouldBeNil)
+			So(count, ShouldEqual, 1)
+
+			db.SetReadOnly(false)
+			So(db.UpdatePartial(ctx, "test_readonly", map[string]any{"id": 1}, map[string]any{"name": "bob"}), ShouldBeNil)
+		})
+
+		Convey("事务中的写操作同样被拒绝", func() {
+			db.SetReadOnly(true)
+			err := db.WithTx(ctx, func(tx Transaction) error {
+				return tx.Increment(ctx, "test_readonly", map[string]any{"id": 1}, "id", 1)
+			})
+			So(errors.Is(err, ErrReadOnly), ShouldBeTrue)
+		})
+
+		Convey("健康检查失败时切换为只读，恢复后解除", func() {
+			var healthy atomic.Bool
+			healthy.Store(true)
+			db.StartHealthCheck(10*time.Millisecond, func(ctx context.Context) error {
+				if healthy.Load() {
+					return nil
+				}
+				return errors.New("replication lag too high")
+			})
+
+			healthy.Store(false)
+			So(waitFor(func() bool { return db.ReadOnly() }), ShouldBeTrue)
+			So(db.HealthError(), ShouldNotBeNil)
+			So(db.Delete(ctx, "test_readonly", map[string]any{"id": 1}), ShouldEqual, ErrReadOnly)
+
+			healthy.Store(true)
+			So(waitFor(func() bool { return !db.ReadOnly() }), ShouldBeTrue)
+			So(db.HealthError(), ShouldBeNil)
+
+			// 手动切换不受健康检查恢复影响
+			db.SetReadOnly(true)
+			time.Sleep(30 * time.Millisecond)
+			So(db.ReadOnly(), ShouldBeTrue)
+		})
+	})
+
+	Convey("测试通过配置创建只读降级包装", t, func() {
+		db, err := NewReadOnlyDatabaseWithOptions(&ReadOnlyOptions{
+			Database: &ref.TypeOptions{
+				Namespace: "github.com/hatlonely/gox/rdb/database",
+				Type:      "SQL",
+				Options:   &SQLOptions{Driver: "sqlite3", Database: ":memory:", MaxConns: 1, MaxIdle: 1},
+			},
+			ReadOnly:            true,
+			HealthCheckInterval: time.Hour,
+		})
+		So(err, ShouldBeNil)
+		defer db.Close()
+
+		So(db.ReadOnly(), ShouldBeTrue)
+		So(db.Migrate(context.Background(), &TableModel{Table: "t", Fields: []FieldDefinition{{Name: "id", Type: FieldTypeInt}}}), ShouldEqual, ErrReadOnly)
+	})
+}
+
+// waitFor 等待条件成立，最多等待 1 秒
+func waitFor(cond func() bool) bool {
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return true
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return cond()
+}
@@ -0,0 +1,144 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// 副本选择策略
+const (
+	// ReplicaSelectorRoundRobin 在可用副本间轮询
+	ReplicaSelectorRoundRobin = "roundRobin"
+	// ReplicaSelectorLatency 选择最近一次探测延迟最低的可用副本
+	ReplicaSelectorLatency = "latency"
+)
+
+type primaryReadContextKey struct{}
+
+// WithPrimaryRead 返回要求读操作使用主库的 context，用于写后立即读取等需要读到最新数据的场景
+// 未配置副本的数据库不受影响
+func WithPrimaryRead(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryReadContextKey{}, true)
+}
+
+// isPrimaryRead context 是否要求读操作使用主库
+func isPrimaryRead(ctx context.Context) bool {
+	primary, _ := ctx.Value(primaryReadContextKey{}).(bool)
+	return primary
+}
+
+// sqlReplica 只读副本连接池及其探测状态
+type sqlReplica struct {
+	db      *sql.DB
+	latency atomic.Int64 // 最近一次探测的延迟，单位纳秒
+	healthy atomic.Bool
+}
+
+// sqlReplicaPool 只读副本集合，按选择策略为读操作分配副本
+type sqlReplicaPool struct {
+	replicas []*sqlReplica
+	selector string
+	next     atomic.Uint64
+	checker  *healthChecker
+}
+
+// newSQLReplicaPool 连接所有副本，任一副本连接失败时关闭已建立的连接并返回错误
+func newSQLReplicaPool(options *SQLOptions) (*sqlReplicaPool, error) {
+	selector := options.ReplicaSelector
+	if selector == "" {
+		selector = ReplicaSelectorRoundRobin
+	}
+	if selector != ReplicaSelectorRoundRobin && selector != ReplicaSelectorLatency {
+		return nil, fmt.Errorf("unsupported replica selector: %s", options.ReplicaSelector)
+	}
+
+	driverName, err := sqlDriverName(options)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &sqlReplicaPool{selector: selector}
+	for _, dsn := range options.Replicas {
+		db, err := sql.Open(driverName, dsn)
+		if err != nil {
+			p.close()
+			return nil, fmt.Errorf("failed to open replica: %v", err)
+		}
+		db.SetMaxOpenConns(options.MaxConns)
+		db.SetMaxIdleConns(options.MaxIdle)
+
+		replica := &sqlReplica{db: db}
+		p.replicas = append(p.replicas, replica)
+		if err := retryConnect(options.Retry, func() error {
+			return replica.ping(context.Background())
+		}); err != nil {
+			p.close()
+			return nil, fmt.Errorf("failed to connect to replica: %v", err)
+		}
+	}
+	p.checker = startHealthChecker(options.ReplicaCheckInterval, p.probe, nil)
+
+	return p, nil
+}
+
+// ping 探测副本并记录延迟和可用状态
+func (r *sqlReplica) ping(ctx context.Context) error {
+	start := time.Now()
+	err := r.db.PingContext(ctx)
+	r.latency.Store(int64(time.Since(start)))
+	r.healthy.Store(err == nil)
+	return err
+}
+
+// probe 探测所有副本，返回所有探测失败的错误
+func (p *sqlReplicaPool) probe(ctx context.Context) error {
+	var errs []error
+	for _, replica := range p.replicas {
+		if err := replica.ping(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// pick 按选择策略返回一个可用副本，没有可用副本时返回 nil
+func (p *sqlReplicaPool) pick() *sql.DB {
+	if p.selector == ReplicaSelectorLatency {
+		var best *sqlReplica
+		for _, replica := range p.replicas {
+			if replica.healthy.Load() && (best == nil || replica.latency.Load() < best.latency.Load()) {
+				best = replica
+			}
+		}
+		if best == nil {
+			return nil
+		}
+		return best.db
+	}
+
+	start := p.next.Add(1)
+	for i := range p.replicas {
+		replica := p.replicas[(start+uint64(i))%uint64(len(p.replicas))]
+		if replica.healthy.Load() {
+			return replica.db
+		}
+	}
+	return nil
+}
+
+// close 停止探测并关闭所有副本连接
+func (p *sqlReplicaPool) close() error {
+	p.checker.stop()
+
+	var errs []error
+	for _, replica := range p.replicas {
+		if err := replica.db.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,119 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hatlonely/gox/rdb/query"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+var testReplicaModel = &TableModel{
+	Table: "test_replica_users",
+	Fields: []FieldDefinition{
+		{Name: "id", Type: FieldTypeInt, Required: true},
+		{Name: "name", Type: FieldTypeString, Size: 100},
+	},
+	PrimaryKey: []string{"id"},
+}
+
+// newTestReplica 创建一个 SQLite 内存数据库作为副本，写入一条以数据库名命名的记录用于区分读到的副本
+func newTestReplica(name string) *SQL {
+	replica, err := NewSQLWithOptions(&SQLOptions{Driver: "sqlite3", Database: "file:" + name + "?mode=memory&cache=shared", MaxConns: 1, MaxIdle: 1})
+	So(err, ShouldBeNil)
+	ctx := context.Background()
+	So(replica.Migrate(ctx, testReplicaModel), ShouldBeNil)
+	So(replica.Create(ctx, "test_replica_users", replica.GetBuilder().FromMap(map[string]any{"id": 1, "name": name}, "test_replica_users")), ShouldBeNil)
+	return replica
+}
+
+func TestSQLReplicas(t *testing.T) {
+	Convey("测试 SQL 读写分离", t, func() {
+		ctx := context.Background()
+		replicaA := newTestReplica("replica_a")
+		defer replicaA.Close()
+		replicaB := newTestReplica("replica_b")
+		defer replicaB.Close()
+
+		db, err := NewSQLWithOptions(&SQLOptions{
+			Driver:   "sqlite3",
+			Database: "file:replica_primary?mode=memory&cache=shared",
+			MaxConns: 1,
+			MaxIdle:  1,
+			Replicas: []string{
+				"file:replica_a?mode=memory&cache=shared",
+				"file:replica_b?mode=memory&cache=shared",
+			},
+		})
+		So(err, ShouldBeNil)
+		defer db.Close()
+		So(db.Migrate(ctx, testReplicaModel), ShouldBeNil)
+		So(db.Create(ctx, "test_replica_users", db.GetBuilder().FromMap(map[string]any{"id": 1, "name": "primary"}, "test_replica_users")), ShouldBeNil)
+
+		readName := func(ctx context.Context) string {
+			record, err := db.Get(ctx, "test_replica_users", map[string]any{"id": 1})
+			So(err, ShouldBeNil)
+			return record.Fields()["name"].(string)
+		}
+
+		Convey("读操作轮询副本", func() {
+			names := map[string]int{}
+			for i := 0; i < 4; i++ {
+				names[readName(ctx)]++
+			}
+			So(names, ShouldResemble, map[string]int{"replica_a": 2, "replica_b": 2})
+
+			records, err := db.Find(ctx, "test_replica_users", &query.TermQuery{Field: "id", Value: 1})
+			So(err, ShouldBeNil)
+			So(records, ShouldHaveLength, 1)
+			So(records[0].Fields()["name"], ShouldNotEqual, "primary")
+		})
+
+		Convey("WithPrimaryRead 读主库", func() {
+			So(readName(WithPrimaryRead(ctx)), ShouldEqual, "primary")
+
+			count, err := db.Count(WithPrimaryRead(ctx), "test_replica_users", &query.TermQuery{Field: "name", Value: "primary"})
+			So(err, ShouldBeNil)
+			So(count, ShouldEqual, 1)
+		})
+
+		Convey("事务使用主库", func() {
+			So(db.WithTx(ctx, func(tx Transaction) error {
+				record, err := tx.Get(ctx, "test_replica_users", map[string]any{"id": 1})
+				So(err, ShouldBeNil)
+				So(record.Fields()["name"], ShouldEqual, "primary")
+				return nil
+			}), ShouldBeNil)
+		})
+
+		Convey("按延迟选择副本", func() {
+			db.replicas.selector = ReplicaSelectorLatency
+			db.replicas.replicas[0].latency.Store(int64(100))
+			db.replicas.replicas[1].latency.Store(int64(10))
+			So(readName(ctx), ShouldEqual, "replica_b")
+			So(readName(ctx), ShouldEqual, "replica_b")
+		})
+
+		Convey("不可用的副本不参与选择", func() {
+			db.replicas.replicas[0].healthy.Store(false)
+			So(readName(ctx), ShouldEqual, "replica_b")
+			So(readName(ctx), ShouldEqual, "replica_b")
+
+			db.replicas.replicas[1].healthy.Store(false)
+			So(readName(ctx), ShouldEqual, "primary")
+
+			So(db.replicas.probe(ctx), ShouldBeNil)
+			So(readName(ctx), ShouldNotEqual, "primary")
+		})
+	})
+
+	Convey("测试不支持的副本选择策略", t, func() {
+		_, err := NewSQLWithOptions(&SQLOptions{
+			Driver:          "sqlite3",
+			Database:        ":memory:",
+			Replicas:        []string{":memory:"},
+			ReplicaSelector: "random",
+		})
+		So(err, ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,132 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"net/http"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/hatlonely/gox/cfg/commonopt"
+	"github.com/mattn/go-sqlite3"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// TransientRetryOptions 临时错误重试策略，操作返回的错误被判定为临时错误时按退避重试
+// 与 RetryOptions 相同，默认 maxAttempts 为 1 不重试，需要显式配置
+type TransientRetryOptions = commonopt.RetryOptions
+
+// ErrorClassifier 判断错误是否为临时错误，临时错误重试后可能成功
+type ErrorClassifier interface {
+	IsTransient(err error) bool
+}
+
+// ErrorClassifierFunc 函数形式的 ErrorClassifier
+type ErrorClassifierFunc func(err error) bool
+
+func (f ErrorClassifierFunc) IsTransient(err error) bool {
+	return f(err)
+}
+
+// ErrorClassifiers 组合多个 ErrorClassifier，任意一个判定为临时错误即为临时错误
+type ErrorClassifiers []ErrorClassifier
+
+func (cs ErrorClassifiers) IsTransient(err error) bool {
+	for _, c := range cs {
+		if c.IsTransient(err) {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultErrorClassifier 内置的临时错误判定，覆盖各后端的常见临时错误：
+//   - MySQL：死锁（1213）、锁等待超时（1205）、连接失效
+//   - SQLite：数据库忙（SQLITE_BUSY）、表被锁定（SQLITE_LOCKED）
+//   - MongoDB：网络错误、带 TransientTransactionError 或 RetryableWriteError 标签的错误、写冲突（112）
+//   - Elasticsearch：429、502、503、504 状态码
+var DefaultErrorClassifier ErrorClassifier = ErrorClassifiers{
+	ErrorClassifierFunc(isTransientSQLError),
+	ErrorClassifierFunc(isTransientMongoError),
+	ErrorClassifierFunc(isTransientESError),
+}
+
+func isTransientSQLError(err error) bool {
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) {
+		return true
+	}
+
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == 1213 || mysqlErr.Number == 1205
+	}
+
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked
+	}
+	return false
+}
+
+func isTransientMongoError(err error) bool {
+	if mongo.IsNetworkError(err) {
+		return true
+	}
+
+	var serverErr mongo.ServerError
+	if errors.As(err, &serverErr) {
+		return serverErr.HasErrorLabel("TransientTransactionError") ||
+			serverErr.HasErrorLabel("RetryableWriteError") ||
+			serverErr.HasErrorCode(112)
+	}
+	return false
+}
+
+func isTransientESError(err error) bool {
+	var esErr *ESResponseError
+	if errors.As(err, &esErr) {
+		switch esErr.StatusCode {
+		case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+	}
+	return false
+}
+
+// NewRetryInterceptor 创建临时错误重试拦截器，classifier 为空时使用 DefaultErrorClassifier
+//
+// 重试等待期间 ctx 取消或超时时立即返回最后一次的错误。网络错误时无法确认第一次是否已经执行，
+// 只重试只读操作 Get、Find、Count、Exists、Aggregate，以及幂等的写操作：Upsert，冲突时按主键更新（WithUpdateOnConflict）的 Create 和 BatchCreate。
+// 其他写操作重试可能重复插入或重复执行，不重试。
+// 事务内的操作不重试，事务遇到死锁等错误时整个事务已失效，由 WithTx 整体重试，失败的事务已经整体回滚
+func NewRetryInterceptor(options *TransientRetryOptions, classifier ErrorClassifier) Interceptor {
+	if classifier == nil {
+		classifier = DefaultErrorClassifier
+	}
+	return func(ctx context.Context, op OperationInfo, next Handler) error {
+		if options == nil || options.MaxAttempts <= 1 || !retryableOperation(op) {
+			return next(ctx, op)
+		}
+
+		return options.DoIf(ctx, func() error {
+			return next(ctx, op)
+		}, classifier.IsTransient)
+	}
+}
+
+func retryableOperation(op OperationInfo) bool {
+	if op.InTx {
+		return false
+	}
+	switch op.Operation {
+	case OpGet, OpFind, OpCount, OpExists, OpAggregate, OpUpsert, OpWithTx:
+		return true
+	case OpCreate, OpBatchCreate:
+		options := &CreateOptions{}
+		for _, opt := range op.CreateOpts {
+			opt(options)
+		}
+		return options.UpdateOnConflict
+	}
+	return false
+}
@@ -0,0 +1,214 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/hatlonely/gox/cfg/commonopt"
+	"github.com/hatlonely/gox/rdb/query"
+	"github.com/mattn/go-sqlite3"
+	. "github.com/smartystreets/goconvey/convey"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestDefaultErrorClassifier(t *testing.T) {
+	Convey("测试内置临时错误判定", t, func() {
+		transient := []error{
+			&mysql.MySQLError{Number: 1213, Message: "Deadlock found when trying to get lock"},
+			fmt.Errorf("failed to update: %w", &mysql.MySQLError{Number: 1205, Message: "Lock wait timeout exceeded"}),
+			mysql.ErrInvalidConn,
+			sqlite3.Error{Code: sqlite3.ErrBusy},
+			sqlite3.Error{Code: sqlite3.ErrLocked},
+			mongo.CommandError{Code: 251, Labels: []string{"TransientTransactionError"}},
+			mongo.CommandError{Code: 112, Name: "WriteConflict"},
+			&ESResponseError{StatusCode: 503},
+			fmt.Errorf("wrapped: %w", &ESResponseError{StatusCode: 429}),
+		}
+		for _, err := range transient {
+			So(DefaultErrorClassifier.IsTransient(err), ShouldBeTrue)
+		}
+
+		permanent := []error{
+			nil,
+			errors.New("boom"),
+			ErrRecordNotFound,
+			ErrDuplicateKey,
+			&mysql.MySQLError{Number: 1062, Message: "Duplicate entry"},
+			sqlite3.Error{Code: sqlite3.ErrConstraint},
+			mongo.CommandError{Code: 11000},
+			&ESResponseError{StatusCode: 400},
+			&ESResponseError{StatusCode: 404},
+		}
+		for _, err := range permanent {
+			So(DefaultErrorClassifier.IsTransient(err), ShouldBeFalse)
+		}
+	})
+}
+
+// failingInterceptor 对指定操作前 n 次调用直接返回 err，记录每个操作的调用次数
+type failingInterceptor struct {
+	operation Operation
+	n         int
+	err       error
+	calls     map[Operation]int
+}
+
+func (f *failingInterceptor) intercept(ctx context.Context, op OperationInfo, next Handler) error {
+	f.calls[op.Operation]++
+	if op.Operation == f.operation && f.calls[op.Operation] <= f.n {
+		return f.err
+	}
+	return next(ctx, op)
+}
+
+func TestRetryInterceptor(t *testing.T) {
+	Convey("测试临时错误重试", t, func() {
+		sql, err := NewSQLWithOptions(&SQLOptions{Driver: "sqlite3", Database: ":memory:", MaxConns: 1, MaxIdle: 1})
+		So(err, ShouldBeNil)
+		defer sql.Close()
+
+		ctx := context.Background()
+		So(sql.Migrate(ctx, &TableModel{
+			Table: "test_retry_users",
+			Fields: []FieldDefinition{
+				{Name: "id", Type: FieldTypeInt, Required: true},
+				{Name: "name", Type: FieldTypeString, Size: 100},
+			},
+			PrimaryKey: []string{"id"},
+		}), ShouldBeNil)
+		So(sql.Create(ctx, "test_retry_users", sql.GetBuilder().FromMap(map[string]any{"id": 1, "name": "alice"}, "test_retry_users")), ShouldBeNil)
+
+		deadlock := &mysql.MySQLError{Number: 1213, Message: "Deadlock found when trying to get lock"}
+		options := &TransientRetryOptions{MaxAttempts: 3, Backoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond, Jitter: 0.2}
+		pk := map[string]any{"id": 1}
+
+		Convey("临时错误重试后成功", func() {
+			f := &failingInterceptor{operation: OpGet, n: 2, err: deadlock, calls: map[Operation]int{}}
+			db := NewInterceptorDatabase(sql, NewRetryInterceptor(options, nil), f.intercept)
+
+			record, err := db.Get(ctx, "test_retry_users", pk)
+			So(err, ShouldBeNil)
+			So(record.Fields()["name"], ShouldEqual, "alice")
+			So(f.calls[OpGet], ShouldEqual, 3)
+		})
+
+		Convey("达到最大尝试次数返回最后一次的错误", func() {
+			f := &failingInterceptor{operation: OpGet, n: 5, err: deadlock, calls: map[Operation]int{}}
+			db := NewInterceptorDatabase(sql, NewRetryInterceptor(options, nil), f.intercept)
+
+			_, err := db.Get(ctx, "test_retry_users", pk)
+			So(errors.Is(err, deadlock), ShouldBeTrue)
+			So(f.calls[OpGet], ShouldEqual, 3)
+		})
+
+		Convey("非临时错误不重试", func() {
+			f := &failingInterceptor{operation: OpGet, n: 5, err: errors.New("boom"), calls: map[Operation]int{}}
+			db := NewInterceptorDatabase(sql, NewRetryInterceptor(options, nil), f.intercept)
+
+			_, err := db.Get(ctx, "test_retry_users", pk)
+			So(err, ShouldNotBeNil)
+			So(f.calls[OpGet], ShouldEqual, 1)
+		})
+
+		Convey("自定义错误判定", func() {
+			errTemporary := errors.New("temporary")
+			classifier := ErrorClassifierFunc(func(err error) bool { return errors.Is(err, errTemporary) })
+			f := &failingInterceptor{operation: OpCount, n: 1, err: errTemporary, calls: map[Operation]int{}}
+			db := NewInterceptorDatabase(sql, NewRetryInterceptor(options, classifier), f.intercept)
+
+			count, err := db.Count(ctx, "test_retry_users", &query.TermQuery{Field: "name", Value: "alice"})
+			So(err, ShouldBeNil)
+			So(count, ShouldEqual, 1)
+			So(f.calls[OpCount], ShouldEqual, 2)
+		})
+
+		Convey("Increment 不重试", func() {
+			f := &failingInterceptor{operation: OpIncrement, n: 1, err: deadlock, calls: map[Operation]int{}}
+			db := NewInterceptorDatabase(sql, NewRetryInterceptor(options, nil), f.intercept)
+
+			So(db.Increment(ctx, "test_retry_users", pk, "id", 0), ShouldEqual, deadlock)
+			So(f.calls[OpIncrement], ShouldEqual, 1)
+		})
+
+		Convey("Upsert 幂等可以重试", func() {
+			f := &failingInterceptor{operation: OpUpsert, n: 1, err: deadlock, calls: map[Operation]int{}}
+			db := NewInterceptorDatabase(sql, NewRetryInterceptor(options, nil), f.intercept)
+
+			record := sql.builder.FromMap(map[string]any{"name": "bob"}, "test_retry_users")
+			So(db.Upsert(ctx, "test_retry_users", pk, record), ShouldBeNil)
+			So(f.calls[OpUpsert], ShouldEqual, 2)
+		})
+
+		Convey("Create 不重试，冲突时更新的 Create 幂等可以重试", func() {
+			errBadConn := driver.ErrBadConn
+			f := &failingInterceptor{operation: OpCreate, n: 1, err: errBadConn, calls: map[Operation]int{}}
+			db := NewInterceptorDatabase(sql, NewRetryInterceptor(options, nil), f.intercept)
+
+			record := sql.GetBuilder().FromMap(map[string]any{"id": 2, "name": "bob"}, "test_retry_users")
+			So(db.Create(ctx, "test_retry_users", record), ShouldEqual, errBadConn)
+			So(f.calls[OpCreate], ShouldEqual, 1)
+
+			f.calls = map[Operation]int{}
+			So(db.Create(ctx, "test_retry_users", record, WithUpdateOnConflict()), ShouldBeNil)
+			So(f.calls[OpCreate], ShouldEqual, 2)
+		})
+
+		Convey("Update 和 Delete 不重试", func() {
+			for _, op := range []Operation{OpUpdatePartial, OpDelete} {
+				f := &failingInterceptor{operation: op, n: 1, err: deadlock, calls: map[Operation]int{}}
+				db := NewInterceptorDatabase(sql, NewRetryInterceptor(options, nil), f.intercept)
+				if op == OpDelete {
+					So(db.Delete(ctx, "test_retry_users", pk), ShouldEqual, deadlock)
+				} else {
+					So(db.UpdatePartial(ctx, "test_retry_users", pk, map[string]any{"name": "bob"}), ShouldEqual, deadlock)
+				}
+				So(f.calls[op], ShouldEqual, 1)
+			}
+		})
+
+		Convey("事务内的操作不重试，WithTx 整体重试", func() {
+			f := &failingInterceptor{operation: OpGet, n: 1, err: deadlock, calls: map[Operation]int{}}
+			db := NewInterceptorDatabase(sql, NewRetryInterceptor(options, nil), f.intercept)
+
+			runs := 0
+			err := db.WithTx(ctx, func(tx Transaction) error {
+				runs++
+				_, err := tx.Get(ctx, "test_retry_users", pk)
+				return err
+			})
+			So(err, ShouldBeNil)
+			So(runs, ShouldEqual, 2)
+			So(f.calls[OpGet], ShouldEqual, 2)
+			So(f.calls[OpWithTx], ShouldEqual, 2)
+		})
+
+		Convey("等待重试时 context 取消立即返回", func() {
+			f := &failingInterceptor{operation: OpGet, n: 5, err: deadlock, calls: map[Operation]int{}}
+			slow := &TransientRetryOptions{MaxAttempts: 3, Backoff: time.Hour}
+			db := NewInterceptorDatabase(sql, NewRetryInterceptor(slow, nil), f.intercept)
+
+			ctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+			defer cancel()
+			start := time.Now()
+			_, err := db.Get(ctx, "test_retry_users", pk)
+			So(errors.Is(err, deadlock), ShouldBeTrue)
+			So(time.Since(start), ShouldBeLessThan, time.Second)
+			So(f.calls[OpGet], ShouldEqual, 1)
+		})
+	})
+}
+
+func TestJitterDuration(t *testing.T) {
+	Convey("测试退避抖动", t, func() {
+		So(commonopt.Jitter(100*time.Millisecond, 0), ShouldEqual, 100*time.Millisecond)
+		for i := 0; i < 100; i++ {
+			d := commonopt.Jitter(100*time.Millisecond, 0.2)
+			So(d, ShouldBeBetweenOrEqual, 80*time.Millisecond, 120*time.Millisecond)
+		}
+	})
+}
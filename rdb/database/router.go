@@ -0,0 +1,659 @@
+package database
+
+import (
+	"container/list"
+	"context"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hatlonely/gox/cfg/storage"
+	"github.com/hatlonely/gox/rdb/aggregation"
+	"github.com/hatlonely/gox/rdb/query"
+	"github.com/hatlonely/gox/ref"
+	"github.com/pkg/errors"
+	"golang.org/x/sync/singleflight"
+)
+
+var (
+	ErrTenantRequired = errors.New("tenant not found in context")
+	ErrTooManyTenants = errors.New("too many tenant databases")
+	ErrRouterClosed   = errors.New("router is closed")
+)
+
+// TenantPlaceholder 租户数据库配置模板中的占位符，创建租户数据库时替换为租户标识
+const TenantPlaceholder = "{tenant}"
+
+// tenantPattern 通过模板创建数据库时租户标识的格式，避免租户标识拼接到库名、DSN 中产生注入
+var tenantPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+type tenantContextKey struct{}
+
+// WithTenant 返回携带租户标识的 context，Router 根据该标识选择数据库
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantContextKey{}, tenant)
+}
+
+// TenantFromContext 获取 context 中的租户标识
+func TenantFromContext(ctx context.Context) (string, bool) {
+	tenant, ok := ctx.Value(tenantContextKey{}).(string)
+	return tenant, ok && tenant != ""
+}
+
+// TenantFactory 为租户创建数据库
+type TenantFactory func(ctx context.Context, tenant string) (Database, error)
+
+// RouterOptions 租户路由配置
+type RouterOptions struct {
+	// Database 租户数据库配置模板，options 中字符串里的 {tenant} 替换为租户标识
+	Database *ref.TypeOptions `cfg:"database"`
+	// Tenants 为指定租户单独配置数据库，优先于模板
+	Tenants map[string]*ref.TypeOptions `cfg:"tenants"`
+	// MaxPools 同时打开的租户数据库数量上限，达到上限时关闭最久未使用的空闲数据库，为 0 时不限制
+	MaxPools int `cfg:"maxPools" def:"100"`
+	// IdleTimeout 租户数据库空闲超过该时间后关闭，为 0 时不关闭
+	IdleTimeout time.Duration `cfg:"idleTimeout" def:"30m"`
+	// OpenTimeout 创建租户数据库的超时时间，为 0 时不限制
+	OpenTimeout time.Duration `cfg:"openTimeout" def:"30s"`
+}
+
+// defaultTenantOpenTimeout NewRouter 创建租户数据库的默认超时时间
+const defaultTenantOpenTimeout = 30 * time.Second
+
+// Router 按 context 中的租户标识将请求路由到租户独立的数据库，适用于每个租户独立库（schema）的架构
+//
+// 租户数据库在第一次访问时创建，超过数量上限或空闲超时后关闭，下次访问时重新创建。
+// 正在使用中的数据库（执行中的操作、未关闭的游标、未结束的事务）不会被淘汰。
+// 所有租户应使用相同类型的数据库，GetBuilder 返回的构建器与第一个创建的租户数据库一致
+type Router struct {
+	factory     TenantFactory
+	maxPools    int
+	idleTimeout time.Duration
+	openTimeout time.Duration
+
+	mu      sync.Mutex
+	pools   map[string]*tenantPool
+	lru     *list.List // 按最近使用排序，队首为最近使用
+	opening int        // 正在创建中的数据库数量，计入上限
+	builder RecordBuilder
+	closed  bool
+
+	group singleflight.Group
+	done  chan struct{}
+}
+
+type tenantPool struct {
+	tenant   string
+	db       Database
+	refs     int
+	lastUsed time.Time
+	elem     *list.Element
+	removed  bool // 已从路由中移除，引用释放后关闭
+}
+
+// NewRouter 使用租户数据库工厂创建路由
+func NewRouter(factory TenantFactory, maxPools int, idleTimeout time.Duration) *Router {
+	r := &Router{
+		factory:     factory,
+		maxPools:    maxPools,
+		idleTimeout: idleTimeout,
+		openTimeout: defaultTenantOpenTimeout,
+		pools:       make(map[string]*tenantPool),
+		lru:         list.New(),
+		done:        make(chan struct{}),
+	}
+	if idleTimeout > 0 {
+		go r.evictIdleLoop()
+	}
+	return r
+}
+
+// NewRouterWithOptions 使用配置创建路由
+func NewRouterWithOptions(options *RouterOptions) (*Router, error) {
+	if options == nil {
+		return nil, errors.New("options is nil")
+	}
+	if options.Database == nil && len(options.Tenants) == 0 {
+		return nil, errors.New("database or tenants is required")
+	}
+
+	r := NewRouter(options.newTenantDatabase, options.MaxPools, options.IdleTimeout)
+	r.openTimeout = options.OpenTimeout
+	return r, nil
+}
+
+// newTenantDatabase 根据租户配置或模板创建租户数据库
+func (o *RouterOptions) newTenantDatabase(ctx context.Context, tenant string) (Database, error) {
+	if options, ok := o.Tenants[tenant]; ok {
+		return NewDatabaseWithOptions(options)
+	}
+	if o.Database == nil {
+		return nil, errors.Errorf("tenant %s is not configured", tenant)
+	}
+	if !tenantPattern.MatchString(tenant) {
+		return nil, errors.Errorf("invalid tenant %q", tenant)
+	}
+
+	// 模板配置统一转换为 map 后替换占位符，再交给构造函数转换为具体的配置类型
+	data := o.Database.Options
+	if convertable, ok := data.(ref.Convertable); ok {
+		data = nil
+		if err := convertable.ConvertTo(&data); err != nil {
+			return nil, errors.WithMessage(err, "failed to convert database template")
+		}
+	}
+	switch data.(type) {
+	case nil, map[string]any:
+	default:
+		return nil, errors.Errorf("database template options must be a map, got %T", data)
+	}
+
+	return NewDatabaseWithOptions(&ref.TypeOptions{
+		Namespace: o.Database.Namespace,
+		Type:      o.Database.Type,
+		Options:   storage.NewMapStorage(replaceTenantPlaceholder(data, tenant)),
+		Retry:     o.Database.Retry,
+	})
+}
+
+// replaceTenantPlaceholder 递归替换配置中字符串里的租户占位符，返回新的配置
+func replaceTenantPlaceholder(v any, tenant string) any {
+	switch val := v.(type) {
+	case string:
+		return strings.ReplaceAll(val, TenantPlaceholder, tenant)
+	case map[string]any:
+		result := make(map[string]any, len(val))
+		for k, item := range val {
+			result[k] = replaceTenantPlaceholder(item, tenant)
+		}
+		return result
+	case []any:
+		result := make([]any, len(val))
+		for i, item := range val {
+			result[i] = replaceTenantPlaceholder(item, tenant)
+		}
+		return result
+	}
+	return v
+}
+
+// acquire 获取 context 中租户对应的数据库并增加引用，使用完毕后必须调用 release
+func (r *Router) acquire(ctx context.Context) (*tenantPool, error) {
+	tenant, ok := TenantFromContext(ctx)
+	if !ok {
+		return nil, ErrTenantRequired
+	}
+
+	for {
+		r.mu.Lock()
+		if r.closed {
+			r.mu.Unlock()
+			return nil, ErrRouterClosed
+		}
+		if pool, ok := r.pools[tenant]; ok {
+			pool.refs++
+			pool.lastUsed = time.Now()
+			r.lru.MoveToFront(pool.elem)
+			r.mu.Unlock()
+			return pool, nil
+		}
+		r.mu.Unlock()
+
+		// 同一租户的并发请求只创建一次数据库
+		// 创建不受发起者 ctx 取消的影响，否则发起者取消会让所有等待者一起失败，由 openTimeout 限制创建时间；
+		// 每个调用方只等待到自己的 ctx 结束
+		ch := r.group.DoChan(tenant, func() (any, error) {
+			openCtx, cancel := r.openContext(ctx)
+			defer cancel()
+			return nil, r.open(openCtx, tenant)
+		})
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case result := <-ch:
+			if result.Err != nil {
+				return nil, result.Err
+			}
+		}
+	}
+}
+
+// openContext 返回创建租户数据库使用的 ctx，保留 ctx 中的值，不继承取消，超时时间为 openTimeout
+func (r *Router) openContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	detached := context.WithoutCancel(ctx)
+	if r.openTimeout <= 0 {
+		return context.WithCancel(detached)
+	}
+	return context.WithTimeout(detached, r.openTimeout)
+}
+
+// open 创建租户数据库，达到数量上限时先淘汰最久未使用的空闲数据库
+func (r *Router) open(ctx context.Context, tenant string) error {
+	r.mu.Lock()
+	if _, ok := r.pools[tenant]; ok {
+		r.mu.Unlock()
+		return nil
+	}
+	var evicted *tenantPool
+	if r.maxPools > 0 && len(r.pools)+r.opening >= r.maxPools {
+		for elem := r.lru.Back(); elem != nil; elem = elem.Prev() {
+			if pool := elem.Value.(*tenantPool); pool.refs == 0 {
+				evicted = pool
+				break
+			}
+		}
+		if evicted == nil {
+			r.mu.Unlock()
+			return ErrTooManyTenants
+		}
+		r.remove(evicted)
+	}
+	r.opening++
+	r.mu.Unlock()
+
+	if evicted != nil {
+		evicted.db.Close()
+	}
+
+	db, err := r.factory(ctx, tenant)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.opening--
+	if err != nil {
+		return errors.WithMessagef(err, "failed to create database for tenant %s", tenant)
+	}
+	if r.closed {
+		db.Close()
+		return ErrRouterClosed
+	}
+
+	pool := &tenantPool{tenant: tenant, db: db, lastUsed: time.Now()}
+	pool.elem = r.lru.PushFront(pool)
+	r.pools[tenant] = pool
+	if r.builder == nil {
+		r.builder = db.GetBuilder()
+	}
+	return nil
+}
+
+// release 释放引用，已移除的数据库在最后一个引用释放后关闭
+func (r *Router) release(pool *tenantPool) {
+	r.mu.Lock()
+	pool.refs--
+	pool.lastUsed = time.Now()
+	closeNow := pool.removed && pool.refs == 0
+	r.mu.Unlock()
+
+	if closeNow {
+		pool.db.Close()
+	}
+}
+
+// remove 从路由中移除租户数据库，调用方需持有锁
+func (r *Router) remove(pool *tenantPool) {
+	delete(r.pools, pool.tenant)
+	r.lru.Remove(pool.elem)
+	pool.removed = true
+}
+
+// evictIdleLoop 定期关闭空闲超时的租户数据库
+func (r *Router) evictIdleLoop() {
+	ticker := time.NewTicker(r.idleTimeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-ticker.C:
+			r.evictIdle()
+		}
+	}
+}
+
+func (r *Router) evictIdle() {
+	var evicted []*tenantPool
+	r.mu.Lock()
+	for elem := r.lru.Back(); elem != nil; {
+		pool := elem.Value.(*tenantPool)
+		elem = elem.Prev()
+		if pool.refs == 0 && time.Since(pool.lastUsed) > r.idleTimeout {
+			r.remove(pool)
+			evicted = append(evicted, pool)
+		}
+	}
+	r.mu.Unlock()
+
+	for _, pool := range evicted {
+		pool.db.Close()
+	}
+}
+
+// Evict 关闭租户的数据库，如租户下线或配置变更，使用中的数据库在使用结束后关闭
+func (r *Router) Evict(tenant string) error {
+	r.mu.Lock()
+	pool, ok := r.pools[tenant]
+	if !ok {
+		r.mu.Unlock()
+		return nil
+	}
+	r.remove(pool)
+	closeNow := pool.refs == 0
+	r.mu.Unlock()
+
+	if closeNow {
+		return pool.db.Close()
+	}
+	return nil
+}
+
+// Tenants 返回当前已打开数据库的租户，按最近使用排序
+func (r *Router) Tenants() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tenants := make([]string, 0, r.lru.Len())
+	for elem := r.lru.Front(); elem != nil; elem = elem.Next() {
+		tenants = append(tenants, elem.Value.(*tenantPool).tenant)
+	}
+	return tenants
+}
+
+func (r *Router) Migrate(ctx context.Context, model *TableModel) error {
+	pool, err := r.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer r.release(pool)
+	return pool.db.Migrate(ctx, model)
+}
+
+func (r *Router) DropTable(ctx context.Context, table string) error {
+	pool, err := r.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer r.release(pool)
+	return pool.db.DropTable(ctx, table)
+}
+
+func (r *Router) Create(ctx context.Context, table string, record Record, opts ...CreateOption) error {
+	pool, err := r.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer r.release(pool)
+	return pool.db.Create(ctx, table, record, opts...)
+}
+
+func (r *Router) Get(ctx context.Context, table string, pk map[string]any, opts ...QueryOption) (Record, error) {
+	pool, err := r.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer r.release(pool)
+	return pool.db.Get(ctx, table, pk, opts...)
+}
+
+func (r *Router) Update(ctx context.Context, table string, pk map[string]any, record Record, opts ...UpdateOption) error {
+	pool, err := r.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer r.release(pool)
+	return pool.db.Update(ctx, table, pk, record, opts...)
+}
+
+func (r *Router) UpdatePartial(ctx context.Context, table string, pk map[string]any, fields map[string]any, opts ...UpdateOption) error {
+	pool, err := r.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer r.release(pool)
+	return pool.db.UpdatePartial(ctx, table, pk, fields, opts...)
+}
+
+func (r *Router) Upsert(ctx context.Context, table string, pk map[string]any, record Record) error {
+	pool, err := r.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer r.release(pool)
+	return pool.db.Upsert(ctx, table, pk, record)
+}
+
+func (r *Router) Increment(ctx context.Context, table string, pk map[string]any, field string, delta any) error {
+	pool, err := r.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer r.release(pool)
+	return pool.db.Increment(ctx, table, pk, field, delta)
+}
+
+func (r *Router) Delete(ctx context.Context, table string, pk map[string]any) error {
+	pool, err := r.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer r.release(pool)
+	return pool.db.Delete(ctx, table, pk)
+}
+
+func (r *Router) Find(ctx context.Context, table string, query query.Query, opts ...QueryOption) ([]Record, error) {
+	pool, err := r.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer r.release(pool)
+	return pool.db.Find(ctx, table, query, opts...)
+}
+
+// Explain 返回当前租户的数据库生成的查询语句
+func (r *Router) Explain(ctx context.Context, table string, query query.Query, opts ...QueryOption) (*ExplainResult, error) {
+	pool, err := r.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer r.release(pool)
+	return Explain(ctx, pool.db, table, query, opts...)
+}
+
+// FindStream 流式查询，游标关闭前租户数据库不会被淘汰
+func (r *Router) FindStream(ctx context.Context, table string, query query.Query, opts ...QueryOption) (RecordCursor, error) {
+	pool, err := r.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cursor, err := pool.db.FindStream(ctx, table, query, opts...)
+	if err != nil {
+		r.release(pool)
+		return nil, err
+	}
+	return &routerCursor{RecordCursor: cursor, release: r.releaseOnce(pool)}, nil
+}
+
+func (r *Router) Count(ctx context.Context, table string, query query.Query) (int64, error) {
+	pool, err := r.acquire(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer r.release(pool)
+	return pool.db.Count(ctx, table, query)
+}
+
+func (r *Router) Exists(ctx context.Context, table string, pk map[string]any) (bool, error) {
+	pool, err := r.acquire(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer r.release(pool)
+	return pool.db.Exists(ctx, table, pk)
+}
+
+func (r *Router) Aggregate(ctx context.Context, table string, query query.Query, aggs []aggregation.Aggregation, opts ...QueryOption) (aggregation.AggregationResult, error) {
+	pool, err := r.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer r.release(pool)
+	return pool.db.Aggregate(ctx, table, query, aggs, opts...)
+}
+
+func (r *Router) BatchCreate(ctx context.Context, table string, records []Record, opts ...CreateOption) error {
+	pool, err := r.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer r.release(pool)
+	return pool.db.BatchCreate(ctx, table, records, opts...)
+}
+
+func (r *Router) BatchUpdate(ctx context.Context, table string, pks []map[string]any, records []Record) error {
+	pool, err := r.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer r.release(pool)
+	return pool.db.BatchUpdate(ctx, table, pks, records)
+}
+
+func (r *Router) BatchDelete(ctx context.Context, table string, pks []map[string]any) error {
+	pool, err := r.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer r.release(pool)
+	return pool.db.BatchDelete(ctx, table, pks)
+}
+
+// BeginTx 在租户数据库上开始事务，事务结束前租户数据库不会被淘汰
+func (r *Router) BeginTx(ctx context.Context) (Transaction, error) {
+	pool, err := r.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := pool.db.BeginTx(ctx)
+	if err != nil {
+		r.release(pool)
+		return nil, err
+	}
+	return &routerTransaction{Transaction: tx, release: r.releaseOnce(pool)}, nil
+}
+
+func (r *Router) WithTx(ctx context.Context, fn func(tx Transaction) error) error {
+	pool, err := r.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer r.release(pool)
+	return pool.db.WithTx(ctx, fn)
+}
+
+// GetBuilder 获取记录构建器，与第一个创建的租户数据库一致，尚未创建租户数据库时使用 SQL 记录构建器
+func (r *Router) GetBuilder() RecordBuilder {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.builder == nil {
+		return &SQLRecordBuilder{}
+	}
+	return r.builder
+}
+
+// Health context 中有租户时检查该租户的数据库，否则检查所有已打开的租户数据库
+func (r *Router) Health(ctx context.Context) error {
+	if _, ok := TenantFromContext(ctx); ok {
+		pool, err := r.acquire(ctx)
+		if err != nil {
+			return err
+		}
+		defer r.release(pool)
+		return pool.db.Health(ctx)
+	}
+
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return ErrRouterClosed
+	}
+	pools := make([]*tenantPool, 0, len(r.pools))
+	for _, pool := range r.pools {
+		pool.refs++
+		pools = append(pools, pool)
+	}
+	r.mu.Unlock()
+
+	var firstErr error
+	for _, pool := range pools {
+		if err := pool.db.Health(ctx); err != nil && firstErr == nil {
+			firstErr = errors.WithMessagef(err, "tenant %s", pool.tenant)
+		}
+		r.release(pool)
+	}
+	return firstErr
+}
+
+// Close 关闭所有租户数据库，使用中的数据库在使用结束后关闭
+func (r *Router) Close() error {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return nil
+	}
+	r.closed = true
+	close(r.done)
+
+	var idle []*tenantPool
+	for _, pool := range r.pools {
+		r.remove(pool)
+		if pool.refs == 0 {
+			idle = append(idle, pool)
+		}
+	}
+	r.mu.Unlock()
+
+	var firstErr error
+	for _, pool := range idle {
+		if err := pool.db.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (r *Router) releaseOnce(pool *tenantPool) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() { r.release(pool) })
+	}
+}
+
+// routerCursor 关闭时释放租户数据库的引用
+type routerCursor struct {
+	RecordCursor
+	release func()
+}
+
+func (c *routerCursor) Close() error {
+	defer c.release()
+	return c.RecordCursor.Close()
+}
+
+// routerTransaction 提交或回滚时释放租户数据库的引用
+type routerTransaction struct {
+	Transaction
+	release func()
+}
+
+func (tx *routerTransaction) Commit() error {
+	defer tx.release()
+	return tx.Transaction.Commit()
+}
+
+func (tx *routerTransaction) Rollback() error {
+	defer tx.release()
+	return tx.Transaction.Rollback()
+}
@@ -0,0 +1,214 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hatlonely/gox/cfg/storage"
+	"github.com/hatlonely/gox/rdb/query"
+	"github.com/hatlonely/gox/ref"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// testRouterTemplate 每个租户一个独立的 SQLite 内存数据库
+func testRouterTemplate() *ref.TypeOptions {
+	return &ref.TypeOptions{
+		Namespace: "github.com/hatlonely/gox/rdb/database",
+		Type:      "SQL",
+		Options: map[string]any{
+			"driver":   "sqlite3",
+			"database": "file:router_{tenant}?mode=memory&cache=shared",
+			"maxConns": 1,
+			"maxIdle":  1,
+		},
+	}
+}
+
+var testRouterModel = &TableModel{
+	Table: "test_router_users",
+	Fields: []FieldDefinition{
+		{Name: "id", Type: FieldTypeInt, Required: true},
+		{Name: "name", Type: FieldTypeString, Size: 100},
+	},
+	PrimaryKey: []string{"id"},
+}
+
+func TestRouter(t *testing.T) {
+	Convey("测试 Router 按租户路由", t, func() {
+		router, err := NewRouterWithOptions(&RouterOptions{Database: testRouterTemplate(), MaxPools: 2})
+		So(err, ShouldBeNil)
+		defer router.Close()
+
+		ctxA := WithTenant(context.Background(), "a")
+		ctxB := WithTenant(context.Background(), "b")
+		So(router.Migrate(ctxA, testRouterModel), ShouldBeNil)
+		So(router.Migrate(ctxB, testRouterModel), ShouldBeNil)
+
+		Convey("context 中没有租户", func() {
+			_, err := router.Count(context.Background(), "test_router_users", &query.TermQuery{Field: "id", Value: 1})
+			So(err, ShouldEqual, ErrTenantRequired)
+		})
+
+		Convey("租户之间数据隔离", func() {
+			So(router.Create(ctxA, "test_router_users", router.GetBuilder().FromMap(map[string]any{"id": 1, "name": "alice"}, "test_router_users")), ShouldBeNil)
+
+			exists, err := router.Exists(ctxA, "test_router_users", map[string]any{"id": 1})
+			So(err, ShouldBeNil)
+			So(exists, ShouldBeTrue)
+			exists, err = router.Exists(ctxB, "test_router_users", map[string]any{"id": 1})
+			So(err, ShouldBeNil)
+			So(exists, ShouldBeFalse)
+
+			So(router.Health(ctxA), ShouldBeNil)
+			So(router.Health(context.Background()), ShouldBeNil)
+		})
+
+		Convey("超过上限时淘汰最久未使用的租户", func() {
+			So(router.Tenants(), ShouldResemble, []string{"b", "a"})
+
+			ctxC := WithTenant(context.Background(), "c")
+			So(router.Migrate(ctxC, testRouterModel), ShouldBeNil)
+			So(router.Tenants(), ShouldResemble, []string{"c", "b"})
+
+			// 被淘汰的租户再次访问时重新创建，内存数据库已随连接关闭而清空
+			_, err := router.Count(ctxA, "test_router_users", &query.TermQuery{Field: "id", Value: 1})
+			So(err, ShouldNotBeNil)
+			So(router.Tenants(), ShouldResemble, []string{"a", "c"})
+		})
+
+		Convey("使用中的租户不被淘汰", func() {
+			tx, err := router.BeginTx(ctxA)
+			So(err, ShouldBeNil)
+			cursor, err := router.FindStream(ctxB, "test_router_users", &query.TermQuery{Field: "id", Value: 1})
+			So(err, ShouldBeNil)
+
+			ctxC := WithTenant(context.Background(), "c")
+			So(router.Migrate(ctxC, testRouterModel), ShouldEqual, ErrTooManyTenants)
+
+			So(cursor.Close(), ShouldBeNil)
+			So(router.Migrate(ctxC, testRouterModel), ShouldBeNil)
+			So(router.Tenants(), ShouldResemble, []string{"c", "a"})
+
+			So(tx.Rollback(), ShouldBeNil)
+		})
+
+		Convey("主动淘汰租户", func() {
+			So(router.Evict("a"), ShouldBeNil)
+			So(router.Evict("not_exists"), ShouldBeNil)
+			So(router.Tenants(), ShouldResemble, []string{"b"})
+		})
+
+		Convey("非法的租户标识", func() {
+			err := router.Migrate(WithTenant(context.Background(), "a;drop"), testRouterModel)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("关闭后不可用", func() {
+			So(router.Close(), ShouldBeNil)
+			So(router.Tenants(), ShouldBeEmpty)
+			So(router.Migrate(ctxA, testRouterModel), ShouldEqual, ErrRouterClosed)
+		})
+	})
+
+	Convey("测试 Router 空闲超时", t, func() {
+		router, err := NewRouterWithOptions(&RouterOptions{Database: testRouterTemplate(), IdleTimeout: 20 * time.Millisecond})
+		So(err, ShouldBeNil)
+		defer router.Close()
+
+		So(router.Migrate(WithTenant(context.Background(), "idle"), testRouterModel), ShouldBeNil)
+		So(router.Tenants(), ShouldResemble, []string{"idle"})
+
+		time.Sleep(100 * time.Millisecond)
+		So(router.Tenants(), ShouldBeEmpty)
+	})
+
+	Convey("测试 Router 租户单独配置", t, func() {
+		vip := testRouterTemplate()
+		vip.Options = storage.NewMapStorage(map[string]any{
+			"driver":   "sqlite3",
+			"database": "file:router_vip_dedicated?mode=memory&cache=shared",
+		})
+		db, err := NewDatabaseWithOptions(&ref.TypeOptions{
+			Namespace: "github.com/hatlonely/gox/rdb/database",
+			Type:      "Router",
+			Options: &RouterOptions{
+				Tenants: map[string]*ref.TypeOptions{"vip": vip},
+			},
+		})
+		So(err, ShouldBeNil)
+		defer db.Close()
+
+		So(db.Migrate(WithTenant(context.Background(), "vip"), testRouterModel), ShouldBeNil)
+		So(db.Migrate(WithTenant(context.Background(), "other"), testRouterModel), ShouldNotBeNil)
+	})
+
+	Convey("测试 Router 创建租户数据库不受发起者取消的影响", t, func() {
+		var calls atomic.Int32
+		router := NewRouter(func(ctx context.Context, tenant string) (Database, error) {
+			calls.Add(1)
+			if _, ok := ctx.Deadline(); !ok {
+				return nil, errors.New("open context has no deadline")
+			}
+			select {
+			case <-time.After(50 * time.Millisecond):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+			return NewSQLWithOptions(&SQLOptions{Driver: "sqlite3", Database: ":memory:"})
+		}, 0, 0)
+		defer router.Close()
+
+		leaderCtx, cancel := context.WithCancel(WithTenant(context.Background(), "a"))
+		leaderErr := make(chan error, 1)
+		go func() {
+			_, err := router.acquire(leaderCtx)
+			leaderErr <- err
+		}()
+		time.Sleep(10 * time.Millisecond)
+
+		waiterErr := make(chan error, 1)
+		go func() {
+			pool, err := router.acquire(WithTenant(context.Background(), "a"))
+			if err == nil {
+				router.release(pool)
+			}
+			waiterErr <- err
+		}()
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+
+		So(<-leaderErr, ShouldEqual, context.Canceled)
+		So(<-waiterErr, ShouldBeNil)
+		So(calls.Load(), ShouldEqual, 1)
+	})
+
+	Convey("测试 Router 配置校验", t, func() {
+		_, err := NewRouterWithOptions(nil)
+		So(err, ShouldNotBeNil)
+		_, err = NewRouterWithOptions(&RouterOptions{})
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestReplaceTenantPlaceholder(t *testing.T) {
+	Convey("测试替换租户占位符", t, func() {
+		template := map[string]any{
+			"database": "db_{tenant}",
+			"port":     3306,
+			"hosts":    []any{"{tenant}.local", "backup"},
+			"nested":   map[string]any{"schema": "{tenant}"},
+		}
+
+		So(replaceTenantPlaceholder(template, "t1"), ShouldResemble, map[string]any{
+			"database": "db_t1",
+			"port":     3306,
+			"hosts":    []any{"t1.local", "backup"},
+			"nested":   map[string]any{"schema": "t1"},
+		})
+		// 不修改模板
+		So(template["database"], ShouldEqual, "db_{tenant}")
+	})
+}
@@ -0,0 +1,180 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/hatlonely/gox/log"
+	"github.com/hatlonely/gox/log/logger"
+	"github.com/hatlonely/gox/rdb/query"
+	"github.com/hatlonely/gox/ref"
+	"github.com/pkg/errors"
+)
+
+// SlowQueryOptions 慢查询日志配置
+type SlowQueryOptions struct {
+	// Threshold 耗时超过该值的操作输出慢查询日志，为 0 时不输出
+	Threshold time.Duration `cfg:"threshold"`
+	// Logger 日志器配置，为空时使用默认日志器
+	Logger *ref.TypeOptions `cfg:"logger"`
+}
+
+// InterceptorDatabaseOptions 拦截器包装配置，用于通过配置为数据库启用内置的拦截器
+type InterceptorDatabaseOptions struct {
+	// Database 被包装的底层数据库配置
+	Database *ref.TypeOptions `cfg:"database" validate:"required"`
+	// SlowQuery 慢查询日志
+	SlowQuery *SlowQueryOptions `cfg:"slowQuery"`
+	// Retry 临时错误重试，使用 DefaultErrorClassifier 判定临时错误
+	Retry *TransientRetryOptions `cfg:"retry"`
+	// Metrics 操作耗时和错误数指标，注册到 prometheus.DefaultRegisterer
+	Metrics *MetricsOptions `cfg:"metrics"`
+	// Blob 大字段存储
+	Blob *BlobOptions `cfg:"blob"`
+	// Audit 审计日志，创建时迁移审计日志表
+	Audit *AuditOptions `cfg:"audit"`
+}
+
+// NewInterceptorDatabaseWithOptions 使用配置创建拦截器包装
+func NewInterceptorDatabaseWithOptions(options *InterceptorDatabaseOptions) (*InterceptorDatabase, error) {
+	if options == nil {
+		return nil, errors.New("options is nil")
+	}
+
+	db, err := NewDatabaseWithOptions(options.Database)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to create underlying database")
+	}
+
+	var interceptors []Interceptor
+	if options.Metrics != nil {
+		metrics, err := NewMetrics(nil, options.Metrics.Buckets)
+		if err != nil {
+			db.Close()
+			return nil, errors.WithMessage(err, "failed to register metrics")
+		}
+		interceptors = append(interceptors, metrics.Interceptor(options.Metrics.Name))
+	}
+	if options.SlowQuery != nil && options.SlowQuery.Threshold > 0 {
+		l, err := log.NewLoggerWithOptions(options.SlowQuery.Logger)
+		if err != nil {
+			db.Close()
+			return nil, errors.WithMessage(err, "failed to create slow query logger")
+		}
+		interceptors = append(interceptors, NewSlowQueryInterceptor(l, options.SlowQuery.Threshold))
+	}
+	if options.Retry != nil && options.Retry.MaxAttempts > 1 {
+		interceptors = append(interceptors, NewRetryInterceptor(options.Retry, nil))
+	}
+	if options.Blob != nil {
+		interceptor, err := newBlobInterceptorWithOptions(db, options.Blob)
+		if err != nil {
+			db.Close()
+			return nil, errors.WithMessage(err, "failed to create blob store")
+		}
+		interceptors = append(interceptors, interceptor)
+	}
+
+	if options.Audit != nil {
+		interceptor, err := newAuditInterceptorWithOptions(db, options.Audit)
+		if err != nil {
+			db.Close()
+			return nil, errors.WithMessage(err, "failed to create audit log")
+		}
+		interceptors = append(interceptors, interceptor)
+	}
+
+	return NewInterceptorDatabase(db, interceptors...), nil
+}
+
+// NewSlowQueryInterceptor 创建慢查询日志拦截器，耗时超过 threshold 的操作以 Warn 级别输出
+//
+// 日志包含表名、操作、耗时以及脱敏后的查询条件，查询条件以 SQL 形式输出，字段值替换为占位符；
+// 主键和更新字段只输出字段名，不输出值
+func NewSlowQueryInterceptor(l logger.Logger, threshold time.Duration) Interceptor {
+	return func(ctx context.Context, op OperationInfo, next Handler) error {
+		start := time.Now()
+		err := next(ctx, op)
+		duration := time.Since(start)
+		if duration < threshold {
+			return err
+		}
+
+		args := []any{
+			"table", op.Table,
+			"operation", string(op.Operation),
+			"duration", duration,
+			"threshold", threshold,
+		}
+		if op.Query != nil {
+			args = append(args, "query", redactQuery(op.Query))
+		}
+		if len(op.PK) > 0 {
+			args = append(args, "pk", sortedKeys(op.PK))
+		}
+		if len(op.Fields) > 0 {
+			args = append(args, "fields", sortedKeys(op.Fields))
+		}
+		if n := max(len(op.Records), len(op.PKs)); n > 0 {
+			args = append(args, "batchSize", n)
+		}
+		if op.InTx {
+			args = append(args, "inTx", true)
+		}
+		if err != nil {
+			args = append(args, "error", err.Error())
+		}
+		l.WarnContext(ctx, "slow query", args...)
+
+		return err
+	}
+}
+
+// redactQuery 返回不含字段值的查询条件
+// 优先使用参数化的 SQL 形式，无法转换为 SQL 时使用 ES 查询结构并将所有值替换为 "?"
+func redactQuery(q query.Query) string {
+	if sql, _, err := q.ToSQL(); err == nil {
+		return sql
+	}
+
+	data, err := json.Marshal(redactValue(q.ToES()))
+	if err != nil {
+		return string(q.Type())
+	}
+	return string(data)
+}
+
+func redactValue(v any) any {
+	switch val := v.(type) {
+	case map[string]any:
+		result := make(map[string]any, len(val))
+		for k, item := range val {
+			result[k] = redactValue(item)
+		}
+		return result
+	case []any:
+		result := make([]any, len(val))
+		for i, item := range val {
+			result[i] = redactValue(item)
+		}
+		return result
+	case []map[string]any:
+		result := make([]any, len(val))
+		for i, item := range val {
+			result[i] = redactValue(item)
+		}
+		return result
+	}
+	return "?"
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
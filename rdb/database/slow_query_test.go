@@ -0,0 +1,109 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hatlonely/gox/log/logger"
+	"github.com/hatlonely/gox/rdb/query"
+	"github.com/hatlonely/gox/ref"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// recordLogger 记录 Warn 日志的日志器
+type recordLogger struct {
+	logger.Logger
+	records []map[string]any
+}
+
+func (l *recordLogger) WarnContext(ctx context.Context, msg string, args ...any) {
+	record := map[string]any{"msg": msg}
+	for i := 0; i+1 < len(args); i += 2 {
+		record[fmt.Sprint(args[i])] = args[i+1]
+	}
+	l.records = append(l.records, record)
+}
+
+func TestSlowQueryInterceptor(t *testing.T) {
+	Convey("测试慢查询日志", t, func() {
+		sql, err := NewSQLWithOptions(&SQLOptions{Driver: "sqlite3", Database: ":memory:", MaxConns: 1, MaxIdle: 1})
+		So(err, ShouldBeNil)
+		defer sql.Close()
+
+		ctx := context.Background()
+		So(sql.Migrate(ctx, &TableModel{
+			Table: "test_slow_users",
+			Fields: []FieldDefinition{
+				{Name: "id", Type: FieldTypeInt, Required: true},
+				{Name: "name", Type: FieldTypeString, Size: 100},
+			},
+			PrimaryKey: []string{"id"},
+		}), ShouldBeNil)
+
+		l := &recordLogger{}
+		slow := func(ctx context.Context, op OperationInfo, next Handler) error {
+			if op.Table == "test_slow_users" && op.Operation != OpCreate {
+				time.Sleep(30 * time.Millisecond)
+			}
+			return next(ctx, op)
+		}
+		db := NewInterceptorDatabase(sql, NewSlowQueryInterceptor(l, 20*time.Millisecond), slow)
+
+		So(db.Create(ctx, "test_slow_users", db.GetBuilder().FromMap(map[string]any{"id": 1, "name": "alice"}, "test_slow_users")), ShouldBeNil)
+		So(l.records, ShouldBeEmpty)
+
+		count, err := db.Count(ctx, "test_slow_users", &query.BoolQuery{Must: []query.Query{
+			&query.TermQuery{Field: "name", Value: "alice"},
+			&query.RangeQuery{Field: "id", Gte: 1},
+		}})
+		So(err, ShouldBeNil)
+		So(count, ShouldEqual, 1)
+
+		So(db.UpdatePartial(ctx, "test_slow_users", map[string]any{"id": 1}, map[string]any{"name": "bob"}), ShouldBeNil)
+		_, err = db.Get(ctx, "test_slow_users", map[string]any{"id": 2})
+		So(err, ShouldEqual, ErrRecordNotFound)
+
+		So(len(l.records), ShouldEqual, 3)
+		So(l.records[0]["msg"], ShouldEqual, "slow query")
+		So(l.records[0]["table"], ShouldEqual, "test_slow_users")
+		So(l.records[0]["operation"], ShouldEqual, "Count")
+		So(l.records[0]["duration"], ShouldBeGreaterThanOrEqualTo, 20*time.Millisecond)
+		So(l.records[0]["query"], ShouldNotContainSubstring, "alice")
+		So(l.records[0]["query"], ShouldContainSubstring, "name = ?")
+
+		So(l.records[1]["operation"], ShouldEqual, "UpdatePartial")
+		So(l.records[1]["pk"], ShouldResemble, []string{"id"})
+		So(l.records[1]["fields"], ShouldResemble, []string{"name"})
+
+		So(l.records[2]["operation"], ShouldEqual, "Get")
+		So(l.records[2]["error"], ShouldEqual, ErrRecordNotFound.Error())
+	})
+
+	Convey("测试查询条件脱敏", t, func() {
+		So(redactQuery(&query.TermQuery{Field: "email", Value: "a@b.com"}), ShouldEqual, "email = ?")
+		So(redactQuery(&query.RawQuery{Mongo: map[string]any{"email": "a@b.com"}, ES: map[string]any{"term": map[string]any{"email": "a@b.com"}}}),
+			ShouldEqual, `{"term":{"email":"?"}}`)
+	})
+
+	Convey("测试通过配置启用慢查询日志", t, func() {
+		db, err := NewDatabaseWithOptions(&ref.TypeOptions{
+			Namespace: "github.com/hatlonely/gox/rdb/database",
+			Type:      "InterceptorDatabase",
+			Options: &InterceptorDatabaseOptions{
+				Database: &ref.TypeOptions{
+					Namespace: "github.com/hatlonely/gox/rdb/database",
+					Type:      "SQL",
+					Options:   &SQLOptions{Driver: "sqlite3", Database: ":memory:", MaxConns: 1, MaxIdle: 1},
+				},
+				SlowQuery: &SlowQueryOptions{Threshold: time.Second},
+			},
+		})
+		So(err, ShouldBeNil)
+		defer db.Close()
+
+		So(len(db.(*InterceptorDatabase).interceptors), ShouldEqual, 1)
+		So(db.Health(context.Background()), ShouldBeNil)
+	})
+}
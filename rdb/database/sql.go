@@ -3,14 +3,21 @@ package database
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
 	"fmt"
+	"io"
 	"reflect"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
+	"github.com/hatlonely/gox/cfg/commonopt"
 	"github.com/hatlonely/gox/rdb/aggregation"
 	"github.com/hatlonely/gox/rdb/query"
+	"github.com/hatlonely/gox/ref"
 	_ "github.com/mattn/go-sqlite3"
 )
 
@@ -25,21 +32,88 @@ type SQLOptions struct {
 	Charset  string `cfg:"charset" def:"utf8mb4"`
 	MaxConns int    `cfg:"maxConns" def:"10"`
 	MaxIdle  int    `cfg:"maxIdle" def:"5"`
+
+	// Compat 兼容模式，目前只支持 mysql，仅用于 sqlite3 驱动
+	// 在 SQLite 上注册 NOW、CONCAT、IF 等 MySQL 函数，UpdateOnConflict 与 ON DUPLICATE KEY UPDATE 一样只更新写入的列，
+	// 字符串列不区分大小写，用于在内存 SQLite 上运行面向 MySQL 编写的单元测试
+	Compat string `cfg:"compat"`
+
+	// TLS MySQL 的 TLS 配置，仅在未配置 DSN 时生效，配置 DSN 时通过 DSN 中的 tls 参数指定
+	TLS *commonopt.TLSOptions `cfg:"tls"`
+
+	// Retry 启动时连接失败的重试策略
+	Retry RetryOptions `cfg:"retry"`
+	// HealthCheckInterval 定期 Ping 的间隔，为 0 时不做定期检查
+	// 连接池会自动重建失效的连接，定期检查用于及时淘汰失效连接
+	HealthCheckInterval time.Duration `cfg:"healthCheckInterval"`
+
+	// Replicas 只读副本的 DSN，驱动与主库相同
+	// 配置后 Get、Find、Count、Exists、Aggregate 等读操作使用副本，写操作和事务使用主库
+	Replicas []string `cfg:"replicas"`
+	// ReplicaSelector 副本选择策略，roundRobin 轮询，latency 选择探测延迟最低的副本
+	ReplicaSelector string `cfg:"replicaSelector" def:"roundRobin"`
+	// ReplicaCheckInterval 副本探测间隔，探测失败的副本暂停使用，没有可用副本时读操作使用主库
+	ReplicaCheckInterval time.Duration `cfg:"replicaCheckInterval" def:"10s"`
+
+	// StatementCacheSize Create、Get、Update、Delete 缓存的语句数，按表名和列集合缓存 SQL 并在主库上预编译，为 0 时不缓存
+	StatementCacheSize int `cfg:"statementCacheSize" def:"256"`
+
+	// CloseTimeout Close 等待进行中的操作和事务完成的最长时间，超时后直接关闭连接，为 0 时不等待
+	CloseTimeout time.Duration `cfg:"closeTimeout" def:"30s"`
+	// TxLeak 事务泄漏检测，开启后超过阈值仍未提交或回滚的事务输出告警日志和开启事务的调用栈
+	TxLeak *TxLeakOptions `cfg:"txLeak"`
+
+	// ChangeProvider 变更订阅的实现，如基于 MySQL binlog 的实现，通过 ref 注册，需要实现 ChangeWatcher
+	// 为空时 Watch 返回 ErrWatchNotSupported
+	ChangeProvider *ref.TypeOptions `cfg:"changeProvider"`
+
+	// AllowMigrations 是否允许 Migrate 和 MigrateDiff 修改表结构，为空时允许
+	// 生产环境配置为 false，表结构变更只能通过 ApplyMigrations 执行，开发和测试环境保持为空以自动建表
+	AllowMigrations *bool `cfg:"allowMigrations"`
+
+	// Metrics 连接池指标，配置后在 prometheus.DefaultRegisterer 中注册 rdb_pool_* 指标，Close 时注销
+	Metrics *MetricsOptions `cfg:"metrics"`
+
+	// TagName 结构体字段与列名对应使用的标签，如 db，用于复用已有结构体的标签
+	TagName string `cfg:"tagName" def:"rdb"`
 }
 
 type SQL struct {
-	db      *sql.DB
-	builder *SQLRecordBuilder
-	driver  string
+	db         *sql.DB
+	replicas   *sqlReplicaPool
+	statements *sqlStatementCache
+	builder    *SQLRecordBuilder
+	driver     string
+	compat     string
+	checker    *healthChecker
+	changes    ChangeWatcher
+
+	ops          *operationTracker
+	closeTimeout time.Duration
+	txLeaks      *txLeakDetector
+	unregister   func()
+	// tlsConfig 向 mysql 驱动注册的 TLS 配置名，Close 时注销
+	tlsConfig string
+	// allowMigrations 为空时允许迁移
+	allowMigrations *bool
 }
 
 func NewSQLWithOptions(options *SQLOptions) (*SQL, error) {
 	dsn := options.DSN
+	var tlsConfig string
 	if dsn == "" {
 		switch options.Driver {
 		case "mysql":
 			dsn = fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=%s&parseTime=True&loc=Local",
 				options.Username, options.Password, options.Host, options.Port, options.Database, options.Charset)
+			if options.TLS != nil {
+				name, err := registerMySQLTLSConfig(options.TLS)
+				if err != nil {
+					return nil, err
+				}
+				tlsConfig = name
+				dsn += "&tls=" + name
+			}
 		case "sqlite3":
 			dsn = options.Database
 		default:
@@ -47,7 +121,25 @@ func NewSQLWithOptions(options *SQLOptions) (*SQL, error) {
 		}
 	}
 
-	db, err := sql.Open(options.Driver, dsn)
+	// 创建失败时注销已注册的 TLS 配置，避免全局注册表泄漏
+	created := false
+	defer func() {
+		if !created {
+			deregisterMySQLTLSConfig(tlsConfig)
+		}
+	}()
+
+	driverName, err := sqlDriverName(options)
+	if err != nil {
+		return nil, err
+	}
+
+	txLeaks, err := newTxLeakDetector(options.TxLeak)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open(driverName, dsn)
 	if err != nil {
 		return nil, err
 	}
@@ -55,23 +147,116 @@ func NewSQLWithOptions(options *SQLOptions) (*SQL, error) {
 	db.SetMaxOpenConns(options.MaxConns)
 	db.SetMaxIdleConns(options.MaxIdle)
 
-	if err := db.Ping(); err != nil {
+	if err := retryConnect(options.Retry, db.Ping); err != nil {
+		db.Close()
 		return nil, err
 	}
 
-	return &SQL{
-		db:      db,
-		builder: &SQLRecordBuilder{},
-		driver:  options.Driver,
-	}, nil
+	s := &SQL{
+		db:           db,
+		builder:      NewSQLRecordBuilder(options.TagName),
+		driver:       options.Driver,
+		compat:       options.Compat,
+		ops:          newOperationTracker(),
+		closeTimeout: options.CloseTimeout,
+		txLeaks:      txLeaks,
+		tlsConfig:    tlsConfig,
+
+		allowMigrations: options.AllowMigrations,
+	}
+	if len(options.Replicas) > 0 {
+		if s.replicas, err = newSQLReplicaPool(options); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	if options.StatementCacheSize > 0 {
+		s.statements = newSQLStatementCache(db, options.StatementCacheSize)
+	}
+	if options.ChangeProvider != nil {
+		if s.changes, err = newChangeProvider(options.ChangeProvider); err != nil {
+			if s.replicas != nil {
+				s.replicas.close()
+			}
+			db.Close()
+			return nil, err
+		}
+	}
+	if s.unregister, err = registerPoolMetrics(options.Metrics, s); err != nil {
+		if s.replicas != nil {
+			s.replicas.close()
+		}
+		db.Close()
+		return nil, err
+	}
+	s.checker = startHealthChecker(options.HealthCheckInterval, s.Health, nil)
+
+	created = true
+	return s, nil
+}
+
+var mysqlTLSConfigID atomic.Int64
+
+// registerMySQLTLSConfig 向 mysql 驱动注册 TLS 配置，返回 DSN 中 tls 参数使用的名称
+func registerMySQLTLSConfig(options *commonopt.TLSOptions) (string, error) {
+	tlsConfig, err := commonopt.NewTLSConfigWithOptions(options)
+	if err != nil {
+		return "", err
+	}
+	name := fmt.Sprintf("gox-%d", mysqlTLSConfigID.Add(1))
+	if err := mysql.RegisterTLSConfig(name, tlsConfig); err != nil {
+		return "", fmt.Errorf("failed to register tls config: %v", err)
+	}
+	return name, nil
+}
+
+// deregisterMySQLTLSConfig 注销 registerMySQLTLSConfig 注册的 TLS 配置，name 为空时不做处理
+func deregisterMySQLTLSConfig(name string) {
+	if name != "" {
+		mysql.DeregisterTLSConfig(name)
+	}
+}
+
+// PoolStats 主库连接池统计
+func (s *SQL) PoolStats() PoolStats {
+	stats := s.db.Stats()
+	return PoolStats{
+		OpenConnections: stats.OpenConnections,
+		InUse:           stats.InUse,
+		Idle:            stats.Idle,
+		WaitCount:       stats.WaitCount,
+		WaitDuration:    stats.WaitDuration,
+	}
 }
 
 type SQLRecord struct {
-	data map[string]any
+	data    map[string]any
+	tagName string
+	score   *float64
+}
+
+// newSQLRecord 由查询结果创建记录，取出相关度评分列
+func newSQLRecord(data map[string]any, tagName string) *SQLRecord {
+	record := &SQLRecord{data: data, tagName: tagName}
+	if value, ok := data[scoreColumn]; ok {
+		delete(data, scoreColumn)
+		if score, ok := toFloat64(normalizeSQLAggValue(value)); ok {
+			record.score = &score
+		}
+	}
+	return record
+}
+
+// Score 返回相关度评分，实现 Scorer 接口
+func (r *SQLRecord) Score() (float64, bool) {
+	if r.score == nil {
+		return 0, false
+	}
+	return *r.score, true
 }
 
 func (r *SQLRecord) Scan(dest any) error {
-	return mapToStruct(r.data, dest)
+	return mapToStruct(r.data, dest, r.tagName)
 }
 
 func (r *SQLRecord) ScanStruct(dest any) error {
@@ -82,19 +267,107 @@ func (r *SQLRecord) Fields() map[string]any {
 	return r.data
 }
 
-type SQLRecordBuilder struct{}
+func (r *SQLRecord) Diff(other Record) map[string]any {
+	return diffFields(r.data, other)
+}
+
+func (r *SQLRecord) withFields(fields map[string]any) Record {
+	return &SQLRecord{data: fields, tagName: r.tagName, score: r.score}
+}
+
+// SQLRecordBuilder SQL 记录构建器，零值使用 rdb 标签
+type SQLRecordBuilder struct {
+	tagName string
+}
+
+// NewSQLRecordBuilder 创建使用 tagName 标签对应结构体字段和列名的构建器，如 "db"，为空时使用 rdb
+// 构建的记录以及查询返回的记录 Scan 时使用同一个标签
+func NewSQLRecordBuilder(tagName string) *SQLRecordBuilder {
+	return &SQLRecordBuilder{tagName: tagName}
+}
+
+// TagName 实现 TagNamer 接口
+func (b *SQLRecordBuilder) TagName() string {
+	return tagNameOrDefault(b.tagName)
+}
 
 func (b *SQLRecordBuilder) FromStruct(v any) Record {
-	data := structToMap(v)
-	return &SQLRecord{data: data}
+	data := structToMap(v, b.tagName)
+	return &SQLRecord{data: data, tagName: b.tagName}
 }
 
 func (b *SQLRecordBuilder) FromMap(data map[string]any, table string) Record {
-	return &SQLRecord{data: data}
+	return &SQLRecord{data: data, tagName: b.tagName}
+}
+
+// sqlStructField 结构体导出字段与列名的对应关系
+type sqlStructField struct {
+	index   int
+	column  string
+	ignored bool // rdb:"-" 的字段写入时跳过
+	json    bool // 结构体、map、slice 字段以 JSON 存储
+}
+
+// sqlStructFieldsKey 字段映射的缓存键，同一个结构体按不同标签解析的结果不同
+type sqlStructFieldsKey struct {
+	rt      reflect.Type
+	tagName string
+}
+
+// sqlStructFields 按结构体类型和标签名缓存字段映射，避免每次转换都解析标签
+var sqlStructFields sync.Map // map[sqlStructFieldsKey][]sqlStructField
+
+// cachedStructFields 获取结构体类型的字段映射，每个类型和标签名只解析一次，tagName 为空时使用 rdb
+func cachedStructFields(rt reflect.Type, tagName string) []sqlStructField {
+	key := sqlStructFieldsKey{rt: rt, tagName: tagNameOrDefault(tagName)}
+	if fields, ok := sqlStructFields.Load(key); ok {
+		return fields.([]sqlStructField)
+	}
+	fields, _ := sqlStructFields.LoadOrStore(key, parseStructFields(rt, key.tagName))
+	return fields.([]sqlStructField)
+}
+
+// parseStructFields 解析结构体导出字段的 tagName 标签，没有标签时使用字段名
+func parseStructFields(rt reflect.Type, tagName string) []sqlStructField {
+	var fields []sqlStructField
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		tag := field.Tag.Get(tagName)
+		fields = append(fields, sqlStructField{index: i, column: tagColumn(field, tag), ignored: tag == "-", json: isJSONFieldType(field.Type)})
+	}
+	return fields
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	valuerType     = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+	sqlScannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+)
+
+// isJSONFieldType 结构体、map、slice（[]byte 除外）及其指针类型的字段以 JSON 存储
+// time.Time 以及自己实现了 driver.Valuer 或 sql.Scanner 的类型（如 uuid.UUID）由驱动处理
+func isJSONFieldType(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == timeType || t.Implements(valuerType) || reflect.PointerTo(t).Implements(valuerType) || reflect.PointerTo(t).Implements(sqlScannerType) {
+		return false
+	}
+	switch t.Kind() {
+	case reflect.Struct, reflect.Map:
+		return true
+	case reflect.Slice:
+		return t.Elem().Kind() != reflect.Uint8
+	}
+	return false
 }
 
-// 辅助函数：结构体转换为 map
-func structToMap(v any) map[string]any {
+// 辅助函数：结构体转换为 map，tagName 为空时使用 rdb 标签
+func structToMap(v any, tagName string) map[string]any {
 	result := make(map[string]any)
 	rv := reflect.ValueOf(v)
 	if rv.Kind() == reflect.Ptr {
@@ -104,64 +377,50 @@ func structToMap(v any) map[string]any {
 		return result
 	}
 
-	rt := rv.Type()
-	for i := 0; i < rv.NumField(); i++ {
-		field := rt.Field(i)
-		if !field.IsExported() {
-			continue
-		}
-
-		// 检查 rdb 标签
-		tag := field.Tag.Get("rdb")
-		if tag == "-" {
+	for _, field := range cachedStructFields(rv.Type(), tagName) {
+		if field.ignored {
 			continue // 跳过被忽略的字段
 		}
-
-		fieldName := field.Name
-		if tag != "" {
-			if idx := strings.Index(tag, ","); idx != -1 {
-				fieldName = tag[:idx]
-			} else {
-				fieldName = tag
-			}
+		value := rv.Field(field.index)
+		if field.json {
+			result[field.column] = jsonFieldValue(value)
+			continue
 		}
-
-		value := rv.Field(i).Interface()
-		result[fieldName] = value
+		result[field.column] = value.Interface()
 	}
 	return result
 }
 
-// 辅助函数：map 转换为结构体
-func mapToStruct(data map[string]any, dest any) error {
+// jsonFieldValue 将结构体、map、slice 字段序列化为 JSON 字符串，nil 值写入 NULL
+// 序列化失败时保留原值，由驱动返回错误
+func jsonFieldValue(value reflect.Value) any {
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice:
+		if value.IsNil() {
+			return nil
+		}
+	}
+	data, err := json.Marshal(value.Interface())
+	if err != nil {
+		return value.Interface()
+	}
+	return string(data)
+}
+
+// 辅助函数：map 转换为结构体，tagName 为空时使用 rdb 标签
+func mapToStruct(data map[string]any, dest any, tagName string) error {
 	rv := reflect.ValueOf(dest)
 	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
 		return fmt.Errorf("dest must be a pointer to struct")
 	}
 
 	rv = rv.Elem()
-	rt := rv.Type()
-
-	for i := 0; i < rv.NumField(); i++ {
-		field := rt.Field(i)
-		if !field.IsExported() {
-			continue
-		}
-
-		fieldName := field.Name
-		if tag := field.Tag.Get("rdb"); tag != "" && tag != "-" {
-			if idx := strings.Index(tag, ","); idx != -1 {
-				fieldName = tag[:idx]
-			} else {
-				fieldName = tag
-			}
-		}
-
-		if value, exists := data[fieldName]; exists && value != nil {
-			fieldValue := rv.Field(i)
+	for _, field := range cachedStructFields(rv.Type(), tagName) {
+		if value, exists := data[field.column]; exists && value != nil {
+			fieldValue := rv.Field(field.index)
 			if fieldValue.CanSet() {
 				if err := setFieldValue(fieldValue, value); err != nil {
-					return fmt.Errorf("failed to set field %s: %v", fieldName, err)
+					return fmt.Errorf("failed to set field %s: %v", field.column, err)
 				}
 			}
 		}
@@ -238,6 +497,30 @@ func setFieldValue(fieldValue reflect.Value, value any) error {
 		return nil
 	}
 
+	// 实现 sql.Scanner 的类型（如 uuid.UUID）由类型自身解析数据库返回的值
+	if fieldValue.CanAddr() {
+		if scanner, ok := fieldValue.Addr().Interface().(sql.Scanner); ok {
+			return scanner.Scan(value)
+		}
+	}
+
+	// JSON 列返回 []byte 或 string，反序列化到结构体、map、slice 字段
+	if isJSONFieldType(fieldType) && fieldValue.CanAddr() {
+		var data []byte
+		switch v := value.(type) {
+		case []byte:
+			data = v
+		case string:
+			data = []byte(v)
+		}
+		if data != nil {
+			if err := json.Unmarshal(data, fieldValue.Addr().Interface()); err != nil {
+				return fmt.Errorf("cannot unmarshal json to %v: %v", fieldType, err)
+			}
+			return nil
+		}
+	}
+
 	if valueType.ConvertibleTo(fieldType) {
 		fieldValue.Set(reflect.ValueOf(value).Convert(fieldType))
 		return nil
@@ -248,8 +531,23 @@ func setFieldValue(fieldValue reflect.Value, value any) error {
 
 // 实现 Database 接口
 func (s *SQL) Migrate(ctx context.Context, model *TableModel) error {
+	if err := checkMigrationsAllowed(ctx, s.allowMigrations, model.Table); err != nil {
+		return err
+	}
+	ctx, done, err := s.ops.enter(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	// 表结构变化后已预编译的语句可能失效
+	defer s.resetStatements()
+
 	// 构建 CREATE TABLE 语句
-	createTableSQL := s.buildCreateTableSQL(model)
+	createTableSQL, err := s.dialect().buildCreateTableSQL(model)
+	if err != nil {
+		return err
+	}
 
 	// 执行创建表语句
 	if _, err := s.db.ExecContext(ctx, createTableSQL); err != nil {
@@ -261,7 +559,13 @@ func (s *SQL) Migrate(ctx context.Context, model *TableModel) error {
 
 	// 创建索引
 	for _, index := range model.Indexes {
-		indexSQL := s.buildCreateIndexSQL(model.Table, index)
+		indexSQL, err := s.dialect().buildCreateIndexSQL(model.Table, index)
+		if err != nil {
+			return err
+		}
+		if indexSQL == "" {
+			continue
+		}
 		if _, err := s.db.ExecContext(ctx, indexSQL); err != nil {
 			// 如果索引已存在，忽略错误
 			if !strings.Contains(err.Error(), "already exists") &&
@@ -272,152 +576,257 @@ func (s *SQL) Migrate(ctx context.Context, model *TableModel) error {
 		}
 	}
 
+	// 创建或替换视图
+	for _, view := range model.Views {
+		statements, err := s.dialect().buildViewStatements(view)
+		if err != nil {
+			return err
+		}
+		if err := s.applySQLStatements(ctx, statements); err != nil {
+			return fmt.Errorf("failed to create view %s: %w", view.Name, err)
+		}
+	}
+
 	return nil
 }
 
-// buildCreateTableSQL 构建创建表的 SQL 语句
-func (s *SQL) buildCreateTableSQL(model *TableModel) string {
-	var columns []string
-
-	// 构建字段定义
-	for _, field := range model.Fields {
-		columnDef := s.buildColumnDefinition(field)
-		columns = append(columns, columnDef)
+// MigrateDiff 对比现有表结构增量迁移
+// 表不存在时生成 CREATE TABLE，否则为缺失的列生成 ALTER TABLE ADD COLUMN，并为缺失的索引生成 CREATE INDEX
+// MySQL 读取 information_schema，SQLite 读取 PRAGMA table_info 和 sqlite_master
+func (s *SQL) MigrateDiff(ctx context.Context, model *TableModel, opts ...MigrateOption) ([]string, error) {
+	ctx, done, err := s.ops.enter(ctx)
+	if err != nil {
+		return nil, err
 	}
+	defer done()
 
-	// 添加主键定义
-	if len(model.PrimaryKey) > 0 {
-		pkDef := fmt.Sprintf("PRIMARY KEY (%s)", strings.Join(model.PrimaryKey, ", "))
-		columns = append(columns, pkDef)
+	options := &MigrateOptions{}
+	for _, opt := range opts {
+		opt(options)
 	}
-
-	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n  %s\n)",
-		model.Table, strings.Join(columns, ",\n  "))
-}
-
-// buildColumnDefinition 构建单个字段定义
-func (s *SQL) buildColumnDefinition(field FieldDefinition) string {
-	var parts []string
-
-	// 字段名和类型
-	parts = append(parts, field.Name)
-	parts = append(parts, s.mapFieldTypeToSQL(field.Type, field.Size))
-
-	// 是否必需
-	if field.Required {
-		parts = append(parts, "NOT NULL")
+	if !options.DryRun {
+		if err := checkMigrationsAllowed(ctx, s.allowMigrations, model.Table); err != nil {
+			return nil, err
+		}
 	}
 
-	// 默认值
-	if field.Default != nil {
-		defaultValue := s.formatDefaultValue(field.Default)
-		parts = append(parts, fmt.Sprintf("DEFAULT %s", defaultValue))
+	if err := validateSQLIdentifier("table", model.Table); err != nil {
+		return nil, err
+	}
+	columns, indexes, err := s.inspectTable(ctx, model.Table)
+	if err != nil {
+		return nil, err
 	}
 
-	return strings.Join(parts, " ")
-}
-
-// mapFieldTypeToSQL 将字段类型映射为 SQL 类型
-func (s *SQL) mapFieldTypeToSQL(fieldType FieldType, size int) string {
-	switch fieldType {
-	case FieldTypeString:
-		if s.driver == "sqlite3" {
-			return "TEXT"
-		}
-		if size > 0 {
-			return fmt.Sprintf("VARCHAR(%d)", size)
-		}
-		return "VARCHAR(255)"
-	case FieldTypeInt:
-		if s.driver == "sqlite3" {
-			return "INTEGER"
-		}
-		return "INT"
-	case FieldTypeFloat:
-		if s.driver == "sqlite3" {
-			return "REAL"
+	var statements []string
+	if len(columns) == 0 {
+		statement, err := s.dialect().buildCreateTableSQL(model)
+		if err != nil {
+			return nil, err
 		}
-		return "FLOAT"
-	case FieldTypeBool:
-		if s.driver == "sqlite3" {
-			return "INTEGER"
+		statements = append(statements, statement)
+	} else {
+		if err := s.dialect().checkFullTextIndexes(model); err != nil {
+			return nil, err
 		}
-		return "BOOLEAN"
-	case FieldTypeDate:
-		if s.driver == "sqlite3" {
-			return "TEXT"
+		for _, field := range model.Fields {
+			if !columns[strings.ToLower(field.Name)] {
+				statement, err := s.dialect().buildAddColumnSQL(model.Table, field)
+				if err != nil {
+					return nil, err
+				}
+				statements = append(statements, statement)
+			}
 		}
-		return "DATETIME"
-	case FieldTypeJSON:
-		if s.driver == "mysql" {
-			return "JSON"
+	}
+	for _, index := range model.Indexes {
+		if !indexes[strings.ToLower(index.Name)] {
+			statement, err := s.dialect().buildCreateIndexSQL(model.Table, index)
+			if err != nil {
+				return nil, err
+			}
+			if statement == "" {
+				continue
+			}
+			statements = append(statements, statement)
 		}
-		return "TEXT"
-	default:
-		if s.driver == "sqlite3" {
-			return "TEXT"
+	}
+
+	if options.DryRun {
+		return statements, nil
+	}
+	for _, statement := range statements {
+		if _, err := s.db.ExecContext(ctx, statement); err != nil {
+			return nil, fmt.Errorf("failed to execute %q: %v", statement, err)
 		}
-		return "VARCHAR(255)"
 	}
+
+	return statements, nil
 }
 
-// formatDefaultValue 格式化默认值
-func (s *SQL) formatDefaultValue(value any) string {
-	switch v := value.(type) {
-	case string:
-		return fmt.Sprintf("'%s'", strings.ReplaceAll(v, "'", "''"))
-	case bool:
-		if v {
-			return "1"
-		}
-		return "0"
+// inspectTable 查询表的现有列和索引，名称统一为小写，表不存在时返回空集合
+func (s *SQL) inspectTable(ctx context.Context, table string) (map[string]bool, map[string]bool, error) {
+	var columnSQL, indexSQL string
+	var args []any
+	switch s.driver {
+	case "mysql":
+		columnSQL = "SELECT COLUMN_NAME FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ?"
+		indexSQL = "SELECT DISTINCT INDEX_NAME FROM information_schema.STATISTICS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ?"
+		args = []any{table}
+	case "sqlite3":
+		columnSQL = fmt.Sprintf("SELECT name FROM pragma_table_info('%s')", strings.ReplaceAll(table, "'", "''"))
+		// 全文索引以同名的触发器记录
+		indexSQL = "SELECT name FROM sqlite_master WHERE type IN ('index', 'trigger') AND tbl_name = ?"
 	default:
-		return fmt.Sprintf("%v", v)
+		return nil, nil, fmt.Errorf("migrate diff not supported for driver: %s", s.driver)
 	}
+
+	columns, err := s.queryNames(ctx, columnSQL, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	indexes, err := s.queryNames(ctx, indexSQL, table)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return columns, indexes, nil
 }
 
-// buildCreateIndexSQL 构建创建索引的 SQL 语句
-func (s *SQL) buildCreateIndexSQL(table string, index IndexDefinition) string {
-	indexType := "INDEX"
-	if index.Unique {
-		indexType = "UNIQUE INDEX"
+// queryNames 查询单列名称集合
+func (s *SQL) queryNames(ctx context.Context, sqlStr string, args ...any) (map[string]bool, error) {
+	rows, err := s.db.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return nil, err
 	}
+	defer rows.Close()
 
-	// MySQL 不支持 IF NOT EXISTS 语法用于索引
-	if s.driver == "mysql" {
-		return fmt.Sprintf("CREATE %s %s ON %s (%s)",
-			indexType, index.Name, table, strings.Join(index.Fields, ", "))
+	names := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names[strings.ToLower(name)] = true
 	}
 
-	return fmt.Sprintf("CREATE %s IF NOT EXISTS %s ON %s (%s)",
-		indexType, index.Name, table, strings.Join(index.Fields, ", "))
+	return names, rows.Err()
 }
 
 func (s *SQL) DropTable(ctx context.Context, table string) error {
-	sqlStr := fmt.Sprintf("DROP TABLE IF EXISTS %s", table)
-	_, err := s.db.ExecContext(ctx, sqlStr)
+	ctx, done, err := s.ops.enter(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	sqlStr, err := s.dialect().buildDropTableSQL(table)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, sqlStr)
+	s.resetStatements()
 	return err
 }
 
+// Stats 获取表统计信息
+// MySQL 读取 information_schema.TABLES，行数为 InnoDB 估算值；
+// SQLite 使用 COUNT(*) 统计行数，大小依赖 dbstat 虚拟表，未编译 dbstat 时为 0
+func (s *SQL) Stats(ctx context.Context, table string) (*TableStats, error) {
+	ctx, done, err := s.ops.enter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	stats := &TableStats{}
+
+	switch s.driver {
+	case "mysql":
+		sqlStr := "SELECT COALESCE(TABLE_ROWS, 0), COALESCE(DATA_LENGTH, 0), COALESCE(INDEX_LENGTH, 0) FROM information_schema.TABLES WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ?"
+		err := s.db.QueryRowContext(ctx, sqlStr, table).Scan(&stats.RowCount, &stats.DataSize, &stats.IndexSize)
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("table %s not found", table)
+		}
+		if err != nil {
+			return nil, err
+		}
+	case "sqlite3":
+		sqlStr, _, err := s.dialect().buildCountSQL(table, &query.BoolQuery{})
+		if err != nil {
+			return nil, err
+		}
+		if err := s.db.QueryRowContext(ctx, sqlStr).Scan(&stats.RowCount); err != nil {
+			return nil, err
+		}
+
+		var dataSize, indexSize sql.NullInt64
+		err = s.db.QueryRowContext(ctx, "SELECT SUM(pgsize) FROM dbstat WHERE name = ?", table).Scan(&dataSize)
+		if err == nil {
+			s.db.QueryRowContext(ctx, "SELECT SUM(pgsize) FROM dbstat WHERE name IN (SELECT name FROM sqlite_master WHERE type = 'index' AND tbl_name = ?)", table).Scan(&indexSize)
+		}
+		stats.DataSize = dataSize.Int64
+		stats.IndexSize = indexSize.Int64
+	default:
+		return nil, fmt.Errorf("stats not supported for driver: %s", s.driver)
+	}
+
+	return stats, nil
+}
+
 func (s *SQL) GetBuilder() RecordBuilder {
 	return s.builder
 }
 
+// Health 通过 Ping 检查数据库连接是否可用
+func (s *SQL) Health(ctx context.Context) error {
+	ctx, done, err := s.ops.enter(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	return s.db.PingContext(ctx)
+}
+
+// Close 拒绝新的操作，等待进行中的操作和事务完成后关闭连接
+// 等待超过 CloseTimeout 时直接关闭，返回 ErrCloseTimeout 并给出被中断的操作数
 func (s *SQL) Close() error {
-	return s.db.Close()
+	s.checker.stop()
+	s.unregister()
+	aborted := s.ops.close(s.closeTimeout)
+	if closer, ok := s.changes.(io.Closer); ok {
+		closer.Close()
+	}
+	if s.replicas != nil {
+		s.replicas.close()
+	}
+	s.resetStatements()
+	err := s.db.Close()
+	deregisterMySQLTLSConfig(s.tlsConfig)
+	return closeError(aborted, err)
+}
+
+// reader 返回读操作使用的连接池
+// 未配置副本、context 要求读主库或没有可用副本时使用主库
+func (s *SQL) reader(ctx context.Context) *sql.DB {
+	if s.replicas == nil || isPrimaryRead(ctx) {
+		return s.db
+	}
+	if db := s.replicas.pick(); db != nil {
+		return db
+	}
+	return s.db
+}
+
+// dialect 返回构建语句使用的 SQL 方言
+func (s *SQL) dialect() sqlDialect {
+	return newSQLDialect(s.driver, s.compat)
 }
 
 // 辅助函数：将参数占位符格式化为对应数据库的格式
 func (s *SQL) formatSQL(sqlStr string, args []any) (string, []any) {
-	if s.driver == "postgres" {
-		// PostgreSQL 使用 $1, $2, $3... 格式
-		count := 1
-		for strings.Contains(sqlStr, "?") {
-			sqlStr = strings.Replace(sqlStr, "?", fmt.Sprintf("$%d", count), 1)
-			count++
-		}
-	}
-	return sqlStr, args
+	return s.dialect().format(sqlStr), args
 }
 
 // 辅助函数：扫描数据库行到 Record
@@ -442,90 +851,74 @@ func (s *SQL) scanRowToRecord(rows *sql.Rows) (Record, error) {
 		data[col] = values[i]
 	}
 
-	return &SQLRecord{data: data}, nil
+	return newSQLRecord(data, s.builder.tagName), nil
 }
 
 // CRUD 操作实现
 func (s *SQL) Create(ctx context.Context, table string, record Record, opts ...CreateOption) error {
+	ctx, done, err := s.ops.enter(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+	ctx, cancel := createContext(ctx, opts)
+	defer cancel()
+
 	// 解析创建选项
 	options := &CreateOptions{}
 	for _, opt := range opts {
 		opt(options)
 	}
 
+	// 列按名称排序，相同列集合的记录生成相同的语句，可以复用缓存
 	fields := record.Fields()
+	columns := sortedKeys(fields)
+	args := sqlColumnValues(fields, columns, make([]any, 0, len(columns)))
 
-	var columns []string
-	var placeholders []string
-	var args []any
-
-	for col, val := range fields {
-		columns = append(columns, col)
-		placeholders = append(placeholders, "?")
-		args = append(args, val)
+	if updateColumns, ok := dirtyUpsertColumns(record, options); ok {
+		_, err = s.execStatement(ctx, sqlStatementKey("upsertDirty", table, columns, updateColumns), func() (string, error) {
+			return s.dialect().buildUpsertSQL(table, columns, updateColumns)
+		}, args)
+		return err
 	}
 
-	var sqlStr string
+	op := "insert"
 	if options.IgnoreConflict {
-		// 使用 INSERT IGNORE 语法忽略冲突
-		if s.driver == "mysql" {
-			sqlStr = fmt.Sprintf("INSERT IGNORE INTO %s (%s) VALUES (%s)",
-				table,
-				strings.Join(columns, ", "),
-				strings.Join(placeholders, ", "))
-		} else {
-			// SQLite 使用 INSERT OR IGNORE
-			sqlStr = fmt.Sprintf("INSERT OR IGNORE INTO %s (%s) VALUES (%s)",
-				table,
-				strings.Join(columns, ", "),
-				strings.Join(placeholders, ", "))
-		}
+		op = "insertIgnore"
 	} else if options.UpdateOnConflict {
-		// 使用 ON DUPLICATE KEY UPDATE 语法在冲突时更新
-		if s.driver == "mysql" {
-			var updateParts []string
-			for col := range fields {
-				updateParts = append(updateParts, fmt.Sprintf("%s = VALUES(%s)", col, col))
-			}
-			sqlStr = fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) ON DUPLICATE KEY UPDATE %s",
-				table,
-				strings.Join(columns, ", "),
-				strings.Join(placeholders, ", "),
-				strings.Join(updateParts, ", "))
-		} else {
-			// SQLite 使用 INSERT OR REPLACE
-			sqlStr = fmt.Sprintf("INSERT OR REPLACE INTO %s (%s) VALUES (%s)",
-				table,
-				strings.Join(columns, ", "),
-				strings.Join(placeholders, ", "))
-		}
-	} else {
-		// 默认的 INSERT 语法
-		sqlStr = fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
-			table,
-			strings.Join(columns, ", "),
-			strings.Join(placeholders, ", "))
+		op = "upsert"
 	}
-
-	sqlStr, args = s.formatSQL(sqlStr, args)
-	_, err := s.db.ExecContext(ctx, sqlStr, args...)
+	_, err = s.execStatement(ctx, sqlStatementKey(op, table, columns), func() (string, error) {
+		return s.dialect().buildInsertSQL(table, columns, options)
+	}, args)
 	return err
 }
 
-func (s *SQL) Get(ctx context.Context, table string, pk map[string]any) (Record, error) {
-	var whereParts []string
-	var args []any
+// dirtyUpsertColumns DirtyRecord 冲突更新时只更新修改过的列
+func dirtyUpsertColumns(record Record, options *CreateOptions) ([]string, bool) {
+	dirty, ok := record.(DirtyRecord)
+	if !ok || !options.UpdateOnConflict || options.IgnoreConflict {
+		return nil, false
+	}
+	return sortedKeys(dirty.DirtyFields()), true
+}
 
-	for col, val := range pk {
-		whereParts = append(whereParts, fmt.Sprintf("%s = ?", col))
-		args = append(args, val)
+func (s *SQL) Get(ctx context.Context, table string, pk map[string]any, opts ...QueryOption) (Record, error) {
+	ctx, done, err := s.ops.enter(ctx)
+	if err != nil {
+		return nil, err
 	}
+	defer done()
+	ctx, cancel := queryContext(ctx, opts)
+	defer cancel()
 
-	sqlStr := fmt.Sprintf("SELECT * FROM %s WHERE %s",
-		table, strings.Join(whereParts, " AND "))
+	columns := sortedKeys(pk)
+	fields := newQueryOptions(opts).Fields
+	args := sqlColumnValues(pk, columns, make([]any, 0, len(columns)))
 
-	sqlStr, args = s.formatSQL(sqlStr, args)
-	rows, err := s.db.QueryContext(ctx, sqlStr, args...)
+	rows, err := s.queryStatement(ctx, sqlStatementKey("get", table, columns, fields), func() (string, error) {
+		return s.dialect().buildGetSQL(table, columns, fields)
+	}, args)
 	if err != nil {
 		return nil, err
 	}
@@ -538,87 +931,157 @@ func (s *SQL) Get(ctx context.Context, table string, pk map[string]any) (Record,
 	return s.scanRowToRecord(rows)
 }
 
-func (s *SQL) Update(ctx context.Context, table string, pk map[string]any, record Record) error {
-	fields := record.Fields()
+func (s *SQL) Update(ctx context.Context, table string, pk map[string]any, record Record, opts ...UpdateOption) error {
+	ctx, done, err := s.ops.enter(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+	ctx, cancel := updateContext(ctx, opts)
+	defer cancel()
 
-	var setParts []string
-	var args []any
+	if newUpdateOptions(opts).versioned() {
+		return s.UpdatePartial(ctx, table, pk, updateFields(record), opts...)
+	}
 
-	for col, val := range fields {
-		setParts = append(setParts, fmt.Sprintf("%s = ?", col))
-		args = append(args, val)
+	fields := updateFields(record)
+	if len(fields) == 0 {
+		return nil
 	}
+	columns := sortedKeys(fields)
+	pkColumns := sortedKeys(pk)
+	args := sqlColumnValues(fields, columns, make([]any, 0, len(columns)+len(pkColumns)))
+	args = sqlColumnValues(pk, pkColumns, args)
 
-	var whereParts []string
-	for col, val := range pk {
-		whereParts = append(whereParts, fmt.Sprintf("%s = ?", col))
-		args = append(args, val)
+	_, err = s.execStatement(ctx, sqlStatementKey("update", table, columns, pkColumns), func() (string, error) {
+		return s.dialect().buildUpdateSQL(table, columns, pkColumns)
+	}, args)
+	return err
+}
+
+// UpdatePartial 只更新 fields 中的列
+func (s *SQL) UpdatePartial(ctx context.Context, table string, pk map[string]any, fields map[string]any, opts ...UpdateOption) error {
+	ctx, done, err := s.ops.enter(ctx)
+	if err != nil {
+		return err
 	}
+	defer done()
+	ctx, cancel := updateContext(ctx, opts)
+	defer cancel()
 
-	sqlStr := fmt.Sprintf("UPDATE %s SET %s WHERE %s",
-		table,
-		strings.Join(setParts, ", "),
-		strings.Join(whereParts, " AND "))
+	options := newUpdateOptions(opts)
+	sqlStr, args, err := s.dialect().buildUpdatePartialSQL(table, pk, fields, options)
+	if err != nil {
+		return err
+	}
 
-	sqlStr, args = s.formatSQL(sqlStr, args)
-	_, err := s.db.ExecContext(ctx, sqlStr, args...)
+	result, err := s.db.ExecContext(ctx, sqlStr, args...)
+	if err != nil || !options.versioned() {
+		return err
+	}
+	return checkVersionedUpdate(result, func() (bool, error) {
+		return s.Exists(ctx, table, pk)
+	})
+}
+
+// Upsert 使用 ON CONFLICT (pk) DO UPDATE 或 ON DUPLICATE KEY UPDATE 按主键插入或更新
+// 冲突时只更新 record 中的列，record 中没有的列保持不变
+func (s *SQL) Upsert(ctx context.Context, table string, pk map[string]any, record Record) error {
+	ctx, done, err := s.ops.enter(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	fields, err := upsertFields(pk, record)
+	if err != nil {
+		return err
+	}
+	columns, pkColumns := sortedKeys(fields), sortedKeys(pk)
+	args := sqlColumnValues(fields, columns, make([]any, 0, len(columns)))
+	_, err = s.execStatement(ctx, sqlStatementKey("upsertPK", table, columns, pkColumns), func() (string, error) {
+		return s.dialect().buildUpsertByPKSQL(table, columns, pkColumns)
+	}, args)
 	return err
 }
 
-func (s *SQL) Delete(ctx context.Context, table string, pk map[string]any) error {
-	var whereParts []string
-	var args []any
+// Increment 使用 SET field = field + ? 原子增减
+func (s *SQL) Increment(ctx context.Context, table string, pk map[string]any, field string, delta any) error {
+	ctx, done, err := s.ops.enter(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
 
-	for col, val := range pk {
-		whereParts = append(whereParts, fmt.Sprintf("%s = ?", col))
-		args = append(args, val)
+	sqlStr, args, err := s.dialect().buildIncrementSQL(table, pk, field, delta)
+	if err != nil {
+		return err
 	}
 
-	sqlStr := fmt.Sprintf("DELETE FROM %s WHERE %s",
-		table, strings.Join(whereParts, " AND "))
+	_, err = s.db.ExecContext(ctx, sqlStr, args...)
+	return err
+}
 
-	sqlStr, args = s.formatSQL(sqlStr, args)
-	_, err := s.db.ExecContext(ctx, sqlStr, args...)
+// checkVersionedUpdate 乐观锁更新没有影响任何行时，区分记录不存在和版本冲突
+func checkVersionedUpdate(result sql.Result, exists func() (bool, error)) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected > 0 {
+		return nil
+	}
+
+	ok, err := exists()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrRecordNotFound
+	}
+	return ErrVersionConflict
+}
+
+func (s *SQL) Delete(ctx context.Context, table string, pk map[string]any) error {
+	ctx, done, err := s.ops.enter(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	columns := sortedKeys(pk)
+	args := sqlColumnValues(pk, columns, make([]any, 0, len(columns)))
+
+	_, err = s.execStatement(ctx, sqlStatementKey("delete", table, columns), func() (string, error) {
+		return s.dialect().buildDeleteSQL(table, columns)
+	}, args)
 	return err
 }
 
 // 查询和聚合功能实现
 func (s *SQL) Find(ctx context.Context, table string, query query.Query, opts ...QueryOption) ([]Record, error) {
+	ctx, done, err := s.ops.enter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	ctx, cancel := queryContext(ctx, opts)
+	defer cancel()
+
 	// 解析查询选项
 	options := &QueryOptions{}
 	for _, opt := range opts {
 		opt(options)
 	}
 
-	// 构建 WHERE 条件
-	whereSQL, whereArgs, err := query.ToSQL()
+	// 构建 SQL，游标分页时追加键集条件
+	sqlStr, whereArgs, err := s.dialect().buildFindSQL(table, query, options)
 	if err != nil {
 		return nil, err
 	}
 
-	// 构建完整 SQL
-	sqlStr := fmt.Sprintf("SELECT * FROM %s WHERE %s", table, whereSQL)
-
-	// 添加排序
-	if options.OrderBy != "" {
-		direction := "ASC"
-		if options.OrderDesc {
-			direction = "DESC"
-		}
-		sqlStr += fmt.Sprintf(" ORDER BY %s %s", options.OrderBy, direction)
-	}
-
-	// 添加分页
-	if options.Limit > 0 {
-		sqlStr += fmt.Sprintf(" LIMIT %d", options.Limit)
-	}
-	if options.Offset > 0 {
-		sqlStr += fmt.Sprintf(" OFFSET %d", options.Offset)
-	}
-
 	// 执行查询
-	sqlStr, whereArgs = s.formatSQL(sqlStr, whereArgs)
-	rows, err := s.db.QueryContext(ctx, sqlStr, whereArgs...)
+	rows, err := s.reader(ctx).QueryContext(ctx, sqlStr, whereArgs...)
 	if err != nil {
 		return nil, err
 	}
@@ -637,100 +1100,429 @@ func (s *SQL) Find(ctx context.Context, table string, query query.Query, opts ..
 	return records, nil
 }
 
+// FindStream 流式查询，基于 sql.Rows 逐行读取
+// 驱动本身按行从连接中读取结果，BatchSize 对 SQL 后端无效
+// 游标关闭前算作进行中的操作，Close 会等待游标关闭
+func (s *SQL) FindStream(ctx context.Context, table string, query query.Query, opts ...QueryOption) (RecordCursor, error) {
+	ctx, done, err := s.ops.enter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cursor, err := streamWithTimeout(ctx, opts, func(ctx context.Context) (RecordCursor, error) {
+		return s.findStream(ctx, table, query, opts...)
+	})
+	if err != nil {
+		done()
+		return nil, err
+	}
+	return &trackedCursor{RecordCursor: cursor, done: done}, nil
+}
+
+func (s *SQL) findStream(ctx context.Context, table string, query query.Query, opts ...QueryOption) (RecordCursor, error) {
+	options := &QueryOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	sqlStr, args, err := s.dialect().buildFindSQL(table, query, options)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.reader(ctx).QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SQLRecordCursor{rows: rows, scan: s.scanRowToRecord}, nil
+}
+
+// SQLRecordCursor 基于 sql.Rows 的记录游标
+type SQLRecordCursor struct {
+	rows   *sql.Rows
+	scan   func(rows *sql.Rows) (Record, error)
+	record Record
+	err    error
+}
+
+func (c *SQLRecordCursor) Next() bool {
+	if c.err != nil || !c.rows.Next() {
+		c.record = nil
+		return false
+	}
+
+	record, err := c.scan(c.rows)
+	if err != nil {
+		c.err = err
+		c.record = nil
+		return false
+	}
+	c.record = record
+	return true
+}
+
+func (c *SQLRecordCursor) Scan(dest any) error {
+	if c.record == nil {
+		return fmt.Errorf("no current record")
+	}
+	return c.record.Scan(dest)
+}
+
+func (c *SQLRecordCursor) Record() Record {
+	return c.record
+}
+
+func (c *SQLRecordCursor) Err() error {
+	if c.err != nil {
+		return c.err
+	}
+	return c.rows.Err()
+}
+
+func (c *SQLRecordCursor) Close() error {
+	return c.rows.Close()
+}
+
+// Explain 返回 Find 生成的 SQL 语句和绑定参数，不执行查询
+func (s *SQL) Explain(ctx context.Context, table string, query query.Query, opts ...QueryOption) (*ExplainResult, error) {
+	options := &QueryOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	sqlStr, args, err := s.dialect().buildFindSQL(table, query, options)
+	if err != nil {
+		return nil, err
+	}
+	return &ExplainResult{Backend: "sql", Statement: sqlStr, Args: args}, nil
+}
+
+// Count 使用 SELECT COUNT(*) 统计记录数
+func (s *SQL) Count(ctx context.Context, table string, query query.Query) (int64, error) {
+	ctx, done, err := s.ops.enter(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer done()
+
+	sqlStr, whereArgs, err := s.dialect().buildCountSQL(table, query)
+	if err != nil {
+		return 0, err
+	}
+
+	var count int64
+	if err := s.reader(ctx).QueryRowContext(ctx, sqlStr, whereArgs...).Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// Exists 根据主键查询单行判断记录是否存在，不读取整行数据
+func (s *SQL) Exists(ctx context.Context, table string, pk map[string]any) (bool, error) {
+	ctx, done, err := s.ops.enter(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer done()
+
+	columns := sortedKeys(pk)
+	sqlStr, err := s.dialect().buildExistsSQL(table, columns)
+	if err != nil {
+		return false, err
+	}
+
+	var one int
+	err = s.reader(ctx).QueryRowContext(ctx, sqlStr, sqlColumnValues(pk, columns, nil)...).Scan(&one)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
 func (s *SQL) Aggregate(ctx context.Context, table string, query query.Query, aggs []aggregation.Aggregation, opts ...QueryOption) (aggregation.AggregationResult, error) {
+	ctx, done, err := s.ops.enter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	ctx, cancel := queryContext(ctx, opts)
+	defer cancel()
+
 	// 解析查询选项
 	options := &QueryOptions{}
 	for _, opt := range opts {
 		opt(options)
 	}
 
+	if err := checkPipelineAggregations("sql", aggs); err != nil {
+		return nil, err
+	}
+	if err := aggregation.ValidateScriptAggregations(aggs); err != nil {
+		return nil, err
+	}
+	if err := validateSQLIdentifier("table", table); err != nil {
+		return nil, err
+	}
+	if err := validateQueryFields(query); err != nil {
+		return nil, err
+	}
+	if err := s.dialect().checkQuerySupport(query); err != nil {
+		return nil, err
+	}
+	if err := validateAggregations(aggs); err != nil {
+		return nil, err
+	}
+	if options.OrderBy != "" {
+		if err := validateSQLIdentifier("order by field", options.OrderBy); err != nil {
+			return nil, err
+		}
+	}
+
 	// 构建 WHERE 条件
-	whereSQL, whereArgs, err := query.ToSQL()
+	where, err := s.dialect().rewriteFullText(table, query)
+	if err != nil {
+		return nil, err
+	}
+	whereSQL, whereArgs, err := where.ToSQL()
 	if err != nil {
 		return nil, err
 	}
 
-	// 构建聚合查询
-	var selectParts []string
-	var groupByParts []string
-
-	for _, agg := range aggs {
-		aggSQL, _, err := agg.ToSQL()
+	// 分组统计：一条 GROUP BY 语句统计每组的全部指标
+	if options.grouping() {
+		group, err := newGroupBy(aggs, options)
 		if err != nil {
 			return nil, err
 		}
-		selectParts = append(selectParts, aggSQL)
+		return s.aggregateGroupBy(ctx, table, whereSQL, whereArgs, group)
+	}
 
-		// 处理桶聚合的 GROUP BY
-		switch agg.Type() {
-		case aggregation.AggTypeTerms:
-			if termsAgg, ok := agg.(*aggregation.TermsAggregation); ok {
-				selectParts = append(selectParts, termsAgg.Field)
-				groupByParts = append(groupByParts, termsAgg.Field)
+	scripts, aggs := aggregation.SplitScriptAggregations(aggs)
+	metrics, buckets := aggregation.SplitAggregations(aggs)
+	result := aggregation.NewAggregationResult()
+
+	// 指标聚合：不分组，一条 SQL 统计全部指标
+	if len(metrics) > 0 {
+		var selectParts []string
+		var args []any
+		for _, agg := range metrics {
+			aggSQL, aggArgs, err := s.dialect().buildMetricSQL(agg)
+			if err != nil {
+				return nil, err
 			}
-		case aggregation.AggTypeDateHisto:
-			if dateHistoAgg, ok := agg.(*aggregation.DateHistogramAggregation); ok {
-				// 简化实现：直接使用字段进行分组
-				selectParts = append(selectParts, dateHistoAgg.Field)
-				groupByParts = append(groupByParts, dateHistoAgg.Field)
+			selectParts = append(selectParts, aggSQL)
+			args = append(args, aggArgs...)
+		}
+		args = append(args, whereArgs...)
+
+		sqlStr := fmt.Sprintf("SELECT %s FROM %s WHERE %s", strings.Join(selectParts, ", "), s.dialect().quote(table), whereSQL)
+		records, err := s.queryAggRecords(ctx, sqlStr, args)
+		if err != nil {
+			return nil, err
+		}
+		if len(records) > 0 {
+			data := records[0].Fields()
+			for _, agg := range metrics {
+				if value, exists := data[agg.Name()]; exists {
+					result.SetResult(agg.Name(), normalizeSQLAggValue(value))
+				}
 			}
 		}
 	}
 
-	// 构建完整 SQL
-	sqlStr := fmt.Sprintf("SELECT %s FROM %s WHERE %s",
-		strings.Join(selectParts, ", "), table, whereSQL)
+	// 桶聚合：逐层 GROUP BY，子聚合挂到对应的父桶下
+	fetch := func(chain []*bucketLevel) ([]bucketRow, error) {
+		return s.fetchBucketRows(ctx, table, whereSQL, whereArgs, chain, options)
+	}
+	for _, bucket := range buckets {
+		tree, err := buildBucketTree([]*bucketLevel{newBucketLevel(bucket)}, fetch)
+		if err != nil {
+			return nil, err
+		}
+		result.SetResult(bucket.Name(), tree[""])
+	}
 
-	if len(groupByParts) > 0 {
-		sqlStr += " GROUP BY " + strings.Join(groupByParts, ", ")
+	// 派生指标：由上面的聚合结果计算
+	if err := applyScriptAggregations(scripts, result.Get, result.SetResult); err != nil {
+		return nil, err
 	}
 
-	// 添加排序
-	if options.OrderBy != "" {
+	return result, nil
+}
+
+// aggregateGroupBy 执行分组统计，结果行按 GroupByResultName 返回
+func (s *SQL) aggregateGroupBy(ctx context.Context, table string, whereSQL string, whereArgs []any, group *groupBy) (aggregation.AggregationResult, error) {
+	sqlStr, args, err := s.dialect().buildGroupBySQL(table, whereSQL, whereArgs, group)
+	if err != nil {
+		return nil, err
+	}
+	records, err := s.queryAggRecords(ctx, sqlStr, args)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]map[string]any, 0, len(records))
+	for _, record := range records {
+		values := record.Fields()
+		for _, metric := range group.metrics {
+			values[metric.Name()] = normalizeSQLAggValue(values[metric.Name()])
+		}
+		row, err := group.row(values)
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+	return group.result(rows), nil
+}
+
+// fetchBucketRows 按层级链的分组表达式执行 GROUP BY，统计当前层级的文档数和指标子聚合
+func (s *SQL) fetchBucketRows(ctx context.Context, table string, whereSQL string, whereArgs []any, chain []*bucketLevel, options *QueryOptions) ([]bucketRow, error) {
+	d := s.dialect()
+	depth := len(chain) - 1
+	level := chain[depth]
+
+	var selectParts []string
+	var groupByParts []string
+	var args []any
+	for i, l := range chain {
+		expr := d.buildGroupExpr(l.agg)
+		selectParts = append(selectParts, fmt.Sprintf("%s AS %s", expr, d.quote(fmt.Sprintf("%s%d", aggKeyFieldPrefix, i))))
+		groupByParts = append(groupByParts, expr)
+	}
+	selectParts = append(selectParts, "COUNT(*) AS "+d.quote(aggDocCountField))
+	for _, metric := range level.metrics {
+		metricSQL, metricArgs, err := d.buildMetricSQL(metric)
+		if err != nil {
+			return nil, err
+		}
+		selectParts = append(selectParts, metricSQL)
+		args = append(args, metricArgs...)
+	}
+	args = append(args, whereArgs...)
+
+	keyField := fmt.Sprintf("%s%d", aggKeyFieldPrefix, depth)
+	sqlStr := fmt.Sprintf("SELECT %s FROM %s WHERE %s GROUP BY %s",
+		strings.Join(selectParts, ", "), d.quote(table), whereSQL, strings.Join(groupByParts, ", "))
+
+	// 排序：词条聚合的 Order 支持 _count、_key 和子聚合名，默认按桶键升序
+	// 排序字段和方向已由 validateAggregations 校验
+	var orderParts []string
+	if termsAgg, ok := level.agg.(*aggregation.TermsAggregation); ok {
+		for field, direction := range termsAgg.Order {
+			switch field {
+			case "_count":
+				field = aggDocCountField
+			case "_key":
+				field = keyField
+			}
+			direction, err := sqlSortDirection(direction)
+			if err != nil {
+				return nil, err
+			}
+			orderParts = append(orderParts, fmt.Sprintf("%s %s", d.quote(field), direction))
+		}
+	}
+	if depth == 0 && options.OrderBy != "" {
 		direction := "ASC"
 		if options.OrderDesc {
 			direction = "DESC"
 		}
-		sqlStr += fmt.Sprintf(" ORDER BY %s %s", options.OrderBy, direction)
+		orderParts = append(orderParts, fmt.Sprintf("%s %s", d.quote(options.OrderBy), direction))
+	}
+	if len(orderParts) == 0 {
+		orderParts = append(orderParts, d.quote(keyField)+" ASC")
 	}
 
-	// 添加分页
-	if options.Limit > 0 {
-		sqlStr += fmt.Sprintf(" LIMIT %d", options.Limit)
+	// 嵌套的词条聚合按父桶分区编号，每个父桶分别取前 Size 个子桶，排序相同时按桶键升序
+	if termsAgg, ok := level.agg.(*aggregation.TermsAggregation); ok && depth > 0 && termsAgg.Size > 0 {
+		partitionParts := make([]string, depth)
+		for i := range partitionParts {
+			partitionParts[i] = d.quote(fmt.Sprintf("%s%d", aggKeyFieldPrefix, i))
+		}
+		rankOrder := strings.Join(append(orderParts[:len(orderParts):len(orderParts)], d.quote(keyField)+" ASC"), ", ")
+		sqlStr = fmt.Sprintf("SELECT * FROM (SELECT %s.*, ROW_NUMBER() OVER (PARTITION BY %s ORDER BY %s) AS %s FROM (%s) %s) %s WHERE %s <= %d",
+			d.quote("g"), strings.Join(partitionParts, ", "), rankOrder, d.quote(aggRankField), sqlStr, d.quote("g"), d.quote("r"), d.quote(aggRankField), termsAgg.Size)
 	}
+	sqlStr += " ORDER BY " + strings.Join(orderParts, ", ")
 
-	// 执行聚合查询
-	sqlStr, whereArgs = s.formatSQL(sqlStr, whereArgs)
-	rows, err := s.db.QueryContext(ctx, sqlStr, whereArgs...)
+	// 分页只作用于顶层桶，嵌套层级的数量由父桶和 Size 决定
+	if depth == 0 {
+		limit := options.Limit
+		if termsAgg, ok := level.agg.(*aggregation.TermsAggregation); ok && termsAgg.Size > 0 {
+			limit = termsAgg.Size
+		}
+		if limit > 0 {
+			sqlStr += fmt.Sprintf(" LIMIT %d", limit)
+		}
+		if options.Offset > 0 {
+			sqlStr += fmt.Sprintf(" OFFSET %d", options.Offset)
+		}
+	}
+
+	records, err := s.queryAggRecords(ctx, sqlStr, args)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
-	// 构建聚合结果
-	result := aggregation.NewAggregationResult()
+	rows := make([]bucketRow, 0, len(records))
+	for _, record := range records {
+		data := record.Fields()
+		row := bucketRow{
+			keys:     make([]interface{}, len(chain)),
+			docCount: toInt64(data[aggDocCountField]),
+			values:   make(map[string]interface{}, len(level.metrics)),
+		}
+		for i := range chain {
+			key := data[fmt.Sprintf("%s%d", aggKeyFieldPrefix, i)]
+			if b, ok := key.([]byte); ok {
+				key = string(b)
+			}
+			row.keys[i] = key
+		}
+		for _, metric := range level.metrics {
+			row.values[metric.Name()] = normalizeSQLAggValue(data[metric.Name()])
+		}
+		rows = append(rows, row)
+	}
 
+	return rows, nil
+}
+
+// queryAggRecords 执行聚合 SQL 并返回全部结果行
+func (s *SQL) queryAggRecords(ctx context.Context, sqlStr string, args []any) ([]Record, error) {
+	sqlStr, args = s.formatSQL(sqlStr, args)
+	rows, err := s.reader(ctx).QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []Record
 	for rows.Next() {
 		record, err := s.scanRowToRecord(rows)
 		if err != nil {
 			return nil, err
 		}
-
-		// 简化处理：将第一个聚合的结果作为主要结果
-		if len(aggs) > 0 {
-			data := record.Fields()
-			aggName := aggs[0].Name()
-			if value, exists := data[aggName]; exists {
-				result.SetResult(aggName, value)
-			}
-		}
+		records = append(records, record)
 	}
 
-	return result, nil
+	return records, rows.Err()
 }
 
 // 批量操作实现
 func (s *SQL) BatchCreate(ctx context.Context, table string, records []Record, opts ...CreateOption) error {
+	ctx, done, err := s.ops.enter(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+	ctx, cancel := createContext(ctx, opts)
+	defer cancel()
+
 	for _, record := range records {
 		if err := s.Create(ctx, table, record, opts...); err != nil {
 			return err
@@ -740,6 +1532,12 @@ func (s *SQL) BatchCreate(ctx context.Context, table string, records []Record, o
 }
 
 func (s *SQL) BatchUpdate(ctx context.Context, table string, pks []map[string]any, records []Record) error {
+	ctx, done, err := s.ops.enter(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
 	if len(pks) != len(records) {
 		return fmt.Errorf("pks and records length mismatch")
 	}
@@ -753,6 +1551,12 @@ func (s *SQL) BatchUpdate(ctx context.Context, table string, pks []map[string]an
 }
 
 func (s *SQL) BatchDelete(ctx context.Context, table string, pks []map[string]any) error {
+	ctx, done, err := s.ops.enter(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
 	for _, pk := range pks {
 		if err := s.Delete(ctx, table, pk); err != nil {
 			return err
@@ -762,16 +1566,33 @@ func (s *SQL) BatchDelete(ctx context.Context, table string, pks []map[string]an
 }
 
 // 事务相关实现
+
+// BeginTx 开启事务，提交或回滚前算作进行中的操作
 func (s *SQL) BeginTx(ctx context.Context) (Transaction, error) {
+	ctx, done, err := s.ops.enter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := s.beginTx(ctx)
+	if err != nil {
+		done()
+		return nil, err
+	}
+	return newTrackedTransaction(ctx, tx, done, s.txLeaks), nil
+}
+
+func (s *SQL) beginTx(ctx context.Context) (Transaction, error) {
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
 
 	return &SQLTransaction{
-		tx:      tx,
-		builder: s.builder,
-		driver:  s.driver,
+		tx:              tx,
+		builder:         s.builder,
+		driver:          s.driver,
+		compat:          s.compat,
+		allowMigrations: s.allowMigrations,
 	}, nil
 }
 
@@ -798,9 +1619,11 @@ func (s *SQL) WithTx(ctx context.Context, fn func(tx Transaction) error) error {
 
 // SQL 事务实现
 type SQLTransaction struct {
-	tx      *sql.Tx
-	builder *SQLRecordBuilder
-	driver  string
+	tx              *sql.Tx
+	builder         *SQLRecordBuilder
+	driver          string
+	compat          string
+	allowMigrations *bool
 }
 
 func (tx *SQLTransaction) Commit() error {
@@ -811,8 +1634,11 @@ func (tx *SQLTransaction) Rollback() error {
 	return tx.tx.Rollback()
 }
 
-// 事务中的 CRUD 操作实现 (复用 SQL 的逻辑，但使用事务连接)
+// 事务中的 CRUD 操作实现 (复用 SQL 的语句构建，但使用事务连接)
 func (tx *SQLTransaction) Create(ctx context.Context, table string, record Record, opts ...CreateOption) error {
+	ctx, cancel := createContext(ctx, opts)
+	defer cancel()
+
 	// 解析创建选项
 	options := &CreateOptions{}
 	for _, opt := range opts {
@@ -820,78 +1646,48 @@ func (tx *SQLTransaction) Create(ctx context.Context, table string, record Recor
 	}
 
 	fields := record.Fields()
+	columns := sortedKeys(fields)
+	var sqlStr string
+	var err error
+	if updateColumns, ok := dirtyUpsertColumns(record, options); ok {
+		sqlStr, err = tx.dialect().buildUpsertSQL(table, columns, updateColumns)
+	} else {
+		sqlStr, err = tx.dialect().buildInsertSQL(table, columns, options)
+	}
+	if err != nil {
+		return err
+	}
 
-	var columns []string
-	var placeholders []string
-	var args []any
+	_, err = tx.tx.ExecContext(ctx, sqlStr, sqlColumnValues(fields, columns, nil)...)
+	return err
+}
 
-	for col, val := range fields {
-		columns = append(columns, col)
-		placeholders = append(placeholders, "?")
-		args = append(args, val)
+func (tx *SQLTransaction) Upsert(ctx context.Context, table string, pk map[string]any, record Record) error {
+	fields, err := upsertFields(pk, record)
+	if err != nil {
+		return err
 	}
-
-	var sqlStr string
-	if options.IgnoreConflict {
-		// 使用 INSERT IGNORE 语法忽略冲突
-		if tx.driver == "mysql" {
-			sqlStr = fmt.Sprintf("INSERT IGNORE INTO %s (%s) VALUES (%s)",
-				table,
-				strings.Join(columns, ", "),
-				strings.Join(placeholders, ", "))
-		} else {
-			// SQLite 使用 INSERT OR IGNORE
-			sqlStr = fmt.Sprintf("INSERT OR IGNORE INTO %s (%s) VALUES (%s)",
-				table,
-				strings.Join(columns, ", "),
-				strings.Join(placeholders, ", "))
-		}
-	} else if options.UpdateOnConflict {
-		// 使用 ON DUPLICATE KEY UPDATE 语法在冲突时更新
-		if tx.driver == "mysql" {
-			var updateParts []string
-			for col := range fields {
-				updateParts = append(updateParts, fmt.Sprintf("%s = VALUES(%s)", col, col))
-			}
-			sqlStr = fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) ON DUPLICATE KEY UPDATE %s",
-				table,
-				strings.Join(columns, ", "),
-				strings.Join(placeholders, ", "),
-				strings.Join(updateParts, ", "))
-		} else {
-			// SQLite 使用 INSERT OR REPLACE
-			sqlStr = fmt.Sprintf("INSERT OR REPLACE INTO %s (%s) VALUES (%s)",
-				table,
-				strings.Join(columns, ", "),
-				strings.Join(placeholders, ", "))
-		}
-	} else {
-		// 默认的 INSERT 语法
-		sqlStr = fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
-			table,
-			strings.Join(columns, ", "),
-			strings.Join(placeholders, ", "))
+	columns := sortedKeys(fields)
+	sqlStr, err := tx.dialect().buildUpsertByPKSQL(table, columns, sortedKeys(pk))
+	if err != nil {
+		return err
 	}
 
-	sqlStr, args = tx.formatSQL(sqlStr, args)
-	_, err := tx.tx.ExecContext(ctx, sqlStr, args...)
+	_, err = tx.tx.ExecContext(ctx, sqlStr, sqlColumnValues(fields, columns, nil)...)
 	return err
 }
 
-func (tx *SQLTransaction) Get(ctx context.Context, table string, pk map[string]any) (Record, error) {
-	var whereParts []string
-	var args []any
+func (tx *SQLTransaction) Get(ctx context.Context, table string, pk map[string]any, opts ...QueryOption) (Record, error) {
+	ctx, cancel := queryContext(ctx, opts)
+	defer cancel()
 
-	for col, val := range pk {
-		whereParts = append(whereParts, fmt.Sprintf("%s = ?", col))
-		args = append(args, val)
+	columns := sortedKeys(pk)
+	sqlStr, err := tx.dialect().buildGetSQL(table, columns, newQueryOptions(opts).Fields)
+	if err != nil {
+		return nil, err
 	}
 
-	sqlStr := fmt.Sprintf("SELECT * FROM %s WHERE %s",
-		table, strings.Join(whereParts, " AND "))
-
-	sqlStr, args = tx.formatSQL(sqlStr, args)
-	rows, err := tx.tx.QueryContext(ctx, sqlStr, args...)
+	rows, err := tx.tx.QueryContext(ctx, sqlStr, sqlColumnValues(pk, columns, nil)...)
 	if err != nil {
 		return nil, err
 	}
@@ -904,101 +1700,141 @@ func (tx *SQLTransaction) Get(ctx context.Context, table string, pk map[string]a
 	return tx.scanRowToRecord(rows)
 }
 
-func (tx *SQLTransaction) Update(ctx context.Context, table string, pk map[string]any, record Record) error {
-	fields := record.Fields()
+func (tx *SQLTransaction) Update(ctx context.Context, table string, pk map[string]any, record Record, opts ...UpdateOption) error {
+	ctx, cancel := updateContext(ctx, opts)
+	defer cancel()
 
-	var setParts []string
-	var args []any
+	if newUpdateOptions(opts).versioned() {
+		return tx.UpdatePartial(ctx, table, pk, updateFields(record), opts...)
+	}
+
+	fields := updateFields(record)
+	if len(fields) == 0 {
+		return nil
+	}
+	columns := sortedKeys(fields)
+	pkColumns := sortedKeys(pk)
+	sqlStr, err := tx.dialect().buildUpdateSQL(table, columns, pkColumns)
+	if err != nil {
+		return err
+	}
+
+	args := sqlColumnValues(fields, columns, make([]any, 0, len(columns)+len(pkColumns)))
+	args = sqlColumnValues(pk, pkColumns, args)
+	_, err = tx.tx.ExecContext(ctx, sqlStr, args...)
+	return err
+}
 
-	for col, val := range fields {
-		setParts = append(setParts, fmt.Sprintf("%s = ?", col))
-		args = append(args, val)
+func (tx *SQLTransaction) UpdatePartial(ctx context.Context, table string, pk map[string]any, fields map[string]any, opts ...UpdateOption) error {
+	ctx, cancel := updateContext(ctx, opts)
+	defer cancel()
+
+	options := newUpdateOptions(opts)
+	sqlStr, args, err := tx.dialect().buildUpdatePartialSQL(table, pk, fields, options)
+	if err != nil {
+		return err
 	}
 
-	var whereParts []string
-	for col, val := range pk {
-		whereParts = append(whereParts, fmt.Sprintf("%s = ?", col))
-		args = append(args, val)
+	result, err := tx.tx.ExecContext(ctx, sqlStr, args...)
+	if err != nil || !options.versioned() {
+		return err
 	}
+	return checkVersionedUpdate(result, func() (bool, error) {
+		return tx.Exists(ctx, table, pk)
+	})
+}
 
-	sqlStr := fmt.Sprintf("UPDATE %s SET %s WHERE %s",
-		table,
-		strings.Join(setParts, ", "),
-		strings.Join(whereParts, " AND "))
+func (tx *SQLTransaction) Increment(ctx context.Context, table string, pk map[string]any, field string, delta any) error {
+	sqlStr, args, err := tx.dialect().buildIncrementSQL(table, pk, field, delta)
+	if err != nil {
+		return err
+	}
 
-	sqlStr, args = tx.formatSQL(sqlStr, args)
-	_, err := tx.tx.ExecContext(ctx, sqlStr, args...)
+	_, err = tx.tx.ExecContext(ctx, sqlStr, args...)
 	return err
 }
 
 func (tx *SQLTransaction) Delete(ctx context.Context, table string, pk map[string]any) error {
-	var whereParts []string
-	var args []any
-
-	for col, val := range pk {
-		whereParts = append(whereParts, fmt.Sprintf("%s = ?", col))
-		args = append(args, val)
+	columns := sortedKeys(pk)
+	sqlStr, err := tx.dialect().buildDeleteSQL(table, columns)
+	if err != nil {
+		return err
 	}
 
-	sqlStr := fmt.Sprintf("DELETE FROM %s WHERE %s",
-		table, strings.Join(whereParts, " AND "))
-
-	sqlStr, args = tx.formatSQL(sqlStr, args)
-	_, err := tx.tx.ExecContext(ctx, sqlStr, args...)
+	_, err = tx.tx.ExecContext(ctx, sqlStr, sqlColumnValues(pk, columns, nil)...)
 	return err
 }
 
 func (tx *SQLTransaction) Find(ctx context.Context, table string, query query.Query, opts ...QueryOption) ([]Record, error) {
+	cursor, err := tx.FindStream(ctx, table, query, opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close()
+
+	var records []Record
+	for cursor.Next() {
+		records = append(records, cursor.Record())
+	}
+
+	return records, cursor.Err()
+}
+
+func (tx *SQLTransaction) FindStream(ctx context.Context, table string, query query.Query, opts ...QueryOption) (RecordCursor, error) {
+	return streamWithTimeout(ctx, opts, func(ctx context.Context) (RecordCursor, error) {
+		return tx.findStream(ctx, table, query, opts...)
+	})
+}
+
+func (tx *SQLTransaction) findStream(ctx context.Context, table string, query query.Query, opts ...QueryOption) (RecordCursor, error) {
 	// 解析查询选项
 	options := &QueryOptions{}
 	for _, opt := range opts {
 		opt(options)
 	}
 
-	// 构建 WHERE 条件
-	whereSQL, whereArgs, err := query.ToSQL()
+	sqlStr, args, err := tx.dialect().buildFindSQL(table, query, options)
 	if err != nil {
 		return nil, err
 	}
 
-	// 构建完整 SQL
-	sqlStr := fmt.Sprintf("SELECT * FROM %s WHERE %s", table, whereSQL)
-
-	// 添加排序和分页
-	if options.OrderBy != "" {
-		direction := "ASC"
-		if options.OrderDesc {
-			direction = "DESC"
-		}
-		sqlStr += fmt.Sprintf(" ORDER BY %s %s", options.OrderBy, direction)
+	rows, err := tx.tx.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return nil, err
 	}
 
-	if options.Limit > 0 {
-		sqlStr += fmt.Sprintf(" LIMIT %d", options.Limit)
+	return &SQLRecordCursor{rows: rows, scan: tx.scanRowToRecord}, nil
+}
+
+func (tx *SQLTransaction) Count(ctx context.Context, table string, query query.Query) (int64, error) {
+	sqlStr, whereArgs, err := tx.dialect().buildCountSQL(table, query)
+	if err != nil {
+		return 0, err
 	}
-	if options.Offset > 0 {
-		sqlStr += fmt.Sprintf(" OFFSET %d", options.Offset)
+
+	var count int64
+	if err := tx.tx.QueryRowContext(ctx, sqlStr, whereArgs...).Scan(&count); err != nil {
+		return 0, err
 	}
+	return count, nil
+}
 
-	// 执行查询
-	sqlStr, whereArgs = tx.formatSQL(sqlStr, whereArgs)
-	rows, err := tx.tx.QueryContext(ctx, sqlStr, whereArgs...)
+func (tx *SQLTransaction) Exists(ctx context.Context, table string, pk map[string]any) (bool, error) {
+	columns := sortedKeys(pk)
+	sqlStr, err := tx.dialect().buildExistsSQL(table, columns)
 	if err != nil {
-		return nil, err
+		return false, err
 	}
-	defer rows.Close()
 
-	// 扫描结果
-	var records []Record
-	for rows.Next() {
-		record, err := tx.scanRowToRecord(rows)
-		if err != nil {
-			return nil, err
-		}
-		records = append(records, record)
+	var one int
+	err = tx.tx.QueryRowContext(ctx, sqlStr, sqlColumnValues(pk, columns, nil)...).Scan(&one)
+	if err == sql.ErrNoRows {
+		return false, nil
 	}
-
-	return records, nil
+	if err != nil {
+		return false, err
+	}
+	return true, nil
 }
 
 // 事务中的其他方法实现（简化版本）
@@ -1007,6 +1843,9 @@ func (tx *SQLTransaction) Aggregate(ctx context.Context, table string, query que
 }
 
 func (tx *SQLTransaction) BatchCreate(ctx context.Context, table string, records []Record, opts ...CreateOption) error {
+	ctx, cancel := createContext(ctx, opts)
+	defer cancel()
+
 	for _, record := range records {
 		if err := tx.Create(ctx, table, record, opts...); err != nil {
 			return err
@@ -1046,8 +1885,15 @@ func (tx *SQLTransaction) WithTx(ctx context.Context, fn func(tx Transaction) er
 }
 
 func (tx *SQLTransaction) Migrate(ctx context.Context, model *TableModel) error {
+	if err := checkMigrationsAllowed(ctx, tx.allowMigrations, model.Table); err != nil {
+		return err
+	}
+
 	// 构建 CREATE TABLE 语句
-	createTableSQL := tx.buildCreateTableSQL(model)
+	createTableSQL, err := tx.dialect().buildCreateTableSQL(model)
+	if err != nil {
+		return err
+	}
 
 	// 执行创建表语句
 	if _, err := tx.tx.ExecContext(ctx, createTableSQL); err != nil {
@@ -1059,7 +1905,13 @@ func (tx *SQLTransaction) Migrate(ctx context.Context, model *TableModel) error
 
 	// 创建索引
 	for _, index := range model.Indexes {
-		indexSQL := tx.buildCreateIndexSQL(model.Table, index)
+		indexSQL, err := tx.dialect().buildCreateIndexSQL(model.Table, index)
+		if err != nil {
+			return err
+		}
+		if indexSQL == "" {
+			continue
+		}
 		if _, err := tx.tx.ExecContext(ctx, indexSQL); err != nil {
 			// 如果索引已存在，忽略错误
 			if !strings.Contains(err.Error(), "already exists") &&
@@ -1070,12 +1922,26 @@ func (tx *SQLTransaction) Migrate(ctx context.Context, model *TableModel) error
 		}
 	}
 
+	// 创建或替换视图
+	for _, view := range model.Views {
+		statements, err := tx.dialect().buildViewStatements(view)
+		if err != nil {
+			return err
+		}
+		if err := execSQLStatements(ctx, tx.tx, statements); err != nil {
+			return fmt.Errorf("failed to create view %s: %w", view.Name, err)
+		}
+	}
+
 	return nil
 }
 
 func (tx *SQLTransaction) DropTable(ctx context.Context, table string) error {
-	sqlStr := fmt.Sprintf("DROP TABLE IF EXISTS %s", table)
-	_, err := tx.tx.ExecContext(ctx, sqlStr)
+	sqlStr, err := tx.dialect().buildDropTableSQL(table)
+	if err != nil {
+		return err
+	}
+	_, err = tx.tx.ExecContext(ctx, sqlStr)
 	return err
 }
 
@@ -1083,138 +1949,19 @@ func (tx *SQLTransaction) GetBuilder() RecordBuilder {
 	return tx.builder
 }
 
+// Health 在事务中执行 SELECT 1，事务已经结束或连接失效时返回错误
+func (tx *SQLTransaction) Health(ctx context.Context) error {
+	_, err := tx.tx.ExecContext(ctx, "SELECT 1")
+	return err
+}
+
 func (tx *SQLTransaction) Close() error {
 	return nil // 事务不需要单独关闭
 }
 
 // 事务的辅助方法
-func (tx *SQLTransaction) formatSQL(sqlStr string, args []any) (string, []any) {
-	if tx.driver == "postgres" {
-		count := 1
-		for strings.Contains(sqlStr, "?") {
-			sqlStr = strings.Replace(sqlStr, "?", fmt.Sprintf("$%d", count), 1)
-			count++
-		}
-	}
-	return sqlStr, args
-}
-
-// buildCreateTableSQL 构建创建表的 SQL 语句 (事务版本)
-func (tx *SQLTransaction) buildCreateTableSQL(model *TableModel) string {
-	var columns []string
-
-	// 构建字段定义
-	for _, field := range model.Fields {
-		columnDef := tx.buildColumnDefinition(field)
-		columns = append(columns, columnDef)
-	}
-
-	// 添加主键定义
-	if len(model.PrimaryKey) > 0 {
-		pkDef := fmt.Sprintf("PRIMARY KEY (%s)", strings.Join(model.PrimaryKey, ", "))
-		columns = append(columns, pkDef)
-	}
-
-	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n  %s\n)",
-		model.Table, strings.Join(columns, ",\n  "))
-}
-
-// buildColumnDefinition 构建单个字段定义 (事务版本)
-func (tx *SQLTransaction) buildColumnDefinition(field FieldDefinition) string {
-	var parts []string
-
-	// 字段名和类型
-	parts = append(parts, field.Name)
-	parts = append(parts, tx.mapFieldTypeToSQL(field.Type, field.Size))
-
-	// 是否必需
-	if field.Required {
-		parts = append(parts, "NOT NULL")
-	}
-
-	// 默认值
-	if field.Default != nil {
-		defaultValue := tx.formatDefaultValue(field.Default)
-		parts = append(parts, fmt.Sprintf("DEFAULT %s", defaultValue))
-	}
-
-	return strings.Join(parts, " ")
-}
-
-// mapFieldTypeToSQL 将字段类型映射为 SQL 类型 (事务版本)
-func (tx *SQLTransaction) mapFieldTypeToSQL(fieldType FieldType, size int) string {
-	switch fieldType {
-	case FieldTypeString:
-		if tx.driver == "sqlite3" {
-			return "TEXT"
-		}
-		if size > 0 {
-			return fmt.Sprintf("VARCHAR(%d)", size)
-		}
-		return "VARCHAR(255)"
-	case FieldTypeInt:
-		if tx.driver == "sqlite3" {
-			return "INTEGER"
-		}
-		return "INT"
-	case FieldTypeFloat:
-		if tx.driver == "sqlite3" {
-			return "REAL"
-		}
-		return "FLOAT"
-	case FieldTypeBool:
-		if tx.driver == "sqlite3" {
-			return "INTEGER"
-		}
-		return "BOOLEAN"
-	case FieldTypeDate:
-		if tx.driver == "sqlite3" {
-			return "TEXT"
-		}
-		return "DATETIME"
-	case FieldTypeJSON:
-		if tx.driver == "mysql" {
-			return "JSON"
-		}
-		return "TEXT"
-	default:
-		if tx.driver == "sqlite3" {
-			return "TEXT"
-		}
-		return "VARCHAR(255)"
-	}
-}
-
-// formatDefaultValue 格式化默认值 (事务版本)
-func (tx *SQLTransaction) formatDefaultValue(value any) string {
-	switch v := value.(type) {
-	case string:
-		return fmt.Sprintf("'%s'", strings.ReplaceAll(v, "'", "''"))
-	case bool:
-		if v {
-			return "1"
-		}
-		return "0"
-	default:
-		return fmt.Sprintf("%v", v)
-	}
-}
-
-// buildCreateIndexSQL 构建创建索引的 SQL 语句 (事务版本)
-func (tx *SQLTransaction) buildCreateIndexSQL(table string, index IndexDefinition) string {
-	indexType := "INDEX"
-	if index.Unique {
-		indexType = "UNIQUE INDEX"
-	}
-
-	// MySQL 不支持 IF NOT EXISTS 语法用于索引
-	if tx.driver == "mysql" {
-		return fmt.Sprintf("CREATE %s %s ON %s (%s)",
-			indexType, index.Name, table, strings.Join(index.Fields, ", "))
-	}
-
-	return fmt.Sprintf("CREATE %s IF NOT EXISTS %s ON %s (%s)",
-		indexType, index.Name, table, strings.Join(index.Fields, ", "))
+func (tx *SQLTransaction) dialect() sqlDialect {
+	return newSQLDialect(tx.driver, tx.compat)
 }
 
 func (tx *SQLTransaction) scanRowToRecord(rows *sql.Rows) (Record, error) {
@@ -1238,5 +1985,5 @@ func (tx *SQLTransaction) scanRowToRecord(rows *sql.Rows) (Record, error) {
 		data[col] = values[i]
 	}
 
-	return &SQLRecord{data: data}, nil
+	return newSQLRecord(data, tx.builder.tagName), nil
 }
@@ -0,0 +1,999 @@
+package database
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hatlonely/gox/rdb/aggregation"
+	"github.com/hatlonely/gox/rdb/query"
+	"github.com/pkg/errors"
+)
+
+// ErrInvalidIdentifier 表名、列名、索引名等标识符不合法
+var ErrInvalidIdentifier = errors.New("invalid sql identifier")
+
+// sqlIdentifierPattern 标识符由字母、数字、下划线和 $ 组成，可以用 . 分隔库名、表名和列名
+var sqlIdentifierPattern = regexp.MustCompile(`^[A-Za-z0-9_$]+(\.[A-Za-z0-9_$]+)*$`)
+
+// validateSQLIdentifier 校验标识符，kind 用于错误信息，如 "table"、"column"
+func validateSQLIdentifier(kind string, name string) error {
+	if len(name) > 128 || !sqlIdentifierPattern.MatchString(name) {
+		return fmt.Errorf("%w: %s %q", ErrInvalidIdentifier, kind, name)
+	}
+	return nil
+}
+
+// validateSQLIdentifiers 校验表名和各组列名
+func validateSQLIdentifiers(table string, columnGroups ...[]string) error {
+	if err := validateSQLIdentifier("table", table); err != nil {
+		return err
+	}
+	for _, columns := range columnGroups {
+		for _, column := range columns {
+			if err := validateSQLIdentifier("column", column); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validateQueryFields 校验查询条件中的字段名，字段名由 query 包直接拼接到条件中，需要在生成 SQL 前校验
+// RawQuery 是调用方编写的 SQL 片段，不做校验
+func validateQueryFields(q query.Query) error {
+	var field string
+	switch v := q.(type) {
+	case nil:
+		return nil
+	case *query.BoolQuery:
+		for _, group := range [][]query.Query{v.Must, v.Should, v.MustNot, v.Filter} {
+			for _, sub := range group {
+				if err := validateQueryFields(sub); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	case *query.TermQuery:
+		field = v.Field
+	case *query.MatchQuery:
+		field = v.Field
+	case *query.RangeQuery:
+		field = v.Field
+	case *query.ExistsQuery:
+		field = v.Field
+	case *query.WildcardQuery:
+		field = v.Field
+	case *query.PrefixQuery:
+		field = v.Field
+	case *query.RegexpQuery:
+		field = v.Field
+	case *query.GeoDistanceQuery:
+		field = v.Field
+	case *query.GeoBoundingBoxQuery:
+		field = v.Field
+	case *query.FullTextQuery:
+		for _, field := range v.Fields {
+			if err := validateSQLIdentifier("field", field); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return nil
+	}
+	return validateSQLIdentifier("field", field)
+}
+
+// checkQuerySupport 检查查询条件在当前方言下可用，地理位置查询生成 PostGIS 表达式，只支持 postgres；
+// 全文检索使用 MySQL 的 FULLTEXT 索引或 SQLite 的 FTS5，不支持 postgres
+func (d sqlDialect) checkQuerySupport(q query.Query) error {
+	switch v := q.(type) {
+	case *query.BoolQuery:
+		for _, group := range [][]query.Query{v.Must, v.Should, v.MustNot, v.Filter} {
+			for _, sub := range group {
+				if err := d.checkQuerySupport(sub); err != nil {
+					return err
+				}
+			}
+		}
+	case *query.GeoDistanceQuery, *query.GeoBoundingBoxQuery:
+		if d != "postgres" {
+			return fmt.Errorf("%s query requires postgres with PostGIS, got %s", q.Type(), d)
+		}
+	case *query.FullTextQuery:
+		if d != "mysql" && !d.sqlite() {
+			return fmt.Errorf("%s query requires mysql or sqlite, got %s", q.Type(), d)
+		}
+	}
+	return nil
+}
+
+// scoreColumn 相关度评分的列别名，扫描结果时从记录中取出
+const scoreColumn = "_score"
+
+// sqliteFTSTable SQLite 全文索引对应的 FTS5 虚拟表
+func sqliteFTSTable(table string) string {
+	return table + "_fts"
+}
+
+// rewriteFullText SQLite 没有 MATCH ... AGAINST，将全文检索改写为对 FTS5 虚拟表的子查询，其他方言原样返回
+func (d sqlDialect) rewriteFullText(table string, q query.Query) (query.Query, error) {
+	if !d.sqlite() {
+		return q, nil
+	}
+	switch v := q.(type) {
+	case *query.FullTextQuery:
+		match, err := v.FTS5Match()
+		if err != nil {
+			return nil, err
+		}
+		fts := d.quote(sqliteFTSTable(table))
+		return query.Raw(fmt.Sprintf("rowid IN (SELECT rowid FROM %s WHERE %s MATCH ?)", fts, fts), match), nil
+	case *query.BoolQuery:
+		rewritten := *v
+		for _, group := range []*[]query.Query{&rewritten.Must, &rewritten.Should, &rewritten.MustNot, &rewritten.Filter} {
+			if len(*group) == 0 {
+				continue
+			}
+			subs := make([]query.Query, len(*group))
+			for i, sub := range *group {
+				r, err := d.rewriteFullText(table, sub)
+				if err != nil {
+					return nil, err
+				}
+				subs[i] = r
+			}
+			*group = subs
+		}
+		return &rewritten, nil
+	}
+	return q, nil
+}
+
+// buildScoreSQL 构建相关度评分表达式，多个全文检索的评分相加
+// MySQL 使用 MATCH ... AGAINST 的返回值；SQLite 使用 bm25 取反，使分数越高越相关，未匹配的记录评分为 0
+func (d sqlDialect) buildScoreSQL(table string, q query.Query) (string, []any, error) {
+	queries := fullTextQueries(q)
+	if len(queries) == 0 {
+		return "", nil, ErrScoreUnsupported
+	}
+	var parts []string
+	var args []any
+	for _, fq := range queries {
+		if d.sqlite() {
+			match, err := fq.FTS5Match()
+			if err != nil {
+				return "", nil, err
+			}
+			fts := d.quote(sqliteFTSTable(table))
+			parts = append(parts, fmt.Sprintf("COALESCE((SELECT -bm25(%s) FROM %s WHERE %s MATCH ? AND rowid = %s.rowid), 0)",
+				fts, fts, fts, d.quote(table)))
+			args = append(args, match)
+			continue
+		}
+		part, partArgs, err := fq.ToSQL()
+		if err != nil {
+			return "", nil, err
+		}
+		parts = append(parts, part)
+		args = append(args, partArgs...)
+	}
+	return strings.Join(parts, " + "), args, nil
+}
+
+// checkFullTextIndexes SQLite 每个表只有一个 FTS5 虚拟表，只能定义一个全文索引；postgres 不支持全文索引
+func (d sqlDialect) checkFullTextIndexes(model *TableModel) error {
+	var names []string
+	for _, index := range model.Indexes {
+		if index.fullText() {
+			names = append(names, index.Name)
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	if d != "mysql" && !d.sqlite() {
+		return fmt.Errorf("fulltext index requires mysql or sqlite, got %s", d)
+	}
+	if d.sqlite() && len(names) > 1 {
+		return fmt.Errorf("sqlite supports one fulltext index per table, got %v", names)
+	}
+	return nil
+}
+
+// validateAggregations 校验聚合名、聚合字段和排序，聚合名作为列别名、字段作为列名拼接到 SQL 中
+// 派生指标由其他聚合结果计算，不生成 SQL，不做校验
+func validateAggregations(aggs []aggregation.Aggregation) error {
+	for _, agg := range aggs {
+		if _, ok := agg.(aggregation.ScriptAggregator); ok {
+			continue
+		}
+		if err := validateSQLIdentifier("aggregation name", agg.Name()); err != nil {
+			return err
+		}
+
+		var fields []string
+		var subAggs []aggregation.Aggregation
+		switch v := agg.(type) {
+		case *aggregation.SumAggregation:
+			fields = append(fields, v.Field)
+		case *aggregation.AvgAggregation:
+			fields = append(fields, v.Field)
+		case *aggregation.MaxAggregation:
+			fields = append(fields, v.Field)
+		case *aggregation.MinAggregation:
+			fields = append(fields, v.Field)
+		case *aggregation.CountAggregation:
+			if v.Field != "" {
+				fields = append(fields, v.Field)
+			}
+		case *aggregation.TermsAggregation:
+			fields = append(fields, v.Field)
+			subAggs = v.SubAggregations
+			for field, direction := range v.Order {
+				if field != "_count" && field != "_key" {
+					if err := validateSQLIdentifier("order field", field); err != nil {
+						return err
+					}
+				}
+				if _, err := sqlSortDirection(direction); err != nil {
+					return err
+				}
+			}
+		case *aggregation.DateHistogramAggregation:
+			fields = append(fields, v.Field)
+			subAggs = v.SubAggregations
+		case *aggregation.CompositeAggregation:
+			subAggs = v.SubAggregations
+			for _, source := range v.Sources {
+				fields = append(fields, source.Field)
+				if err := validateSQLIdentifier("aggregation name", source.Name); err != nil {
+					return err
+				}
+				if source.Order != "" {
+					if _, err := sqlSortDirection(source.Order); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		for _, field := range fields {
+			if err := validateSQLIdentifier("aggregation field", field); err != nil {
+				return err
+			}
+		}
+		if err := validateAggregations(subAggs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sqlSortDirection 将排序方向规范为 ASC 或 DESC，其他取值返回错误
+func sqlSortDirection(direction string) (string, error) {
+	switch upper := strings.ToUpper(direction); upper {
+	case "ASC", "DESC":
+		return upper, nil
+	default:
+		return "", fmt.Errorf("invalid sort direction %q", direction)
+	}
+}
+
+// sqlDialect SQL 方言，SQL 和 SQLTransaction 的语句都由它构建
+//
+// 表名、列名、索引名先校验再按方言引用：MySQL 和 SQLite 使用反引号，PostgreSQL 使用双引号。
+// 查询条件中的字段名只校验不引用，视图的 SELECT 和 RawQuery 原样使用
+type sqlDialect string
+
+// sqlite 是否为 SQLite，包括模拟 MySQL 的兼容模式
+func (d sqlDialect) sqlite() bool {
+	return d == "sqlite3" || d == sqliteMySQLDialect
+}
+
+// quote 引用标识符，库名、表名用 . 分隔时分别引用
+func (d sqlDialect) quote(name string) string {
+	q := "`"
+	if d == "postgres" {
+		q = `"`
+	}
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		parts[i] = q + strings.ReplaceAll(part, q, q+q) + q
+	}
+	return strings.Join(parts, ".")
+}
+
+// quoteList 引用多个标识符并以 ", " 连接
+func (d sqlDialect) quoteList(names []string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = d.quote(name)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// columnsEqual 构建 "`a` = ?, `b` = ?" 形式的条件，sep 为连接符
+func (d sqlDialect) columnsEqual(columns []string, sep string) string {
+	parts := make([]string, len(columns))
+	for i, column := range columns {
+		parts[i] = d.quote(column) + " = ?"
+	}
+	return strings.Join(parts, sep)
+}
+
+// format 将 ? 占位符转换为驱动的格式，PostgreSQL 使用 $1, $2, $3...
+func (d sqlDialect) format(sqlStr string) string {
+	if d == "postgres" {
+		count := 1
+		for strings.Contains(sqlStr, "?") {
+			sqlStr = strings.Replace(sqlStr, "?", fmt.Sprintf("$%d", count), 1)
+			count++
+		}
+	}
+	return sqlStr
+}
+
+// buildCreateTableSQL 构建创建表的 SQL 语句
+func (d sqlDialect) buildCreateTableSQL(model *TableModel) (string, error) {
+	if err := validateSQLIdentifier("table", model.Table); err != nil {
+		return "", err
+	}
+	if err := d.checkFullTextIndexes(model); err != nil {
+		return "", err
+	}
+
+	var columns []string
+	for _, field := range model.Fields {
+		if err := validateSQLIdentifier("column", field.Name); err != nil {
+			return "", err
+		}
+		columns = append(columns, d.buildColumnDefinition(field))
+	}
+
+	if len(model.PrimaryKey) > 0 {
+		if err := validateSQLIdentifiers(model.Table, model.PrimaryKey); err != nil {
+			return "", err
+		}
+		columns = append(columns, fmt.Sprintf("PRIMARY KEY (%s)", d.quoteList(model.PrimaryKey)))
+	}
+
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n  %s\n)",
+		d.quote(model.Table), strings.Join(columns, ",\n  ")), nil
+}
+
+// buildAddColumnSQL 构建增加列的 SQL 语句
+func (d sqlDialect) buildAddColumnSQL(table string, field FieldDefinition) (string, error) {
+	if err := validateSQLIdentifiers(table, []string{field.Name}); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", d.quote(table), d.buildColumnDefinition(field)), nil
+}
+
+// buildColumnDefinition 构建单个字段定义，字段名由调用方校验
+func (d sqlDialect) buildColumnDefinition(field FieldDefinition) string {
+	parts := []string{d.quote(field.Name), d.mapFieldTypeToSQL(field.Type, field.Size)}
+
+	if field.Required {
+		parts = append(parts, "NOT NULL")
+	}
+	if field.Default != nil {
+		parts = append(parts, fmt.Sprintf("DEFAULT %s", d.formatDefaultValue(field.Default)))
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// mapFieldTypeToSQL 将字段类型映射为 SQL 类型
+func (d sqlDialect) mapFieldTypeToSQL(fieldType FieldType, size int) string {
+	switch fieldType {
+	case FieldTypeString:
+		if d == sqliteMySQLDialect {
+			// MySQL 默认的排序规则不区分大小写
+			return "TEXT COLLATE NOCASE"
+		}
+		if d.sqlite() {
+			return "TEXT"
+		}
+		if size > 0 {
+			return fmt.Sprintf("VARCHAR(%d)", size)
+		}
+		return "VARCHAR(255)"
+	case FieldTypeInt:
+		if d.sqlite() {
+			return "INTEGER"
+		}
+		return "INT"
+	case FieldTypeFloat:
+		if d.sqlite() {
+			return "REAL"
+		}
+		return "FLOAT"
+	case FieldTypeBool:
+		if d.sqlite() {
+			return "INTEGER"
+		}
+		return "BOOLEAN"
+	case FieldTypeDate:
+		if d.sqlite() {
+			return "TEXT"
+		}
+		return "DATETIME"
+	case FieldTypeJSON:
+		if d == "mysql" {
+			return "JSON"
+		}
+		return "TEXT"
+	case FieldTypeGeoPoint:
+		// 只有 PostGIS 支持地理位置查询，其他数据库与 JSON 字段一样存储
+		if d == "postgres" {
+			return "GEOGRAPHY(POINT, 4326)"
+		}
+		if d == "mysql" {
+			return "JSON"
+		}
+		return "TEXT"
+	default:
+		if d.sqlite() {
+			return "TEXT"
+		}
+		return "VARCHAR(255)"
+	}
+}
+
+// formatDefaultValue 格式化默认值
+func (d sqlDialect) formatDefaultValue(value any) string {
+	switch v := value.(type) {
+	case string:
+		return fmt.Sprintf("'%s'", strings.ReplaceAll(v, "'", "''"))
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+// buildCreateIndexSQL 构建创建索引的 SQL 语句，数据库不支持的索引类型返回空语句，调用方跳过
+// ttl 索引创建为普通索引；geo 索引只在 postgres 上创建为 GIST 索引；Expression 在 SQLite 和 postgres 上作为 WHERE 子句，
+// MySQL 不支持部分索引，非唯一索引忽略 Expression 索引全部记录，唯一索引会改变约束范围，返回错误
+func (d sqlDialect) buildCreateIndexSQL(table string, index IndexDefinition) (string, error) {
+	if err := validateSQLIdentifiers(table, index.Fields); err != nil {
+		return "", err
+	}
+	if err := validateSQLIdentifier("index", index.Name); err != nil {
+		return "", err
+	}
+	if err := index.validate(); err != nil {
+		return "", err
+	}
+
+	if index.Kind == IndexKindGeo && d != "postgres" {
+		return "", nil
+	}
+	if index.fullText() && d.sqlite() {
+		return d.buildSQLiteFTSSQL(table, index), nil
+	}
+
+	indexType := "INDEX"
+	if index.Unique {
+		indexType = "UNIQUE INDEX"
+	}
+	if index.fullText() {
+		indexType = "FULLTEXT INDEX"
+	}
+
+	using := ""
+	if index.Kind == IndexKindGeo {
+		using = " USING GIST"
+	}
+
+	where := ""
+	if index.Expression != nil && d == "mysql" && index.Unique {
+		return "", fmt.Errorf("partial unique index %s is not supported by mysql", index.Name)
+	}
+	if index.Expression != nil && d != "mysql" {
+		if err := validateQueryFields(index.Expression); err != nil {
+			return "", err
+		}
+		if err := d.checkQuerySupport(index.Expression); err != nil {
+			return "", err
+		}
+		condition, args, err := index.Expression.ToSQL()
+		if err != nil {
+			return "", fmt.Errorf("failed to build index expression %s: %v", index.Name, err)
+		}
+		where = " WHERE " + d.inlineArgs(condition, args)
+	}
+
+	// MySQL 不支持 IF NOT EXISTS 语法用于索引
+	if d == "mysql" {
+		return fmt.Sprintf("CREATE %s %s ON %s (%s)",
+			indexType, d.quote(index.Name), d.quote(table), d.quoteList(index.Fields)), nil
+	}
+
+	return fmt.Sprintf("CREATE %s IF NOT EXISTS %s ON %s%s (%s)%s",
+		indexType, d.quote(index.Name), d.quote(table), using, d.quoteList(index.Fields), where), nil
+}
+
+// inlineArgs 将参数以字面量替换条件中的 ? 占位符，DDL 语句不能绑定参数
+func (d sqlDialect) inlineArgs(condition string, args []any) string {
+	var b strings.Builder
+	for _, c := range condition {
+		if c == '?' && len(args) > 0 {
+			b.WriteString(d.formatLiteral(args[0]))
+			args = args[1:]
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// formatLiteral 格式化 SQL 字面量，时间按 RFC3339 格式化为字符串
+func (d sqlDialect) formatLiteral(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "NULL"
+	case time.Time:
+		return d.formatDefaultValue(v.Format(time.RFC3339))
+	case bool:
+		if d == "postgres" {
+			return strconv.FormatBool(v)
+		}
+		return d.formatDefaultValue(v)
+	default:
+		return d.formatDefaultValue(v)
+	}
+}
+
+// buildSQLiteFTSSQL 构建 SQLite 全文索引：外部内容的 FTS5 虚拟表 <table>_fts 及同步数据的触发器
+// 多条语句以分号分隔，sqlite3 驱动在一次 Exec 中依次执行；插入触发器使用索引名，MigrateDiff 据此判断索引是否存在。
+// 只同步创建之后写入的数据，表中已有数据时需要执行一次 INSERT INTO <table>_fts(<table>_fts) VALUES('rebuild')
+func (d sqlDialect) buildSQLiteFTSSQL(table string, index IndexDefinition) string {
+	fts := d.quote(sqliteFTSTable(table))
+	columns := d.quoteList(index.Fields)
+	values := func(prefix string) string {
+		parts := make([]string, len(index.Fields))
+		for i, field := range index.Fields {
+			parts[i] = prefix + "." + d.quote(field)
+		}
+		return strings.Join(parts, ", ")
+	}
+	tableName := strings.ReplaceAll(table, "'", "''")
+	insert := fmt.Sprintf("INSERT INTO %s(rowid, %s) VALUES (new.rowid, %s);", fts, columns, values("new"))
+	remove := fmt.Sprintf("INSERT INTO %s(%s, rowid, %s) VALUES ('delete', old.rowid, %s);", fts, fts, columns, values("old"))
+
+	return strings.Join([]string{
+		fmt.Sprintf("CREATE VIRTUAL TABLE IF NOT EXISTS %s USING fts5(%s, content='%s', content_rowid='rowid')", fts, columns, tableName),
+		fmt.Sprintf("CREATE TRIGGER IF NOT EXISTS %s AFTER INSERT ON %s BEGIN %s END",
+			d.quote(index.Name), d.quote(table), insert),
+		fmt.Sprintf("CREATE TRIGGER IF NOT EXISTS %s AFTER DELETE ON %s BEGIN %s END",
+			d.quote(index.Name+"_ad"), d.quote(table), remove),
+		fmt.Sprintf("CREATE TRIGGER IF NOT EXISTS %s AFTER UPDATE ON %s BEGIN %s %s END",
+			d.quote(index.Name+"_au"), d.quote(table), remove, insert),
+	}, ";\n") + ";"
+}
+
+// buildViewStatements 构建创建或替换视图的语句
+// MySQL 使用 CREATE OR REPLACE VIEW，SQLite 不支持替换，先删除再创建
+func (d sqlDialect) buildViewStatements(view ViewDefinition) ([]string, error) {
+	if err := validateSQLIdentifier("view", view.Name); err != nil {
+		return nil, err
+	}
+	if view.Select == "" {
+		return nil, fmt.Errorf("view %s has no select definition", view.Name)
+	}
+
+	if d.sqlite() {
+		return []string{
+			fmt.Sprintf("DROP VIEW IF EXISTS %s", d.quote(view.Name)),
+			fmt.Sprintf("CREATE VIEW %s AS %s", d.quote(view.Name), view.Select),
+		}, nil
+	}
+	return []string{fmt.Sprintf("CREATE OR REPLACE VIEW %s AS %s", d.quote(view.Name), view.Select)}, nil
+}
+
+// buildDropTableSQL 构建删除表的 SQL 语句
+func (d sqlDialect) buildDropTableSQL(table string) (string, error) {
+	if err := validateSQLIdentifier("table", table); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("DROP TABLE IF EXISTS %s", d.quote(table)), nil
+}
+
+// buildInsertSQL 构建 INSERT 语句，冲突处理方式由 options 决定
+func (d sqlDialect) buildInsertSQL(table string, columns []string, options *CreateOptions) (string, error) {
+	if err := validateSQLIdentifiers(table, columns); err != nil {
+		return "", err
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ")
+	target := fmt.Sprintf("%s (%s) VALUES (%s)", d.quote(table), d.quoteList(columns), placeholders)
+
+	var sqlStr string
+	switch {
+	case options.IgnoreConflict && d == "mysql":
+		sqlStr = "INSERT IGNORE INTO " + target
+	case options.IgnoreConflict:
+		// SQLite 使用 INSERT OR IGNORE
+		sqlStr = "INSERT OR IGNORE INTO " + target
+	case options.UpdateOnConflict && d == "mysql":
+		updateParts := make([]string, len(columns))
+		for i, column := range columns {
+			updateParts[i] = fmt.Sprintf("%s = VALUES(%s)", d.quote(column), d.quote(column))
+		}
+		sqlStr = fmt.Sprintf("INSERT INTO %s ON DUPLICATE KEY UPDATE %s", target, strings.Join(updateParts, ", "))
+	case options.UpdateOnConflict && d == sqliteMySQLDialect:
+		// 与 ON DUPLICATE KEY UPDATE 一致，只更新写入的列，INSERT OR REPLACE 会删除旧行，未写入的列被重置为默认值
+		updateParts := make([]string, len(columns))
+		for i, column := range columns {
+			updateParts[i] = fmt.Sprintf("%s = excluded.%s", d.quote(column), d.quote(column))
+		}
+		sqlStr = fmt.Sprintf("INSERT INTO %s ON CONFLICT DO UPDATE SET %s", target, strings.Join(updateParts, ", "))
+	case options.UpdateOnConflict:
+		// SQLite 使用 INSERT OR REPLACE
+		sqlStr = "INSERT OR REPLACE INTO " + target
+	default:
+		sqlStr = "INSERT INTO " + target
+	}
+
+	return d.format(sqlStr), nil
+}
+
+// buildUpsertSQL 构建冲突时只更新 updateColumns 的 INSERT 语句，用于 DirtyRecord，updateColumns 为空时忽略冲突
+func (d sqlDialect) buildUpsertSQL(table string, columns []string, updateColumns []string) (string, error) {
+	if len(updateColumns) == 0 {
+		return d.buildInsertSQL(table, columns, &CreateOptions{IgnoreConflict: true})
+	}
+	if err := validateSQLIdentifiers(table, columns, updateColumns); err != nil {
+		return "", err
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ")
+	target := fmt.Sprintf("%s (%s) VALUES (%s)", d.quote(table), d.quoteList(columns), placeholders)
+	updateParts := make([]string, len(updateColumns))
+	if d == "mysql" {
+		for i, column := range updateColumns {
+			updateParts[i] = fmt.Sprintf("%s = VALUES(%s)", d.quote(column), d.quote(column))
+		}
+		return d.format(fmt.Sprintf("INSERT INTO %s ON DUPLICATE KEY UPDATE %s", target, strings.Join(updateParts, ", "))), nil
+	}
+	for i, column := range updateColumns {
+		updateParts[i] = fmt.Sprintf("%s = excluded.%s", d.quote(column), d.quote(column))
+	}
+	return d.format(fmt.Sprintf("INSERT INTO %s ON CONFLICT DO UPDATE SET %s", target, strings.Join(updateParts, ", "))), nil
+}
+
+// buildUpsertByPKSQL 构建按主键插入或更新的语句，冲突时更新除主键之外的列，没有其他列时忽略冲突
+// SQLite、PostgreSQL 使用 ON CONFLICT (pk) 只按主键判断冲突；MySQL 使用 ON DUPLICATE KEY UPDATE，
+// 无法指定冲突列，表上的其他唯一索引冲突时同样会更新
+func (d sqlDialect) buildUpsertByPKSQL(table string, columns []string, pkColumns []string) (string, error) {
+	if err := validateSQLIdentifiers(table, columns, pkColumns); err != nil {
+		return "", err
+	}
+
+	isPK := make(map[string]bool, len(pkColumns))
+	for _, column := range pkColumns {
+		isPK[column] = true
+	}
+	var updateColumns []string
+	for _, column := range columns {
+		if !isPK[column] {
+			updateColumns = append(updateColumns, column)
+		}
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ")
+	target := fmt.Sprintf("%s (%s) VALUES (%s)", d.quote(table), d.quoteList(columns), placeholders)
+	updateParts := make([]string, len(updateColumns))
+	if d == "mysql" {
+		if len(updateColumns) == 0 {
+			return d.format("INSERT IGNORE INTO " + target), nil
+		}
+		for i, column := range updateColumns {
+			updateParts[i] = fmt.Sprintf("%s = VALUES(%s)", d.quote(column), d.quote(column))
+		}
+		return d.format(fmt.Sprintf("INSERT INTO %s ON DUPLICATE KEY UPDATE %s", target, strings.Join(updateParts, ", "))), nil
+	}
+
+	conflict := fmt.Sprintf("INSERT INTO %s ON CONFLICT (%s)", target, d.quoteList(pkColumns))
+	if len(updateColumns) == 0 {
+		return d.format(conflict + " DO NOTHING"), nil
+	}
+	for i, column := range updateColumns {
+		updateParts[i] = fmt.Sprintf("%s = excluded.%s", d.quote(column), d.quote(column))
+	}
+	return d.format(fmt.Sprintf("%s DO UPDATE SET %s", conflict, strings.Join(updateParts, ", "))), nil
+}
+
+// buildGetSQL 构建按主键查询的 SELECT 语句，pkColumns 需要与参数顺序一致，fields 为空时查询所有列
+func (d sqlDialect) buildGetSQL(table string, pkColumns []string, fields []string) (string, error) {
+	if err := validateSQLIdentifiers(table, pkColumns, fields); err != nil {
+		return "", err
+	}
+	return d.format(fmt.Sprintf("SELECT %s FROM %s WHERE %s",
+		d.selectColumns(fields), d.quote(table), d.columnsEqual(pkColumns, " AND "))), nil
+}
+
+// selectColumns 返回 SELECT 的列，fields 为空时返回 *
+func (d sqlDialect) selectColumns(fields []string) string {
+	if len(fields) == 0 {
+		return "*"
+	}
+	return d.quoteList(fields)
+}
+
+// buildExistsSQL 构建按主键判断记录是否存在的语句
+func (d sqlDialect) buildExistsSQL(table string, pkColumns []string) (string, error) {
+	if err := validateSQLIdentifiers(table, pkColumns); err != nil {
+		return "", err
+	}
+	return d.format(fmt.Sprintf("SELECT 1 FROM %s WHERE %s LIMIT 1", d.quote(table), d.columnsEqual(pkColumns, " AND "))), nil
+}
+
+// buildUpdateSQL 构建更新整条记录的 UPDATE 语句
+func (d sqlDialect) buildUpdateSQL(table string, columns []string, pkColumns []string) (string, error) {
+	if err := validateSQLIdentifiers(table, columns, pkColumns); err != nil {
+		return "", err
+	}
+	return d.format(fmt.Sprintf("UPDATE %s SET %s WHERE %s",
+		d.quote(table), d.columnsEqual(columns, ", "), d.columnsEqual(pkColumns, " AND "))), nil
+}
+
+// buildDeleteSQL 构建按主键删除的 DELETE 语句
+func (d sqlDialect) buildDeleteSQL(table string, pkColumns []string) (string, error) {
+	if err := validateSQLIdentifiers(table, pkColumns); err != nil {
+		return "", err
+	}
+	return d.format(fmt.Sprintf("DELETE FROM %s WHERE %s", d.quote(table), d.columnsEqual(pkColumns, " AND "))), nil
+}
+
+// buildUpdatePartialSQL 构建只更新部分列的 UPDATE 语句，列按名称排序保证语句稳定
+// 启用乐观锁时追加 version = version + 1 和 WHERE version = ?
+func (d sqlDialect) buildUpdatePartialSQL(table string, pk map[string]any, fields map[string]any, options *UpdateOptions) (string, []any, error) {
+	fields = options.stripVersion(fields)
+	if len(fields) == 0 && !options.versioned() {
+		return "", nil, fmt.Errorf("no fields to update")
+	}
+
+	columns := sortedKeys(fields)
+	pkColumns := sortedKeys(pk)
+	if err := validateSQLIdentifiers(table, columns, pkColumns); err != nil {
+		return "", nil, err
+	}
+
+	setParts := make([]string, 0, len(columns)+1)
+	if len(columns) > 0 {
+		setParts = append(setParts, d.columnsEqual(columns, ", "))
+	}
+	whereSQL := d.columnsEqual(pkColumns, " AND ")
+	args := sqlColumnValues(fields, columns, make([]any, 0, len(columns)+len(pkColumns)+1))
+	args = sqlColumnValues(pk, pkColumns, args)
+	if options.versioned() {
+		if err := validateSQLIdentifier("column", options.VersionField); err != nil {
+			return "", nil, err
+		}
+		version := d.quote(options.VersionField)
+		setParts = append(setParts, fmt.Sprintf("%s = %s + 1", version, version))
+		whereSQL += fmt.Sprintf(" AND %s = ?", version)
+		args = append(args, options.Version)
+	}
+
+	sqlStr := fmt.Sprintf("UPDATE %s SET %s WHERE %s", d.quote(table), strings.Join(setParts, ", "), whereSQL)
+	return d.format(sqlStr), args, nil
+}
+
+// buildIncrementSQL 构建原子增减的 UPDATE 语句
+func (d sqlDialect) buildIncrementSQL(table string, pk map[string]any, field string, delta any) (string, []any, error) {
+	if err := validateIncrementDelta(delta); err != nil {
+		return "", nil, err
+	}
+
+	pkColumns := sortedKeys(pk)
+	if err := validateSQLIdentifiers(table, []string{field}, pkColumns); err != nil {
+		return "", nil, err
+	}
+
+	sqlStr := fmt.Sprintf("UPDATE %s SET %s = %s + ? WHERE %s",
+		d.quote(table), d.quote(field), d.quote(field), d.columnsEqual(pkColumns, " AND "))
+	return d.format(sqlStr), sqlColumnValues(pk, pkColumns, []any{delta}), nil
+}
+
+// buildFindSQL 构建 Find 使用的 SELECT 语句，游标分页时追加键集条件
+func (d sqlDialect) buildFindSQL(table string, q query.Query, options *QueryOptions) (string, []any, error) {
+	if err := validateSQLIdentifiers(table, options.Fields); err != nil {
+		return "", nil, err
+	}
+	if err := validateQueryFields(q); err != nil {
+		return "", nil, err
+	}
+	if err := d.checkQuerySupport(q); err != nil {
+		return "", nil, err
+	}
+	scoring, err := options.scoring()
+	if err != nil {
+		return "", nil, err
+	}
+	orderBy, err := d.buildOrderBy(options)
+	if err != nil {
+		return "", nil, err
+	}
+
+	selectSQL := d.selectColumns(options.Fields)
+	var args []any
+	if scoring {
+		scoreSQL, scoreArgs, err := d.buildScoreSQL(table, q)
+		if err != nil {
+			return "", nil, err
+		}
+		selectSQL = fmt.Sprintf("%s, %s AS %s", selectSQL, scoreSQL, d.quote(scoreColumn))
+		args = append(args, scoreArgs...)
+	}
+
+	where, err := d.rewriteFullText(table, options.keysetQuery(q))
+	if err != nil {
+		return "", nil, err
+	}
+	whereSQL, whereArgs, err := where.ToSQL()
+	if err != nil {
+		return "", nil, err
+	}
+	args = append(args, whereArgs...)
+
+	sqlStr := fmt.Sprintf("SELECT %s FROM %s WHERE %s", selectSQL, d.quote(table), whereSQL)
+	sqlStr += orderBy
+	if options.Limit > 0 {
+		sqlStr += fmt.Sprintf(" LIMIT %d", options.Limit)
+	}
+	if options.Offset > 0 {
+		sqlStr += fmt.Sprintf(" OFFSET %d", options.Offset)
+	}
+
+	return d.format(sqlStr), args, nil
+}
+
+// buildCountSQL 构建 SELECT COUNT(*) 语句
+func (d sqlDialect) buildCountSQL(table string, q query.Query) (string, []any, error) {
+	if err := validateSQLIdentifier("table", table); err != nil {
+		return "", nil, err
+	}
+	if err := validateQueryFields(q); err != nil {
+		return "", nil, err
+	}
+	if err := d.checkQuerySupport(q); err != nil {
+		return "", nil, err
+	}
+
+	where, err := d.rewriteFullText(table, q)
+	if err != nil {
+		return "", nil, err
+	}
+	whereSQL, whereArgs, err := where.ToSQL()
+	if err != nil {
+		return "", nil, err
+	}
+	return d.format(fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s", d.quote(table), whereSQL)), whereArgs, nil
+}
+
+// buildOrderBy 构建 ORDER BY 子句，没有排序字段时返回空字符串
+// 按评分排序时评分降序在前，排序字段作为评分相同时的次排序
+func (d sqlDialect) buildOrderBy(options *QueryOptions) (string, error) {
+	fields := options.sortFields()
+	if len(fields) == 0 && !options.OrderByScore {
+		return "", nil
+	}
+	direction := "ASC"
+	if options.OrderDesc {
+		direction = "DESC"
+	}
+	parts := make([]string, 0, len(fields)+1)
+	if options.OrderByScore {
+		parts = append(parts, d.quote(scoreColumn)+" DESC")
+	}
+	for _, field := range fields {
+		if err := validateSQLIdentifier("order by field", field); err != nil {
+			return "", err
+		}
+		parts = append(parts, d.quote(field)+" "+direction)
+	}
+	return " ORDER BY " + strings.Join(parts, ", "), nil
+}
+
+// buildMetricSQL 构建指标聚合的 SELECT 表达式，字段和别名按方言引用
+// 其他指标聚合使用自身的 ToSQL，字段和别名由 validateAggregations 校验
+func (d sqlDialect) buildMetricSQL(agg aggregation.Aggregation) (string, []any, error) {
+	var fn, field string
+	switch v := agg.(type) {
+	case *aggregation.SumAggregation:
+		fn, field = "SUM", v.Field
+	case *aggregation.AvgAggregation:
+		fn, field = "AVG", v.Field
+	case *aggregation.MaxAggregation:
+		fn, field = "MAX", v.Field
+	case *aggregation.MinAggregation:
+		fn, field = "MIN", v.Field
+	case *aggregation.CountAggregation:
+		if v.Field == "" {
+			return "COUNT(*) AS " + d.quote(v.AggName), nil, nil
+		}
+		fn, field = "COUNT", v.Field
+	default:
+		return agg.ToSQL()
+	}
+	return fmt.Sprintf("%s(%s) AS %s", fn, d.quote(field), d.quote(agg.Name())), nil, nil
+}
+
+// buildGroupBySQL 构建分组统计语句，分组统计作为子查询，Having 和排序作用于外层查询，
+// 这样 Having 可以直接引用指标的别名（PostgreSQL 的 HAVING 不支持引用 SELECT 中的别名）
+func (d sqlDialect) buildGroupBySQL(table string, whereSQL string, whereArgs []any, group *groupBy) (string, []any, error) {
+	if err := validateSQLIdentifiers(table, group.fields); err != nil {
+		return "", nil, err
+	}
+
+	groupByParts := make([]string, len(group.fields))
+	for i, field := range group.fields {
+		groupByParts[i] = d.quote(field)
+	}
+	selectParts := append(append([]string{}, groupByParts...), "COUNT(*) AS "+d.quote(GroupCountField))
+	var args []any
+	for _, metric := range group.metrics {
+		metricSQL, metricArgs, err := d.buildMetricSQL(metric)
+		if err != nil {
+			return "", nil, err
+		}
+		selectParts = append(selectParts, metricSQL)
+		args = append(args, metricArgs...)
+	}
+	args = append(args, whereArgs...)
+
+	sqlStr := fmt.Sprintf("SELECT * FROM (SELECT %s FROM %s WHERE %s GROUP BY %s) AS %s",
+		strings.Join(selectParts, ", "), d.quote(table), whereSQL, strings.Join(groupByParts, ", "), d.quote("_groups"))
+	if group.having != nil {
+		havingSQL, havingArgs, err := group.having.ToSQL()
+		if err != nil {
+			return "", nil, err
+		}
+		sqlStr += " WHERE " + havingSQL
+		args = append(args, havingArgs...)
+	}
+
+	keys := group.sortKeys()
+	orderParts := make([]string, len(keys))
+	for i, key := range keys {
+		direction := "ASC"
+		if i == 0 && group.options.OrderBy != "" && group.options.OrderDesc {
+			direction = "DESC"
+		}
+		orderParts[i] = d.quote(key) + " " + direction
+	}
+	sqlStr += " ORDER BY " + strings.Join(orderParts, ", ")
+	if group.options.Limit > 0 {
+		sqlStr += fmt.Sprintf(" LIMIT %d", group.options.Limit)
+	}
+	if group.options.Offset > 0 {
+		sqlStr += fmt.Sprintf(" OFFSET %d", group.options.Offset)
+	}
+	return d.format(sqlStr), args, nil
+}
+
+// buildGroupExpr 构建桶聚合的分组表达式，字段按方言引用
+func (d sqlDialect) buildGroupExpr(agg aggregation.BucketAggregator) string {
+	switch v := agg.(type) {
+	case *aggregation.TermsAggregation:
+		return d.quote(v.Field)
+	case *aggregation.DateHistogramAggregation:
+		quoted := *v
+		quoted.Field = d.quote(v.Field)
+		if d.sqlite() {
+			return quoted.SQLiteGroupExpr()
+		}
+		return quoted.SQLGroupExpr()
+	}
+	return agg.SQLGroupExpr()
+}
@@ -0,0 +1,272 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/hatlonely/gox/cfg/commonopt"
+	"github.com/hatlonely/gox/rdb/aggregation"
+	"github.com/hatlonely/gox/rdb/query"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSQLDialect(t *testing.T) {
+	Convey("测试标识符引用", t, func() {
+		So(sqlDialect("mysql").quote("users"), ShouldEqual, "`users`")
+		So(sqlDialect("sqlite3").quote("app.users"), ShouldEqual, "`app`.`users`")
+		So(sqlDialect("postgres").quote("users"), ShouldEqual, `"users"`)
+		So(sqlDialect("mysql").quote("a`b"), ShouldEqual, "`a``b`")
+	})
+
+	Convey("测试标识符校验", t, func() {
+		for _, name := range []string{"users", "user_2fa", "2fa_enabled", "app.users", "$tmp"} {
+			So(validateSQLIdentifier("table", name), ShouldBeNil)
+		}
+		for _, name := range []string{"", "users; DROP TABLE users", "users--", "a b", "`users`", "app..users", "users)"} {
+			So(errors.Is(validateSQLIdentifier("table", name), ErrInvalidIdentifier), ShouldBeTrue)
+		}
+	})
+
+	Convey("测试语句构建", t, func() {
+		d := sqlDialect("postgres")
+
+		sqlStr, err := d.buildInsertSQL("users", []string{"id", "name"}, &CreateOptions{})
+		So(err, ShouldBeNil)
+		So(sqlStr, ShouldEqual, `INSERT INTO "users" ("id", "name") VALUES ($1, $2)`)
+
+		sqlStr, args, err := d.buildUpdatePartialSQL("users", map[string]any{"id": 1}, map[string]any{"name": "bob"}, &UpdateOptions{VersionField: "version", Version: 3})
+		So(err, ShouldBeNil)
+		So(sqlStr, ShouldEqual, `UPDATE "users" SET "name" = $1, "version" = "version" + 1 WHERE "id" = $2 AND "version" = $3`)
+		So(args, ShouldResemble, []any{"bob", 1, 3})
+
+		sqlStr, err = sqlDialect("mysql").buildUpsertSQL("users", []string{"age", "id", "name"}, []string{"name"})
+		So(err, ShouldBeNil)
+		So(sqlStr, ShouldEqual, "INSERT INTO `users` (`age`, `id`, `name`) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE `name` = VALUES(`name`)")
+		sqlStr, err = sqlDialect("sqlite3").buildUpsertSQL("users", []string{"id", "name"}, []string{"name"})
+		So(err, ShouldBeNil)
+		So(sqlStr, ShouldEqual, "INSERT INTO `users` (`id`, `name`) VALUES (?, ?) ON CONFLICT DO UPDATE SET `name` = excluded.`name`")
+
+		sqlStr, err = d.buildUpsertByPKSQL("users", []string{"id", "name"}, []string{"id"})
+		So(err, ShouldBeNil)
+		So(sqlStr, ShouldEqual, `INSERT INTO "users" ("id", "name") VALUES ($1, $2) ON CONFLICT ("id") DO UPDATE SET "name" = excluded."name"`)
+		sqlStr, err = sqlDialect("mysql").buildUpsertByPKSQL("users", []string{"id", "name"}, []string{"id"})
+		So(err, ShouldBeNil)
+		So(sqlStr, ShouldEqual, "INSERT INTO `users` (`id`, `name`) VALUES (?, ?) ON DUPLICATE KEY UPDATE `name` = VALUES(`name`)")
+		sqlStr, err = sqlDialect("sqlite3").buildUpsertByPKSQL("users", []string{"id"}, []string{"id"})
+		So(err, ShouldBeNil)
+		So(sqlStr, ShouldEqual, "INSERT INTO `users` (`id`) VALUES (?) ON CONFLICT (`id`) DO NOTHING")
+
+		sqlStr, args, err = sqlDialect("mysql").buildFindSQL("users", &query.TermQuery{Field: "name", Value: "bob"}, &QueryOptions{OrderBy: "age", Limit: 10})
+		So(err, ShouldBeNil)
+		So(sqlStr, ShouldEqual, "SELECT * FROM `users` WHERE name = ? ORDER BY `age` ASC LIMIT 10")
+		So(args, ShouldResemble, []any{"bob"})
+
+		sqlStr, _, err = sqlDialect("postgres").buildFindSQL("users", &query.TermQuery{Field: "name", Value: "bob"}, &QueryOptions{Fields: []string{"id", "name"}})
+		So(err, ShouldBeNil)
+		So(sqlStr, ShouldEqual, `SELECT "id", "name" FROM "users" WHERE name = $1`)
+		sqlStr, err = sqlDialect("mysql").buildGetSQL("users", []string{"id"}, []string{"name"})
+		So(err, ShouldBeNil)
+		So(sqlStr, ShouldEqual, "SELECT `name` FROM `users` WHERE `id` = ?")
+		_, err = sqlDialect("mysql").buildGetSQL("users", []string{"id"}, []string{"name`"})
+		So(err, ShouldWrap, ErrInvalidIdentifier)
+
+		_, err = sqlDialect("mysql").buildInsertSQL("users", []string{"id", "name) VALUES (1, 'x'); --"}, &CreateOptions{})
+		So(err, ShouldWrap, ErrInvalidIdentifier)
+		_, _, err = sqlDialect("mysql").buildFindSQL("users", &query.TermQuery{Field: "name", Value: "bob"}, &QueryOptions{OrderBy: "age; DROP TABLE users"})
+		So(err, ShouldWrap, ErrInvalidIdentifier)
+		_, _, err = sqlDialect("mysql").buildCountSQL("users", &query.BoolQuery{Must: []query.Query{&query.RangeQuery{Field: "1=1 OR age", Gte: 0}}})
+		So(err, ShouldWrap, ErrInvalidIdentifier)
+	})
+
+	Convey("测试地理位置查询", t, func() {
+		near := &query.GeoDistanceQuery{Field: "location", Center: query.GeoPoint{Lat: 31.23, Lon: 121.47}, Distance: 1000}
+
+		sqlStr, args, err := sqlDialect("postgres").buildCountSQL("shops", &query.BoolQuery{Must: []query.Query{near}})
+		So(err, ShouldBeNil)
+		So(sqlStr, ShouldEqual, `SELECT COUNT(*) FROM "shops" WHERE (ST_DWithin(location::geography, ST_SetSRID(ST_MakePoint($1, $2), 4326)::geography, $3))`)
+		So(args, ShouldResemble, []any{121.47, 31.23, 1000.0})
+		So(sqlDialect("postgres").mapFieldTypeToSQL(FieldTypeGeoPoint, 0), ShouldEqual, "GEOGRAPHY(POINT, 4326)")
+
+		// 只有 PostGIS 支持
+		_, _, err = sqlDialect("mysql").buildFindSQL("shops", &query.BoolQuery{Filter: []query.Query{near}}, &QueryOptions{})
+		So(err, ShouldNotBeNil)
+		_, _, err = sqlDialect("sqlite3").buildCountSQL("shops", &query.GeoBoundingBoxQuery{Field: "location"})
+		So(err, ShouldNotBeNil)
+		_, _, err = sqlDialect("postgres").buildCountSQL("shops", &query.GeoDistanceQuery{Field: "location) OR (1=1", Distance: 1})
+		So(err, ShouldWrap, ErrInvalidIdentifier)
+	})
+
+	Convey("测试全文检索", t, func() {
+		search := &query.FullTextQuery{Fields: []string{"title", "body"}, Text: "golang orm"}
+		filter := &query.TermQuery{Field: "status", Value: "published"}
+		q := &query.BoolQuery{Must: []query.Query{search}, Filter: []query.Query{filter}}
+
+		sqlStr, args, err := sqlDialect("mysql").buildFindSQL("articles", q, &QueryOptions{Score: true, OrderByScore: true, OrderBy: "id", Limit: 10})
+		So(err, ShouldBeNil)
+		So(sqlStr, ShouldEqual, "SELECT *, MATCH (title, body) AGAINST (? IN NATURAL LANGUAGE MODE) AS `_score` FROM `articles` "+
+			"WHERE (MATCH (title, body) AGAINST (? IN NATURAL LANGUAGE MODE)) AND (status = ?) ORDER BY `_score` DESC, `id` ASC LIMIT 10")
+		So(args, ShouldResemble, []any{"golang orm", "golang orm", "published"})
+
+		// SQLite 改写为对 FTS5 虚拟表的子查询
+		sqlStr, args, err = sqlDialect("sqlite3").buildFindSQL("articles", q, &QueryOptions{Score: true})
+		So(err, ShouldBeNil)
+		So(sqlStr, ShouldEqual, "SELECT *, COALESCE((SELECT -bm25(`articles_fts`) FROM `articles_fts` WHERE `articles_fts` MATCH ? AND rowid = `articles`.rowid), 0) AS `_score` FROM `articles` "+
+			"WHERE ((rowid IN (SELECT rowid FROM `articles_fts` WHERE `articles_fts` MATCH ?))) AND (status = ?)")
+		So(args, ShouldResemble, []any{`{title body} : ("golang" OR "orm")`, `{title body} : ("golang" OR "orm")`, "published"})
+		So(q.Must[0], ShouldEqual, search)
+
+		sqlStr, _, err = sqlDialect("sqlite3").buildCountSQL("articles", search)
+		So(err, ShouldBeNil)
+		So(sqlStr, ShouldEqual, "SELECT COUNT(*) FROM `articles` WHERE (rowid IN (SELECT rowid FROM `articles_fts` WHERE `articles_fts` MATCH ?))")
+
+		// 没有全文检索时无法计算评分，MustNot 中的全文检索不参与评分
+		_, _, err = sqlDialect("mysql").buildFindSQL("articles", &query.BoolQuery{MustNot: []query.Query{search}}, &QueryOptions{Score: true})
+		So(err, ShouldEqual, ErrScoreUnsupported)
+		_, _, err = sqlDialect("mysql").buildFindSQL("articles", search, &QueryOptions{OrderByScore: true, OrderBy: "id", SearchAfter: []any{1}})
+		So(err, ShouldNotBeNil)
+		_, _, err = sqlDialect("postgres").buildFindSQL("articles", search, &QueryOptions{})
+		So(err, ShouldNotBeNil)
+		_, _, err = sqlDialect("mysql").buildCountSQL("articles", &query.FullTextQuery{Fields: []string{"title) OR (1=1"}, Text: "x"})
+		So(err, ShouldWrap, ErrInvalidIdentifier)
+
+		Convey("全文索引", func() {
+			index := IndexDefinition{Name: "ft_articles", Fields: []string{"title", "body"}, FullText: true}
+			indexSQL, err := sqlDialect("mysql").buildCreateIndexSQL("articles", index)
+			So(err, ShouldBeNil)
+			So(indexSQL, ShouldEqual, "CREATE FULLTEXT INDEX `ft_articles` ON `articles` (`title`, `body`)")
+
+			indexSQL, err = sqlDialect("sqlite3").buildCreateIndexSQL("articles", index)
+			So(err, ShouldBeNil)
+			So(indexSQL, ShouldContainSubstring, "CREATE VIRTUAL TABLE IF NOT EXISTS `articles_fts` USING fts5(`title`, `body`, content='articles', content_rowid='rowid')")
+			So(indexSQL, ShouldContainSubstring, "CREATE TRIGGER IF NOT EXISTS `ft_articles` AFTER INSERT ON `articles`")
+
+			model := &TableModel{Table: "articles", Fields: []FieldDefinition{{Name: "title", Type: FieldTypeString}}, Indexes: []IndexDefinition{index, {Name: "ft_title", Fields: []string{"title"}, FullText: true}}}
+			_, err = sqlDialect("sqlite3").buildCreateTableSQL(model)
+			So(err, ShouldNotBeNil)
+			_, err = sqlDialect("mysql").buildCreateTableSQL(model)
+			So(err, ShouldBeNil)
+			_, err = sqlDialect("postgres").buildCreateTableSQL(model)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestSQLiteIdentifiers(t *testing.T) {
+	Convey("测试 SQLite 标识符处理", t, func() {
+		db, err := NewSQLWithOptions(&SQLOptions{Driver: "sqlite3", Database: ":memory:", MaxConns: 1, MaxIdle: 1, StatementCacheSize: 16})
+		So(err, ShouldBeNil)
+		defer db.Close()
+		ctx := context.Background()
+
+		Convey("保留字作为表名和列名", func() {
+			So(db.Migrate(ctx, &TableModel{
+				Table: "order",
+				Fields: []FieldDefinition{
+					{Name: "id", Type: FieldTypeInt, Required: true},
+					{Name: "group", Type: FieldTypeString},
+				},
+				PrimaryKey: []string{"id"},
+				Indexes:    []IndexDefinition{{Name: "index", Fields: []string{"group"}}},
+			}), ShouldBeNil)
+
+			So(db.Create(ctx, "order", db.GetBuilder().FromMap(map[string]any{"id": 1, "group": "a"}, "order")), ShouldBeNil)
+			So(db.UpdatePartial(ctx, "order", map[string]any{"id": 1}, map[string]any{"group": "b"}), ShouldBeNil)
+			record, err := db.Get(ctx, "order", map[string]any{"id": 1})
+			So(err, ShouldBeNil)
+			So(record.Fields()["group"], ShouldEqual, "b")
+
+			records, err := db.Find(ctx, "order", &query.BoolQuery{}, func(o *QueryOptions) { o.OrderBy, o.OrderDesc = "group", true })
+			So(err, ShouldBeNil)
+			So(len(records), ShouldEqual, 1)
+
+			So(db.WithTx(ctx, func(tx Transaction) error {
+				return tx.Delete(ctx, "order", map[string]any{"id": 1})
+			}), ShouldBeNil)
+			count, err := db.Count(ctx, "order", &query.BoolQuery{})
+			So(err, ShouldBeNil)
+			So(count, ShouldEqual, 0)
+		})
+
+		Convey("拒绝不合法的表名和列名", func() {
+			builder := db.GetBuilder()
+			So(db.Create(ctx, "users; DROP TABLE users", builder.FromMap(map[string]any{"id": 1}, "users")), ShouldWrap, ErrInvalidIdentifier)
+			_, err := db.Get(ctx, "users", map[string]any{"id = 1 OR 1": 1})
+			So(err, ShouldWrap, ErrInvalidIdentifier)
+			_, err = db.Find(ctx, "users", &query.BoolQuery{}, func(o *QueryOptions) { o.OrderBy = "id; --" })
+			So(err, ShouldWrap, ErrInvalidIdentifier)
+			So(db.Migrate(ctx, &TableModel{Table: "bad table"}), ShouldWrap, ErrInvalidIdentifier)
+
+			So(db.WithTx(ctx, func(tx Transaction) error {
+				return tx.Increment(ctx, "users", map[string]any{"id": 1}, "count = 0, admin", 1)
+			}), ShouldWrap, ErrInvalidIdentifier)
+		})
+
+		Convey("聚合的字段、别名和排序", func() {
+			So(db.Migrate(ctx, &TableModel{
+				Table: "order",
+				Fields: []FieldDefinition{
+					{Name: "id", Type: FieldTypeInt, Required: true},
+					{Name: "group", Type: FieldTypeString},
+					{Name: "limit", Type: FieldTypeInt},
+				},
+				PrimaryKey: []string{"id"},
+			}), ShouldBeNil)
+			builder := db.GetBuilder()
+			for i, group := range []string{"a", "a", "b"} {
+				So(db.Create(ctx, "order", builder.FromMap(map[string]any{"id": i + 1, "group": group, "limit": i + 1}, "order")), ShouldBeNil)
+			}
+
+			result, err := db.Aggregate(ctx, "order", &query.BoolQuery{}, []aggregation.Aggregation{
+				&aggregation.SumAggregation{MetricAggregation: aggregation.MetricAggregation{AggName: "select", Field: "limit"}},
+				&aggregation.TermsAggregation{
+					BucketAggregation: aggregation.BucketAggregation{AggName: "by_group", Field: "group", SubAggregations: []aggregation.Aggregation{
+						&aggregation.MaxAggregation{MetricAggregation: aggregation.MetricAggregation{AggName: "max_limit", Field: "limit"}},
+					}},
+					Order: map[string]string{"max_limit": "desc"},
+				},
+			})
+			So(err, ShouldBeNil)
+			So(result.Get("select"), ShouldEqual, 6)
+			buckets := result.Get("by_group").([]aggregation.Bucket)
+			So(len(buckets), ShouldEqual, 2)
+			So(buckets[0].Key(), ShouldEqual, "b")
+
+			invalid := [][]aggregation.Aggregation{
+				{&aggregation.SumAggregation{MetricAggregation: aggregation.MetricAggregation{AggName: "total", Field: "limit) FROM users --"}}},
+				{&aggregation.SumAggregation{MetricAggregation: aggregation.MetricAggregation{AggName: "total, password", Field: "limit"}}},
+				{&aggregation.TermsAggregation{BucketAggregation: aggregation.BucketAggregation{AggName: "by_group", Field: "group"}, Order: map[string]string{"_count; DROP TABLE users": "asc"}}},
+			}
+			for _, aggs := range invalid {
+				_, err := db.Aggregate(ctx, "order", &query.BoolQuery{}, aggs)
+				So(err, ShouldWrap, ErrInvalidIdentifier)
+			}
+			_, err = db.Aggregate(ctx, "order", &query.BoolQuery{}, []aggregation.Aggregation{
+				&aggregation.TermsAggregation{BucketAggregation: aggregation.BucketAggregation{AggName: "by_group", Field: "group"}, Order: map[string]string{"_count": "asc, id"}},
+			})
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestMySQLTLSConfig(t *testing.T) {
+	Convey("测试 MySQL TLS 配置的注册和注销", t, func() {
+		registered := func(name string) bool {
+			_, err := mysql.ParseDSN("user:pass@tcp(localhost:3306)/db?tls=" + name)
+			return err == nil
+		}
+
+		name, err := registerMySQLTLSConfig(&commonopt.TLSOptions{ServerName: "mysql"})
+		So(err, ShouldBeNil)
+		So(registered(name), ShouldBeTrue)
+		deregisterMySQLTLSConfig(name)
+		So(registered(name), ShouldBeFalse)
+
+		// 连接失败时注销创建过程中注册的 TLS 配置
+		_, err = NewSQLWithOptions(&SQLOptions{Driver: "mysql", Host: "127.0.0.1", Port: "1", TLS: &commonopt.TLSOptions{ServerName: "mysql"}})
+		So(err, ShouldNotBeNil)
+		So(registered(fmt.Sprintf("gox-%d", mysqlTLSConfigID.Load())), ShouldBeFalse)
+	})
+}
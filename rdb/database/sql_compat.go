@@ -0,0 +1,270 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// SQLCompatMySQL 在 SQLite 上模拟 MySQL 的部分行为，用于在内存 SQLite 上运行面向 MySQL 编写的单元测试
+const SQLCompatMySQL = "mysql"
+
+// sqliteMySQLDriver 注册了 MySQL 兼容函数的 SQLite 驱动名
+const sqliteMySQLDriver = "sqlite3_gox_mysql"
+
+// sqliteMySQLDialect SQLite 模拟 MySQL 时使用的方言
+const sqliteMySQLDialect sqlDialect = "sqlite3+mysql"
+
+func init() {
+	sql.Register(sqliteMySQLDriver, &sqlite3.SQLiteDriver{
+		ConnectHook: registerMySQLFunctions,
+	})
+}
+
+// sqlDriverName 返回 sql.Open 使用的驱动名，校验兼容模式只用于 sqlite3 驱动
+func sqlDriverName(options *SQLOptions) (string, error) {
+	switch options.Compat {
+	case "":
+		return options.Driver, nil
+	case SQLCompatMySQL:
+		if options.Driver != "sqlite3" {
+			return "", fmt.Errorf("compat %s requires driver sqlite3, got %s", options.Compat, options.Driver)
+		}
+		return sqliteMySQLDriver, nil
+	default:
+		return "", fmt.Errorf("unsupported compat: %s", options.Compat)
+	}
+}
+
+// newSQLDialect 根据驱动和兼容模式返回构建语句使用的方言
+func newSQLDialect(driver string, compat string) sqlDialect {
+	if driver == "sqlite3" && compat == SQLCompatMySQL {
+		return sqliteMySQLDialect
+	}
+	return sqlDialect(driver)
+}
+
+// mysqlDateTimeLayout MySQL DATETIME 的文本格式
+const mysqlDateTimeLayout = "2006-01-02 15:04:05"
+
+// registerMySQLFunctions 在连接上注册 SQLite 没有的 MySQL 常用函数
+//
+// 参数中有 NULL 时与 MySQL 一致返回 NULL（CONCAT_WS 忽略 NULL 参数，IF 只看条件）
+func registerMySQLFunctions(conn *sqlite3.SQLiteConn) error {
+	functions := []struct {
+		name string
+		impl any
+		pure bool
+	}{
+		{"NOW", mysqlNow, false},
+		{"CURDATE", mysqlCurDate, false},
+		{"UNIX_TIMESTAMP", mysqlUnixTimestamp, false},
+		{"FROM_UNIXTIME", mysqlFromUnixTime, true},
+		{"CONCAT", mysqlConcat, true},
+		{"CONCAT_WS", mysqlConcatWS, true},
+		{"IF", mysqlIf, true},
+		{"LEAST", mysqlLeast, true},
+		{"GREATEST", mysqlGreatest, true},
+		{"FIND_IN_SET", mysqlFindInSet, true},
+	}
+	for _, f := range functions {
+		if err := conn.RegisterFunc(f.name, f.impl, f.pure); err != nil {
+			return fmt.Errorf("failed to register function %s: %v", f.name, err)
+		}
+	}
+	return nil
+}
+
+func mysqlNow() string {
+	return time.Now().Format(mysqlDateTimeLayout)
+}
+
+func mysqlCurDate() string {
+	return time.Now().Format(time.DateOnly)
+}
+
+// mysqlUnixTimestamp 没有参数时返回当前时间戳，有参数时解析日期时间
+func mysqlUnixTimestamp(args ...any) any {
+	if len(args) == 0 {
+		return time.Now().Unix()
+	}
+	if compatNull(args[0]) {
+		return nil
+	}
+	s := compatString(args[0])
+	for _, layout := range []string{mysqlDateTimeLayout, time.DateOnly, time.RFC3339Nano} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t.Unix()
+		}
+	}
+	return nil
+}
+
+func mysqlFromUnixTime(ts any) any {
+	f, ok := compatFloat(ts)
+	if !ok {
+		return nil
+	}
+	return time.Unix(int64(f), 0).Format(mysqlDateTimeLayout)
+}
+
+func mysqlConcat(args ...any) any {
+	var sb strings.Builder
+	for _, arg := range args {
+		if compatNull(arg) {
+			return nil
+		}
+		sb.WriteString(compatString(arg))
+	}
+	return sb.String()
+}
+
+func mysqlConcatWS(sep any, args ...any) any {
+	if compatNull(sep) {
+		return nil
+	}
+	parts := make([]string, 0, len(args))
+	for _, arg := range args {
+		if !compatNull(arg) {
+			parts = append(parts, compatString(arg))
+		}
+	}
+	return strings.Join(parts, compatString(sep))
+}
+
+func mysqlIf(cond any, then any, otherwise any) any {
+	if compatTruthy(cond) {
+		return compatValue(then)
+	}
+	return compatValue(otherwise)
+}
+
+func mysqlLeast(args ...any) any {
+	return compatExtreme(args, -1)
+}
+
+func mysqlGreatest(args ...any) any {
+	return compatExtreme(args, 1)
+}
+
+// mysqlFindInSet 返回 needle 在逗号分隔列表中的位置（从 1 开始），不存在时返回 0
+func mysqlFindInSet(needle any, list any) any {
+	if compatNull(needle) || compatNull(list) {
+		return nil
+	}
+	s := compatString(list)
+	if s == "" {
+		return int64(0)
+	}
+	n := compatString(needle)
+	for i, item := range strings.Split(s, ",") {
+		if item == n {
+			return int64(i + 1)
+		}
+	}
+	return int64(0)
+}
+
+// compatExtreme 返回最小（sign 为 -1）或最大（sign 为 1）的参数，都是数字时按数值比较，否则按字符串比较
+func compatExtreme(args []any, sign int) any {
+	if len(args) == 0 {
+		return nil
+	}
+	numeric := true
+	for _, arg := range args {
+		if compatNull(arg) {
+			return nil
+		}
+		if _, ok := compatFloat(arg); !ok {
+			numeric = false
+		}
+	}
+	result := args[0]
+	for _, arg := range args[1:] {
+		var cmp int
+		if numeric {
+			a, _ := compatFloat(arg)
+			b, _ := compatFloat(result)
+			switch {
+			case a < b:
+				cmp = -1
+			case a > b:
+				cmp = 1
+			}
+		} else {
+			cmp = strings.Compare(compatString(arg), compatString(result))
+		}
+		if cmp*sign > 0 {
+			result = arg
+		}
+	}
+	return result
+}
+
+// compatNull 参数是否为 NULL，go-sqlite3 将 NULL 参数传为值为 nil 的 []byte
+func compatNull(v any) bool {
+	b, ok := v.([]byte)
+	return v == nil || ok && b == nil
+}
+
+// compatValue 将 NULL 参数转换为 nil，直接返回 []byte(nil) 会得到空的 BLOB 而不是 NULL
+func compatValue(v any) any {
+	if compatNull(v) {
+		return nil
+	}
+	return v
+}
+
+func compatString(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	case time.Time:
+		return v.Format(mysqlDateTimeLayout)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func compatFloat(v any) (float64, bool) {
+	switch v := v.(type) {
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	default:
+		return 0, false
+	}
+}
+
+// compatTruthy MySQL 的条件判断，NULL 和 0 为假，字符串按数值判断
+func compatTruthy(v any) bool {
+	if compatNull(v) {
+		return false
+	}
+	if f, ok := compatFloat(v); ok {
+		return f != 0
+	}
+	f, err := strconv.ParseFloat(strings.TrimSpace(compatString(v)), 64)
+	return err == nil && f != 0
+}
@@ -0,0 +1,96 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hatlonely/gox/rdb/query"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSQLCompatMySQL(t *testing.T) {
+	Convey("测试 SQLite 模拟 MySQL 的兼容模式", t, func() {
+		ctx := context.Background()
+		db, err := NewSQLWithOptions(&SQLOptions{Driver: "sqlite3", Database: ":memory:", Compat: SQLCompatMySQL, MaxConns: 1, MaxIdle: 1})
+		So(err, ShouldBeNil)
+		defer db.Close()
+		So(db.dialect(), ShouldEqual, sqliteMySQLDialect)
+
+		So(db.Migrate(ctx, &TableModel{
+			Table: "test_compat_users",
+			Fields: []FieldDefinition{
+				{Name: "id", Type: FieldTypeInt, Required: true},
+				{Name: "name", Type: FieldTypeString},
+				{Name: "email", Type: FieldTypeString},
+				{Name: "age", Type: FieldTypeInt},
+			},
+			PrimaryKey: []string{"id"},
+		}), ShouldBeNil)
+		builder := db.GetBuilder()
+		So(db.Create(ctx, "test_compat_users", builder.FromMap(map[string]any{"id": 1, "name": "Alice", "email": "alice@example.com", "age": 20}, "test_compat_users")), ShouldBeNil)
+
+		Convey("UpdateOnConflict 只更新写入的列", func() {
+			So(db.Create(ctx, "test_compat_users", builder.FromMap(map[string]any{"id": 1, "age": 21}, "test_compat_users"), WithUpdateOnConflict()), ShouldBeNil)
+
+			record, err := db.Get(ctx, "test_compat_users", map[string]any{"id": 1})
+			So(err, ShouldBeNil)
+			fields := record.Fields()
+			So(fields["name"], ShouldEqual, "Alice")
+			So(fields["email"], ShouldEqual, "alice@example.com")
+			So(fields["age"], ShouldEqual, 21)
+		})
+
+		Convey("字符串比较不区分大小写", func() {
+			count, err := db.Count(ctx, "test_compat_users", &query.TermQuery{Field: "name", Value: "alice"})
+			So(err, ShouldBeNil)
+			So(count, ShouldEqual, 1)
+		})
+
+		Convey("MySQL 函数", func() {
+			var concat, concatWS, ifResult, fromUnixTime string
+			var least, greatest, findInSet, unixTimestamp int64
+			So(db.db.QueryRowContext(ctx, `SELECT CONCAT(name, '-', age), CONCAT_WS(',', 'a', NULL, 'b'), IF(age > 18, 'adult', 'minor'),
+				LEAST(3, 1, 2), GREATEST(3, 1, 2), FIND_IN_SET('b', 'a,b,c'),
+				FROM_UNIXTIME(UNIX_TIMESTAMP('2024-01-02 03:04:05')), UNIX_TIMESTAMP('2024-01-02 03:04:05') - UNIX_TIMESTAMP('2024-01-02')
+				FROM test_compat_users WHERE id = 1`).Scan(
+				&concat, &concatWS, &ifResult, &least, &greatest, &findInSet, &fromUnixTime, &unixTimestamp,
+			), ShouldBeNil)
+			So(concat, ShouldEqual, "Alice-20")
+			So(concatWS, ShouldEqual, "a,b")
+			So(ifResult, ShouldEqual, "adult")
+			So(least, ShouldEqual, 1)
+			So(greatest, ShouldEqual, 3)
+			So(findInSet, ShouldEqual, 2)
+			So(fromUnixTime, ShouldEqual, "2024-01-02 03:04:05")
+			So(unixTimestamp, ShouldEqual, 3*3600+4*60+5)
+
+			var null any
+			So(db.db.QueryRowContext(ctx, "SELECT CONCAT('a', NULL)").Scan(&null), ShouldBeNil)
+			So(null, ShouldBeNil)
+
+			var now string
+			So(db.db.QueryRowContext(ctx, "SELECT NOW()").Scan(&now), ShouldBeNil)
+			So(now, ShouldHaveLength, len(mysqlDateTimeLayout))
+		})
+	})
+
+	Convey("测试兼容模式的配置校验", t, func() {
+		_, err := NewSQLWithOptions(&SQLOptions{Driver: "mysql", DSN: "user:pass@tcp(localhost:3306)/db", Compat: SQLCompatMySQL})
+		So(err, ShouldNotBeNil)
+		_, err = NewSQLWithOptions(&SQLOptions{Driver: "sqlite3", Database: ":memory:", Compat: "postgres"})
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("测试兼容模式的语句构建", t, func() {
+		sqlStr, err := sqliteMySQLDialect.buildInsertSQL("users", []string{"id", "name"}, &CreateOptions{UpdateOnConflict: true})
+		So(err, ShouldBeNil)
+		So(sqlStr, ShouldEqual, "INSERT INTO `users` (`id`, `name`) VALUES (?, ?) ON CONFLICT DO UPDATE SET `id` = excluded.`id`, `name` = excluded.`name`")
+
+		sqlStr, err = sqlDialect("sqlite3").buildInsertSQL("users", []string{"id", "name"}, &CreateOptions{UpdateOnConflict: true})
+		So(err, ShouldBeNil)
+		So(sqlStr, ShouldEqual, "INSERT OR REPLACE INTO `users` (`id`, `name`) VALUES (?, ?)")
+
+		So(sqliteMySQLDialect.mapFieldTypeToSQL(FieldTypeString, 100), ShouldEqual, "TEXT COLLATE NOCASE")
+		So(sqliteMySQLDialect.mapFieldTypeToSQL(FieldTypeInt, 0), ShouldEqual, "INTEGER")
+	})
+}
@@ -0,0 +1,191 @@
+package database
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"strings"
+	"sync"
+)
+
+// sqlStatement 缓存的 SQL 语句及其在主库上的预编译语句
+type sqlStatement struct {
+	key     string
+	query   string
+	stmt    *sql.Stmt // 预编译失败时为 nil，直接执行 query
+	refs    int       // 正在使用该语句的调用数
+	evicted bool      // 已从缓存中移除，最后一个使用者释放时关闭预编译语句
+}
+
+// sqlStatementCache 按语句形态（操作、表名、列集合、选项）缓存 SQL 和预编译语句，超出容量时淘汰最久未使用的语句
+type sqlStatementCache struct {
+	db   *sql.DB
+	size int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+}
+
+func newSQLStatementCache(db *sql.DB, size int) *sqlStatementCache {
+	return &sqlStatementCache{
+		db:      db,
+		size:    size,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// acquire 获取 key 对应的语句，不存在时调用 build 构建 SQL 并预编译，使用完后需要调用 release
+// build 返回错误（如表名不合法）时不缓存，直接返回错误
+func (c *sqlStatementCache) acquire(ctx context.Context, key string, build func() (string, error)) (*sqlStatement, error) {
+	c.mu.Lock()
+	if statement := c.lookup(key); statement != nil {
+		c.mu.Unlock()
+		return statement, nil
+	}
+	c.mu.Unlock()
+
+	query, err := build()
+	if err != nil {
+		return nil, err
+	}
+	stmt, err := c.db.PrepareContext(ctx, query)
+	if err != nil {
+		// 预编译失败（如表不存在）时不缓存，直接执行原始语句，由执行返回具体的错误
+		return &sqlStatement{query: query, refs: 1, evicted: true}, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if statement := c.lookup(key); statement != nil {
+		// 并发构建了相同的语句，使用先缓存的
+		stmt.Close()
+		return statement, nil
+	}
+
+	statement := &sqlStatement{key: key, query: query, stmt: stmt, refs: 1}
+	c.entries[key] = c.lru.PushFront(statement)
+	for c.lru.Len() > c.size {
+		c.evict(c.lru.Back())
+	}
+	return statement, nil
+}
+
+// lookup 查找并引用已缓存的语句，调用方需持有锁
+func (c *sqlStatementCache) lookup(key string) *sqlStatement {
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+	c.lru.MoveToFront(elem)
+	statement := elem.Value.(*sqlStatement)
+	statement.refs++
+	return statement
+}
+
+// evict 从缓存中移除语句，没有使用者时立即关闭，调用方需持有锁
+func (c *sqlStatementCache) evict(elem *list.Element) {
+	statement := c.lru.Remove(elem).(*sqlStatement)
+	delete(c.entries, statement.key)
+	statement.evicted = true
+	if statement.refs == 0 {
+		statement.stmt.Close()
+	}
+}
+
+// release 释放对语句的引用
+func (c *sqlStatementCache) release(statement *sqlStatement) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	statement.refs--
+	if statement.evicted && statement.refs == 0 && statement.stmt != nil {
+		statement.stmt.Close()
+	}
+}
+
+// reset 清空缓存，表结构变更后调用
+func (c *sqlStatementCache) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for c.lru.Len() > 0 {
+		c.evict(c.lru.Back())
+	}
+}
+
+// sqlStatementKey 由操作、表名和各组列名组成语句缓存的键
+func sqlStatementKey(op string, table string, columnGroups ...[]string) string {
+	var sb strings.Builder
+	sb.WriteString(op)
+	sb.WriteByte(0)
+	sb.WriteString(table)
+	for _, columns := range columnGroups {
+		sb.WriteByte(0)
+		for i, column := range columns {
+			if i > 0 {
+				sb.WriteByte(',')
+			}
+			sb.WriteString(column)
+		}
+	}
+	return sb.String()
+}
+
+// sqlColumnValues 按列的顺序取出参数
+func sqlColumnValues(data map[string]any, columns []string, args []any) []any {
+	for _, column := range columns {
+		args = append(args, data[column])
+	}
+	return args
+}
+
+// execStatement 执行写语句，启用语句缓存时复用主库上的预编译语句
+func (s *SQL) execStatement(ctx context.Context, key string, build func() (string, error), args []any) (sql.Result, error) {
+	if s.statements == nil {
+		query, err := build()
+		if err != nil {
+			return nil, err
+		}
+		return s.db.ExecContext(ctx, query, args...)
+	}
+
+	statement, err := s.statements.acquire(ctx, key, build)
+	if err != nil {
+		return nil, err
+	}
+	defer s.statements.release(statement)
+	if statement.stmt == nil {
+		return s.db.ExecContext(ctx, statement.query, args...)
+	}
+	return statement.stmt.ExecContext(ctx, args...)
+}
+
+// queryStatement 执行读语句，读主库时复用预编译语句，读副本时只复用缓存的 SQL
+func (s *SQL) queryStatement(ctx context.Context, key string, build func() (string, error), args []any) (*sql.Rows, error) {
+	db := s.reader(ctx)
+	if s.statements == nil {
+		query, err := build()
+		if err != nil {
+			return nil, err
+		}
+		return db.QueryContext(ctx, query, args...)
+	}
+
+	statement, err := s.statements.acquire(ctx, key, build)
+	if err != nil {
+		return nil, err
+	}
+	defer s.statements.release(statement)
+	if statement.stmt == nil || db != s.db {
+		return db.QueryContext(ctx, statement.query, args...)
+	}
+	// 预编译语句在返回的 Rows 关闭之前不会被真正关闭
+	return statement.stmt.QueryContext(ctx, args...)
+}
+
+// resetStatements 表结构变更后清空语句缓存，未启用语句缓存时不做处理
+func (s *SQL) resetStatements() {
+	if s.statements != nil {
+		s.statements.reset()
+	}
+}
@@ -0,0 +1,174 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type testStatementUser struct {
+	ID     int    `rdb:"id,primary"`
+	Name   string `rdb:"name"`
+	Age    int    `rdb:"age"`
+	Secret string `rdb:"-"`
+}
+
+var testStatementModel = &TableModel{
+	Table: "test_statement_users",
+	Fields: []FieldDefinition{
+		{Name: "id", Type: FieldTypeInt, Required: true},
+		{Name: "name", Type: FieldTypeString, Size: 100},
+		{Name: "age", Type: FieldTypeInt},
+	},
+	PrimaryKey: []string{"id"},
+}
+
+func newTestStatementSQL(tb testing.TB, cacheSize int) *SQL {
+	db, err := NewSQLWithOptions(&SQLOptions{Driver: "sqlite3", Database: ":memory:", MaxConns: 1, MaxIdle: 1, StatementCacheSize: cacheSize})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	if err := db.Migrate(context.Background(), testStatementModel); err != nil {
+		tb.Fatal(err)
+	}
+	return db
+}
+
+func TestSQLStatementCache(t *testing.T) {
+	Convey("测试语句缓存", t, func() {
+		ctx := context.Background()
+		db := newTestStatementSQL(t, 2)
+		defer db.Close()
+
+		Convey("相同列集合复用语句", func() {
+			for i := 1; i <= 3; i++ {
+				So(db.Create(ctx, "test_statement_users", db.GetBuilder().FromStruct(&testStatementUser{ID: i, Name: fmt.Sprint("user", i), Age: 20 + i})), ShouldBeNil)
+			}
+			So(db.statements.lru.Len(), ShouldEqual, 1)
+
+			record, err := db.Get(ctx, "test_statement_users", map[string]any{"id": 2})
+			So(err, ShouldBeNil)
+			var user testStatementUser
+			So(record.Scan(&user), ShouldBeNil)
+			So(user, ShouldResemble, testStatementUser{ID: 2, Name: "user2", Age: 22})
+			So(db.statements.lru.Len(), ShouldEqual, 2)
+
+			So(db.Update(ctx, "test_statement_users", map[string]any{"id": 2}, db.GetBuilder().FromMap(map[string]any{"name": "bob", "age": 30}, "test_statement_users")), ShouldBeNil)
+			record, err = db.Get(ctx, "test_statement_users", map[string]any{"id": 2})
+			So(err, ShouldBeNil)
+			So(record.Fields()["name"], ShouldEqual, "bob")
+			So(record.Fields()["age"], ShouldEqual, int64(30))
+
+			So(db.Delete(ctx, "test_statement_users", map[string]any{"id": 2}), ShouldBeNil)
+			_, err = db.Get(ctx, "test_statement_users", map[string]any{"id": 2})
+			So(err, ShouldEqual, ErrRecordNotFound)
+		})
+
+		Convey("超出容量时淘汰最久未使用的语句", func() {
+			So(db.Create(ctx, "test_statement_users", db.GetBuilder().FromMap(map[string]any{"id": 1}, "test_statement_users")), ShouldBeNil)
+			So(db.Create(ctx, "test_statement_users", db.GetBuilder().FromMap(map[string]any{"id": 2, "name": "b"}, "test_statement_users")), ShouldBeNil)
+			So(db.Create(ctx, "test_statement_users", db.GetBuilder().FromMap(map[string]any{"id": 3, "age": 3}, "test_statement_users")), ShouldBeNil)
+			So(db.statements.lru.Len(), ShouldEqual, 2)
+			So(db.statements.entries, ShouldNotContainKey, sqlStatementKey("insert", "test_statement_users", []string{"id"}))
+		})
+
+		Convey("冲突处理方式不同的语句分别缓存", func() {
+			record := db.GetBuilder().FromMap(map[string]any{"id": 1, "name": "a"}, "test_statement_users")
+			So(db.Create(ctx, "test_statement_users", record), ShouldBeNil)
+			So(db.Create(ctx, "test_statement_users", record), ShouldNotBeNil)
+			So(db.Create(ctx, "test_statement_users", record, WithIgnoreConflict()), ShouldBeNil)
+			So(db.Create(ctx, "test_statement_users", db.GetBuilder().FromMap(map[string]any{"id": 1, "name": "b"}, "test_statement_users"), WithUpdateOnConflict()), ShouldBeNil)
+
+			record, err := db.Get(ctx, "test_statement_users", map[string]any{"id": 1})
+			So(err, ShouldBeNil)
+			So(record.Fields()["name"], ShouldEqual, "b")
+		})
+
+		Convey("表结构变更后清空缓存", func() {
+			So(db.Create(ctx, "test_statement_users", db.GetBuilder().FromMap(map[string]any{"id": 1}, "test_statement_users")), ShouldBeNil)
+			So(db.statements.lru.Len(), ShouldEqual, 1)
+			So(db.DropTable(ctx, "test_statement_users"), ShouldBeNil)
+			So(db.statements.lru.Len(), ShouldEqual, 0)
+
+			So(db.Create(ctx, "test_statement_users", db.GetBuilder().FromMap(map[string]any{"id": 1}, "test_statement_users")), ShouldNotBeNil)
+			So(db.statements.lru.Len(), ShouldEqual, 0)
+
+			So(db.Migrate(ctx, testStatementModel), ShouldBeNil)
+			So(db.Create(ctx, "test_statement_users", db.GetBuilder().FromMap(map[string]any{"id": 1}, "test_statement_users")), ShouldBeNil)
+		})
+	})
+
+	Convey("测试结构体字段缓存", t, func() {
+		fields := cachedStructFields(reflect.TypeOf(testStatementUser{}), DefaultTagName)
+		So(fields, ShouldResemble, []sqlStructField{
+			{index: 0, column: "id"},
+			{index: 1, column: "name"},
+			{index: 2, column: "age"},
+			{index: 3, column: "Secret", ignored: true},
+		})
+		So(structToMap(&testStatementUser{ID: 1, Name: "a", Secret: "s"}, DefaultTagName), ShouldResemble, map[string]any{"id": 1, "name": "a", "age": 0})
+	})
+}
+
+func BenchmarkSQLCreate(b *testing.B) {
+	for _, cacheSize := range []int{0, 256} {
+		b.Run(fmt.Sprintf("statementCacheSize=%d", cacheSize), func(b *testing.B) {
+			db := newTestStatementSQL(b, cacheSize)
+			defer db.Close()
+			ctx := context.Background()
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				record := db.GetBuilder().FromStruct(&testStatementUser{ID: i, Name: "user", Age: 20})
+				if err := db.Create(ctx, "test_statement_users", record); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkSQLGet(b *testing.B) {
+	for _, cacheSize := range []int{0, 256} {
+		b.Run(fmt.Sprintf("statementCacheSize=%d", cacheSize), func(b *testing.B) {
+			db := newTestStatementSQL(b, cacheSize)
+			defer db.Close()
+			ctx := context.Background()
+			for i := 0; i < 100; i++ {
+				if err := db.Create(ctx, "test_statement_users", db.GetBuilder().FromStruct(&testStatementUser{ID: i, Name: "user", Age: 20})); err != nil {
+					b.Fatal(err)
+				}
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				record, err := db.Get(ctx, "test_statement_users", map[string]any{"id": i % 100})
+				if err != nil {
+					b.Fatal(err)
+				}
+				var user testStatementUser
+				if err := record.Scan(&user); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkStructToMap(b *testing.B) {
+	user := &testStatementUser{ID: 1, Name: "user", Age: 20}
+	b.Run("cached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			structToMap(user, DefaultTagName)
+		}
+	})
+	b.Run("uncached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			sqlStructFields.Delete(sqlStructFieldsKey{rt: reflect.TypeOf(testStatementUser{}), tagName: DefaultTagName})
+			structToMap(user, DefaultTagName)
+		}
+	})
+}
@@ -0,0 +1,54 @@
+package database
+
+import (
+	"context"
+
+	"github.com/hatlonely/gox/rdb/query"
+	"github.com/hatlonely/gox/ref"
+	"github.com/pkg/errors"
+)
+
+// newChangeProvider 通过 ref 创建变更订阅的实现
+func newChangeProvider(options *ref.TypeOptions) (ChangeWatcher, error) {
+	provider, err := ref.NewWithOptions(options)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to create change provider")
+	}
+	watcher, ok := provider.(ChangeWatcher)
+	if !ok {
+		return nil, errors.Errorf("change provider %T does not implement ChangeWatcher", provider)
+	}
+	return watcher, nil
+}
+
+// Watch 通过 ChangeProvider 订阅表的变更，订阅期间算作进行中的操作，未配置时返回 ErrWatchNotSupported
+func (s *SQL) Watch(ctx context.Context, table string, query query.Query, opts ...WatchOption) (<-chan ChangeEvent, error) {
+	if s.changes == nil {
+		return nil, ErrWatchNotSupported
+	}
+
+	ctx, done, err := s.ops.enter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	source, err := s.changes.Watch(ctx, table, query, opts...)
+	if err != nil {
+		done()
+		return nil, err
+	}
+
+	// 转发事件，provider 关闭 channel 后结束订阅
+	events := make(chan ChangeEvent, newWatchOptions(opts).BufferSize)
+	go func() {
+		defer done()
+		defer close(events)
+		for event := range source {
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events, nil
+}
@@ -0,0 +1,191 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"strings"
+)
+
+// ApplySQLFile 按文件名顺序执行 fsys 中匹配 pattern 的 SQL 脚本
+//
+// 用于视图、触发器、存储过程等无法通过 TableModel 表达的 DDL。脚本中的多条语句按分号拆分，
+// 支持引号、注释、SQLite 触发器的 BEGIN ... END 语句块，以及 MySQL 客户端的 DELIMITER 指令。
+// SQLite 支持事务性 DDL，每个文件在一个事务中执行，失败时整体回滚；
+// MySQL 的 DDL 会隐式提交事务，逐条执行，失败时已执行的语句不会回滚
+func (s *SQL) ApplySQLFile(ctx context.Context, fsys fs.FS, pattern string) error {
+	ctx, done, err := s.ops.enter(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	files, err := fs.Glob(fsys, pattern)
+	if err != nil {
+		return fmt.Errorf("invalid pattern %q: %v", pattern, err)
+	}
+
+	for _, file := range files {
+		content, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %v", file, err)
+		}
+		if err := s.applySQLStatements(ctx, splitSQLStatements(string(content))); err != nil {
+			return fmt.Errorf("failed to apply %s: %w", file, err)
+		}
+	}
+
+	return nil
+}
+
+// applySQLStatements 执行拆分后的语句，支持事务性 DDL 的数据库在事务中执行
+func (s *SQL) applySQLStatements(ctx context.Context, statements []string) error {
+	defer s.resetStatements()
+
+	if s.driver != "sqlite3" {
+		return execSQLStatements(ctx, s.db, statements)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	if err := execSQLStatements(ctx, tx, statements); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+type sqlExecer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+func execSQLStatements(ctx context.Context, execer sqlExecer, statements []string) error {
+	for i, statement := range statements {
+		if _, err := execer.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("statement %d %q: %w", i+1, statement, err)
+		}
+	}
+	return nil
+}
+
+// splitSQLStatements 将脚本拆分为单条语句，去掉注释和语句末尾的分隔符
+func splitSQLStatements(script string) []string {
+	var statements []string
+	var current strings.Builder
+	delimiter := ";"
+	// 触发器语句块嵌套深度，大于 0 时分号属于语句块内部
+	depth := 0
+
+	flush := func() {
+		if statement := strings.TrimSpace(current.String()); statement != "" {
+			statements = append(statements, statement)
+		}
+		current.Reset()
+		depth = 0
+	}
+
+	for i := 0; i < len(script); {
+		c := script[i]
+
+		// DELIMITER 指令只能出现在语句开头，独占一行
+		if strings.TrimSpace(current.String()) == "" && hasPrefixFold(script[i:], "DELIMITER ") {
+			end := strings.IndexByte(script[i:], '\n')
+			if end < 0 {
+				end = len(script) - i
+			}
+			if d := strings.TrimSpace(script[i+len("DELIMITER ") : i+end]); d != "" {
+				delimiter = d
+			}
+			current.Reset()
+			i += end
+			continue
+		}
+
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			end := i + 1
+			for end < len(script) {
+				if script[end] == '\\' && c != '`' {
+					end += 2
+					continue
+				}
+				if script[end] == c {
+					// 连续两个引号是转义
+					if end+1 < len(script) && script[end+1] == c {
+						end += 2
+						continue
+					}
+					break
+				}
+				end++
+			}
+			end = min(end+1, len(script))
+			current.WriteString(script[i:end])
+			i = end
+		case strings.HasPrefix(script[i:], "--") || c == '#':
+			end := strings.IndexByte(script[i:], '\n')
+			if end < 0 {
+				end = len(script) - i
+			}
+			i += end
+		case strings.HasPrefix(script[i:], "/*"):
+			end := strings.Index(script[i+2:], "*/")
+			if end < 0 {
+				i = len(script)
+			} else {
+				i += end + 4
+			}
+			current.WriteByte(' ')
+		case isSQLWordByte(c) && (i == 0 || !isSQLWordByte(script[i-1])):
+			end := i
+			for end < len(script) && isSQLWordByte(script[end]) {
+				end++
+			}
+			word := strings.ToUpper(script[i:end])
+			switch {
+			case word == "BEGIN" && depth == 0 && delimiter == ";" && isCreateTrigger(current.String()):
+				depth++
+			case word == "CASE" && depth > 0:
+				depth++
+			case word == "END" && depth > 0:
+				depth--
+			}
+			current.WriteString(script[i:end])
+			i = end
+		case depth == 0 && strings.HasPrefix(script[i:], delimiter):
+			flush()
+			i += len(delimiter)
+		default:
+			current.WriteByte(c)
+			i++
+		}
+	}
+	flush()
+
+	return statements
+}
+
+// isCreateTrigger 判断语句是否为 CREATE [TEMP] TRIGGER
+func isCreateTrigger(statement string) bool {
+	fields := strings.Fields(strings.ToUpper(statement))
+	if len(fields) < 2 || fields[0] != "CREATE" {
+		return false
+	}
+	for _, field := range fields[1:min(len(fields), 3)] {
+		if field == "TRIGGER" {
+			return true
+		}
+	}
+	return false
+}
+
+func isSQLWordByte(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+func hasPrefixFold(s, prefix string) bool {
+	return len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix)
+}
@@ -0,0 +1,124 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"github.com/hatlonely/gox/rdb/query"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSplitSQLStatements(t *testing.T) {
+	Convey("测试 SQL 脚本拆分", t, func() {
+		Convey("按分号拆分并去掉注释", func() {
+			statements := splitSQLStatements(`
+-- 用户表
+CREATE TABLE users (id INT PRIMARY KEY, name VARCHAR(64)); /* 索引 */
+CREATE INDEX idx_name ON users (name);
+# mysql 风格注释
+INSERT INTO users VALUES (1, 'a;b'), (2, "it''s; \"ok\"");
+`)
+			So(statements, ShouldResemble, []string{
+				"CREATE TABLE users (id INT PRIMARY KEY, name VARCHAR(64))",
+				"CREATE INDEX idx_name ON users (name)",
+				`INSERT INTO users VALUES (1, 'a;b'), (2, "it''s; \"ok\"")`,
+			})
+		})
+
+		Convey("SQLite 触发器语句块", func() {
+			statements := splitSQLStatements(`
+CREATE TRIGGER trg AFTER INSERT ON users
+BEGIN
+  UPDATE stats SET n = n + 1;
+  UPDATE stats SET kind = CASE WHEN n > 1 THEN 'many' ELSE 'one' END;
+END;
+SELECT 1;
+`)
+			So(len(statements), ShouldEqual, 2)
+			So(statements[0], ShouldStartWith, "CREATE TRIGGER trg")
+			So(statements[0], ShouldEndWith, "END")
+			So(statements[1], ShouldEqual, "SELECT 1")
+		})
+
+		Convey("MySQL DELIMITER 指令", func() {
+			statements := splitSQLStatements(`
+DELIMITER $$
+CREATE PROCEDURE p()
+BEGIN
+  SELECT 1;
+  SELECT 2;
+END$$
+DELIMITER ;
+SELECT 3;
+`)
+			So(len(statements), ShouldEqual, 2)
+			So(statements[0], ShouldStartWith, "CREATE PROCEDURE p()")
+			So(statements[0], ShouldContainSubstring, "SELECT 2;")
+			So(statements[1], ShouldEqual, "SELECT 3")
+		})
+
+		Convey("空脚本", func() {
+			So(splitSQLStatements("  -- only comment\n ; ;"), ShouldBeEmpty)
+		})
+	})
+}
+
+func TestSQLiteApplySQLFile(t *testing.T) {
+	Convey("测试 SQLite 执行 SQL 脚本文件", t, func() {
+		db, err := NewSQLWithOptions(&SQLOptions{Driver: "sqlite3", Database: ":memory:", MaxConns: 1, MaxIdle: 1})
+		So(err, ShouldBeNil)
+		defer db.Close()
+
+		ctx := context.Background()
+		fsys := fstest.MapFS{
+			"schema/001_tables.sql": {Data: []byte(`
+CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT, active INTEGER);
+CREATE TABLE stats (n INTEGER);
+INSERT INTO stats VALUES (0);
+`)},
+			"schema/002_views.sql": {Data: []byte(`
+CREATE VIEW active_users AS SELECT id, name FROM users WHERE active = 1;
+CREATE TRIGGER users_count AFTER INSERT ON users
+BEGIN
+  UPDATE stats SET n = n + 1;
+END;
+`)},
+			"schema/readme.txt": {Data: []byte("not sql")},
+		}
+
+		So(db.ApplySQLFile(ctx, fsys, "schema/*.sql"), ShouldBeNil)
+
+		So(db.Create(ctx, "users", db.GetBuilder().FromMap(map[string]any{"id": 1, "name": "a", "active": 1}, "users")), ShouldBeNil)
+		So(db.Create(ctx, "users", db.GetBuilder().FromMap(map[string]any{"id": 2, "name": "b", "active": 0}, "users")), ShouldBeNil)
+
+		var n int
+		So(db.db.QueryRowContext(ctx, "SELECT n FROM stats").Scan(&n), ShouldBeNil)
+		So(n, ShouldEqual, 2)
+
+		count, err := db.Count(ctx, "active_users", &query.TermQuery{Field: "name", Value: "a"})
+		So(err, ShouldBeNil)
+		So(count, ShouldEqual, 1)
+		count, err = db.Count(ctx, "active_users", &query.TermQuery{Field: "name", Value: "b"})
+		So(err, ShouldBeNil)
+		So(count, ShouldEqual, 0)
+
+		Convey("失败时整个文件回滚", func() {
+			err := db.ApplySQLFile(ctx, fstest.MapFS{"bad.sql": {Data: []byte(`
+CREATE TABLE orders (id INTEGER PRIMARY KEY);
+INSERT INTO not_exists VALUES (1);
+`)}}, "*.sql")
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "bad.sql")
+			So(err.Error(), ShouldContainSubstring, "statement 2")
+
+			var count int
+			So(db.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM sqlite_master WHERE name = 'orders'").Scan(&count), ShouldBeNil)
+			So(count, ShouldEqual, 0)
+		})
+
+		Convey("非法的匹配模式", func() {
+			So(db.ApplySQLFile(ctx, fsys, "["), ShouldNotBeNil)
+		})
+	})
+}
@@ -2,6 +2,7 @@ package database
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"testing"
 	"time"
@@ -520,6 +521,8 @@ func TestSQLiteAggregate(t *testing.T) {
 				{Name: "age", Type: FieldTypeInt},
 				{Name: "score", Type: FieldTypeFloat},
 				{Name: "active", Type: FieldTypeBool, Default: true},
+				{Name: "email", Type: FieldTypeString, Size: 255},
+				{Name: "create_at", Type: FieldTypeDate},
 			},
 			PrimaryKey: []string{"id"},
 		}
@@ -547,6 +550,137 @@ func TestSQLiteAggregate(t *testing.T) {
 			result, err := sql.Aggregate(ctx, "test_agg_users", termQuery, aggs)
 			So(err, ShouldBeNil)
 			So(result, ShouldNotBeNil)
+			So(result.GetCount("total_count"), ShouldEqual, 2)
+		})
+
+		Convey("不支持管道聚合", func() {
+			lookupAgg := &aggregation.LookupAggregation{AggName: "orders", From: "orders", LocalField: "id", ForeignField: "user_id"}
+			_, err := sql.Aggregate(ctx, "test_agg_users", &query.TermQuery{Field: "active", Value: true}, []aggregation.Aggregation{lookupAgg})
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "not supported by sql")
+		})
+
+		Convey("桶聚合嵌套子聚合", func() {
+			rangeQuery := &query.RangeQuery{Field: "age", Gte: 0}
+			ageAgg := &aggregation.TermsAggregation{
+				BucketAggregation: aggregation.BucketAggregation{
+					AggName: "by_age",
+					Field:   "age",
+					SubAggregations: []aggregation.Aggregation{
+						&aggregation.AvgAggregation{MetricAggregation: aggregation.MetricAggregation{AggName: "avg_score", Field: "score"}},
+					},
+				},
+			}
+			activeAgg := &aggregation.TermsAggregation{
+				BucketAggregation: aggregation.BucketAggregation{
+					AggName: "by_active",
+					Field:   "active",
+					SubAggregations: []aggregation.Aggregation{
+						&aggregation.MaxAggregation{MetricAggregation: aggregation.MetricAggregation{AggName: "max_score", Field: "score"}},
+						ageAgg,
+					},
+				},
+				Order: map[string]string{"_count": "desc"},
+			}
+
+			result, err := sql.Aggregate(ctx, "test_agg_users", rangeQuery, []aggregation.Aggregation{activeAgg})
+			So(err, ShouldBeNil)
+
+			buckets := result.GetBuckets("by_active")
+			So(len(buckets), ShouldEqual, 2)
+			So(buckets[0].DocCount(), ShouldEqual, 2)
+			So(buckets[0].SubAggregations().GetValue("max_score"), ShouldEqual, 95.5)
+
+			ageBuckets := buckets[0].SubAggregations().GetBuckets("by_age")
+			So(len(ageBuckets), ShouldEqual, 2)
+			So(ageBuckets[0].Key(), ShouldEqual, 25)
+			So(ageBuckets[0].SubAggregations().GetValue("avg_score"), ShouldEqual, 88.0)
+
+			inactive := result.GetBucket("by_active", 0)
+			So(inactive, ShouldNotBeNil)
+			So(inactive.DocCount(), ShouldEqual, 1)
+			bob := inactive.SubAggregations().GetBucket("by_age", 35)
+			So(bob, ShouldNotBeNil)
+			So(bob.SubAggregations().GetValue("avg_score"), ShouldEqual, 92.5)
+		})
+
+		Convey("嵌套词条聚合的 Size 作用于每个父桶", func() {
+			sql.Create(ctx, "test_agg_users", sql.builder.FromStruct(TestSQLiteUser{ID: 4, Name: "Tom", Age: 40, Score: 70.0, Active: false}))
+			sql.Create(ctx, "test_agg_users", sql.builder.FromStruct(TestSQLiteUser{ID: 5, Name: "Amy", Age: 25, Score: 80.0, Active: true}))
+
+			ageAgg := &aggregation.TermsAggregation{
+				BucketAggregation: aggregation.BucketAggregation{AggName: "by_age", Field: "age"},
+				Size:              1,
+				Order:             map[string]string{"_count": "desc"},
+			}
+			activeAgg := &aggregation.TermsAggregation{
+				BucketAggregation: aggregation.BucketAggregation{
+					AggName:         "by_active",
+					Field:           "active",
+					SubAggregations: []aggregation.Aggregation{ageAgg},
+				},
+			}
+
+			result, err := sql.Aggregate(ctx, "test_agg_users", &query.RangeQuery{Field: "age", Gte: 0}, []aggregation.Aggregation{activeAgg})
+			So(err, ShouldBeNil)
+			So(len(result.GetBuckets("by_active")), ShouldEqual, 2)
+
+			// active: 25 岁 2 人、30 岁 1 人，只保留文档数最多的 25 岁
+			activeAges := result.GetBucket("by_active", 1).SubAggregations().GetBuckets("by_age")
+			So(len(activeAges), ShouldEqual, 1)
+			So(activeAges[0].Key(), ShouldEqual, 25)
+			So(activeAges[0].DocCount(), ShouldEqual, 2)
+
+			// inactive: 35 岁和 40 岁各 1 人，文档数相同时按桶键升序
+			inactiveAges := result.GetBucket("by_active", 0).SubAggregations().GetBuckets("by_age")
+			So(len(inactiveAges), ShouldEqual, 1)
+			So(inactiveAges[0].Key(), ShouldEqual, 35)
+		})
+
+		Convey("派生指标", func() {
+			rangeQuery := &query.RangeQuery{Field: "age", Gte: 0}
+			aggs := []aggregation.Aggregation{
+				&aggregation.SumAggregation{MetricAggregation: aggregation.MetricAggregation{AggName: "total_score", Field: "score"}},
+				&aggregation.CountAggregation{MetricAggregation: aggregation.MetricAggregation{AggName: "total"}},
+				&aggregation.BucketScriptAggregation{AggName: "avg_score", Script: "total_score / total"},
+				&aggregation.TermsAggregation{
+					BucketAggregation: aggregation.BucketAggregation{
+						AggName: "by_active",
+						Field:   "active",
+						SubAggregations: []aggregation.Aggregation{
+							&aggregation.MaxAggregation{MetricAggregation: aggregation.MetricAggregation{AggName: "max_score", Field: "score"}},
+							&aggregation.BucketScriptAggregation{AggName: "share", Script: "_count / 3 * 100"},
+							&aggregation.BucketScriptAggregation{
+								AggName:     "gap",
+								Script:      "(max - 90) * share",
+								BucketsPath: map[string]string{"max": "max_score"},
+							},
+						},
+					},
+				},
+			}
+
+			result, err := sql.Aggregate(ctx, "test_agg_users", rangeQuery, aggs)
+			So(err, ShouldBeNil)
+			So(result.GetValue("avg_score"), ShouldAlmostEqual, 92.0)
+
+			active := result.GetBucket("by_active", 1)
+			So(active, ShouldNotBeNil)
+			So(active.SubAggregations().GetValue("share"), ShouldAlmostEqual, 200.0/3)
+			So(active.SubAggregations().GetValue("gap"), ShouldAlmostEqual, 5.5*200/3)
+
+			// 除数为 0 时不输出结果
+			result, err = sql.Aggregate(ctx, "test_agg_users", &query.TermQuery{Field: "age", Value: 99}, []aggregation.Aggregation{
+				&aggregation.CountAggregation{MetricAggregation: aggregation.MetricAggregation{AggName: "total"}},
+				&aggregation.BucketScriptAggregation{AggName: "ratio", Script: "1 / total"},
+			})
+			So(err, ShouldBeNil)
+			So(result.Get("ratio"), ShouldBeNil)
+
+			_, err = sql.Aggregate(ctx, "test_agg_users", rangeQuery, []aggregation.Aggregation{
+				&aggregation.BucketScriptAggregation{AggName: "bad", Script: "a / (b"},
+			})
+			So(err, ShouldNotBeNil)
 		})
 	})
 }
@@ -875,17 +1009,18 @@ func TestSQLiteBuildMethods(t *testing.T) {
 				PrimaryKey: []string{"id"},
 			}
 
-			sqlStr := sql.buildCreateTableSQL(model)
-			So(sqlStr, ShouldContainSubstring, "CREATE TABLE IF NOT EXISTS test_build_table")
-			So(sqlStr, ShouldContainSubstring, "id INTEGER NOT NULL")
-			So(sqlStr, ShouldContainSubstring, "name TEXT NOT NULL")
-			So(sqlStr, ShouldContainSubstring, "email TEXT")
-			So(sqlStr, ShouldContainSubstring, "age INTEGER DEFAULT 0")
-			So(sqlStr, ShouldContainSubstring, "active INTEGER DEFAULT 1")
-			So(sqlStr, ShouldContainSubstring, "score REAL")
-			So(sqlStr, ShouldContainSubstring, "data TEXT")
-			So(sqlStr, ShouldContainSubstring, "created_at TEXT")
-			So(sqlStr, ShouldContainSubstring, "PRIMARY KEY (id)")
+			sqlStr, err := sql.dialect().buildCreateTableSQL(model)
+			So(err, ShouldBeNil)
+			So(sqlStr, ShouldContainSubstring, "CREATE TABLE IF NOT EXISTS `test_build_table`")
+			So(sqlStr, ShouldContainSubstring, "`id` INTEGER NOT NULL")
+			So(sqlStr, ShouldContainSubstring, "`name` TEXT NOT NULL")
+			So(sqlStr, ShouldContainSubstring, "`email` TEXT")
+			So(sqlStr, ShouldContainSubstring, "`age` INTEGER DEFAULT 0")
+			So(sqlStr, ShouldContainSubstring, "`active` INTEGER DEFAULT 1")
+			So(sqlStr, ShouldContainSubstring, "`score` REAL")
+			So(sqlStr, ShouldContainSubstring, "`data` TEXT")
+			So(sqlStr, ShouldContainSubstring, "`created_at` TEXT")
+			So(sqlStr, ShouldContainSubstring, "PRIMARY KEY (`id`)")
 		})
 
 		Convey("测试 buildColumnDefinition", func() {
@@ -897,27 +1032,27 @@ func TestSQLiteBuildMethods(t *testing.T) {
 				Default:  "default_value",
 			}
 
-			columnDef := sql.buildColumnDefinition(field)
-			So(columnDef, ShouldEqual, "test_field TEXT NOT NULL DEFAULT 'default_value'")
+			columnDef := sql.dialect().buildColumnDefinition(field)
+			So(columnDef, ShouldEqual, "`test_field` TEXT NOT NULL DEFAULT 'default_value'")
 		})
 
 		Convey("测试 mapFieldTypeToSQL", func() {
-			So(sql.mapFieldTypeToSQL(FieldTypeString, 100), ShouldEqual, "TEXT")
-			So(sql.mapFieldTypeToSQL(FieldTypeString, 0), ShouldEqual, "TEXT")
-			So(sql.mapFieldTypeToSQL(FieldTypeInt, 0), ShouldEqual, "INTEGER")
-			So(sql.mapFieldTypeToSQL(FieldTypeFloat, 0), ShouldEqual, "REAL")
-			So(sql.mapFieldTypeToSQL(FieldTypeBool, 0), ShouldEqual, "INTEGER")
-			So(sql.mapFieldTypeToSQL(FieldTypeDate, 0), ShouldEqual, "TEXT")
-			So(sql.mapFieldTypeToSQL(FieldTypeJSON, 0), ShouldEqual, "TEXT")
+			So(sql.dialect().mapFieldTypeToSQL(FieldTypeString, 100), ShouldEqual, "TEXT")
+			So(sql.dialect().mapFieldTypeToSQL(FieldTypeString, 0), ShouldEqual, "TEXT")
+			So(sql.dialect().mapFieldTypeToSQL(FieldTypeInt, 0), ShouldEqual, "INTEGER")
+			So(sql.dialect().mapFieldTypeToSQL(FieldTypeFloat, 0), ShouldEqual, "REAL")
+			So(sql.dialect().mapFieldTypeToSQL(FieldTypeBool, 0), ShouldEqual, "INTEGER")
+			So(sql.dialect().mapFieldTypeToSQL(FieldTypeDate, 0), ShouldEqual, "TEXT")
+			So(sql.dialect().mapFieldTypeToSQL(FieldTypeJSON, 0), ShouldEqual, "TEXT")
 		})
 
 		Convey("测试 formatDefaultValue", func() {
-			So(sql.formatDefaultValue("test"), ShouldEqual, "'test'")
-			So(sql.formatDefaultValue("test's"), ShouldEqual, "'test''s'")
-			So(sql.formatDefaultValue(true), ShouldEqual, "1")
-			So(sql.formatDefaultValue(false), ShouldEqual, "0")
-			So(sql.formatDefaultValue(123), ShouldEqual, "123")
-			So(sql.formatDefaultValue(12.34), ShouldEqual, "12.34")
+			So(sql.dialect().formatDefaultValue("test"), ShouldEqual, "'test'")
+			So(sql.dialect().formatDefaultValue("test's"), ShouldEqual, "'test''s'")
+			So(sql.dialect().formatDefaultValue(true), ShouldEqual, "1")
+			So(sql.dialect().formatDefaultValue(false), ShouldEqual, "0")
+			So(sql.dialect().formatDefaultValue(123), ShouldEqual, "123")
+			So(sql.dialect().formatDefaultValue(12.34), ShouldEqual, "12.34")
 		})
 
 		Convey("测试 buildCreateIndexSQL", func() {
@@ -926,16 +1061,18 @@ func TestSQLiteBuildMethods(t *testing.T) {
 				Fields: []string{"name", "age"},
 				Unique: false,
 			}
-			indexSQL := sql.buildCreateIndexSQL("test_table", index)
-			So(indexSQL, ShouldEqual, "CREATE INDEX IF NOT EXISTS idx_test ON test_table (name, age)")
+			indexSQL, err := sql.dialect().buildCreateIndexSQL("test_table", index)
+			So(err, ShouldBeNil)
+			So(indexSQL, ShouldEqual, "CREATE INDEX IF NOT EXISTS `idx_test` ON `test_table` (`name`, `age`)")
 
 			uniqueIndex := IndexDefinition{
 				Name:   "idx_unique_email",
 				Fields: []string{"email"},
 				Unique: true,
 			}
-			uniqueIndexSQL := sql.buildCreateIndexSQL("test_table", uniqueIndex)
-			So(uniqueIndexSQL, ShouldEqual, "CREATE UNIQUE INDEX IF NOT EXISTS idx_unique_email ON test_table (email)")
+			uniqueIndexSQL, err := sql.dialect().buildCreateIndexSQL("test_table", uniqueIndex)
+			So(err, ShouldBeNil)
+			So(uniqueIndexSQL, ShouldEqual, "CREATE UNIQUE INDEX IF NOT EXISTS `idx_unique_email` ON `test_table` (`email`)")
 		})
 	})
 }
@@ -1094,4 +1231,802 @@ func TestSQLiteEdgeCases(t *testing.T) {
 			So(err.Error(), ShouldContainSubstring, "length mismatch")
 		})
 	})
-}
\ No newline at end of file
+}
+func TestSQLiteStats(t *testing.T) {
+	Convey("测试 SQLite Stats 方法", t, func() {
+		sql, err := NewSQLWithOptions(testSQLiteOptions)
+		So(err, ShouldBeNil)
+		defer sql.Close()
+
+		ctx := context.Background()
+		model := &TableModel{
+			Table: "test_stats_users",
+			Fields: []FieldDefinition{
+				{Name: "id", Type: FieldTypeInt, Required: true},
+				{Name: "name", Type: FieldTypeString, Size: 100, Required: true},
+			},
+			PrimaryKey: []string{"id"},
+		}
+		So(sql.Migrate(ctx, model), ShouldBeNil)
+		defer sql.DropTable(ctx, "test_stats_users")
+
+		for i := 1; i <= 3; i++ {
+			record := sql.builder.FromMap(map[string]any{"id": i, "name": "user"}, "test_stats_users")
+			So(sql.Create(ctx, "test_stats_users", record), ShouldBeNil)
+		}
+
+		Convey("统计行数", func() {
+			stats, err := sql.Stats(ctx, "test_stats_users")
+			So(err, ShouldBeNil)
+			So(stats.RowCount, ShouldEqual, 3)
+			So(stats.DataSize, ShouldBeGreaterThanOrEqualTo, 0)
+		})
+
+		Convey("表不存在", func() {
+			_, err := sql.Stats(ctx, "test_stats_not_exists")
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("实现 StatsProvider 接口", func() {
+			var db Database = sql
+			_, ok := db.(StatsProvider)
+			So(ok, ShouldBeTrue)
+		})
+	})
+}
+
+func TestSQLiteFindStream(t *testing.T) {
+	Convey("测试 SQLite FindStream 方法", t, func() {
+		sql, err := NewSQLWithOptions(testSQLiteOptions)
+		So(err, ShouldBeNil)
+		defer sql.Close()
+
+		ctx := context.Background()
+		model := &TableModel{
+			Table: "test_stream_users",
+			Fields: []FieldDefinition{
+				{Name: "id", Type: FieldTypeInt, Required: true},
+				{Name: "name", Type: FieldTypeString, Size: 100, Required: true},
+				{Name: "age", Type: FieldTypeInt},
+			},
+			PrimaryKey: []string{"id"},
+		}
+		So(sql.Migrate(ctx, model), ShouldBeNil)
+		defer sql.DropTable(ctx, "test_stream_users")
+
+		for i := 1; i <= 5; i++ {
+			record := sql.builder.FromMap(map[string]any{"id": i, "name": fmt.Sprintf("user%d", i), "age": 20 + i}, "test_stream_users")
+			So(sql.Create(ctx, "test_stream_users", record), ShouldBeNil)
+		}
+
+		Convey("逐条遍历结果", func() {
+			rangeQuery := &query.RangeQuery{Field: "age", Gte: 22}
+			cursor, err := sql.FindStream(ctx, "test_stream_users", rangeQuery, func(opts *QueryOptions) {
+				opts.OrderBy = "id"
+			}, WithBatchSize(2))
+			So(err, ShouldBeNil)
+			defer cursor.Close()
+
+			var ids []int
+			for cursor.Next() {
+				var user struct {
+					ID   int    `rdb:"id"`
+					Name string `rdb:"name"`
+				}
+				So(cursor.Scan(&user), ShouldBeNil)
+				So(cursor.Record(), ShouldNotBeNil)
+				ids = append(ids, user.ID)
+			}
+			So(cursor.Err(), ShouldBeNil)
+			So(ids, ShouldResemble, []int{2, 3, 4, 5})
+
+			// 遍历结束后没有当前记录
+			So(cursor.Scan(&struct{}{}), ShouldNotBeNil)
+		})
+
+		Convey("分页参数", func() {
+			rangeQuery := &query.RangeQuery{Field: "age", Gte: 0}
+			cursor, err := sql.FindStream(ctx, "test_stream_users", rangeQuery, func(opts *QueryOptions) {
+				opts.OrderBy = "id"
+				opts.OrderDesc = true
+				opts.Limit = 2
+			})
+			So(err, ShouldBeNil)
+			defer cursor.Close()
+
+			count := 0
+			for cursor.Next() {
+				count++
+			}
+			So(count, ShouldEqual, 2)
+		})
+
+		Convey("事务中流式查询", func() {
+			err := sql.WithTx(ctx, func(tx Transaction) error {
+				cursor, err := tx.FindStream(ctx, "test_stream_users", &query.TermQuery{Field: "id", Value: 1})
+				if err != nil {
+					return err
+				}
+				defer cursor.Close()
+
+				So(cursor.Next(), ShouldBeTrue)
+				So(cursor.Next(), ShouldBeFalse)
+				return cursor.Err()
+			})
+			So(err, ShouldBeNil)
+		})
+
+		Convey("无效表名", func() {
+			_, err := sql.FindStream(ctx, "test_stream_not_exists", &query.TermQuery{Field: "id", Value: 1})
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestSQLiteCountExists(t *testing.T) {
+	Convey("测试 SQLite Count 和 Exists 方法", t, func() {
+		sql, err := NewSQLWithOptions(testSQLiteOptions)
+		So(err, ShouldBeNil)
+		defer sql.Close()
+
+		ctx := context.Background()
+		model := &TableModel{
+			Table: "test_count_users",
+			Fields: []FieldDefinition{
+				{Name: "id", Type: FieldTypeInt, Required: true},
+				{Name: "name", Type: FieldTypeString, Size: 100, Required: true},
+				{Name: "age", Type: FieldTypeInt},
+			},
+			PrimaryKey: []string{"id"},
+		}
+		So(sql.Migrate(ctx, model), ShouldBeNil)
+		defer sql.DropTable(ctx, "test_count_users")
+
+		for i := 1; i <= 5; i++ {
+			record := sql.builder.FromMap(map[string]any{"id": i, "name": fmt.Sprintf("user%d", i), "age": 20 + i}, "test_count_users")
+			So(sql.Create(ctx, "test_count_users", record), ShouldBeNil)
+		}
+
+		Convey("统计记录数", func() {
+			count, err := sql.Count(ctx, "test_count_users", &query.RangeQuery{Field: "age", Gte: 23})
+			So(err, ShouldBeNil)
+			So(count, ShouldEqual, 3)
+
+			count, err = sql.Count(ctx, "test_count_users", &query.TermQuery{Field: "name", Value: "nobody"})
+			So(err, ShouldBeNil)
+			So(count, ShouldEqual, 0)
+		})
+
+		Convey("根据主键判断是否存在", func() {
+			exists, err := sql.Exists(ctx, "test_count_users", map[string]any{"id": 3})
+			So(err, ShouldBeNil)
+			So(exists, ShouldBeTrue)
+
+			exists, err = sql.Exists(ctx, "test_count_users", map[string]any{"id": 100})
+			So(err, ShouldBeNil)
+			So(exists, ShouldBeFalse)
+		})
+
+		Convey("事务中统计", func() {
+			err := sql.WithTx(ctx, func(tx Transaction) error {
+				record := sql.builder.FromMap(map[string]any{"id": 6, "name": "user6", "age": 26}, "test_count_users")
+				So(tx.Create(ctx, "test_count_users", record), ShouldBeNil)
+
+				count, err := tx.Count(ctx, "test_count_users", &query.RangeQuery{Field: "age", Gte: 0})
+				So(err, ShouldBeNil)
+				So(count, ShouldEqual, 6)
+
+				exists, err := tx.Exists(ctx, "test_count_users", map[string]any{"id": 6})
+				So(err, ShouldBeNil)
+				So(exists, ShouldBeTrue)
+				return nil
+			})
+			So(err, ShouldBeNil)
+		})
+
+		Convey("表不存在", func() {
+			_, err := sql.Count(ctx, "test_count_not_exists", &query.TermQuery{Field: "id", Value: 1})
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestSQLiteUpdatePartialIncrement(t *testing.T) {
+	Convey("测试 SQLite UpdatePartial 和 Increment 方法", t, func() {
+		sql, err := NewSQLWithOptions(testSQLiteOptions)
+		So(err, ShouldBeNil)
+		defer sql.Close()
+
+		ctx := context.Background()
+		model := &TableModel{
+			Table: "test_partial_users",
+			Fields: []FieldDefinition{
+				{Name: "id", Type: FieldTypeInt, Required: true},
+				{Name: "name", Type: FieldTypeString, Size: 100, Required: true},
+				{Name: "age", Type: FieldTypeInt},
+				{Name: "score", Type: FieldTypeFloat},
+			},
+			PrimaryKey: []string{"id"},
+		}
+		So(sql.Migrate(ctx, model), ShouldBeNil)
+		defer sql.DropTable(ctx, "test_partial_users")
+
+		record := sql.builder.FromMap(map[string]any{"id": 1, "name": "alice", "age": 20, "score": 1.5}, "test_partial_users")
+		So(sql.Create(ctx, "test_partial_users", record), ShouldBeNil)
+		pk := map[string]any{"id": 1}
+
+		Convey("只更新指定字段", func() {
+			So(sql.UpdatePartial(ctx, "test_partial_users", pk, map[string]any{"age": 30}), ShouldBeNil)
+
+			record, err := sql.Get(ctx, "test_partial_users", pk)
+			So(err, ShouldBeNil)
+			fields := record.Fields()
+			So(fields["age"], ShouldEqual, 30)
+			So(fields["name"], ShouldEqual, "alice")
+			So(fields["score"], ShouldEqual, 1.5)
+
+			So(sql.UpdatePartial(ctx, "test_partial_users", pk, nil), ShouldNotBeNil)
+		})
+
+		Convey("原子增减", func() {
+			So(sql.Increment(ctx, "test_partial_users", pk, "age", 5), ShouldBeNil)
+			So(sql.Increment(ctx, "test_partial_users", pk, "age", -2), ShouldBeNil)
+			So(sql.Increment(ctx, "test_partial_users", pk, "score", 0.25), ShouldBeNil)
+
+			record, err := sql.Get(ctx, "test_partial_users", pk)
+			So(err, ShouldBeNil)
+			So(record.Fields()["age"], ShouldEqual, 23)
+			So(record.Fields()["score"], ShouldEqual, 1.75)
+
+			So(sql.Increment(ctx, "test_partial_users", pk, "age", "1"), ShouldNotBeNil)
+		})
+
+		Convey("事务中部分更新", func() {
+			err := sql.WithTx(ctx, func(tx Transaction) error {
+				if err := tx.UpdatePartial(ctx, "test_partial_users", pk, map[string]any{"name": "bob"}); err != nil {
+					return err
+				}
+				return tx.Increment(ctx, "test_partial_users", pk, "age", 1)
+			})
+			So(err, ShouldBeNil)
+
+			record, err := sql.Get(ctx, "test_partial_users", pk)
+			So(err, ShouldBeNil)
+			So(record.Fields()["name"], ShouldEqual, "bob")
+			So(record.Fields()["age"], ShouldEqual, 21)
+		})
+	})
+}
+
+func TestSQLiteUpsert(t *testing.T) {
+	Convey("测试 SQLite Upsert 方法", t, func() {
+		sql, err := NewSQLWithOptions(testSQLiteOptions)
+		So(err, ShouldBeNil)
+		defer sql.Close()
+
+		ctx := context.Background()
+		model := &TableModel{
+			Table: "test_upsert_users",
+			Fields: []FieldDefinition{
+				{Name: "id", Type: FieldTypeInt, Required: true},
+				{Name: "email", Type: FieldTypeString, Size: 100, Required: true},
+				{Name: "name", Type: FieldTypeString, Size: 100},
+				{Name: "age", Type: FieldTypeInt},
+			},
+			PrimaryKey: []string{"id"},
+			Indexes: []IndexDefinition{
+				{Name: "idx_upsert_email", Fields: []string{"email"}, Unique: true},
+			},
+		}
+		So(sql.Migrate(ctx, model), ShouldBeNil)
+		defer sql.DropTable(ctx, "test_upsert_users")
+		pk := map[string]any{"id": 1}
+
+		Convey("不存在时插入，存在时更新", func() {
+			record := sql.builder.FromMap(map[string]any{"email": "alice@example.com", "name": "alice", "age": 20}, "test_upsert_users")
+			So(sql.Upsert(ctx, "test_upsert_users", pk, record), ShouldBeNil)
+
+			record, err := sql.Get(ctx, "test_upsert_users", pk)
+			So(err, ShouldBeNil)
+			So(record.Fields()["name"], ShouldEqual, "alice")
+
+			// record 中没有的列保持不变
+			record = sql.builder.FromMap(map[string]any{"email": "alice@example.com", "name": "bob"}, "test_upsert_users")
+			So(sql.Upsert(ctx, "test_upsert_users", pk, record), ShouldBeNil)
+
+			record, err = sql.Get(ctx, "test_upsert_users", pk)
+			So(err, ShouldBeNil)
+			So(record.Fields()["name"], ShouldEqual, "bob")
+			So(record.Fields()["age"], ShouldEqual, 20)
+
+			count, err := sql.Count(ctx, "test_upsert_users", &query.ExistsQuery{Field: "id"})
+			So(err, ShouldBeNil)
+			So(count, ShouldEqual, 1)
+		})
+
+		Convey("只按主键判断冲突", func() {
+			record := sql.builder.FromMap(map[string]any{"email": "alice@example.com", "name": "alice"}, "test_upsert_users")
+			So(sql.Upsert(ctx, "test_upsert_users", pk, record), ShouldBeNil)
+
+			// 其他唯一索引冲突时返回错误，不会更新其他记录
+			record = sql.builder.FromMap(map[string]any{"email": "alice@example.com", "name": "carol"}, "test_upsert_users")
+			So(sql.Upsert(ctx, "test_upsert_users", map[string]any{"id": 2}, record), ShouldNotBeNil)
+
+			record, err := sql.Get(ctx, "test_upsert_users", pk)
+			So(err, ShouldBeNil)
+			So(record.Fields()["name"], ShouldEqual, "alice")
+		})
+
+		Convey("主键不能为空", func() {
+			record := sql.builder.FromMap(map[string]any{"email": "alice@example.com"}, "test_upsert_users")
+			So(sql.Upsert(ctx, "test_upsert_users", nil, record), ShouldNotBeNil)
+		})
+
+		Convey("事务中插入或更新", func() {
+			err := sql.WithTx(ctx, func(tx Transaction) error {
+				record := sql.builder.FromMap(map[string]any{"email": "alice@example.com", "name": "alice"}, "test_upsert_users")
+				if err := tx.Upsert(ctx, "test_upsert_users", pk, record); err != nil {
+					return err
+				}
+				record = sql.builder.FromMap(map[string]any{"email": "alice@example.com", "name": "dave"}, "test_upsert_users")
+				return tx.Upsert(ctx, "test_upsert_users", pk, record)
+			})
+			So(err, ShouldBeNil)
+
+			record, err := sql.Get(ctx, "test_upsert_users", pk)
+			So(err, ShouldBeNil)
+			So(record.Fields()["name"], ShouldEqual, "dave")
+		})
+	})
+}
+
+func TestSQLiteFields(t *testing.T) {
+	Convey("测试 SQLite 只返回指定字段", t, func() {
+		sql, err := NewSQLWithOptions(testSQLiteOptions)
+		So(err, ShouldBeNil)
+		defer sql.Close()
+
+		ctx := context.Background()
+		model, err := NewTableModelBuilder().FromStruct(TestSQLiteUser{})
+		So(err, ShouldBeNil)
+		model.Table = "test_fields_users"
+		So(sql.Migrate(ctx, model), ShouldBeNil)
+		defer sql.DropTable(ctx, "test_fields_users")
+
+		for _, user := range []TestSQLiteUser{
+			{ID: 1, Name: "alice", Email: "alice@example.com", Age: 20},
+			{ID: 2, Name: "bob", Email: "bob@example.com", Age: 30},
+		} {
+			So(sql.Create(ctx, "test_fields_users", sql.builder.FromStruct(&user)), ShouldBeNil)
+		}
+
+		Convey("Get 只返回指定字段", func() {
+			record, err := sql.Get(ctx, "test_fields_users", map[string]any{"id": 1}, WithFields("name", "age"))
+			So(err, ShouldBeNil)
+			So(record.Fields(), ShouldResemble, map[string]any{"name": "alice", "age": int64(20)})
+
+			var user TestSQLiteUser
+			So(record.Scan(&user), ShouldBeNil)
+			So(user, ShouldResemble, TestSQLiteUser{Name: "alice", Age: 20})
+
+			// 不同字段的查询使用不同的语句
+			record, err = sql.Get(ctx, "test_fields_users", map[string]any{"id": 1}, WithFields("email"))
+			So(err, ShouldBeNil)
+			So(record.Fields(), ShouldResemble, map[string]any{"email": "alice@example.com"})
+			record, err = sql.Get(ctx, "test_fields_users", map[string]any{"id": 1})
+			So(err, ShouldBeNil)
+			So(len(record.Fields()), ShouldEqual, 7)
+		})
+
+		Convey("Find 和 FindStream 只返回指定字段", func() {
+			records, err := sql.Find(ctx, "test_fields_users", &query.RangeQuery{Field: "age", Gte: 20}, WithFields("id", "name"), func(opts *QueryOptions) { opts.OrderBy = "id" })
+			So(err, ShouldBeNil)
+			So(len(records), ShouldEqual, 2)
+			So(records[1].Fields(), ShouldResemble, map[string]any{"id": int64(2), "name": "bob"})
+
+			cursor, err := sql.FindStream(ctx, "test_fields_users", &query.TermQuery{Field: "name", Value: "bob"}, WithFields("email"))
+			So(err, ShouldBeNil)
+			defer cursor.Close()
+			So(cursor.Next(), ShouldBeTrue)
+			So(cursor.Record().Fields(), ShouldResemble, map[string]any{"email": "bob@example.com"})
+		})
+
+		Convey("字段名不合法或不存在时返回错误", func() {
+			_, err := sql.Get(ctx, "test_fields_users", map[string]any{"id": 1}, WithFields("name; DROP TABLE test_fields_users"))
+			So(err, ShouldWrap, ErrInvalidIdentifier)
+			_, err = sql.Find(ctx, "test_fields_users", &query.TermQuery{Field: "id", Value: 1}, WithFields("missing"))
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestSQLiteMigrateDiff(t *testing.T) {
+	Convey("测试 SQLite MigrateDiff 方法", t, func() {
+		sql, err := NewSQLWithOptions(&SQLOptions{Driver: "sqlite3", Database: ":memory:", MaxConns: 1, MaxIdle: 1})
+		So(err, ShouldBeNil)
+		defer sql.Close()
+
+		ctx := context.Background()
+		model := &TableModel{
+			Table: "test_diff_users",
+			Fields: []FieldDefinition{
+				{Name: "id", Type: FieldTypeInt, Required: true},
+				{Name: "name", Type: FieldTypeString, Size: 100},
+			},
+			PrimaryKey: []string{"id"},
+		}
+
+		Convey("表不存在时生成建表语句", func() {
+			statements, err := sql.MigrateDiff(ctx, model, WithDryRun())
+			So(err, ShouldBeNil)
+			So(len(statements), ShouldEqual, 1)
+			So(statements[0], ShouldStartWith, "CREATE TABLE IF NOT EXISTS `test_diff_users`")
+
+			_, err = sql.Stats(ctx, "test_diff_users")
+			So(err, ShouldNotBeNil)
+
+			statements, err = sql.MigrateDiff(ctx, model)
+			So(err, ShouldBeNil)
+			So(len(statements), ShouldEqual, 1)
+			_, err = sql.Stats(ctx, "test_diff_users")
+			So(err, ShouldBeNil)
+		})
+
+		Convey("为已有表补齐列和索引", func() {
+			So(sql.Migrate(ctx, model), ShouldBeNil)
+			record := sql.builder.FromMap(map[string]any{"id": 1, "name": "alice"}, "test_diff_users")
+			So(sql.Create(ctx, "test_diff_users", record), ShouldBeNil)
+
+			model.Fields = append(model.Fields,
+				FieldDefinition{Name: "age", Type: FieldTypeInt, Default: 18},
+				FieldDefinition{Name: "email", Type: FieldTypeString},
+			)
+			model.Indexes = []IndexDefinition{{Name: "idx_diff_users_email", Fields: []string{"email"}}}
+
+			statements, err := sql.MigrateDiff(ctx, model, WithDryRun())
+			So(err, ShouldBeNil)
+			So(statements, ShouldResemble, []string{
+				"ALTER TABLE `test_diff_users` ADD COLUMN `age` INTEGER DEFAULT 18",
+				"ALTER TABLE `test_diff_users` ADD COLUMN `email` TEXT",
+				"CREATE INDEX IF NOT EXISTS `idx_diff_users_email` ON `test_diff_users` (`email`)",
+			})
+
+			// 演练不修改表结构
+			_, err = sql.Count(ctx, "test_diff_users", &query.TermQuery{Field: "age", Value: 18})
+			So(err, ShouldNotBeNil)
+
+			statements, err = sql.MigrateDiff(ctx, model)
+			So(err, ShouldBeNil)
+			So(len(statements), ShouldEqual, 3)
+
+			count, err := sql.Count(ctx, "test_diff_users", &query.TermQuery{Field: "age", Value: 18})
+			So(err, ShouldBeNil)
+			So(count, ShouldEqual, 1)
+
+			// 结构一致后不再生成语句
+			statements, err = sql.MigrateDiff(ctx, model)
+			So(err, ShouldBeNil)
+			So(statements, ShouldBeEmpty)
+		})
+	})
+}
+
+func TestSQLiteHealth(t *testing.T) {
+	Convey("测试 SQLite 健康检查", t, func() {
+		options := *testSQLiteOptions
+		options.Retry = RetryOptions{MaxAttempts: 3, Backoff: time.Millisecond}
+		options.HealthCheckInterval = 10 * time.Millisecond
+		sql, err := NewSQLWithOptions(&options)
+		So(err, ShouldBeNil)
+
+		ctx := context.Background()
+		So(sql.Health(ctx), ShouldBeNil)
+
+		So(sql.WithTx(ctx, func(tx Transaction) error {
+			return tx.Health(ctx)
+		}), ShouldBeNil)
+
+		time.Sleep(30 * time.Millisecond)
+		So(sql.Close(), ShouldBeNil)
+		So(sql.Health(ctx), ShouldNotBeNil)
+	})
+}
+
+func TestSQLiteOptimisticLock(t *testing.T) {
+	Convey("测试 SQLite 乐观锁", t, func() {
+		sql, err := NewSQLWithOptions(testSQLiteOptions)
+		So(err, ShouldBeNil)
+		defer sql.Close()
+
+		ctx := context.Background()
+		model := &TableModel{
+			Table: "test_version_users",
+			Fields: []FieldDefinition{
+				{Name: "id", Type: FieldTypeInt, Required: true},
+				{Name: "name", Type: FieldTypeString, Size: 100},
+				{Name: "version", Type: FieldTypeInt},
+			},
+			PrimaryKey: []string{"id"},
+			Version:    "version",
+		}
+		So(sql.Migrate(ctx, model), ShouldBeNil)
+		defer sql.DropTable(ctx, "test_version_users")
+
+		So(sql.Create(ctx, "test_version_users", sql.builder.FromMap(map[string]any{"id": 1, "name": "alice", "version": 0}, "test_version_users")), ShouldBeNil)
+		pk := map[string]any{"id": 1}
+
+		Convey("版本一致时更新并递增版本", func() {
+			record := sql.builder.FromMap(map[string]any{"id": 1, "name": "bob", "version": 0}, "test_version_users")
+			So(sql.Update(ctx, "test_version_users", pk, record, WithVersion("version", 0)), ShouldBeNil)
+
+			got, err := sql.Get(ctx, "test_version_users", pk)
+			So(err, ShouldBeNil)
+			So(got.Fields()["name"], ShouldEqual, "bob")
+			So(got.Fields()["version"], ShouldEqual, 1)
+
+			// 使用过期版本更新
+			So(sql.Update(ctx, "test_version_users", pk, record, WithVersion("version", 0)), ShouldEqual, ErrVersionConflict)
+
+			So(sql.UpdatePartial(ctx, "test_version_users", pk, map[string]any{"name": "carol", "version": 100}, WithVersion("version", 1)), ShouldBeNil)
+			got, err = sql.Get(ctx, "test_version_users", pk)
+			So(err, ShouldBeNil)
+			So(got.Fields()["name"], ShouldEqual, "carol")
+			So(got.Fields()["version"], ShouldEqual, 2)
+
+			// 只递增版本
+			So(sql.UpdatePartial(ctx, "test_version_users", pk, nil, WithVersion("version", 2)), ShouldBeNil)
+		})
+
+		Convey("记录不存在", func() {
+			err := sql.UpdatePartial(ctx, "test_version_users", map[string]any{"id": 2}, map[string]any{"name": "x"}, WithVersion("version", 0))
+			So(err, ShouldEqual, ErrRecordNotFound)
+		})
+
+		Convey("事务中的乐观锁", func() {
+			err := sql.WithTx(ctx, func(tx Transaction) error {
+				if err := tx.UpdatePartial(ctx, "test_version_users", pk, map[string]any{"name": "dave"}, WithVersion("version", 0)); err != nil {
+					return err
+				}
+				return tx.UpdatePartial(ctx, "test_version_users", pk, map[string]any{"name": "eve"}, WithVersion("version", 0))
+			})
+			So(err, ShouldEqual, ErrVersionConflict)
+
+			got, err := sql.Get(ctx, "test_version_users", pk)
+			So(err, ShouldBeNil)
+			So(got.Fields()["name"], ShouldEqual, "alice")
+			So(got.Fields()["version"], ShouldEqual, 0)
+		})
+	})
+}
+
+func TestSQLiteMigrateViews(t *testing.T) {
+	Convey("测试 SQLite 视图迁移", t, func() {
+		sql, err := NewSQLWithOptions(&SQLOptions{Driver: "sqlite3", Database: ":memory:", MaxConns: 1, MaxIdle: 1})
+		So(err, ShouldBeNil)
+		defer sql.Close()
+
+		ctx := context.Background()
+		model := &TableModel{
+			Table: "test_view_orders",
+			Fields: []FieldDefinition{
+				{Name: "id", Type: FieldTypeInt, Required: true},
+				{Name: "status", Type: FieldTypeString, Size: 20},
+				{Name: "amount", Type: FieldTypeInt},
+			},
+			PrimaryKey: []string{"id"},
+			Views: []ViewDefinition{
+				{Name: "test_view_paid_orders", Select: "SELECT id, amount FROM test_view_orders WHERE status = 'paid'"},
+			},
+		}
+		So(sql.Migrate(ctx, model), ShouldBeNil)
+
+		for i, status := range []string{"paid", "paid", "created"} {
+			So(sql.Create(ctx, "test_view_orders", sql.builder.FromMap(map[string]any{"id": i + 1, "status": status, "amount": 10}, "test_view_orders")), ShouldBeNil)
+		}
+
+		count, err := sql.Count(ctx, "test_view_paid_orders", &query.TermQuery{Field: "amount", Value: 10})
+		So(err, ShouldBeNil)
+		So(count, ShouldEqual, 2)
+
+		Convey("重复迁移时替换视图定义", func() {
+			model.Views[0].Select = "SELECT id, amount FROM test_view_orders WHERE status = 'created'"
+			So(sql.Migrate(ctx, model), ShouldBeNil)
+
+			count, err := sql.Count(ctx, "test_view_paid_orders", &query.TermQuery{Field: "amount", Value: 10})
+			So(err, ShouldBeNil)
+			So(count, ShouldEqual, 1)
+		})
+
+		Convey("事务中迁移视图", func() {
+			model.Views = append(model.Views, ViewDefinition{Name: "test_view_all_orders", Select: "SELECT * FROM test_view_orders"})
+			So(sql.WithTx(ctx, func(tx Transaction) error {
+				return tx.Migrate(ctx, model)
+			}), ShouldBeNil)
+
+			count, err := sql.Count(ctx, "test_view_all_orders", &query.TermQuery{Field: "amount", Value: 10})
+			So(err, ShouldBeNil)
+			So(count, ShouldEqual, 3)
+		})
+
+		Convey("缺少视图定义", func() {
+			err := sql.Migrate(ctx, &TableModel{
+				Table:      "test_view_orders",
+				Fields:     model.Fields,
+				PrimaryKey: model.PrimaryKey,
+				Views:      []ViewDefinition{{Name: "test_view_empty"}},
+			})
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestBuildSQLViewStatements(t *testing.T) {
+	Convey("测试视图语句构建", t, func() {
+		view := ViewDefinition{Name: "v", Select: "SELECT 1"}
+
+		statements, err := sqlDialect("mysql").buildViewStatements(view)
+		So(err, ShouldBeNil)
+		So(statements, ShouldResemble, []string{"CREATE OR REPLACE VIEW `v` AS SELECT 1"})
+
+		statements, err = sqlDialect("sqlite3").buildViewStatements(view)
+		So(err, ShouldBeNil)
+		So(statements, ShouldResemble, []string{"DROP VIEW IF EXISTS `v`", "CREATE VIEW `v` AS SELECT 1"})
+
+		_, err = sqlDialect("mysql").buildViewStatements(ViewDefinition{Name: "v"})
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestSQLiteJSONFields(t *testing.T) {
+	type Address struct {
+		City   string `json:"city"`
+		Street string `json:"street"`
+	}
+	type Profile struct {
+		ID       int               `rdb:"id"`
+		Address  Address           `rdb:"address"`
+		Backup   *Address          `rdb:"backup"`
+		Tags     []string          `rdb:"tags"`
+		Labels   map[string]string `rdb:"labels"`
+		Avatar   []byte            `rdb:"avatar"`
+		CreateAt time.Time         `rdb:"create_at"`
+	}
+
+	Convey("测试 SQLite JSON 字段读写", t, func() {
+		ctx := context.Background()
+		sql, err := NewSQLWithOptions(&SQLOptions{Driver: "sqlite3", Database: ":memory:", MaxConns: 1, MaxIdle: 1})
+		So(err, ShouldBeNil)
+		defer sql.Close()
+
+		model, err := NewTableModelBuilder().FromStruct(&Profile{})
+		So(err, ShouldBeNil)
+		model.Table = "test_json_profiles"
+		So(sql.Migrate(ctx, model), ShouldBeNil)
+
+		Convey("结构体、map、slice 字段写入时序列化为 JSON", func() {
+			record := sql.GetBuilder().FromStruct(&Profile{ID: 1, Address: Address{City: "hz"}, Tags: []string{"a"}, Avatar: []byte("png")})
+			fields := record.Fields()
+			So(fields["address"], ShouldEqual, `{"city":"hz","street":""}`)
+			So(fields["tags"], ShouldEqual, `["a"]`)
+			So(fields["backup"], ShouldBeNil)
+			So(fields["labels"], ShouldBeNil)
+			So(fields["avatar"], ShouldResemble, []byte("png"))
+		})
+
+		Convey("JSON 列读取到嵌套结构体", func() {
+			profile := &Profile{
+				ID:       1,
+				Address:  Address{City: "hz", Street: "wenyi"},
+				Backup:   &Address{City: "sh"},
+				Tags:     []string{"a", "b"},
+				Labels:   map[string]string{"level": "vip"},
+				CreateAt: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			}
+			So(sql.Create(ctx, "test_json_profiles", sql.GetBuilder().FromStruct(profile)), ShouldBeNil)
+			So(sql.Create(ctx, "test_json_profiles", sql.GetBuilder().FromStruct(&Profile{ID: 2})), ShouldBeNil)
+
+			record, err := sql.Get(ctx, "test_json_profiles", map[string]any{"id": 1})
+			So(err, ShouldBeNil)
+			var got Profile
+			So(record.ScanStruct(&got), ShouldBeNil)
+			So(got.Address, ShouldResemble, profile.Address)
+			So(got.Backup, ShouldResemble, profile.Backup)
+			So(got.Tags, ShouldResemble, profile.Tags)
+			So(got.Labels, ShouldResemble, profile.Labels)
+
+			record, err = sql.Get(ctx, "test_json_profiles", map[string]any{"id": 2})
+			So(err, ShouldBeNil)
+			var empty Profile
+			So(record.ScanStruct(&empty), ShouldBeNil)
+			So(empty.Backup, ShouldBeNil)
+			So(empty.Tags, ShouldBeNil)
+		})
+
+		Convey("JSON 格式错误时返回错误", func() {
+			var got Profile
+			err := (&SQLRecord{data: map[string]any{"address": "not json"}}).ScanStruct(&got)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+// TestSQLiteArticle 全文检索测试用的结构体
+type TestSQLiteArticle struct {
+	ID     int    `rdb:"id,primary"`
+	Title  string `rdb:"title,fulltext"`
+	Body   string `rdb:"body,fulltext"`
+	Status string `rdb:"status"`
+}
+
+func (TestSQLiteArticle) Table() string {
+	return "test_articles"
+}
+
+func TestSQLiteFullText(t *testing.T) {
+	sql, err := NewSQLWithOptions(&SQLOptions{Driver: "sqlite3", Database: ":memory:", MaxConns: 1, MaxIdle: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sql.Close()
+
+	// FTS5 需要使用 sqlite_fts5 构建标签编译 sqlite3 驱动
+	var fts5 bool
+	if err := sql.db.QueryRow("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&fts5); err != nil || !fts5 {
+		t.Skip("sqlite3 driver is built without fts5, run with -tags sqlite_fts5")
+	}
+
+	Convey("测试 SQLite 全文检索", t, func() {
+		ctx := context.Background()
+		model, err := NewTableModelBuilder().FromStruct(TestSQLiteArticle{})
+		So(err, ShouldBeNil)
+		So(model.Indexes, ShouldResemble, []IndexDefinition{{Name: "ft_test_articles", Fields: []string{"title", "body"}, FullText: true}})
+		So(sql.Migrate(ctx, model), ShouldBeNil)
+		So(sql.Migrate(ctx, model), ShouldBeNil)
+
+		statements, err := sql.MigrateDiff(ctx, model, WithDryRun())
+		So(err, ShouldBeNil)
+		So(statements, ShouldBeEmpty)
+
+		articles := []TestSQLiteArticle{
+			{ID: 1, Title: "golang orm", Body: "an orm for golang with sql and mongo", Status: "published"},
+			{ID: 2, Title: "rust", Body: "systems programming", Status: "published"},
+			{ID: 3, Title: "golang", Body: "concurrency patterns", Status: "draft"},
+		}
+		for _, article := range articles {
+			So(sql.Create(ctx, model.Table, sql.GetBuilder().FromStruct(&article)), ShouldBeNil)
+		}
+
+		search := &query.FullTextQuery{Fields: []string{"title", "body"}, Text: "golang orm"}
+		records, err := sql.Find(ctx, model.Table, search, WithOrderByScore())
+		So(err, ShouldBeNil)
+		So(len(records), ShouldEqual, 2)
+
+		var first TestSQLiteArticle
+		So(records[0].Scan(&first), ShouldBeNil)
+		So(first.ID, ShouldEqual, 1)
+		So(records[0].Fields(), ShouldNotContainKey, scoreColumn)
+		score1, ok := RecordScore(records[0])
+		So(ok, ShouldBeTrue)
+		score2, _ := RecordScore(records[1])
+		So(score1, ShouldBeGreaterThan, score2)
+		So(score2, ShouldBeGreaterThan, 0)
+
+		Convey("与其他条件组合", func() {
+			count, err := sql.Count(ctx, model.Table, &query.BoolQuery{
+				Must:   []query.Query{search},
+				Filter: []query.Query{&query.TermQuery{Field: "status", Value: "published"}},
+			})
+			So(err, ShouldBeNil)
+			So(count, ShouldEqual, 1)
+
+			// 更新和删除同步到全文索引
+			So(sql.Update(ctx, model.Table, map[string]any{"id": 2}, sql.GetBuilder().FromStruct(&TestSQLiteArticle{ID: 2, Title: "golang vs rust", Status: "published"})), ShouldBeNil)
+			So(sql.Delete(ctx, model.Table, map[string]any{"id": 3}), ShouldBeNil)
+			records, err := sql.Find(ctx, model.Table, &query.FullTextQuery{Fields: []string{"title"}, Text: "golang"}, func(opts *QueryOptions) { opts.OrderBy = "id" })
+			So(err, ShouldBeNil)
+			So(len(records), ShouldEqual, 2)
+			_, ok := RecordScore(records[0])
+			So(ok, ShouldBeFalse)
+		})
+	})
+}
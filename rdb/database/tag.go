@@ -0,0 +1,47 @@
+package database
+
+import (
+	"reflect"
+	"strings"
+)
+
+// DefaultTagName 结构体字段与列名对应使用的默认标签
+const DefaultTagName = "rdb"
+
+// TagNamer 返回结构体字段与列名对应使用的标签名
+// 各后端的 RecordBuilder 都实现了该接口，Repository 按同一个标签解析实体
+type TagNamer interface {
+	TagName() string
+}
+
+// BuilderTagName 返回记录构建器使用的标签名，构建器未实现 TagNamer 时返回 DefaultTagName
+func BuilderTagName(builder RecordBuilder) string {
+	if namer, ok := builder.(TagNamer); ok {
+		return namer.TagName()
+	}
+	return DefaultTagName
+}
+
+// tagNameOrDefault 标签名为空时使用 DefaultTagName
+func tagNameOrDefault(tagName string) string {
+	if tagName == "" {
+		return DefaultTagName
+	}
+	return tagName
+}
+
+// tagColumn 返回标签中的列名，标签为空或没有指定列名时返回字段名
+// 其他数据库库的标签（如 db:"name,omitempty"）逗号之后的选项被忽略
+func tagColumn(field reflect.StructField, tag string) string {
+	if tag == "" || tag == "-" {
+		return field.Name
+	}
+	name := tag
+	if idx := strings.Index(tag, ","); idx != -1 {
+		name = tag[:idx]
+	}
+	if name == "" || strings.Contains(name, "=") {
+		return field.Name
+	}
+	return name
+}
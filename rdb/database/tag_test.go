@@ -0,0 +1,79 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hatlonely/gox/rdb/query"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// legacyUser 已有代码中使用 db 和 json 标签的结构体
+type legacyUser struct {
+	ID       int    `db:"id,primary" json:"id" bson:"_id"`
+	Name     string `db:"user_name" json:"userName" bson:"user_name,omitempty"`
+	Password string `db:"-" json:"-" bson:"-"`
+	Age      int
+}
+
+func TestTagName(t *testing.T) {
+	Convey("测试 SQL 使用自定义标签", t, func() {
+		sql, err := NewSQLWithOptions(&SQLOptions{Driver: "sqlite3", Database: ":memory:", MaxConns: 1, MaxIdle: 1, TagName: "db"})
+		So(err, ShouldBeNil)
+		defer sql.Close()
+
+		ctx := context.Background()
+		So(BuilderTagName(sql.GetBuilder()), ShouldEqual, "db")
+		So(BuilderTagName(TrackDirty(sql.GetBuilder(), nil)), ShouldEqual, "db")
+
+		model, err := NewTableModelBuilderWithTag("db").FromStruct(legacyUser{})
+		So(err, ShouldBeNil)
+		So(model.PrimaryKey, ShouldResemble, []string{"id"})
+		So(len(model.Fields), ShouldEqual, 3)
+		So(model.Fields[1].Name, ShouldEqual, "user_name")
+		So(sql.Migrate(ctx, model), ShouldBeNil)
+
+		record := sql.GetBuilder().FromStruct(&legacyUser{ID: 1, Name: "alice", Password: "secret", Age: 20})
+		So(record.Fields(), ShouldResemble, map[string]any{"id": 1, "user_name": "alice", "Age": 20})
+		So(sql.Create(ctx, model.Table, record), ShouldBeNil)
+
+		// 查询返回的记录按同一个标签解码
+		got, err := sql.Get(ctx, model.Table, map[string]any{"id": 1})
+		So(err, ShouldBeNil)
+		var user legacyUser
+		So(got.Scan(&user), ShouldBeNil)
+		So(user, ShouldResemble, legacyUser{ID: 1, Name: "alice", Age: 20})
+
+		var users []legacyUser
+		So(FindInto(ctx, sql, model.Table, &query.BoolQuery{}, &users), ShouldBeNil)
+		So(users, ShouldResemble, []legacyUser{{ID: 1, Name: "alice", Age: 20}})
+
+		Convey("默认使用 rdb 标签", func() {
+			So(BuilderTagName(&SQLRecordBuilder{}), ShouldEqual, DefaultTagName)
+			So((&SQLRecordBuilder{}).FromStruct(&legacyUser{ID: 1}).Fields(), ShouldContainKey, "Name")
+		})
+	})
+
+	Convey("测试 ES 使用自定义标签", t, func() {
+		builder := NewESRecordBuilder("json")
+		record := builder.FromStruct(&legacyUser{ID: 1, Name: "alice", Password: "secret", Age: 20})
+		So(record.Fields(), ShouldResemble, map[string]any{"id": 1, "userName": "alice", "Age": 20})
+
+		var user legacyUser
+		So(record.Scan(&user), ShouldBeNil)
+		So(user, ShouldResemble, legacyUser{ID: 1, Name: "alice", Age: 20})
+	})
+
+	Convey("测试 Mongo 使用自定义标签", t, func() {
+		builder := NewMongoRecordBuilder("bson")
+		fields := builder.FromStruct(&legacyUser{ID: 1, Age: 20}).Fields()
+		So(fields, ShouldContainKey, "_id")
+		So(fields, ShouldNotContainKey, "user_name")
+
+		record := builder.FromStruct(&legacyUser{ID: 1, Name: "alice", Age: 20})
+		var user legacyUser
+		So(record.Scan(&user), ShouldBeNil)
+		So(user, ShouldResemble, legacyUser{ID: 1, Name: "alice", Age: 20})
+	})
+}
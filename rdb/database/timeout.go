@@ -0,0 +1,83 @@
+package database
+
+import (
+	"context"
+	"time"
+)
+
+// WithTimeout 设置单次查询的超时，用于 Get、Find、FindStream、Aggregate
+// 在调用方 ctx 的基础上派生带超时的 ctx，ES 的 HTTP 请求随 ctx 取消；Mongo 同时设置 maxTimeMS，超时后服务端停止执行
+// Go 的函数类型不能同时作为 QueryOption 和 CreateOption，写操作使用 WithCreateTimeout、WithUpdateTimeout
+func WithTimeout(timeout time.Duration) QueryOption {
+	return func(opts *QueryOptions) {
+		opts.Timeout = timeout
+	}
+}
+
+// WithCreateTimeout 设置单次创建的超时，用于 Create、BatchCreate，BatchCreate 的超时覆盖整批记录
+func WithCreateTimeout(timeout time.Duration) CreateOption {
+	return func(opts *CreateOptions) {
+		opts.Timeout = timeout
+	}
+}
+
+// WithUpdateTimeout 设置单次更新的超时，用于 Update、UpdatePartial
+func WithUpdateTimeout(timeout time.Duration) UpdateOption {
+	return func(opts *UpdateOptions) {
+		opts.Timeout = timeout
+	}
+}
+
+// withTimeout 为单次操作设置超时，timeout 不大于 0 时原样返回 ctx
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// queryContext 按查询选项中的超时设置 ctx
+func queryContext(ctx context.Context, opts []QueryOption) (context.Context, context.CancelFunc) {
+	options := &QueryOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+	return withTimeout(ctx, options.Timeout)
+}
+
+// createContext 按创建选项中的超时设置 ctx
+func createContext(ctx context.Context, opts []CreateOption) (context.Context, context.CancelFunc) {
+	options := &CreateOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+	return withTimeout(ctx, options.Timeout)
+}
+
+// updateContext 按更新选项中的超时设置 ctx
+func updateContext(ctx context.Context, opts []UpdateOption) (context.Context, context.CancelFunc) {
+	options := &UpdateOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+	return withTimeout(ctx, options.Timeout)
+}
+
+// streamWithTimeout 在 ctx 上打开游标，游标关闭时才取消超时，打开失败时立即取消
+func streamWithTimeout(ctx context.Context, opts []QueryOption, open func(ctx context.Context) (RecordCursor, error)) (RecordCursor, error) {
+	options := &QueryOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+	if options.Timeout <= 0 {
+		return open(ctx)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, options.Timeout)
+	cursor, err := open(ctx)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	return &trackedCursor{RecordCursor: cursor, done: cancel}, nil
+}
@@ -0,0 +1,94 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hatlonely/gox/rdb/query"
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestOperationTimeout(t *testing.T) {
+	Convey("测试单次操作超时", t, func() {
+		ctx := context.Background()
+		sql, err := NewSQLWithOptions(&SQLOptions{Driver: "sqlite3", Database: ":memory:", MaxConns: 1, MaxIdle: 1})
+		So(err, ShouldBeNil)
+		defer sql.Close()
+
+		table := "test_timeout"
+		So(sql.Migrate(ctx, &TableModel{
+			Table: table,
+			Fields: []FieldDefinition{
+				{Name: "id", Type: FieldTypeInt, Required: true},
+				{Name: "name", Type: FieldTypeString, Size: 100},
+			},
+			PrimaryKey: []string{"id"},
+		}), ShouldBeNil)
+		builder := sql.GetBuilder()
+		q := &query.ExistsQuery{Field: "id"}
+
+		Convey("未超时时正常执行", func() {
+			So(sql.Create(ctx, table, builder.FromMap(map[string]any{"id": 1, "name": "alice"}, table), WithCreateTimeout(time.Second)), ShouldBeNil)
+			So(sql.UpdatePartial(ctx, table, map[string]any{"id": 1}, map[string]any{"name": "bob"}, WithUpdateTimeout(time.Second)), ShouldBeNil)
+			records, err := sql.Find(ctx, table, q, WithTimeout(time.Second))
+			So(err, ShouldBeNil)
+			So(records, ShouldHaveLength, 1)
+
+			// 流式查询的超时在游标关闭时才释放
+			cursor, err := sql.FindStream(ctx, table, q, WithTimeout(time.Second))
+			So(err, ShouldBeNil)
+			So(cursor.Next(), ShouldBeTrue)
+			So(cursor.Record().Fields()["name"], ShouldEqual, "bob")
+			So(cursor.Next(), ShouldBeFalse)
+			So(cursor.Err(), ShouldBeNil)
+			So(cursor.Close(), ShouldBeNil)
+
+			So(sql.WithTx(ctx, func(tx Transaction) error {
+				cursor, err := tx.FindStream(ctx, table, q, WithTimeout(time.Second))
+				if err != nil {
+					return err
+				}
+				defer cursor.Close()
+				if !cursor.Next() {
+					return errors.New("expected a record")
+				}
+				return cursor.Err()
+			}), ShouldBeNil)
+		})
+
+		Convey("超时后返回 context.DeadlineExceeded", func() {
+			err := sql.Create(ctx, table, builder.FromMap(map[string]any{"id": 2}, table), WithCreateTimeout(time.Nanosecond))
+			So(errors.Is(err, context.DeadlineExceeded), ShouldBeTrue)
+			err = sql.Update(ctx, table, map[string]any{"id": 2}, builder.FromMap(map[string]any{"id": 2}, table), WithUpdateTimeout(time.Nanosecond))
+			So(errors.Is(err, context.DeadlineExceeded), ShouldBeTrue)
+			_, err = sql.Find(ctx, table, q, WithTimeout(time.Nanosecond))
+			So(errors.Is(err, context.DeadlineExceeded), ShouldBeTrue)
+			_, err = sql.FindStream(ctx, table, q, WithTimeout(time.Nanosecond))
+			So(errors.Is(err, context.DeadlineExceeded), ShouldBeTrue)
+
+			exists, err := sql.Exists(ctx, table, map[string]any{"id": 2})
+			So(err, ShouldBeNil)
+			So(exists, ShouldBeFalse)
+		})
+
+		Convey("Mongo 设置 maxTimeMS", func() {
+			_, findOptions, _, err := mongoFindArgs(q, &QueryOptions{Timeout: 500 * time.Millisecond})
+			So(err, ShouldBeNil)
+			So(*findOptions.MaxTime, ShouldEqual, 500*time.Millisecond)
+
+			result, err := (&Mongo{}).Explain(ctx, table, q, WithTimeout(500*time.Millisecond))
+			So(err, ShouldBeNil)
+			So(result.Statement, ShouldEndWith, ".maxTimeMS(500)")
+		})
+
+		Convey("超时不影响查询缓存的键", func() {
+			key1, err := findCachePayload(q, []QueryOption{WithTimeout(time.Second)})
+			So(err, ShouldBeNil)
+			key2, err := findCachePayload(q, nil)
+			So(err, ShouldBeNil)
+			So(key1, ShouldEqual, key2)
+		})
+	})
+}
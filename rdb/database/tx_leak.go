@@ -0,0 +1,81 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/hatlonely/gox/log"
+	"github.com/hatlonely/gox/log/logger"
+	"github.com/hatlonely/gox/ref"
+	"github.com/pkg/errors"
+)
+
+// ErrTxCanceled 事务开启时的 context 已取消或超时，事务已自动回滚
+var ErrTxCanceled = errors.New("transaction rolled back because its context is done")
+
+// TxLeakOptions 事务泄漏检测配置
+type TxLeakOptions struct {
+	// Threshold 事务开启后超过该时间仍未提交或回滚时输出告警日志，为 0 时不检测
+	Threshold time.Duration `cfg:"threshold"`
+	// Logger 日志器配置，为空时使用默认日志器
+	Logger *ref.TypeOptions `cfg:"logger"`
+}
+
+// txLeakDetector 事务泄漏检测，记录开启事务的调用栈，超过阈值仍未结束的事务以 Warn 级别输出
+// 只输出日志不回滚事务，长事务可能是合理的，由调用方根据日志排查。nil detector 不做检测
+type txLeakDetector struct {
+	threshold time.Duration
+	logger    logger.Logger
+}
+
+// newTxLeakDetector 创建事务泄漏检测，未配置或阈值为 0 时返回 nil
+func newTxLeakDetector(options *TxLeakOptions) (*txLeakDetector, error) {
+	if options == nil || options.Threshold <= 0 {
+		return nil, nil
+	}
+	l, err := log.NewLoggerWithOptions(options.Logger)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to create transaction leak logger")
+	}
+	return &txLeakDetector{threshold: options.Threshold, logger: l}, nil
+}
+
+// watch 开始检测一个事务，事务结束时需要停止返回的定时器
+func (d *txLeakDetector) watch() *time.Timer {
+	if d == nil {
+		return nil
+	}
+
+	stack := callerStack(3)
+	start := time.Now()
+	return time.AfterFunc(d.threshold, func() {
+		d.logger.WarnContext(context.Background(), "transaction not committed or rolled back",
+			"age", time.Since(start),
+			"threshold", d.threshold,
+			"stack", stack,
+		)
+	})
+}
+
+// callerStack 返回调用栈，跳过 skip 层调用，最多 32 层
+func callerStack(skip int) string {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(skip+1, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	var b strings.Builder
+	for {
+		frame, more := frames.Next()
+		if strings.HasPrefix(frame.Function, "runtime.") {
+			break
+		}
+		fmt.Fprintf(&b, "%s\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+		if !more {
+			break
+		}
+	}
+	return b.String()
+}
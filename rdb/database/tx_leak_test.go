@@ -0,0 +1,145 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hatlonely/gox/log/logger"
+	"github.com/hatlonely/gox/rdb/query"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// chanLogger 将 Warn 日志发送到 channel 的日志器
+type chanLogger struct {
+	logger.Logger
+	records chan map[string]any
+}
+
+func (l *chanLogger) WarnContext(ctx context.Context, msg string, args ...any) {
+	record := map[string]any{"msg": msg}
+	for i := 0; i+1 < len(args); i += 2 {
+		record[fmt.Sprint(args[i])] = args[i+1]
+	}
+	l.records <- record
+}
+
+func TestTransactionContextBinding(t *testing.T) {
+	Convey("测试事务绑定 context", t, func() {
+		// context 取消时 database/sql 会丢弃连接，使用文件数据库保证新连接能看到同样的数据
+		db, err := NewSQLWithOptions(&SQLOptions{Driver: "sqlite3", Database: filepath.Join(t.TempDir(), "tx.db"), MaxConns: 1, MaxIdle: 1, CloseTimeout: time.Second})
+		So(err, ShouldBeNil)
+		defer db.Close()
+
+		ctx := context.Background()
+		So(db.Migrate(ctx, &TableModel{
+			Table: "tx_users",
+			Fields: []FieldDefinition{
+				{Name: "id", Type: FieldTypeInt, Required: true},
+			},
+			PrimaryKey: []string{"id"},
+		}), ShouldBeNil)
+		builder := db.GetBuilder()
+
+		Convey("context 取消后自动回滚并释放连接", func() {
+			txCtx, cancel := context.WithCancel(ctx)
+			tx, err := db.BeginTx(txCtx)
+			So(err, ShouldBeNil)
+			So(tx.Create(txCtx, "tx_users", builder.FromMap(map[string]any{"id": 1}, "tx_users")), ShouldBeNil)
+			cancel()
+
+			// 只有一个连接，事务未释放连接时查询会一直阻塞
+			countCtx, countCancel := context.WithTimeout(ctx, time.Second)
+			defer countCancel()
+			count, err := db.Count(countCtx, "tx_users", &query.BoolQuery{})
+			So(err, ShouldBeNil)
+			So(count, ShouldEqual, 0)
+
+			err = tx.Commit()
+			So(errors.Is(err, ErrTxCanceled), ShouldBeTrue)
+			So(tx.Rollback(), ShouldBeNil)
+			So(db.ops.active, ShouldEqual, 0)
+		})
+
+		Convey("提交之后取消 context 不影响事务", func() {
+			txCtx, cancel := context.WithCancel(ctx)
+			tx, err := db.BeginTx(txCtx)
+			So(err, ShouldBeNil)
+			So(tx.Create(txCtx, "tx_users", builder.FromMap(map[string]any{"id": 2}, "tx_users")), ShouldBeNil)
+			So(tx.Commit(), ShouldBeNil)
+			cancel()
+
+			exists, err := db.Exists(ctx, "tx_users", map[string]any{"id": 2})
+			So(err, ShouldBeNil)
+			So(exists, ShouldBeTrue)
+		})
+
+		Convey("WithTx 中 context 超时回滚", func() {
+			txCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+			defer cancel()
+			err := db.WithTx(txCtx, func(tx Transaction) error {
+				if err := tx.Create(txCtx, "tx_users", builder.FromMap(map[string]any{"id": 3}, "tx_users")); err != nil {
+					return err
+				}
+				<-txCtx.Done()
+				return nil
+			})
+			So(errors.Is(err, ErrTxCanceled), ShouldBeTrue)
+			So(errors.Is(err, context.DeadlineExceeded), ShouldBeFalse)
+
+			exists, err := db.Exists(ctx, "tx_users", map[string]any{"id": 3})
+			So(err, ShouldBeNil)
+			So(exists, ShouldBeFalse)
+		})
+	})
+}
+
+func TestTxLeakDetector(t *testing.T) {
+	Convey("测试事务泄漏检测", t, func() {
+		db, err := NewSQLWithOptions(&SQLOptions{Driver: "sqlite3", Database: ":memory:", MaxConns: 2, MaxIdle: 2, TxLeak: &TxLeakOptions{Threshold: 20 * time.Millisecond}})
+		So(err, ShouldBeNil)
+		defer db.Close()
+		So(db.txLeaks, ShouldNotBeNil)
+
+		l := &chanLogger{records: make(chan map[string]any, 10)}
+		db.txLeaks.logger = l
+		ctx := context.Background()
+
+		Convey("超过阈值未结束的事务输出告警和调用栈", func() {
+			tx, err := db.BeginTx(ctx)
+			So(err, ShouldBeNil)
+			defer tx.Rollback()
+
+			select {
+			case record := <-l.records:
+				So(record["msg"], ShouldEqual, "transaction not committed or rolled back")
+				So(record["threshold"], ShouldEqual, 20*time.Millisecond)
+				So(record["stack"], ShouldContainSubstring, "TestTxLeakDetector")
+			case <-time.After(time.Second):
+				t.Fatal("expected leak warning")
+			}
+		})
+
+		Convey("按时结束的事务不告警", func() {
+			tx, err := db.BeginTx(ctx)
+			So(err, ShouldBeNil)
+			So(tx.Commit(), ShouldBeNil)
+
+			select {
+			case record := <-l.records:
+				t.Fatalf("unexpected leak warning: %v", record)
+			case <-time.After(50 * time.Millisecond):
+			}
+		})
+
+		Convey("未配置时不检测", func() {
+			detector, err := newTxLeakDetector(&TxLeakOptions{})
+			So(err, ShouldBeNil)
+			So(detector, ShouldBeNil)
+			So(detector.watch(), ShouldBeNil)
+		})
+	})
+}
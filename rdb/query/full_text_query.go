@@ -0,0 +1,69 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FullTextQuery 全文检索，按分词匹配 Fields 中的任意一个词，并计算相关度评分
+//   - ES：multi_match，Fields 为空时搜索所有字段
+//   - Mongo：$text，搜索集合的文本索引，忽略 Fields
+//   - SQL：MySQL 的 MATCH ... AGAINST 自然语言模式，Fields 需要与 FULLTEXT 索引的列一致；
+//     SQLite 由 database 包改写为对 FTS5 虚拟表的查询，参考 FTS5Match
+//
+// 与 MatchQuery 不同，MatchQuery 在 SQL 和 Mongo 中是模糊匹配，不使用索引，也没有评分
+type FullTextQuery struct {
+	Fields []string `json:"fields,omitempty"`
+	Text   string   `json:"text"`
+}
+
+func (q *FullTextQuery) Type() QueryType {
+	return QueryTypeFullText
+}
+
+func (q *FullTextQuery) ToES() map[string]interface{} {
+	multiMatch := map[string]interface{}{
+		"query": q.Text,
+	}
+	if len(q.Fields) > 0 {
+		multiMatch["fields"] = q.Fields
+	}
+	return map[string]interface{}{
+		"multi_match": multiMatch,
+	}
+}
+
+// ToSQL 返回 MySQL 的全文检索条件，同一个表达式用在 SELECT 中时返回相关度评分
+func (q *FullTextQuery) ToSQL() (string, []interface{}, error) {
+	if len(q.Fields) == 0 {
+		return "", nil, fmt.Errorf("full text query requires fields for sql")
+	}
+	return fmt.Sprintf("MATCH (%s) AGAINST (? IN NATURAL LANGUAGE MODE)", strings.Join(q.Fields, ", ")),
+		[]interface{}{q.Text}, nil
+}
+
+func (q *FullTextQuery) ToMongo() (map[string]interface{}, error) {
+	return map[string]interface{}{
+		"$text": map[string]interface{}{
+			"$search": q.Text,
+		},
+	}, nil
+}
+
+// FTS5Match 返回 SQLite FTS5 的 MATCH 表达式
+// Text 按空白分词，每个词作为短语引用后以 OR 连接，与 MySQL 自然语言模式一样匹配任意一个词，
+// 不会把用户输入中的 AND、NOT、* 等当作 FTS5 语法；Fields 不为空时限定在这些列中搜索
+func (q *FullTextQuery) FTS5Match() (string, error) {
+	words := strings.Fields(q.Text)
+	if len(words) == 0 {
+		return "", fmt.Errorf("full text query has no search terms")
+	}
+	for i, word := range words {
+		words[i] = `"` + strings.ReplaceAll(word, `"`, `""`) + `"`
+	}
+	expr := strings.Join(words, " OR ")
+	if len(q.Fields) == 0 {
+		return expr, nil
+	}
+	return fmt.Sprintf("{%s} : (%s)", strings.Join(q.Fields, " "), expr), nil
+}
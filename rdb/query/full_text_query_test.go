@@ -0,0 +1,58 @@
+package query
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestFullTextQuery(t *testing.T) {
+	Convey("测试 FullTextQuery", t, func() {
+		q := &FullTextQuery{Fields: []string{"title", "body"}, Text: "golang orm"}
+		So(q.Type(), ShouldEqual, QueryTypeFullText)
+
+		Convey("ToES", func() {
+			So(q.ToES(), ShouldResemble, map[string]interface{}{
+				"multi_match": map[string]interface{}{
+					"query":  "golang orm",
+					"fields": []string{"title", "body"},
+				},
+			})
+			So((&FullTextQuery{Text: "golang"}).ToES(), ShouldResemble, map[string]interface{}{
+				"multi_match": map[string]interface{}{"query": "golang"},
+			})
+		})
+
+		Convey("ToSQL", func() {
+			sql, args, err := q.ToSQL()
+			So(err, ShouldBeNil)
+			So(sql, ShouldEqual, "MATCH (title, body) AGAINST (? IN NATURAL LANGUAGE MODE)")
+			So(args, ShouldResemble, []interface{}{"golang orm"})
+
+			_, _, err = (&FullTextQuery{Text: "golang"}).ToSQL()
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("ToMongo", func() {
+			result, err := q.ToMongo()
+			So(err, ShouldBeNil)
+			So(result, ShouldResemble, map[string]interface{}{
+				"$text": map[string]interface{}{"$search": "golang orm"},
+			})
+		})
+
+		Convey("FTS5Match", func() {
+			expr, err := q.FTS5Match()
+			So(err, ShouldBeNil)
+			So(expr, ShouldEqual, `{title body} : ("golang" OR "orm")`)
+
+			// 用户输入中的 FTS5 语法和引号按普通文本处理
+			expr, err = (&FullTextQuery{Text: `say "hi" NOT*`}).FTS5Match()
+			So(err, ShouldBeNil)
+			So(expr, ShouldEqual, `"say" OR """hi""" OR "NOT*"`)
+
+			_, err = (&FullTextQuery{Text: "  "}).FTS5Match()
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,156 @@
+package query
+
+import (
+	"fmt"
+	"math"
+)
+
+// earthRadiusMeters Mongo 计算球面距离使用的地球半径
+const earthRadiusMeters = 6378100.0
+
+// GeoPoint 经纬度坐标，WGS84
+type GeoPoint struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+// GeoJSON 返回 GeoJSON Point，Mongo 的 2dsphere 索引要求位置字段以 GeoJSON 存储
+// ES 的 geo_point 字段直接存储 GeoPoint 即可
+func (p GeoPoint) GeoJSON() map[string]interface{} {
+	return map[string]interface{}{
+		"type":        "Point",
+		"coordinates": []interface{}{p.Lon, p.Lat},
+	}
+}
+
+// WKT 返回 EWKT 格式的点，用于写入 PostGIS 的 geography 列
+func (p GeoPoint) WKT() string {
+	return fmt.Sprintf("SRID=4326;POINT(%g %g)", p.Lon, p.Lat)
+}
+
+// GeoDistanceQuery 距离查询，匹配与 Center 的球面距离不超过 Distance 米的位置
+//   - ES：geo_distance，字段需要映射为 geo_point
+//   - Mongo：$geoWithin + $centerSphere，可以用于 Count 和 BoolQuery；不使用 $near，结果不按距离排序
+//   - SQL：PostGIS 的 ST_DWithin，仅支持 postgres
+type GeoDistanceQuery struct {
+	Field    string   `json:"field"`
+	Center   GeoPoint `json:"center"`
+	Distance float64  `json:"distance"` // 单位为米
+}
+
+func (q *GeoDistanceQuery) Type() QueryType {
+	return QueryTypeGeoDistance
+}
+
+func (q *GeoDistanceQuery) ToES() map[string]interface{} {
+	return map[string]interface{}{
+		"geo_distance": map[string]interface{}{
+			"distance": fmt.Sprintf("%gm", q.Distance),
+			q.Field: map[string]interface{}{
+				"lat": q.Center.Lat,
+				"lon": q.Center.Lon,
+			},
+		},
+	}
+}
+
+func (q *GeoDistanceQuery) ToSQL() (string, []interface{}, error) {
+	if q.Distance < 0 {
+		return "", nil, fmt.Errorf("geo distance must not be negative, got %g", q.Distance)
+	}
+	return fmt.Sprintf("ST_DWithin(%s::geography, ST_SetSRID(ST_MakePoint(?, ?), 4326)::geography, ?)", q.Field),
+		[]interface{}{q.Center.Lon, q.Center.Lat, q.Distance}, nil
+}
+
+func (q *GeoDistanceQuery) ToMongo() (map[string]interface{}, error) {
+	if q.Distance < 0 {
+		return nil, fmt.Errorf("geo distance must not be negative, got %g", q.Distance)
+	}
+	return map[string]interface{}{
+		q.Field: map[string]interface{}{
+			"$geoWithin": map[string]interface{}{
+				"$centerSphere": []interface{}{
+					[]interface{}{q.Center.Lon, q.Center.Lat},
+					q.Distance / earthRadiusMeters,
+				},
+			},
+		},
+	}, nil
+}
+
+// GeoBoundingBoxQuery 矩形范围查询，匹配位于 TopLeft 和 BottomRight 围成的经纬度矩形内的位置
+//   - ES：geo_bounding_box，字段需要映射为 geo_point
+//   - Mongo：$geoWithin + 矩形 Polygon，2dsphere 下多边形的边为大圆弧，跨度很大的矩形边界与经纬线略有偏差
+//   - SQL：PostGIS 的 ST_Intersects 和 ST_MakeEnvelope，仅支持 postgres
+//
+// 不支持跨越 180 度经线的矩形
+type GeoBoundingBoxQuery struct {
+	Field       string   `json:"field"`
+	TopLeft     GeoPoint `json:"topLeft"`
+	BottomRight GeoPoint `json:"bottomRight"`
+}
+
+func (q *GeoBoundingBoxQuery) Type() QueryType {
+	return QueryTypeGeoBoundingBox
+}
+
+func (q *GeoBoundingBoxQuery) ToES() map[string]interface{} {
+	return map[string]interface{}{
+		"geo_bounding_box": map[string]interface{}{
+			q.Field: map[string]interface{}{
+				"top_left": map[string]interface{}{
+					"lat": q.TopLeft.Lat,
+					"lon": q.TopLeft.Lon,
+				},
+				"bottom_right": map[string]interface{}{
+					"lat": q.BottomRight.Lat,
+					"lon": q.BottomRight.Lon,
+				},
+			},
+		},
+	}
+}
+
+func (q *GeoBoundingBoxQuery) ToSQL() (string, []interface{}, error) {
+	if err := q.validate(); err != nil {
+		return "", nil, err
+	}
+	return fmt.Sprintf("ST_Intersects(%s::geometry, ST_MakeEnvelope(?, ?, ?, ?, 4326))", q.Field),
+		[]interface{}{q.TopLeft.Lon, q.BottomRight.Lat, q.BottomRight.Lon, q.TopLeft.Lat}, nil
+}
+
+func (q *GeoBoundingBoxQuery) ToMongo() (map[string]interface{}, error) {
+	if err := q.validate(); err != nil {
+		return nil, err
+	}
+	minLon, minLat, maxLon, maxLat := q.TopLeft.Lon, q.BottomRight.Lat, q.BottomRight.Lon, q.TopLeft.Lat
+	return map[string]interface{}{
+		q.Field: map[string]interface{}{
+			"$geoWithin": map[string]interface{}{
+				"$geometry": map[string]interface{}{
+					"type": "Polygon",
+					"coordinates": []interface{}{
+						[]interface{}{
+							[]interface{}{minLon, minLat},
+							[]interface{}{maxLon, minLat},
+							[]interface{}{maxLon, maxLat},
+							[]interface{}{minLon, maxLat},
+							[]interface{}{minLon, minLat},
+						},
+					},
+				},
+			},
+		},
+	}, nil
+}
+
+// validate 左上角需要在右下角的西北方向
+func (q *GeoBoundingBoxQuery) validate() error {
+	if math.IsNaN(q.TopLeft.Lat) || math.IsNaN(q.TopLeft.Lon) || math.IsNaN(q.BottomRight.Lat) || math.IsNaN(q.BottomRight.Lon) {
+		return fmt.Errorf("geo bounding box contains NaN")
+	}
+	if q.TopLeft.Lat < q.BottomRight.Lat || q.TopLeft.Lon > q.BottomRight.Lon {
+		return fmt.Errorf("geo bounding box top left %v must be north west of bottom right %v", q.TopLeft, q.BottomRight)
+	}
+	return nil
+}
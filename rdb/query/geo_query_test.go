@@ -0,0 +1,119 @@
+package query
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestGeoDistanceQuery(t *testing.T) {
+	Convey("测试 GeoDistanceQuery", t, func() {
+		q := &GeoDistanceQuery{Field: "location", Center: GeoPoint{Lat: 31.23, Lon: 121.47}, Distance: 5000}
+		So(q.Type(), ShouldEqual, QueryTypeGeoDistance)
+
+		Convey("ToES", func() {
+			So(q.ToES(), ShouldResemble, map[string]interface{}{
+				"geo_distance": map[string]interface{}{
+					"distance": "5000m",
+					"location": map[string]interface{}{"lat": 31.23, "lon": 121.47},
+				},
+			})
+		})
+
+		Convey("ToSQL", func() {
+			sql, args, err := q.ToSQL()
+			So(err, ShouldBeNil)
+			So(sql, ShouldEqual, "ST_DWithin(location::geography, ST_SetSRID(ST_MakePoint(?, ?), 4326)::geography, ?)")
+			So(args, ShouldResemble, []interface{}{121.47, 31.23, 5000.0})
+		})
+
+		Convey("ToMongo", func() {
+			result, err := q.ToMongo()
+			So(err, ShouldBeNil)
+			So(result, ShouldResemble, map[string]interface{}{
+				"location": map[string]interface{}{
+					"$geoWithin": map[string]interface{}{
+						"$centerSphere": []interface{}{[]interface{}{121.47, 31.23}, 5000 / earthRadiusMeters},
+					},
+				},
+			})
+		})
+
+		Convey("距离为负数", func() {
+			q.Distance = -1
+			_, _, err := q.ToSQL()
+			So(err, ShouldNotBeNil)
+			_, err = q.ToMongo()
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestGeoBoundingBoxQuery(t *testing.T) {
+	Convey("测试 GeoBoundingBoxQuery", t, func() {
+		q := &GeoBoundingBoxQuery{
+			Field:       "location",
+			TopLeft:     GeoPoint{Lat: 40, Lon: 116},
+			BottomRight: GeoPoint{Lat: 39, Lon: 117},
+		}
+		So(q.Type(), ShouldEqual, QueryTypeGeoBoundingBox)
+
+		Convey("ToES", func() {
+			So(q.ToES(), ShouldResemble, map[string]interface{}{
+				"geo_bounding_box": map[string]interface{}{
+					"location": map[string]interface{}{
+						"top_left":     map[string]interface{}{"lat": 40.0, "lon": 116.0},
+						"bottom_right": map[string]interface{}{"lat": 39.0, "lon": 117.0},
+					},
+				},
+			})
+		})
+
+		Convey("ToSQL", func() {
+			sql, args, err := q.ToSQL()
+			So(err, ShouldBeNil)
+			So(sql, ShouldEqual, "ST_Intersects(location::geometry, ST_MakeEnvelope(?, ?, ?, ?, 4326))")
+			So(args, ShouldResemble, []interface{}{116.0, 39.0, 117.0, 40.0})
+		})
+
+		Convey("ToMongo", func() {
+			result, err := q.ToMongo()
+			So(err, ShouldBeNil)
+			So(result, ShouldResemble, map[string]interface{}{
+				"location": map[string]interface{}{
+					"$geoWithin": map[string]interface{}{
+						"$geometry": map[string]interface{}{
+							"type": "Polygon",
+							"coordinates": []interface{}{[]interface{}{
+								[]interface{}{116.0, 39.0},
+								[]interface{}{117.0, 39.0},
+								[]interface{}{117.0, 40.0},
+								[]interface{}{116.0, 40.0},
+								[]interface{}{116.0, 39.0},
+							}},
+						},
+					},
+				},
+			})
+		})
+
+		Convey("左上角不在右下角的西北方向", func() {
+			q.TopLeft, q.BottomRight = q.BottomRight, q.TopLeft
+			_, _, err := q.ToSQL()
+			So(err, ShouldNotBeNil)
+			_, err = q.ToMongo()
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestGeoPoint(t *testing.T) {
+	Convey("测试 GeoPoint 存储格式", t, func() {
+		p := GeoPoint{Lat: 31.23, Lon: 121.47}
+		So(p.GeoJSON(), ShouldResemble, map[string]interface{}{
+			"type":        "Point",
+			"coordinates": []interface{}{121.47, 31.23},
+		})
+		So(p.WKT(), ShouldEqual, "SRID=4326;POINT(121.47 31.23)")
+	})
+}
@@ -12,6 +12,12 @@ const (
 	QueryTypeWildcard QueryType = "wildcard"
 	QueryTypePrefix   QueryType = "prefix"
 	QueryTypeRegexp   QueryType = "regexp"
+	QueryTypeRaw      QueryType = "raw"
+
+	QueryTypeGeoDistance    QueryType = "geo_distance"
+	QueryTypeGeoBoundingBox QueryType = "geo_bounding_box"
+
+	QueryTypeFullText QueryType = "full_text"
 )
 
 // Query 查询节点接口
@@ -16,5 +16,6 @@ func TestQueryType(t *testing.T) {
 		So(QueryTypeWildcard, ShouldEqual, QueryType("wildcard"))
 		So(QueryTypePrefix, ShouldEqual, QueryType("prefix"))
 		So(QueryTypeRegexp, ShouldEqual, QueryType("regexp"))
+		So(QueryTypeRaw, ShouldEqual, QueryType("raw"))
 	})
 }
\ No newline at end of file
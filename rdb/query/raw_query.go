@@ -0,0 +1,94 @@
+package query
+
+import (
+	"fmt"
+)
+
+// RawQuery 原生查询，作为查询 DSL 无法表达时的逃生通道
+// 各后端分别使用对应的原生表达式：
+//   - SQL：带 ? 占位符的条件表达式，参数通过 Args 绑定，不做字符串拼接
+//   - Mongo：原生 bson 过滤条件
+//   - ES：原生查询 DSL
+//
+// 未提供对应后端表达式时，ToSQL/ToMongo 返回错误；ToES 没有错误返回值，返回 match_none 查询，
+// ES 后端在执行前通过 ValidateES 返回错误，不会静默地返回空结果
+type RawQuery struct {
+	SQL   string                 `json:"sql,omitempty"`
+	Args  []interface{}          `json:"args,omitempty"`
+	Mongo map[string]interface{} `json:"mongo,omitempty"`
+	ES    map[string]interface{} `json:"es,omitempty"`
+}
+
+// Raw 创建原生 SQL 查询，参数通过占位符绑定
+// 例如 Raw("age > ? AND json_extract(data, '$.x') = ?", 18, "y")
+func Raw(sql string, args ...interface{}) *RawQuery {
+	return &RawQuery{SQL: sql, Args: args}
+}
+
+// RawMongo 创建原生 Mongo 查询
+func RawMongo(filter map[string]interface{}) *RawQuery {
+	return &RawQuery{Mongo: filter}
+}
+
+// RawES 创建原生 ES 查询
+func RawES(dsl map[string]interface{}) *RawQuery {
+	return &RawQuery{ES: dsl}
+}
+
+// WithMongo 设置 Mongo 后端使用的原生过滤条件
+func (q *RawQuery) WithMongo(filter map[string]interface{}) *RawQuery {
+	q.Mongo = filter
+	return q
+}
+
+// WithES 设置 ES 后端使用的原生查询 DSL
+func (q *RawQuery) WithES(dsl map[string]interface{}) *RawQuery {
+	q.ES = dsl
+	return q
+}
+
+func (q *RawQuery) Type() QueryType {
+	return QueryTypeRaw
+}
+
+func (q *RawQuery) ToES() map[string]interface{} {
+	if q.ES == nil {
+		return map[string]interface{}{
+			"match_none": map[string]interface{}{},
+		}
+	}
+	return q.ES
+}
+
+func (q *RawQuery) ToSQL() (string, []interface{}, error) {
+	if q.SQL == "" {
+		return "", nil, fmt.Errorf("raw query has no sql expression")
+	}
+	return "(" + q.SQL + ")", q.Args, nil
+}
+
+func (q *RawQuery) ToMongo() (map[string]interface{}, error) {
+	if q.Mongo == nil {
+		return nil, fmt.Errorf("raw query has no mongo filter")
+	}
+	return q.Mongo, nil
+}
+
+// ValidateES 校验查询可以转换为 ES 查询，递归检查 BoolQuery 中未提供 ES 表达式的 RawQuery
+func ValidateES(q Query) error {
+	switch v := q.(type) {
+	case *RawQuery:
+		if v.ES == nil {
+			return fmt.Errorf("raw query has no es expression")
+		}
+	case *BoolQuery:
+		for _, group := range [][]Query{v.Must, v.Should, v.MustNot, v.Filter} {
+			for _, sub := range group {
+				if err := ValidateES(sub); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package query
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRawQueryType(t *testing.T) {
+	Convey("测试 RawQuery Type 方法", t, func() {
+		q := Raw("age > ?", 18)
+		So(q.Type(), ShouldEqual, QueryTypeRaw)
+	})
+}
+
+func TestRawQueryToSQL(t *testing.T) {
+	Convey("测试 RawQuery ToSQL 方法", t, func() {
+		Convey("带参数绑定的表达式", func() {
+			q := Raw("age > ? AND json_extract(data, '$.x') = ?", 18, "y")
+			sql, args, err := q.ToSQL()
+			So(err, ShouldBeNil)
+			So(sql, ShouldEqual, "(age > ? AND json_extract(data, '$.x') = ?)")
+			So(args, ShouldResemble, []interface{}{18, "y"})
+		})
+
+		Convey("无参数表达式", func() {
+			sql, args, err := Raw("deleted_at IS NULL").ToSQL()
+			So(err, ShouldBeNil)
+			So(sql, ShouldEqual, "(deleted_at IS NULL)")
+			So(args, ShouldBeEmpty)
+		})
+
+		Convey("未提供 SQL 表达式", func() {
+			_, _, err := RawMongo(map[string]interface{}{"age": 18}).ToSQL()
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("嵌套在 BoolQuery 中", func() {
+			q := &BoolQuery{
+				Must: []Query{
+					&TermQuery{Field: "status", Value: "active"},
+					Raw("age BETWEEN ? AND ?", 18, 30),
+				},
+			}
+			sql, args, err := q.ToSQL()
+			So(err, ShouldBeNil)
+			So(sql, ShouldEqual, "(status = ? AND (age BETWEEN ? AND ?))")
+			So(args, ShouldResemble, []interface{}{"active", 18, 30})
+		})
+	})
+}
+
+func TestRawQueryToMongo(t *testing.T) {
+	Convey("测试 RawQuery ToMongo 方法", t, func() {
+		Convey("原生过滤条件", func() {
+			filter := map[string]interface{}{
+				"tags": map[string]interface{}{"$size": 2},
+			}
+			result, err := RawMongo(filter).ToMongo()
+			So(err, ShouldBeNil)
+			So(result, ShouldResemble, filter)
+		})
+
+		Convey("链式设置多个后端", func() {
+			filter := map[string]interface{}{"age": map[string]interface{}{"$gt": 18}}
+			q := Raw("age > ?", 18).WithMongo(filter)
+			result, err := q.ToMongo()
+			So(err, ShouldBeNil)
+			So(result, ShouldResemble, filter)
+		})
+
+		Convey("未提供 Mongo 过滤条件", func() {
+			_, err := Raw("age > ?", 18).ToMongo()
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestRawQueryToES(t *testing.T) {
+	Convey("测试 RawQuery ToES 方法", t, func() {
+		Convey("原生 DSL", func() {
+			dsl := map[string]interface{}{
+				"script": map[string]interface{}{
+					"script": "doc['age'].value > 18",
+				},
+			}
+			So(RawES(dsl).ToES(), ShouldResemble, dsl)
+			So(Raw("age > ?", 18).WithES(dsl).ToES(), ShouldResemble, dsl)
+		})
+
+		Convey("未提供 ES DSL 时不匹配任何文档", func() {
+			So(Raw("age > ?", 18).ToES(), ShouldResemble, map[string]interface{}{
+				"match_none": map[string]interface{}{},
+			})
+		})
+	})
+}
+
+func TestValidateES(t *testing.T) {
+	Convey("测试 ValidateES", t, func() {
+		dsl := map[string]interface{}{"match_all": map[string]interface{}{}}
+		So(ValidateES(&TermQuery{Field: "status", Value: "active"}), ShouldBeNil)
+		So(ValidateES(RawES(dsl)), ShouldBeNil)
+		So(ValidateES(Raw("age > ?", 18)), ShouldNotBeNil)
+		So(ValidateES(&BoolQuery{Filter: []Query{&TermQuery{Field: "status", Value: "active"}, Raw("age > ?", 18)}}), ShouldNotBeNil)
+		So(ValidateES(&BoolQuery{Must: []Query{Raw("age > ?", 18).WithES(dsl)}}), ShouldBeNil)
+	})
+}
@@ -11,7 +11,19 @@ func NewDatabaseWithOptions(options *ref.TypeOptions) (database.Database, error)
 	return database.NewDatabaseWithOptions(options)
 }
 
+// Repository 泛型仓库，在 Database 之上按实体类型读写记录，Record 与实体之间的转换由仓库完成
+type Repository[T any] = repository.Repository[T]
+
 // NewRepository 创建新的 Repository 实例
-func NewRepository[T any](db database.Database) (repository.Repository[T], error) {
+// 表名默认为结构体名称，实体实现 Table() string 方法时使用该方法返回的表名
+func NewRepository[T any](db database.Database) (Repository[T], error) {
 	return repository.NewRepository[T](db)
-}
\ No newline at end of file
+}
+// FieldChange 记录中一个字段的变化
+type FieldChange = database.FieldChange
+
+// DiffRecords 比较两条记录，返回值不同的字段及其前后的值，按字段名排序
+// before 为 nil 时所有字段视为新增，after 为 nil 时视为删除
+func DiffRecords(before, after database.Record) []FieldChange {
+	return database.DiffRecords(before, after)
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"encoding"
 	"fmt"
 	"reflect"
 	"strings"
@@ -19,8 +20,13 @@ type Repository[T any] interface {
 	Create(ctx context.Context, entity *T, opts ...database.CreateOption) error
 	Get(ctx context.Context, id any) (*T, error)
 	Update(ctx context.Context, entity *T) error
+	Upsert(ctx context.Context, entity *T) error
 	Delete(ctx context.Context, id any) error
 
+	// 部分更新
+	UpdatePartial(ctx context.Context, id any, fields map[string]any) error
+	Increment(ctx context.Context, id any, field string, delta any) error
+
 	// 查询操作
 	Find(ctx context.Context, q query.Query, opts ...database.QueryOption) ([]*T, error)
 	FindOne(ctx context.Context, q query.Query) (*T, error)
@@ -35,9 +41,10 @@ type Repository[T any] interface {
 
 // repositoryImpl Repository 接口的实现
 type repositoryImpl[T any] struct {
-	db    database.Database
-	table string
-	model *database.TableModel
+	db      database.Database
+	table   string
+	model   *database.TableModel
+	tagName string // 与数据库的 RecordBuilder 使用同一个标签
 }
 
 // NewRepository 创建新的 Repository 实例
@@ -45,16 +52,19 @@ func NewRepository[T any](db database.Database) (Repository[T], error) {
 	var zero T
 
 	// 使用 TableModelBuilder 从结构体构建模型
-	builder := database.NewTableModelBuilder()
-	model, err := builder.FromStruct(zero)
+	// 传入指针，值接收者和指针接收者实现的 Table() 方法都可以用于自定义表名
+	tagName := database.BuilderTagName(db.GetBuilder())
+	builder := database.NewTableModelBuilderWithTag(tagName)
+	model, err := builder.FromStruct(&zero)
 	if err != nil {
 		return nil, fmt.Errorf("failed to build table model: %w", err)
 	}
 
 	repo := &repositoryImpl[T]{
-		db:    db,
-		table: model.Table,
-		model: model,
+		db:      db,
+		table:   model.Table,
+		model:   model,
+		tagName: tagName,
 	}
 
 	return repo, nil
@@ -67,6 +77,9 @@ func (r *repositoryImpl[T]) Migrate(ctx context.Context) error {
 
 // Create 创建记录
 func (r *repositoryImpl[T]) Create(ctx context.Context, entity *T, opts ...database.CreateOption) error {
+	if err := r.fillID(entity); err != nil {
+		return err
+	}
 	builder := r.db.GetBuilder()
 	record := builder.FromStruct(entity)
 	return r.db.Create(ctx, r.table, record, opts...)
@@ -98,7 +111,61 @@ func (r *repositoryImpl[T]) Update(ctx context.Context, entity *T) error {
 
 	builder := r.db.GetBuilder()
 	record := builder.FromStruct(entity)
-	return r.db.Update(ctx, r.table, pk, record)
+	if r.model.Version == "" {
+		return r.db.Update(ctx, r.table, pk, record)
+	}
+
+	// 乐观锁：以实体中的版本作为期望版本，更新成功后同步递增实体的版本
+	version, ok := r.fieldValue(entity, r.model.Version)
+	if !ok {
+		return fmt.Errorf("version field %s not found in entity", r.model.Version)
+	}
+	if err := r.db.Update(ctx, r.table, pk, record, database.WithVersion(r.model.Version, version.Interface())); err != nil {
+		return err
+	}
+	switch version.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		version.SetInt(version.Int() + 1)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		version.SetUint(version.Uint() + 1)
+	}
+	return nil
+}
+
+// Upsert 根据实体的主键插入或更新记录，主键为空且配置了主键生成策略时先生成主键
+func (r *repositoryImpl[T]) Upsert(ctx context.Context, entity *T) error {
+	if err := r.fillID(entity); err != nil {
+		return err
+	}
+	pk := r.extractPrimaryKey(entity)
+	if len(pk) == 0 {
+		return fmt.Errorf("primary key not found in entity")
+	}
+
+	builder := r.db.GetBuilder()
+	record := builder.FromStruct(entity)
+	return r.db.Upsert(ctx, r.table, pk, record)
+}
+
+// UpdatePartial 根据主键只更新指定字段
+// 实体声明了版本字段时，fields 中必须包含期望的当前版本
+func (r *repositoryImpl[T]) UpdatePartial(ctx context.Context, id any, fields map[string]any) error {
+	pk := r.buildPrimaryKey(id)
+	if r.model.Version == "" {
+		return r.db.UpdatePartial(ctx, r.table, pk, fields)
+	}
+
+	version, ok := fields[r.model.Version]
+	if !ok {
+		return fmt.Errorf("version field %s is required", r.model.Version)
+	}
+	return r.db.UpdatePartial(ctx, r.table, pk, fields, database.WithVersion(r.model.Version, version))
+}
+
+// Increment 根据主键对数值字段做原子增减
+func (r *repositoryImpl[T]) Increment(ctx context.Context, id any, field string, delta any) error {
+	pk := r.buildPrimaryKey(id)
+	return r.db.Increment(ctx, r.table, pk, field, delta)
 }
 
 // Delete 根据主键删除记录
@@ -148,13 +215,7 @@ func (r *repositoryImpl[T]) FindOne(ctx context.Context, q query.Query) (*T, err
 
 // Count 统计记录数量
 func (r *repositoryImpl[T]) Count(ctx context.Context, q query.Query) (int64, error) {
-	// 这里需要使用聚合查询来统计数量
-	// 简化实现：先查询所有记录然后计数
-	records, err := r.db.Find(ctx, r.table, q)
-	if err != nil {
-		return 0, err
-	}
-	return int64(len(records)), nil
+	return r.db.Count(ctx, r.table, q)
 }
 
 // Exists 检查记录是否存在
@@ -172,6 +233,9 @@ func (r *repositoryImpl[T]) BatchCreate(ctx context.Context, entities []*T, opts
 	var records []database.Record
 
 	for _, entity := range entities {
+		if err := r.fillID(entity); err != nil {
+			return err
+		}
 		record := builder.FromStruct(entity)
 		records = append(records, record)
 	}
@@ -261,9 +325,49 @@ func (r *repositoryImpl[T]) extractPrimaryKey(entity *T) map[string]any {
 	return pk
 }
 
+// fillID 表模型配置了主键生成策略且实体主键为空时生成主键，写回实体的主键字段
+func (r *repositoryImpl[T]) fillID(entity *T) error {
+	if r.model.IDStrategy == "" {
+		return nil
+	}
+	field, ok := r.fieldValue(entity, r.model.PrimaryKey[0])
+	if !ok || !field.IsZero() {
+		return nil
+	}
+
+	id, err := r.model.IDStrategy.Generate()
+	if err != nil {
+		return err
+	}
+	if field.Kind() == reflect.String {
+		field.SetString(id)
+		return nil
+	}
+	// uuid.UUID 等实现了 encoding.TextUnmarshaler 的主键类型
+	if u, ok := field.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		if err := u.UnmarshalText([]byte(id)); err != nil {
+			return fmt.Errorf("failed to set generated id %s: %w", id, err)
+		}
+		return nil
+	}
+	return fmt.Errorf("cannot set generated id to field of type %s", field.Type())
+}
+
+// fieldValue 根据列名查找实体中对应的字段
+func (r *repositoryImpl[T]) fieldValue(entity *T, name string) (reflect.Value, bool) {
+	rv := reflect.ValueOf(entity).Elem()
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		if r.getFieldName(rt.Field(i)) == name {
+			return rv.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
 // getFieldName 获取字段的数据库列名
 func (r *repositoryImpl[T]) getFieldName(field reflect.StructField) string {
-	tag := field.Tag.Get("rdb")
+	tag := field.Tag.Get(r.tagName)
 	if tag == "" || tag == "-" {
 		return field.Name
 	}
@@ -5,6 +5,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/hatlonely/gox/rdb/database"
 	"github.com/hatlonely/gox/rdb/query"
 	. "github.com/smartystreets/goconvey/convey"
@@ -581,6 +582,91 @@ func TestRepositoryTableName(t *testing.T) {
 	})
 }
 
+// 指针接收者实现 Table 方法的实体
+type Order struct {
+	ID     int    `rdb:"id,primary"`
+	Status string `rdb:"status"`
+}
+
+func (o *Order) Table() string {
+	return "orders"
+}
+
+func TestRepositoryPointerReceiverTable(t *testing.T) {
+	Convey("测试指针接收者实现的 Table 方法", t, func() {
+		db, err := database.NewSQLWithOptions(&database.SQLOptions{Driver: "sqlite3", Database: ":memory:", MaxConns: 1, MaxIdle: 1})
+		So(err, ShouldBeNil)
+		defer db.Close()
+
+		repo, err := NewRepository[Order](db)
+		So(err, ShouldBeNil)
+		So(repo.(*repositoryImpl[Order]).table, ShouldEqual, "orders")
+
+		ctx := context.Background()
+		So(repo.Migrate(ctx), ShouldBeNil)
+		So(repo.Create(ctx, &Order{ID: 1, Status: "paid"}), ShouldBeNil)
+
+		exists, err := db.Exists(ctx, "orders", map[string]any{"id": 1})
+		So(err, ShouldBeNil)
+		So(exists, ShouldBeTrue)
+
+		order, err := repo.Get(ctx, 1)
+		So(err, ShouldBeNil)
+		So(order.Status, ShouldEqual, "paid")
+	})
+}
+
+// Session 主键按 uuidv7 自动生成的会话
+type Session struct {
+	ID     string `rdb:"id,primary,idgen=uuidv7"`
+	UserID int    `rdb:"user_id"`
+}
+
+// Device 主键类型为 uuid.UUID 的设备
+type Device struct {
+	ID   uuid.UUID `rdb:"id,primary,idgen=uuidv4"`
+	Name string    `rdb:"name"`
+}
+
+func TestRepositoryIDStrategy(t *testing.T) {
+	Convey("测试主键生成策略", t, func() {
+		db, err := database.NewSQLWithOptions(&database.SQLOptions{Driver: "sqlite3", Database: ":memory:", MaxConns: 1, MaxIdle: 1})
+		So(err, ShouldBeNil)
+		defer db.Close()
+		ctx := context.Background()
+		Convey("主键为空时生成并写回实体", func() {
+			repo, err := NewRepository[Session](db)
+			So(err, ShouldBeNil)
+			So(repo.Migrate(ctx), ShouldBeNil)
+
+			session := &Session{UserID: 1}
+			So(repo.Create(ctx, session), ShouldBeNil)
+			So(session.ID, ShouldHaveLength, 36)
+
+			got, err := repo.Get(ctx, session.ID)
+			So(err, ShouldBeNil)
+			So(got.UserID, ShouldEqual, 1)
+
+			sessions := []*Session{{UserID: 2}, {ID: "custom", UserID: 3}}
+			So(repo.BatchCreate(ctx, sessions), ShouldBeNil)
+			So(sessions[0].ID, ShouldBeGreaterThan, session.ID)
+			So(sessions[1].ID, ShouldEqual, "custom")
+		})
+
+		Convey("uuid.UUID 类型的主键", func() {
+			repo, err := NewRepository[Device](db)
+			So(err, ShouldBeNil)
+			So(repo.Migrate(ctx), ShouldBeNil)
+			device := &Device{Name: "phone"}
+			So(repo.Create(ctx, device), ShouldBeNil)
+			So(device.ID, ShouldNotEqual, uuid.Nil)
+			got, err := repo.Get(ctx, device.ID.String())
+			So(err, ShouldBeNil)
+			So(got.ID, ShouldEqual, device.ID)
+		})
+	})
+}
+
 func TestRepositoryCompositeKey(t *testing.T) {
 	Convey("测试复合主键 Repository", t, func() {
 		db, err := database.NewSQLWithOptions(testMySQLOptions)
@@ -647,4 +733,49 @@ func TestRepositoryCompositeKey(t *testing.T) {
 			So(err, ShouldEqual, database.ErrRecordNotFound)
 		})
 	})
-}
\ No newline at end of file
+}
+// 乐观锁测试实体
+type Account struct {
+	ID      int    `rdb:"id,primary"`
+	Owner   string `rdb:"owner"`
+	Version int    `rdb:"version,version"`
+}
+
+func TestRepositoryOptimisticLock(t *testing.T) {
+	Convey("测试 Repository 乐观锁", t, func() {
+		db, err := database.NewSQLWithOptions(testMySQLOptions)
+		So(err, ShouldBeNil)
+		defer db.Close()
+
+		repo, err := NewRepository[Account](db)
+		So(err, ShouldBeNil)
+
+		ctx := context.Background()
+		So(repo.Migrate(ctx), ShouldBeNil)
+		defer db.DropTable(ctx, "Account")
+
+		So(repo.Create(ctx, &Account{ID: 1, Owner: "alice"}), ShouldBeNil)
+
+		// 两个调用方读取同一版本
+		a, err := repo.Get(ctx, 1)
+		So(err, ShouldBeNil)
+		b, err := repo.Get(ctx, 1)
+		So(err, ShouldBeNil)
+
+		a.Owner = "bob"
+		So(repo.Update(ctx, a), ShouldBeNil)
+		So(a.Version, ShouldEqual, 1)
+
+		b.Owner = "carol"
+		So(repo.Update(ctx, b), ShouldEqual, database.ErrVersionConflict)
+		So(b.Version, ShouldEqual, 0)
+
+		So(repo.UpdatePartial(ctx, 1, map[string]any{"owner": "dave"}), ShouldNotBeNil)
+		So(repo.UpdatePartial(ctx, 1, map[string]any{"owner": "dave", "version": 1}), ShouldBeNil)
+
+		account, err := repo.Get(ctx, 1)
+		So(err, ShouldBeNil)
+		So(account.Owner, ShouldEqual, "dave")
+		So(account.Version, ShouldEqual, 2)
+	})
+}
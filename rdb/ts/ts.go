@@ -0,0 +1,390 @@
+package ts
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hatlonely/gox/rdb/aggregation"
+	"github.com/hatlonely/gox/rdb/database"
+	"github.com/hatlonely/gox/rdb/query"
+)
+
+// 数据点存储字段
+const (
+	fieldSeries = "series"
+	fieldTags   = "tags"
+	fieldBucket = "bucket"
+	fieldTime   = "ts"
+	fieldValue  = "value"
+)
+
+// Point 时间序列数据点
+type Point struct {
+	Series string
+	Tags   map[string]string
+	Time   time.Time
+	Value  float64
+}
+
+// StoreOptions 时间序列存储选项
+type StoreOptions struct {
+	Table      string        `cfg:"table" def:"ts_points"`
+	BucketSize time.Duration `cfg:"bucketSize" def:"1h"` // 分桶粒度，数据点按所在桶的起始时间建立索引
+}
+
+// Store 基于 Database 的轻量时间序列存储
+//
+// 每个数据点存为一条记录：series、tags、bucket、ts、value，
+// 其中 tags 为排序后的 k=v 串（键值中的 \、, 和 = 转义），bucket 为数据点所在桶的起始时间，
+// (series, tags, bucket) 上建立索引，范围查询先按桶裁剪再按时间过滤。
+// 同一序列同一时间的数据点重复写入时覆盖旧值
+type Store struct {
+	db         database.Database
+	table      string
+	bucketSize time.Duration
+	layout     layout
+}
+
+// layout 各后端的存储布局差异
+type layout struct {
+	idField      string              // 数据点主键字段，SQL 使用 id 列，Mongo 和 ES 使用文档 _id
+	keywordField func(string) string // 字符串精确匹配使用的字段名，ES 使用 keyword 子字段
+	timeValue    func(time.Time) any // 时间字段的存储值，ES 映射只接受 epoch_millis 等格式
+}
+
+// newLayout 按底层数据库选择存储布局，CachingDatabase 等包装通过 database.Unwrap 解开后判断
+func newLayout(db database.Database) layout {
+	identity := func(field string) string { return field }
+	utc := func(t time.Time) any { return t.UTC() }
+
+	switch database.Unwrap(db).(type) {
+	case *database.Mongo, *database.MongoTransaction:
+		return layout{idField: "_id", keywordField: identity, timeValue: utc}
+	case *database.ES, *database.ESTransaction:
+		return layout{
+			idField:      "_id",
+			keywordField: func(field string) string { return field + ".keyword" },
+			timeValue:    func(t time.Time) any { return t.UnixMilli() },
+		}
+	default:
+		return layout{idField: "id", keywordField: identity, timeValue: utc}
+	}
+}
+
+// NewStore 创建时间序列存储
+func NewStore(db database.Database, options *StoreOptions) (*Store, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database is required")
+	}
+	if options == nil {
+		options = &StoreOptions{}
+	}
+
+	table := options.Table
+	if table == "" {
+		table = "ts_points"
+	}
+	bucketSize := options.BucketSize
+	if bucketSize <= 0 {
+		bucketSize = time.Hour
+	}
+
+	return &Store{
+		db:         db,
+		table:      table,
+		bucketSize: bucketSize,
+		layout:     newLayout(db),
+	}, nil
+}
+
+// Migrate 创建数据点表和 (series, tags, bucket) 索引
+func (s *Store) Migrate(ctx context.Context) error {
+	var fields []database.FieldDefinition
+	var primaryKey []string
+	if s.layout.idField == "id" {
+		fields = append(fields, database.FieldDefinition{Name: "id", Type: database.FieldTypeString, Size: 255, Required: true})
+		primaryKey = []string{"id"}
+	}
+	fields = append(fields,
+		database.FieldDefinition{Name: fieldSeries, Type: database.FieldTypeString, Size: 128, Required: true},
+		database.FieldDefinition{Name: fieldTags, Type: database.FieldTypeString, Size: 255},
+		database.FieldDefinition{Name: fieldBucket, Type: database.FieldTypeDate, Required: true},
+		database.FieldDefinition{Name: fieldTime, Type: database.FieldTypeDate, Required: true},
+		database.FieldDefinition{Name: fieldValue, Type: database.FieldTypeFloat},
+	)
+
+	return s.db.Migrate(ctx, &database.TableModel{
+		Table:      s.table,
+		Fields:     fields,
+		PrimaryKey: primaryKey,
+		Indexes: []database.IndexDefinition{
+			{Name: "idx_" + s.table + "_series_bucket", Fields: []string{fieldSeries, fieldTags, fieldBucket}},
+		},
+	})
+}
+
+// AppendPoint 写入数据点，同一序列同一时间重复写入时覆盖
+func (s *Store) AppendPoint(ctx context.Context, series string, tags map[string]string, value float64, t time.Time) error {
+	if series == "" {
+		return fmt.Errorf("series is required")
+	}
+
+	tagsKey := formatTags(tags)
+	t = t.UTC()
+	data := map[string]any{
+		s.layout.idField: fmt.Sprintf("%s|%s|%d", series, tagsKey, t.UnixNano()),
+		fieldSeries:      series,
+		fieldTags:        tagsKey,
+		fieldBucket:      s.layout.timeValue(t.Truncate(s.bucketSize)),
+		fieldTime:        s.layout.timeValue(t),
+		fieldValue:       value,
+	}
+
+	record := s.db.GetBuilder().FromMap(data, s.table)
+	return s.db.Create(ctx, s.table, record, database.WithUpdateOnConflict())
+}
+
+// Query 查询 [start, end) 范围内的原始数据点，按时间升序
+func (s *Store) Query(ctx context.Context, series string, tags map[string]string, start, end time.Time) ([]Point, error) {
+	records, err := s.db.Find(ctx, s.table, s.rangeQuery(series, tags, start, end), func(opts *database.QueryOptions) {
+		opts.OrderBy = fieldTime
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	points := make([]Point, 0, len(records))
+	for _, record := range records {
+		fields := record.Fields()
+		t, err := parseTime(fields[fieldTime])
+		if err != nil {
+			return nil, err
+		}
+		points = append(points, Point{
+			Series: series,
+			Tags:   parseTags(fmt.Sprint(fields[fieldTags])),
+			Time:   t,
+			Value:  toFloat64(fields[fieldValue]),
+		})
+	}
+
+	return points, nil
+}
+
+// Downsample 降采样配置
+type Downsample struct {
+	Interval string                      // 时间桶间隔，支持 1h、1d、1M、1y（或 hour、day、month、year）
+	Agg      aggregation.AggregationType // 桶内聚合方式：sum、avg、max、min、count
+}
+
+// DownsampleQuery 创建降采样配置
+func DownsampleQuery(interval string, agg aggregation.AggregationType) *Downsample {
+	return &Downsample{Interval: interval, Agg: agg}
+}
+
+// Downsample 对 [start, end) 范围内的数据点按时间桶聚合，每个时间桶返回一个点
+// 基于 DateHistogramAggregation 实现，时间桶的计算由各后端完成
+func (s *Store) Downsample(ctx context.Context, series string, tags map[string]string, start, end time.Time, downsample *Downsample) ([]Point, error) {
+	if downsample == nil {
+		return nil, fmt.Errorf("downsample is required")
+	}
+
+	metric, err := newMetricAggregation(downsample.Agg)
+	if err != nil {
+		return nil, err
+	}
+	histogram := &aggregation.DateHistogramAggregation{
+		BucketAggregation: aggregation.BucketAggregation{
+			AggName:         "downsample",
+			Field:           fieldTime,
+			SubAggregations: []aggregation.Aggregation{metric},
+		},
+		Interval: downsample.Interval,
+	}
+	// SQL 和 MongoDB 只能按 1h、1d、1M、1y 分桶，其他间隔会被静默按天分桶，直接拒绝
+	if err := histogram.Validate(); err != nil {
+		return nil, err
+	}
+
+	result, err := s.db.Aggregate(ctx, s.table, s.rangeQuery(series, tags, start, end), []aggregation.Aggregation{histogram})
+	if err != nil {
+		return nil, err
+	}
+
+	buckets := result.GetBuckets(histogram.Name())
+	points := make([]Point, 0, len(buckets))
+	for _, bucket := range buckets {
+		t, err := parseTime(bucket.Key())
+		if err != nil {
+			return nil, err
+		}
+		points = append(points, Point{
+			Series: series,
+			Tags:   tags,
+			Time:   t,
+			Value:  toFloat64(bucket.SubAggregations().Get(metric.Name())),
+		})
+	}
+	sort.Slice(points, func(i, j int) bool {
+		return points[i].Time.Before(points[j].Time)
+	})
+
+	return points, nil
+}
+
+// rangeQuery 构建序列的时间范围查询，先按桶裁剪再按时间过滤
+func (s *Store) rangeQuery(series string, tags map[string]string, start, end time.Time) query.Query {
+	return &query.BoolQuery{
+		Must: []query.Query{
+			&query.TermQuery{Field: s.layout.keywordField(fieldSeries), Value: series},
+			&query.TermQuery{Field: s.layout.keywordField(fieldTags), Value: formatTags(tags)},
+			&query.RangeQuery{
+				Field: fieldBucket,
+				Gte:   s.layout.timeValue(start.UTC().Truncate(s.bucketSize)),
+				Lt:    s.layout.timeValue(end.UTC()),
+			},
+			&query.RangeQuery{
+				Field: fieldTime,
+				Gte:   s.layout.timeValue(start.UTC()),
+				Lt:    s.layout.timeValue(end.UTC()),
+			},
+		},
+	}
+}
+
+func newMetricAggregation(aggType aggregation.AggregationType) (aggregation.Aggregation, error) {
+	metric := aggregation.MetricAggregation{AggName: fieldValue, Field: fieldValue}
+
+	switch aggType {
+	case aggregation.AggTypeSum:
+		return &aggregation.SumAggregation{MetricAggregation: metric}, nil
+	case aggregation.AggTypeAvg:
+		return &aggregation.AvgAggregation{MetricAggregation: metric}, nil
+	case aggregation.AggTypeMax:
+		return &aggregation.MaxAggregation{MetricAggregation: metric}, nil
+	case aggregation.AggTypeMin:
+		return &aggregation.MinAggregation{MetricAggregation: metric}, nil
+	case aggregation.AggTypeCount:
+		return &aggregation.CountAggregation{MetricAggregation: metric}, nil
+	default:
+		return nil, fmt.Errorf("unsupported downsample aggregation: %s", aggType)
+	}
+}
+
+// tagEscaper 转义标签键值中的 \、, 和 =，避免 {a: "1,b=2"} 与 {a: "1", b: "2"} 格式化为相同的串
+var tagEscaper = strings.NewReplacer(`\`, `\\`, `,`, `\,`, `=`, `\=`)
+
+// formatTags 将标签格式化为按键排序的 k=v 串，作为序列的一部分参与精确匹配，键值中的分隔符转义
+func formatTags(tags map[string]string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = tagEscaper.Replace(k) + "=" + tagEscaper.Replace(tags[k])
+	}
+	return strings.Join(parts, ",")
+}
+
+// parseTags 解析 formatTags 格式化的标签串
+func parseTags(s string) map[string]string {
+	if s == "" {
+		return nil
+	}
+
+	tags := make(map[string]string)
+	var key, current strings.Builder
+	inValue := false
+	flush := func() {
+		if inValue {
+			tags[key.String()] = current.String()
+		}
+		key.Reset()
+		current.Reset()
+		inValue = false
+	}
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\' && i+1 < len(s):
+			i++
+			current.WriteByte(s[i])
+		case c == '=' && !inValue:
+			key.WriteString(current.String())
+			current.Reset()
+			inValue = true
+		case c == ',':
+			flush()
+		default:
+			current.WriteByte(c)
+		}
+	}
+	flush()
+	return tags
+}
+
+// parseTime 解析各后端返回的时间值和时间桶键
+func parseTime(value any) (time.Time, error) {
+	switch v := value.(type) {
+	case time.Time:
+		return v.UTC(), nil
+	case interface{ Time() time.Time }:
+		return v.Time().UTC(), nil
+	case int64:
+		return time.UnixMilli(v).UTC(), nil
+	case float64:
+		return time.UnixMilli(int64(v)).UTC(), nil
+	case []byte:
+		return parseTime(string(v))
+	case string:
+		layouts := []string{
+			time.RFC3339Nano,
+			"2006-01-02 15:04:05.999999999-07:00",
+			"2006-01-02 15:04:05",
+			"2006-01-02",
+			"2006-01",
+			"2006",
+		}
+		for _, layout := range layouts {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t.UTC(), nil
+			}
+		}
+		if millis, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return time.UnixMilli(millis).UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time value: %v", value)
+}
+
+func toFloat64(value any) float64 {
+	switch v := value.(type) {
+	case float64:
+		return v
+	case float32:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case int32:
+		return float64(v)
+	case int:
+		return float64(v)
+	case []byte:
+		f, _ := strconv.ParseFloat(string(v), 64)
+		return f
+	case string:
+		f, _ := strconv.ParseFloat(v, 64)
+		return f
+	}
+	return 0
+}
@@ -0,0 +1,149 @@
+package ts
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hatlonely/gox/rdb/aggregation"
+	"github.com/hatlonely/gox/rdb/database"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestStore(t *testing.T) {
+	Convey("测试时间序列存储", t, func() {
+		db, err := database.NewSQLWithOptions(&database.SQLOptions{
+			Driver:   "sqlite3",
+			Database: ":memory:",
+			MaxConns: 1,
+			MaxIdle:  1,
+		})
+		So(err, ShouldBeNil)
+		defer db.Close()
+
+		store, err := NewStore(db, &StoreOptions{Table: "metrics", BucketSize: time.Hour})
+		So(err, ShouldBeNil)
+
+		ctx := context.Background()
+		So(store.Migrate(ctx), ShouldBeNil)
+
+		tags := map[string]string{"host": "a", "region": "cn"}
+		day1 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		day2 := day1.Add(24 * time.Hour)
+		So(store.AppendPoint(ctx, "cpu", tags, 1, day1.Add(10*time.Minute)), ShouldBeNil)
+		So(store.AppendPoint(ctx, "cpu", tags, 3, day1.Add(2*time.Hour)), ShouldBeNil)
+		So(store.AppendPoint(ctx, "cpu", tags, 5, day2.Add(30*time.Minute)), ShouldBeNil)
+		So(store.AppendPoint(ctx, "cpu", map[string]string{"host": "b"}, 100, day1.Add(time.Hour)), ShouldBeNil)
+		So(store.AppendPoint(ctx, "mem", tags, 200, day1.Add(time.Hour)), ShouldBeNil)
+
+		Convey("查询原始数据点", func() {
+			points, err := store.Query(ctx, "cpu", tags, day1, day2.Add(24*time.Hour))
+			So(err, ShouldBeNil)
+			So(len(points), ShouldEqual, 3)
+			So(points[0].Value, ShouldEqual, 1)
+			So(points[0].Time.Equal(day1.Add(10*time.Minute)), ShouldBeTrue)
+			So(points[0].Tags, ShouldResemble, tags)
+			So(points[2].Value, ShouldEqual, 5)
+
+			// 时间范围为左闭右开，并按桶裁剪
+			points, err = store.Query(ctx, "cpu", tags, day1.Add(time.Hour), day2)
+			So(err, ShouldBeNil)
+			So(len(points), ShouldEqual, 1)
+			So(points[0].Value, ShouldEqual, 3)
+		})
+
+		Convey("重复写入同一时间的数据点覆盖旧值", func() {
+			So(store.AppendPoint(ctx, "cpu", tags, 7, day1.Add(10*time.Minute)), ShouldBeNil)
+
+			points, err := store.Query(ctx, "cpu", tags, day1, day1.Add(time.Hour))
+			So(err, ShouldBeNil)
+			So(len(points), ShouldEqual, 1)
+			So(points[0].Value, ShouldEqual, 7)
+		})
+
+		Convey("按天降采样", func() {
+			points, err := store.Downsample(ctx, "cpu", tags, day1, day2.Add(24*time.Hour), DownsampleQuery("1d", aggregation.AggTypeAvg))
+			So(err, ShouldBeNil)
+			So(len(points), ShouldEqual, 2)
+			So(points[0].Time.Equal(day1), ShouldBeTrue)
+			So(points[0].Value, ShouldEqual, 2)
+			So(points[1].Time.Equal(day2), ShouldBeTrue)
+			So(points[1].Value, ShouldEqual, 5)
+
+			points, err = store.Downsample(ctx, "cpu", tags, day1, day2.Add(24*time.Hour), DownsampleQuery("1d", aggregation.AggTypeSum))
+			So(err, ShouldBeNil)
+			So(points[0].Value, ShouldEqual, 4)
+		})
+
+		Convey("按小时降采样", func() {
+			points, err := store.Downsample(ctx, "cpu", tags, day1, day2.Add(24*time.Hour), DownsampleQuery("1h", aggregation.AggTypeSum))
+			So(err, ShouldBeNil)
+			So(len(points), ShouldEqual, 3)
+			So(points[0].Time.Equal(day1), ShouldBeTrue)
+			So(points[0].Value, ShouldEqual, 1)
+			So(points[1].Time.Equal(day1.Add(2*time.Hour)), ShouldBeTrue)
+			So(points[1].Value, ShouldEqual, 3)
+			So(points[2].Time.Equal(day2), ShouldBeTrue)
+			So(points[2].Value, ShouldEqual, 5)
+		})
+
+		Convey("按月和按年降采样", func() {
+			So(store.AppendPoint(ctx, "cpu", tags, 7, day1.AddDate(0, 1, 3)), ShouldBeNil)
+
+			points, err := store.Downsample(ctx, "cpu", tags, day1, day1.AddDate(1, 0, 0), DownsampleQuery("1M", aggregation.AggTypeSum))
+			So(err, ShouldBeNil)
+			So(len(points), ShouldEqual, 2)
+			So(points[0].Time.Equal(day1), ShouldBeTrue)
+			So(points[0].Value, ShouldEqual, 9)
+			So(points[1].Time.Equal(day1.AddDate(0, 1, 0)), ShouldBeTrue)
+			So(points[1].Value, ShouldEqual, 7)
+
+			points, err = store.Downsample(ctx, "cpu", tags, day1, day1.AddDate(1, 0, 0), DownsampleQuery("1y", aggregation.AggTypeCount))
+			So(err, ShouldBeNil)
+			So(len(points), ShouldEqual, 1)
+			So(points[0].Time.Equal(day1), ShouldBeTrue)
+			So(points[0].Value, ShouldEqual, 4)
+		})
+
+		Convey("不支持的降采样间隔", func() {
+			_, err := store.Downsample(ctx, "cpu", tags, day1, day2, DownsampleQuery("5m", aggregation.AggTypeAvg))
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("不支持的降采样聚合", func() {
+			_, err := store.Downsample(ctx, "cpu", tags, day1, day2, DownsampleQuery("1d", aggregation.AggTypeTerms))
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestFormatTags(t *testing.T) {
+	Convey("测试标签格式化", t, func() {
+		So(formatTags(nil), ShouldEqual, "")
+		So(formatTags(map[string]string{"region": "cn", "host": "a"}), ShouldEqual, "host=a,region=cn")
+		So(parseTags("host=a,region=cn"), ShouldResemble, map[string]string{"host": "a", "region": "cn"})
+		So(parseTags(""), ShouldBeNil)
+
+		// 键值中的分隔符转义，不同的标签不会格式化为相同的串
+		So(formatTags(map[string]string{"a": "1,b=2"}), ShouldNotEqual, formatTags(map[string]string{"a": "1", "b": "2"}))
+		for _, tags := range []map[string]string{
+			{"a": "1,b=2"},
+			{"a=b": `c\d`, "e": ""},
+			{"path": `C:\`, "q": "x,y"},
+		} {
+			So(parseTags(formatTags(tags)), ShouldResemble, tags)
+		}
+	})
+}
+
+func TestNewLayout(t *testing.T) {
+	Convey("测试按底层数据库选择存储布局", t, func() {
+		So(newLayout(&database.SQL{}).idField, ShouldEqual, "id")
+		So(newLayout(&database.Mongo{}).idField, ShouldEqual, "_id")
+
+		// 包装的数据库解开后判断
+		wrapped := newLayout(database.NewCoalescingDatabase(database.NewInterceptorDatabase(&database.ES{})))
+		So(wrapped.idField, ShouldEqual, "_id")
+		So(wrapped.keywordField(fieldTags), ShouldEqual, "tags.keyword")
+	})
+}
@@ -0,0 +1,277 @@
+package ref
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"strconv"
+	"sync"
+)
+
+// 组件生命周期
+type lifetime int
+
+const (
+	// lifetimeSingleton 单例，在声明的容器中创建一次，子作用域共享
+	lifetimeSingleton lifetime = iota
+	// lifetimeScoped 作用域组件，每个作用域各自创建一份
+	lifetimeScoped
+)
+
+type componentDefinition struct {
+	options  *TypeOptions
+	lifetime lifetime
+}
+
+// componentCall 正在创建中的组件，同一组件的并发获取等待同一次创建
+type componentCall struct {
+	builder  uint64 // 执行构造的 goroutine
+	done     chan struct{}
+	instance any
+	err      error
+}
+
+// waitingOn 记录每个 goroutine 正在等待的组件创建，用于检测循环依赖
+var (
+	waitingMu sync.Mutex
+	waitingOn = make(map[uint64]*componentCall)
+)
+
+// wait 等待组件创建完成，如果等待会形成环（构造函数直接或间接依赖自身）则返回错误
+func (call *componentCall) wait(name string, gid uint64) (any, error) {
+	waitingMu.Lock()
+	for next := call; next != nil; next = waitingOn[next.builder] {
+		if next.builder == gid {
+			waitingMu.Unlock()
+			return nil, fmt.Errorf("circular dependency detected for component %s", name)
+		}
+	}
+	waitingOn[gid] = call
+	waitingMu.Unlock()
+
+	<-call.done
+
+	waitingMu.Lock()
+	delete(waitingOn, gid)
+	waitingMu.Unlock()
+
+	return call.instance, call.err
+}
+
+// goroutineID 获取当前 goroutine 的编号，标准库不直接提供，从栈信息的首行 "goroutine N [" 中解析
+func goroutineID() uint64 {
+	var buf [64]byte
+	line := buf[:runtime.Stack(buf[:], false)]
+	line = bytes.TrimPrefix(line, []byte("goroutine "))
+	if i := bytes.IndexByte(line, ' '); i >= 0 {
+		line = line[:i]
+	}
+	id, _ := strconv.ParseUint(string(line), 10, 64)
+	return id
+}
+
+// Container 组件容器，按名称管理通过 TypeOptions 构造的组件
+//
+// 子作用域通过 NewScope 创建，可以访问父容器中的单例组件，同时拥有自己的作用域组件，
+// 例如请求级别的数据库会话、租户级别的日志。作用域结束时调用 Close，
+// 按创建的逆序释放本作用域内创建的组件，父容器中的组件不受影响
+type Container struct {
+	parent *Container
+
+	mu          sync.Mutex
+	definitions map[string]*componentDefinition
+	instances   map[string]any
+	building    map[string]*componentCall
+	created     []any
+	closed      bool
+}
+
+// NewContainer 创建根容器
+func NewContainer() *Container {
+	return &Container{
+		definitions: make(map[string]*componentDefinition),
+		instances:   make(map[string]any),
+		building:    make(map[string]*componentCall),
+	}
+}
+
+// NewScope 创建子作用域
+func (c *Container) NewScope() *Container {
+	scope := NewContainer()
+	scope.parent = c
+	return scope
+}
+
+// Parent 获取父容器，根容器返回 nil
+func (c *Container) Parent() *Container {
+	return c.parent
+}
+
+// Provide 声明单例组件，首次获取时在当前容器中创建，子作用域共享同一实例
+func (c *Container) Provide(name string, options *TypeOptions) error {
+	return c.define(name, options, lifetimeSingleton)
+}
+
+// ProvideScoped 声明作用域组件，当前容器及每个子作用域首次获取时各自创建一份
+func (c *Container) ProvideScoped(name string, options *TypeOptions) error {
+	return c.define(name, options, lifetimeScoped)
+}
+
+// Set 将已创建的组件放入当前容器，由调用方负责其生命周期，Close 时不会释放
+func (c *Container) Set(name string, component any) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return fmt.Errorf("container is closed")
+	}
+	if _, ok := c.instances[name]; ok {
+		return fmt.Errorf("component %s already exists", name)
+	}
+	c.instances[name] = component
+	return nil
+}
+
+func (c *Container) define(name string, options *TypeOptions, lifetime lifetime) error {
+	if options == nil {
+		return fmt.Errorf("options cannot be nil for component %s", name)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return fmt.Errorf("container is closed")
+	}
+	if _, ok := c.definitions[name]; ok {
+		return fmt.Errorf("component %s already provided", name)
+	}
+	c.definitions[name] = &componentDefinition{options: options, lifetime: lifetime}
+	return nil
+}
+
+// Get 按名称获取组件
+// 查找顺序：当前容器的实例，当前容器的声明，然后沿父容器逐级查找。
+// 单例在声明它的容器中创建，作用域组件在发起获取的容器中创建
+func (c *Container) Get(name string) (any, error) {
+	for owner := c; owner != nil; owner = owner.parent {
+		owner.mu.Lock()
+		if owner.closed {
+			owner.mu.Unlock()
+			return nil, fmt.Errorf("container is closed")
+		}
+		if instance, ok := owner.instances[name]; ok {
+			owner.mu.Unlock()
+			return instance, nil
+		}
+		definition, ok := owner.definitions[name]
+		owner.mu.Unlock()
+		if !ok {
+			continue
+		}
+
+		if definition.lifetime == lifetimeScoped {
+			return c.create(name, definition)
+		}
+		return owner.create(name, definition)
+	}
+
+	return nil, fmt.Errorf("component %s not found", name)
+}
+
+// create 在当前容器中创建组件并缓存，同一容器中只创建一次
+// 构造在锁外执行，构造函数可以通过 Get 获取其依赖的其他组件，并发获取同一组件时等待同一次创建
+func (c *Container) create(name string, definition *componentDefinition) (any, error) {
+	gid := goroutineID()
+
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil, fmt.Errorf("container is closed")
+	}
+	if instance, ok := c.instances[name]; ok {
+		c.mu.Unlock()
+		return instance, nil
+	}
+	if call, ok := c.building[name]; ok {
+		c.mu.Unlock()
+		return call.wait(name, gid)
+	}
+	call := &componentCall{builder: gid, done: make(chan struct{})}
+	c.building[name] = call
+	c.mu.Unlock()
+
+	instance, err := NewWithOptions(definition.options)
+
+	c.mu.Lock()
+	delete(c.building, name)
+	switch {
+	case err != nil:
+		call.err = fmt.Errorf("failed to create component %s: %w", name, err)
+	case c.closed:
+		// 构造期间容器已关闭，新创建的组件不再归容器管理，直接释放
+		call.err = errors.Join(fmt.Errorf("container is closed"), closeComponent(instance))
+	default:
+		call.instance = instance
+		c.instances[name] = instance
+		c.created = append(c.created, instance)
+	}
+	c.mu.Unlock()
+	close(call.done)
+
+	return call.instance, call.err
+}
+
+// Close 结束作用域，按创建的逆序关闭本容器创建的组件
+// 支持实现 io.Closer 或 Close() 方法的组件，返回所有关闭错误
+func (c *Container) Close() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+	c.closed = true
+	created := c.created
+	c.created = nil
+	c.instances = nil
+	c.mu.Unlock()
+
+	var errs []error
+	for i := len(created) - 1; i >= 0; i-- {
+		if err := closeComponent(created[i]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// closeComponent 关闭实现 io.Closer 或 Close() 方法的组件
+func closeComponent(component any) error {
+	switch component := component.(type) {
+	case io.Closer:
+		return component.Close()
+	case interface{ Close() }:
+		component.Close()
+	}
+	return nil
+}
+
+// GetT 按名称获取指定类型的组件
+func GetT[T any](c *Container, name string) (T, error) {
+	var t T
+
+	obj, err := c.Get(name)
+	if err != nil {
+		return t, err
+	}
+
+	result, ok := obj.(T)
+	if !ok {
+		return t, fmt.Errorf("component %s is not of type %T", name, t)
+	}
+
+	return result, nil
+}
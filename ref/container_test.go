@@ -0,0 +1,256 @@
+package ref
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type scopedSession struct {
+	Name   string
+	closed *[]string
+}
+
+func (s *scopedSession) Close() error {
+	*s.closed = append(*s.closed, s.Name)
+	if s.Name == "fail" {
+		return errors.New("close failed")
+	}
+	return nil
+}
+
+func TestContainer(t *testing.T) {
+	var closed []string
+	count := 0
+	newSession := func(options *Options) *scopedSession {
+		count++
+		return &scopedSession{Name: options.Name, closed: &closed}
+	}
+	if err := Register("test-container", "Session", newSession); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	if err := Register("test-container", "Value", NewSimpleValue); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+
+	root := NewContainer()
+	if err := root.Provide("config", &TypeOptions{Namespace: "test-container", Type: "Value", Options: &Options{Name: "global"}}); err != nil {
+		t.Fatalf("Provide() error = %v", err)
+	}
+	if err := root.ProvideScoped("session", &TypeOptions{Namespace: "test-container", Type: "Session", Options: &Options{Name: "request"}}); err != nil {
+		t.Fatalf("ProvideScoped() error = %v", err)
+	}
+	if err := root.Provide("config", &TypeOptions{Namespace: "test-container", Type: "Value"}); err == nil {
+		t.Error("expected error for duplicate component")
+	}
+
+	t.Run("singleton shared across scopes", func(t *testing.T) {
+		scope1 := root.NewScope()
+		defer scope1.Close()
+		scope2 := root.NewScope()
+		defer scope2.Close()
+
+		v1, err := GetT[*Value](scope1, "config")
+		if err != nil {
+			t.Fatalf("GetT() error = %v", err)
+		}
+		v2, err := GetT[*Value](scope2, "config")
+		if err != nil {
+			t.Fatalf("GetT() error = %v", err)
+		}
+		if v1 != v2 || v1.Name != "global" {
+			t.Errorf("expected shared singleton, got %p and %p", v1, v2)
+		}
+		if scope1.Parent() != root {
+			t.Error("expected scope parent to be root")
+		}
+	})
+
+	t.Run("scoped component per scope and disposed at scope end", func(t *testing.T) {
+		closed = nil
+		count = 0
+
+		scope1 := root.NewScope()
+		scope2 := root.NewScope()
+
+		s1, err := GetT[*scopedSession](scope1, "session")
+		if err != nil {
+			t.Fatalf("GetT() error = %v", err)
+		}
+		again, _ := GetT[*scopedSession](scope1, "session")
+		s2, _ := GetT[*scopedSession](scope2, "session")
+		if s1 != again {
+			t.Error("expected same instance within a scope")
+		}
+		if s1 == s2 || count != 2 {
+			t.Errorf("expected one instance per scope, got %d", count)
+		}
+
+		if err := scope1.Close(); err != nil {
+			t.Fatalf("Close() error = %v", err)
+		}
+		if len(closed) != 1 {
+			t.Errorf("expected 1 closed component, got %v", closed)
+		}
+		if _, err := scope1.Get("session"); err == nil {
+			t.Error("expected error after scope closed")
+		}
+		if err := scope1.Close(); err != nil {
+			t.Errorf("expected second Close to be no-op, got %v", err)
+		}
+
+		scope2.Close()
+		if len(closed) != 2 {
+			t.Errorf("expected 2 closed components, got %v", closed)
+		}
+
+		// 父容器中的单例不随子作用域释放
+		if _, err := root.Get("config"); err != nil {
+			t.Errorf("expected root component to survive, got %v", err)
+		}
+	})
+
+	t.Run("scope overrides and close errors", func(t *testing.T) {
+		closed = nil
+
+		scope := root.NewScope()
+		tenantLogger := &Value{Name: "tenant-a"}
+		if err := scope.Set("logger", tenantLogger); err != nil {
+			t.Fatalf("Set() error = %v", err)
+		}
+		if err := scope.ProvideScoped("first", &TypeOptions{Namespace: "test-container", Type: "Session", Options: &Options{Name: "first"}}); err != nil {
+			t.Fatalf("ProvideScoped() error = %v", err)
+		}
+		if err := scope.ProvideScoped("fail", &TypeOptions{Namespace: "test-container", Type: "Session", Options: &Options{Name: "fail"}}); err != nil {
+			t.Fatalf("ProvideScoped() error = %v", err)
+		}
+
+		if logger, _ := GetT[*Value](scope, "logger"); logger != tenantLogger {
+			t.Error("expected scope local component")
+		}
+		if _, err := root.Get("logger"); err == nil {
+			t.Error("expected scope component invisible to parent")
+		}
+		if _, err := GetT[*scopedSession](scope, "logger"); err == nil {
+			t.Error("expected type mismatch error")
+		}
+
+		scope.Get("first")
+		scope.Get("fail")
+		if err := scope.Close(); err == nil {
+			t.Error("expected close error")
+		}
+		// 按创建的逆序释放
+		if len(closed) != 2 || closed[0] != "fail" || closed[1] != "first" {
+			t.Errorf("expected reverse close order, got %v", closed)
+		}
+	})
+
+	t.Run("not found and constructor error", func(t *testing.T) {
+		if _, err := root.Get("missing"); err == nil {
+			t.Error("expected not found error")
+		}
+		if err := root.Provide("broken", &TypeOptions{Namespace: "test-container", Type: "NotRegistered"}); err != nil {
+			t.Fatalf("Provide() error = %v", err)
+		}
+		if _, err := root.Get("broken"); err == nil {
+			t.Error("expected constructor error")
+		}
+	})
+}
+
+type dependentService struct {
+	Dependency *Value
+}
+
+func TestContainerDependencies(t *testing.T) {
+	root := NewContainer()
+	var count atomic.Int32
+	// 构造函数通过容器获取依赖，Options.Name 为依赖的组件名
+	newService := func(options *Options) (*dependentService, error) {
+		count.Add(1)
+		time.Sleep(10 * time.Millisecond)
+		dependency, err := GetT[*Value](root, options.Name)
+		if err != nil {
+			return nil, err
+		}
+		return &dependentService{Dependency: dependency}, nil
+	}
+	if err := Register("test-container-deps", "Service", newService); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	if err := Register("test-container-deps", "Value", NewSimpleValue); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+
+	root.Provide("config", &TypeOptions{Namespace: "test-container-deps", Type: "Value", Options: &Options{Name: "global"}})
+	root.Provide("service", &TypeOptions{Namespace: "test-container-deps", Type: "Service", Options: &Options{Name: "config"}})
+	root.Provide("loop-a", &TypeOptions{Namespace: "test-container-deps", Type: "Service", Options: &Options{Name: "loop-b"}})
+	root.Provide("loop-b", &TypeOptions{Namespace: "test-container-deps", Type: "Service", Options: &Options{Name: "loop-a"}})
+
+	t.Run("constructor resolves dependency from the same container", func(t *testing.T) {
+		done := make(chan struct{})
+		var service *dependentService
+		var err error
+		go func() {
+			defer close(done)
+			service, err = GetT[*dependentService](root, "service")
+		}()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Get() deadlocked while constructor resolved its dependency")
+		}
+		if err != nil {
+			t.Fatalf("GetT() error = %v", err)
+		}
+		if service.Dependency == nil || service.Dependency.Name != "global" {
+			t.Errorf("expected dependency global, got %+v", service.Dependency)
+		}
+	})
+
+	t.Run("concurrent get creates once", func(t *testing.T) {
+		count.Store(0)
+		scope := root.NewScope()
+		defer scope.Close()
+		scope.Provide("service", &TypeOptions{Namespace: "test-container-deps", Type: "Service", Options: &Options{Name: "config"}})
+
+		var wg sync.WaitGroup
+		results := make([]any, 8)
+		for i := range results {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				results[i], _ = scope.Get("service")
+			}(i)
+		}
+		wg.Wait()
+
+		if count.Load() != 1 {
+			t.Errorf("expected constructor called once, got %d", count.Load())
+		}
+		for _, result := range results {
+			if result == nil || result != results[0] {
+				t.Fatalf("expected shared instance, got %v", results)
+			}
+		}
+	})
+
+	t.Run("circular dependency", func(t *testing.T) {
+		done := make(chan error)
+		go func() {
+			_, err := root.Get("loop-a")
+			done <- err
+		}()
+		select {
+		case err := <-done:
+			if err == nil {
+				t.Error("expected circular dependency error")
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Get() deadlocked on circular dependency")
+		}
+	})
+}
@@ -0,0 +1,147 @@
+package ref
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/hatlonely/gox/cfg/storage"
+)
+
+// Expand 替换字符串中的环境变量引用，支持以下写法：
+//   - ${VAR}：环境变量 VAR 的值，未设置时返回错误
+//   - ${VAR:-default}：VAR 未设置或为空时使用 default
+//   - ${VAR:?message}：VAR 未设置或为空时返回包含 message 的错误
+//   - $${：输出字面量 ${，不做替换
+//
+// 没有花括号的 $VAR 原样保留，避免误替换密码等值中的 $
+func Expand(s string) (string, error) {
+	if !strings.Contains(s, "${") {
+		return s, nil
+	}
+
+	var b strings.Builder
+	for {
+		i := strings.Index(s, "${")
+		if i < 0 {
+			b.WriteString(s)
+			return b.String(), nil
+		}
+		if i > 0 && s[i-1] == '$' {
+			b.WriteString(s[:i])
+			b.WriteString("{")
+			s = s[i+2:]
+			continue
+		}
+		end := strings.IndexByte(s[i:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("unterminated reference in %q", s)
+		}
+		value, err := expandReference(s[i+2 : i+end])
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(s[:i])
+		b.WriteString(value)
+		s = s[i+end+1:]
+	}
+}
+
+// expandReference 解析花括号中的引用，返回替换后的值
+func expandReference(ref string) (string, error) {
+	name, fallback, hasDefault := strings.Cut(ref, ":-")
+	message, hasMessage := "", false
+	if !hasDefault {
+		name, message, hasMessage = strings.Cut(ref, ":?")
+	}
+	if name == "" {
+		return "", fmt.Errorf("empty variable name in ${%s}", ref)
+	}
+
+	value, ok := os.LookupEnv(name)
+	switch {
+	case hasDefault:
+		if value == "" {
+			return fallback, nil
+		}
+	case hasMessage:
+		if value == "" {
+			return "", fmt.Errorf("environment variable %s is required: %s", name, message)
+		}
+	case !ok:
+		return "", fmt.Errorf("environment variable %s is not set", name)
+	}
+	return value, nil
+}
+
+// expandValue 递归替换 map、切片中所有字符串的引用，不修改原始数据
+// 整个值只有一个引用且替换结果为数字时转换为 json.Number，可以转换为数值类型的字段，也可以转换为字符串
+func expandValue(value any) (any, error) {
+	switch v := value.(type) {
+	case string:
+		expanded, err := Expand(v)
+		if err != nil {
+			return nil, err
+		}
+		if expanded != v && isWholeReference(v) && json.Valid([]byte(expanded)) {
+			if _, err := json.Number(expanded).Float64(); err == nil {
+				return json.Number(expanded), nil
+			}
+		}
+		return expanded, nil
+	case map[string]any:
+		result := make(map[string]any, len(v))
+		for key, item := range v {
+			expanded, err := expandValue(item)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+			result[key] = expanded
+		}
+		return result, nil
+	case []any:
+		result := make([]any, len(v))
+		for i, item := range v {
+			expanded, err := expandValue(item)
+			if err != nil {
+				return nil, fmt.Errorf("[%d]: %w", i, err)
+			}
+			result[i] = expanded
+		}
+		return result, nil
+	default:
+		return value, nil
+	}
+}
+
+// isWholeReference 判断字符串是否只包含一个 ${...} 引用
+func isWholeReference(s string) bool {
+	return strings.HasPrefix(s, "${") && strings.IndexByte(s, '}') == len(s)-1
+}
+
+// expandOptions 替换从配置中加载的 options 中的引用，返回替换之后的存储；
+// 代码中直接构造的选项结构体原样返回
+func expandOptions(options any) (any, error) {
+	if isNilOptions(options) {
+		return options, nil
+	}
+
+	var data any
+	switch v := options.(type) {
+	case Convertable:
+		if err := v.ConvertTo(&data); err != nil {
+			return nil, fmt.Errorf("failed to read options: %w", err)
+		}
+	case map[string]any:
+		data = v
+	default:
+		return options, nil
+	}
+
+	expanded, err := expandValue(data)
+	if err != nil {
+		return nil, err
+	}
+	return storage.NewMapStorage(expanded), nil
+}
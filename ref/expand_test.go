@@ -0,0 +1,121 @@
+package ref
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hatlonely/gox/cfg/storage"
+)
+
+func TestExpand(t *testing.T) {
+	t.Setenv("REF_EXPAND_HOST", "db.prod")
+	t.Setenv("REF_EXPAND_EMPTY", "")
+
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"plain", "plain"},
+		{"${REF_EXPAND_HOST}", "db.prod"},
+		{"tcp(${REF_EXPAND_HOST}:3306)/app", "tcp(db.prod:3306)/app"},
+		{"${REF_EXPAND_MISSING:-localhost}", "localhost"},
+		{"${REF_EXPAND_EMPTY:-localhost}", "localhost"},
+		{"${REF_EXPAND_EMPTY}", ""},
+		{"$${REF_EXPAND_HOST}", "${REF_EXPAND_HOST}"},
+		{"pa$$word$HOME", "pa$$word$HOME"},
+	}
+	for _, tt := range tests {
+		got, err := Expand(tt.input)
+		if err != nil || got != tt.want {
+			t.Errorf("Expand(%q) = %q, %v, want %q", tt.input, got, err, tt.want)
+		}
+	}
+
+	for _, input := range []string{"${REF_EXPAND_MISSING}", "${REF_EXPAND_EMPTY:?db host}", "${REF_EXPAND_HOST", "${}"} {
+		if _, err := Expand(input); err == nil {
+			t.Errorf("Expand(%q) expected error", input)
+		}
+	}
+	if _, err := Expand("${REF_EXPAND_EMPTY:?db host is required}"); err == nil || !strings.Contains(err.Error(), "db host is required") {
+		t.Errorf("expected error with message, got %v", err)
+	}
+}
+
+func TestNewWithOptions_Expand(t *testing.T) {
+	type expandOptions struct {
+		Path    string        `cfg:"path"`
+		Port    int           `cfg:"port"`
+		Timeout time.Duration `cfg:"timeout"`
+		Tags    []string      `cfg:"tags"`
+	}
+	MustRegister("test", "ExpandValue", func(options *expandOptions) (*expandOptions, error) {
+		return options, nil
+	})
+	t.Setenv("REF_EXPAND_DIR", "/var/log/app")
+	t.Setenv("REF_EXPAND_PORT", "3307")
+	t.Setenv("REF_EXPAND_ZONE", "cn-1")
+
+	var options TypeOptions
+	err := storage.NewMapStorage(map[string]any{
+		"namespace": "test",
+		"type":      "ExpandValue",
+		"expand":    true,
+		"options": map[string]any{
+			"path":    "${REF_EXPAND_DIR}/app.log",
+			"port":    "${REF_EXPAND_PORT}",
+			"timeout": "${REF_EXPAND_TIMEOUT:-5s}",
+			"tags":    []any{"zone=${REF_EXPAND_ZONE}", "$${literal}"},
+		},
+	}).ConvertTo(&options)
+	if err != nil {
+		t.Fatalf("ConvertTo failed: %v", err)
+	}
+	if !options.Expand {
+		t.Fatal("expected expand to be loaded from config")
+	}
+
+	v, err := NewWithOptions(&options)
+	if err != nil {
+		t.Fatalf("NewWithOptions failed: %v", err)
+	}
+	got := v.(*expandOptions)
+	if got.Path != "/var/log/app/app.log" || got.Port != 3307 || got.Timeout != 5*time.Second {
+		t.Errorf("unexpected options: %+v", got)
+	}
+	if len(got.Tags) != 2 || got.Tags[0] != "zone=cn-1" || got.Tags[1] != "${literal}" {
+		t.Errorf("unexpected tags: %v", got.Tags)
+	}
+
+	// 整个值为引用的数字同样可以用于字符串字段
+	t.Setenv("REF_EXPAND_DIR", "2024")
+	v, err = NewWithOptions(&TypeOptions{Namespace: "test", Type: "ExpandValue", Expand: true, Options: map[string]any{"path": "${REF_EXPAND_DIR}"}})
+	if err != nil || v.(*expandOptions).Path != "2024" {
+		t.Errorf("expected numeric value for string field, got %v, %v", v, err)
+	}
+
+	// 未设置 Expand 时原样传入
+	options.Expand = false
+	v, err = NewWithOptions(&options)
+	if err == nil {
+		t.Errorf("expected conversion error without expand, got %+v", v)
+	}
+
+	// 引用的环境变量不存在时返回错误
+	_, err = NewWithOptions(&TypeOptions{Namespace: "test", Type: "ExpandValue", Expand: true, Options: map[string]any{"path": "${REF_EXPAND_MISSING}"}})
+	if err == nil || !strings.Contains(err.Error(), "REF_EXPAND_MISSING") {
+		t.Errorf("expected missing variable error, got %v", err)
+	}
+
+	// 代码中构造的选项结构体不做替换
+	v, err = NewWithOptions(&TypeOptions{Namespace: "test", Type: "ExpandValue", Expand: true, Options: &expandOptions{Path: "${REF_EXPAND_DIR}"}})
+	if err != nil || v.(*expandOptions).Path != "${REF_EXPAND_DIR}" {
+		t.Errorf("expected struct options unchanged, got %v, %v", v, err)
+	}
+
+	// 序列化时保留 expand
+	m, err := (TypeOptions{Namespace: "test", Type: "ExpandValue", Expand: true}).ToMap()
+	if err != nil || m["expand"] != true {
+		t.Errorf("expected expand in ToMap, got %v, %v", m, err)
+	}
+}
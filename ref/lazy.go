@@ -0,0 +1,83 @@
+package ref
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// Lazy 延迟构造的组件，第一次调用 Get 时才根据选项构造
+// 用于大多数部署都不会用到、但默认开启的可选集成，避免在启动时建立连接、占用资源
+//
+// 并发调用 Get 时只会构造一次，构造成功后始终返回同一个对象；
+// 构造失败时返回错误且不缓存，下一次 Get 会重新构造
+type Lazy[T any] struct {
+	options *TypeOptions
+
+	mu     sync.Mutex
+	loaded atomic.Bool
+	value  T
+}
+
+// NewLazyWithOptions 根据选项创建组件，options.Lazy 为 true 时延迟到第一次 Get 时构造，否则立即构造
+// 延迟构造时会立即检查构造函数是否已注册，配置错误在启动时就能发现
+func NewLazyWithOptions[T any](options *TypeOptions) (*Lazy[T], error) {
+	if options == nil {
+		return nil, fmt.Errorf("options cannot be nil")
+	}
+
+	l := &Lazy[T]{options: options}
+	if !options.Lazy {
+		if _, err := l.Get(); err != nil {
+			return nil, err
+		}
+		return l, nil
+	}
+
+	if _, err := lookup(options.Namespace, options.Type); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Get 获取组件，第一次调用时构造
+func (l *Lazy[T]) Get() (T, error) {
+	if l.loaded.Load() {
+		return l.value, nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.loaded.Load() {
+		return l.value, nil
+	}
+
+	var zero T
+	obj, err := NewWithOptions(l.options)
+	if err != nil {
+		return zero, err
+	}
+	value, ok := obj.(T)
+	if !ok {
+		return zero, fmt.Errorf("%s:%s created %T, which is not %T", l.options.Namespace, l.options.Type, obj, zero)
+	}
+
+	l.value = value
+	l.loaded.Store(true)
+	return value, nil
+}
+
+// MustGet 获取组件，构造失败时 panic
+func (l *Lazy[T]) MustGet() T {
+	value, err := l.Get()
+	if err != nil {
+		panic(err)
+	}
+	return value
+}
+
+// Loaded 组件是否已经构造
+func (l *Lazy[T]) Loaded() bool {
+	return l.loaded.Load()
+}
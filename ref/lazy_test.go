@@ -0,0 +1,84 @@
+package ref
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestNewLazyWithOptions(t *testing.T) {
+	var calls atomic.Int32
+	var fail atomic.Bool
+	MustRegister("test", "LazyValue", func(options *Options) (*Value, error) {
+		calls.Add(1)
+		if fail.Load() {
+			return nil, errors.New("dependency unavailable")
+		}
+		return &Value{Name: options.Name}, nil
+	})
+
+	// 延迟构造：创建时不调用构造函数
+	lazy, err := NewLazyWithOptions[*Value](&TypeOptions{Namespace: "test", Type: "LazyValue", Options: &Options{Name: "lazy"}, Lazy: true})
+	if err != nil {
+		t.Fatalf("NewLazyWithOptions failed: %v", err)
+	}
+	if calls.Load() != 0 || lazy.Loaded() {
+		t.Fatalf("expected no construction before Get, got %d calls", calls.Load())
+	}
+
+	// 并发 Get 只构造一次
+	var wg sync.WaitGroup
+	values := make([]*Value, 10)
+	for i := range values {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			values[i] = lazy.MustGet()
+		}(i)
+	}
+	wg.Wait()
+	if calls.Load() != 1 || !lazy.Loaded() {
+		t.Errorf("expected 1 construction, got %d", calls.Load())
+	}
+	for _, v := range values {
+		if v != values[0] || v.Name != "lazy" {
+			t.Errorf("expected the same value, got %v", v)
+		}
+	}
+
+	// 构造失败不缓存，下一次 Get 重新构造
+	calls.Store(0)
+	fail.Store(true)
+	lazy, _ = NewLazyWithOptions[*Value](&TypeOptions{Namespace: "test", Type: "LazyValue", Options: &Options{Name: "retry"}, Lazy: true})
+	if _, err := lazy.Get(); err == nil {
+		t.Error("expected construction error")
+	}
+	fail.Store(false)
+	if v, err := lazy.Get(); err != nil || v.Name != "retry" || calls.Load() != 2 {
+		t.Errorf("expected reconstruction after failure, got %v, %v, %d calls", v, err, calls.Load())
+	}
+
+	// 非延迟构造：创建时立即构造，构造失败直接返回错误
+	calls.Store(0)
+	eager, err := NewLazyWithOptions[*Value](&TypeOptions{Namespace: "test", Type: "LazyValue", Options: &Options{Name: "eager"}})
+	if err != nil || !eager.Loaded() || calls.Load() != 1 {
+		t.Errorf("expected eager construction, got %v, %d calls", err, calls.Load())
+	}
+	fail.Store(true)
+	if _, err := NewLazyWithOptions[*Value](&TypeOptions{Namespace: "test", Type: "LazyValue", Options: &Options{}}); err == nil {
+		t.Error("expected eager construction error")
+	}
+
+	// 未注册的类型在创建时就返回错误
+	if _, err := NewLazyWithOptions[*Value](&TypeOptions{Namespace: "test", Type: "NotRegistered", Lazy: true}); err == nil {
+		t.Error("expected error for unregistered type")
+	}
+
+	// 类型不匹配
+	fail.Store(false)
+	mismatch, _ := NewLazyWithOptions[string](&TypeOptions{Namespace: "test", Type: "LazyValue", Options: &Options{}, Lazy: true})
+	if _, err := mismatch.Get(); err == nil {
+		t.Error("expected type mismatch error")
+	}
+}
@@ -0,0 +1,207 @@
+package ref
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/hatlonely/gox/cfg/storage"
+	"gopkg.in/yaml.v3"
+)
+
+// ToMap 将 TypeOptions 转换为与配置文件结构相同的 map，用于持久化和比较
+//
+// Options 中的结构体按 cfg 标签（依次回退到 json、yaml、toml、ini 标签和字段名）转换为 map，
+// 嵌套的 TypeOptions 递归转换，time.Duration 转换为 "30s" 这样的字符串，time.Time 转换为 RFC3339 字符串，
+// 从配置中加载的 Options 转换为其中的原始数据；nil 的指针、map、切片和接口以及标签为 "-" 的字段省略
+func (o TypeOptions) ToMap() (map[string]any, error) {
+	result := map[string]any{
+		"namespace": o.Namespace,
+		"type":      o.Type,
+	}
+	if !isNilOptions(o.Options) {
+		options, err := toGeneric(reflect.ValueOf(o.Options))
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert options of %s.%s: %w", o.Namespace, o.Type, err)
+		}
+		if options != nil {
+			result["options"] = options
+		}
+	}
+	if o.Retry != nil {
+		retry, err := toGeneric(reflect.ValueOf(o.Retry))
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert retry of %s.%s: %w", o.Namespace, o.Type, err)
+		}
+		result["retry"] = retry
+	}
+	if o.Lazy {
+		result["lazy"] = true
+	}
+	if o.Expand {
+		result["expand"] = true
+	}
+	return result, nil
+}
+
+// MarshalJSON 按 ToMap 的结构序列化，map 的键按字典序输出，相同的配置得到相同的结果
+func (o TypeOptions) MarshalJSON() ([]byte, error) {
+	m, err := o.ToMap()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(m)
+}
+
+// MarshalYAML 按 ToMap 的结构序列化，map 的键按字典序输出
+func (o TypeOptions) MarshalYAML() (any, error) {
+	return o.ToMap()
+}
+
+// UnmarshalJSON 从 MarshalJSON 或配置文件格式的 JSON 加载，Options 与从配置中加载时一样，在构造时转换为构造函数的选项类型
+func (o *TypeOptions) UnmarshalJSON(data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var m map[string]any
+	if err := decoder.Decode(&m); err != nil {
+		return err
+	}
+	return o.fromMap(m)
+}
+
+// UnmarshalYAML 从 MarshalYAML 或配置文件格式的 YAML 加载
+func (o *TypeOptions) UnmarshalYAML(node *yaml.Node) error {
+	var m map[string]any
+	if err := node.Decode(&m); err != nil {
+		return err
+	}
+	return o.fromMap(m)
+}
+
+// fromMap 使用 MapStorage 转换，与从配置中加载 TypeOptions 的行为一致
+func (o *TypeOptions) fromMap(m map[string]any) error {
+	var result TypeOptions
+	if err := storage.NewMapStorage(m).ConvertTo(&result); err != nil {
+		return err
+	}
+	*o = result
+	return nil
+}
+
+var (
+	durationType    = reflect.TypeOf(time.Duration(0))
+	timeType        = reflect.TypeOf(time.Time{})
+	typeOptionsType = reflect.TypeOf(TypeOptions{})
+)
+
+// toGeneric 将值转换为由 map[string]any、[]any 和基础类型组成的通用结构，nil 值返回 nil
+func toGeneric(v reflect.Value) (any, error) {
+	if !v.IsValid() {
+		return nil, nil
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil, nil
+		}
+		if convertable, ok := v.Interface().(Convertable); ok {
+			var data any
+			if err := convertable.ConvertTo(&data); err != nil {
+				return nil, err
+			}
+			return data, nil
+		}
+		return toGeneric(v.Elem())
+	}
+
+	switch v.Type() {
+	case durationType:
+		return time.Duration(v.Int()).String(), nil
+	case timeType:
+		return v.Interface().(time.Time).Format(time.RFC3339Nano), nil
+	case typeOptionsType:
+		return v.Interface().(TypeOptions).ToMap()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		result := make(map[string]any, v.NumField())
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name, skip := fieldKey(field)
+			if skip {
+				continue
+			}
+			value, err := toGeneric(v.Field(i))
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			if value != nil {
+				result[name] = value
+			}
+		}
+		return result, nil
+	case reflect.Map:
+		if v.IsNil() {
+			return nil, nil
+		}
+		result := make(map[string]any, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			key := fmt.Sprint(iter.Key().Interface())
+			value, err := toGeneric(iter.Value())
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+			result[key] = value
+		}
+		return result, nil
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil, nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return v.Bytes(), nil
+		}
+		fallthrough
+	case reflect.Array:
+		result := make([]any, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			value, err := toGeneric(v.Index(i))
+			if err != nil {
+				return nil, fmt.Errorf("[%d]: %w", i, err)
+			}
+			result[i] = value
+		}
+		return result, nil
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer, reflect.Complex64, reflect.Complex128:
+		return nil, fmt.Errorf("unsupported type %v", v.Type())
+	}
+	return v.Interface(), nil
+}
+
+// fieldKey 返回字段在配置中的键，与 cfg/storage 按标签匹配字段的规则一致
+func fieldKey(field reflect.StructField) (string, bool) {
+	for _, tagName := range []string{"cfg", "json", "yaml", "toml", "ini"} {
+		tag, ok := field.Tag.Lookup(tagName)
+		if !ok || tag == "" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		if name == "-" {
+			return "", true
+		}
+		if name != "" {
+			return name, false
+		}
+		return field.Name, false
+	}
+	return field.Name, false
+}
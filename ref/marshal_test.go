@@ -0,0 +1,147 @@
+package ref
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hatlonely/gox/cfg/storage"
+	"gopkg.in/yaml.v3"
+)
+
+type marshalTestOptions struct {
+	Name     string            `cfg:"name"`
+	Timeout  time.Duration     `cfg:"timeout" def:"5s"`
+	Tags     []string          `cfg:"tags"`
+	Labels   map[string]string `cfg:"labels"`
+	Backend  TypeOptions       `cfg:"backend"`
+	Fallback *TypeOptions      `cfg:"fallback"`
+	Internal string            `cfg:"-"`
+}
+
+type marshalTestComponent struct {
+	options *marshalTestOptions
+}
+
+func newMarshalTestComponent(options *marshalTestOptions) *marshalTestComponent {
+	return &marshalTestComponent{options: options}
+}
+
+func newMarshalTestTypeOptions() TypeOptions {
+	return TypeOptions{
+		Namespace: "github.com/hatlonely/gox/ref/marshal_test",
+		Type:      "Component",
+		Options: &marshalTestOptions{
+			Name:     "main",
+			Timeout:  3 * time.Second,
+			Tags:     []string{"a", "b"},
+			Labels:   map[string]string{"z": "1", "a": "2"},
+			Backend:  TypeOptions{Namespace: "github.com/hatlonely/gox/ref/marshal_test", Type: "Backend", Options: map[string]any{"port": 8080}},
+			Internal: "skipped",
+		},
+		Retry: &RetryOptions{MaxAttempts: 3, Backoff: 100 * time.Millisecond},
+		Lazy:  true,
+	}
+}
+
+func TestTypeOptions_MarshalJSON(t *testing.T) {
+	options := newMarshalTestTypeOptions()
+
+	data, err := json.Marshal(options)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	expected := `{"lazy":true,"namespace":"github.com/hatlonely/gox/ref/marshal_test",` +
+		`"options":{"backend":{"namespace":"github.com/hatlonely/gox/ref/marshal_test","options":{"port":8080},"type":"Backend"},` +
+		`"labels":{"a":"2","z":"1"},"name":"main","tags":["a","b"],"timeout":"3s"},` +
+		`"retry":{"backoff":"100ms","jitter":0,"maxAttempts":3,"maxBackoff":"0s"},"type":"Component"}`
+	if string(data) != expected {
+		t.Errorf("Marshal() =\n%s\nwant\n%s", data, expected)
+	}
+
+	// 序列化结果稳定
+	for i := 0; i < 10; i++ {
+		again, _ := json.Marshal(options)
+		if string(again) != string(data) {
+			t.Fatalf("Marshal() is not stable: %s", again)
+		}
+	}
+}
+
+func TestTypeOptions_RoundTrip(t *testing.T) {
+	MustRegister("github.com/hatlonely/gox/ref/marshal_test", "Component", newMarshalTestComponent)
+	options := newMarshalTestTypeOptions()
+
+	check := func(t *testing.T, loaded TypeOptions) {
+		if loaded.Namespace != options.Namespace || loaded.Type != options.Type || !loaded.Lazy {
+			t.Errorf("loaded = %+v", loaded)
+		}
+		if !reflect.DeepEqual(loaded.Retry, options.Retry) {
+			t.Errorf("loaded.Retry = %+v, want %+v", loaded.Retry, options.Retry)
+		}
+
+		obj, err := NewWithOptions(&loaded)
+		if err != nil {
+			t.Fatalf("NewWithOptions() error = %v", err)
+		}
+		got := obj.(*marshalTestComponent).options
+		want := *options.Options.(*marshalTestOptions)
+		want.Internal = ""
+		if got.Name != want.Name || got.Timeout != want.Timeout || !reflect.DeepEqual(got.Tags, want.Tags) ||
+			!reflect.DeepEqual(got.Labels, want.Labels) || got.Backend.Type != "Backend" || got.Fallback != nil {
+			t.Errorf("options = %+v, want %+v", got, want)
+		}
+
+		// 重新序列化得到相同的结果
+		first, _ := json.Marshal(options)
+		second, err := json.Marshal(loaded)
+		if err != nil || string(first) != string(second) {
+			t.Errorf("re-marshal = %s, %v, want %s", second, err, first)
+		}
+	}
+
+	t.Run("json", func(t *testing.T) {
+		data, err := json.Marshal(options)
+		if err != nil {
+			t.Fatalf("Marshal() error = %v", err)
+		}
+		var loaded TypeOptions
+		if err := json.Unmarshal(data, &loaded); err != nil {
+			t.Fatalf("Unmarshal() error = %v", err)
+		}
+		check(t, loaded)
+	})
+
+	t.Run("yaml", func(t *testing.T) {
+		data, err := yaml.Marshal(options)
+		if err != nil {
+			t.Fatalf("Marshal() error = %v", err)
+		}
+		var loaded TypeOptions
+		if err := yaml.Unmarshal(data, &loaded); err != nil {
+			t.Fatalf("Unmarshal() error = %v", err)
+		}
+		check(t, loaded)
+	})
+}
+
+func TestTypeOptions_ToMap_ConvertableOptions(t *testing.T) {
+	// 从配置中加载的 Options 是 storage，转换为其中的原始数据
+	options := TypeOptions{
+		Namespace: "ns",
+		Type:      "T",
+		Options:   storage.NewMapStorage(map[string]any{"name": "x"}),
+	}
+	m, err := options.ToMap()
+	if err != nil {
+		t.Fatalf("ToMap() error = %v", err)
+	}
+	if !reflect.DeepEqual(m["options"], map[string]any{"name": "x"}) {
+		t.Errorf("options = %v", m["options"])
+	}
+
+	if m, _ := (TypeOptions{Namespace: "ns", Type: "T"}).ToMap(); len(m) != 2 {
+		t.Errorf("ToMap() = %v, want only namespace and type", m)
+	}
+}
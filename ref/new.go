@@ -1,9 +1,15 @@
 package ref
 
 import (
+	"errors"
 	"fmt"
 	"reflect"
+	"runtime"
+	"strings"
 	"sync"
+
+	"github.com/hatlonely/gox/cfg/def"
+	"github.com/hatlonely/gox/cfg/validator"
 )
 
 type constructor struct {
@@ -11,6 +17,9 @@ type constructor struct {
 	newFunc      reflect.Value
 	hasOptions   bool
 	returnsError bool
+
+	// site 注册的调用位置，冲突时用于定位
+	site string
 }
 
 func newConstructor(newFunc any) (*constructor, error) {
@@ -71,6 +80,12 @@ func (c *constructor) new(options any) (any, error) {
 				return nil, fmt.Errorf("failed to process storage options: %w", err)
 			}
 
+			if paramType := c.newFunc.Type().In(0); isOptionsInterface(paramType) {
+				if processedOptions, err = selectInterfaceOptions(paramType, processedOptions); err != nil {
+					return nil, err
+				}
+			}
+
 			args = []reflect.Value{reflect.ValueOf(processedOptions)}
 		}
 	} else {
@@ -100,6 +115,85 @@ func (c *constructor) new(options any) (any, error) {
 	}
 }
 
+// newOrDefault 与 new 相同，options 为 nil 时传入参数类型的默认值而不是零值，用于 TypeOptions 省略了 options 的情况
+// 配置中省略 options 时，storage 转换得到的是值为 nil 的指针，同样视为省略
+func (c *constructor) newOrDefault(options any) (any, error) {
+	if isNilOptions(options) && c.hasOptions {
+		defaults, err := c.defaultOptions()
+		if err != nil {
+			return nil, err
+		}
+		options = defaults
+	}
+	return c.new(options)
+}
+
+func isNilOptions(options any) bool {
+	if options == nil {
+		return true
+	}
+	v := reflect.ValueOf(options)
+	return v.Kind() == reflect.Ptr && v.IsNil()
+}
+
+// defaultOptions 构造参数类型的默认值：结构体或结构体指针按 def 标签设置默认值并校验
+// 接口等其他类型无法确定具体的选项类型，返回 nil，由 new 传入零值
+func (c *constructor) defaultOptions() (any, error) {
+	paramType := c.newFunc.Type().In(0)
+	structType := paramType
+	if structType.Kind() == reflect.Ptr {
+		structType = structType.Elem()
+	}
+	if structType.Kind() != reflect.Struct {
+		return nil, nil
+	}
+
+	value := reflect.New(structType)
+	if err := def.SetDefaults(value.Interface()); err != nil {
+		return nil, fmt.Errorf("failed to set default options for %v: %w", paramType, err)
+	}
+	if err := validator.ValidateStruct(value.Interface()); err != nil {
+		return nil, fmt.Errorf("invalid default options for %v: %w", paramType, err)
+	}
+
+	if paramType.Kind() == reflect.Ptr {
+		return value.Interface(), nil
+	}
+	return value.Elem().Interface(), nil
+}
+
+// isOptionsInterface 选项参数是否为需要由 TypeOptions 选择实现的接口，any 可以直接接收任意选项，不需要选择
+func isOptionsInterface(paramType reflect.Type) bool {
+	return paramType.Kind() == reflect.Interface && paramType.NumMethod() > 0
+}
+
+// selectInterfaceOptions 参数类型为接口时，options 是另一个 TypeOptions，由它选择并构造具体的选项
+// 已经实现了该接口的 options 直接使用
+func selectInterfaceOptions(paramType reflect.Type, options any) (any, error) {
+	if reflect.TypeOf(options).Implements(paramType) {
+		return options, nil
+	}
+
+	var typeOptions *TypeOptions
+	switch v := options.(type) {
+	case *TypeOptions:
+		typeOptions = v
+	case TypeOptions:
+		typeOptions = &v
+	default:
+		return nil, fmt.Errorf("options %T does not implement %v", options, paramType)
+	}
+
+	selected, err := NewWithOptions(typeOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create options %s:%s: %w", typeOptions.Namespace, typeOptions.Type, err)
+	}
+	if selected == nil || !reflect.TypeOf(selected).Implements(paramType) {
+		return nil, fmt.Errorf("options %s:%s created %T, which does not implement %v", typeOptions.Namespace, typeOptions.Type, selected, paramType)
+	}
+	return selected, nil
+}
+
 // Convertable 接口定义，用于支持配置数据的自动转换
 // 任何实现了此接口的类型都可以作为 options 参数传递给 New 方法
 // 并自动转换为构造函数期望的参数类型
@@ -122,6 +216,15 @@ func (c *constructor) processStorageOptions(options any) (any, error) {
 		// 获取目标参数类型
 		paramType := funcType.In(0)
 
+		// 接口类型的参数由另一个 TypeOptions 选择具体的选项，先转换为 TypeOptions
+		if isOptionsInterface(paramType) {
+			typeOptions := &TypeOptions{}
+			if err := convertable.ConvertTo(typeOptions); err != nil {
+				return nil, fmt.Errorf("failed to convert convertable to type options: %w", err)
+			}
+			return typeOptions, nil
+		}
+
 		// 创建目标类型的实例
 		var targetValue reflect.Value
 		if paramType.Kind() == reflect.Ptr {
@@ -150,6 +253,66 @@ func (c *constructor) processStorageOptions(options any) (any, error) {
 
 var nameConstructorMap sync.Map
 
+// registerMu 保证注册时检查和写入的原子性
+var registerMu sync.Mutex
+
+// ErrDuplicateRegistration 同一个类型注册了不同的构造函数
+var ErrDuplicateRegistration = errors.New("duplicate registration")
+
+// RegistrationConflictError 同一个类型注册了不同的构造函数，给出两处注册的位置
+// 可以通过 errors.Is(err, ErrDuplicateRegistration) 判断
+type RegistrationConflictError struct {
+	Namespace string
+	Type      string
+	// ExistingSite 已有注册的位置
+	ExistingSite string
+	// Site 冲突的注册位置
+	Site string
+}
+
+func (e *RegistrationConflictError) Error() string {
+	return fmt.Sprintf("constructor for %s:%s already registered with different function at %s, conflicting registration at %s",
+		e.Namespace, e.Type, e.ExistingSite, e.Site)
+}
+
+func (e *RegistrationConflictError) Unwrap() error {
+	return ErrDuplicateRegistration
+}
+
+type registerOptions struct {
+	allowOverride bool
+}
+
+// RegisterOption 注册选项
+type RegisterOption func(*registerOptions)
+
+// AllowOverride 允许用新的构造函数覆盖已注册的构造函数，用于测试中替换为桩实现
+func AllowOverride() RegisterOption {
+	return func(options *registerOptions) {
+		options.allowOverride = true
+	}
+}
+
+// registerPkgPrefix 本包函数名的前缀，用于在调用栈中跳过注册函数
+var registerPkgPrefix = reflect.TypeOf(constructor{}).PkgPath() + "."
+
+// registrationSite 返回调用注册函数的位置，跳过本包的注册函数
+func registrationSite() string {
+	pcs := make([]uintptr, 16)
+	n := runtime.Callers(2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		name, ok := strings.CutPrefix(frame.Function, registerPkgPrefix)
+		if !ok || !(strings.HasPrefix(name, "Register") || strings.HasPrefix(name, "MustRegister")) {
+			return fmt.Sprintf("%s:%d", frame.File, frame.Line)
+		}
+		if !more {
+			return "unknown"
+		}
+	}
+}
+
 func isSameFunc(func1, func2 any) bool {
 	if func1 == nil || func2 == nil {
 		return func1 == func2
@@ -162,20 +325,33 @@ func isSameFunc(func1, func2 any) bool {
 	return v1.Pointer() == v2.Pointer()
 }
 
-func Register(namespace string, type_ string, newFunc any) error {
+// Register 注册构造函数，相同的函数重复注册时忽略
+//
+// 已经注册了不同的构造函数时返回 RegistrationConflictError，给出两处注册的位置，已有的注册保持不变；
+// 确实需要替换时（如测试中替换为桩实现）使用 AllowOverride
+func Register(namespace string, type_ string, newFunc any, opts ...RegisterOption) error {
+	options := &registerOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+	site := registrationSite()
 	key := namespace + ":" + type_
 
+	registerMu.Lock()
+	defer registerMu.Unlock()
+
 	// 检查是否已经注册
-	if existingValue, ok := nameConstructorMap.Load(key); ok {
+	if existingValue, ok := nameConstructorMap.Load(key); ok && !options.allowOverride {
 		if existingConstructor, ok := existingValue.(*constructor); ok {
-			// 检查是否是相同的函数
+			// 相同函数，跳过注册
 			if isSameFunc(existingConstructor.originalFunc, newFunc) {
-				// 相同函数，跳过注册
 				return nil
-			} else {
-				// 不同函数，返回错误
-				return fmt.Errorf("constructor for %s:%s already registered with different function", namespace, type_)
 			}
+			// 不同函数，返回错误，保留已有的注册
+			return &RegistrationConflictError{Namespace: namespace, Type: type_, ExistingSite: existingConstructor.site, Site: site}
+		}
+		if _, ok := existingValue.(*unsupportedType); ok {
+			return fmt.Errorf("type %s:%s already registered as unsupported on this platform", namespace, type_)
 		}
 	}
 
@@ -183,12 +359,13 @@ func Register(namespace string, type_ string, newFunc any) error {
 	if err != nil {
 		return fmt.Errorf("failed to create constructor: %w", err)
 	}
+	constructor.site = site
 
 	nameConstructorMap.Store(key, constructor)
 	return nil
 }
 
-func RegisterT[T any](newFunc any) error {
+func RegisterT[T any](newFunc any, opts ...RegisterOption) error {
 	var t T
 	tType := reflect.TypeOf(t)
 
@@ -205,18 +382,18 @@ func RegisterT[T any](newFunc any) error {
 		return fmt.Errorf("cannot determine package path or type name for type %T", t)
 	}
 
-	return Register(pkgPath, typeName, newFunc)
+	return Register(pkgPath, typeName, newFunc, opts...)
 }
 
-func MustRegister(namespace string, type_ string, newFunc any) {
-	err := Register(namespace, type_, newFunc)
+func MustRegister(namespace string, type_ string, newFunc any, opts ...RegisterOption) {
+	err := Register(namespace, type_, newFunc, opts...)
 	if err != nil {
 		panic(err)
 	}
 }
 
-func MustRegisterT[T any](newFunc any) {
-	err := RegisterT[T](newFunc)
+func MustRegisterT[T any](newFunc any, opts ...RegisterOption) {
+	err := RegisterT[T](newFunc, opts...)
 	if err != nil {
 		panic(err)
 	}
@@ -226,29 +403,66 @@ type TypeOptions struct {
 	Namespace string `cfg:"namespace"`
 	Type      string `cfg:"type"`
 	Options   any    `cfg:"options"`
+	// Retry 构造失败时的重试策略，为空时不重试
+	Retry *RetryOptions `cfg:"retry"`
+	// Lazy 延迟到第一次使用时构造，只对 NewLazyWithOptions 生效，NewWithOptions 始终立即构造
+	Lazy bool `cfg:"lazy"`
+	// Expand 构造之前替换 Options 中字符串的 ${VAR} 环境变量引用，语法见 Expand；
+	// 只作用于从配置中加载的 Options，代码中直接构造的选项结构体不做替换
+	Expand bool `cfg:"expand"`
 }
 
+// NewWithOptions 根据 TypeOptions 创建对象
+//
+// Options 为 nil 时（如配置中省略了 options），构造函数的选项结构体按 def 标签设置默认值并校验后传入；
+// 选项参数为接口时，Options 是另一个 TypeOptions，由它选择并构造实现该接口的选项
 func NewWithOptions(options *TypeOptions) (any, error) {
-	v, err := New(options.Namespace, options.Type, options.Options)
+	constructorOptions := options.Options
+	if options.Expand {
+		expanded, err := expandOptions(constructorOptions)
+		if err != nil {
+			return nil, fmt.Errorf("failed to expand options for %s:%s: %w", options.Namespace, options.Type, err)
+		}
+		constructorOptions = expanded
+	}
+
+	if options.Retry != nil && options.Retry.MaxAttempts > 1 {
+		return newWithRetry(options.Namespace, options.Type, constructorOptions, options.Retry)
+	}
+
+	constructor, err := lookup(options.Namespace, options.Type)
 	if err != nil {
 		return nil, err
 	}
-	return v, nil
+	return constructor.newOrDefault(constructorOptions)
 }
 
 func New(namespace string, type_ string, options any) (any, error) {
+	constructor, err := lookup(namespace, type_)
+	if err != nil {
+		return nil, err
+	}
+
+	return constructor.new(options)
+}
+
+// lookup 查找已注册的构造函数
+func lookup(namespace string, type_ string) (*constructor, error) {
 	key := namespace + ":" + type_
 	value, ok := nameConstructorMap.Load(key)
 	if !ok {
 		return nil, fmt.Errorf("constructor not found for %s:%s", namespace, type_)
 	}
+	if unsupported, ok := value.(*unsupportedType); ok {
+		return nil, unsupportedError(namespace, type_, unsupported)
+	}
 
 	constructor, ok := value.(*constructor)
 	if !ok {
 		return nil, fmt.Errorf("invalid constructor type for %s:%s", namespace, type_)
 	}
 
-	return constructor.new(options)
+	return constructor, nil
 }
 
 func NewT[T any](options any) (T, error) {
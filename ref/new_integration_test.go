@@ -180,3 +180,39 @@ func TestStorageIntegrationWithNewT(t *testing.T) {
 		t.Errorf("Expected api_key 'secret-key-123', got '%s'", client.Config.APIKey)
 	}
 }
+
+type integrationFormatter interface {
+	Format(string) string
+}
+
+type integrationPrefixFormatter struct {
+	Prefix string `cfg:"prefix" def:"> "`
+}
+
+func (f *integrationPrefixFormatter) Format(s string) string {
+	return f.Prefix + s
+}
+
+// TestStorageInterfaceOptionsIntegration tests interface options selected by a nested TypeOptions in storage
+func TestStorageInterfaceOptionsIntegration(t *testing.T) {
+	namespace := "test-storage-interface-options"
+	ref.MustRegister(namespace, "PrefixFormatter", func(options *integrationPrefixFormatter) *integrationPrefixFormatter {
+		return options
+	})
+	ref.MustRegister(namespace, "Greeting", func(formatter integrationFormatter) string {
+		return formatter.Format("hello")
+	})
+
+	// options 中省略了 PrefixFormatter 的 options，使用 def 标签的默认值
+	mapStorage := storage.NewMapStorage(map[string]interface{}{
+		"namespace": namespace,
+		"type":      "PrefixFormatter",
+	})
+	result, err := ref.NewWithOptions(&ref.TypeOptions{Namespace: namespace, Type: "Greeting", Options: mapStorage})
+	if err != nil {
+		t.Fatalf("NewWithOptions() error = %v", err)
+	}
+	if result != "> hello" {
+		t.Errorf("Expected '> hello', got '%v'", result)
+	}
+}
@@ -3,7 +3,9 @@ package ref
 import (
 	"errors"
 	"fmt"
+	"strings"
 	"testing"
+	"time"
 )
 
 type Value struct {
@@ -219,9 +221,22 @@ func TestDuplicateRegister(t *testing.T) {
 	if err == nil {
 		t.Error("Register() with different function should return error")
 	} else {
-		expectedMsg := "constructor for test-duplicate:Value already registered with different function"
-		if err.Error() != expectedMsg {
-			t.Errorf("Register() error message = %v, want %v", err.Error(), expectedMsg)
+		expectedMsg := "constructor for test-duplicate:Value already registered with different function at "
+		if !strings.HasPrefix(err.Error(), expectedMsg) {
+			t.Errorf("Register() error message = %v, want prefix %v", err.Error(), expectedMsg)
+		}
+		if !errors.Is(err, ErrDuplicateRegistration) {
+			t.Errorf("Register() error = %v, want ErrDuplicateRegistration", err)
+		}
+		var conflictErr *RegistrationConflictError
+		if !errors.As(err, &conflictErr) {
+			t.Fatalf("Register() error type = %T, want *RegistrationConflictError", err)
+		}
+		if !strings.Contains(conflictErr.ExistingSite, "new_test.go:") || !strings.Contains(conflictErr.Site, "new_test.go:") {
+			t.Errorf("Register() sites = %v, %v, want both in new_test.go", conflictErr.ExistingSite, conflictErr.Site)
+		}
+		if conflictErr.ExistingSite == conflictErr.Site {
+			t.Errorf("Register() sites should differ, got %v", conflictErr.Site)
 		}
 	}
 
@@ -289,6 +304,31 @@ func TestDuplicateRegisterT(t *testing.T) {
 	}
 }
 
+func TestRegisterAllowOverride(t *testing.T) {
+	namespace := "test-allow-override"
+
+	MustRegister(namespace, "Value", NewValue)
+
+	// 允许覆盖时替换为新的构造函数
+	err := Register(namespace, "Value", NewDefaultValue, AllowOverride())
+	if err != nil {
+		t.Fatalf("Register() with AllowOverride error = %v", err)
+	}
+
+	result, err := New(namespace, "Value", nil)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if value := result.(*Value); value.Name != "default" {
+		t.Errorf("New() got name = %v, want %v", value.Name, "default")
+	}
+
+	// 覆盖之后，不带选项注册不同的函数仍然失败
+	if err := Register(namespace, "Value", NewValue); !errors.Is(err, ErrDuplicateRegistration) {
+		t.Errorf("Register() error = %v, want ErrDuplicateRegistration", err)
+	}
+}
+
 func TestMustRegister(t *testing.T) {
 	namespace := "test-must"
 
@@ -329,9 +369,9 @@ func TestMustRegisterPanic(t *testing.T) {
 		} else {
 			// 验证panic信息包含预期的错误信息
 			errorStr := fmt.Sprintf("%v", r)
-			expected := "constructor for test-must-panic:Value already registered with different function"
-			if errorStr != expected {
-				t.Errorf("MustRegister() panic message = %v, want %v", errorStr, expected)
+			expected := "constructor for test-must-panic:Value already registered with different function at "
+			if !strings.HasPrefix(errorStr, expected) {
+				t.Errorf("MustRegister() panic message = %v, want prefix %v", errorStr, expected)
 			}
 		}
 	}()
@@ -566,3 +606,123 @@ func TestNilOptionsZeroValue(t *testing.T) {
 		t.Errorf("Expected name 'test' with valid options, got '%s'", value2.Name)
 	}
 }
+
+type DefaultOptions struct {
+	Name    string        `cfg:"name" def:"default-name"`
+	Timeout time.Duration `cfg:"timeout" def:"3s"`
+	Retry   int           `cfg:"retry" def:"3" validate:"min=1"`
+}
+
+type DefaultValue struct {
+	Options DefaultOptions
+}
+
+// Formatter 由另一个 TypeOptions 选择具体实现的选项接口
+type Formatter interface {
+	Format(string) string
+}
+
+type PrefixFormatter struct {
+	Prefix string `cfg:"prefix" def:"> "`
+}
+
+func (f *PrefixFormatter) Format(s string) string {
+	return f.Prefix + s
+}
+
+// TestNewWithOptionsDefaultOptions 测试 TypeOptions.Options 为 nil 时按 def 标签构造选项
+func TestNewWithOptionsDefaultOptions(t *testing.T) {
+	namespace := "test-default-options"
+	MustRegister(namespace, "Pointer", func(options *DefaultOptions) *DefaultValue {
+		return &DefaultValue{Options: *options}
+	})
+	MustRegister(namespace, "Struct", func(options DefaultOptions) *DefaultValue {
+		return &DefaultValue{Options: options}
+	})
+
+	for _, type_ := range []string{"Pointer", "Struct"} {
+		result, err := NewWithOptions(&TypeOptions{Namespace: namespace, Type: type_})
+		if err != nil {
+			t.Fatalf("NewWithOptions(%s) error = %v", type_, err)
+		}
+		options := result.(*DefaultValue).Options
+		if options.Name != "default-name" || options.Timeout != 3*time.Second || options.Retry != 3 {
+			t.Errorf("NewWithOptions(%s) options = %+v, want defaults", type_, options)
+		}
+	}
+
+	// New 传入 nil 时仍然传递零值
+	result, err := New(namespace, "Struct", nil)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if options := result.(*DefaultValue).Options; options != (DefaultOptions{}) {
+		t.Errorf("New() options = %+v, want zero value", options)
+	}
+
+	// 默认值校验失败时返回错误
+	type InvalidOptions struct {
+		Size int `validate:"min=1"`
+	}
+	MustRegister(namespace, "Invalid", func(options *InvalidOptions) *DefaultValue {
+		return &DefaultValue{}
+	})
+	if _, err := NewWithOptions(&TypeOptions{Namespace: namespace, Type: "Invalid"}); err == nil {
+		t.Error("NewWithOptions() with invalid default options should return error")
+	}
+}
+
+// TestNewWithOptionsInterfaceOptions 测试选项参数为接口时由另一个 TypeOptions 选择实现
+func TestNewWithOptionsInterfaceOptions(t *testing.T) {
+	namespace := "test-interface-options"
+	MustRegister(namespace, "PrefixFormatter", func(options *PrefixFormatter) *PrefixFormatter {
+		return options
+	})
+	MustRegister(namespace, "Plain", NewDefaultValue)
+	MustRegister(namespace, "Value", func(formatter Formatter) *Value {
+		if formatter == nil {
+			return &Value{Name: "no-formatter"}
+		}
+		return &Value{Name: formatter.Format("value")}
+	})
+
+	result, err := NewWithOptions(&TypeOptions{
+		Namespace: namespace,
+		Type:      "Value",
+		Options:   &TypeOptions{Namespace: namespace, Type: "PrefixFormatter"},
+	})
+	if err != nil {
+		t.Fatalf("NewWithOptions() error = %v", err)
+	}
+	if name := result.(*Value).Name; name != "> value" {
+		t.Errorf("NewWithOptions() name = %v, want %v", name, "> value")
+	}
+
+	// 已经实现了接口的选项直接使用
+	result, err = New(namespace, "Value", &PrefixFormatter{Prefix: "# "})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if name := result.(*Value).Name; name != "# value" {
+		t.Errorf("New() name = %v, want %v", name, "# value")
+	}
+
+	// 省略选项时无法确定实现，传入零值
+	result, err = NewWithOptions(&TypeOptions{Namespace: namespace, Type: "Value"})
+	if err != nil {
+		t.Fatalf("NewWithOptions() error = %v", err)
+	}
+	if name := result.(*Value).Name; name != "no-formatter" {
+		t.Errorf("NewWithOptions() name = %v, want %v", name, "no-formatter")
+	}
+
+	// 选择的实现没有实现接口时返回错误
+	_, err = NewWithOptions(&TypeOptions{
+		Namespace: namespace,
+		Type:      "Value",
+		Options:   &TypeOptions{Namespace: namespace, Type: "Plain"},
+	})
+	if err == nil {
+		t.Error("NewWithOptions() with options not implementing interface should return error")
+	}
+}
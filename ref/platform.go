@@ -0,0 +1,105 @@
+package ref
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// ErrUnsupportedPlatform 类型在当前平台不可用
+var ErrUnsupportedPlatform = errors.New("not supported on this platform")
+
+// unsupportedType 当前平台不可用的类型，platforms 为支持的平台
+type unsupportedType struct {
+	platforms []string
+}
+
+// currentPlatform 当前平台，格式为 GOOS/GOARCH
+func currentPlatform() string {
+	return runtime.GOOS + "/" + runtime.GOARCH
+}
+
+// matchPlatform 判断当前平台是否匹配 platform，platform 可以是 GOOS 或 GOOS/GOARCH
+func matchPlatform(platform string) bool {
+	if strings.Contains(platform, "/") {
+		return platform == currentPlatform()
+	}
+	return platform == runtime.GOOS
+}
+
+// RegisterPlatform 注册只在 platforms 上可用的构造函数
+// platforms 的元素可以是 GOOS（如 linux）或 GOOS/GOARCH（如 linux/amd64），当前平台不匹配时登记为不可用，
+// 构造时返回说明支持平台和可用类型的错误，而不是构造函数未注册
+func RegisterPlatform(namespace string, type_ string, platforms []string, newFunc any) error {
+	for _, platform := range platforms {
+		if matchPlatform(platform) {
+			return Register(namespace, type_, newFunc)
+		}
+	}
+	return RegisterUnsupported(namespace, type_, platforms...)
+}
+
+// RegisterUnsupported 登记在当前平台不可用的类型，platforms 为支持的平台
+// 用于通过 build tag 只在部分平台编译的类型，在其余平台的文件中调用，例如：
+//
+//	//go:build !linux
+//
+//	func init() {
+//		ref.MustRegisterUnsupported("github.com/hatlonely/gox/log/writer", "JournaldWriter", "linux")
+//	}
+func RegisterUnsupported(namespace string, type_ string, platforms ...string) error {
+	key := namespace + ":" + type_
+	registerMu.Lock()
+	defer registerMu.Unlock()
+	if existingValue, ok := nameConstructorMap.Load(key); ok {
+		if _, ok := existingValue.(*unsupportedType); ok {
+			return nil
+		}
+		return fmt.Errorf("constructor for %s:%s already registered", namespace, type_)
+	}
+
+	nameConstructorMap.Store(key, &unsupportedType{platforms: platforms})
+	return nil
+}
+
+func MustRegisterPlatform(namespace string, type_ string, platforms []string, newFunc any) {
+	err := RegisterPlatform(namespace, type_, platforms, newFunc)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func MustRegisterUnsupported(namespace string, type_ string, platforms ...string) {
+	err := RegisterUnsupported(namespace, type_, platforms...)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// unsupportedError 构造当前平台不可用的类型时的错误，列出支持的平台和同一 namespace 下可用的类型
+func unsupportedError(namespace string, type_ string, unsupported *unsupportedType) error {
+	details := []string{"platform: " + currentPlatform()}
+	if len(unsupported.platforms) > 0 {
+		details = append(details, "supported: "+strings.Join(unsupported.platforms, ", "))
+	}
+	if available := availableTypes(namespace); len(available) > 0 {
+		details = append(details, "available: "+strings.Join(available, ", "))
+	}
+	return fmt.Errorf("type %s:%s %w (%s)", namespace, type_, ErrUnsupportedPlatform, strings.Join(details, "; "))
+}
+
+// availableTypes namespace 下当前平台可用的类型，按名称排序
+func availableTypes(namespace string) []string {
+	prefix := namespace + ":"
+	var types []string
+	nameConstructorMap.Range(func(key, value any) bool {
+		if _, ok := value.(*constructor); ok && strings.HasPrefix(key.(string), prefix) {
+			types = append(types, strings.TrimPrefix(key.(string), prefix))
+		}
+		return true
+	})
+	sort.Strings(types)
+	return types
+}
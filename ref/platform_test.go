@@ -0,0 +1,68 @@
+package ref
+
+import (
+	"errors"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestRegisterPlatform(t *testing.T) {
+	if err := RegisterPlatform("platform", "Current", []string{"plan9", runtime.GOOS}, NewDefaultValue); err != nil {
+		t.Fatalf("RegisterPlatform failed: %v", err)
+	}
+	if err := RegisterPlatform("platform", "Arch", []string{runtime.GOOS + "/" + runtime.GOARCH}, NewDefaultValue); err != nil {
+		t.Fatalf("RegisterPlatform failed: %v", err)
+	}
+	if err := RegisterPlatform("platform", "Other", []string{"plan9", "aix/ppc64"}, NewDefaultValue); err != nil {
+		t.Fatalf("RegisterPlatform failed: %v", err)
+	}
+
+	for _, type_ := range []string{"Current", "Arch"} {
+		obj, err := New("platform", type_, nil)
+		if err != nil {
+			t.Fatalf("New %s failed: %v", type_, err)
+		}
+		if obj.(*Value).Name != "default" {
+			t.Errorf("New %s returned %+v", type_, obj)
+		}
+	}
+
+	_, err := New("platform", "Other", nil)
+	if !errors.Is(err, ErrUnsupportedPlatform) {
+		t.Fatalf("expected ErrUnsupportedPlatform, got %v", err)
+	}
+	for _, want := range []string{
+		"type platform:Other not supported on this platform",
+		"platform: " + runtime.GOOS + "/" + runtime.GOARCH,
+		"supported: plan9, aix/ppc64",
+		"available: Arch, Current",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not contain %q", err, want)
+		}
+	}
+
+	// 不可用的类型不能再注册构造函数
+	if err := Register("platform", "Other", NewDefaultValue); err == nil {
+		t.Error("expected error when registering constructor for unsupported type")
+	}
+}
+
+func TestRegisterUnsupported(t *testing.T) {
+	MustRegisterUnsupported("unsupported", "Journald", "linux")
+	MustRegisterUnsupported("unsupported", "Journald", "linux")
+
+	MustRegister("registered", "Value", NewDefaultValue)
+	if err := RegisterUnsupported("registered", "Value", "linux"); err == nil {
+		t.Error("expected error when marking registered type as unsupported")
+	}
+
+	_, err := NewWithOptions(&TypeOptions{Namespace: "unsupported", Type: "Journald", Retry: &RetryOptions{MaxAttempts: 3}})
+	if !errors.Is(err, ErrUnsupportedPlatform) {
+		t.Fatalf("expected ErrUnsupportedPlatform, got %v", err)
+	}
+	if strings.Contains(err.Error(), "available") {
+		t.Errorf("error %q should not list available types for empty namespace", err)
+	}
+}
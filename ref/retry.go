@@ -0,0 +1,60 @@
+package ref
+
+import (
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/hatlonely/gox/cfg/commonopt"
+)
+
+// RetryOptions 构造失败时的重试策略
+// 用于启动时依赖暂时不可用（DNS 抖动、数据库重启等）的场景，避免单个组件失败导致整个构造树失败
+type RetryOptions = commonopt.RetryOptions
+
+// RetryHandler 构造失败回调，每次尝试失败都会调用，attempt 从 1 开始
+type RetryHandler func(namespace string, type_ string, attempt int, err error)
+
+var retryHandler atomic.Pointer[RetryHandler]
+
+// SetRetryHandler 设置构造失败回调，传入 nil 时恢复默认行为：输出到标准错误
+// ref 处于依赖的最底层，不能依赖日志包，需要接入日志时通过回调转发
+func SetRetryHandler(handler RetryHandler) {
+	if handler == nil {
+		retryHandler.Store(nil)
+		return
+	}
+	retryHandler.Store(&handler)
+}
+
+// reportRetry 上报一次构造失败
+func reportRetry(namespace string, type_ string, attempt int, err error) {
+	if handler := retryHandler.Load(); handler != nil {
+		(*handler)(namespace, type_, attempt, err)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "ref: failed to construct %s:%s, attempt %d: %v\n", namespace, type_, attempt, err)
+}
+
+// newWithRetry 按重试策略调用构造函数，返回最后一次的错误
+// 只重试构造函数本身返回的错误，构造函数未注册等配置错误不会重试
+func newWithRetry(namespace string, type_ string, options any, retry *RetryOptions) (any, error) {
+	constructor, err := lookup(namespace, type_)
+	if err != nil {
+		return nil, err
+	}
+
+	for attempt := 1; ; attempt++ {
+		v, err := constructor.newOrDefault(options)
+		if err == nil {
+			return v, nil
+		}
+		reportRetry(namespace, type_, attempt, err)
+		if attempt >= retry.MaxAttempts {
+			return nil, fmt.Errorf("failed to construct %s:%s after %d attempts: %w", namespace, type_, attempt, err)
+		}
+
+		time.Sleep(retry.BackoffFor(attempt))
+	}
+}
@@ -0,0 +1,77 @@
+package ref
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewWithOptionsRetry(t *testing.T) {
+	calls := 0
+	MustRegister("test", "FlakyValue", func(options *Options) (*Value, error) {
+		calls++
+		if calls < 3 {
+			return nil, errors.New("dependency unavailable")
+		}
+		return &Value{Name: options.Name}, nil
+	})
+
+	type attemptRecord struct {
+		key     string
+		attempt int
+	}
+	var attempts []attemptRecord
+	SetRetryHandler(func(namespace string, type_ string, attempt int, err error) {
+		attempts = append(attempts, attemptRecord{key: namespace + ":" + type_, attempt: attempt})
+	})
+	defer SetRetryHandler(nil)
+
+	options := &TypeOptions{
+		Namespace: "test",
+		Type:      "FlakyValue",
+		Options:   &Options{Name: "flaky"},
+		Retry:     &RetryOptions{MaxAttempts: 5, Backoff: time.Millisecond},
+	}
+
+	// 重试后构造成功
+	obj, err := NewWithOptions(options)
+	if err != nil {
+		t.Fatalf("NewWithOptions failed: %v", err)
+	}
+	if obj.(*Value).Name != "flaky" {
+		t.Errorf("expected name flaky, got %s", obj.(*Value).Name)
+	}
+	if calls != 3 {
+		t.Errorf("expected 3 calls, got %d", calls)
+	}
+	if len(attempts) != 2 || attempts[0] != (attemptRecord{"test:FlakyValue", 1}) || attempts[1].attempt != 2 {
+		t.Errorf("unexpected retry reports: %v", attempts)
+	}
+
+	// 达到最大次数后返回最后一次的错误
+	calls = -10
+	attempts = nil
+	options.Retry = &RetryOptions{MaxAttempts: 2, Backoff: time.Millisecond}
+	_, err = NewWithOptions(options)
+	if err == nil || !strings.Contains(err.Error(), "after 2 attempts") || !strings.Contains(err.Error(), "dependency unavailable") {
+		t.Errorf("expected error after 2 attempts, got %v", err)
+	}
+	if len(attempts) != 2 {
+		t.Errorf("expected 2 retry reports, got %d", len(attempts))
+	}
+
+	// 未注册的构造函数不重试
+	attempts = nil
+	_, err = NewWithOptions(&TypeOptions{Namespace: "test", Type: "NotExists", Retry: &RetryOptions{MaxAttempts: 3}})
+	if err == nil || len(attempts) != 0 {
+		t.Errorf("expected immediate error without retry, got %v, attempts %d", err, len(attempts))
+	}
+
+	// 未配置重试时只尝试一次
+	calls = -10
+	options.Retry = nil
+	if _, err := NewWithOptions(options); err == nil || calls != -9 {
+		t.Errorf("expected single attempt without retry, got err %v, calls %d", err, calls)
+	}
+}
@@ -19,7 +19,7 @@ type IntGenerator interface {
 
 // NewIntGeneratorWithOptions 创建整数生成器
 func NewIntGeneratorWithOptions(options *ref.TypeOptions) (IntGenerator, error) {
-	generator, err := ref.New(options.Namespace, options.Type, options.Options)
+	generator, err := ref.NewWithOptions(options)
 	if err != nil {
 		return nil, errors.WithMessage(err, "ref.New failed")
 	}
@@ -17,7 +17,7 @@ type StrGenerator interface {
 
 // NewStrGeneratorWithOptions 创建字符串生成器
 func NewStrGeneratorWithOptions(options *ref.TypeOptions) (StrGenerator, error) {
-	generator, err := ref.New(options.Namespace, options.Type, options.Options)
+	generator, err := ref.NewWithOptions(options)
 	if err != nil {
 		return nil, errors.WithMessage(err, "ref.New failed")
 	}